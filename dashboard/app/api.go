@@ -37,6 +37,9 @@ var apiHandlers = map[string]APIHandler{
 	"need_repro":          apiNeedRepro,
 	"reporting_poll":      apiReportingPoll,
 	"reporting_update":    apiReportingUpdate,
+	"job_poll":            apiJobPoll,
+	"job_done":            apiJobDone,
+	"report_regression":   apiReportRegression,
 }
 
 type JSONHandler func(c context.Context, r *http.Request) (interface{}, error)
@@ -324,6 +327,7 @@ func apiReportCrash(c context.Context, ns string, r *http.Request) (interface{},
 	}
 	req.Title = limitLength(req.Title, maxTextLen)
 	req.Maintainers = email.MergeEmailLists(req.Maintainers)
+	req.Subsystem = limitLength(req.Subsystem, maxTextLen)
 
 	build, err := loadBuild(c, ns, req.BuildID)
 	if err != nil {
@@ -331,12 +335,15 @@ func apiReportCrash(c context.Context, ns string, r *http.Request) (interface{},
 	}
 
 	crash := &Crash{
-		Manager:     build.Manager,
-		BuildID:     req.BuildID,
-		Time:        timeNow(c),
-		Maintainers: req.Maintainers,
-		ReproOpts:   req.ReproOpts,
-		ReportLen:   len(req.Report),
+		Manager:      build.Manager,
+		BuildID:      req.BuildID,
+		Time:         timeNow(c),
+		Maintainers:  req.Maintainers,
+		Subsystem:    req.Subsystem,
+		ReproOpts:    req.ReproOpts,
+		ReproRuns:    req.ReproRuns,
+		ReproCrashes: req.ReproCrashes,
+		ReportLen:    len(req.Report),
 	}
 
 	if crash.Log, err = putText(c, ns, "CrashLog", req.Log, false); err != nil {
@@ -410,6 +417,12 @@ func apiReportCrash(c context.Context, ns string, r *http.Request) (interface{},
 		if crash.Report != 0 {
 			bug.HasReport = true
 		}
+		if crash.Subsystem != "" {
+			bug.Subsystem = crash.Subsystem
+		}
+		if len(bug.Frames) == 0 {
+			bug.CrashType, bug.Frames = crashSignature(req.Title, req.Report)
+		}
 		if bugKey, err = datastore.Put(c, bugKey, bug); err != nil {
 			return fmt.Errorf("failed to put bug: %v", err)
 		}
@@ -8,6 +8,7 @@ package dash
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/google/syzkaller/dashboard/dashapi"
 )
@@ -23,7 +24,9 @@ var config = GlobalConfig{
 			Key: "test1keytest1keytest1key",
 			Clients: map[string]string{
 				client1: key1,
+				client4: key4,
 			},
+			FixBisectionPeriod: 30 * 24 * time.Hour,
 			Reporting: []Reporting{
 				{
 					Name:       "reporting1",
@@ -35,6 +38,7 @@ var config = GlobalConfig{
 				{
 					Name:       "reporting2",
 					DailyLimit: 3,
+					Public:     true,
 					Config: &TestConfig{
 						Index: 2,
 					},
@@ -61,6 +65,25 @@ var config = GlobalConfig{
 					Config: &EmailConfig{
 						Email:           "bugs@syzkaller.com",
 						MailMaintainers: true,
+						SubsystemLists: map[string]string{
+							"SUBSYSTEM1": "subsystem1@syzkaller.com",
+						},
+					},
+				},
+			},
+		},
+		"test3": &Config{
+			Key: "test3keytest3keytest3key",
+			Clients: map[string]string{
+				client3: key3,
+			},
+			MinimizeConfig: true,
+			Reporting: []Reporting{
+				{
+					Name:       "reporting1",
+					DailyLimit: 3,
+					Config: &EmailConfig{
+						Email: "test3@syzkaller.com",
 					},
 				},
 			},
@@ -71,8 +94,12 @@ var config = GlobalConfig{
 const (
 	client1 = "client1"
 	client2 = "client2"
+	client3 = "client3"
+	client4 = "client4"
 	key1    = "client1keyclient1keyclient1key"
 	key2    = "client2keyclient2keyclient2key"
+	key3    = "client3keyclient3keyclient3key"
+	key4    = "client4keyclient4keyclient4key"
 )
 
 type TestConfig struct {
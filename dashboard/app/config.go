@@ -36,6 +36,12 @@ type Config struct {
 	MailWithoutReport bool
 	// How long should we wait for a C repro before reporting a bug.
 	WaitForRepro time.Duration
+	// Start fix bisection for bugs with reproducers that did not happen for that long.
+	// Zero disables fix bisection.
+	FixBisectionPeriod time.Duration
+	// Minimize kernel config for bugs with reproducers (see JobMinimizeConfig).
+	// The minimized config is attached to bug reports once it is available.
+	MinimizeConfig bool
 	// Reporting config.
 	Reporting []Reporting
 }
@@ -48,6 +54,9 @@ type Reporting struct {
 	Status ReportingStatus
 	// How many new bugs report per day.
 	DailyLimit int
+	// Bugs reported to this stage are exposed via the public JSON export API
+	// (/export/bugs and /export/bug). Must not be set for private/moderation stages.
+	Public bool
 	// Type of reporting and its configuration.
 	// The app has one built-in type, EmailConfig, which reports bugs by email.
 	// And ExternalConfig which can be used to attach any external reporting system (e.g. Bugzilla).
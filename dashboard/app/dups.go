@@ -0,0 +1,183 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package dash
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// This file contains detection of likely duplicate bugs.
+// Different managers/kernels frequently produce slightly different titles
+// for the same bug (e.g. due to different inlining), so we compare crash
+// signatures (crash type + top stack frames) of open bugs in a namespace.
+// Likely duplicates are shown on the bug page and can be merged with one click.
+
+func init() {
+	http.Handle("/dup", handlerWrapper(handleDup))
+}
+
+const (
+	signatureFrames = 5
+	// Minimal similarity of signatures to consider bugs as duplicates.
+	dupSimilarity = 0.6
+)
+
+var (
+	frameRe       = regexp.MustCompile(`(?:^|[ \t\]])([a-zA-Z0-9_.]+)\+0x[0-9a-f]+/0x[0-9a-f]+`)
+	frameSuffixRe = regexp.MustCompile(`(\.(isra|constprop|part|cold)(\.[0-9]+)?)+$`)
+	// Frames that are present in most reports and don't identify the bug.
+	frameBlacklist = []string{
+		"dump_stack", "__dump_stack", "show_stack", "print_address_description",
+		"kasan_", "__kasan_", "__asan_", "check_memory_region", "kmsan_", "__msan_",
+		"ubsan_", "__ubsan_", "__warn", "warn_slowpath", "report_bug", "fixup_bug",
+		"do_trap", "do_error_trap", "do_invalid_op", "invalid_op", "panic",
+		"lockdep_", "print_", "__sanitizer_cov_", "trace_hardirqs",
+		"entry_SYSCALL", "do_syscall_", "do_fast_syscall",
+	}
+)
+
+// crashSignature returns crash type (title without the function name)
+// and normalized top stack frames of the crash report.
+func crashSignature(title string, report []byte) (string, []string) {
+	typ := title
+	if pos := strings.LastIndex(title, " in "); pos > 0 {
+		typ = title[:pos]
+	}
+	var frames []string
+	for _, line := range strings.Split(string(report), "\n") {
+		if strings.Contains(line, " ? ") {
+			continue // questionable frame
+		}
+		match := frameRe.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		frame := frameSuffixRe.ReplaceAllString(match[1], "")
+		if blacklistedFrame(frame) {
+			continue
+		}
+		if len(frames) != 0 && frames[len(frames)-1] == frame {
+			continue
+		}
+		frames = append(frames, frame)
+		if len(frames) == signatureFrames {
+			break
+		}
+	}
+	return typ, frames
+}
+
+func blacklistedFrame(frame string) bool {
+	for _, prefix := range frameBlacklist {
+		if strings.HasPrefix(frame, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// signatureSimilarity returns similarity of two crash signatures in [0, 1].
+func signatureSimilarity(typ1 string, frames1 []string, typ2 string, frames2 []string) float64 {
+	if typ1 != typ2 || len(frames1) == 0 || len(frames2) == 0 {
+		return 0
+	}
+	set := make(map[string]bool)
+	for _, frame := range frames1 {
+		set[frame] = true
+	}
+	common := 0
+	for _, frame := range frames2 {
+		if set[frame] {
+			common++
+		}
+	}
+	max := len(frames1)
+	if max < len(frames2) {
+		max = len(frames2)
+	}
+	return float64(common) / float64(max)
+}
+
+// findLikelyDups returns open bugs in the same namespace with signatures similar to bug.
+func findLikelyDups(c context.Context, bug *Bug, state *ReportingState) ([]*uiBug, error) {
+	if bug.Status != BugStatusOpen || len(bug.Frames) == 0 {
+		return nil, nil
+	}
+	var bugs []*Bug
+	_, err := datastore.NewQuery("Bug").
+		Filter("Namespace=", bug.Namespace).
+		Filter("Status=", BugStatusOpen).
+		GetAll(c, &bugs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bugs: %v", err)
+	}
+	type candidate struct {
+		bug        *uiBug
+		similarity float64
+	}
+	var candidates []candidate
+	for _, other := range bugs {
+		if other.Title == bug.Title && other.Seq == bug.Seq {
+			continue
+		}
+		sim := signatureSimilarity(bug.CrashType, bug.Frames, other.CrashType, other.Frames)
+		if sim < dupSimilarity {
+			continue
+		}
+		candidates = append(candidates, candidate{createUIBug(c, other, state), sim})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+	var res []*uiBug
+	for _, cand := range candidates {
+		res = append(res, cand.bug)
+	}
+	return res, nil
+}
+
+// handleDup marks bug id as a duplicate of bug dup (both are passed as bug IDs).
+func handleDup(c context.Context, w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return fmt.Errorf("dup requires POST request")
+	}
+	id, dupID := r.FormValue("id"), r.FormValue("dup")
+	bug := new(Bug)
+	if err := datastore.Get(c, datastore.NewKey(c, "Bug", id, 0, nil), bug); err != nil {
+		return fmt.Errorf("failed to get bug %v: %v", id, err)
+	}
+	dup := new(Bug)
+	if err := datastore.Get(c, datastore.NewKey(c, "Bug", dupID, 0, nil), dup); err != nil {
+		return fmt.Errorf("failed to get bug %v: %v", dupID, err)
+	}
+	_, bugReporting, _, _, err := currentReporting(c, bug)
+	if err != nil {
+		return err
+	}
+	if bugReporting == nil {
+		return fmt.Errorf("bug %q is not in any reporting", bug.displayTitle())
+	}
+	cmd := &dashapi.BugUpdate{
+		ID:     bugReporting.ID,
+		Status: dashapi.BugStatusDup,
+	}
+	for _, dupReporting := range dup.Reporting {
+		if dupReporting.Name == bugReporting.Name {
+			cmd.DupOf = dupReporting.ID
+		}
+	}
+	if reply, ok := incomingCommand(c, cmd); !ok {
+		return fmt.Errorf("failed to mark bug as dup: %v", reply)
+	}
+	http.Redirect(w, r, "/bug?id="+id, http.StatusFound)
+	return nil
+}
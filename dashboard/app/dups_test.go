@@ -0,0 +1,109 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// +build aetest
+
+package dash
+
+import (
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+)
+
+const dupReport1 = `BUG: KASAN: use-after-free in skb_release_data+0x5b6/0x600 net/core/skbuff.c:561
+Read of size 8 at addr ffff88003a7a1a38 by task syz-executor/3114
+
+CPU: 1 PID: 3114 Comm: syz-executor Not tainted 4.14.0-rc4+ #80
+Call Trace:
+ __dump_stack lib/dump_stack.c:16 [inline]
+ dump_stack+0x194/0x257 lib/dump_stack.c:52
+ print_address_description+0x73/0x250 mm/kasan/report.c:252
+ kasan_report_error mm/kasan/report.c:351 [inline]
+ kasan_report+0x25b/0x340 mm/kasan/report.c:409
+ __asan_report_load8_noabort+0x14/0x20 mm/kasan/report.c:430
+ skb_release_data+0x5b6/0x600 net/core/skbuff.c:561
+ ? rcu_read_lock_sched_held+0x108/0x120 include/linux/rcupdate.h:645
+ skb_release_all+0x4a/0x60 net/core/skbuff.c:640
+ __kfree_skb net/core/skbuff.c:654 [inline]
+ kfree_skb+0x15d/0x4c0 net/core/skbuff.c:675
+ tcp_close.isra.12+0x2a8/0x1070 net/ipv4/tcp.c:2258
+ inet_release+0xed/0x1c0 net/ipv4/af_inet.c:425
+`
+
+const dupReport2 = `BUG: KASAN: use-after-free in skb_release_all+0x4a/0x60 net/core/skbuff.c:640
+Read of size 8 at addr ffff88003a7a1a38 by task syz-executor/3114
+
+Call Trace:
+ dump_stack+0x194/0x257 lib/dump_stack.c:52
+ kasan_report+0x25b/0x340 mm/kasan/report.c:409
+ skb_release_data.constprop.3+0x5b6/0x600 net/core/skbuff.c:561
+ skb_release_all+0x4a/0x60 net/core/skbuff.c:640
+ kfree_skb+0x15d/0x4c0 net/core/skbuff.c:675
+ tcp_close+0x2a8/0x1070 net/ipv4/tcp.c:2258
+ sock_close+0x16/0x20 net/socket.c:1126
+`
+
+func TestCrashSignature(t *testing.T) {
+	typ, frames := crashSignature("KASAN: use-after-free Read in skb_release_data", []byte(dupReport1))
+	if typ != "KASAN: use-after-free Read" {
+		t.Fatalf("bad crash type %q", typ)
+	}
+	want := []string{"skb_release_data", "skb_release_all", "kfree_skb", "tcp_close", "inet_release"}
+	if len(frames) != len(want) {
+		t.Fatalf("got frames %q, want %q", frames, want)
+	}
+	for i := range want {
+		if frames[i] != want[i] {
+			t.Fatalf("got frames %q, want %q", frames, want)
+		}
+	}
+	typ2, frames2 := crashSignature("KASAN: use-after-free Read in skb_release_all", []byte(dupReport2))
+	if sim := signatureSimilarity(typ, frames, typ2, frames2); sim < dupSimilarity {
+		t.Fatalf("similar signatures have low similarity %v", sim)
+	}
+	if sim := signatureSimilarity(typ, frames, "WARNING", frames2); sim != 0 {
+		t.Fatalf("different crash types have similarity %v", sim)
+	}
+}
+
+func TestLikelyDups(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build1 := testBuild(1)
+	c.expectOK(c.API(client1, key1, "upload_build", build1, nil))
+	build2 := testBuild(2)
+	c.expectOK(c.API(client1, key1, "upload_build", build2, nil))
+
+	crash1 := testCrash(build1, 1)
+	crash1.Title = "KASAN: use-after-free Read in skb_release_data"
+	crash1.Report = []byte(dupReport1)
+	c.expectOK(c.API(client1, key1, "report_crash", crash1, nil))
+
+	crash2 := testCrash(build2, 2)
+	crash2.Title = "KASAN: use-after-free Read in skb_release_all"
+	crash2.Report = []byte(dupReport2)
+	c.expectOK(c.API(client1, key1, "report_crash", crash2, nil))
+
+	// Unrelated bug.
+	crash3 := testCrash(build2, 3)
+	c.expectOK(c.API(client1, key1, "report_crash", crash3, nil))
+
+	state, err := loadReportingState(c.ctx)
+	c.expectOK(err)
+	bug2 := new(Bug)
+	bug2Key := datastore.NewKey(c.ctx, "Bug", bugKeyHash("test1", crash2.Title, 0), 0, nil)
+	c.expectOK(datastore.Get(c.ctx, bug2Key, bug2))
+	dups, err := findLikelyDups(c.ctx, bug2, state)
+	c.expectOK(err)
+	c.expectEQ(len(dups), 1)
+	bug1ID := bugKeyHash("test1", crash1.Title, 0)
+	c.expectEQ(dups[0].ID, bug1ID)
+	c.expectOK(c.GET("/bug?id=" + bug2Key.StringID()))
+
+	c.expectOK(c.POST("/dup?id="+bug2Key.StringID()+"&dup="+bug1ID, ""))
+	c.expectOK(datastore.Get(c.ctx, bug2Key, bug2))
+	c.expectEQ(bug2.Status, BugStatusDup)
+	c.expectEQ(bug2.DupOf, bug1ID)
+}
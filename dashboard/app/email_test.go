@@ -236,6 +236,8 @@ Content-Type: text/plain
 
 	// Now upload a C reproducer.
 	crash.ReproC = []byte("int main() {}")
+	crash.ReproRuns = 5
+	crash.ReproCrashes = 3
 	crash.Maintainers = []string{"\"qux\" <qux@qux.com>"}
 	c.expectOK(c.API(client2, key2, "report_crash", crash, nil))
 
@@ -268,6 +270,7 @@ Raw console output is attached.
 C reproducer is attached
 syzkaller reproducer is attached. See https://goo.gl/kgGztJ
 for information about syzkaller reproducers
+The reproducer is flaky, it triggered the crash 3 times out of 5 runs.
 
 
 report1
@@ -439,3 +442,45 @@ Content-Type: text/plain
 		c.expectEQ(msg.Subject, crash2.Title+" (2)")
 	}
 }
+
+// Bugs in subsystems with a configured mailing list must be mailed to the list.
+func TestEmailSubsystem(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build := testBuild(1)
+	c.expectOK(c.API(client2, key2, "upload_build", build, nil))
+
+	crash := testCrash(build, 1)
+	crash.Maintainers = []string{`foo@bar.com`}
+	crash.Subsystem = "SUBSYSTEM1"
+	c.expectOK(c.API(client2, key2, "report_crash", crash, nil))
+
+	c.expectOK(c.GET("/email_poll"))
+	c.expectEQ(len(c.emailSink), 1)
+	msg := <-c.emailSink
+	c.expectEQ(msg.To, []string{"test@syzkaller.com"})
+	if !strings.Contains(msg.Body, "\nsubsystem: SUBSYSTEM1\n") {
+		t.Fatalf("no subsystem in the report:\n%v", msg.Body)
+	}
+
+	incoming1 := fmt.Sprintf(`Sender: syzkaller@googlegroups.com
+Date: Tue, 15 Aug 2017 14:59:00 -0700
+Message-ID: <1234>
+Subject: crash1
+From: %v
+To: foo@bar.com
+Content-Type: text/plain
+
+#syz upstream
+`, msg.Sender)
+	c.expectOK(c.POST("/_ah/mail/", incoming1))
+
+	c.expectOK(c.GET("/email_poll"))
+	c.expectEQ(len(c.emailSink), 1)
+	msg = <-c.emailSink
+	c.expectEQ(msg.To, []string{"bugs@syzkaller.com", "foo@bar.com", "subsystem1@syzkaller.com"})
+
+	c.expectOK(c.GET("/subsystem?name=SUBSYSTEM1"))
+	c.expectOK(c.GET("/"))
+}
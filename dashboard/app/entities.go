@@ -55,6 +55,21 @@ type Bug struct {
 	Reporting  []BugReporting
 	Commits    []string
 	PatchedOn  []string
+	Subsystem  string // MAINTAINERS entry responsible for the guilty file of the latest crash
+	// Crash signature used for duplicate detection (see crashSignature).
+	CrashType string
+	Frames    []string `datastore:",noindex"`
+	// Fix bisection status and result.
+	BisectFix        int
+	FixCandidate     string // title of the commit identified by fix bisection
+	FixCandidateHash string
+	// Config minimization status (Bisect* consts) and result.
+	MinimizeConfig  int
+	KernelConfigMin int64 // reference to KernelConfig text entity
+	// Result of the last regression test of the bug reproducer (Regression* consts).
+	RegressionStatus int
+	RegressionBuild  string
+	RegressionTime   time.Time
 }
 
 type BugReporting struct {
@@ -69,16 +84,66 @@ type BugReporting struct {
 }
 
 type Crash struct {
-	Manager     string
-	BuildID     string
-	Time        time.Time
-	Maintainers []string `datastore:",noindex"`
-	Log         int64    // reference to CrashLog text entity
-	Report      int64    // reference to CrashReport text entity
-	ReproOpts   []byte   `datastore:",noindex"`
-	ReproSyz    int64    // reference to ReproSyz text entity
-	ReproC      int64    // reference to ReproC text entity
-	ReportLen   int
+	Manager      string
+	BuildID      string
+	Time         time.Time
+	Maintainers  []string `datastore:",noindex"`
+	Subsystem    string   `datastore:",noindex"`
+	Log          int64    // reference to CrashLog text entity
+	Report       int64    // reference to CrashReport text entity
+	ReproOpts    []byte   `datastore:",noindex"`
+	ReproSyz     int64    // reference to ReproSyz text entity
+	ReproC       int64    // reference to ReproC text entity
+	ReproRuns    int      // number of runs used to measure repro reliability, 0 if not measured
+	ReproCrashes int      // number of runs that triggered the crash
+	ReportLen    int
+}
+
+// Job is a long-running task (e.g. fix bisection or patch testing) executed by syz-ci.
+// Jobs are stored as children of the bug they refer to.
+type Job struct {
+	Type         dashapi.JobType
+	Created      time.Time
+	Namespace    string
+	Manager      string
+	BugTitle     string // Bug.Title without the seq suffix, crashes are matched against it
+	CrashID      int64  // ID of the crash with the reproducer (child of the bug)
+	KernelRepo   string
+	KernelBranch string
+	KernelCommit string
+
+	// Patch testing request parameters.
+	User        string   // email of the user who requested testing
+	CC          []string `datastore:",noindex"`
+	ReportingID string   // BugReporting.ID the request came from
+	ExtID       string   // Message-ID of the request, result is sent as a reply to it
+	Link        string   // link to the request (e.g. mailing list archive)
+	Patch       int64    // reference to Patch text entity
+
+	Attempts int    // number of times the job was handed out to syz-ci
+	Client   string // API client that took the job last, only it can report results
+	Started  time.Time
+	Finished time.Time // if set, the job is done
+	Error    int64     // reference to Error text entity, if set the job has failed
+	Log      int64     // reference to JobLog text entity
+	Commits  []string  `datastore:",noindex"` // "hash title" of resulting commits
+
+	CrashTitle  string // if set, the patch did not fix the crash
+	CrashReport int64  // reference to CrashReport text entity
+
+	KernelConfigMin int64 // reference to KernelConfig text entity with minimized config
+}
+
+// Regression holds results of re-running known reproducers on a single build.
+type Regression struct {
+	Namespace    string
+	Manager      string
+	BuildID      string
+	Time         time.Time
+	Fixed        []string `datastore:",noindex"` // titles of bugs that did not reproduce
+	Reproduced   []string `datastore:",noindex"` // titles of bugs that still reproduce
+	Regressed    []string `datastore:",noindex"` // titles of fixed bugs that reproduce again
+	Inconclusive []string `datastore:",noindex"` // titles of bugs with reproducers that triggered other crashes
 }
 
 // ReportingState holds dynamic info associated with reporting.
@@ -110,6 +175,21 @@ const (
 	BugStatusDup
 )
 
+const (
+	BisectNot = iota
+	BisectPending
+	BisectError
+	BisectDone
+)
+
+const (
+	RegressionNot = iota
+	RegressionFixed
+	RegressionReproduced
+	RegressionRegressed
+	RegressionInconclusive
+)
+
 const (
 	ReproLevelNone = dashapi.ReproLevelNone
 	ReproLevelSyz  = dashapi.ReproLevelSyz
@@ -0,0 +1,161 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package dash
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// This file contains public read-only JSON API (/export/bugs, /export/bug).
+// The API does not require authorization, so it exposes only bugs
+// that were reported to a reporting stage with Public set.
+
+func init() {
+	http.Handle("/export/bugs", handleJSON(handleExportBugs))
+	http.Handle("/export/bug", handleJSON(handleExportBug))
+}
+
+// handleExportBugs returns list of public bugs in namespace ns,
+// optionally filtered by status (open, fixed, invalid, dup).
+func handleExportBugs(c context.Context, r *http.Request) (interface{}, error) {
+	ns := r.FormValue("ns")
+	if config.Namespaces[ns] == nil {
+		return nil, fmt.Errorf("unknown namespace %q", ns)
+	}
+	query := datastore.NewQuery("Bug").Filter("Namespace=", ns)
+	if statusStr := r.FormValue("status"); statusStr != "" {
+		status, ok := exportStatuses[statusStr]
+		if !ok {
+			return nil, fmt.Errorf("unknown bug status %q", statusStr)
+		}
+		query = query.Filter("Status=", status)
+	}
+	var bugs []*Bug
+	if _, err := query.GetAll(c, &bugs); err != nil {
+		return nil, fmt.Errorf("failed to query bugs: %v", err)
+	}
+	res := []*dashapi.ExportBug{}
+	for _, bug := range bugs {
+		if !bugIsPublic(bug) {
+			continue
+		}
+		res = append(res, exportBug(bug))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].FirstTime.Before(res[j].FirstTime)
+	})
+	return res, nil
+}
+
+// handleExportBug returns a single public bug with its crashes.
+func handleExportBug(c context.Context, r *http.Request) (interface{}, error) {
+	bug := new(Bug)
+	bugKey := datastore.NewKey(c, "Bug", r.FormValue("id"), 0, nil)
+	if err := datastore.Get(c, bugKey, bug); err != nil || !bugIsPublic(bug) {
+		// Don't distinguish non-existent and non-public bugs.
+		return nil, fmt.Errorf("no bug %q", r.FormValue("id"))
+	}
+	res := exportBug(bug)
+	crashes, err := queryCrashesForBug(c, bugKey, maxCrashes)
+	if err != nil {
+		return nil, err
+	}
+	builds := make(map[string]*Build)
+	for _, crash := range crashes {
+		build := builds[crash.BuildID]
+		if build == nil {
+			build, err = loadBuild(c, bug.Namespace, crash.BuildID)
+			if err != nil {
+				return nil, err
+			}
+			builds[crash.BuildID] = build
+		}
+		ec := &dashapi.ExportCrash{
+			Manager:         crash.Manager,
+			Time:            crash.Time,
+			OS:              build.OS,
+			Arch:            build.Arch,
+			CompilerID:      build.CompilerID,
+			KernelRepo:      build.KernelRepo,
+			KernelBranch:    build.KernelBranch,
+			KernelCommit:    build.KernelCommit,
+			SyzkallerCommit: build.SyzkallerCommit,
+			ReproOpts:       crash.ReproOpts,
+		}
+		if ec.Report, err = getText(c, "CrashReport", crash.Report); err != nil {
+			return nil, err
+		}
+		if ec.ReproSyz, err = getText(c, "ReproSyz", crash.ReproSyz); err != nil {
+			return nil, err
+		}
+		if ec.ReproC, err = getText(c, "ReproC", crash.ReproC); err != nil {
+			return nil, err
+		}
+		res.Crashes = append(res.Crashes, ec)
+	}
+	return res, nil
+}
+
+var exportStatuses = map[string]int{
+	"open":    BugStatusOpen,
+	"fixed":   BugStatusFixed,
+	"invalid": BugStatusInvalid,
+	"dup":     BugStatusDup,
+}
+
+// bugIsPublic says if the bug was reported to at least one public reporting stage.
+func bugIsPublic(bug *Bug) bool {
+	cfg := config.Namespaces[bug.Namespace]
+	if cfg == nil {
+		return false
+	}
+	for _, bugReporting := range bug.Reporting {
+		reporting := cfg.ReportingByName(bugReporting.Name)
+		if reporting != nil && reporting.Public && !bugReporting.Reported.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+func exportBug(bug *Bug) *dashapi.ExportBug {
+	res := &dashapi.ExportBug{
+		ID:         bugKeyHash(bug.Namespace, bug.Title, bug.Seq),
+		Namespace:  bug.Namespace,
+		Title:      bug.displayTitle(),
+		Subsystem:  bug.Subsystem,
+		NumCrashes: bug.NumCrashes,
+		NumRepro:   bug.NumRepro,
+		ReproLevel: bug.ReproLevel,
+		FirstTime:  bug.FirstTime,
+		LastTime:   bug.LastTime,
+		ClosedTime: bug.Closed,
+		FixCommits: bug.Commits,
+	}
+	for status, v := range exportStatuses {
+		if bug.Status == v {
+			res.Status = status
+		}
+	}
+	if bug.Status == BugStatusDup {
+		res.DupOf = bug.DupOf
+	}
+	if bug.BisectFix == BisectDone {
+		res.FixCandidate = bug.FixCandidate
+	}
+	cfg := config.Namespaces[bug.Namespace]
+	for _, bugReporting := range bug.Reporting {
+		reporting := cfg.ReportingByName(bugReporting.Name)
+		if reporting != nil && reporting.Public && bugReporting.Link != "" {
+			res.Links = append(res.Links, bugReporting.Link)
+		}
+	}
+	return res
+}
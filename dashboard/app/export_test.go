@@ -0,0 +1,78 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// +build aetest
+
+package dash
+
+import (
+	"testing"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+)
+
+func TestExport(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build := testBuild(1)
+	c.expectOK(c.API(client1, key1, "upload_build", build, nil))
+
+	crash := testCrash(build, 1)
+	crash.Subsystem = "SUBSYSTEM1"
+	crash.ReproOpts = []byte("repro opts")
+	crash.ReproSyz = []byte("getpid()")
+	c.expectOK(c.API(client1, key1, "report_crash", crash, nil))
+
+	// The bug is reported only to the private reporting, so it is not exported.
+	rep := reportAllBugs(c, 1)[0]
+	reply := new(dashapi.BugUpdateReply)
+	c.expectOK(c.API(client1, key1, "reporting_update", &dashapi.BugUpdate{
+		ID:     rep.ID,
+		Status: dashapi.BugStatusOpen,
+	}, reply))
+	c.expectEQ(reply.OK, true)
+
+	var bugs []*dashapi.ExportBug
+	c.expectOK(c.GETJSON("/export/bugs?ns=test1", &bugs))
+	c.expectEQ(len(bugs), 0)
+	c.expectFail("unknown namespace", c.GET("/export/bugs?ns=foo"))
+
+	// Upstream the bug to the public reporting.
+	c.expectOK(c.API(client1, key1, "reporting_update", &dashapi.BugUpdate{
+		ID:     rep.ID,
+		Status: dashapi.BugStatusUpstream,
+	}, reply))
+	rep2 := reportAllBugs(c, 1)[0]
+	c.expectOK(c.API(client1, key1, "reporting_update", &dashapi.BugUpdate{
+		ID:     rep2.ID,
+		Status: dashapi.BugStatusOpen,
+		Link:   "http://public/bug1",
+	}, reply))
+	c.expectEQ(reply.OK, true)
+
+	c.expectOK(c.GETJSON("/export/bugs?ns=test1", &bugs))
+	c.expectEQ(len(bugs), 1)
+	c.expectOK(c.GETJSON("/export/bugs?ns=test1&status=fixed", &bugs))
+	c.expectEQ(len(bugs), 0)
+	c.expectOK(c.GETJSON("/export/bugs?ns=test1&status=open", &bugs))
+	c.expectEQ(len(bugs), 1)
+	bug := bugs[0]
+	c.expectEQ(bug.Title, crash.Title)
+	c.expectEQ(bug.Status, "open")
+	c.expectEQ(bug.Subsystem, "SUBSYSTEM1")
+	c.expectEQ(bug.NumCrashes, int64(1))
+	c.expectEQ(bug.ReproLevel, dashapi.ReproLevelSyz)
+	c.expectEQ(bug.Links, []string{"http://public/bug1"})
+	c.expectEQ(len(bug.Crashes), 0)
+
+	full := new(dashapi.ExportBug)
+	c.expectOK(c.GETJSON("/export/bug?id="+bug.ID, full))
+	c.expectEQ(full.ID, bug.ID)
+	c.expectEQ(len(full.Crashes), 1)
+	c.expectEQ(full.Crashes[0].KernelCommit, build.KernelCommit)
+	c.expectEQ(full.Crashes[0].Report, crash.Report)
+	c.expectEQ(full.Crashes[0].ReproSyz, crash.ReproSyz)
+	c.expectEQ(full.Crashes[0].ReproOpts, crash.ReproOpts)
+	c.expectFail("no bug", c.GET("/export/bug?id=foo"))
+}
@@ -0,0 +1,522 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package dash
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+)
+
+// This file contains logic of jobs executed by syz-ci
+// (fix bisection, patch testing, config minimization).
+// Patch testing jobs are created on user requests (see handleTestRequest).
+// Fix bisection and config minimization jobs are created lazily on job_poll requests:
+// if there are no pending jobs for the polling managers, we look for a bug that needs one.
+
+const (
+	// If syz-ci took a job, but did not report results for that long,
+	// the job is handed out again (e.g. syz-ci was restarted).
+	jobTimeout     = 24 * time.Hour
+	maxJobAttempts = 3
+)
+
+func apiJobPoll(c context.Context, ns string, r *http.Request) (interface{}, error) {
+	req := new(dashapi.JobPollReq)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request: %v", err)
+	}
+	if len(req.Managers) == 0 {
+		return nil, fmt.Errorf("no managers")
+	}
+	managers := make(map[string]bool)
+	for _, mgr := range req.Managers {
+		managers[mgr] = true
+	}
+	job, jobKey, err := findPendingJob(c, ns, managers)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		job, jobKey, err = createFixBisectionJob(c, ns, managers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if job == nil {
+		job, jobKey, err = createConfigMinimizationJob(c, ns, managers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp := new(dashapi.JobPollResp)
+	if job == nil {
+		return resp, nil
+	}
+	now := timeNow(c)
+	taken := false
+	tx := func(c context.Context) error {
+		job = new(Job)
+		if err := datastore.Get(c, jobKey, job); err != nil {
+			return fmt.Errorf("failed to get job: %v", err)
+		}
+		// Somebody else could take the job since we've queried it.
+		if !jobAvailable(c, job) {
+			return nil
+		}
+		job.Attempts++
+		job.Started = now
+		job.Client = r.FormValue("client")
+		if _, err := datastore.Put(c, jobKey, job); err != nil {
+			return fmt.Errorf("failed to put job: %v", err)
+		}
+		taken = true
+		return nil
+	}
+	if err := datastore.RunInTransaction(c, tx, nil); err != nil {
+		return nil, err
+	}
+	if !taken {
+		return resp, nil
+	}
+	crash := new(Crash)
+	crashKey := datastore.NewKey(c, "Crash", "", job.CrashID, jobKey.Parent())
+	if err := datastore.Get(c, crashKey, crash); err != nil {
+		return nil, fmt.Errorf("failed to get crash: %v", err)
+	}
+	build, err := loadBuild(c, ns, crash.BuildID)
+	if err != nil {
+		return nil, err
+	}
+	kernelConfig, err := getText(c, "KernelConfig", build.KernelConfig)
+	if err != nil {
+		return nil, err
+	}
+	reproC, err := getText(c, "ReproC", crash.ReproC)
+	if err != nil {
+		return nil, err
+	}
+	reproSyz, err := getText(c, "ReproSyz", crash.ReproSyz)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := getText(c, "Patch", job.Patch)
+	if err != nil {
+		return nil, err
+	}
+	resp.ID = jobKey.Encode()
+	resp.Type = job.Type
+	resp.Manager = job.Manager
+	resp.BugTitle = job.BugTitle
+	resp.KernelRepo = job.KernelRepo
+	resp.KernelBranch = job.KernelBranch
+	resp.KernelCommit = job.KernelCommit
+	resp.KernelConfig = kernelConfig
+	resp.ReproOpts = crash.ReproOpts
+	resp.ReproSyz = reproSyz
+	resp.ReproC = reproC
+	resp.Patch = patch
+	return resp, nil
+}
+
+func findPendingJob(c context.Context, ns string, managers map[string]bool) (*Job, *datastore.Key, error) {
+	var jobs []*Job
+	keys, err := datastore.NewQuery("Job").
+		Filter("Namespace=", ns).
+		Filter("Finished=", time.Time{}).
+		GetAll(c, &jobs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query jobs: %v", err)
+	}
+	for i, job := range jobs {
+		if !managers[job.Manager] {
+			continue
+		}
+		if !job.Started.IsZero() && timeSince(c, job.Started) < jobTimeout {
+			continue
+		}
+		if job.Attempts >= maxJobAttempts {
+			// syz-ci took the job maxJobAttempts times, but never reported results.
+			// Fail it, otherwise the bug stays pending forever.
+			req := &dashapi.JobDoneReq{
+				ID:    keys[i].Encode(),
+				Error: []byte(fmt.Sprintf("job was not finished after %v attempts", job.Attempts)),
+			}
+			if err := jobDone(c, ns, "", req); err != nil {
+				log.Errorf(c, "failed to fail job: %v", err)
+			}
+			continue
+		}
+		return job, keys[i], nil
+	}
+	return nil, nil, nil
+}
+
+// jobAvailable says if the job can be handed out to syz-ci.
+func jobAvailable(c context.Context, job *Job) bool {
+	return job.Finished.IsZero() &&
+		(job.Started.IsZero() || timeSince(c, job.Started) >= jobTimeout) &&
+		job.Attempts < maxJobAttempts
+}
+
+// createFixBisectionJob looks for an open bug with a reproducer that did not happen
+// for FixBisectionPeriod and creates a fix bisection job for it.
+func createFixBisectionJob(c context.Context, ns string, managers map[string]bool) (*Job, *datastore.Key, error) {
+	period := config.Namespaces[ns].FixBisectionPeriod
+	if period == 0 {
+		return nil, nil, nil
+	}
+	need := func(bug *Bug) bool {
+		return len(bug.Commits) == 0 && timeSince(c, bug.LastTime) >= period
+	}
+	status := func(bug *Bug) *int { return &bug.BisectFix }
+	return createBugJob(c, ns, managers, dashapi.JobBisectFix, need, status)
+}
+
+// createConfigMinimizationJob looks for an open bug with a reproducer
+// that does not have a minimized config yet and creates a config minimization job for it.
+func createConfigMinimizationJob(c context.Context, ns string, managers map[string]bool) (
+	*Job, *datastore.Key, error) {
+	if !config.Namespaces[ns].MinimizeConfig {
+		return nil, nil, nil
+	}
+	need := func(bug *Bug) bool { return true }
+	status := func(bug *Bug) *int { return &bug.MinimizeConfig }
+	return createBugJob(c, ns, managers, dashapi.JobMinimizeConfig, need, status)
+}
+
+// createBugJob creates a job of type typ for the first open bug with a reproducer
+// that satisfies need and was not yet processed. status returns the bug field
+// that tracks state of this type of jobs (Bisect* consts).
+func createBugJob(c context.Context, ns string, managers map[string]bool, typ dashapi.JobType,
+	need func(*Bug) bool, status func(*Bug) *int) (*Job, *datastore.Key, error) {
+	var bugs []*Bug
+	bugKeys, err := datastore.NewQuery("Bug").
+		Filter("Namespace=", ns).
+		Filter("Status=", BugStatusOpen).
+		GetAll(c, &bugs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query bugs: %v", err)
+	}
+	for i, bug := range bugs {
+		if *status(bug) != BisectNot || bug.ReproLevel == ReproLevelNone || !need(bug) {
+			continue
+		}
+		crash, crashKey, err := findReproCrash(c, bugKeys[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if crash == nil {
+			continue
+		}
+		build, err := loadBuild(c, ns, crash.BuildID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !managers[build.Manager] {
+			continue
+		}
+		job := &Job{
+			Type:         typ,
+			Created:      timeNow(c),
+			Namespace:    ns,
+			Manager:      build.Manager,
+			BugTitle:     bug.Title,
+			CrashID:      crashKey.IntID(),
+			KernelRepo:   build.KernelRepo,
+			KernelBranch: build.KernelBranch,
+			KernelCommit: build.KernelCommit,
+		}
+		var jobKey *datastore.Key
+		bugKey := bugKeys[i]
+		tx := func(c context.Context) error {
+			bug := new(Bug)
+			if err := datastore.Get(c, bugKey, bug); err != nil {
+				return fmt.Errorf("failed to get bug: %v", err)
+			}
+			if *status(bug) != BisectNot {
+				return nil
+			}
+			*status(bug) = BisectPending
+			if _, err := datastore.Put(c, bugKey, bug); err != nil {
+				return fmt.Errorf("failed to put bug: %v", err)
+			}
+			jobKey, err = datastore.Put(c, datastore.NewIncompleteKey(c, "Job", bugKey), job)
+			if err != nil {
+				return fmt.Errorf("failed to put job: %v", err)
+			}
+			return nil
+		}
+		if err := datastore.RunInTransaction(c, tx, nil); err != nil {
+			return nil, nil, err
+		}
+		if jobKey == nil {
+			continue
+		}
+		log.Infof(c, "created %v job for bug %q", formatJobType(typ), bug.Title)
+		return job, jobKey, nil
+	}
+	return nil, nil, nil
+}
+
+func findReproCrash(c context.Context, bugKey *datastore.Key) (*Crash, *datastore.Key, error) {
+	var crashes []*Crash
+	keys, err := datastore.NewQuery("Crash").
+		Ancestor(bugKey).
+		Order("-ReproC").
+		Order("-ReproSyz").
+		Order("-ReportLen").
+		Order("-Time").
+		Limit(1).
+		GetAll(c, &crashes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch crashes: %v", err)
+	}
+	if len(crashes) == 0 || crashes[0].ReproSyz == 0 && crashes[0].ReproC == 0 {
+		return nil, nil, nil
+	}
+	return crashes[0], keys[0], nil
+}
+
+func apiJobDone(c context.Context, ns string, r *http.Request) (interface{}, error) {
+	req := new(dashapi.JobDoneReq)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request: %v", err)
+	}
+	return nil, jobDone(c, ns, r.FormValue("client"), req)
+}
+
+// jobDone records results of the job and updates the bug accordingly.
+// If client is not empty, the job must have been taken by this client
+// (i.e. for one of its managers). Empty client is used for jobs failed by the app itself.
+func jobDone(c context.Context, ns, client string, req *dashapi.JobDoneReq) error {
+	jobKey, err := datastore.DecodeKey(req.ID)
+	if err != nil {
+		return fmt.Errorf("bad job id %q: %v", req.ID, err)
+	}
+	errorID, err := putText(c, ns, "Error", req.Error, false)
+	if err != nil {
+		return err
+	}
+	logID, err := putText(c, ns, "JobLog", req.Log, false)
+	if err != nil {
+		return err
+	}
+	crashReportID, err := putText(c, ns, "CrashReport", req.CrashReport, false)
+	if err != nil {
+		return err
+	}
+	kernelConfigID, err := putText(c, ns, "KernelConfig", req.KernelConfig, true)
+	if err != nil {
+		return err
+	}
+	now := timeNow(c)
+	job := new(Job)
+	bug := new(Bug)
+	tx := func(c context.Context) error {
+		if err := datastore.Get(c, jobKey, job); err != nil {
+			return fmt.Errorf("failed to get job: %v", err)
+		}
+		if job.Namespace != ns {
+			return fmt.Errorf("job %v is in a different namespace", req.ID)
+		}
+		if client != "" && job.Client != client {
+			return fmt.Errorf("job %v was not taken by client %q", req.ID, client)
+		}
+		if !job.Finished.IsZero() {
+			return fmt.Errorf("job %v is already finished", req.ID)
+		}
+		job.Finished = now
+		job.Error = errorID
+		job.Log = logID
+		job.Commits = nil
+		for _, com := range req.Commits {
+			job.Commits = append(job.Commits, fmt.Sprintf("%v %v", com.Hash, com.Title))
+		}
+		job.CrashTitle = req.CrashTitle
+		job.CrashReport = crashReportID
+		job.KernelConfigMin = kernelConfigID
+		if _, err := datastore.Put(c, jobKey, job); err != nil {
+			return fmt.Errorf("failed to put job: %v", err)
+		}
+		bugKey := jobKey.Parent()
+		if err := datastore.Get(c, bugKey, bug); err != nil {
+			return fmt.Errorf("failed to get bug: %v", err)
+		}
+		switch job.Type {
+		case dashapi.JobBisectFix:
+			switch {
+			case len(req.Error) != 0:
+				bug.BisectFix = BisectError
+			case len(req.Commits) == 1:
+				bug.BisectFix = BisectDone
+				bug.FixCandidate = req.Commits[0].Title
+				bug.FixCandidateHash = req.Commits[0].Hash
+			default:
+				// Bisection did not narrow down the range to a single commit.
+				bug.BisectFix = BisectDone
+			}
+		case dashapi.JobMinimizeConfig:
+			if len(req.Error) != 0 || kernelConfigID == 0 {
+				bug.MinimizeConfig = BisectError
+			} else {
+				bug.MinimizeConfig = BisectDone
+				bug.KernelConfigMin = kernelConfigID
+			}
+		default:
+			return nil
+		}
+		if _, err := datastore.Put(c, bugKey, bug); err != nil {
+			return fmt.Errorf("failed to put bug: %v", err)
+		}
+		return nil
+	}
+	if err := datastore.RunInTransaction(c, tx, nil); err != nil {
+		return err
+	}
+	if job.Type == dashapi.JobTestPatch {
+		if err := emailTestResult(c, job, bug, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleTestRequest creates a patch testing job for the bug identified by bugID
+// (reporting ID). If repo/branch are empty, the patch is tested on the tree
+// where the reproducer was obtained. Returns a non-empty reply if the request is rejected.
+func handleTestRequest(c context.Context, bugID, user, extID, link string, patch []byte,
+	repo, branch string, cc []string) string {
+	reply, err := addTestJob(c, bugID, user, extID, link, patch, repo, branch, cc)
+	if err != nil {
+		log.Errorf(c, "test request failed: %v", err)
+		if reply == "" {
+			reply = internalError
+		}
+	}
+	return reply
+}
+
+func addTestJob(c context.Context, bugID, user, extID, link string, patch []byte,
+	repo, branch string, cc []string) (string, error) {
+	bug, bugKey, err := findBugByReportingID(c, bugID)
+	if err != nil {
+		return "can't find the associated bug", err
+	}
+	if bug.ReproLevel == ReproLevelNone {
+		return "This crash does not have a reproducer. I cannot test it.", nil
+	}
+	if len(patch) == 0 {
+		return "I don't see any patch attached to the request.", nil
+	}
+	crash, crashKey, err := findReproCrash(c, bugKey)
+	if err != nil {
+		return "", err
+	}
+	if crash == nil {
+		return "", fmt.Errorf("no crash with reproducer for bug %q", bug.Title)
+	}
+	build, err := loadBuild(c, bug.Namespace, crash.BuildID)
+	if err != nil {
+		return "", err
+	}
+	if repo == "" {
+		repo, branch = build.KernelRepo, build.KernelBranch
+	}
+	patchID, err := putText(c, bug.Namespace, "Patch", patch, false)
+	if err != nil {
+		return "", err
+	}
+	job := &Job{
+		Type:         dashapi.JobTestPatch,
+		Created:      timeNow(c),
+		Namespace:    bug.Namespace,
+		Manager:      build.Manager,
+		BugTitle:     bug.Title,
+		CrashID:      crashKey.IntID(),
+		KernelRepo:   repo,
+		KernelBranch: branch,
+		KernelCommit: build.KernelCommit,
+		User:         user,
+		CC:           cc,
+		ReportingID:  bugID,
+		ExtID:        extID,
+		Link:         link,
+		Patch:        patchID,
+	}
+	if _, err := datastore.Put(c, datastore.NewIncompleteKey(c, "Job", bugKey), job); err != nil {
+		return "", fmt.Errorf("failed to put job: %v", err)
+	}
+	log.Infof(c, "created patch testing job for bug %q", bug.Title)
+	return "", nil
+}
+
+func loadJobsForBug(c context.Context, bugKey *datastore.Key) ([]*uiJob, error) {
+	var jobs []*Job
+	_, err := datastore.NewQuery("Job").
+		Ancestor(bugKey).
+		GetAll(c, &jobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch jobs: %v", err)
+	}
+	var results []*uiJob
+	for _, job := range jobs {
+		ui := &uiJob{
+			Type:         formatJobType(job.Type),
+			Created:      job.Created,
+			Started:      job.Started,
+			Finished:     job.Finished,
+			Manager:      job.Manager,
+			KernelRepo:   job.KernelRepo,
+			KernelBranch: job.KernelBranch,
+			KernelCommit: job.KernelCommit,
+			Commits:      job.Commits,
+			User:         job.User,
+			Link:         job.Link,
+			PatchLink:    textLink("Patch", job.Patch),
+			CrashTitle:   job.CrashTitle,
+			ErrorLink:    textLink("Error", job.Error),
+			LogLink:      textLink("JobLog", job.Log),
+			ReportLink:   textLink("CrashReport", job.CrashReport),
+			ConfigLink:   textLink("KernelConfig", job.KernelConfigMin),
+		}
+		results = append(results, ui)
+	}
+	return results, nil
+}
+
+func formatJobType(typ dashapi.JobType) string {
+	switch typ {
+	case dashapi.JobBisectFix:
+		return "fix bisection"
+	case dashapi.JobTestPatch:
+		return "patch testing"
+	case dashapi.JobMinimizeConfig:
+		return "config minimization"
+	default:
+		return fmt.Sprintf("unknown job type %v", typ)
+	}
+}
+
+func formatBisectStatus(bug *Bug) string {
+	switch bug.BisectFix {
+	case BisectPending:
+		return "pending"
+	case BisectError:
+		return "failed"
+	case BisectDone:
+		if bug.FixCandidate == "" {
+			return "inconclusive"
+		}
+		return fmt.Sprintf("%v %q", bug.FixCandidateHash, bug.FixCandidate)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// +build aetest
+
+package dash
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestFixBisection(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build1 := testBuild(1)
+	c.expectOK(c.API(client1, key1, "upload_build", build1, nil))
+
+	crash1 := testCrash(build1, 1)
+	crash1.ReproOpts = []byte("repro opts")
+	crash1.ReproSyz = []byte("syz repro")
+	c.expectOK(c.API(client1, key1, "report_crash", crash1, nil))
+
+	// Bug without reproducer must not be bisected.
+	crash2 := testCrash(build1, 2)
+	c.expectOK(c.API(client1, key1, "report_crash", crash2, nil))
+
+	pollReq := &dashapi.JobPollReq{Managers: []string{build1.Manager}}
+	pollResp := new(dashapi.JobPollResp)
+	c.expectOK(c.API(client1, key1, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.ID, "")
+
+	// The bug still happens recently, so no bisection yet.
+	c.advanceTime(20 * 24 * time.Hour)
+	c.expectOK(c.API(client1, key1, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.ID, "")
+
+	// Unknown manager must not get the job.
+	c.advanceTime(20 * 24 * time.Hour)
+	otherReq := &dashapi.JobPollReq{Managers: []string{"foobar"}}
+	c.expectOK(c.API(client1, key1, "job_poll", otherReq, pollResp))
+	c.expectEQ(pollResp.ID, "")
+
+	c.expectOK(c.API(client1, key1, "job_poll", pollReq, pollResp))
+	if pollResp.ID == "" {
+		t.Fatalf("no bisection job")
+	}
+	c.expectEQ(pollResp.Type, dashapi.JobBisectFix)
+	c.expectEQ(pollResp.Manager, build1.Manager)
+	c.expectEQ(pollResp.BugTitle, crash1.Title)
+	c.expectEQ(pollResp.KernelRepo, build1.KernelRepo)
+	c.expectEQ(pollResp.KernelBranch, build1.KernelBranch)
+	c.expectEQ(pollResp.KernelCommit, build1.KernelCommit)
+	c.expectEQ(pollResp.KernelConfig, build1.KernelConfig)
+	c.expectEQ(pollResp.ReproOpts, crash1.ReproOpts)
+	c.expectEQ(pollResp.ReproSyz, crash1.ReproSyz)
+	c.expectEQ(len(pollResp.ReproC), 0)
+	jobID := pollResp.ID
+
+	// The job is taken, so it must not be handed out again.
+	pollResp = new(dashapi.JobPollResp)
+	c.expectOK(c.API(client1, key1, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.ID, "")
+
+	// But it is handed out again if syz-ci did not reply for too long.
+	c.advanceTime(jobTimeout + time.Hour)
+	c.expectOK(c.API(client1, key1, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.ID, jobID)
+
+	done := &dashapi.JobDoneReq{
+		ID:  jobID,
+		Log: []byte("bisection log"),
+		Commits: []dashapi.Commit{
+			{
+				Hash:   "111111111111",
+				Title:  "kernel: fix the bug",
+				Author: "foo@bar.com",
+				Date:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	// Only the client that took the job can report results.
+	c.expectFail("was not taken by client", c.API(client4, key4, "job_done", done, nil))
+	c.expectOK(c.API(client1, key1, "job_done", done, nil))
+	c.expectFail("is already finished", c.API(client1, key1, "job_done", done, nil))
+
+	pollResp = new(dashapi.JobPollResp)
+	c.expectOK(c.API(client1, key1, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.ID, "")
+
+	bug := new(Bug)
+	bugKey := datastore.NewKey(c.ctx, "Bug", bugKeyHash("test1", crash1.Title, 0), 0, nil)
+	c.expectOK(datastore.Get(c.ctx, bugKey, bug))
+	c.expectEQ(bug.BisectFix, BisectDone)
+	c.expectEQ(bug.FixCandidate, "kernel: fix the bug")
+	c.expectEQ(bug.FixCandidateHash, "111111111111")
+}
+
+func TestJobAttempts(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build := testBuild(1)
+	c.expectOK(c.API(client1, key1, "upload_build", build, nil))
+	crash := testCrash(build, 1)
+	crash.ReproSyz = []byte("syz repro")
+	c.expectOK(c.API(client1, key1, "report_crash", crash, nil))
+	c.advanceTime(40 * 24 * time.Hour)
+
+	pollReq := &dashapi.JobPollReq{Managers: []string{build.Manager}}
+	var jobID string
+	for i := 0; i < maxJobAttempts; i++ {
+		pollResp := new(dashapi.JobPollResp)
+		c.expectOK(c.API(client1, key1, "job_poll", pollReq, pollResp))
+		if pollResp.ID == "" || jobID != "" && pollResp.ID != jobID {
+			t.Fatalf("attempt %v: got job %q, want %q", i, pollResp.ID, jobID)
+		}
+		jobID = pollResp.ID
+		c.advanceTime(jobTimeout + time.Hour)
+	}
+
+	// syz-ci never reported results, so the job must be failed
+	// and must not be handed out again.
+	pollResp := new(dashapi.JobPollResp)
+	c.expectOK(c.API(client1, key1, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.ID, "")
+	jobKey, err := datastore.DecodeKey(jobID)
+	c.expectOK(err)
+	job := new(Job)
+	c.expectOK(datastore.Get(c.ctx, jobKey, job))
+	if job.Finished.IsZero() || job.Error == 0 {
+		t.Fatalf("job is not failed: %+v", job)
+	}
+	bug := new(Bug)
+	c.expectOK(datastore.Get(c.ctx, jobKey.Parent(), bug))
+	c.expectEQ(bug.BisectFix, BisectError)
+}
+
+// Jobs for bugs with Seq>0 must refer to the raw crash title without the " (N)" suffix,
+// otherwise syz-ci never matches the crash.
+func TestJobBugSeq(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build := testBuild(1)
+	c.expectOK(c.API(client1, key1, "upload_build", build, nil))
+	crash := testCrash(build, 1)
+	c.expectOK(c.API(client1, key1, "report_crash", crash, nil))
+	rep := reportAllBugs(c, 1)[0]
+	cmd := &dashapi.BugUpdate{
+		ID:     rep.ID,
+		Status: dashapi.BugStatusInvalid,
+	}
+	reply := new(dashapi.BugUpdateReply)
+	c.expectOK(c.API(client1, key1, "reporting_update", cmd, reply))
+	c.expectEQ(reply.OK, true)
+
+	crash.ReproSyz = []byte("syz repro")
+	c.expectOK(c.API(client1, key1, "report_crash", crash, nil))
+	c.expectEQ(reportAllBugs(c, 1)[0].Title, crash.Title+" (2)")
+	c.advanceTime(40 * 24 * time.Hour)
+
+	pollReq := &dashapi.JobPollReq{Managers: []string{build.Manager}}
+	pollResp := new(dashapi.JobPollResp)
+	c.expectOK(c.API(client1, key1, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.Type, dashapi.JobBisectFix)
+	c.expectEQ(pollResp.BugTitle, crash.Title)
+}
+
+func TestPatchTesting(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build := testBuild(1)
+	c.expectOK(c.API(client2, key2, "upload_build", build, nil))
+
+	crash := testCrash(build, 1)
+	crash.ReproSyz = []byte("getpid()")
+	c.expectOK(c.API(client2, key2, "report_crash", crash, nil))
+
+	c.expectOK(c.GET("/email_poll"))
+	c.expectEQ(len(c.emailSink), 1)
+	sender := (<-c.emailSink).Sender
+
+	patch := `--- a/mm/kasan/kasan.c
++++ b/mm/kasan/kasan.c
+-       current->kasan_depth++;
++       current->kasan_depth--;
+`
+	incoming := fmt.Sprintf(`Sender: syzkaller@googlegroups.com
+Date: Tue, 15 Aug 2017 14:59:00 -0700
+Message-ID: <1234>
+Subject: title1
+From: foo@bar.com
+To: %v
+Content-Type: text/plain
+
+#syz test: git://git.git/git.git kernel-branch
+
+%v
+`, sender, patch)
+	c.expectOK(c.POST("/_ah/mail/", incoming))
+	c.expectEQ(len(c.emailSink), 0)
+
+	pollReq := &dashapi.JobPollReq{Managers: []string{build.Manager}}
+	pollResp := new(dashapi.JobPollResp)
+	c.expectOK(c.API(client2, key2, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.Type, dashapi.JobTestPatch)
+	c.expectEQ(pollResp.Manager, build.Manager)
+	c.expectEQ(pollResp.KernelRepo, "git://git.git/git.git")
+	c.expectEQ(pollResp.KernelBranch, "kernel-branch")
+	c.expectEQ(pollResp.KernelConfig, build.KernelConfig)
+	c.expectEQ(pollResp.ReproSyz, crash.ReproSyz)
+	c.expectEQ(string(pollResp.Patch), patch)
+
+	done := &dashapi.JobDoneReq{
+		ID:          pollResp.ID,
+		Log:         []byte("test log"),
+		Commits:     []dashapi.Commit{{Hash: "222222222222", Title: "kernel: some commit"}},
+		CrashTitle:  "title1",
+		CrashReport: []byte("report1"),
+	}
+	c.expectOK(c.API(client2, key2, "job_done", done, nil))
+
+	c.expectEQ(len(c.emailSink), 1)
+	msg := <-c.emailSink
+	c.expectEQ(msg.Sender, sender)
+	c.expectEQ(msg.To, []string{"foo@bar.com"})
+	c.expectEQ(msg.Subject, "Re: title1")
+	c.expectEQ(msg.Headers["In-Reply-To"], []string{"<1234>"})
+	c.expectEQ(len(msg.Attachments), 2)
+	c.expectEQ(msg.Attachments[0].Name, "patch.diff")
+	c.expectEQ(string(msg.Attachments[0].Data), patch)
+	c.expectEQ(msg.Attachments[1].Name, "test.log")
+	c.expectEQ(msg.Body, `syzbot has tested the proposed patch but the reproducer still triggered crash:
+title1
+
+report1
+
+Tested on 222222222222
+git://git.git/git.git/kernel-branch
+Patch is attached.
+`)
+
+	pollResp = new(dashapi.JobPollResp)
+	c.expectOK(c.API(client2, key2, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.ID, "")
+}
+
+func TestConfigMinimization(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build := testBuild(1)
+	c.expectOK(c.API(client3, key3, "upload_build", build, nil))
+
+	// Bug without reproducer must not be minimized.
+	crash1 := testCrash(build, 1)
+	c.expectOK(c.API(client3, key3, "report_crash", crash1, nil))
+	pollReq := &dashapi.JobPollReq{Managers: []string{build.Manager}}
+	pollResp := new(dashapi.JobPollResp)
+	c.expectOK(c.API(client3, key3, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.ID, "")
+
+	crash1.ReproSyz = []byte("getpid()")
+	c.expectOK(c.API(client3, key3, "report_crash", crash1, nil))
+	c.expectOK(c.API(client3, key3, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.Type, dashapi.JobMinimizeConfig)
+	c.expectEQ(pollResp.BugTitle, crash1.Title)
+	c.expectEQ(pollResp.KernelConfig, build.KernelConfig)
+	c.expectEQ(pollResp.ReproSyz, crash1.ReproSyz)
+
+	done := &dashapi.JobDoneReq{
+		ID:           pollResp.ID,
+		Log:          []byte("minimization log"),
+		KernelConfig: []byte("CONFIG_KASAN=y\n"),
+	}
+	c.expectOK(c.API(client3, key3, "job_done", done, nil))
+
+	// The bug must be minimized only once.
+	pollResp = new(dashapi.JobPollResp)
+	c.expectOK(c.API(client3, key3, "job_poll", pollReq, pollResp))
+	c.expectEQ(pollResp.ID, "")
+
+	bug := new(Bug)
+	bugKey := datastore.NewKey(c.ctx, "Bug", bugKeyHash("test3", crash1.Title, 0), 0, nil)
+	c.expectOK(datastore.Get(c.ctx, bugKey, bug))
+	c.expectEQ(bug.MinimizeConfig, BisectDone)
+
+	// The minimized config must be attached to the report.
+	c.expectOK(c.GET("/email_poll"))
+	c.expectEQ(len(c.emailSink), 1)
+	msg := <-c.emailSink
+	c.expectEQ(len(msg.Attachments), 4)
+	c.expectEQ(msg.Attachments[1].Name, "config.min.txt")
+	c.expectEQ(msg.Attachments[1].Data, done.KernelConfig)
+	if !strings.Contains(msg.Body, "Minimized .config that still reproduces the crash is attached") {
+		t.Fatalf("no minimized config mention in the report:\n%v", msg.Body)
+	}
+	c.expectOK(c.GET("/bug?id=" + bugKey.StringID()))
+}
@@ -24,6 +24,7 @@ func init() {
 	http.Handle("/", handlerWrapper(handleMain))
 	http.Handle("/bug", handlerWrapper(handleBug))
 	http.Handle("/text", handlerWrapper(handleText))
+	http.Handle("/subsystem", handlerWrapper(handleSubsystem))
 }
 
 type uiMain struct {
@@ -32,10 +33,18 @@ type uiMain struct {
 	BugGroups []*uiBugGroup
 }
 
+type uiSubsystemPage struct {
+	Header    *uiHeader
+	Subsystem string
+	BugGroups []*uiBugGroup
+}
+
 type uiBugPage struct {
 	Header  *uiHeader
 	Bug     *uiBug
 	Crashes []*uiCrash
+	Jobs    []*uiJob
+	Dups    []*uiBug // likely duplicates of the bug
 }
 
 type uiBugGroup struct {
@@ -55,6 +64,10 @@ type uiBug struct {
 	Status         string
 	Link           string
 	Commits        string
+	Subsystem      string
+	BisectFix      string
+	ConfigMinLink  string
+	Regression     string
 }
 
 type uiCrash struct {
@@ -65,6 +78,7 @@ type uiCrash struct {
 	ReportLink       string
 	ReproSyzLink     string
 	ReproCLink       string
+	ReproReliability string
 	SyzkallerCommit  string
 	KernelRepo       string
 	KernelBranch     string
@@ -72,6 +86,26 @@ type uiCrash struct {
 	KernelConfigLink string
 }
 
+type uiJob struct {
+	Type         string
+	Created      time.Time
+	Started      time.Time
+	Finished     time.Time
+	Manager      string
+	KernelRepo   string
+	KernelBranch string
+	KernelCommit string
+	Commits      []string
+	User         string
+	Link         string
+	PatchLink    string
+	CrashTitle   string
+	ErrorLink    string
+	LogLink      string
+	ReportLink   string
+	ConfigLink   string
+}
+
 // handleMain serves main page.
 func handleMain(c context.Context, w http.ResponseWriter, r *http.Request) error {
 	h, err := commonHeader(c)
@@ -82,7 +116,7 @@ func handleMain(c context.Context, w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return err
 	}
-	groups, err := fetchBugs(c)
+	groups, err := fetchBugs(c, "")
 	if err != nil {
 		return err
 	}
@@ -114,14 +148,46 @@ func handleBug(c context.Context, w http.ResponseWriter, r *http.Request) error
 	if err != nil {
 		return err
 	}
+	jobs, err := loadJobsForBug(c, bugKey)
+	if err != nil {
+		return err
+	}
+	dups, err := findLikelyDups(c, bug, state)
+	if err != nil {
+		return err
+	}
 	data := &uiBugPage{
 		Header:  h,
 		Bug:     uiBug,
 		Crashes: crashes,
+		Jobs:    jobs,
+		Dups:    dups,
 	}
 	return templates.ExecuteTemplate(w, "bug.html", data)
 }
 
+// handleSubsystem serves open bugs of a single subsystem (which is passed in name argument).
+func handleSubsystem(c context.Context, w http.ResponseWriter, r *http.Request) error {
+	subsystem := r.FormValue("name")
+	if subsystem == "" {
+		return fmt.Errorf("no subsystem name")
+	}
+	h, err := commonHeader(c)
+	if err != nil {
+		return err
+	}
+	groups, err := fetchBugs(c, subsystem)
+	if err != nil {
+		return err
+	}
+	data := &uiSubsystemPage{
+		Header:    h,
+		Subsystem: subsystem,
+		BugGroups: groups,
+	}
+	return templates.ExecuteTemplate(w, "subsystem.html", data)
+}
+
 // handleText serves plain text blobs (crash logs, reports, reproducers, etc).
 func handleText(c context.Context, w http.ResponseWriter, r *http.Request) error {
 	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
@@ -137,11 +203,16 @@ func handleText(c context.Context, w http.ResponseWriter, r *http.Request) error
 	return nil
 }
 
-func fetchBugs(c context.Context) ([]*uiBugGroup, error) {
+// fetchBugs returns open bugs grouped by namespace,
+// if subsystem is not empty only bugs in that subsystem are returned.
+func fetchBugs(c context.Context, subsystem string) ([]*uiBugGroup, error) {
 	var bugs []*Bug
-	_, err := datastore.NewQuery("Bug").
-		Filter("Status=", BugStatusOpen).
-		GetAll(c, &bugs)
+	query := datastore.NewQuery("Bug").
+		Filter("Status=", BugStatusOpen)
+	if subsystem != "" {
+		query = query.Filter("Subsystem=", subsystem)
+	}
+	_, err := query.GetAll(c, &bugs)
 	if err != nil {
 		return nil, err
 	}
@@ -186,6 +257,10 @@ func createUIBug(c context.Context, bug *Bug, state *ReportingState) *uiBug {
 		Status:         status,
 		Link:           link,
 		Commits:        fmt.Sprintf("%q", bug.Commits),
+		Subsystem:      bug.Subsystem,
+		BisectFix:      formatBisectStatus(bug),
+		ConfigMinLink:  textLink("KernelConfig", bug.KernelConfigMin),
+		Regression:     formatRegressionStatus(bug),
 	}
 	return uiBug
 }
@@ -216,6 +291,7 @@ func loadCrashesForBug(c context.Context, bug *Bug) ([]*uiCrash, error) {
 			ReportLink:       textLink("CrashReport", crash.Report),
 			ReproSyzLink:     textLink("ReproSyz", crash.ReproSyz),
 			ReproCLink:       textLink("ReproC", crash.ReproC),
+			ReproReliability: reproReliability(crash.ReproRuns, crash.ReproCrashes),
 			SyzkallerCommit:  build.SyzkallerCommit,
 			KernelRepo:       build.KernelRepo,
 			KernelBranch:     build.KernelBranch,
@@ -293,3 +369,12 @@ type uiBugGroupSorter []*uiBugGroup
 func (a uiBugGroupSorter) Len() int           { return len(a) }
 func (a uiBugGroupSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a uiBugGroupSorter) Less(i, j int) bool { return a[i].Namespace < a[j].Namespace }
+
+// reproReliability returns human-readable reliability of a reproducer
+// or an empty string if it was not measured.
+func reproReliability(runs, crashes int) string {
+	if runs == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v/%v", crashes, runs)
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package dash
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// This file contains handling of regression testing results.
+// Managers periodically re-run all reproducers they have against the current kernel
+// and report which bugs still reproduce. Fixed bugs that reproduce again
+// are marked as regressed. Results for each build are stored in a Regression entity.
+
+func apiReportRegression(c context.Context, ns string, r *http.Request) (interface{}, error) {
+	req := new(dashapi.RegressionReport)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request: %v", err)
+	}
+	build, err := loadBuild(c, ns, req.BuildID)
+	if err != nil {
+		return nil, err
+	}
+	now := timeNow(c)
+	regression := &Regression{
+		Namespace: ns,
+		Manager:   build.Manager,
+		BuildID:   req.BuildID,
+		Time:      now,
+	}
+	for _, res := range req.Results {
+		title := limitLength(res.Title, maxTextLen)
+		status, err := updateBugRegression(c, ns, title, req.BuildID, res)
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case RegressionNot:
+			// Unknown bug (e.g. the crash was never reported to dashboard).
+		case RegressionFixed:
+			regression.Fixed = append(regression.Fixed, title)
+		case RegressionReproduced:
+			regression.Reproduced = append(regression.Reproduced, title)
+		case RegressionRegressed:
+			regression.Regressed = append(regression.Regressed, title)
+		case RegressionInconclusive:
+			regression.Inconclusive = append(regression.Inconclusive, title)
+		}
+	}
+	key := datastore.NewIncompleteKey(c, "Regression", buildKey(c, ns, req.BuildID))
+	if _, err := datastore.Put(c, key, regression); err != nil {
+		return nil, fmt.Errorf("failed to put regression: %v", err)
+	}
+	return nil, nil
+}
+
+// updateBugRegression records regression testing result in the latest bug with the title.
+func updateBugRegression(c context.Context, ns, title, buildID string, res dashapi.RegressionResult) (int, error) {
+	status := RegressionNot
+	tx := func(c context.Context) error {
+		status = RegressionNot
+		var bug *Bug
+		var bugKey *datastore.Key
+		for seq := int64(0); ; seq++ {
+			key := datastore.NewKey(c, "Bug", bugKeyHash(ns, title, seq), 0, nil)
+			next := new(Bug)
+			if err := datastore.Get(c, key, next); err != nil {
+				if err != datastore.ErrNoSuchEntity {
+					return fmt.Errorf("failed to get bug: %v", err)
+				}
+				break
+			}
+			bug, bugKey = next, key
+		}
+		if bug == nil {
+			return nil
+		}
+		switch {
+		case res.Reproduced && bug.Status == BugStatusFixed:
+			status = RegressionRegressed
+		case res.Reproduced:
+			status = RegressionReproduced
+		case res.CrashTitle != "":
+			status = RegressionInconclusive
+		default:
+			status = RegressionFixed
+		}
+		bug.RegressionStatus = status
+		bug.RegressionBuild = buildID
+		bug.RegressionTime = timeNow(c)
+		if _, err := datastore.Put(c, bugKey, bug); err != nil {
+			return fmt.Errorf("failed to put bug: %v", err)
+		}
+		return nil
+	}
+	if err := datastore.RunInTransaction(c, tx, nil); err != nil {
+		return RegressionNot, err
+	}
+	return status, nil
+}
+
+func formatRegressionStatus(bug *Bug) string {
+	var status string
+	switch bug.RegressionStatus {
+	case RegressionFixed:
+		status = "does not reproduce"
+	case RegressionReproduced:
+		status = "reproduces"
+	case RegressionRegressed:
+		status = "regressed"
+	case RegressionInconclusive:
+		status = "inconclusive (other crash)"
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%v on %v (%v)", status, bug.RegressionBuild, formatTime(bug.RegressionTime))
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// +build aetest
+
+package dash
+
+import (
+	"testing"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"google.golang.org/appengine/datastore"
+)
+
+func TestRegression(t *testing.T) {
+	c := NewCtx(t)
+	defer c.Close()
+
+	build1 := testBuild(1)
+	c.expectOK(c.API(client1, key1, "upload_build", build1, nil))
+	crash1 := testCrash(build1, 1)
+	c.expectOK(c.API(client1, key1, "report_crash", crash1, nil))
+	crash2 := testCrash(build1, 2)
+	c.expectOK(c.API(client1, key1, "report_crash", crash2, nil))
+	crash3 := testCrash(build1, 3)
+	c.expectOK(c.API(client1, key1, "report_crash", crash3, nil))
+
+	reports := reportAllBugs(c, 3)
+	var rep1 *dashapi.BugReport
+	for _, rep := range reports {
+		if rep.Title == crash1.Title {
+			rep1 = rep
+		}
+	}
+
+	loadBug := func(title string) *Bug {
+		bug := new(Bug)
+		bugKey := datastore.NewKey(c.ctx, "Bug", bugKeyHash("test1", title, 0), 0, nil)
+		c.expectOK(datastore.Get(c.ctx, bugKey, bug))
+		return bug
+	}
+
+	regression := &dashapi.RegressionReport{
+		BuildID: build1.ID,
+		Results: []dashapi.RegressionResult{
+			{Title: crash1.Title, Reproduced: true},
+			{Title: crash2.Title},
+			{Title: crash3.Title, CrashTitle: "other crash"},
+			{Title: "unknown bug", Reproduced: true},
+		},
+	}
+	c.expectOK(c.API(client1, key1, "report_regression", regression, nil))
+	bug1 := loadBug(crash1.Title)
+	c.expectEQ(bug1.RegressionStatus, RegressionReproduced)
+	c.expectEQ(bug1.RegressionBuild, build1.ID)
+	c.expectEQ(loadBug(crash2.Title).RegressionStatus, RegressionFixed)
+	c.expectEQ(loadBug(crash3.Title).RegressionStatus, RegressionInconclusive)
+
+	// Fix the first bug.
+	cmd := &dashapi.BugUpdate{
+		ID:         rep1.ID,
+		Status:     dashapi.BugStatusOpen,
+		FixCommits: []string{"foo: fix the crash"},
+	}
+	reply := new(dashapi.BugUpdateReply)
+	c.expectOK(c.API(client1, key1, "reporting_update", cmd, reply))
+	c.expectEQ(reply.OK, true)
+	build2 := testBuild(2)
+	build2.Manager = build1.Manager
+	build2.Commits = []string{"foo: fix the crash"}
+	c.expectOK(c.API(client1, key1, "upload_build", build2, nil))
+	c.expectEQ(loadBug(crash1.Title).Status, BugStatusFixed)
+
+	// The fixed bug reproduces again.
+	regression = &dashapi.RegressionReport{
+		BuildID: build2.ID,
+		Results: []dashapi.RegressionResult{
+			{Title: crash1.Title, Reproduced: true},
+		},
+	}
+	c.expectOK(c.API(client1, key1, "report_regression", regression, nil))
+	bug1 = loadBug(crash1.Title)
+	c.expectEQ(bug1.RegressionStatus, RegressionRegressed)
+	c.expectEQ(bug1.RegressionBuild, build2.ID)
+
+	var regressions []*Regression
+	_, err := datastore.NewQuery("Regression").GetAll(c.ctx, &regressions)
+	c.expectOK(err)
+	c.expectEQ(len(regressions), 2)
+}
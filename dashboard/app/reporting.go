@@ -213,26 +213,34 @@ func createBugReport(c context.Context, bug *Bug, crash *Crash, bugReporting *Bu
 	if err != nil {
 		return nil, err
 	}
+	kernelConfigMin, err := getText(c, "KernelConfig", bug.KernelConfigMin)
+	if err != nil {
+		return nil, err
+	}
 
 	rep := &dashapi.BugReport{
-		Config:       reportingConfig,
-		ID:           bugReporting.ID,
-		ExtID:        bugReporting.ExtID,
-		First:        bugReporting.Reported.IsZero(),
-		Title:        bug.displayTitle(),
-		Log:          crashLog,
-		Report:       report,
-		Maintainers:  crash.Maintainers,
-		OS:           build.OS,
-		Arch:         build.Arch,
-		VMArch:       build.VMArch,
-		CompilerID:   build.CompilerID,
-		KernelRepo:   build.KernelRepo,
-		KernelBranch: build.KernelBranch,
-		KernelCommit: build.KernelCommit,
-		KernelConfig: kernelConfig,
-		ReproC:       reproC,
-		ReproSyz:     reproSyz,
+		Config:          reportingConfig,
+		ID:              bugReporting.ID,
+		ExtID:           bugReporting.ExtID,
+		First:           bugReporting.Reported.IsZero(),
+		Title:           bug.displayTitle(),
+		Log:             crashLog,
+		Report:          report,
+		Maintainers:     crash.Maintainers,
+		Subsystem:       crash.Subsystem,
+		OS:              build.OS,
+		Arch:            build.Arch,
+		VMArch:          build.VMArch,
+		CompilerID:      build.CompilerID,
+		KernelRepo:      build.KernelRepo,
+		KernelBranch:    build.KernelBranch,
+		KernelCommit:    build.KernelCommit,
+		KernelConfig:    kernelConfig,
+		KernelConfigMin: kernelConfigMin,
+		ReproC:          reproC,
+		ReproSyz:        reproSyz,
+		ReproRuns:       crash.ReproRuns,
+		ReproCrashes:    crash.ReproCrashes,
 	}
 	if bugReporting.CC != "" {
 		rep.CC = strings.Split(bugReporting.CC, "|")
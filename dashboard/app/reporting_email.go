@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/mail"
+	"strings"
 	"text/template"
 
 	"github.com/google/syzkaller/dashboard/dashapi"
@@ -32,6 +33,9 @@ type EmailConfig struct {
 	Email           string
 	Moderation      bool
 	MailMaintainers bool
+	// SubsystemLists maps MAINTAINERS subsystem names to mailing lists.
+	// Bugs in these subsystems are additionally sent to the corresponding list.
+	SubsystemLists map[string]string
 }
 
 func (cfg *EmailConfig) Type() string {
@@ -49,6 +53,17 @@ func (cfg *EmailConfig) Validate() error {
 	if cfg.Moderation && cfg.MailMaintainers {
 		return fmt.Errorf("both Moderation and MailMaintainers set")
 	}
+	if len(cfg.SubsystemLists) != 0 && !cfg.MailMaintainers {
+		return fmt.Errorf("SubsystemLists set without MailMaintainers")
+	}
+	for subsystem, list := range cfg.SubsystemLists {
+		if subsystem == "" {
+			return fmt.Errorf("empty subsystem name in SubsystemLists")
+		}
+		if _, err := mail.ParseAddress(list); err != nil {
+			return fmt.Errorf("bad email address %q for subsystem %q: %v", list, subsystem, err)
+		}
+	}
 	return nil
 }
 
@@ -84,6 +99,9 @@ func emailReport(c context.Context, rep *dashapi.BugReport) error {
 			panic("are you nuts?")
 		}
 		to = append(to, rep.Maintainers...)
+		if list := cfg.SubsystemLists[rep.Subsystem]; list != "" {
+			to = append(to, list)
+		}
 	}
 	to = email.MergeEmailLists(to, rep.CC)
 	attachments := []aemail.Attachment{
@@ -92,6 +110,12 @@ func emailReport(c context.Context, rep *dashapi.BugReport) error {
 			Data: rep.KernelConfig,
 		},
 	}
+	if len(rep.KernelConfigMin) != 0 {
+		attachments = append(attachments, aemail.Attachment{
+			Name: "config.min.txt",
+			Data: rep.KernelConfigMin,
+		})
+	}
 	if len(rep.Log) != 0 {
 		attachments = append(attachments, aemail.Attachment{
 			Name: "raw.log",
@@ -122,27 +146,38 @@ func emailReport(c context.Context, rep *dashapi.BugReport) error {
 		First        bool
 		Moderation   bool
 		Maintainers  []string
+		Subsystem    string
 		CompilerID   string
 		KernelRepo   string
 		KernelBranch string
 		KernelCommit string
 		Report       []byte
+		HasConfigMin bool
 		HasLog       bool
 		ReproSyz     bool
 		ReproC       bool
+		// Set if the reproducer does not trigger the crash reliably.
+		ReproFlaky   bool
+		ReproRuns    int
+		ReproCrashes int
 	}
 	data := &BugReportData{
 		First:        rep.First,
 		Moderation:   cfg.Moderation,
 		Maintainers:  rep.Maintainers,
+		Subsystem:    rep.Subsystem,
 		CompilerID:   rep.CompilerID,
 		KernelRepo:   rep.KernelRepo,
 		KernelBranch: rep.KernelBranch,
 		KernelCommit: rep.KernelCommit,
 		Report:       rep.Report,
+		HasConfigMin: len(rep.KernelConfigMin) != 0,
 		HasLog:       len(rep.Log) != 0,
 		ReproSyz:     len(rep.ReproSyz) != 0,
 		ReproC:       len(rep.ReproC) != 0,
+		ReproFlaky:   rep.ReproRuns != 0 && rep.ReproCrashes < rep.ReproRuns,
+		ReproRuns:    rep.ReproRuns,
+		ReproCrashes: rep.ReproCrashes,
 	}
 	err = sendMailTemplate(c, rep.Title, from, to, rep.ExtID, attachments, "mail_bug.txt", data)
 	if err != nil {
@@ -201,6 +236,22 @@ func incomingMail(c context.Context, r *http.Request) error {
 		}
 		cmd.Status = dashapi.BugStatusDup
 		cmd.DupOf = msg.CommandArgs
+	case "test:", "test":
+		// "#syz test: repo branch", repo and branch are optional.
+		args := strings.Fields(msg.CommandArgs)
+		if len(args) != 0 && len(args) != 2 {
+			return replyTo(c, msg, fmt.Sprintf("want 2 args (repo, branch), got %v", len(args)), nil)
+		}
+		repo, branch := "", ""
+		if len(args) == 2 {
+			repo, branch = args[0], args[1]
+		}
+		reply := handleTestRequest(c, msg.BugID, msg.From, msg.MessageID, msg.Link,
+			[]byte(msg.Patch), repo, branch, msg.Cc)
+		if reply != "" {
+			return replyTo(c, msg, reply, nil)
+		}
+		return nil
 	default:
 		return replyTo(c, msg, fmt.Sprintf("unknown command %q", msg.Command), nil)
 	}
@@ -211,6 +262,55 @@ func incomingMail(c context.Context, r *http.Request) error {
 	return replyTo(c, msg, reply, nil)
 }
 
+// emailTestResult sends result of a patch testing job as a reply to the request.
+func emailTestResult(c context.Context, job *Job, bug *Bug, res *dashapi.JobDoneReq) error {
+	if job.ExtID == "" {
+		return nil
+	}
+	from, err := email.AddAddrContext(fromAddr(c), job.ReportingID)
+	if err != nil {
+		return err
+	}
+	patch, err := getText(c, "Patch", job.Patch)
+	if err != nil {
+		return err
+	}
+	attachments := []aemail.Attachment{
+		{
+			Name: "patch.diff",
+			Data: patch,
+		},
+	}
+	if len(res.Log) != 0 {
+		attachments = append(attachments, aemail.Attachment{
+			Name: "test.log",
+			Data: res.Log,
+		})
+	}
+	commit := job.KernelCommit
+	if len(res.Commits) != 0 {
+		commit = res.Commits[0].Hash
+	}
+	data := &struct {
+		KernelRepo   string
+		KernelBranch string
+		KernelCommit string
+		Error        []byte
+		CrashTitle   string
+		CrashReport  []byte
+	}{
+		KernelRepo:   job.KernelRepo,
+		KernelBranch: job.KernelBranch,
+		KernelCommit: commit,
+		Error:        res.Error,
+		CrashTitle:   res.CrashTitle,
+		CrashReport:  res.CrashReport,
+	}
+	to := email.MergeEmailLists([]string{job.User}, job.CC)
+	return sendMailTemplate(c, "Re: "+bug.displayTitle(), from, to, job.ExtID,
+		attachments, "mail_test_result.txt", data)
+}
+
 var mailTemplates = template.Must(template.New("").ParseGlob("mail_*.txt"))
 
 func sendMailTemplate(c context.Context, subject, from string, to []string, replyTo string,
@@ -12,6 +12,7 @@ package dash
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -136,15 +137,26 @@ func (c *Ctx) API(client, key, method string, req, reply interface{}) error {
 
 // GET sends authorized HTTP GET request to the app.
 func (c *Ctx) GET(url string) error {
-	return c.httpRequest("GET", url, "")
+	_, err := c.httpRequest("GET", url, "")
+	return err
+}
+
+// GETJSON sends authorized HTTP GET request to the app and unmarshals JSON reply.
+func (c *Ctx) GETJSON(url string, reply interface{}) error {
+	body, err := c.httpRequest("GET", url, "")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, reply)
 }
 
 // POST sends authorized HTTP POST request to the app.
 func (c *Ctx) POST(url, body string) error {
-	return c.httpRequest("POST", url, body)
+	_, err := c.httpRequest("POST", url, body)
+	return err
 }
 
-func (c *Ctx) httpRequest(method, url, body string) error {
+func (c *Ctx) httpRequest(method, url, body string) ([]byte, error) {
 	c.t.Logf("%v: %v", method, url)
 	r, err := c.inst.NewRequest(method, url, strings.NewReader(body))
 	if err != nil {
@@ -160,10 +172,10 @@ func (c *Ctx) httpRequest(method, url, body string) error {
 	w := httptest.NewRecorder()
 	http.DefaultServeMux.ServeHTTP(w, r)
 	c.t.Logf("REPLY: %v", w.Code)
-	if w.Code != http.StatusOK {
-		return fmt.Errorf("%v", w.Body.String())
+	if w.Code != http.StatusOK && w.Code != http.StatusFound {
+		return nil, fmt.Errorf("%v", w.Body.String())
 	}
-	return nil
+	return w.Body.Bytes(), nil
 }
 
 func init() {
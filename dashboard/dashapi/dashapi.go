@@ -16,6 +16,7 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"time"
 )
 
 type Dashboard struct {
@@ -80,12 +81,17 @@ type Crash struct {
 	BuildID     string // refers to Build.ID
 	Title       string
 	Maintainers []string
+	Subsystem   string // name of the MAINTAINERS entry responsible for the guilty file
 	Log         []byte
 	Report      []byte
 	// The following is optional and is filled only after repro.
 	ReproOpts []byte
 	ReproSyz  []byte
 	ReproC    []byte
+	// The reproducer triggered the crash ReproCrashes times out of ReproRuns
+	// (ReproRuns is 0 if reliability was not measured).
+	ReproRuns    int
+	ReproCrashes int
 }
 
 type ReportCrashResp struct {
@@ -120,6 +126,94 @@ func (dash *Dashboard) ReportFailedRepro(crash *CrashID) error {
 	return dash.query("report_failed_repro", crash, nil)
 }
 
+// RegressionReport contains results of re-running all known reproducers on a new build.
+type RegressionReport struct {
+	BuildID string
+	Results []RegressionResult
+}
+
+type RegressionResult struct {
+	Title string // title of the bug the reproducer belongs to
+	// Reproduced is set if the reproducer triggered the same crash.
+	Reproduced bool
+	// CrashTitle is set if the reproducer triggered a different crash.
+	CrashTitle string
+}
+
+// ReportRegression sends results of regression testing to dashboard.
+func (dash *Dashboard) ReportRegression(rep *RegressionReport) error {
+	return dash.query("report_regression", rep, nil)
+}
+
+// JobPollReq is done by syz-ci to ask dashboard for work (e.g. fix bisection).
+// Managers is the list of managers that the syz-ci instance runs,
+// dashboard returns only jobs for these managers.
+type JobPollReq struct {
+	Managers []string
+}
+
+// JobPollResp describes a single job. Empty ID means that there is no job.
+type JobPollResp struct {
+	ID           string
+	Type         JobType
+	Manager      string
+	BugTitle     string
+	KernelRepo   string
+	KernelBranch string
+	// KernelCommit is the commit on which the bug was last seen reproducing.
+	KernelCommit string
+	KernelConfig []byte
+	ReproOpts    []byte
+	ReproSyz     []byte
+	ReproC       []byte
+	Patch        []byte // patch to apply on top of KernelBranch for JobTestPatch
+}
+
+// JobDoneReq reports result of a job back to dashboard.
+type JobDoneReq struct {
+	ID    string
+	Error []byte // non-empty if the job has failed
+	Log   []byte // job log (e.g. bisection log)
+	// Commits is the result of bisection: the single fixing commit,
+	// or several commits if bisection could not narrow down the range.
+	// For patch testing it contains the commit the patch was applied to.
+	Commits []Commit
+	// Result of patch testing: title and report of the crash
+	// if the reproducer still triggers a crash with the patch applied.
+	CrashTitle  string
+	CrashReport []byte
+	// Result of config minimization: the minimal config that still reproduces the bug.
+	KernelConfig []byte
+}
+
+type Commit struct {
+	Hash   string
+	Title  string
+	Author string
+	Date   time.Time
+}
+
+type JobType int
+
+const (
+	JobBisectFix JobType = iota
+	JobTestPatch
+	JobMinimizeConfig
+)
+
+func (dash *Dashboard) JobPoll(managers []string) (*JobPollResp, error) {
+	req := &JobPollReq{
+		Managers: managers,
+	}
+	resp := new(JobPollResp)
+	err := dash.query("job_poll", req, resp)
+	return resp, err
+}
+
+func (dash *Dashboard) JobDone(req *JobDoneReq) error {
+	return dash.query("job_done", req, nil)
+}
+
 type LogEntry struct {
 	Name string
 	Text string
@@ -143,6 +237,7 @@ type BugReport struct {
 	First        bool   // Set for first report for this bug.
 	Title        string
 	Maintainers  []string
+	Subsystem    string
 	CC           []string // additional CC emails
 	OS           string
 	Arch         string
@@ -152,10 +247,14 @@ type BugReport struct {
 	KernelBranch string
 	KernelCommit string
 	KernelConfig []byte
-	Log          []byte
-	Report       []byte
-	ReproC       []byte
-	ReproSyz     []byte
+	// Minimized kernel config that still reproduces the bug, if available.
+	KernelConfigMin []byte
+	Log             []byte
+	Report          []byte
+	ReproC          []byte
+	ReproSyz        []byte
+	ReproRuns       int
+	ReproCrashes    int
 }
 
 type BugUpdate struct {
@@ -182,6 +281,45 @@ type PollResponse struct {
 	Reports []*BugReport
 }
 
+// ExportBug describes a bug in the public read-only JSON API
+// (/export/bugs and /export/bug dashboard handlers).
+// Only bugs that were reported to a public reporting stage are exported.
+type ExportBug struct {
+	ID           string
+	Namespace    string
+	Title        string
+	Status       string // one of "open", "fixed", "invalid", "dup"
+	DupOf        string // ID of the canonical bug for dups
+	Subsystem    string
+	NumCrashes   int64
+	NumRepro     int64
+	ReproLevel   ReproLevel
+	FirstTime    time.Time
+	LastTime     time.Time
+	ClosedTime   time.Time
+	Links        []string // links to public reports of the bug
+	FixCommits   []string // titles of commits that fix the bug
+	FixCandidate string   // title of the commit identified by fix bisection
+	// Crashes are filled only by /export/bug.
+	Crashes []*ExportCrash `json:",omitempty"`
+}
+
+type ExportCrash struct {
+	Manager         string
+	Time            time.Time
+	OS              string
+	Arch            string
+	CompilerID      string
+	KernelRepo      string
+	KernelBranch    string
+	KernelCommit    string
+	SyzkallerCommit string
+	Report          []byte
+	ReproOpts       []byte
+	ReproSyz        []byte
+	ReproC          []byte
+}
+
 type (
 	BugStatus  int
 	ReproLevel int
@@ -21,6 +21,17 @@
 #include <stdarg.h>
 #include <stdio.h>
 #endif
+#if defined(SYZ_TRACE)
+#include <errno.h>
+#include <stdarg.h>
+#include <stdio.h>
+#include <string.h>
+#endif
+#if defined(SYZ_DATA_OFFSET)
+#include <stdio.h>
+#include <stdlib.h>
+#include <sys/mman.h>
+#endif
 
 #if defined(SYZ_EXECUTOR)
 // exit/_exit do not necessary work (e.g. if fuzzer sets seccomp filter that prohibits exit_group).
@@ -57,7 +68,8 @@ extern unsigned syscall_count;
 
 #if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) ||            \
     defined(SYZ_USE_TMP_DIR) || defined(SYZ_TUN_ENABLE) || defined(SYZ_SANDBOX_NAMESPACE) || \
-    defined(SYZ_SANDBOX_SETUID) || defined(SYZ_FAULT_INJECTION) || defined(__NR_syz_kvm_setup_cpu)
+    defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_SECCOMP) || defined(SYZ_FAULT_INJECTION) || \
+    defined(SYZ_THREADED) || defined(__NR_syz_kvm_setup_cpu)
 const int kFailStatus = 67;
 const int kRetryStatus = 69;
 #endif
@@ -68,7 +80,8 @@ const int kErrorStatus = 68;
 
 #if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) ||            \
     defined(SYZ_USE_TMP_DIR) || defined(SYZ_TUN_ENABLE) || defined(SYZ_SANDBOX_NAMESPACE) || \
-    defined(SYZ_SANDBOX_SETUID) || defined(SYZ_FAULT_INJECTION) || defined(__NR_syz_kvm_setup_cpu)
+    defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_SECCOMP) || defined(SYZ_FAULT_INJECTION) || \
+    defined(SYZ_THREADED) || defined(__NR_syz_kvm_setup_cpu)
 // logical error (e.g. invalid input program), use as an assert() alernative
 NORETURN static void fail(const char* msg, ...)
 {
@@ -126,6 +139,74 @@ static void debug(const char* msg, ...)
 }
 #endif
 
+#if defined(SYZ_BASE64)
+// base64_decode decodes NUL-terminated base64 string src into dst.
+// C programs use it for large data arguments to keep the source small.
+static void base64_decode(char* dst, const char* src)
+{
+	unsigned acc = 0;
+	int bits = 0;
+
+	for (; *src && *src != '='; src++) {
+		char c = *src;
+		unsigned v;
+		if (c >= 'A' && c <= 'Z')
+			v = c - 'A';
+		else if (c >= 'a' && c <= 'z')
+			v = c - 'a' + 26;
+		else if (c >= '0' && c <= '9')
+			v = c - '0' + 52;
+		else if (c == '+')
+			v = 62;
+		else
+			v = 63;
+		acc = (acc << 6) | v;
+		bits += 6;
+		if (bits >= 8) {
+			bits -= 8;
+			*dst++ = (char)(acc >> bits);
+		}
+	}
+}
+#endif
+
+#if defined(SYZ_TRACE)
+// trace_call prints the call with nargs arguments and its result to stderr
+// in strace-like format, so that it's visible how far the program has progressed.
+static void trace_call(int call, const char* name, long res, int nargs, ...)
+{
+	int err = errno;
+	va_list args;
+	int i;
+
+	fprintf(stderr, "#%d: %s(", call, name);
+	va_start(args, nargs);
+	for (i = 0; i < nargs; i++)
+		fprintf(stderr, "%s0x%lx", i ? ", " : "", va_arg(args, long));
+	va_end(args);
+	if (res == -1)
+		fprintf(stderr, ") = -1 (errno %d: %s)\n", err, strerror(err));
+	else
+		fprintf(stderr, ") = 0x%lx\n", res);
+	errno = err;
+}
+#endif
+
+#if defined(SYZ_DATA_OFFSET)
+// C programs map the data area that pointer arguments point to first thing in main,
+// so that they don't depend on mmap calls in the program succeeding.
+// Failure to map it means that the kernel does not allow mappings at this address
+// (e.g. due to vm.mmap_min_addr), then the program can't work as intended.
+static void setup_data_mapping()
+{
+	void* addr = (void*)SYZ_DATA_OFFSET;
+	if (mmap(addr, SYZ_DATA_SIZE, PROT_READ | PROT_WRITE, MAP_PRIVATE | MAP_ANONYMOUS | MAP_FIXED, -1, 0) != addr) {
+		perror("failed to mmap data area");
+		exit(1);
+	}
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(SYZ_USE_BITMASKS)
 #define BITMASK_LEN(type, bf_len) (type)((1ull << (bf_len)) - 1)
 
@@ -173,3 +254,31 @@ static uint16_t csum_inet_digest(struct csum_inet* csum)
 	return ~csum->acc;
 }
 #endif
+
+#if defined(SYZ_EXECUTOR) || defined(SYZ_USE_CRC32C)
+// CRC32c (Castagnoli) as used by SCTP (RFC 4960, Appendix B).
+struct csum_crc32c {
+	uint32_t crc;
+};
+
+static void csum_crc32c_init(struct csum_crc32c* csum)
+{
+	csum->crc = 0xffffffff;
+}
+
+static void csum_crc32c_update(struct csum_crc32c* csum, const uint8_t* data, size_t length)
+{
+	size_t i;
+	int j;
+	for (i = 0; i < length; i++) {
+		csum->crc ^= data[i];
+		for (j = 0; j < 8; j++)
+			csum->crc = (csum->crc >> 1) ^ (0x82f63b78 & -(csum->crc & 1));
+	}
+}
+
+static uint32_t csum_crc32c_digest(struct csum_crc32c* csum)
+{
+	return ~csum->crc;
+}
+#endif
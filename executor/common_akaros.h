@@ -8,7 +8,13 @@
 #include <pthread.h>
 #include <stdlib.h>
 #endif
-#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT))
+#if defined(SYZ_REPEAT_TIMES)
+#include <sys/wait.h>
+#endif
+#if defined(SYZ_THREADED)
+#include <stdbool.h>
+#endif
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) || defined(SYZ_THREADED)
 #include <errno.h>
 #include <signal.h>
 #include <stdarg.h>
@@ -33,7 +39,8 @@
 #if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) ||      \
     defined(SYZ_USE_TMP_DIR) || defined(SYZ_HANDLE_SEGV) || defined(SYZ_TUN_ENABLE) || \
     defined(SYZ_SANDBOX_NAMESPACE) || defined(SYZ_SANDBOX_SETUID) ||                   \
-    defined(SYZ_SANDBOX_NONE) || defined(SYZ_FAULT_INJECTION) || defined(__NR_syz_kvm_setup_cpu)
+    defined(SYZ_SANDBOX_NONE) || defined(SYZ_FAULT_INJECTION) || defined(SYZ_THREADED) ||     \
+    defined(__NR_syz_kvm_setup_cpu)
 __attribute__((noreturn)) static void doexit(int status)
 {
 	_exit(status);
@@ -98,7 +105,7 @@ static void install_segv_handler()
 	}
 #endif
 
-#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT))
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) || defined(SYZ_THREADED)
 static uint64_t current_time_ms()
 {
 	struct timespec ts;
@@ -123,13 +130,97 @@ static void use_temporary_dir()
 }
 #endif
 
-#if defined(SYZ_EXECUTOR)
+#if defined(SYZ_EXECUTOR) || defined(SYZ_THREADED)
 static void sleep_ms(uint64_t ms)
 {
 	usleep(ms * 1000);
 }
 #endif
 
+// Executor has own implementation of events in executor_posix.h,
+// this one is used by C reproducers to synchronize calls between threads the same way.
+#if defined(SYZ_THREADED)
+struct event_t {
+	pthread_mutex_t mu;
+	pthread_cond_t cv;
+	bool state;
+};
+
+static void event_init(struct event_t* ev)
+{
+	if (pthread_mutex_init(&ev->mu, 0))
+		fail("pthread_mutex_init failed");
+	if (pthread_cond_init(&ev->cv, 0))
+		fail("pthread_cond_init failed");
+	ev->state = false;
+}
+
+static void event_reset(struct event_t* ev)
+{
+	ev->state = false;
+}
+
+static void event_set(struct event_t* ev)
+{
+	pthread_mutex_lock(&ev->mu);
+	if (ev->state)
+		fail("event already set");
+	ev->state = true;
+	pthread_mutex_unlock(&ev->mu);
+	pthread_cond_broadcast(&ev->cv);
+}
+
+static void event_wait(struct event_t* ev)
+{
+	pthread_mutex_lock(&ev->mu);
+	while (!ev->state)
+		pthread_cond_wait(&ev->cv, &ev->mu);
+	pthread_mutex_unlock(&ev->mu);
+}
+
+static bool event_isset(struct event_t* ev)
+{
+	pthread_mutex_lock(&ev->mu);
+	bool res = ev->state;
+	pthread_mutex_unlock(&ev->mu);
+	return res;
+}
+
+static bool event_timedwait(struct event_t* ev, uint64_t timeout_ms)
+{
+	pthread_mutex_lock(&ev->mu);
+	uint64_t start = current_time_ms();
+	for (;;) {
+		if (ev->state)
+			break;
+		uint64_t now = current_time_ms();
+		if (now - start > timeout_ms)
+			break;
+		// pthread_cond_timedwait takes absolute CLOCK_REALTIME time.
+		struct timespec ts;
+		clock_gettime(CLOCK_REALTIME, &ts);
+		uint64_t deadline = (uint64_t)ts.tv_nsec + (timeout_ms - (now - start)) * 1000 * 1000;
+		ts.tv_sec += deadline / 1000000000;
+		ts.tv_nsec = deadline % 1000000000;
+		pthread_cond_timedwait(&ev->cv, &ev->mu, &ts);
+	}
+	bool res = ev->state;
+	pthread_mutex_unlock(&ev->mu);
+	return res;
+}
+
+static void thread_start(void* (*fn)(void*), void* arg)
+{
+	pthread_t th;
+	pthread_attr_t attr;
+	pthread_attr_init(&attr);
+	pthread_attr_setstacksize(&attr, 128 << 10);
+	if (pthread_create(&th, &attr, fn, arg))
+		fail("pthread_create failed");
+	pthread_attr_destroy(&attr);
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(SYZ_FAULT_INJECTION)
 static int inject_fault(int nth)
 {
@@ -201,7 +292,11 @@ static void test();
 void loop()
 {
 	int iter;
+#if defined(SYZ_REPEAT_TIMES)
+	for (iter = 0; iter < SYZ_REPEAT_TIMES; iter++) {
+#else
 	for (iter = 0;; iter++) {
+#endif
 #ifdef SYZ_USE_TMP_DIR
 		char cwdbuf[256];
 		sprintf(cwdbuf, "./%d", iter);
@@ -226,7 +321,7 @@ void loop()
 			if (res == pid)
 				break;
 			usleep(1000);
-			if (current_time_ms() - start > 5 * 1000) {
+			if (current_time_ms() - start > SYZ_PROGRAM_TIMEOUT_MS) {
 				kill(pid, SIGKILL);
 				while (waitpid(-1, &status, 0) != pid) {
 				}
@@ -241,7 +336,12 @@ void loop()
 #else
 void loop()
 {
+#if defined(SYZ_REPEAT_TIMES)
+	int iter;
+	for (iter = 0; iter < SYZ_REPEAT_TIMES; iter++) {
+#else
 	while (1) {
+#endif
 		test();
 	}
 }
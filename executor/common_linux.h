@@ -13,7 +13,14 @@
 #include <pthread.h>
 #include <stdlib.h>
 #endif
-#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT))
+#if defined(SYZ_REPEAT_TIMES)
+#include <sys/wait.h>
+#endif
+#if defined(SYZ_THREADED)
+#include <linux/futex.h>
+#include <stdbool.h>
+#endif
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) || defined(SYZ_THREADED)
 #include <errno.h>
 #include <signal.h>
 #include <stdarg.h>
@@ -29,7 +36,8 @@
 #include <dirent.h>
 #include <sys/mount.h>
 #endif
-#if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_NAMESPACE)
+#if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_NAMESPACE) || \
+    defined(SYZ_SANDBOX_SECCOMP) || defined(SYZ_MATCH_EXECUTOR_ENV)
 #include <errno.h>
 #include <sched.h>
 #include <signal.h>
@@ -44,6 +52,11 @@
 #if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_SETUID)
 #include <grp.h>
 #endif
+#if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_SECCOMP)
+#include <linux/filter.h>
+#include <linux/seccomp.h>
+#include <stddef.h>
+#endif
 #if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_NAMESPACE)
 #include <fcntl.h>
 #include <linux/capability.h>
@@ -69,6 +82,9 @@
 #include <sys/stat.h>
 #include <sys/uio.h>
 #endif
+#if defined(SYZ_TUN_NETNS)
+#include <sched.h>
+#endif
 #if defined(SYZ_EXECUTOR) || defined(SYZ_FAULT_INJECTION)
 #include <errno.h>
 #include <fcntl.h>
@@ -77,6 +93,23 @@
 #include <stdio.h>
 #include <sys/stat.h>
 #endif
+#if defined(SYZ_SYSCTLS)
+#include <fcntl.h>
+#include <stdarg.h>
+#include <stdbool.h>
+#include <stdio.h>
+#include <string.h>
+#include <sys/stat.h>
+#endif
+#if defined(SYZ_COVERAGE)
+#include <errno.h>
+#include <fcntl.h>
+#include <stdio.h>
+#include <string.h>
+#include <sys/ioctl.h>
+#include <sys/mman.h>
+#include <sys/stat.h>
+#endif
 #if defined(SYZ_EXECUTOR) || defined(__NR_syz_open_dev)
 #include <fcntl.h>
 #include <stdio.h>
@@ -88,12 +121,63 @@
 #include <sys/stat.h>
 #include <sys/sysmacros.h>
 #endif
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_mount_image)
+#include <errno.h>
+#include <fcntl.h>
+#include <linux/loop.h>
+#include <stdio.h>
+#include <sys/ioctl.h>
+#include <sys/mount.h>
+#include <sys/stat.h>
+#include <unistd.h>
+#endif
 #if defined(SYZ_EXECUTOR) || defined(__NR_syz_open_pts)
 #include <fcntl.h>
 #include <stdio.h>
 #include <sys/ioctl.h>
 #include <sys/stat.h>
 #endif
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_usb_connect) || defined(__NR_syz_usb_control_io) || \
+    defined(__NR_syz_usb_disconnect)
+#include <fcntl.h>
+#include <linux/usb/ch9.h>
+#include <stdbool.h>
+#include <string.h>
+#include <sys/ioctl.h>
+#include <sys/stat.h>
+#include <unistd.h>
+#endif
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_binder_open) || defined(__NR_syz_binder_transaction) || \
+    defined(__NR_syz_binder_read)
+#include <fcntl.h>
+#include <linux/android/binder.h>
+#include <string.h>
+#include <sys/ioctl.h>
+#include <sys/mman.h>
+#include <sys/stat.h>
+#include <unistd.h>
+#endif
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_ashmem_create)
+#include <fcntl.h>
+#include <string.h>
+#include <sys/ioctl.h>
+#include <sys/stat.h>
+#include <unistd.h>
+#endif
+#if defined(SYZ_WATCHDOG)
+#include <errno.h>
+#include <fcntl.h>
+#include <signal.h>
+#include <stdio.h>
+#include <string.h>
+#include <sys/prctl.h>
+#include <sys/wait.h>
+#include <unistd.h>
+#endif
+#if defined(SYZ_AFFINITY)
+#include <sched.h>
+#include <unistd.h>
+#endif
 #if defined(SYZ_EXECUTOR) || defined(__NR_syz_kvm_setup_cpu)
 #include <errno.h>
 #include <fcntl.h>
@@ -108,7 +192,8 @@
 #if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) ||      \
     defined(SYZ_USE_TMP_DIR) || defined(SYZ_HANDLE_SEGV) || defined(SYZ_TUN_ENABLE) || \
     defined(SYZ_SANDBOX_NAMESPACE) || defined(SYZ_SANDBOX_SETUID) ||                   \
-    defined(SYZ_SANDBOX_NONE) || defined(SYZ_FAULT_INJECTION) || defined(__NR_syz_kvm_setup_cpu)
+    defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_SECCOMP) || defined(SYZ_FAULT_INJECTION) || \
+    defined(SYZ_THREADED) || defined(__NR_syz_kvm_setup_cpu)
 // One does not simply exit.
 // _exit can in fact fail.
 // syzkaller did manage to generate a seccomp filter that prohibits exit_group syscall.
@@ -185,7 +270,7 @@ static void install_segv_handler()
 	}
 #endif
 
-#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT))
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) || defined(SYZ_THREADED)
 static uint64_t current_time_ms()
 {
 	struct timespec ts;
@@ -196,13 +281,78 @@ static uint64_t current_time_ms()
 }
 #endif
 
-#if defined(SYZ_EXECUTOR)
+#if defined(SYZ_EXECUTOR) || defined(SYZ_THREADED)
 static void sleep_ms(uint64_t ms)
 {
 	usleep(ms * 1000);
 }
 #endif
 
+// Executor has own implementation of events in executor_linux.h,
+// this one is used by C reproducers to synchronize calls between threads the same way.
+#if defined(SYZ_THREADED)
+struct event_t {
+	int state;
+};
+
+static void event_init(struct event_t* ev)
+{
+	ev->state = 0;
+}
+
+static void event_reset(struct event_t* ev)
+{
+	ev->state = 0;
+}
+
+static void event_set(struct event_t* ev)
+{
+	if (ev->state)
+		fail("event already set");
+	__atomic_store_n(&ev->state, 1, __ATOMIC_RELEASE);
+	syscall(SYS_futex, &ev->state, FUTEX_WAKE);
+}
+
+static void event_wait(struct event_t* ev)
+{
+	while (!__atomic_load_n(&ev->state, __ATOMIC_ACQUIRE))
+		syscall(SYS_futex, &ev->state, FUTEX_WAIT, 0, 0);
+}
+
+static bool event_isset(struct event_t* ev)
+{
+	return __atomic_load_n(&ev->state, __ATOMIC_ACQUIRE);
+}
+
+static bool event_timedwait(struct event_t* ev, uint64_t timeout_ms)
+{
+	uint64_t start = current_time_ms();
+	uint64_t now = start;
+	for (;;) {
+		struct timespec ts;
+		ts.tv_sec = 0;
+		ts.tv_nsec = (timeout_ms - (now - start)) * 1000 * 1000;
+		syscall(SYS_futex, &ev->state, FUTEX_WAIT, 0, &ts);
+		if (__atomic_load_n(&ev->state, __ATOMIC_RELAXED))
+			return true;
+		now = current_time_ms();
+		if (now - start > timeout_ms)
+			return false;
+	}
+}
+
+static void thread_start(void* (*fn)(void*), void* arg)
+{
+	pthread_t th;
+	pthread_attr_t attr;
+	pthread_attr_init(&attr);
+	pthread_attr_setstacksize(&attr, 128 << 10);
+	if (pthread_create(&th, &attr, fn, arg))
+		fail("pthread_create failed");
+	pthread_attr_destroy(&attr);
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(SYZ_USE_TMP_DIR)
 static void use_temporary_dir()
 {
@@ -217,6 +367,22 @@ static void use_temporary_dir()
 }
 #endif
 
+#if defined(SYZ_REPEAT_TIMEOUT_SEC) && defined(SYZ_USE_TMP_DIR)
+static void remove_dir(const char* dir);
+
+// remove_temporary_dir removes the current dir created by use_temporary_dir
+// after all test processes have exited.
+static void remove_temporary_dir()
+{
+	char cwd[256];
+	if (!getcwd(cwd, sizeof(cwd)))
+		exitf("getcwd failed");
+	if (chdir(".."))
+		exitf("failed to chdir");
+	remove_dir(cwd);
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(SYZ_TUN_ENABLE)
 static void vsnprintf_check(char* str, size_t size, const char* format, va_list args)
 {
@@ -355,6 +521,19 @@ static void setup_tun(uint64_t pid, bool enable_tun)
 }
 #endif
 
+#if defined(SYZ_TUN_NETNS)
+// Executor processes share the network namespace (tun devices and addresses are per-pid),
+// but in C programs with several procs each proc gets own network namespace.
+// This way procs don't interfere with each other via sockets bound to the same ports
+// and the host network is not affected.
+static void setup_net_namespace()
+{
+	if (unshare(CLONE_NEWNET))
+		fail("tun: unshare(CLONE_NEWNET) failed");
+	execute_command("ip link set dev lo up");
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || (defined(SYZ_TUN_ENABLE) && (defined(__NR_syz_extract_tcp_res) || defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)))
 static int read_tun(char* data, int size)
 {
@@ -626,6 +805,525 @@ static uintptr_t syz_fuseblk_mount(uintptr_t a0, uintptr_t a1, uintptr_t a2, uin
 }
 #endif
 
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_mount_image)
+struct fs_image_segment {
+	void* data;
+	uintptr_t size;
+	uintptr_t offset;
+};
+
+#define IMAGE_MAX_SEGMENTS 4096
+#define IMAGE_MAX_SIZE (129 << 20)
+
+#ifndef __NR_memfd_create
+#if defined(__x86_64__)
+#define __NR_memfd_create 319
+#elif defined(__i386__)
+#define __NR_memfd_create 356
+#elif defined(__aarch64__)
+#define __NR_memfd_create 279
+#elif defined(__arm__)
+#define __NR_memfd_create 385
+#elif defined(__powerpc64__)
+#define __NR_memfd_create 360
+#endif
+#endif
+
+static uintptr_t syz_mount_image(uintptr_t fs, uintptr_t dir, uintptr_t size, uintptr_t nsegs, uintptr_t segments, uintptr_t flags, uintptr_t opts)
+{
+	// syz_mount_image(fs ptr[in, string[image_filesystem]], dir ptr[in, filename], size intptr, nsegs len[segments], segments ptr[in, array[fs_image_segment]], flags flags[mount_flags], opts ptr[in, string, opt])
+	char loopname[64];
+	int loopfd, memfd, ctlfd, n, try_;
+	int err = 0, res = -1;
+	uintptr_t i;
+	struct fs_image_segment* segs = (struct fs_image_segment*)segments;
+
+	if (nsegs > IMAGE_MAX_SEGMENTS)
+		nsegs = IMAGE_MAX_SEGMENTS;
+	for (i = 0; i < nsegs; i++) {
+		if (segs[i].size > IMAGE_MAX_SIZE)
+			segs[i].size = IMAGE_MAX_SIZE;
+		segs[i].offset %= IMAGE_MAX_SIZE;
+		if (segs[i].offset > IMAGE_MAX_SIZE - segs[i].size)
+			segs[i].offset = IMAGE_MAX_SIZE - segs[i].size;
+		if (size < segs[i].offset + segs[i].size)
+			size = segs[i].offset + segs[i].size;
+	}
+	if (size > IMAGE_MAX_SIZE)
+		size = IMAGE_MAX_SIZE;
+	memfd = syscall(__NR_memfd_create, "syz_mount_image", 0);
+	if (memfd == -1)
+		return -1;
+	if (ftruncate(memfd, size)) {
+		err = errno;
+		goto error;
+	}
+	for (i = 0; i < nsegs; i++) {
+		if (pwrite(memfd, segs[i].data, segs[i].size, segs[i].offset) < 0) {
+			debug("syz_mount_image: pwrite[%d] failed: %d\n", (int)i, errno);
+		}
+	}
+	// Concurrent processes can get the same free loop device, so retry if it's taken.
+	for (try_ = 0;; try_++) {
+		ctlfd = open("/dev/loop-control", O_RDWR);
+		if (ctlfd == -1) {
+			err = errno;
+			goto error;
+		}
+		n = ioctl(ctlfd, LOOP_CTL_GET_FREE);
+		close(ctlfd);
+		if (n < 0) {
+			err = errno;
+			goto error;
+		}
+		snprintf(loopname, sizeof(loopname), "/dev/loop%d", n);
+		loopfd = open(loopname, O_RDWR);
+		if (loopfd == -1) {
+			err = errno;
+			goto error;
+		}
+		if (ioctl(loopfd, LOOP_SET_FD, memfd) == 0)
+			break;
+		err = errno;
+		close(loopfd);
+		if (err != EBUSY || try_ >= 100)
+			goto error;
+		usleep(1000);
+	}
+	mkdir((char*)dir, 0777);
+	res = mount(loopname, (char*)dir, (char*)fs, flags, (char*)opts);
+	if (res == -1) {
+		err = errno;
+		ioctl(loopfd, LOOP_CLR_FD, 0);
+	} else {
+		// Release the loop device when the filesystem is unmounted.
+		struct loop_info64 info;
+		memset(&info, 0, sizeof(info));
+		if (ioctl(loopfd, LOOP_GET_STATUS64, &info) == 0) {
+			info.lo_flags |= LO_FLAGS_AUTOCLEAR;
+			ioctl(loopfd, LOOP_SET_STATUS64, &info);
+		}
+	}
+	close(loopfd);
+error:
+	close(memfd);
+	errno = err;
+	return res;
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_usb_connect) || defined(__NR_syz_usb_control_io)
+// USB devices are emulated with /dev/raw-gadget connected to dummy_hcd.
+// The raw-gadget interface is not in all kernel headers yet, so it's described here.
+#define USB_RAW_EVENT_CONNECT 1
+#define USB_RAW_EVENT_CONTROL 2
+
+struct usb_raw_init {
+	uint8_t driver_name[128];
+	uint8_t device_name[128];
+	uint8_t speed;
+};
+
+struct usb_raw_event {
+	uint32_t type;
+	uint32_t length;
+	uint8_t data[0];
+};
+
+struct usb_raw_ep_io {
+	uint16_t ep;
+	uint16_t flags;
+	uint32_t length;
+	uint8_t data[0];
+};
+
+#define USB_RAW_IOCTL_INIT _IOW('U', 0, struct usb_raw_init)
+#define USB_RAW_IOCTL_RUN _IO('U', 1)
+#define USB_RAW_IOCTL_EVENT_FETCH _IOR('U', 2, struct usb_raw_event)
+#define USB_RAW_IOCTL_EP0_WRITE _IOW('U', 3, struct usb_raw_ep_io)
+#define USB_RAW_IOCTL_EP0_READ _IOWR('U', 4, struct usb_raw_ep_io)
+#define USB_RAW_IOCTL_EP_ENABLE _IOW('U', 5, struct usb_endpoint_descriptor)
+#define USB_RAW_IOCTL_CONFIGURE _IO('U', 9)
+#define USB_RAW_IOCTL_VBUS_DRAW _IOW('U', 10, uint32_t)
+
+#define USB_EP0_MAX_DATA 1024
+
+struct usb_raw_control_event {
+	struct usb_raw_event inner;
+	struct usb_ctrlrequest ctrl;
+};
+
+struct usb_raw_ep0_io {
+	struct usb_raw_ep_io inner;
+	char data[USB_EP0_MAX_DATA];
+};
+
+static int usb_raw_fetch_control(int fd, struct usb_raw_control_event* event)
+{
+	event->inner.type = 0;
+	event->inner.length = sizeof(event->ctrl);
+	return ioctl(fd, USB_RAW_IOCTL_EVENT_FETCH, event);
+}
+
+static int usb_raw_ep0_write(int fd, const char* data, uint32_t length)
+{
+	struct usb_raw_ep0_io io;
+	if (length > sizeof(io.data))
+		length = sizeof(io.data);
+	io.inner.ep = 0;
+	io.inner.flags = 0;
+	io.inner.length = length;
+	if (length)
+		memcpy(io.data, data, length);
+	return ioctl(fd, USB_RAW_IOCTL_EP0_WRITE, &io);
+}
+
+static int usb_raw_ep0_read(int fd, uint32_t length)
+{
+	struct usb_raw_ep0_io io;
+	if (length > sizeof(io.data))
+		length = sizeof(io.data);
+	io.inner.ep = 0;
+	io.inner.flags = 0;
+	io.inner.length = length;
+	return ioctl(fd, USB_RAW_IOCTL_EP0_READ, &io);
+}
+
+// usb_raw_ep0_ack completes a control request that we don't have a meaningful answer for.
+static int usb_raw_ep0_ack(int fd, struct usb_ctrlrequest* ctrl)
+{
+	if (ctrl->bRequestType & USB_DIR_IN)
+		return usb_raw_ep0_write(fd, NULL, 0);
+	return usb_raw_ep0_read(fd, ctrl->wLength);
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_usb_connect)
+// usb_enable_endpoints walks over descriptors that follow the config descriptor
+// and enables all endpoints they describe.
+static void usb_enable_endpoints(int fd, const char* config, uint32_t length)
+{
+	uint32_t off = 0;
+	while (off + 2 <= length) {
+		uint8_t len = config[off];
+		uint8_t type = config[off + 1];
+		if (len < 2 || off + len > length)
+			break;
+		if (type == USB_DT_ENDPOINT && len >= USB_DT_ENDPOINT_SIZE) {
+			struct usb_endpoint_descriptor ep;
+			memset(&ep, 0, sizeof(ep));
+			memcpy(&ep, config + off, USB_DT_ENDPOINT_SIZE);
+			ioctl(fd, USB_RAW_IOCTL_EP_ENABLE, &ep);
+		}
+		off += len;
+	}
+}
+
+// Maximum number of control requests processed during enumeration.
+#define USB_MAX_ENUM_REQUESTS 64
+
+static uintptr_t syz_usb_connect(uintptr_t a0, uintptr_t a1, uintptr_t a2)
+{
+	// syz_usb_connect(speed flags[usb_device_speed], dev_len len[dev], dev ptr[in, usb_device_descriptor]) fd_usb
+	static const char lang_ids[] = {4, USB_DT_STRING, 0x09, 0x04};
+	uint8_t speed = (uint8_t)a0;
+	uint64_t dev_len = a1;
+	const char* dev = (const char*)a2;
+	const char* config = dev + USB_DT_DEVICE_SIZE;
+	uint32_t config_len;
+	struct usb_raw_init init;
+	struct usb_raw_control_event event;
+	uint32_t power;
+	int fd, i;
+
+	if (dev_len < USB_DT_DEVICE_SIZE + USB_DT_CONFIG_SIZE || dev_len > USB_EP0_MAX_DATA)
+		return -1;
+	config_len = dev_len - USB_DT_DEVICE_SIZE;
+	fd = open("/dev/raw-gadget", O_RDWR);
+	if (fd == -1)
+		return -1;
+	memset(&init, 0, sizeof(init));
+	strcpy((char*)init.driver_name, "dummy_udc");
+	strcpy((char*)init.device_name, "dummy_udc.0");
+	init.speed = speed;
+	if (ioctl(fd, USB_RAW_IOCTL_INIT, &init) || ioctl(fd, USB_RAW_IOCTL_RUN, 0)) {
+		close(fd);
+		return -1;
+	}
+	for (i = 0; i < USB_MAX_ENUM_REQUESTS; i++) {
+		if (usb_raw_fetch_control(fd, &event) == -1)
+			break;
+		if (event.inner.type != USB_RAW_EVENT_CONTROL)
+			continue;
+		if ((event.ctrl.bRequestType & USB_TYPE_MASK) == USB_TYPE_STANDARD &&
+		    event.ctrl.bRequest == USB_REQ_GET_DESCRIPTOR) {
+			const char* data = NULL;
+			uint32_t len = 0;
+			switch (event.ctrl.wValue >> 8) {
+			case USB_DT_DEVICE:
+				data = dev;
+				len = USB_DT_DEVICE_SIZE;
+				break;
+			case USB_DT_CONFIG:
+				data = config;
+				len = config_len;
+				break;
+			case USB_DT_STRING:
+				data = lang_ids;
+				len = sizeof(lang_ids);
+				break;
+			}
+			if (len > event.ctrl.wLength)
+				len = event.ctrl.wLength;
+			usb_raw_ep0_write(fd, data, len);
+			continue;
+		}
+		if ((event.ctrl.bRequestType & USB_TYPE_MASK) == USB_TYPE_STANDARD &&
+		    event.ctrl.bRequest == USB_REQ_SET_CONFIGURATION) {
+			usb_enable_endpoints(fd, config + USB_DT_CONFIG_SIZE, config_len - USB_DT_CONFIG_SIZE);
+			// bMaxPower is in 2mA units.
+			power = 2 * (uint8_t)config[8];
+			ioctl(fd, USB_RAW_IOCTL_VBUS_DRAW, power);
+			ioctl(fd, USB_RAW_IOCTL_CONFIGURE, 0);
+			usb_raw_ep0_ack(fd, &event.ctrl);
+			return fd;
+		}
+		usb_raw_ep0_ack(fd, &event.ctrl);
+	}
+	close(fd);
+	return -1;
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_usb_control_io)
+struct vusb_response {
+	uint32_t len;
+	char data[0];
+};
+
+static uintptr_t syz_usb_control_io(uintptr_t a0, uintptr_t a1)
+{
+	// syz_usb_control_io(fd fd_usb, resp ptr[in, vusb_response, opt])
+	int fd = a0;
+	const struct vusb_response* resp = (const struct vusb_response*)a1;
+	struct usb_raw_control_event event;
+	uint32_t len;
+
+	if (usb_raw_fetch_control(fd, &event) == -1)
+		return -1;
+	if (event.inner.type != USB_RAW_EVENT_CONTROL)
+		return -1;
+	if (!(event.ctrl.bRequestType & USB_DIR_IN) || !resp)
+		return usb_raw_ep0_ack(fd, &event.ctrl);
+	len = resp->len;
+	if (len > event.ctrl.wLength)
+		len = event.ctrl.wLength;
+	return usb_raw_ep0_write(fd, resp->data, len);
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_usb_disconnect)
+static uintptr_t syz_usb_disconnect(uintptr_t a0)
+{
+	// syz_usb_disconnect(fd fd_usb)
+	// Closing the raw-gadget fd disconnects the device from the bus.
+	return close((int)a0);
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_binder_open) || defined(__NR_syz_binder_transaction) || \
+    defined(__NR_syz_binder_read)
+static int binder_write(int fd, const void* data, size_t size)
+{
+	struct binder_write_read bwr;
+	memset(&bwr, 0, sizeof(bwr));
+	bwr.write_size = size;
+	bwr.write_buffer = (binder_uintptr_t)(uintptr_t)data;
+	return ioctl(fd, BINDER_WRITE_READ, &bwr);
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_binder_open)
+// Size of the receive buffer mapping, the driver limits it to 4MB.
+#define BINDER_VM_SIZE (1 << 20)
+
+static uintptr_t syz_binder_open(uintptr_t a0, uintptr_t a1)
+{
+	// syz_binder_open(dev ptr[in, string[binder_devices]], ctx_mgr int32[0:1]) fd_binder
+	char dev[64];
+	uint32_t cmd = BC_ENTER_LOOPER;
+	int fd;
+
+	NONFAILING(strncpy(dev, (char*)a0, sizeof(dev)));
+	dev[sizeof(dev) - 1] = 0;
+	// Non-blocking, so that syz_binder_read does not hang when there is no incoming work.
+	fd = open(dev, O_RDWR | O_NONBLOCK | O_CLOEXEC);
+	if (fd == -1)
+		return -1;
+	// The driver does not deliver transactions to a process without the buffer mapping
+	// and to threads that did not enter the looper.
+	if (mmap(NULL, BINDER_VM_SIZE, PROT_READ, MAP_PRIVATE, fd, 0) == MAP_FAILED) {
+		close(fd);
+		return -1;
+	}
+	binder_write(fd, &cmd, sizeof(cmd));
+	// There is at most one context manager per device, so this fails if somebody else is it.
+	if (a1)
+		ioctl(fd, BINDER_SET_CONTEXT_MGR, 0);
+	return fd;
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_binder_transaction)
+struct syz_binder_txn {
+	uint32_t handle;
+	uint32_t code;
+	uint32_t flags;
+	uint32_t data_size;
+	uint64_t data;
+	uint64_t objs_size;
+	uint64_t objs;
+};
+
+#define BINDER_TXN_MAX_DATA 4096
+#define BINDER_TXN_MAX_OBJECTS 32
+
+static size_t binder_object_size(uint32_t type)
+{
+	switch (type) {
+	case BINDER_TYPE_BINDER:
+	case BINDER_TYPE_WEAK_BINDER:
+	case BINDER_TYPE_HANDLE:
+	case BINDER_TYPE_WEAK_HANDLE:
+		return sizeof(struct flat_binder_object);
+	case BINDER_TYPE_FD:
+		return sizeof(struct binder_fd_object);
+	case BINDER_TYPE_PTR:
+		return sizeof(struct binder_buffer_object);
+	case BINDER_TYPE_FDA:
+		return sizeof(struct binder_fd_array_object);
+	}
+	return 0;
+}
+
+static uintptr_t syz_binder_transaction(uintptr_t a0, uintptr_t a1, uintptr_t a2)
+{
+	// syz_binder_transaction(fd fd_binder, reply int32[0:1], txn ptr[in, binder_txn])
+	int fd = a0;
+	struct syz_binder_txn txn;
+	char buf[BINDER_TXN_MAX_DATA] __attribute__((aligned(8)));
+	binder_size_t offsets[BINDER_TXN_MAX_OBJECTS];
+	struct {
+		uint32_t cmd;
+		struct binder_transaction_data_sg tr;
+	} __attribute__((packed)) wr;
+	uint64_t off = 0, buffers_size = 0;
+	unsigned nobjs = 0;
+
+	memset(&txn, 0, sizeof(txn));
+	NONFAILING(txn = *(struct syz_binder_txn*)a2);
+	if (txn.objs_size > sizeof(buf))
+		txn.objs_size = sizeof(buf);
+	NONFAILING(memcpy(buf, (char*)(uintptr_t)txn.objs, txn.objs_size));
+	// Objects are laid out one after another at the beginning of the buffer,
+	// their offsets are computed here because the fuzzer can't guess them.
+	while (nobjs < BINDER_TXN_MAX_OBJECTS && off + sizeof(struct binder_object_header) <= txn.objs_size) {
+		struct binder_object_header* hdr = (struct binder_object_header*)(buf + off);
+		size_t size = binder_object_size(hdr->type);
+		if (size == 0 || off + size > txn.objs_size)
+			break;
+		// Scatter-gather buffers are copied into the extra buffers space, 8-byte aligned.
+		if (hdr->type == BINDER_TYPE_PTR)
+			buffers_size += (((struct binder_buffer_object*)hdr)->length + 7) & ~7ull;
+		offsets[nobjs++] = off;
+		off += size;
+	}
+	if (txn.data_size > sizeof(buf) - off)
+		txn.data_size = sizeof(buf) - off;
+	NONFAILING(memcpy(buf + off, (char*)(uintptr_t)txn.data, txn.data_size));
+
+	memset(&wr, 0, sizeof(wr));
+	if (buffers_size)
+		wr.cmd = a1 ? BC_REPLY_SG : BC_TRANSACTION_SG;
+	else
+		wr.cmd = a1 ? BC_REPLY : BC_TRANSACTION;
+	wr.tr.transaction_data.target.handle = txn.handle;
+	wr.tr.transaction_data.code = txn.code;
+	wr.tr.transaction_data.flags = txn.flags;
+	wr.tr.transaction_data.data_size = off + txn.data_size;
+	wr.tr.transaction_data.offsets_size = nobjs * sizeof(offsets[0]);
+	wr.tr.transaction_data.data.ptr.buffer = (binder_uintptr_t)(uintptr_t)buf;
+	wr.tr.transaction_data.data.ptr.offsets = (binder_uintptr_t)(uintptr_t)offsets;
+	wr.tr.buffers_size = buffers_size;
+	if (buffers_size)
+		return binder_write(fd, &wr, sizeof(wr));
+	return binder_write(fd, &wr, sizeof(wr.cmd) + sizeof(wr.tr.transaction_data));
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_binder_read)
+static uintptr_t syz_binder_read(uintptr_t a0)
+{
+	// syz_binder_read(fd fd_binder)
+	int fd = a0;
+	char buf[1024] __attribute__((aligned(8)));
+	struct binder_write_read bwr;
+	struct {
+		uint32_t cmd;
+		binder_uintptr_t buffer;
+	} __attribute__((packed)) free_cmd;
+	uint64_t off = 0;
+
+	memset(&bwr, 0, sizeof(bwr));
+	bwr.read_size = sizeof(buf);
+	bwr.read_buffer = (binder_uintptr_t)(uintptr_t)buf;
+	if (ioctl(fd, BINDER_WRITE_READ, &bwr))
+		return -1;
+	while (off + sizeof(uint32_t) <= bwr.read_consumed) {
+		uint32_t cmd = *(uint32_t*)(buf + off);
+		off += sizeof(cmd);
+		if (off + _IOC_SIZE(cmd) > bwr.read_consumed)
+			break;
+		// Received buffers stay allocated in the receive mapping until they are freed,
+		// without this the mapping is quickly exhausted.
+		if (cmd == BR_TRANSACTION || cmd == BR_REPLY) {
+			struct binder_transaction_data* tr = (struct binder_transaction_data*)(buf + off);
+			free_cmd.cmd = BC_FREE_BUFFER;
+			free_cmd.buffer = tr->data.ptr.buffer;
+			binder_write(fd, &free_cmd, sizeof(free_cmd));
+		}
+		off += _IOC_SIZE(cmd);
+	}
+	return 0;
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(__NR_syz_ashmem_create)
+// ashmem is in staging and its uapi header is not installed, so the ioctls are described here.
+#define ASHMEM_NAME_LEN 256
+#define ASHMEM_SET_NAME _IOW(0x77, 1, char[ASHMEM_NAME_LEN])
+#define ASHMEM_SET_SIZE _IOW(0x77, 3, size_t)
+
+static uintptr_t syz_ashmem_create(uintptr_t a0, uintptr_t a1)
+{
+	// syz_ashmem_create(name ptr[in, string[ashmem_names]], size intptr) fd_ashmem
+	char name[ASHMEM_NAME_LEN];
+	int fd;
+
+	NONFAILING(strncpy(name, (char*)a0, sizeof(name)));
+	name[sizeof(name) - 1] = 0;
+	fd = open("/dev/ashmem", O_RDWR | O_CLOEXEC);
+	if (fd == -1)
+		return -1;
+	// The region can be passed to other processes in binder fd objects and mapped there.
+	if (ioctl(fd, ASHMEM_SET_NAME, name) || ioctl(fd, ASHMEM_SET_SIZE, (size_t)a1)) {
+		close(fd);
+		return -1;
+	}
+	return fd;
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(__NR_syz_kvm_setup_cpu)
 #if defined(__x86_64__)
 #include "common_kvm_amd64.h"
@@ -647,15 +1345,12 @@ static uintptr_t syz_test()
 }
 #endif
 
-#if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_NAMESPACE)
-static void loop();
-
-static void sandbox_common()
+#if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_NAMESPACE) || \
+    defined(SYZ_SANDBOX_SECCOMP) || defined(SYZ_MATCH_EXECUTOR_ENV)
+// Resource limits of test processes. C programs without a sandbox apply them
+// with SYZ_MATCH_EXECUTOR_ENV, some bugs trigger only under these limits.
+static void setup_rlimits()
 {
-	prctl(PR_SET_PDEATHSIG, SIGKILL, 0, 0, 0);
-	setpgrp();
-	setsid();
-
 	struct rlimit rlim;
 	rlim.rlim_cur = rlim.rlim_max = 128 << 20;
 	setrlimit(RLIMIT_AS, &rlim);
@@ -665,8 +1360,26 @@ static void sandbox_common()
 	setrlimit(RLIMIT_FSIZE, &rlim);
 	rlim.rlim_cur = rlim.rlim_max = 1 << 20;
 	setrlimit(RLIMIT_STACK, &rlim);
+	// Don't depend on the limit inherited from whoever started the executor
+	// (executor's own fds are remapped below the limit).
+	rlim.rlim_cur = rlim.rlim_max = 256;
+	setrlimit(RLIMIT_NOFILE, &rlim);
+	// No core dumps.
 	rlim.rlim_cur = rlim.rlim_max = 0;
 	setrlimit(RLIMIT_CORE, &rlim);
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_NAMESPACE) || \
+    defined(SYZ_SANDBOX_SECCOMP)
+static void loop();
+
+static void sandbox_common()
+{
+	prctl(PR_SET_PDEATHSIG, SIGKILL, 0, 0, 0);
+	setpgrp();
+	setsid();
+	setup_rlimits();
 
 	// CLONE_NEWIPC/CLONE_IO cause EINVAL on some systems, so we do them separately of clone.
 	unshare(CLONE_NEWNS);
@@ -692,6 +1405,68 @@ static int do_sandbox_none(int executor_pid, bool enable_tun)
 }
 #endif
 
+#if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_SECCOMP)
+// Syscall numbers denied by the seccomp sandbox.
+// Executor receives them from the fuzzer at runtime, C programs have them hardcoded in SYZ_SECCOMP_DENY.
+#if defined(SYZ_EXECUTOR)
+static int seccomp_deny[256];
+static int seccomp_deny_count;
+#elif defined(SYZ_SECCOMP_DENY)
+static int seccomp_deny[] = {SYZ_SECCOMP_DENY};
+static int seccomp_deny_count = sizeof(seccomp_deny) / sizeof(seccomp_deny[0]);
+#else
+static int* seccomp_deny;
+static int seccomp_deny_count;
+#endif
+
+static void seccomp_insn(struct sock_filter* insn, unsigned short code, unsigned char jt, unsigned char jf, unsigned int k)
+{
+	insn->code = code;
+	insn->jt = jt;
+	insn->jf = jf;
+	insn->k = k;
+}
+
+static void install_seccomp_filter()
+{
+	// Denied syscalls fail with EPERM, everything else is allowed.
+	// Note: the filter does not check arch, so compat syscalls are matched by the same numbers.
+	struct sock_filter filter[2 * 256 + 2];
+	int n = 0, i;
+	if (seccomp_deny_count > 256)
+		fail("too many denied syscalls: %d", seccomp_deny_count);
+	seccomp_insn(&filter[n++], BPF_LD | BPF_W | BPF_ABS, 0, 0, offsetof(struct seccomp_data, nr));
+	for (i = 0; i < seccomp_deny_count; i++) {
+		seccomp_insn(&filter[n++], BPF_JMP | BPF_JEQ | BPF_K, 0, 1, seccomp_deny[i]);
+		seccomp_insn(&filter[n++], BPF_RET | BPF_K, 0, 0, SECCOMP_RET_ERRNO | EPERM);
+	}
+	seccomp_insn(&filter[n++], BPF_RET | BPF_K, 0, 0, SECCOMP_RET_ALLOW);
+	struct sock_fprog prog;
+	prog.len = n;
+	prog.filter = filter;
+	if (prctl(PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0))
+		fail("prctl(PR_SET_NO_NEW_PRIVS) failed");
+	if (prctl(PR_SET_SECCOMP, SECCOMP_MODE_FILTER, &prog))
+		fail("prctl(PR_SET_SECCOMP) failed");
+}
+
+static int do_sandbox_seccomp(int executor_pid, bool enable_tun)
+{
+	int pid = fork();
+	if (pid)
+		return pid;
+
+	sandbox_common();
+#if defined(SYZ_EXECUTOR) || defined(SYZ_TUN_ENABLE)
+	setup_tun(executor_pid, enable_tun);
+#endif
+	install_seccomp_filter();
+
+	loop();
+	doexit(1);
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_SETUID)
 static int do_sandbox_setuid(int executor_pid, bool enable_tun)
 {
@@ -722,7 +1497,7 @@ static int do_sandbox_setuid(int executor_pid, bool enable_tun)
 }
 #endif
 
-#if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_NAMESPACE) || defined(SYZ_FAULT_INJECTION)
+#if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_NAMESPACE) || defined(SYZ_FAULT_INJECTION) || defined(SYZ_SYSCTLS)
 static bool write_file(const char* file, const char* what, ...)
 {
 	char buf[1024];
@@ -745,20 +1520,90 @@ static bool write_file(const char* file, const char* what, ...)
 }
 #endif
 
+#if defined(SYZ_SYSCTLS)
+// C programs set the same sysctls as the manager sets in fuzzing VMs.
+// SYZ_SYSCTLS is a list of {"/proc/sys/...", "value"} pairs.
+// Failures (e.g. sysctl not present in the kernel) are ignored, the program should still run.
+static void setup_sysctls()
+{
+	static const char* sysctls[][2] = {SYZ_SYSCTLS};
+	unsigned i;
+	for (i = 0; i < sizeof(sysctls) / sizeof(sysctls[0]); i++)
+		write_file(sysctls[i][0], "%s", sysctls[i][1]);
+}
+#endif
+
+#if defined(SYZ_SANDBOX_NAMESPACE) && defined(SYZ_ENABLE_CGROUPS)
+#define SYZ_CGROUP_ROOT "/syzcgroup"
+
+// setup_cgroups mounts cpu, memory and pids cgroup hierarchies (if they are not mounted yet)
+// and moves the current process into a dedicated per-proc cgroup in each of them.
+// The sandboxed process and all its children inherit these cgroups.
+// Failures are not fatal, since some controllers may be missing or already
+// mounted in a different combination by the init system.
+static void setup_cgroups(int proc)
+{
+	static const char* controllers[] = {"cpu", "memory", "pids"};
+	char dir[64], file[128];
+	unsigned i;
+
+	if (mkdir(SYZ_CGROUP_ROOT, 0777) && errno != EEXIST) {
+		debug("mkdir(%s) failed: %d\n", SYZ_CGROUP_ROOT, errno);
+		return;
+	}
+	for (i = 0; i < sizeof(controllers) / sizeof(controllers[0]); i++) {
+		snprintf(dir, sizeof(dir), SYZ_CGROUP_ROOT "/%s", controllers[i]);
+		if (mkdir(dir, 0777) && errno != EEXIST) {
+			debug("mkdir(%s) failed: %d\n", dir, errno);
+			continue;
+		}
+		snprintf(file, sizeof(file), "%s/cgroup.procs", dir);
+		if (access(file, F_OK) && mount("none", dir, "cgroup", 0, controllers[i])) {
+			debug("mount(%s) failed: %d\n", dir, errno);
+			continue;
+		}
+		snprintf(dir, sizeof(dir), SYZ_CGROUP_ROOT "/%s/syz%d", controllers[i], proc);
+		if (mkdir(dir, 0777) && errno != EEXIST) {
+			debug("mkdir(%s) failed: %d\n", dir, errno);
+			continue;
+		}
+		// Let the sandboxed process create nested cgroups.
+		chmod(dir, 0777);
+		snprintf(file, sizeof(file), "%s/cgroup.procs", dir);
+		if (!write_file(file, "%d", getpid())) {
+			debug("write(%s) failed: %d\n", file, errno);
+		}
+	}
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(SYZ_SANDBOX_NAMESPACE)
 static int real_uid;
 static int real_gid;
 __attribute__((aligned(64 << 10))) static char sandbox_stack[1 << 20];
 
+// C programs can run test processes with a particular UID/GID inside of the user namespace
+// (mapped to the real UID/GID) to reproduce permission-dependent bugs, root is used by default.
+#if defined(SYZ_SANDBOX_UID)
+static const int sandbox_uid = SYZ_SANDBOX_UID;
+#else
+static const int sandbox_uid = 0;
+#endif
+#if defined(SYZ_SANDBOX_GID)
+static const int sandbox_gid = SYZ_SANDBOX_GID;
+#else
+static const int sandbox_gid = 0;
+#endif
+
 static int namespace_sandbox_proc(void* arg)
 {
 	sandbox_common();
 
 	// /proc/self/setgroups is not present on some systems, ignore error.
 	write_file("/proc/self/setgroups", "deny");
-	if (!write_file("/proc/self/uid_map", "0 %d 1\n", real_uid))
+	if (!write_file("/proc/self/uid_map", "%d %d 1\n", sandbox_uid, real_uid))
 		fail("write of /proc/self/uid_map failed");
-	if (!write_file("/proc/self/gid_map", "0 %d 1\n", real_gid))
+	if (!write_file("/proc/self/gid_map", "%d %d 1\n", sandbox_gid, real_gid))
 		fail("write of /proc/self/gid_map failed");
 
 	if (mkdir("./syz-tmp", 0777))
@@ -775,6 +1620,14 @@ static int namespace_sandbox_proc(void* arg)
 		fail("mkdir failed");
 	if (mount(NULL, "./syz-tmp/newroot/proc", "proc", 0, NULL))
 		fail("mount(proc) failed");
+#if defined(SYZ_ENABLE_CGROUPS)
+	// Make cgroup hierarchies available to the test program.
+	if (mkdir("./syz-tmp/newroot" SYZ_CGROUP_ROOT, 0700))
+		fail("mkdir failed");
+	if (mount(SYZ_CGROUP_ROOT, "./syz-tmp/newroot" SYZ_CGROUP_ROOT, NULL, MS_BIND | MS_REC | MS_PRIVATE, NULL)) {
+		debug("mount(%s) failed: %d\n", SYZ_CGROUP_ROOT, errno);
+	}
+#endif
 	if (mkdir("./syz-tmp/pivot", 0777))
 		fail("mkdir failed");
 	if (syscall(SYS_pivot_root, "./syz-tmp", "./syz-tmp/pivot")) {
@@ -819,6 +1672,11 @@ static int do_sandbox_namespace(int executor_pid, bool enable_tun)
 	// because IFF_NAPI_FRAGS requires root.
 	setup_tun(executor_pid, enable_tun);
 #endif
+#if defined(SYZ_ENABLE_CGROUPS)
+	// Cgroups are set up before entering the user namespace,
+	// since mounting cgroup hierarchies requires real root.
+	setup_cgroups(executor_pid);
+#endif
 
 	real_uid = getuid();
 	real_gid = getgid();
@@ -940,6 +1798,265 @@ static int fault_injected(int fail_fd)
 }
 #endif
 
+#if defined(SYZ_AFFINITY)
+// Some races trigger only with a particular placement of threads on CPUs.
+// C programs bind procs and threads round-robin to the CPUs listed in SYZ_CPUS
+// (or to all online CPUs if the list is not specified).
+#if defined(SYZ_CPUS)
+static int affinity_cpus[] = {SYZ_CPUS};
+#endif
+static int affinity_base;
+
+static void bind_to_cpu(int idx)
+{
+#if defined(SYZ_CPUS)
+	int ncpus = sizeof(affinity_cpus) / sizeof(affinity_cpus[0]);
+#else
+	int ncpus = sysconf(_SC_NPROCESSORS_ONLN);
+	if (ncpus <= 0)
+		return;
+#endif
+	idx = (affinity_base + idx) % ncpus;
+	cpu_set_t set;
+	CPU_ZERO(&set);
+#if defined(SYZ_CPUS)
+	CPU_SET(affinity_cpus[idx], &set);
+#else
+	CPU_SET(idx, &set);
+#endif
+	// Binds only the calling thread. Failures (e.g. offline CPU) are ignored,
+	// the program should still run.
+	sched_setaffinity(0, sizeof(set), &set);
+}
+#endif
+
+#if defined(SYZ_WATCHDOG)
+// The watchdog allows scripts around C programs to distinguish kernel crashes and hangs
+// from runs where nothing happened. The main process forks the test process and monitors
+// the kernel log, it exits with SYZ_WATCHDOG_CRASH_STATUS on oopses and warnings,
+// with SYZ_WATCHDOG_HANG_STATUS on lockups and stalls and with the test process
+// exit status if the test process exits and nothing is detected.
+// Hangs are checked first, because lockups are reported as "BUG: soft lockup".
+static const char* watchdog_hangs[] = {
+    "soft lockup",
+    "hard LOCKUP",
+    "blocked for more than",
+    "detected stall",
+};
+
+static const char* watchdog_crashes[] = {
+    "BUG:",
+    "WARNING:",
+    "kernel BUG at",
+    "general protection fault",
+    "Kernel panic",
+    "Oops:",
+    "UBSAN:",
+};
+
+static int watchdog_check(const char* msg)
+{
+	unsigned i;
+
+	for (i = 0; i < sizeof(watchdog_hangs) / sizeof(watchdog_hangs[0]); i++) {
+		if (strstr(msg, watchdog_hangs[i]))
+			return SYZ_WATCHDOG_HANG_STATUS;
+	}
+	for (i = 0; i < sizeof(watchdog_crashes) / sizeof(watchdog_crashes[0]); i++) {
+		if (strstr(msg, watchdog_crashes[i]))
+			return SYZ_WATCHDOG_CRASH_STATUS;
+	}
+	return 0;
+}
+
+// watchdog_start returns in the forked test process, the calling process
+// becomes the monitor and never returns.
+// The watchdog is best-effort: if the kernel log is not readable, the program still runs.
+static void watchdog_start()
+{
+	char buf[8 << 10];
+	int fd, pid, n, status, exited;
+
+	fd = open("/dev/kmsg", O_RDONLY | O_NONBLOCK);
+	if (fd == -1) {
+		fprintf(stderr, "failed to open /dev/kmsg: %s\n", strerror(errno));
+		return;
+	}
+	// Skip messages that were printed before the program started.
+	lseek(fd, 0, SEEK_END);
+	pid = fork();
+	if (pid < 0) {
+		fprintf(stderr, "failed to fork watchdog: %s\n", strerror(errno));
+		close(fd);
+		return;
+	}
+	if (pid == 0) {
+		close(fd);
+		prctl(PR_SET_PDEATHSIG, SIGKILL, 0, 0, 0);
+		return;
+	}
+	exited = 0;
+	status = 0;
+	for (;;) {
+		// Each read returns a single log record.
+		n = read(fd, buf, sizeof(buf) - 1);
+		if (n > 0) {
+			buf[n] = 0;
+			int res = watchdog_check(buf);
+			if (res) {
+				kill(pid, SIGKILL);
+				_exit(res);
+			}
+			continue;
+		}
+		if (n < 0 && errno == EPIPE)
+			continue; // Some records were overwritten, proceed with the next ones.
+		// The log is drained after the test process has exited, nothing happened.
+		if (exited)
+			_exit(WIFEXITED(status) ? WEXITSTATUS(status) : 0);
+		if (waitpid(pid, &status, WNOHANG | __WALL) == pid)
+			exited = 1;
+		else
+			usleep(100 * 1000);
+	}
+}
+#endif
+
+#if defined(SYZ_COVERAGE)
+#define KCOV_INIT_TRACE _IOR('c', 1, unsigned long)
+#define KCOV_ENABLE _IO('c', 100)
+#define KCOV_TRACE_PC 0
+#define COVER_SIZE (64 << 10)
+#define COVER_TABLE_SIZE (1 << 18)
+
+// Covered PCs are deduplicated across all threads and processes of the program
+// in a shared hash table and appended to SYZ_COVER_FILE as they are discovered,
+// so the file is complete regardless of how the program exits.
+// Coverage is best-effort: if kcov is not available, the program still runs.
+static int cover_file = -1;
+static unsigned long* cover_table;
+
+// kcov is per-thread, so every thread that executes calls opens its own descriptor.
+static __thread int cover_opened;
+static __thread unsigned long* cover_data;
+
+static void cover_init()
+{
+	void* table;
+	int fd;
+
+	// Check kcov presence once, rather than complaining in every test process.
+	fd = open("/sys/kernel/debug/kcov", O_RDWR);
+	if (fd == -1) {
+		fprintf(stderr, "failed to open /sys/kernel/debug/kcov: %s\n", strerror(errno));
+		return;
+	}
+	close(fd);
+	table = mmap(NULL, COVER_TABLE_SIZE * sizeof(cover_table[0]),
+		     PROT_READ | PROT_WRITE, MAP_SHARED | MAP_ANONYMOUS, -1, 0);
+	if (table == MAP_FAILED) {
+		fprintf(stderr, "failed to mmap coverage table: %s\n", strerror(errno));
+		return;
+	}
+	cover_file = open(SYZ_COVER_FILE, O_WRONLY | O_CREAT | O_TRUNC | O_APPEND, 0644);
+	if (cover_file == -1) {
+		fprintf(stderr, "failed to open %s: %s\n", SYZ_COVER_FILE, strerror(errno));
+		return;
+	}
+	cover_table = (unsigned long*)table;
+}
+
+static void cover_open()
+{
+	int fd;
+	void* data;
+
+	cover_opened = 1;
+	if (cover_file == -1)
+		return;
+	fd = open("/sys/kernel/debug/kcov", O_RDWR);
+	if (fd == -1) {
+		fprintf(stderr, "failed to open /sys/kernel/debug/kcov: %s\n", strerror(errno));
+		return;
+	}
+	if (ioctl(fd, KCOV_INIT_TRACE, COVER_SIZE)) {
+		fprintf(stderr, "kcov init trace failed: %s\n", strerror(errno));
+		close(fd);
+		return;
+	}
+	data = mmap(NULL, COVER_SIZE * sizeof(unsigned long), PROT_READ | PROT_WRITE, MAP_SHARED, fd, 0);
+	if (data == MAP_FAILED) {
+		fprintf(stderr, "kcov mmap failed: %s\n", strerror(errno));
+		close(fd);
+		return;
+	}
+	if (ioctl(fd, KCOV_ENABLE, KCOV_TRACE_PC)) {
+		fprintf(stderr, "kcov enable failed: %s\n", strerror(errno));
+		munmap(data, COVER_SIZE * sizeof(unsigned long));
+		close(fd);
+		return;
+	}
+	cover_data = (unsigned long*)data;
+}
+
+// cover_reset is called before each call, it starts collecting coverage for the current thread.
+static void cover_reset()
+{
+	if (!cover_opened)
+		cover_open();
+	if (cover_data)
+		__atomic_store_n(&cover_data[0], 0, __ATOMIC_RELAXED);
+}
+
+// cover_insert adds pc to the shared table, returns 1 if pc was not seen before.
+static int cover_insert(unsigned long pc)
+{
+	unsigned long hash = (pc ^ (pc >> 15)) * 2654435761u;
+	unsigned long i;
+
+	for (i = 0; i < COVER_TABLE_SIZE; i++) {
+		unsigned long* slot = &cover_table[(hash + i) % COVER_TABLE_SIZE];
+		unsigned long old = 0;
+		if (__atomic_compare_exchange_n(slot, &old, pc, 0, __ATOMIC_RELAXED, __ATOMIC_RELAXED))
+			return 1;
+		if (old == pc)
+			return 0;
+	}
+	// The table is full, drop the PC.
+	return 0;
+}
+
+// cover_collect is called after each call, it writes newly covered PCs to SYZ_COVER_FILE.
+static void cover_collect()
+{
+	int err = errno;
+	char buf[4096];
+	unsigned long n, i;
+	int pos = 0;
+
+	if (!cover_data)
+		return;
+	n = __atomic_load_n(&cover_data[0], __ATOMIC_RELAXED);
+	if (n > COVER_SIZE - 1)
+		n = COVER_SIZE - 1;
+	for (i = 0; i < n; i++) {
+		unsigned long pc = cover_data[i + 1];
+		if (!cover_insert(pc))
+			continue;
+		if (pos > (int)sizeof(buf) - 32) {
+			if (write(cover_file, buf, pos) != pos) {
+			}
+			pos = 0;
+		}
+		pos += sprintf(buf + pos, "0x%lx\n", pc);
+	}
+	if (pos && write(cover_file, buf, pos) != pos) {
+	}
+	// Trace needs errno of the call.
+	errno = err;
+}
+#endif
+
 #if defined(SYZ_REPEAT)
 static void test();
 
@@ -947,7 +2064,21 @@ static void test();
 void loop()
 {
 	int iter;
+#if defined(SYZ_REPEAT_TIMEOUT_SEC)
+	// Stop repeating after the timeout, so that the program exits cleanly
+	// (the current iteration is killed and its temp dir is removed).
+	uint64_t repeat_start = current_time_ms();
+	int repeat_timeout = 0;
+#endif
+#if defined(SYZ_REPEAT_TIMES)
+	for (iter = 0; iter < SYZ_REPEAT_TIMES; iter++) {
+#else
 	for (iter = 0;; iter++) {
+#endif
+#if defined(SYZ_REPEAT_TIMEOUT_SEC)
+		if (repeat_timeout || current_time_ms() - repeat_start > SYZ_REPEAT_TIMEOUT_SEC * 1000ull)
+			break;
+#endif
 #ifdef SYZ_USE_TMP_DIR
 		char cwdbuf[256];
 		sprintf(cwdbuf, "./%d", iter);
@@ -977,7 +2108,13 @@ void loop()
 			if (res == pid)
 				break;
 			usleep(1000);
-			if (current_time_ms() - start > 5 * 1000) {
+#if defined(SYZ_REPEAT_TIMEOUT_SEC)
+			if (current_time_ms() - repeat_start > SYZ_REPEAT_TIMEOUT_SEC * 1000ull)
+				repeat_timeout = 1;
+			if (current_time_ms() - start > SYZ_PROGRAM_TIMEOUT_MS || repeat_timeout) {
+#else
+			if (current_time_ms() - start > SYZ_PROGRAM_TIMEOUT_MS) {
+#endif
 				kill(-pid, SIGKILL);
 				kill(pid, SIGKILL);
 				while (waitpid(-1, &status, __WALL) != pid) {
@@ -993,7 +2130,12 @@ void loop()
 #else
 void loop()
 {
+#if defined(SYZ_REPEAT_TIMES)
+	int iter;
+	for (iter = 0; iter < SYZ_REPEAT_TIMES; iter++) {
+#else
 	while (1) {
+#endif
 		test();
 	}
 }
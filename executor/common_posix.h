@@ -0,0 +1,421 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// This file is shared between executor and csource package.
+// It contains generic implementation for POSIX OSes that don't have a dedicated common header
+// (currently used for freebsd).
+
+#include <sys/syscall.h>
+#include <unistd.h>
+#if defined(SYZ_EXECUTOR) || defined(SYZ_THREADED) || defined(SYZ_COLLIDE)
+#include <pthread.h>
+#include <stdlib.h>
+#endif
+#if defined(SYZ_REPEAT_TIMES)
+#include <sys/wait.h>
+#endif
+#if defined(SYZ_THREADED)
+#include <stdbool.h>
+#endif
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) || defined(SYZ_THREADED)
+#include <errno.h>
+#include <signal.h>
+#include <stdarg.h>
+#include <stdio.h>
+#include <sys/time.h>
+#include <sys/wait.h>
+#include <time.h>
+#endif
+#if defined(SYZ_EXECUTOR) || defined(SYZ_USE_TMP_DIR)
+#include <stdlib.h>
+#include <sys/stat.h>
+#endif
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT) && defined(SYZ_USE_TMP_DIR))
+#include <dirent.h>
+#endif
+#if defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_NAMESPACE) || defined(SYZ_MATCH_EXECUTOR_ENV)
+#include <stdbool.h>
+#include <sys/resource.h>
+#include <sys/wait.h>
+#endif
+#if defined(SYZ_SANDBOX_SETUID)
+#include <grp.h>
+#endif
+
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) ||      \
+    defined(SYZ_USE_TMP_DIR) || defined(SYZ_HANDLE_SEGV) || defined(SYZ_TUN_ENABLE) || \
+    defined(SYZ_SANDBOX_NAMESPACE) || defined(SYZ_SANDBOX_SETUID) ||                   \
+    defined(SYZ_SANDBOX_NONE) || defined(SYZ_FAULT_INJECTION) || defined(SYZ_THREADED) ||     \
+    defined(__NR_syz_kvm_setup_cpu)
+__attribute__((noreturn)) static void doexit(int status)
+{
+	_exit(status);
+	for (;;) {
+	}
+}
+#endif
+
+#include "common.h"
+
+#if defined(SYZ_EXECUTOR) || defined(SYZ_HANDLE_SEGV)
+static __thread int skip_segv;
+static __thread jmp_buf segv_env;
+
+static void segv_handler(int sig, siginfo_t* info, void* uctx)
+{
+	// Generated programs can contain bad (unmapped/protected) addresses,
+	// which cause SIGSEGVs during copyin/copyout.
+	// This handler ignores such crashes to allow the program to proceed.
+	// We additionally opportunistically check that the faulty address
+	// is not within executable data region, because such accesses can corrupt
+	// output region and then fuzzer will fail on corrupted data.
+	uintptr_t addr = (uintptr_t)info->si_addr;
+	const uintptr_t prog_start = 1 << 20;
+	const uintptr_t prog_end = 100 << 20;
+	if (__atomic_load_n(&skip_segv, __ATOMIC_RELAXED) && (addr < prog_start || addr > prog_end)) {
+		debug("SIGSEGV on %p, skipping\n", addr);
+		_longjmp(segv_env, 1);
+	}
+	debug("SIGSEGV on %p, exiting\n", addr);
+	doexit(sig);
+	for (;;) {
+	}
+}
+
+static void install_segv_handler()
+{
+	struct sigaction sa;
+
+	memset(&sa, 0, sizeof(sa));
+	sa.sa_sigaction = segv_handler;
+	sa.sa_flags = SA_NODEFER | SA_SIGINFO;
+	sigaction(SIGSEGV, &sa, NULL);
+	sigaction(SIGBUS, &sa, NULL);
+}
+
+#define NONFAILING(...)                                              \
+	{                                                            \
+		__atomic_fetch_add(&skip_segv, 1, __ATOMIC_SEQ_CST); \
+		if (_setjmp(segv_env) == 0) {                        \
+			__VA_ARGS__;                                 \
+		}                                                    \
+		__atomic_fetch_sub(&skip_segv, 1, __ATOMIC_SEQ_CST); \
+	}
+#endif
+
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) || defined(SYZ_THREADED)
+static uint64_t current_time_ms()
+{
+	struct timespec ts;
+
+	if (clock_gettime(CLOCK_MONOTONIC, &ts))
+		fail("clock_gettime failed");
+	return (uint64_t)ts.tv_sec * 1000 + (uint64_t)ts.tv_nsec / 1000000;
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(SYZ_USE_TMP_DIR)
+static void use_temporary_dir()
+{
+	char tmpdir_template[] = "./syzkaller.XXXXXX";
+	char* tmpdir = mkdtemp(tmpdir_template);
+	if (!tmpdir)
+		fail("failed to mkdtemp");
+	if (chmod(tmpdir, 0777))
+		fail("failed to chmod");
+	if (chdir(tmpdir))
+		fail("failed to chdir");
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(SYZ_THREADED)
+static void sleep_ms(uint64_t ms)
+{
+	usleep(ms * 1000);
+}
+#endif
+
+// Executor has own implementation of events in executor_posix.h,
+// this one is used by C reproducers to synchronize calls between threads the same way.
+#if defined(SYZ_THREADED)
+struct event_t {
+	pthread_mutex_t mu;
+	pthread_cond_t cv;
+	bool state;
+};
+
+static void event_init(struct event_t* ev)
+{
+	if (pthread_mutex_init(&ev->mu, 0))
+		fail("pthread_mutex_init failed");
+	if (pthread_cond_init(&ev->cv, 0))
+		fail("pthread_cond_init failed");
+	ev->state = false;
+}
+
+static void event_reset(struct event_t* ev)
+{
+	ev->state = false;
+}
+
+static void event_set(struct event_t* ev)
+{
+	pthread_mutex_lock(&ev->mu);
+	if (ev->state)
+		fail("event already set");
+	ev->state = true;
+	pthread_mutex_unlock(&ev->mu);
+	pthread_cond_broadcast(&ev->cv);
+}
+
+static void event_wait(struct event_t* ev)
+{
+	pthread_mutex_lock(&ev->mu);
+	while (!ev->state)
+		pthread_cond_wait(&ev->cv, &ev->mu);
+	pthread_mutex_unlock(&ev->mu);
+}
+
+static bool event_isset(struct event_t* ev)
+{
+	pthread_mutex_lock(&ev->mu);
+	bool res = ev->state;
+	pthread_mutex_unlock(&ev->mu);
+	return res;
+}
+
+static bool event_timedwait(struct event_t* ev, uint64_t timeout_ms)
+{
+	pthread_mutex_lock(&ev->mu);
+	uint64_t start = current_time_ms();
+	for (;;) {
+		if (ev->state)
+			break;
+		uint64_t now = current_time_ms();
+		if (now - start > timeout_ms)
+			break;
+		// pthread_cond_timedwait takes absolute CLOCK_REALTIME time.
+		struct timespec ts;
+		clock_gettime(CLOCK_REALTIME, &ts);
+		uint64_t deadline = (uint64_t)ts.tv_nsec + (timeout_ms - (now - start)) * 1000 * 1000;
+		ts.tv_sec += deadline / 1000000000;
+		ts.tv_nsec = deadline % 1000000000;
+		pthread_cond_timedwait(&ev->cv, &ev->mu, &ts);
+	}
+	bool res = ev->state;
+	pthread_mutex_unlock(&ev->mu);
+	return res;
+}
+
+static void thread_start(void* (*fn)(void*), void* arg)
+{
+	pthread_t th;
+	pthread_attr_t attr;
+	pthread_attr_init(&attr);
+	pthread_attr_setstacksize(&attr, 128 << 10);
+	if (pthread_create(&th, &attr, fn, arg))
+		fail("pthread_create failed");
+	pthread_attr_destroy(&attr);
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT) && defined(SYZ_USE_TMP_DIR))
+static void remove_dir(const char* dir)
+{
+	DIR* dp;
+	struct dirent* ep;
+	int iter = 0;
+retry:
+	dp = opendir(dir);
+	if (dp == NULL)
+		return;
+	while ((ep = readdir(dp))) {
+		if (strcmp(ep->d_name, ".") == 0 || strcmp(ep->d_name, "..") == 0)
+			continue;
+		char filename[FILENAME_MAX];
+		snprintf(filename, sizeof(filename), "%s/%s", dir, ep->d_name);
+		struct stat st;
+		if (lstat(filename, &st))
+			return;
+		if (S_ISDIR(st.st_mode)) {
+			remove_dir(filename);
+			continue;
+		}
+		int i;
+		for (i = 0;; i++) {
+			if (unlink(filename) == 0)
+				break;
+			if (errno == EROFS)
+				break;
+			if (errno != EBUSY || i > 100)
+				return;
+		}
+	}
+	closedir(dp);
+	int i;
+	for (i = 0;; i++) {
+		if (rmdir(dir) == 0)
+			break;
+		if (i < 100) {
+			if (errno == EROFS)
+				break;
+			if (errno == ENOTEMPTY) {
+				if (iter < 100) {
+					iter++;
+					goto retry;
+				}
+			}
+		}
+		return;
+	}
+}
+#endif
+
+#if defined(SYZ_EXECUTOR) || defined(SYZ_FAULT_INJECTION)
+static int inject_fault(int nth)
+{
+	return 0;
+}
+
+static int fault_injected(int fail_fd)
+{
+	return 0;
+}
+#endif
+
+// There is no generic tun nor user namespaces support, so sandboxes are implemented with a plain fork (plus setuid for the setuid sandbox)
+// and enable_tun is ignored. The namespace sandbox falls back to the none sandbox.
+#if defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_NAMESPACE) || defined(SYZ_MATCH_EXECUTOR_ENV)
+// Resource limits of test processes, see common_linux.h.
+static void setup_rlimits()
+{
+	struct rlimit rlim;
+	rlim.rlim_cur = rlim.rlim_max = 128 << 20;
+	setrlimit(RLIMIT_AS, &rlim);
+	rlim.rlim_cur = rlim.rlim_max = 8 << 20;
+	setrlimit(RLIMIT_MEMLOCK, &rlim);
+	rlim.rlim_cur = rlim.rlim_max = 1 << 20;
+	setrlimit(RLIMIT_FSIZE, &rlim);
+	rlim.rlim_cur = rlim.rlim_max = 1 << 20;
+	setrlimit(RLIMIT_STACK, &rlim);
+	rlim.rlim_cur = rlim.rlim_max = 256;
+	setrlimit(RLIMIT_NOFILE, &rlim);
+	rlim.rlim_cur = rlim.rlim_max = 0;
+	setrlimit(RLIMIT_CORE, &rlim);
+}
+#endif
+
+#if defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_NAMESPACE)
+static void loop();
+
+static void sandbox_common()
+{
+	setsid();
+	setup_rlimits();
+}
+#endif
+
+#if defined(SYZ_SANDBOX_NONE) || defined(SYZ_SANDBOX_NAMESPACE)
+static int do_sandbox_none(int executor_pid, bool enable_tun)
+{
+	int pid = fork();
+	if (pid)
+		return pid;
+
+	sandbox_common();
+	loop();
+	doexit(1);
+}
+#endif
+
+#if defined(SYZ_SANDBOX_SETUID)
+static int do_sandbox_setuid(int executor_pid, bool enable_tun)
+{
+	int pid = fork();
+	if (pid)
+		return pid;
+
+	sandbox_common();
+
+	const int nobody = 65534;
+	if (setgroups(0, NULL))
+		fail("failed to setgroups");
+	if (setresgid(nobody, nobody, nobody))
+		fail("failed to setresgid");
+	if (setresuid(nobody, nobody, nobody))
+		fail("failed to setresuid");
+
+	loop();
+	doexit(1);
+}
+#endif
+
+#if defined(SYZ_SANDBOX_NAMESPACE)
+static int do_sandbox_namespace(int executor_pid, bool enable_tun)
+{
+	return do_sandbox_none(executor_pid, enable_tun);
+}
+#endif
+
+#if defined(SYZ_REPEAT)
+static void test();
+
+#if defined(SYZ_WAIT_REPEAT)
+void loop()
+{
+	int iter;
+#if defined(SYZ_REPEAT_TIMES)
+	for (iter = 0; iter < SYZ_REPEAT_TIMES; iter++) {
+#else
+	for (iter = 0;; iter++) {
+#endif
+#ifdef SYZ_USE_TMP_DIR
+		char cwdbuf[256];
+		sprintf(cwdbuf, "./%d", iter);
+		if (mkdir(cwdbuf, 0777))
+			fail("failed to mkdir");
+#endif
+		int pid = fork();
+		if (pid < 0)
+			fail("fork failed");
+		if (pid == 0) {
+#ifdef SYZ_USE_TMP_DIR
+			if (chdir(cwdbuf))
+				fail("failed to chdir");
+#endif
+			test();
+			doexit(0);
+		}
+		int status = 0;
+		uint64_t start = current_time_ms();
+		for (;;) {
+			int res = waitpid(-1, &status, WNOHANG);
+			if (res == pid)
+				break;
+			usleep(1000);
+			if (current_time_ms() - start > SYZ_PROGRAM_TIMEOUT_MS) {
+				kill(pid, SIGKILL);
+				while (waitpid(-1, &status, 0) != pid) {
+				}
+				break;
+			}
+		}
+#ifdef SYZ_USE_TMP_DIR
+		remove_dir(cwdbuf);
+#endif
+	}
+}
+#else
+void loop()
+{
+#if defined(SYZ_REPEAT_TIMES)
+	int iter;
+	for (iter = 0; iter < SYZ_REPEAT_TIMES; iter++) {
+#else
+	while (1) {
+#endif
+		test();
+	}
+}
+#endif
+#endif
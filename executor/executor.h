@@ -30,6 +30,10 @@ const int kMaxArgs = 9;
 const int kMaxThreads = 16;
 const int kMaxCommands = 16 << 10;
 
+// Must match prog.ExecMagic/ExecVersion (checked by pkg/execfmt tests).
+const uint64_t kExecMagic = 0x63657865637a7973;
+const uint64_t kExecVersion = 1;
+
 const uint64_t instr_eof = -1;
 const uint64_t instr_copyin = -2;
 const uint64_t instr_copyout = -3;
@@ -43,6 +47,7 @@ enum sandbox_type {
 	sandbox_none,
 	sandbox_setuid,
 	sandbox_namespace,
+	sandbox_seccomp,
 };
 
 bool flag_cover;
@@ -66,6 +71,31 @@ int flag_fault_nth;
 
 int flag_pid;
 
+// Max time to wait for a single call in threaded mode before moving on to the next call,
+// and max time a single program is allowed to run before the test process is killed.
+uint64_t flag_call_timeout_ms;
+uint64_t flag_program_timeout_ms;
+
+// If set, executor checks if calls return kernel pointers to user-space (KASLR leaks).
+// A value is considered a kernel pointer if it falls into one of kernel_ranges.
+bool flag_detect_leaks;
+const int kMaxKernelRanges = 8;
+uint64_t kernel_ranges[kMaxKernelRanges][2];
+int kernel_range_count;
+
+// Memory pointed to by call arguments is checked for leaks only above this address:
+// NONFAILING does not recover from faults in the executor's own memory below it.
+const uint64_t kLeakMinAddr = 100 << 20;
+// Max amount of memory checked for leaks per argument (must not cross a page).
+const uint64_t kLeakDataSize = 512;
+
+// If set, executor measures counts of kernel objects (files, sockets, etc)
+// after each program and returns them in execute_reply for resource leak detection.
+// The order of the counters must match ipc.Resources.
+bool flag_detect_resource_leaks;
+const int kMaxResources = 4;
+uint64_t resources[kMaxResources];
+
 int running;
 uint32_t completed;
 bool collide;
@@ -80,6 +110,7 @@ const uint64_t default_value = -1;
 
 // Checksum kinds.
 const uint64_t arg_csum_inet = 0;
+const uint64_t arg_csum_crc32c = 1;
 
 // Checksum chunk kinds.
 const uint64_t arg_csum_chunk_data = 0;
@@ -106,9 +137,14 @@ struct thread_t {
 	long args[kMaxArgs];
 	long res;
 	uint32_t reserrno;
+	uint64_t duration_us;
 	uint64_t cover_size;
 	bool fault_injected;
 	int cover_fd;
+	uint64_t leak; // kernel pointer leaked by the call, 0 if none
+	// Contents of memory pointed to by arguments before the call.
+	uint64_t leak_data[kMaxArgs][kLeakDataSize / sizeof(uint64_t)];
+	uint64_t leak_size[kMaxArgs];
 };
 
 thread_t threads[kMaxThreads];
@@ -140,6 +176,8 @@ struct execute_req {
 	uint64_t pid;
 	uint64_t fault_call;
 	uint64_t fault_nth;
+	uint64_t call_timeout_ms;
+	uint64_t program_timeout_ms;
 	uint64_t prog_size;
 };
 
@@ -147,6 +185,8 @@ struct execute_reply {
 	uint32_t magic;
 	uint32_t done;
 	uint32_t status;
+	uint32_t pad;
+	uint64_t resources[kMaxResources];
 };
 
 enum {
@@ -167,6 +207,8 @@ struct kcov_comparison_t {
 	// Writes the structure using the write_one function for each field.
 	// Inspired by write_output() function.
 	void write(uint32_t* (*write_one)(uint32_t));
+	// Returns true if the comparison is not useful for hints.
+	bool ignore() const;
 	bool operator==(const struct kcov_comparison_t& other) const;
 	bool operator<(const struct kcov_comparison_t& other) const;
 };
@@ -188,6 +230,8 @@ void cover_open();
 void cover_enable(thread_t* th);
 void cover_reset(thread_t* th);
 uint64_t read_cover_size(thread_t* th);
+void leak_snapshot(thread_t* th);
+uint64_t leak_check(thread_t* th);
 static uint32_t hash(uint32_t a);
 static bool dedup(uint32_t sig);
 
@@ -214,10 +258,14 @@ void parse_env_flags(uint64_t flags)
 		flag_sandbox = sandbox_setuid;
 	else if (flags & (1 << 5))
 		flag_sandbox = sandbox_namespace;
+	else if (flags & (1 << 10))
+		flag_sandbox = sandbox_seccomp;
 	if (!flag_threaded)
 		flag_collide = false;
 	flag_enable_tun = flags & (1 << 6);
 	flag_enable_fault_injection = flags & (1 << 7);
+	flag_detect_leaks = flags & (1 << 11);
+	flag_detect_resource_leaks = flags & (1 << 12);
 }
 
 void receive_handshake()
@@ -257,10 +305,15 @@ void receive_execute(bool need_prog)
 	flag_collect_comps = req.exec_flags & (1 << 3);
 	flag_fault_call = req.fault_call;
 	flag_fault_nth = req.fault_nth;
-	debug("exec opts: pid=%d threaded=%d collide=%d cover=%d comps=%d dedup=%d fault=%d/%d/%d prog=%llu\n",
+	flag_call_timeout_ms = req.call_timeout_ms;
+	flag_program_timeout_ms = req.program_timeout_ms;
+	if (flag_call_timeout_ms == 0 || flag_program_timeout_ms == 0)
+		fail("bad timeouts: call=%llu program=%llu", req.call_timeout_ms, req.program_timeout_ms);
+	debug("exec opts: pid=%d threaded=%d collide=%d cover=%d comps=%d dedup=%d fault=%d/%d/%d"
+	      " timeouts=%llu/%llu prog=%llu\n",
 	      flag_pid, flag_threaded, flag_collide, flag_collect_cover, flag_collect_comps,
 	      flag_dedup_cover, flag_inject_fault, flag_fault_call, flag_fault_nth,
-	      req.prog_size);
+	      req.call_timeout_ms, req.program_timeout_ms, req.prog_size);
 	if (req.prog_size == 0) {
 		if (need_prog)
 			fail("need_prog: no program");
@@ -285,6 +338,8 @@ void reply_execute(int status)
 	reply.magic = kOutMagic;
 	reply.done = true;
 	reply.status = status;
+	if (flag_detect_resource_leaks)
+		memcpy(reply.resources, resources, sizeof(resources));
 	if (write(kOutPipeFd, &reply, sizeof(reply)) != sizeof(reply))
 		fail("control pipe write failed");
 }
@@ -294,6 +349,12 @@ void execute_one()
 {
 retry:
 	uint64_t* input_pos = (uint64_t*)input_data;
+	uint64_t magic = read_input(&input_pos);
+	uint64_t version = read_input(&input_pos);
+	if (magic != kExecMagic)
+		fail("bad exec program magic 0x%llx", magic);
+	if (version != kExecVersion)
+		fail("unsupported exec format version %llu, want %llu", version, kExecVersion);
 	write_output(0); // Number of executed syscalls (updated later).
 
 	if (!collide && !flag_threaded)
@@ -370,6 +431,41 @@ retry:
 					NONFAILING(copyin(csum_addr, csum_value, 2, 0, 0));
 					break;
 				}
+				case arg_csum_crc32c: {
+					if (csum_size != 4) {
+						fail("crc32c checksum must be 4 bytes, not %lu", size);
+					}
+					debug("calculating crc32c checksum for %llx\n", csum_addr);
+					struct csum_crc32c csum;
+					csum_crc32c_init(&csum);
+					uint64_t chunks_num = read_input(&input_pos);
+					uint64_t chunk;
+					for (chunk = 0; chunk < chunks_num; chunk++) {
+						uint64_t chunk_kind = read_input(&input_pos);
+						uint64_t chunk_value = read_input(&input_pos);
+						uint64_t chunk_size = read_input(&input_pos);
+						switch (chunk_kind) {
+						case arg_csum_chunk_data:
+							debug("#%d: data chunk, addr: %llx, size: %llu\n", chunk, chunk_value, chunk_size);
+							NONFAILING(csum_crc32c_update(&csum, (const uint8_t*)chunk_value, chunk_size));
+							break;
+						case arg_csum_chunk_const:
+							if (chunk_size != 2 && chunk_size != 4 && chunk_size != 8) {
+								fail("bad checksum const chunk size %lld\n", chunk_size);
+							}
+							debug("#%d: const chunk, value: %llx, size: %llu\n", chunk, chunk_value, chunk_size);
+							csum_crc32c_update(&csum, (const uint8_t*)&chunk_value, chunk_size);
+							break;
+						default:
+							fail("bad checksum chunk kind %lu", chunk_kind);
+						}
+					}
+					// SCTP stores the checksum little endian, which is what we get on little endian hosts.
+					uint32_t csum_value = csum_crc32c_digest(&csum);
+					debug("writing crc32c checksum %x to %llx\n", csum_value, csum_addr);
+					NONFAILING(copyin(csum_addr, csum_value, 4, 0, 0));
+					break;
+				}
 				default:
 					fail("bad checksum kind %lu", csum_kind);
 				}
@@ -405,9 +501,9 @@ retry:
 			// We already have results from the previous execution.
 		} else if (flag_threaded) {
 			// Wait for call completion.
-			// Note: sys knows about this 20ms timeout when it generates
+			// Note: sys knows about the default 20ms timeout when it generates
 			// timespec/timeval values.
-			const uint64_t timeout_ms = flag_debug ? 500 : 20;
+			const uint64_t timeout_ms = flag_debug ? 500 : flag_call_timeout_ms;
 			if (event_timedwait(&th->done, timeout_ms))
 				handle_completion(th);
 			// Check if any of previous calls have completed.
@@ -470,6 +566,8 @@ thread_t* schedule_call(int n, int call_index, int call_num, uint64_t num_args,
 	th->num_args = num_args;
 	for (int i = 0; i < kMaxArgs; i++)
 		th->args[i] = args[i];
+	if (flag_detect_leaks)
+		leak_snapshot(th);
 	event_set(&th->ready);
 	running++;
 	return th;
@@ -508,11 +606,19 @@ void handle_completion(thread_t* th)
 		}
 	}
 	if (!collide) {
+		th->leak = flag_detect_leaks ? leak_check(th) : 0;
+		if (th->leak)
+			debug("call %d [%s] leaked kernel pointer 0x%llx\n",
+			      th->call_index, syscalls[th->call_num].name, th->leak);
 		write_output(th->call_index);
 		write_output(th->call_num);
 		uint32_t reserrno = th->res != -1 ? 0 : th->reserrno;
 		write_output(reserrno);
 		write_output(th->fault_injected);
+		// Durations over ~71 minutes don't fit into 32 bits, but calls are killed much earlier.
+		write_output(th->duration_us < UINT32_MAX ? (uint32_t)th->duration_us : UINT32_MAX);
+		write_output((uint32_t)th->leak);
+		write_output((uint32_t)(th->leak >> 32));
 		uint32_t* signal_count_pos = write_output(0); // filled in later
 		uint32_t* cover_count_pos = write_output(0); // filled in later
 		uint32_t* comps_count_pos = write_output(0); // filled in later
@@ -525,8 +631,14 @@ void handle_completion(thread_t* th)
 			kcov_comparison_t* end = start + comps_size;
 			std::sort(start, end);
 			comps_size = std::unique(start, end) - start;
-			for (uint32_t i = 0; i < comps_size; ++i)
+			uint32_t filtered = 0;
+			for (uint32_t i = 0; i < comps_size; ++i) {
+				if (start[i].ignore())
+					continue;
 				start[i].write(write_output);
+				filtered++;
+			}
+			comps_size = filtered;
 		} else {
 			// Write out feedback signals.
 			// Currently it is code edges computed as xor of
@@ -562,9 +674,9 @@ void handle_completion(thread_t* th)
 		*comps_count_pos = comps_size;
 		// Write out number of signals
 		*signal_count_pos = nsig;
-		debug("out #%u: index=%u num=%u errno=%d sig=%u cover=%u comps=%u\n",
-		      completed, th->call_index, th->call_num, reserrno, nsig,
-		      cover_size, comps_size);
+		debug("out #%u: index=%u num=%u errno=%d duration=%luus sig=%u cover=%u comps=%u\n",
+		      completed, th->call_index, th->call_num, reserrno, (unsigned long)th->duration_us,
+		      nsig, cover_size, comps_size);
 		completed++;
 		write_completed(completed);
 	}
@@ -618,10 +730,12 @@ void execute_call(thread_t* th)
 
 	cover_reset(th);
 	errno = 0;
+	uint64_t start = current_time_us();
 	th->res = execute_syscall(call, th->args[0], th->args[1], th->args[2],
 				  th->args[3], th->args[4], th->args[5],
 				  th->args[6], th->args[7], th->args[8]);
 	th->reserrno = errno;
+	th->duration_us = current_time_us() - start;
 	th->cover_size = read_cover_size(th);
 	th->fault_injected = false;
 
@@ -637,6 +751,57 @@ void execute_call(thread_t* th)
 	event_set(&th->done);
 }
 
+static bool is_kernel_pointer(uint64_t v)
+{
+	for (int i = 0; i < kernel_range_count; i++) {
+		if (v >= kernel_ranges[i][0] && v < kernel_ranges[i][1])
+			return true;
+	}
+	return false;
+}
+
+// leak_snapshot remembers contents of memory pointed to by the call arguments,
+// so that leak_check reports only values written by the kernel.
+// Both are executed in the main thread, because NONFAILING is not thread-safe.
+void leak_snapshot(thread_t* th)
+{
+	for (int i = 0; i < th->num_args; i++) {
+		th->leak_size[i] = 0;
+		uint64_t addr = (uint64_t)th->args[i] & ~(sizeof(uint64_t) - 1);
+		if (addr < kLeakMinAddr)
+			continue;
+		// Don't cross page boundary, the next page may be not mapped.
+		uint64_t size = (4 << 10) - addr % (4 << 10);
+		if (size > kLeakDataSize)
+			size = kLeakDataSize;
+		NONFAILING(memcpy(th->leak_data[i], (void*)addr, size); th->leak_size[i] = size);
+	}
+}
+
+static uint64_t leak_scan(thread_t* th)
+{
+	for (int i = 0; i < th->num_args; i++) {
+		uint64_t* data = (uint64_t*)((uint64_t)th->args[i] & ~(sizeof(uint64_t) - 1));
+		for (uint64_t j = 0; j < th->leak_size[i] / sizeof(uint64_t); j++) {
+			uint64_t v = data[j];
+			if (v != th->leak_data[i][j] && is_kernel_pointer(v))
+				return v;
+		}
+	}
+	return 0;
+}
+
+// leak_check returns a kernel pointer returned by the call
+// either as the return value or in the memory pointed to by arguments.
+uint64_t leak_check(thread_t* th)
+{
+	if (th->res != -1 && is_kernel_pointer((uint64_t)th->res))
+		return (uint64_t)th->res;
+	uint64_t leak = 0;
+	NONFAILING(leak = leak_scan(th));
+	return leak;
+}
+
 static uint32_t hash(uint32_t a)
 {
 	a = (a ^ 61) ^ (a >> 16);
@@ -799,6 +964,27 @@ void kcov_comparison_t::write(uint32_t* (*write_one)(uint32_t))
 	write_one((uint32_t)(arg2 >> 32));
 }
 
+bool kcov_comparison_t::ignore() const
+{
+	// Comparisons with 0 are not interesting, fuzzer should be able to guess 0's without help.
+	if (arg1 == 0 && (arg2 == 0 || (type & KCOV_CMP_CONST)))
+		return true;
+	if ((type & KCOV_CMP_SIZE_MASK) == KCOV_CMP_SIZE8) {
+#if defined(__linux__)
+		// This can be a pointer (assuming 64-bit kernel).
+		// Filter out comparisons of kernel direct mapping addresses (first 1TB of physical memory),
+		// these are internal kernel pointer comparisons that are useless as argument values.
+		const uint64_t kmem_start = 0xffff880000000000ull;
+		const uint64_t kmem_end = 0xffff890000000000ull;
+		bool kptr1 = arg1 >= kmem_start && arg1 < kmem_end;
+		bool kptr2 = arg2 >= kmem_start && arg2 < kmem_end;
+		if ((kptr1 && kptr2) || (kptr1 && arg2 == 0) || (kptr2 && arg1 == 0))
+			return true;
+#endif
+	}
+	return false;
+}
+
 bool kcov_comparison_t::operator==(const struct kcov_comparison_t& other) const
 {
 	// We don't check for PC equality now, because it is not used.
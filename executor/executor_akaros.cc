@@ -50,7 +50,7 @@ int main(int argc, char** argv)
 				break;
 			sleep_ms(10);
 			uint64_t now = current_time_ms();
-			if (now - start < 3 * 1000)
+			if (now - start < flag_program_timeout_ms)
 				continue;
 			kill(pid, SIGKILL);
 			while (waitpid(pid, &status, 0) != pid) {
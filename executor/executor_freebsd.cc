@@ -4,7 +4,7 @@
 // +build
 
 #define SYZ_EXECUTOR
-#include "common_freebsd.h"
+#include "common_posix.h"
 
 #include "executor_posix.h"
 
@@ -98,7 +98,7 @@ int main(int argc, char** argv)
 				executed_calls = now_executed;
 				last_executed = now;
 			}
-			if ((now - start < 3 * 1000) && (now - last_executed < 500))
+			if ((now - start < flag_program_timeout_ms) && (now - last_executed < 25 * flag_call_timeout_ms))
 				continue;
 			kill(pid, SIGKILL);
 			while (waitpid(pid, &status, 0) != pid) {
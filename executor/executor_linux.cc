@@ -6,8 +6,11 @@
 #include <fcntl.h>
 #include <limits.h>
 #include <linux/futex.h>
+#include <linux/genetlink.h>
+#include <linux/netlink.h>
 #include <sys/ioctl.h>
 #include <sys/prctl.h>
+#include <sys/socket.h>
 #include <sys/stat.h>
 #include <sys/syscall.h>
 #include <sys/time.h>
@@ -40,12 +43,156 @@ const int kPageSize = 4 << 10;
 uint32_t* output_data;
 uint32_t* output_pos;
 
+// parse_seccomp_deny parses comma-separated syscall numbers passed by ipc package
+// in SYZ_SECCOMP_DENY environment variable for the seccomp sandbox.
+static void parse_seccomp_deny()
+{
+	const char* deny = getenv("SYZ_SECCOMP_DENY");
+	if (!deny)
+		return;
+	while (*deny) {
+		if (seccomp_deny_count == sizeof(seccomp_deny) / sizeof(seccomp_deny[0]))
+			fail("too many denied syscalls");
+		char* end = 0;
+		seccomp_deny[seccomp_deny_count++] = strtol(deny, &end, 10);
+		if (end == deny || (*end && *end != ','))
+			fail("bad SYZ_SECCOMP_DENY: %s", getenv("SYZ_SECCOMP_DENY"));
+		deny = *end ? end + 1 : end;
+	}
+}
+
+// parse_kernel_ranges parses kernel address ranges passed by ipc package
+// in SYZ_KERNEL_RANGES environment variable as comma-separated start-end hex pairs.
+static void parse_kernel_ranges()
+{
+	const char* ranges = getenv("SYZ_KERNEL_RANGES");
+	if (!ranges)
+		fail("leak detection is enabled, but SYZ_KERNEL_RANGES is not set");
+	while (*ranges) {
+		if (kernel_range_count == kMaxKernelRanges)
+			fail("too many kernel ranges");
+		char* end = 0;
+		uint64_t* r = kernel_ranges[kernel_range_count++];
+		r[0] = strtoull(ranges, &end, 16);
+		if (end == ranges || *end != '-')
+			fail("bad SYZ_KERNEL_RANGES: %s", getenv("SYZ_KERNEL_RANGES"));
+		ranges = end + 1;
+		r[1] = strtoull(ranges, &end, 16);
+		if (end == ranges || (*end && *end != ',') || r[0] >= r[1])
+			fail("bad SYZ_KERNEL_RANGES: %s", getenv("SYZ_KERNEL_RANGES"));
+		ranges = *end ? end + 1 : end;
+	}
+}
+
+// read_counter returns the number that follows prefix in file, or 0 if there is no such file
+// (e.g. memory cgroup is not mounted).
+static uint64_t read_counter(const char* file, const char* prefix)
+{
+	int fd = open(file, O_RDONLY);
+	if (fd == -1)
+		return 0;
+	char buf[4096];
+	ssize_t n = read(fd, buf, sizeof(buf) - 1);
+	close(fd);
+	if (n <= 0)
+		return 0;
+	buf[n] = 0;
+	char* pos = strstr(buf, prefix);
+	if (!pos)
+		return 0;
+	return strtoull(pos + strlen(prefix), 0, 10);
+}
+
+// count_loop_devices returns number of loop devices bound to a backing file
+// (sysfs loop directory exists only while the device is bound).
+static uint64_t count_loop_devices()
+{
+	DIR* dir = opendir("/sys/block");
+	if (!dir)
+		return 0;
+	uint64_t count = 0;
+	struct dirent* ent;
+	while ((ent = readdir(dir))) {
+		if (strncmp(ent->d_name, "loop", 4))
+			continue;
+		char path[512];
+		snprintf(path, sizeof(path), "/sys/block/%s/loop", ent->d_name);
+		if (access(path, F_OK) == 0)
+			count++;
+	}
+	closedir(dir);
+	return count;
+}
+
+// measure_resources measures global counts of kernel objects after the test process has exited.
+// Namespaces are not measured as kernel does not export the number of live namespaces.
+static void measure_resources()
+{
+	resources[0] = read_counter("/proc/sys/fs/file-nr", "");
+	resources[1] = read_counter("/proc/net/sockstat", "sockets: used ");
+	resources[2] = count_loop_devices();
+	resources[3] = read_counter("/sys/fs/cgroup/memory/memory.usage_in_bytes", "");
+}
+
+// print_netlink_families prints name and ID of every generic netlink family registered
+// in the kernel, one per line. IDs of most families are allocated dynamically,
+// so fuzzer discovers them at startup to generate messages that reach the families.
+static void print_netlink_families()
+{
+	int sock = socket(AF_NETLINK, SOCK_RAW, NETLINK_GENERIC);
+	if (sock == -1)
+		fail("failed to create generic netlink socket");
+	struct {
+		struct nlmsghdr hdr;
+		struct genlmsghdr genl;
+	} req;
+	memset(&req, 0, sizeof(req));
+	req.hdr.nlmsg_len = sizeof(req);
+	req.hdr.nlmsg_type = GENL_ID_CTRL;
+	req.hdr.nlmsg_flags = NLM_F_REQUEST | NLM_F_DUMP;
+	req.genl.cmd = CTRL_CMD_GETFAMILY;
+	req.genl.version = 1;
+	if (send(sock, &req, sizeof(req), 0) != sizeof(req))
+		fail("failed to send generic netlink request");
+	static char buf[64 << 10];
+	for (;;) {
+		int n = recv(sock, buf, sizeof(buf), 0);
+		if (n <= 0)
+			fail("failed to receive generic netlink reply");
+		for (struct nlmsghdr* hdr = (struct nlmsghdr*)buf; NLMSG_OK(hdr, (unsigned)n); hdr = NLMSG_NEXT(hdr, n)) {
+			if (hdr->nlmsg_type == NLMSG_DONE) {
+				close(sock);
+				return;
+			}
+			if (hdr->nlmsg_type == NLMSG_ERROR)
+				fail("generic netlink request failed");
+			struct nlattr* attr = (struct nlattr*)((char*)NLMSG_DATA(hdr) + GENL_HDRLEN);
+			int len = hdr->nlmsg_len - NLMSG_LENGTH(GENL_HDRLEN);
+			const char* name = 0;
+			int id = -1;
+			for (; len >= (int)sizeof(*attr) && attr->nla_len >= sizeof(*attr) && attr->nla_len <= len;
+			     len -= NLA_ALIGN(attr->nla_len), attr = (struct nlattr*)((char*)attr + NLA_ALIGN(attr->nla_len))) {
+				if (attr->nla_type == CTRL_ATTR_FAMILY_NAME)
+					name = (const char*)attr + NLA_HDRLEN;
+				else if (attr->nla_type == CTRL_ATTR_FAMILY_ID)
+					id = *(uint16_t*)((char*)attr + NLA_HDRLEN);
+			}
+			if (name && id != -1)
+				printf("%s %d\n", name, id);
+		}
+	}
+}
+
 int main(int argc, char** argv)
 {
 	if (argc == 2 && strcmp(argv[1], "version") == 0) {
 		puts(GOOS " " GOARCH " " SYZ_REVISION " " GIT_REVISION);
 		return 0;
 	}
+	if (argc == 2 && strcmp(argv[1], "netlink") == 0) {
+		print_netlink_families();
+		return 0;
+	}
 
 	prctl(PR_SET_PDEATHSIG, SIGKILL, 0, 0, 0);
 	if (mmap(&input_data[0], kMaxInput, PROT_READ, MAP_PRIVATE | MAP_FIXED, kInFd, 0) != &input_data[0])
@@ -66,21 +213,13 @@ int main(int argc, char** argv)
 	close(kOutFd);
 	setup_control_pipes();
 	receive_handshake();
+	if (flag_detect_leaks)
+		parse_kernel_ranges();
 
 	cover_open();
 	install_segv_handler();
 	use_temporary_dir();
 
-#if defined(__i386__) || defined(__arm__)
-	// mmap syscall on i386/arm is translated to old_mmap and has different signature.
-	// As a workaround fix it up to mmap2, which has signature that we expect.
-	// pkg/csource has the same hack.
-	for (size_t i = 0; i < sizeof(syscalls) / sizeof(syscalls[0]); i++) {
-		if (syscalls[i].sys_nr == __NR_mmap)
-			syscalls[i].sys_nr = __NR_mmap2;
-	}
-#endif
-
 	int pid = -1;
 	switch (flag_sandbox) {
 	case sandbox_none:
@@ -92,6 +231,10 @@ int main(int argc, char** argv)
 	case sandbox_namespace:
 		pid = do_sandbox_namespace(flag_pid, flag_enable_tun);
 		break;
+	case sandbox_seccomp:
+		parse_seccomp_deny();
+		pid = do_sandbox_seccomp(flag_pid, flag_enable_tun);
+		break;
 	default:
 		fail("unknown sandbox type");
 	}
@@ -179,7 +322,7 @@ void loop()
 			}
 			usleep(1000);
 			// Even though the test process executes exit at the end
-			// and execution time of each syscall is bounded by 20ms,
+			// and execution time of each syscall is bounded by flag_call_timeout_ms,
 			// this backup watchdog is necessary and its performance is important.
 			// The problem is that exit in the test processes can fail (sic).
 			// One observed scenario is that the test processes prohibits
@@ -187,14 +330,14 @@ void loop()
 			// is that the test processes setups a userfaultfd for itself,
 			// then the main thread hangs when it wants to page in a page.
 			// Below we check if the test process still executes syscalls
-			// and kill it after 200ms of inactivity.
+			// and kill it after 25 call timeouts (500ms by default) of inactivity.
 			uint64_t now = current_time_ms();
 			uint32_t now_executed = __atomic_load_n(output_data, __ATOMIC_RELAXED);
 			if (executed_calls != now_executed) {
 				executed_calls = now_executed;
 				last_executed = now;
 			}
-			if ((now - start < 3 * 1000) && (now - last_executed < 500))
+			if ((now - start < flag_program_timeout_ms) && (now - last_executed < 25 * flag_call_timeout_ms))
 				continue;
 			debug("waitpid(%d)=%d (%d)\n", pid, res, errno0);
 			debug("killing\n");
@@ -214,6 +357,8 @@ void loop()
 		if (status == kErrorStatus)
 			error("child errored");
 		remove_dir(cwdbuf);
+		if (flag_detect_resource_leaks)
+			measure_resources();
 		reply_execute(0);
 	}
 }
@@ -5,6 +5,15 @@
 
 typedef pthread_t osthread_t;
 
+// current_time_us returns monotonic time used to measure duration of individual calls.
+uint64_t current_time_us()
+{
+	timespec ts;
+	if (clock_gettime(CLOCK_MONOTONIC, &ts))
+		fail("clock_gettime failed");
+	return (uint64_t)ts.tv_sec * 1000000 + (uint64_t)ts.tv_nsec / 1000;
+}
+
 void thread_start(osthread_t* t, void* (*fn)(void*), void* arg)
 {
 	pthread_attr_t attr;
@@ -5,6 +5,15 @@
 
 typedef HANDLE osthread_t;
 
+// current_time_us returns monotonic time used to measure duration of individual calls.
+uint64_t current_time_us()
+{
+	LARGE_INTEGER freq, now;
+	QueryPerformanceFrequency(&freq);
+	QueryPerformanceCounter(&now);
+	return (uint64_t)now.QuadPart * 1000000 / (uint64_t)freq.QuadPart;
+}
+
 void thread_start(osthread_t* t, void* (*fn)(void*), void* arg)
 {
 	*t = CreateThread(NULL, 128 << 10, (LPTHREAD_START_ROUTINE)fn, arg, 0, NULL);
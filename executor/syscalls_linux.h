@@ -2,17 +2,25 @@
 
 #if defined(__i386__) || 0
 #define GOARCH "386"
-#define SYZ_REVISION "80be6dcc6a0cde46af8d6dbc06d19c49161c7d81"
-#define __NR_syz_emit_ethernet 1000000
-#define __NR_syz_extract_tcp_res 1000001
-#define __NR_syz_fuse_mount 1000002
-#define __NR_syz_fuseblk_mount 1000003
-#define __NR_syz_kvm_setup_cpu 1000004
-#define __NR_syz_open_dev 1000005
-#define __NR_syz_open_pts 1000006
-#define __NR_syz_test 1000007
+#define SYZ_REVISION "8e3f768243b3a13da85427675068796896225d00"
+#define __NR_syz_ashmem_create 1000000
+#define __NR_syz_binder_open 1000001
+#define __NR_syz_binder_read 1000002
+#define __NR_syz_binder_transaction 1000003
+#define __NR_syz_emit_ethernet 1000004
+#define __NR_syz_extract_tcp_res 1000005
+#define __NR_syz_fuse_mount 1000006
+#define __NR_syz_fuseblk_mount 1000007
+#define __NR_syz_kvm_setup_cpu 1000008
+#define __NR_syz_mount_image 1000009
+#define __NR_syz_open_dev 1000010
+#define __NR_syz_open_pts 1000011
+#define __NR_syz_test 1000012
+#define __NR_syz_usb_connect 1000013
+#define __NR_syz_usb_control_io 1000014
+#define __NR_syz_usb_disconnect 1000015
 
-unsigned syscall_count = 1481;
+unsigned syscall_count = 1490;
 call_t syscalls[] = {
     {"accept4", 364},
     {"accept4$ax25", 364},
@@ -899,7 +907,7 @@ call_t syscalls[] = {
     {"mlock", 150},
     {"mlock2", 376},
     {"mlockall", 152},
-    {"mmap", 90},
+    {"mmap", 192},
     {"modify_ldt$read", 123},
     {"modify_ldt$read_default", 123},
     {"modify_ldt$write", 123},
@@ -1347,109 +1355,118 @@ call_t syscalls[] = {
     {"sysfs$3", 135},
     {"sysinfo", 116},
     {"syslog", 103},
-    {"syz_emit_ethernet", 1000000, (syscall_t)syz_emit_ethernet},
-    {"syz_extract_tcp_res", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_extract_tcp_res$synack", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_fuse_mount", 1000002, (syscall_t)syz_fuse_mount},
-    {"syz_fuseblk_mount", 1000003, (syscall_t)syz_fuseblk_mount},
-    {"syz_kvm_setup_cpu$arm64", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_kvm_setup_cpu$x86", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_open_dev$admmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$adsp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$amidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$audion", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dmmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dri", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dricontrol", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$drirender", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dspn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$evdev", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$floppy", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$ircomm", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$loop", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mice", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$midi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mouse", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$random", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sg", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndctrl", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndhw", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmc", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndseq", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndtimer", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tlk_device", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tun", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$urandom", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usb", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usbmon", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsa", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_pts", 1000006, (syscall_t)syz_open_pts},
-    {"syz_test", 1000007, (syscall_t)syz_test},
-    {"syz_test$align0", 1000007, (syscall_t)syz_test},
-    {"syz_test$align1", 1000007, (syscall_t)syz_test},
-    {"syz_test$align2", 1000007, (syscall_t)syz_test},
-    {"syz_test$align3", 1000007, (syscall_t)syz_test},
-    {"syz_test$align4", 1000007, (syscall_t)syz_test},
-    {"syz_test$align5", 1000007, (syscall_t)syz_test},
-    {"syz_test$align6", 1000007, (syscall_t)syz_test},
-    {"syz_test$array0", 1000007, (syscall_t)syz_test},
-    {"syz_test$array1", 1000007, (syscall_t)syz_test},
-    {"syz_test$array2", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf0", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf1", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_encode", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_icmp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$end0", 1000007, (syscall_t)syz_test},
-    {"syz_test$end1", 1000007, (syscall_t)syz_test},
-    {"syz_test$int", 1000007, (syscall_t)syz_test},
-    {"syz_test$length0", 1000007, (syscall_t)syz_test},
-    {"syz_test$length1", 1000007, (syscall_t)syz_test},
-    {"syz_test$length10", 1000007, (syscall_t)syz_test},
-    {"syz_test$length11", 1000007, (syscall_t)syz_test},
-    {"syz_test$length12", 1000007, (syscall_t)syz_test},
-    {"syz_test$length13", 1000007, (syscall_t)syz_test},
-    {"syz_test$length14", 1000007, (syscall_t)syz_test},
-    {"syz_test$length15", 1000007, (syscall_t)syz_test},
-    {"syz_test$length16", 1000007, (syscall_t)syz_test},
-    {"syz_test$length17", 1000007, (syscall_t)syz_test},
-    {"syz_test$length18", 1000007, (syscall_t)syz_test},
-    {"syz_test$length19", 1000007, (syscall_t)syz_test},
-    {"syz_test$length2", 1000007, (syscall_t)syz_test},
-    {"syz_test$length20", 1000007, (syscall_t)syz_test},
-    {"syz_test$length3", 1000007, (syscall_t)syz_test},
-    {"syz_test$length4", 1000007, (syscall_t)syz_test},
-    {"syz_test$length5", 1000007, (syscall_t)syz_test},
-    {"syz_test$length6", 1000007, (syscall_t)syz_test},
-    {"syz_test$length7", 1000007, (syscall_t)syz_test},
-    {"syz_test$length8", 1000007, (syscall_t)syz_test},
-    {"syz_test$length9", 1000007, (syscall_t)syz_test},
-    {"syz_test$missing_resource", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt0", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt1", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt2", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur0", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur1", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur2", 1000007, (syscall_t)syz_test},
-    {"syz_test$regression0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res1", 1000007, (syscall_t)syz_test},
-    {"syz_test$struct", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_16", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_32", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_64", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_real", 1000007, (syscall_t)syz_test},
-    {"syz_test$union0", 1000007, (syscall_t)syz_test},
-    {"syz_test$union1", 1000007, (syscall_t)syz_test},
-    {"syz_test$union2", 1000007, (syscall_t)syz_test},
-    {"syz_test$vma0", 1000007, (syscall_t)syz_test},
+    {"syz_ashmem_create", 1000000, (syscall_t)syz_ashmem_create},
+    {"syz_binder_open", 1000001, (syscall_t)syz_binder_open},
+    {"syz_binder_read", 1000002, (syscall_t)syz_binder_read},
+    {"syz_binder_transaction", 1000003, (syscall_t)syz_binder_transaction},
+    {"syz_emit_ethernet", 1000004, (syscall_t)syz_emit_ethernet},
+    {"syz_extract_tcp_res", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_extract_tcp_res$synack", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_fuse_mount", 1000006, (syscall_t)syz_fuse_mount},
+    {"syz_fuseblk_mount", 1000007, (syscall_t)syz_fuseblk_mount},
+    {"syz_kvm_setup_cpu$arm64", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_kvm_setup_cpu$x86", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_mount_image", 1000009, (syscall_t)syz_mount_image},
+    {"syz_open_dev$admmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$adsp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$amidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$audion", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dmmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dri", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dricontrol", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$drirender", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dspn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$evdev", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$floppy", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$ircomm", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$loop", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mice", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$midi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mouse", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$random", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sg", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndctrl", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndhw", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmc", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndseq", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndtimer", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tlk_device", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tun", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$urandom", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usb", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usbmon", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsa", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_pts", 1000011, (syscall_t)syz_open_pts},
+    {"syz_test", 1000012, (syscall_t)syz_test},
+    {"syz_test$align0", 1000012, (syscall_t)syz_test},
+    {"syz_test$align1", 1000012, (syscall_t)syz_test},
+    {"syz_test$align2", 1000012, (syscall_t)syz_test},
+    {"syz_test$align3", 1000012, (syscall_t)syz_test},
+    {"syz_test$align4", 1000012, (syscall_t)syz_test},
+    {"syz_test$align5", 1000012, (syscall_t)syz_test},
+    {"syz_test$align6", 1000012, (syscall_t)syz_test},
+    {"syz_test$array0", 1000012, (syscall_t)syz_test},
+    {"syz_test$array1", 1000012, (syscall_t)syz_test},
+    {"syz_test$array2", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf0", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf1", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_encode", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_icmp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_sctp", 1000012, (syscall_t)syz_test},
+    {"syz_test$end0", 1000012, (syscall_t)syz_test},
+    {"syz_test$end1", 1000012, (syscall_t)syz_test},
+    {"syz_test$int", 1000012, (syscall_t)syz_test},
+    {"syz_test$length0", 1000012, (syscall_t)syz_test},
+    {"syz_test$length1", 1000012, (syscall_t)syz_test},
+    {"syz_test$length10", 1000012, (syscall_t)syz_test},
+    {"syz_test$length11", 1000012, (syscall_t)syz_test},
+    {"syz_test$length12", 1000012, (syscall_t)syz_test},
+    {"syz_test$length13", 1000012, (syscall_t)syz_test},
+    {"syz_test$length14", 1000012, (syscall_t)syz_test},
+    {"syz_test$length15", 1000012, (syscall_t)syz_test},
+    {"syz_test$length16", 1000012, (syscall_t)syz_test},
+    {"syz_test$length17", 1000012, (syscall_t)syz_test},
+    {"syz_test$length18", 1000012, (syscall_t)syz_test},
+    {"syz_test$length19", 1000012, (syscall_t)syz_test},
+    {"syz_test$length2", 1000012, (syscall_t)syz_test},
+    {"syz_test$length20", 1000012, (syscall_t)syz_test},
+    {"syz_test$length3", 1000012, (syscall_t)syz_test},
+    {"syz_test$length4", 1000012, (syscall_t)syz_test},
+    {"syz_test$length5", 1000012, (syscall_t)syz_test},
+    {"syz_test$length6", 1000012, (syscall_t)syz_test},
+    {"syz_test$length7", 1000012, (syscall_t)syz_test},
+    {"syz_test$length8", 1000012, (syscall_t)syz_test},
+    {"syz_test$length9", 1000012, (syscall_t)syz_test},
+    {"syz_test$missing_resource", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt0", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt1", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt2", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur0", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur1", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur2", 1000012, (syscall_t)syz_test},
+    {"syz_test$regression0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res1", 1000012, (syscall_t)syz_test},
+    {"syz_test$struct", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_16", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_32", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_64", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_real", 1000012, (syscall_t)syz_test},
+    {"syz_test$union0", 1000012, (syscall_t)syz_test},
+    {"syz_test$union1", 1000012, (syscall_t)syz_test},
+    {"syz_test$union2", 1000012, (syscall_t)syz_test},
+    {"syz_test$vma0", 1000012, (syscall_t)syz_test},
+    {"syz_usb_connect", 1000013, (syscall_t)syz_usb_connect},
+    {"syz_usb_control_io", 1000014, (syscall_t)syz_usb_control_io},
+    {"syz_usb_disconnect", 1000015, (syscall_t)syz_usb_disconnect},
     {"tee", 315},
     {"tgkill", 270},
     {"time", 13},
@@ -1501,17 +1518,25 @@ call_t syscalls[] = {
 
 #if defined(__x86_64__) || 0
 #define GOARCH "amd64"
-#define SYZ_REVISION "c3f6d5fad0e7c9a78ec0ee093aee06f4c84f9548"
-#define __NR_syz_emit_ethernet 1000000
-#define __NR_syz_extract_tcp_res 1000001
-#define __NR_syz_fuse_mount 1000002
-#define __NR_syz_fuseblk_mount 1000003
-#define __NR_syz_kvm_setup_cpu 1000004
-#define __NR_syz_open_dev 1000005
-#define __NR_syz_open_pts 1000006
-#define __NR_syz_test 1000007
+#define SYZ_REVISION "e70dc6ed66ee284d8fb506df9408935c920cdf89"
+#define __NR_syz_ashmem_create 1000000
+#define __NR_syz_binder_open 1000001
+#define __NR_syz_binder_read 1000002
+#define __NR_syz_binder_transaction 1000003
+#define __NR_syz_emit_ethernet 1000004
+#define __NR_syz_extract_tcp_res 1000005
+#define __NR_syz_fuse_mount 1000006
+#define __NR_syz_fuseblk_mount 1000007
+#define __NR_syz_kvm_setup_cpu 1000008
+#define __NR_syz_mount_image 1000009
+#define __NR_syz_open_dev 1000010
+#define __NR_syz_open_pts 1000011
+#define __NR_syz_test 1000012
+#define __NR_syz_usb_connect 1000013
+#define __NR_syz_usb_control_io 1000014
+#define __NR_syz_usb_disconnect 1000015
 
-unsigned syscall_count = 1542;
+unsigned syscall_count = 1551;
 call_t syscalls[] = {
     {"accept", 43},
     {"accept$alg", 43},
@@ -2907,109 +2932,118 @@ call_t syscalls[] = {
     {"sysfs$3", 139},
     {"sysinfo", 99},
     {"syslog", 103},
-    {"syz_emit_ethernet", 1000000, (syscall_t)syz_emit_ethernet},
-    {"syz_extract_tcp_res", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_extract_tcp_res$synack", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_fuse_mount", 1000002, (syscall_t)syz_fuse_mount},
-    {"syz_fuseblk_mount", 1000003, (syscall_t)syz_fuseblk_mount},
-    {"syz_kvm_setup_cpu$arm64", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_kvm_setup_cpu$x86", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_open_dev$admmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$adsp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$amidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$audion", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dmmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dri", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dricontrol", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$drirender", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dspn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$evdev", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$floppy", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$ircomm", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$loop", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mice", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$midi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mouse", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$random", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sg", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndctrl", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndhw", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmc", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndseq", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndtimer", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tlk_device", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tun", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$urandom", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usb", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usbmon", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsa", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_pts", 1000006, (syscall_t)syz_open_pts},
-    {"syz_test", 1000007, (syscall_t)syz_test},
-    {"syz_test$align0", 1000007, (syscall_t)syz_test},
-    {"syz_test$align1", 1000007, (syscall_t)syz_test},
-    {"syz_test$align2", 1000007, (syscall_t)syz_test},
-    {"syz_test$align3", 1000007, (syscall_t)syz_test},
-    {"syz_test$align4", 1000007, (syscall_t)syz_test},
-    {"syz_test$align5", 1000007, (syscall_t)syz_test},
-    {"syz_test$align6", 1000007, (syscall_t)syz_test},
-    {"syz_test$array0", 1000007, (syscall_t)syz_test},
-    {"syz_test$array1", 1000007, (syscall_t)syz_test},
-    {"syz_test$array2", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf0", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf1", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_encode", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_icmp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$end0", 1000007, (syscall_t)syz_test},
-    {"syz_test$end1", 1000007, (syscall_t)syz_test},
-    {"syz_test$int", 1000007, (syscall_t)syz_test},
-    {"syz_test$length0", 1000007, (syscall_t)syz_test},
-    {"syz_test$length1", 1000007, (syscall_t)syz_test},
-    {"syz_test$length10", 1000007, (syscall_t)syz_test},
-    {"syz_test$length11", 1000007, (syscall_t)syz_test},
-    {"syz_test$length12", 1000007, (syscall_t)syz_test},
-    {"syz_test$length13", 1000007, (syscall_t)syz_test},
-    {"syz_test$length14", 1000007, (syscall_t)syz_test},
-    {"syz_test$length15", 1000007, (syscall_t)syz_test},
-    {"syz_test$length16", 1000007, (syscall_t)syz_test},
-    {"syz_test$length17", 1000007, (syscall_t)syz_test},
-    {"syz_test$length18", 1000007, (syscall_t)syz_test},
-    {"syz_test$length19", 1000007, (syscall_t)syz_test},
-    {"syz_test$length2", 1000007, (syscall_t)syz_test},
-    {"syz_test$length20", 1000007, (syscall_t)syz_test},
-    {"syz_test$length3", 1000007, (syscall_t)syz_test},
-    {"syz_test$length4", 1000007, (syscall_t)syz_test},
-    {"syz_test$length5", 1000007, (syscall_t)syz_test},
-    {"syz_test$length6", 1000007, (syscall_t)syz_test},
-    {"syz_test$length7", 1000007, (syscall_t)syz_test},
-    {"syz_test$length8", 1000007, (syscall_t)syz_test},
-    {"syz_test$length9", 1000007, (syscall_t)syz_test},
-    {"syz_test$missing_resource", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt0", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt1", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt2", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur0", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur1", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur2", 1000007, (syscall_t)syz_test},
-    {"syz_test$regression0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res1", 1000007, (syscall_t)syz_test},
-    {"syz_test$struct", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_16", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_32", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_64", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_real", 1000007, (syscall_t)syz_test},
-    {"syz_test$union0", 1000007, (syscall_t)syz_test},
-    {"syz_test$union1", 1000007, (syscall_t)syz_test},
-    {"syz_test$union2", 1000007, (syscall_t)syz_test},
-    {"syz_test$vma0", 1000007, (syscall_t)syz_test},
+    {"syz_ashmem_create", 1000000, (syscall_t)syz_ashmem_create},
+    {"syz_binder_open", 1000001, (syscall_t)syz_binder_open},
+    {"syz_binder_read", 1000002, (syscall_t)syz_binder_read},
+    {"syz_binder_transaction", 1000003, (syscall_t)syz_binder_transaction},
+    {"syz_emit_ethernet", 1000004, (syscall_t)syz_emit_ethernet},
+    {"syz_extract_tcp_res", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_extract_tcp_res$synack", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_fuse_mount", 1000006, (syscall_t)syz_fuse_mount},
+    {"syz_fuseblk_mount", 1000007, (syscall_t)syz_fuseblk_mount},
+    {"syz_kvm_setup_cpu$arm64", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_kvm_setup_cpu$x86", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_mount_image", 1000009, (syscall_t)syz_mount_image},
+    {"syz_open_dev$admmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$adsp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$amidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$audion", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dmmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dri", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dricontrol", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$drirender", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dspn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$evdev", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$floppy", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$ircomm", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$loop", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mice", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$midi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mouse", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$random", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sg", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndctrl", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndhw", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmc", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndseq", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndtimer", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tlk_device", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tun", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$urandom", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usb", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usbmon", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsa", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_pts", 1000011, (syscall_t)syz_open_pts},
+    {"syz_test", 1000012, (syscall_t)syz_test},
+    {"syz_test$align0", 1000012, (syscall_t)syz_test},
+    {"syz_test$align1", 1000012, (syscall_t)syz_test},
+    {"syz_test$align2", 1000012, (syscall_t)syz_test},
+    {"syz_test$align3", 1000012, (syscall_t)syz_test},
+    {"syz_test$align4", 1000012, (syscall_t)syz_test},
+    {"syz_test$align5", 1000012, (syscall_t)syz_test},
+    {"syz_test$align6", 1000012, (syscall_t)syz_test},
+    {"syz_test$array0", 1000012, (syscall_t)syz_test},
+    {"syz_test$array1", 1000012, (syscall_t)syz_test},
+    {"syz_test$array2", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf0", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf1", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_encode", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_icmp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_sctp", 1000012, (syscall_t)syz_test},
+    {"syz_test$end0", 1000012, (syscall_t)syz_test},
+    {"syz_test$end1", 1000012, (syscall_t)syz_test},
+    {"syz_test$int", 1000012, (syscall_t)syz_test},
+    {"syz_test$length0", 1000012, (syscall_t)syz_test},
+    {"syz_test$length1", 1000012, (syscall_t)syz_test},
+    {"syz_test$length10", 1000012, (syscall_t)syz_test},
+    {"syz_test$length11", 1000012, (syscall_t)syz_test},
+    {"syz_test$length12", 1000012, (syscall_t)syz_test},
+    {"syz_test$length13", 1000012, (syscall_t)syz_test},
+    {"syz_test$length14", 1000012, (syscall_t)syz_test},
+    {"syz_test$length15", 1000012, (syscall_t)syz_test},
+    {"syz_test$length16", 1000012, (syscall_t)syz_test},
+    {"syz_test$length17", 1000012, (syscall_t)syz_test},
+    {"syz_test$length18", 1000012, (syscall_t)syz_test},
+    {"syz_test$length19", 1000012, (syscall_t)syz_test},
+    {"syz_test$length2", 1000012, (syscall_t)syz_test},
+    {"syz_test$length20", 1000012, (syscall_t)syz_test},
+    {"syz_test$length3", 1000012, (syscall_t)syz_test},
+    {"syz_test$length4", 1000012, (syscall_t)syz_test},
+    {"syz_test$length5", 1000012, (syscall_t)syz_test},
+    {"syz_test$length6", 1000012, (syscall_t)syz_test},
+    {"syz_test$length7", 1000012, (syscall_t)syz_test},
+    {"syz_test$length8", 1000012, (syscall_t)syz_test},
+    {"syz_test$length9", 1000012, (syscall_t)syz_test},
+    {"syz_test$missing_resource", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt0", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt1", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt2", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur0", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur1", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur2", 1000012, (syscall_t)syz_test},
+    {"syz_test$regression0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res1", 1000012, (syscall_t)syz_test},
+    {"syz_test$struct", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_16", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_32", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_64", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_real", 1000012, (syscall_t)syz_test},
+    {"syz_test$union0", 1000012, (syscall_t)syz_test},
+    {"syz_test$union1", 1000012, (syscall_t)syz_test},
+    {"syz_test$union2", 1000012, (syscall_t)syz_test},
+    {"syz_test$vma0", 1000012, (syscall_t)syz_test},
+    {"syz_usb_connect", 1000013, (syscall_t)syz_usb_connect},
+    {"syz_usb_control_io", 1000014, (syscall_t)syz_usb_control_io},
+    {"syz_usb_disconnect", 1000015, (syscall_t)syz_usb_disconnect},
     {"tee", 276},
     {"tgkill", 234},
     {"time", 201},
@@ -3061,17 +3095,25 @@ call_t syscalls[] = {
 
 #if defined(__arm__) || 0
 #define GOARCH "arm"
-#define SYZ_REVISION "3179054d4b602e1435c9f8e963c0e39788296983"
-#define __NR_syz_emit_ethernet 1000000
-#define __NR_syz_extract_tcp_res 1000001
-#define __NR_syz_fuse_mount 1000002
-#define __NR_syz_fuseblk_mount 1000003
-#define __NR_syz_kvm_setup_cpu 1000004
-#define __NR_syz_open_dev 1000005
-#define __NR_syz_open_pts 1000006
-#define __NR_syz_test 1000007
+#define SYZ_REVISION "beb285df313e2d4ffee0753868ea566378f464e6"
+#define __NR_syz_ashmem_create 1000000
+#define __NR_syz_binder_open 1000001
+#define __NR_syz_binder_read 1000002
+#define __NR_syz_binder_transaction 1000003
+#define __NR_syz_emit_ethernet 1000004
+#define __NR_syz_extract_tcp_res 1000005
+#define __NR_syz_fuse_mount 1000006
+#define __NR_syz_fuseblk_mount 1000007
+#define __NR_syz_kvm_setup_cpu 1000008
+#define __NR_syz_mount_image 1000009
+#define __NR_syz_open_dev 1000010
+#define __NR_syz_open_pts 1000011
+#define __NR_syz_test 1000012
+#define __NR_syz_usb_connect 1000013
+#define __NR_syz_usb_control_io 1000014
+#define __NR_syz_usb_disconnect 1000015
 
-unsigned syscall_count = 1495;
+unsigned syscall_count = 1504;
 call_t syscalls[] = {
     {"accept", 9437469},
     {"accept$alg", 9437469},
@@ -3939,7 +3981,7 @@ call_t syscalls[] = {
     {"mlock", 9437334},
     {"mlock2", 9437574},
     {"mlockall", 9437336},
-    {"mmap", 9437274},
+    {"mmap", 9437376},
     {"mount", 9437205},
     {"move_pages", 9437528},
     {"mprotect", 9437309},
@@ -4420,109 +4462,118 @@ call_t syscalls[] = {
     {"sysfs$3", 9437319},
     {"sysinfo", 9437300},
     {"syslog", 9437287},
-    {"syz_emit_ethernet", 1000000, (syscall_t)syz_emit_ethernet},
-    {"syz_extract_tcp_res", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_extract_tcp_res$synack", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_fuse_mount", 1000002, (syscall_t)syz_fuse_mount},
-    {"syz_fuseblk_mount", 1000003, (syscall_t)syz_fuseblk_mount},
-    {"syz_kvm_setup_cpu$arm64", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_kvm_setup_cpu$x86", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_open_dev$admmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$adsp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$amidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$audion", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dmmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dri", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dricontrol", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$drirender", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dspn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$evdev", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$floppy", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$ircomm", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$loop", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mice", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$midi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mouse", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$random", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sg", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndctrl", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndhw", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmc", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndseq", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndtimer", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tlk_device", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tun", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$urandom", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usb", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usbmon", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsa", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_pts", 1000006, (syscall_t)syz_open_pts},
-    {"syz_test", 1000007, (syscall_t)syz_test},
-    {"syz_test$align0", 1000007, (syscall_t)syz_test},
-    {"syz_test$align1", 1000007, (syscall_t)syz_test},
-    {"syz_test$align2", 1000007, (syscall_t)syz_test},
-    {"syz_test$align3", 1000007, (syscall_t)syz_test},
-    {"syz_test$align4", 1000007, (syscall_t)syz_test},
-    {"syz_test$align5", 1000007, (syscall_t)syz_test},
-    {"syz_test$align6", 1000007, (syscall_t)syz_test},
-    {"syz_test$array0", 1000007, (syscall_t)syz_test},
-    {"syz_test$array1", 1000007, (syscall_t)syz_test},
-    {"syz_test$array2", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf0", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf1", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_encode", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_icmp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$end0", 1000007, (syscall_t)syz_test},
-    {"syz_test$end1", 1000007, (syscall_t)syz_test},
-    {"syz_test$int", 1000007, (syscall_t)syz_test},
-    {"syz_test$length0", 1000007, (syscall_t)syz_test},
-    {"syz_test$length1", 1000007, (syscall_t)syz_test},
-    {"syz_test$length10", 1000007, (syscall_t)syz_test},
-    {"syz_test$length11", 1000007, (syscall_t)syz_test},
-    {"syz_test$length12", 1000007, (syscall_t)syz_test},
-    {"syz_test$length13", 1000007, (syscall_t)syz_test},
-    {"syz_test$length14", 1000007, (syscall_t)syz_test},
-    {"syz_test$length15", 1000007, (syscall_t)syz_test},
-    {"syz_test$length16", 1000007, (syscall_t)syz_test},
-    {"syz_test$length17", 1000007, (syscall_t)syz_test},
-    {"syz_test$length18", 1000007, (syscall_t)syz_test},
-    {"syz_test$length19", 1000007, (syscall_t)syz_test},
-    {"syz_test$length2", 1000007, (syscall_t)syz_test},
-    {"syz_test$length20", 1000007, (syscall_t)syz_test},
-    {"syz_test$length3", 1000007, (syscall_t)syz_test},
-    {"syz_test$length4", 1000007, (syscall_t)syz_test},
-    {"syz_test$length5", 1000007, (syscall_t)syz_test},
-    {"syz_test$length6", 1000007, (syscall_t)syz_test},
-    {"syz_test$length7", 1000007, (syscall_t)syz_test},
-    {"syz_test$length8", 1000007, (syscall_t)syz_test},
-    {"syz_test$length9", 1000007, (syscall_t)syz_test},
-    {"syz_test$missing_resource", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt0", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt1", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt2", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur0", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur1", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur2", 1000007, (syscall_t)syz_test},
-    {"syz_test$regression0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res1", 1000007, (syscall_t)syz_test},
-    {"syz_test$struct", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_16", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_32", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_64", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_real", 1000007, (syscall_t)syz_test},
-    {"syz_test$union0", 1000007, (syscall_t)syz_test},
-    {"syz_test$union1", 1000007, (syscall_t)syz_test},
-    {"syz_test$union2", 1000007, (syscall_t)syz_test},
-    {"syz_test$vma0", 1000007, (syscall_t)syz_test},
+    {"syz_ashmem_create", 1000000, (syscall_t)syz_ashmem_create},
+    {"syz_binder_open", 1000001, (syscall_t)syz_binder_open},
+    {"syz_binder_read", 1000002, (syscall_t)syz_binder_read},
+    {"syz_binder_transaction", 1000003, (syscall_t)syz_binder_transaction},
+    {"syz_emit_ethernet", 1000004, (syscall_t)syz_emit_ethernet},
+    {"syz_extract_tcp_res", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_extract_tcp_res$synack", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_fuse_mount", 1000006, (syscall_t)syz_fuse_mount},
+    {"syz_fuseblk_mount", 1000007, (syscall_t)syz_fuseblk_mount},
+    {"syz_kvm_setup_cpu$arm64", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_kvm_setup_cpu$x86", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_mount_image", 1000009, (syscall_t)syz_mount_image},
+    {"syz_open_dev$admmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$adsp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$amidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$audion", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dmmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dri", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dricontrol", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$drirender", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dspn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$evdev", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$floppy", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$ircomm", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$loop", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mice", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$midi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mouse", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$random", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sg", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndctrl", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndhw", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmc", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndseq", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndtimer", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tlk_device", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tun", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$urandom", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usb", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usbmon", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsa", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_pts", 1000011, (syscall_t)syz_open_pts},
+    {"syz_test", 1000012, (syscall_t)syz_test},
+    {"syz_test$align0", 1000012, (syscall_t)syz_test},
+    {"syz_test$align1", 1000012, (syscall_t)syz_test},
+    {"syz_test$align2", 1000012, (syscall_t)syz_test},
+    {"syz_test$align3", 1000012, (syscall_t)syz_test},
+    {"syz_test$align4", 1000012, (syscall_t)syz_test},
+    {"syz_test$align5", 1000012, (syscall_t)syz_test},
+    {"syz_test$align6", 1000012, (syscall_t)syz_test},
+    {"syz_test$array0", 1000012, (syscall_t)syz_test},
+    {"syz_test$array1", 1000012, (syscall_t)syz_test},
+    {"syz_test$array2", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf0", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf1", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_encode", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_icmp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_sctp", 1000012, (syscall_t)syz_test},
+    {"syz_test$end0", 1000012, (syscall_t)syz_test},
+    {"syz_test$end1", 1000012, (syscall_t)syz_test},
+    {"syz_test$int", 1000012, (syscall_t)syz_test},
+    {"syz_test$length0", 1000012, (syscall_t)syz_test},
+    {"syz_test$length1", 1000012, (syscall_t)syz_test},
+    {"syz_test$length10", 1000012, (syscall_t)syz_test},
+    {"syz_test$length11", 1000012, (syscall_t)syz_test},
+    {"syz_test$length12", 1000012, (syscall_t)syz_test},
+    {"syz_test$length13", 1000012, (syscall_t)syz_test},
+    {"syz_test$length14", 1000012, (syscall_t)syz_test},
+    {"syz_test$length15", 1000012, (syscall_t)syz_test},
+    {"syz_test$length16", 1000012, (syscall_t)syz_test},
+    {"syz_test$length17", 1000012, (syscall_t)syz_test},
+    {"syz_test$length18", 1000012, (syscall_t)syz_test},
+    {"syz_test$length19", 1000012, (syscall_t)syz_test},
+    {"syz_test$length2", 1000012, (syscall_t)syz_test},
+    {"syz_test$length20", 1000012, (syscall_t)syz_test},
+    {"syz_test$length3", 1000012, (syscall_t)syz_test},
+    {"syz_test$length4", 1000012, (syscall_t)syz_test},
+    {"syz_test$length5", 1000012, (syscall_t)syz_test},
+    {"syz_test$length6", 1000012, (syscall_t)syz_test},
+    {"syz_test$length7", 1000012, (syscall_t)syz_test},
+    {"syz_test$length8", 1000012, (syscall_t)syz_test},
+    {"syz_test$length9", 1000012, (syscall_t)syz_test},
+    {"syz_test$missing_resource", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt0", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt1", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt2", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur0", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur1", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur2", 1000012, (syscall_t)syz_test},
+    {"syz_test$regression0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res1", 1000012, (syscall_t)syz_test},
+    {"syz_test$struct", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_16", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_32", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_64", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_real", 1000012, (syscall_t)syz_test},
+    {"syz_test$union0", 1000012, (syscall_t)syz_test},
+    {"syz_test$union1", 1000012, (syscall_t)syz_test},
+    {"syz_test$union2", 1000012, (syscall_t)syz_test},
+    {"syz_test$vma0", 1000012, (syscall_t)syz_test},
+    {"syz_usb_connect", 1000013, (syscall_t)syz_usb_connect},
+    {"syz_usb_control_io", 1000014, (syscall_t)syz_usb_control_io},
+    {"syz_usb_disconnect", 1000015, (syscall_t)syz_usb_disconnect},
     {"tee", 9437526},
     {"tgkill", 9437452},
     {"time", 9437197},
@@ -4574,17 +4625,25 @@ call_t syscalls[] = {
 
 #if defined(__aarch64__) || 0
 #define GOARCH "arm64"
-#define SYZ_REVISION "cdbdae9ff361c157b1284fca7534cc44bffe5dc1"
-#define __NR_syz_emit_ethernet 1000000
-#define __NR_syz_extract_tcp_res 1000001
-#define __NR_syz_fuse_mount 1000002
-#define __NR_syz_fuseblk_mount 1000003
-#define __NR_syz_kvm_setup_cpu 1000004
-#define __NR_syz_open_dev 1000005
-#define __NR_syz_open_pts 1000006
-#define __NR_syz_test 1000007
+#define SYZ_REVISION "3adb065599e6eb4a712fd40ef09e950313164ef7"
+#define __NR_syz_ashmem_create 1000000
+#define __NR_syz_binder_open 1000001
+#define __NR_syz_binder_read 1000002
+#define __NR_syz_binder_transaction 1000003
+#define __NR_syz_emit_ethernet 1000004
+#define __NR_syz_extract_tcp_res 1000005
+#define __NR_syz_fuse_mount 1000006
+#define __NR_syz_fuseblk_mount 1000007
+#define __NR_syz_kvm_setup_cpu 1000008
+#define __NR_syz_mount_image 1000009
+#define __NR_syz_open_dev 1000010
+#define __NR_syz_open_pts 1000011
+#define __NR_syz_test 1000012
+#define __NR_syz_usb_connect 1000013
+#define __NR_syz_usb_control_io 1000014
+#define __NR_syz_usb_disconnect 1000015
 
-unsigned syscall_count = 1470;
+unsigned syscall_count = 1479;
 call_t syscalls[] = {
     {"accept", 202},
     {"accept$alg", 202},
@@ -5914,109 +5973,118 @@ call_t syscalls[] = {
     {"syncfs", 267},
     {"sysinfo", 179},
     {"syslog", 116},
-    {"syz_emit_ethernet", 1000000, (syscall_t)syz_emit_ethernet},
-    {"syz_extract_tcp_res", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_extract_tcp_res$synack", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_fuse_mount", 1000002, (syscall_t)syz_fuse_mount},
-    {"syz_fuseblk_mount", 1000003, (syscall_t)syz_fuseblk_mount},
-    {"syz_kvm_setup_cpu$arm64", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_kvm_setup_cpu$x86", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_open_dev$admmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$adsp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$amidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$audion", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dmmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dri", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dricontrol", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$drirender", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dspn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$evdev", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$floppy", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$ircomm", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$loop", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mice", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$midi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mouse", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$random", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sg", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndctrl", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndhw", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmc", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndseq", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndtimer", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tlk_device", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tun", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$urandom", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usb", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usbmon", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsa", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_pts", 1000006, (syscall_t)syz_open_pts},
-    {"syz_test", 1000007, (syscall_t)syz_test},
-    {"syz_test$align0", 1000007, (syscall_t)syz_test},
-    {"syz_test$align1", 1000007, (syscall_t)syz_test},
-    {"syz_test$align2", 1000007, (syscall_t)syz_test},
-    {"syz_test$align3", 1000007, (syscall_t)syz_test},
-    {"syz_test$align4", 1000007, (syscall_t)syz_test},
-    {"syz_test$align5", 1000007, (syscall_t)syz_test},
-    {"syz_test$align6", 1000007, (syscall_t)syz_test},
-    {"syz_test$array0", 1000007, (syscall_t)syz_test},
-    {"syz_test$array1", 1000007, (syscall_t)syz_test},
-    {"syz_test$array2", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf0", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf1", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_encode", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_icmp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$end0", 1000007, (syscall_t)syz_test},
-    {"syz_test$end1", 1000007, (syscall_t)syz_test},
-    {"syz_test$int", 1000007, (syscall_t)syz_test},
-    {"syz_test$length0", 1000007, (syscall_t)syz_test},
-    {"syz_test$length1", 1000007, (syscall_t)syz_test},
-    {"syz_test$length10", 1000007, (syscall_t)syz_test},
-    {"syz_test$length11", 1000007, (syscall_t)syz_test},
-    {"syz_test$length12", 1000007, (syscall_t)syz_test},
-    {"syz_test$length13", 1000007, (syscall_t)syz_test},
-    {"syz_test$length14", 1000007, (syscall_t)syz_test},
-    {"syz_test$length15", 1000007, (syscall_t)syz_test},
-    {"syz_test$length16", 1000007, (syscall_t)syz_test},
-    {"syz_test$length17", 1000007, (syscall_t)syz_test},
-    {"syz_test$length18", 1000007, (syscall_t)syz_test},
-    {"syz_test$length19", 1000007, (syscall_t)syz_test},
-    {"syz_test$length2", 1000007, (syscall_t)syz_test},
-    {"syz_test$length20", 1000007, (syscall_t)syz_test},
-    {"syz_test$length3", 1000007, (syscall_t)syz_test},
-    {"syz_test$length4", 1000007, (syscall_t)syz_test},
-    {"syz_test$length5", 1000007, (syscall_t)syz_test},
-    {"syz_test$length6", 1000007, (syscall_t)syz_test},
-    {"syz_test$length7", 1000007, (syscall_t)syz_test},
-    {"syz_test$length8", 1000007, (syscall_t)syz_test},
-    {"syz_test$length9", 1000007, (syscall_t)syz_test},
-    {"syz_test$missing_resource", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt0", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt1", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt2", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur0", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur1", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur2", 1000007, (syscall_t)syz_test},
-    {"syz_test$regression0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res1", 1000007, (syscall_t)syz_test},
-    {"syz_test$struct", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_16", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_32", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_64", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_real", 1000007, (syscall_t)syz_test},
-    {"syz_test$union0", 1000007, (syscall_t)syz_test},
-    {"syz_test$union1", 1000007, (syscall_t)syz_test},
-    {"syz_test$union2", 1000007, (syscall_t)syz_test},
-    {"syz_test$vma0", 1000007, (syscall_t)syz_test},
+    {"syz_ashmem_create", 1000000, (syscall_t)syz_ashmem_create},
+    {"syz_binder_open", 1000001, (syscall_t)syz_binder_open},
+    {"syz_binder_read", 1000002, (syscall_t)syz_binder_read},
+    {"syz_binder_transaction", 1000003, (syscall_t)syz_binder_transaction},
+    {"syz_emit_ethernet", 1000004, (syscall_t)syz_emit_ethernet},
+    {"syz_extract_tcp_res", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_extract_tcp_res$synack", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_fuse_mount", 1000006, (syscall_t)syz_fuse_mount},
+    {"syz_fuseblk_mount", 1000007, (syscall_t)syz_fuseblk_mount},
+    {"syz_kvm_setup_cpu$arm64", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_kvm_setup_cpu$x86", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_mount_image", 1000009, (syscall_t)syz_mount_image},
+    {"syz_open_dev$admmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$adsp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$amidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$audion", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dmmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dri", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dricontrol", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$drirender", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dspn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$evdev", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$floppy", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$ircomm", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$loop", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mice", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$midi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mouse", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$random", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sg", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndctrl", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndhw", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmc", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndseq", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndtimer", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tlk_device", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tun", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$urandom", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usb", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usbmon", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsa", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_pts", 1000011, (syscall_t)syz_open_pts},
+    {"syz_test", 1000012, (syscall_t)syz_test},
+    {"syz_test$align0", 1000012, (syscall_t)syz_test},
+    {"syz_test$align1", 1000012, (syscall_t)syz_test},
+    {"syz_test$align2", 1000012, (syscall_t)syz_test},
+    {"syz_test$align3", 1000012, (syscall_t)syz_test},
+    {"syz_test$align4", 1000012, (syscall_t)syz_test},
+    {"syz_test$align5", 1000012, (syscall_t)syz_test},
+    {"syz_test$align6", 1000012, (syscall_t)syz_test},
+    {"syz_test$array0", 1000012, (syscall_t)syz_test},
+    {"syz_test$array1", 1000012, (syscall_t)syz_test},
+    {"syz_test$array2", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf0", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf1", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_encode", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_icmp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_sctp", 1000012, (syscall_t)syz_test},
+    {"syz_test$end0", 1000012, (syscall_t)syz_test},
+    {"syz_test$end1", 1000012, (syscall_t)syz_test},
+    {"syz_test$int", 1000012, (syscall_t)syz_test},
+    {"syz_test$length0", 1000012, (syscall_t)syz_test},
+    {"syz_test$length1", 1000012, (syscall_t)syz_test},
+    {"syz_test$length10", 1000012, (syscall_t)syz_test},
+    {"syz_test$length11", 1000012, (syscall_t)syz_test},
+    {"syz_test$length12", 1000012, (syscall_t)syz_test},
+    {"syz_test$length13", 1000012, (syscall_t)syz_test},
+    {"syz_test$length14", 1000012, (syscall_t)syz_test},
+    {"syz_test$length15", 1000012, (syscall_t)syz_test},
+    {"syz_test$length16", 1000012, (syscall_t)syz_test},
+    {"syz_test$length17", 1000012, (syscall_t)syz_test},
+    {"syz_test$length18", 1000012, (syscall_t)syz_test},
+    {"syz_test$length19", 1000012, (syscall_t)syz_test},
+    {"syz_test$length2", 1000012, (syscall_t)syz_test},
+    {"syz_test$length20", 1000012, (syscall_t)syz_test},
+    {"syz_test$length3", 1000012, (syscall_t)syz_test},
+    {"syz_test$length4", 1000012, (syscall_t)syz_test},
+    {"syz_test$length5", 1000012, (syscall_t)syz_test},
+    {"syz_test$length6", 1000012, (syscall_t)syz_test},
+    {"syz_test$length7", 1000012, (syscall_t)syz_test},
+    {"syz_test$length8", 1000012, (syscall_t)syz_test},
+    {"syz_test$length9", 1000012, (syscall_t)syz_test},
+    {"syz_test$missing_resource", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt0", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt1", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt2", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur0", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur1", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur2", 1000012, (syscall_t)syz_test},
+    {"syz_test$regression0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res1", 1000012, (syscall_t)syz_test},
+    {"syz_test$struct", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_16", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_32", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_64", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_real", 1000012, (syscall_t)syz_test},
+    {"syz_test$union0", 1000012, (syscall_t)syz_test},
+    {"syz_test$union1", 1000012, (syscall_t)syz_test},
+    {"syz_test$union2", 1000012, (syscall_t)syz_test},
+    {"syz_test$vma0", 1000012, (syscall_t)syz_test},
+    {"syz_usb_connect", 1000013, (syscall_t)syz_usb_connect},
+    {"syz_usb_control_io", 1000014, (syscall_t)syz_usb_control_io},
+    {"syz_usb_disconnect", 1000015, (syscall_t)syz_usb_disconnect},
     {"tee", 77},
     {"tgkill", 131},
     {"timer_create", 107},
@@ -6062,17 +6130,25 @@ call_t syscalls[] = {
 
 #if defined(__ppc64__) || defined(__PPC64__) || defined(__powerpc64__) || 0
 #define GOARCH "ppc64le"
-#define SYZ_REVISION "95b3a8b4a1bf4c6f123a0b70e89e8f569b7ddb0a"
-#define __NR_syz_emit_ethernet 1000000
-#define __NR_syz_extract_tcp_res 1000001
-#define __NR_syz_fuse_mount 1000002
-#define __NR_syz_fuseblk_mount 1000003
-#define __NR_syz_kvm_setup_cpu 1000004
-#define __NR_syz_open_dev 1000005
-#define __NR_syz_open_pts 1000006
-#define __NR_syz_test 1000007
+#define SYZ_REVISION "719984edda21c90dc95cd044c95bea1d2f455896"
+#define __NR_syz_ashmem_create 1000000
+#define __NR_syz_binder_open 1000001
+#define __NR_syz_binder_read 1000002
+#define __NR_syz_binder_transaction 1000003
+#define __NR_syz_emit_ethernet 1000004
+#define __NR_syz_extract_tcp_res 1000005
+#define __NR_syz_fuse_mount 1000006
+#define __NR_syz_fuseblk_mount 1000007
+#define __NR_syz_kvm_setup_cpu 1000008
+#define __NR_syz_mount_image 1000009
+#define __NR_syz_open_dev 1000010
+#define __NR_syz_open_pts 1000011
+#define __NR_syz_test 1000012
+#define __NR_syz_usb_connect 1000013
+#define __NR_syz_usb_control_io 1000014
+#define __NR_syz_usb_disconnect 1000015
 
-unsigned syscall_count = 1451;
+unsigned syscall_count = 1460;
 call_t syscalls[] = {
     {"accept", 330},
     {"accept$alg", 330},
@@ -7377,109 +7453,118 @@ call_t syscalls[] = {
     {"sysfs$3", 135},
     {"sysinfo", 116},
     {"syslog", 103},
-    {"syz_emit_ethernet", 1000000, (syscall_t)syz_emit_ethernet},
-    {"syz_extract_tcp_res", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_extract_tcp_res$synack", 1000001, (syscall_t)syz_extract_tcp_res},
-    {"syz_fuse_mount", 1000002, (syscall_t)syz_fuse_mount},
-    {"syz_fuseblk_mount", 1000003, (syscall_t)syz_fuseblk_mount},
-    {"syz_kvm_setup_cpu$arm64", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_kvm_setup_cpu$x86", 1000004, (syscall_t)syz_kvm_setup_cpu},
-    {"syz_open_dev$admmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$adsp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$amidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$audion", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dmmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dri", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dricontrol", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$drirender", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$dspn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$evdev", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$floppy", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$ircomm", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$loop", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mice", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$midi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$mouse", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$random", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sg", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndctrl", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndhw", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndmidi", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmc", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndpcmp", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndseq", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$sndtimer", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tlk_device", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$tun", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$urandom", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usb", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$usbmon", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsa", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_dev$vcsn", 1000005, (syscall_t)syz_open_dev},
-    {"syz_open_pts", 1000006, (syscall_t)syz_open_pts},
-    {"syz_test", 1000007, (syscall_t)syz_test},
-    {"syz_test$align0", 1000007, (syscall_t)syz_test},
-    {"syz_test$align1", 1000007, (syscall_t)syz_test},
-    {"syz_test$align2", 1000007, (syscall_t)syz_test},
-    {"syz_test$align3", 1000007, (syscall_t)syz_test},
-    {"syz_test$align4", 1000007, (syscall_t)syz_test},
-    {"syz_test$align5", 1000007, (syscall_t)syz_test},
-    {"syz_test$align6", 1000007, (syscall_t)syz_test},
-    {"syz_test$array0", 1000007, (syscall_t)syz_test},
-    {"syz_test$array1", 1000007, (syscall_t)syz_test},
-    {"syz_test$array2", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf0", 1000007, (syscall_t)syz_test},
-    {"syz_test$bf1", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_encode", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv4_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_icmp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_tcp", 1000007, (syscall_t)syz_test},
-    {"syz_test$csum_ipv6_udp", 1000007, (syscall_t)syz_test},
-    {"syz_test$end0", 1000007, (syscall_t)syz_test},
-    {"syz_test$end1", 1000007, (syscall_t)syz_test},
-    {"syz_test$int", 1000007, (syscall_t)syz_test},
-    {"syz_test$length0", 1000007, (syscall_t)syz_test},
-    {"syz_test$length1", 1000007, (syscall_t)syz_test},
-    {"syz_test$length10", 1000007, (syscall_t)syz_test},
-    {"syz_test$length11", 1000007, (syscall_t)syz_test},
-    {"syz_test$length12", 1000007, (syscall_t)syz_test},
-    {"syz_test$length13", 1000007, (syscall_t)syz_test},
-    {"syz_test$length14", 1000007, (syscall_t)syz_test},
-    {"syz_test$length15", 1000007, (syscall_t)syz_test},
-    {"syz_test$length16", 1000007, (syscall_t)syz_test},
-    {"syz_test$length17", 1000007, (syscall_t)syz_test},
-    {"syz_test$length18", 1000007, (syscall_t)syz_test},
-    {"syz_test$length19", 1000007, (syscall_t)syz_test},
-    {"syz_test$length2", 1000007, (syscall_t)syz_test},
-    {"syz_test$length20", 1000007, (syscall_t)syz_test},
-    {"syz_test$length3", 1000007, (syscall_t)syz_test},
-    {"syz_test$length4", 1000007, (syscall_t)syz_test},
-    {"syz_test$length5", 1000007, (syscall_t)syz_test},
-    {"syz_test$length6", 1000007, (syscall_t)syz_test},
-    {"syz_test$length7", 1000007, (syscall_t)syz_test},
-    {"syz_test$length8", 1000007, (syscall_t)syz_test},
-    {"syz_test$length9", 1000007, (syscall_t)syz_test},
-    {"syz_test$missing_resource", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt0", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt1", 1000007, (syscall_t)syz_test},
-    {"syz_test$opt2", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur0", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur1", 1000007, (syscall_t)syz_test},
-    {"syz_test$recur2", 1000007, (syscall_t)syz_test},
-    {"syz_test$regression0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res0", 1000007, (syscall_t)syz_test},
-    {"syz_test$res1", 1000007, (syscall_t)syz_test},
-    {"syz_test$struct", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_16", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_32", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_64", 1000007, (syscall_t)syz_test},
-    {"syz_test$text_x86_real", 1000007, (syscall_t)syz_test},
-    {"syz_test$union0", 1000007, (syscall_t)syz_test},
-    {"syz_test$union1", 1000007, (syscall_t)syz_test},
-    {"syz_test$union2", 1000007, (syscall_t)syz_test},
-    {"syz_test$vma0", 1000007, (syscall_t)syz_test},
+    {"syz_ashmem_create", 1000000, (syscall_t)syz_ashmem_create},
+    {"syz_binder_open", 1000001, (syscall_t)syz_binder_open},
+    {"syz_binder_read", 1000002, (syscall_t)syz_binder_read},
+    {"syz_binder_transaction", 1000003, (syscall_t)syz_binder_transaction},
+    {"syz_emit_ethernet", 1000004, (syscall_t)syz_emit_ethernet},
+    {"syz_extract_tcp_res", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_extract_tcp_res$synack", 1000005, (syscall_t)syz_extract_tcp_res},
+    {"syz_fuse_mount", 1000006, (syscall_t)syz_fuse_mount},
+    {"syz_fuseblk_mount", 1000007, (syscall_t)syz_fuseblk_mount},
+    {"syz_kvm_setup_cpu$arm64", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_kvm_setup_cpu$x86", 1000008, (syscall_t)syz_kvm_setup_cpu},
+    {"syz_mount_image", 1000009, (syscall_t)syz_mount_image},
+    {"syz_open_dev$admmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$adsp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$amidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$audion", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dmmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dri", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dricontrol", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$drirender", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$dspn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$evdev", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$floppy", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$ircomm", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$loop", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mice", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$midi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$mouse", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$random", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sg", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndctrl", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndhw", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndmidi", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmc", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndpcmp", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndseq", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$sndtimer", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tlk_device", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$tun", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$urandom", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usb", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$usbmon", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsa", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_dev$vcsn", 1000010, (syscall_t)syz_open_dev},
+    {"syz_open_pts", 1000011, (syscall_t)syz_open_pts},
+    {"syz_test", 1000012, (syscall_t)syz_test},
+    {"syz_test$align0", 1000012, (syscall_t)syz_test},
+    {"syz_test$align1", 1000012, (syscall_t)syz_test},
+    {"syz_test$align2", 1000012, (syscall_t)syz_test},
+    {"syz_test$align3", 1000012, (syscall_t)syz_test},
+    {"syz_test$align4", 1000012, (syscall_t)syz_test},
+    {"syz_test$align5", 1000012, (syscall_t)syz_test},
+    {"syz_test$align6", 1000012, (syscall_t)syz_test},
+    {"syz_test$array0", 1000012, (syscall_t)syz_test},
+    {"syz_test$array1", 1000012, (syscall_t)syz_test},
+    {"syz_test$array2", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf0", 1000012, (syscall_t)syz_test},
+    {"syz_test$bf1", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_encode", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv4_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_icmp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_tcp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_ipv6_udp", 1000012, (syscall_t)syz_test},
+    {"syz_test$csum_sctp", 1000012, (syscall_t)syz_test},
+    {"syz_test$end0", 1000012, (syscall_t)syz_test},
+    {"syz_test$end1", 1000012, (syscall_t)syz_test},
+    {"syz_test$int", 1000012, (syscall_t)syz_test},
+    {"syz_test$length0", 1000012, (syscall_t)syz_test},
+    {"syz_test$length1", 1000012, (syscall_t)syz_test},
+    {"syz_test$length10", 1000012, (syscall_t)syz_test},
+    {"syz_test$length11", 1000012, (syscall_t)syz_test},
+    {"syz_test$length12", 1000012, (syscall_t)syz_test},
+    {"syz_test$length13", 1000012, (syscall_t)syz_test},
+    {"syz_test$length14", 1000012, (syscall_t)syz_test},
+    {"syz_test$length15", 1000012, (syscall_t)syz_test},
+    {"syz_test$length16", 1000012, (syscall_t)syz_test},
+    {"syz_test$length17", 1000012, (syscall_t)syz_test},
+    {"syz_test$length18", 1000012, (syscall_t)syz_test},
+    {"syz_test$length19", 1000012, (syscall_t)syz_test},
+    {"syz_test$length2", 1000012, (syscall_t)syz_test},
+    {"syz_test$length20", 1000012, (syscall_t)syz_test},
+    {"syz_test$length3", 1000012, (syscall_t)syz_test},
+    {"syz_test$length4", 1000012, (syscall_t)syz_test},
+    {"syz_test$length5", 1000012, (syscall_t)syz_test},
+    {"syz_test$length6", 1000012, (syscall_t)syz_test},
+    {"syz_test$length7", 1000012, (syscall_t)syz_test},
+    {"syz_test$length8", 1000012, (syscall_t)syz_test},
+    {"syz_test$length9", 1000012, (syscall_t)syz_test},
+    {"syz_test$missing_resource", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt0", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt1", 1000012, (syscall_t)syz_test},
+    {"syz_test$opt2", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur0", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur1", 1000012, (syscall_t)syz_test},
+    {"syz_test$recur2", 1000012, (syscall_t)syz_test},
+    {"syz_test$regression0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res0", 1000012, (syscall_t)syz_test},
+    {"syz_test$res1", 1000012, (syscall_t)syz_test},
+    {"syz_test$struct", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_16", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_32", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_64", 1000012, (syscall_t)syz_test},
+    {"syz_test$text_x86_real", 1000012, (syscall_t)syz_test},
+    {"syz_test$union0", 1000012, (syscall_t)syz_test},
+    {"syz_test$union1", 1000012, (syscall_t)syz_test},
+    {"syz_test$union2", 1000012, (syscall_t)syz_test},
+    {"syz_test$vma0", 1000012, (syscall_t)syz_test},
+    {"syz_usb_connect", 1000013, (syscall_t)syz_usb_connect},
+    {"syz_usb_control_io", 1000014, (syscall_t)syz_usb_control_io},
+    {"syz_usb_disconnect", 1000015, (syscall_t)syz_usb_disconnect},
     {"tee", 284},
     {"tgkill", 250},
     {"time", 13},
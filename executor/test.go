@@ -10,6 +10,7 @@ package executor
 // int test_copyin();
 // int test_csum_inet();
 // int test_csum_inet_acc();
+// int test_csum_crc32c();
 // int test_kvm();
 import "C"
 
@@ -25,6 +26,10 @@ func testCsumInetAcc() int {
 	return int(C.test_csum_inet_acc())
 }
 
+func testCsumCrc32c() int {
+	return int(C.test_csum_crc32c())
+}
+
 func testKVM() int {
 	return int(C.test_kvm())
 }
@@ -195,6 +195,47 @@ extern "C" int test_csum_inet_acc()
 	return 0;
 }
 
+struct csum_crc32c_test {
+	const char* data;
+	size_t length;
+	uint32_t csum;
+};
+
+extern "C" int test_csum_crc32c()
+{
+	// Test vectors from RFC 3720, Appendix B.4.
+	struct csum_crc32c_test tests[] = {
+	    {"", 0, 0x00000000},
+	    {"123456789", 9, 0xe3069283},
+	    {"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"
+	     "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00",
+	     32, 0x8a9136aa},
+	    {"\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff"
+	     "\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff",
+	     32, 0x62a8ab43},
+	    {"\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f"
+	     "\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f",
+	     32, 0x46dd794e},
+	    {"\x1f\x1e\x1d\x1c\x1b\x1a\x19\x18\x17\x16\x15\x14\x13\x12\x11\x10"
+	     "\x0f\x0e\x0d\x0c\x0b\x0a\x09\x08\x07\x06\x05\x04\x03\x02\x01\x00",
+	     32, 0x113fdb5c},
+	};
+
+	for (unsigned i = 0; i < ARRAY_SIZE(tests); i++) {
+		struct csum_crc32c csum;
+		csum_crc32c_init(&csum);
+		// Feed data in two chunks to check that updates accumulate.
+		size_t half = tests[i].length / 2;
+		csum_crc32c_update(&csum, (const uint8_t*)tests[i].data, half);
+		csum_crc32c_update(&csum, (const uint8_t*)tests[i].data + half, tests[i].length - half);
+		if (csum_crc32c_digest(&csum) != tests[i].csum) {
+			fprintf(stderr, "bad crc32c checksum in test #%u, want: %x, got: %x\n", i, tests[i].csum, csum_crc32c_digest(&csum));
+			return 1;
+		}
+	}
+	return 0;
+}
+
 static unsigned host_kernel_version();
 static void dump_cpu_state(int cpufd, char* vm_mem);
 
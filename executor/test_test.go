@@ -29,6 +29,10 @@ func TestCsumInetAcc(t *testing.T) {
 	testWrapper(t, testCsumInetAcc)
 }
 
+func TestCsumCrc32c(t *testing.T) {
+	testWrapper(t, testCsumCrc32c)
+}
+
 func TestKVM(t *testing.T) {
 	testWrapper(t, testKVM)
 }
@@ -0,0 +1,394 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package bisect finds the kernel commit that introduced (or fixed) a crash.
+// It builds kernels at bisection points, boots them in the configured VM type
+// and runs the reproducer to see if the crash happens.
+package bisect
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/google/syzkaller/pkg/csource"
+	"github.com/google/syzkaller/pkg/git"
+	"github.com/google/syzkaller/pkg/kernel"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+	"github.com/google/syzkaller/vm"
+)
+
+type Config struct {
+	Trace io.Writer // receives bisection log
+	// Kernel git checkout, it is modified during bisection.
+	KernelDir string
+	// The crash does not happen on Good, but happens on Bad.
+	// If Fix is set, the crash happens on Good, but does not happen on Bad.
+	Good string
+	Bad  string
+	Fix  bool
+	// Kernel build and image creation parameters (see kernel.Build and kernel.CreateImage).
+	KernelConfig string
+	Compiler     string
+	Ccache       string
+	Userspace    string
+	Cmdline      string
+	Sysctl       string
+	// Work dir for kernel images and reproducer binaries.
+	WorkDir string
+	// Manager config that describes the VM type and target.
+	// Image, Sshkey and Kernel_Src are replaced with the bisection kernel.
+	Manager *mgrconfig.Config
+	// C reproducer source. If empty, ReproSyz is run with syz-execprog.
+	Repro    []byte
+	ReproSyz []byte
+	// csource.Options of ReproSyz as printed by syz-manager (%+v),
+	// execprog flags are derived from them (manager defaults are used if empty).
+	ReproOpts []byte
+	// Title of the crash. If set, other crashes don't count as reproduction.
+	Title string
+	// Number of times the reproducer is run on every commit and duration of every run.
+	Attempts int
+	Duration time.Duration
+	// If set, kernel builds are serialized with other users of the semaphore.
+	BuildSem chan struct{}
+	// If closed, testing of further commits fails.
+	Stop <-chan struct{}
+}
+
+// TestResult is the result of testing a single commit.
+type TestResult struct {
+	Result git.BisectResult
+	// Title and report of the first crash that counts as reproduction, if any.
+	Title  string
+	Report []byte
+}
+
+// Run bisects the crash between cfg.Good and cfg.Bad.
+// Returns the single commit that introduced the crash, or the set of candidate commits
+// if some commits could not be tested (e.g. did not build or crashed with a different crash).
+// For fix bisection returns no commits if the crash still happens on cfg.Bad.
+func Run(cfg *Config) ([]*git.Commit, error) {
+	env, err := NewEnv(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer env.Close()
+
+	env.logf("bisecting %q between %v and %v", cfg.Title, cfg.Good, cfg.Bad)
+	for _, check := range []struct {
+		commit string
+		want   git.BisectResult
+	}{
+		{cfg.Bad, git.BisectNew},
+		{cfg.Good, git.BisectOld},
+	} {
+		if err := git.Checkout(cfg.KernelDir, check.commit); err != nil {
+			return nil, err
+		}
+		res, err := env.test()
+		if err != nil {
+			return nil, err
+		}
+		if res != check.want {
+			if cfg.Fix && check.commit == cfg.Bad && res == git.BisectOld {
+				env.logf("the crash still happens on %v", cfg.Bad)
+				return nil, nil
+			}
+			return nil, fmt.Errorf("commit %v: %v, want %v",
+				check.commit, env.resultString(res), env.resultString(check.want))
+		}
+	}
+	commits, err := git.Bisect(cfg.KernelDir, cfg.Good, cfg.Bad, cfg.Trace, env.test)
+	if err != nil {
+		return nil, err
+	}
+	for _, com := range commits {
+		env.logf("culprit candidate: %v %q", com.Hash, com.Title)
+	}
+	return commits, nil
+}
+
+// Env tests kernel commits with the reproducer.
+type Env struct {
+	cfg       *Config
+	bin       string // C reproducer binary
+	reproFile string // syz reproducer file
+}
+
+// NewEnv prepares the reproducer for testing commits according to cfg.
+// The returned Env must be closed.
+func NewEnv(cfg *Config) (*Env, error) {
+	if cfg.Attempts <= 0 {
+		cfg.Attempts = 3
+	}
+	if cfg.Duration == 0 {
+		cfg.Duration = 10 * time.Minute
+	}
+	if cfg.Trace == nil {
+		cfg.Trace = ioutil.Discard
+	}
+	env := &Env{cfg: cfg}
+	if err := osutil.MkdirAll(cfg.WorkDir); err != nil {
+		return nil, fmt.Errorf("failed to create work dir: %v", err)
+	}
+	if len(cfg.Repro) == 0 {
+		env.reproFile = filepath.Join(cfg.WorkDir, "repro.prog")
+		if err := osutil.WriteFile(env.reproFile, cfg.ReproSyz); err != nil {
+			return nil, fmt.Errorf("failed to write reproducer: %v", err)
+		}
+		return env, nil
+	}
+	target, err := prog.GetTarget(cfg.Manager.TargetOS, cfg.Manager.TargetArch)
+	if err != nil {
+		return nil, err
+	}
+	srcFile := filepath.Join(cfg.WorkDir, "repro.c")
+	if err := osutil.WriteFile(srcFile, cfg.Repro); err != nil {
+		return nil, fmt.Errorf("failed to write reproducer: %v", err)
+	}
+	env.bin, err = csource.Build(target, "c", srcFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build reproducer: %v", err)
+	}
+	return env, nil
+}
+
+// Close removes the reproducer binary.
+func (env *Env) Close() {
+	if env.bin != "" {
+		os.Remove(env.bin)
+	}
+}
+
+func (env *Env) logf(msg string, args ...interface{}) {
+	fmt.Fprintf(env.cfg.Trace, "%v: %v\n", time.Now().Format("2006/01/02 15:04:05"), fmt.Sprintf(msg, args...))
+}
+
+func (env *Env) test() (git.BisectResult, error) {
+	res, err := env.Test()
+	if err != nil {
+		return git.BisectSkip, err
+	}
+	return res.Result, nil
+}
+
+// Test builds the currently checked out kernel and runs the reproducer on it.
+// The result is BisectNew if the crash happens, BisectOld if it does not
+// (the other way around if cfg.Fix is set) and BisectSkip if the commit can't be tested.
+func (env *Env) Test() (*TestResult, error) {
+	cfg := env.cfg
+	select {
+	case <-cfg.Stop:
+		return nil, fmt.Errorf("testing is stopped")
+	default:
+	}
+	res := &TestResult{Result: git.BisectSkip}
+	commit, err := git.HeadCommit(cfg.KernelDir)
+	if err != nil {
+		return nil, err
+	}
+	env.logf("testing commit %v", commit)
+	mgrcfg, err := env.build()
+	if err != nil {
+		env.logf("build failed: %v", err)
+		return res, nil
+	}
+	reporter, err := report.NewReporter(mgrcfg.TargetOS, mgrcfg.Kernel_Src, "", nil, mgrcfg.ParsedIgnores)
+	if err != nil {
+		return nil, err
+	}
+	var titles []string
+	for i := 0; i < cfg.Attempts; i++ {
+		title, report, err := env.runRepro(mgrcfg, reporter)
+		if err != nil {
+			env.logf("failed to run reproducer: %v", err)
+			continue
+		}
+		if title != "" {
+			env.logf("crashed: %v", title)
+			if res.Title == "" && (cfg.Title == "" || title == cfg.Title) {
+				res.Title, res.Report = title, report
+			}
+		}
+		titles = append(titles, title)
+	}
+	if len(titles) == 0 {
+		return res, nil
+	}
+	res.Result = classify(cfg.Title, titles)
+	if cfg.Fix && res.Result != git.BisectSkip {
+		res.Result = invert(res.Result)
+	}
+	env.logf("result: %v", env.resultString(res.Result))
+	return res, nil
+}
+
+// classify decides if the crash reproduced given titles of crashes
+// in all reproducer runs (empty title means that the kernel did not crash).
+func classify(want string, titles []string) git.BisectResult {
+	other := false
+	for _, title := range titles {
+		if title == "" {
+			continue
+		}
+		if want == "" || title == want {
+			return git.BisectNew
+		}
+		other = true
+	}
+	if other {
+		// Some other crash masks the one we are interested in.
+		return git.BisectSkip
+	}
+	return git.BisectOld
+}
+
+func invert(res git.BisectResult) git.BisectResult {
+	if res == git.BisectNew {
+		return git.BisectOld
+	}
+	return git.BisectNew
+}
+
+func (env *Env) resultString(res git.BisectResult) string {
+	if env.cfg.Fix && res != git.BisectSkip {
+		res = invert(res)
+	}
+	return resultString(res)
+}
+
+func resultString(res git.BisectResult) string {
+	switch res {
+	case git.BisectOld:
+		return "does not crash"
+	case git.BisectNew:
+		return "crashes"
+	default:
+		return "can't be tested"
+	}
+}
+
+// build builds kernel and image for the checked out commit
+// and returns manager config that refers to them.
+func (env *Env) build() (*mgrconfig.Config, error) {
+	cfg := env.cfg
+	if cfg.BuildSem != nil {
+		cfg.BuildSem <- struct{}{}
+		defer func() { <-cfg.BuildSem }()
+	}
+	if err := kernel.Build(cfg.KernelDir, cfg.Compiler, cfg.Ccache, cfg.KernelConfig); err != nil {
+		return nil, fmt.Errorf("kernel build failed: %v", err)
+	}
+	imageDir := filepath.Join(cfg.WorkDir, "image")
+	if err := os.RemoveAll(imageDir); err != nil {
+		return nil, fmt.Errorf("failed to remove image dir: %v", err)
+	}
+	if err := osutil.MkdirAll(imageDir); err != nil {
+		return nil, fmt.Errorf("failed to create image dir: %v", err)
+	}
+	mgrcfg := *cfg.Manager
+	mgrcfg.Image = filepath.Join(imageDir, "image")
+	mgrcfg.Sshkey = filepath.Join(imageDir, "key")
+	mgrcfg.Kernel_Src = cfg.KernelDir
+	mgrcfg.Vmlinux = filepath.Join(cfg.KernelDir, "vmlinux")
+	mgrcfg.Workdir = filepath.Join(cfg.WorkDir, "workdir")
+	if err := kernel.CreateImage(cfg.KernelDir, cfg.Userspace, cfg.Cmdline, cfg.Sysctl,
+		mgrcfg.Image, mgrcfg.Sshkey); err != nil {
+		return nil, fmt.Errorf("image build failed: %v", err)
+	}
+	if err := osutil.MkdirAll(mgrcfg.Workdir); err != nil {
+		return nil, fmt.Errorf("failed to create workdir: %v", err)
+	}
+	return &mgrcfg, nil
+}
+
+// runRepro boots a single VM and runs the reproducer in it.
+// Returns title and report of the crash, or empty title if the kernel did not crash.
+func (env *Env) runRepro(mgrcfg *mgrconfig.Config, reporter report.Reporter) (string, []byte, error) {
+	pool, err := vm.Create(mgrcfg.Type, mgrconfig.CreateVMEnv(mgrcfg, false))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create VM pool: %v", err)
+	}
+	inst, err := pool.Create(0)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create VM: %v", err)
+	}
+	defer inst.Close()
+	cmd, err := env.reproCommand(inst, mgrcfg)
+	if err != nil {
+		return "", nil, err
+	}
+	outc, errc, err := inst.Run(env.cfg.Duration, nil, cmd)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to run reproducer: %v", err)
+	}
+	title, report, _, crashed, timedout := vm.MonitorExecution(outc, errc, false, reporter)
+	if timedout || !crashed {
+		return "", nil, nil
+	}
+	return title, report, nil
+}
+
+// reproCommand copies the reproducer into the VM and returns the command that runs it.
+func (env *Env) reproCommand(inst *vm.Instance, mgrcfg *mgrconfig.Config) (string, error) {
+	if env.bin != "" {
+		bin, err := inst.Copy(env.bin)
+		if err != nil {
+			return "", fmt.Errorf("failed to copy to VM: %v", err)
+		}
+		return bin, nil
+	}
+	execprogBin, err := inst.Copy(mgrcfg.SyzExecprogBin)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy to VM: %v", err)
+	}
+	executorBin, err := inst.Copy(mgrcfg.SyzExecutorBin)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy to VM: %v", err)
+	}
+	reproFile, err := inst.Copy(env.reproFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy to VM: %v", err)
+	}
+	return fmt.Sprintf("%v -executor=%v -arch=%v -cover=0%v%v %v",
+		execprogBin, executorBin, mgrcfg.TargetArch, execprogFlags(env.cfg.ReproOpts, mgrcfg),
+		mgrcfg.TimeoutFlags(), reproFile), nil
+}
+
+var reproOptRe = regexp.MustCompile(`(\w+):([^ {}\[\]]*)`)
+
+// execprogFlags returns syz-execprog flags that run the reproducer the same way
+// it was run during reproduction according to opts (see Config.ReproOpts).
+func execprogFlags(opts []byte, mgrcfg *mgrconfig.Config) string {
+	vals := map[string]string{
+		"Threaded": "true",
+		"Collide":  "true",
+		"Repeat":   "true",
+		"Procs":    fmt.Sprint(mgrcfg.Procs),
+		"Sandbox":  mgrcfg.Sandbox,
+	}
+	for _, match := range reproOptRe.FindAllSubmatch(opts, -1) {
+		vals[string(match[1])] = string(match[2])
+	}
+	if vals["Sandbox"] == "" {
+		vals["Sandbox"] = "none"
+	}
+	repeat := 0
+	if vals["Repeat"] == "false" {
+		repeat = 1
+	}
+	flags := fmt.Sprintf(" -repeat=%v -procs=%v -sandbox=%v -threaded=%v -collide=%v",
+		repeat, vals["Procs"], vals["Sandbox"], vals["Threaded"] != "false", vals["Collide"] != "false")
+	if vals["Fault"] == "true" {
+		flags += fmt.Sprintf(" -fault_call=%v -fault_nth=%v", vals["FaultCall"], vals["FaultNth"])
+	}
+	return flags
+}
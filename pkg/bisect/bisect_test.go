@@ -0,0 +1,76 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package bisect
+
+import (
+	"testing"
+
+	"github.com/google/syzkaller/pkg/git"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		want   string
+		titles []string
+		res    git.BisectResult
+	}{
+		{"", []string{"", "", ""}, git.BisectOld},
+		{"", []string{"", "foo", ""}, git.BisectNew},
+		{"foo", []string{"", "", "foo"}, git.BisectNew},
+		{"foo", []string{"bar", "", ""}, git.BisectSkip},
+		{"foo", []string{"bar", "foo", ""}, git.BisectNew},
+		{"foo", []string{""}, git.BisectOld},
+	}
+	for i, test := range tests {
+		if res := classify(test.want, test.titles); res != test.res {
+			t.Errorf("test #%v: got %v, want %v", i, resultString(res), resultString(test.res))
+		}
+	}
+}
+
+func TestResultStringFix(t *testing.T) {
+	env := &Env{cfg: &Config{Fix: true}}
+	for res, want := range map[git.BisectResult]string{
+		git.BisectOld:  "crashes",
+		git.BisectNew:  "does not crash",
+		git.BisectSkip: "can't be tested",
+	} {
+		if got := env.resultString(res); got != want {
+			t.Errorf("result %v: got %q, want %q", res, got, want)
+		}
+	}
+}
+
+func TestExecprogFlags(t *testing.T) {
+	mgrcfg := &mgrconfig.Config{
+		Procs:   8,
+		Sandbox: "setuid",
+	}
+	tests := []struct {
+		opts  string
+		flags string
+	}{
+		{
+			"",
+			" -repeat=0 -procs=8 -sandbox=setuid -threaded=true -collide=true",
+		},
+		{
+			"{Threaded:true Collide:false Repeat:true RepeatTimes:0 Procs:2 Sandbox:namespace" +
+				" SeccompDeny:[] Fault:false FaultCall:-1 FaultNth:0 Faults:[] ThreadAssignment:[]}",
+			" -repeat=0 -procs=2 -sandbox=namespace -threaded=true -collide=false",
+		},
+		{
+			"{Threaded:false Collide:false Repeat:false RepeatTimes:0 Procs:1 Sandbox:" +
+				" SeccompDeny:[] Fault:true FaultCall:3 FaultNth:5 Faults:[{Call:1 Nth:2}]}",
+			" -repeat=1 -procs=1 -sandbox=none -threaded=false -collide=false -fault_call=3 -fault_nth=5",
+		},
+	}
+	for i, test := range tests {
+		flags := execprogFlags([]byte(test.opts), mgrcfg)
+		if flags != test.flags {
+			t.Errorf("#%v: got flags:\n%v\nwant:\n%v", i, flags, test.flags)
+		}
+	}
+}
@@ -25,6 +25,7 @@ func (comp *compiler) check() {
 	comp.checkUsed()
 	comp.checkRecursion()
 	comp.checkLenTargets()
+	comp.checkTagTargets()
 	comp.checkConstructors()
 	comp.checkVarlens()
 }
@@ -231,6 +232,107 @@ func (comp *compiler) checkLenTarget(t *ast.Type, name, target string, fields []
 	comp.error(t.Pos, "%v target %v does not exist", t.Ident, target)
 }
 
+func (comp *compiler) checkTagTargets() {
+	for _, decl := range comp.desc.Nodes {
+		switch n := decl.(type) {
+		case *ast.Call:
+			for _, arg := range n.Args {
+				comp.checkTagUse(arg.Type, nil, true)
+			}
+			if n.Ret != nil {
+				comp.checkTagUse(n.Ret, nil, true)
+			}
+		case *ast.Struct:
+			if n.IsUnion {
+				comp.checkKeyedUnion(n)
+			}
+			for _, fld := range n.Fields {
+				comp.checkTagUse(fld.Type, n, false)
+			}
+		}
+	}
+}
+
+// checkTagUse checks that tag types are used only as direct struct fields
+// and keyed types are used only as direct union options.
+// parent is the struct/union that contains t as a field, if any.
+func (comp *compiler) checkTagUse(t *ast.Type, parent *ast.Struct, isArg bool) {
+	desc := comp.getTypeDesc(t)
+	switch desc {
+	case typeTag:
+		if parent == nil || parent.IsUnion {
+			comp.error(t.Pos, "tag can be used only as struct field")
+			return
+		}
+		comp.checkTagTarget(t, parent)
+	case typeKeyed:
+		if parent == nil || !parent.IsUnion {
+			comp.error(t.Pos, "keyed can be used only as union option")
+			return
+		}
+	case typeStruct:
+		return
+	}
+	_, args, _ := comp.getArgsBase(t, "", prog.DirIn, isArg)
+	for i, arg := range args {
+		if desc.Args[i].Type == typeArgType {
+			comp.checkTagUse(arg, nil, false)
+		}
+	}
+}
+
+func (comp *compiler) checkTagTarget(t *ast.Type, parent *ast.Struct) {
+	target := t.Args[0].Ident
+	for _, fld := range parent.Fields {
+		if fld.Name.Name != target {
+			continue
+		}
+		if comp.getTypeDesc(fld.Type) != typeStruct ||
+			!comp.structs[fld.Type.Ident].IsUnion ||
+			!comp.isKeyedUnion(comp.structs[fld.Type.Ident]) {
+			comp.error(t.Pos, "tag target %v is not a union with keyed options", target)
+		}
+		return
+	}
+	comp.error(t.Pos, "tag target %v does not exist", target)
+}
+
+func (comp *compiler) checkKeyedUnion(n *ast.Struct) {
+	keyed := 0
+	for _, fld := range n.Fields {
+		if comp.getTypeDesc(fld.Type) == typeKeyed {
+			keyed++
+		}
+	}
+	if keyed == 0 {
+		return
+	}
+	if keyed != len(n.Fields) {
+		comp.error(n.Pos, "union %v mixes keyed and non-keyed options", n.Name.Name)
+		return
+	}
+	keys := make(map[uint64]string)
+	for _, fld := range n.Fields {
+		key := fld.Type.Args[0].Value
+		if prev, ok := keys[key]; ok {
+			comp.error(fld.Pos, "union %v options %v and %v have the same key 0x%x",
+				n.Name.Name, prev, fld.Name.Name, key)
+			continue
+		}
+		keys[key] = fld.Name.Name
+	}
+}
+
+// isKeyedUnion returns true if options of the union are selected by a tag (keyed[...] options).
+func (comp *compiler) isKeyedUnion(n *ast.Struct) bool {
+	for _, fld := range n.Fields {
+		if comp.getTypeDesc(fld.Type) != typeKeyed {
+			return false
+		}
+	}
+	return len(n.Fields) != 0
+}
+
 func (comp *compiler) checkUsed() {
 	for _, decl := range comp.desc.Nodes {
 		switch n := decl.(type) {
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/google/syzkaller/pkg/ast"
+	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
 )
 
@@ -103,3 +104,43 @@ s2 {
 	got := p.StructDescs[0].Desc
 	t.Logf("got: %#v", got)
 }
+
+func TestTaggedUnion(t *testing.T) {
+	const input = `
+foo(a ptr[in, nlattr])
+nlattr {
+	nla_len		len[parent, int16]
+	nla_type	tag[payload, int16]
+	payload		nlattr_payload
+} [packed]
+nlattr_payload [
+	mtu	keyed[C1, int32]
+	name	keyed[C2, string]
+] [varlen]
+	`
+	desc := ast.Parse([]byte(input), "input", nil)
+	if desc == nil {
+		t.Fatal("failed to parse")
+	}
+	consts := map[string]uint64{"__NR_foo": 1, "C1": 4, "C2": 3}
+	p := Compile(desc, consts, targets.List["linux"]["amd64"], nil)
+	if p == nil {
+		t.Fatal("failed to compile")
+	}
+	for _, s := range p.StructDescs {
+		switch s.Key.Name {
+		case "nlattr":
+			tag, ok := s.Desc.Fields[1].(*prog.TagType)
+			if !ok || tag.Union != "payload" || tag.Size() != 2 {
+				t.Fatalf("bad tag field: %#v", s.Desc.Fields[1])
+			}
+		case "nlattr_payload":
+			if len(s.Desc.OptionKeys) != 2 || s.Desc.OptionKeys[0] != 4 || s.Desc.OptionKeys[1] != 3 {
+				t.Fatalf("bad option keys: %v", s.Desc.OptionKeys)
+			}
+			if _, ok := s.Desc.Fields[0].(*prog.IntType); !ok || s.Desc.Fields[0].FieldName() != "mtu" {
+				t.Fatalf("bad option: %#v", s.Desc.Fields[0])
+			}
+		}
+	}
+}
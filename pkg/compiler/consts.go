@@ -247,6 +247,9 @@ func typeConstIdentifier(n *ast.Type) *ast.Type {
 	if n.Ident == "const" && len(n.Args) > 0 {
 		return n.Args[0]
 	}
+	if n.Ident == "keyed" && len(n.Args) > 0 {
+		return n.Args[0]
+	}
 	if n.Ident == "array" && len(n.Args) > 1 && n.Args[1].Ident != "opt" {
 		return n.Args[1]
 	}
@@ -207,6 +207,11 @@ func (comp *compiler) genStructDesc(res *prog.StructDesc, n *ast.Struct, dir pro
 		TypeCommon: genCommon(n.Name.Name, "", sizeUnassigned, dir, false),
 		Fields:     comp.genFieldArray(n.Fields, dir, false),
 	}
+	if n.IsUnion && comp.isKeyedUnion(n) {
+		for _, f := range n.Fields {
+			res.OptionKeys = append(res.OptionKeys, f.Type.Args[0].Value)
+		}
+	}
 }
 
 func (comp *compiler) isStructVarlen(name string) bool {
@@ -256,6 +261,8 @@ func setBitfieldOffset(t0 prog.Type, offset uint64, middle bool) {
 		t.BitfieldOff, t.BitfieldMdl = offset, middle
 	case *prog.LenType:
 		t.BitfieldOff, t.BitfieldMdl = offset, middle
+	case *prog.TagType:
+		t.BitfieldOff, t.BitfieldMdl = offset, middle
 	case *prog.FlagsType:
 		t.BitfieldOff, t.BitfieldMdl = offset, middle
 	case *prog.ProcType:
@@ -317,7 +324,7 @@ func (comp *compiler) addAlignment(fields []prog.Type, varlen, packed bool, alig
 
 func (comp *compiler) typeAlign(t0 prog.Type) uint64 {
 	switch t0.(type) {
-	case *prog.IntType, *prog.ConstType, *prog.LenType, *prog.FlagsType, *prog.ProcType,
+	case *prog.IntType, *prog.ConstType, *prog.LenType, *prog.TagType, *prog.FlagsType, *prog.ProcType,
 		*prog.CsumType, *prog.PtrType, *prog.VmaType, *prog.ResourceType:
 		return t0.Size()
 	case *prog.BufferType:
@@ -189,6 +189,39 @@ var typeArgLenTarget = &typeArg{
 	Kind: kindIdent,
 }
 
+// typeTag is a field that holds key of the option chosen in a sibling union field,
+// e.g. for netlink attributes: nla_type tag[payload, int16].
+var typeTag = &typeDesc{
+	Names:     []string{"tag"},
+	CantBeOpt: true,
+	NeedBase:  true,
+	Args:      []namedArg{{"union", typeArgTagTarget}},
+	Gen: func(comp *compiler, t *ast.Type, args []*ast.Type, base prog.IntTypeCommon) prog.Type {
+		return &prog.TagType{
+			IntTypeCommon: base,
+			Union:         args[0].Ident,
+		}
+	},
+}
+
+var typeArgTagTarget = &typeArg{
+	Kind: kindIdent,
+}
+
+// typeKeyed is a union option selected by tag value, e.g. keyed[IFLA_MTU, int32].
+// It can be used only as direct type of union options and generates the inner type.
+var typeKeyed = &typeDesc{
+	Names:     []string{"keyed"},
+	CantBeOpt: true,
+	Args:      []namedArg{{"value", typeArgInt}, {"type", typeArgType}},
+	Varlen: func(comp *compiler, t *ast.Type, args []*ast.Type, base prog.IntTypeCommon) bool {
+		return comp.isVarlen(args[1])
+	},
+	Gen: func(comp *compiler, t *ast.Type, args []*ast.Type, base prog.IntTypeCommon) prog.Type {
+		return comp.genType(args[1], base.FldName, base.ArgDir, false)
+	},
+}
+
 var typeFlags = &typeDesc{
 	Names:     []string{"flags"},
 	CanBeArg:  true,
@@ -299,6 +332,9 @@ var typeCsum = &typeDesc{
 		if len(args) > 2 && genCsumKind(args[1]) != prog.CsumPseudo {
 			comp.error(args[2].Pos, "only pseudo csum can have proto")
 		}
+		if genCsumKind(args[1]) == prog.CsumCrc32c && (base.TypeSize != 4 || base.BigEndian) {
+			comp.error(args[1].Pos, "crc32c csum must have int32 base type")
+		}
 	},
 	Gen: func(comp *compiler, t *ast.Type, args []*ast.Type, base prog.IntTypeCommon) prog.Type {
 		var proto uint64
@@ -316,7 +352,7 @@ var typeCsum = &typeDesc{
 
 var typeArgCsumType = &typeArg{
 	Kind:  kindIdent,
-	Names: []string{"inet", "pseudo"},
+	Names: []string{"inet", "pseudo", "crc32c"},
 }
 
 func genCsumKind(t *ast.Type) prog.CsumKind {
@@ -325,6 +361,8 @@ func genCsumKind(t *ast.Type) prog.CsumKind {
 		return prog.CsumInet
 	case "pseudo":
 		return prog.CsumPseudo
+	case "crc32c":
+		return prog.CsumCrc32c
 	default:
 		panic(fmt.Sprintf("unknown csum kind %q", t.Ident))
 	}
@@ -655,6 +693,8 @@ func init() {
 		typePtr,
 		typeArray,
 		typeLen,
+		typeTag,
+		typeKeyed,
 		typeConst,
 		typeFlags,
 		typeFilename,
@@ -73,8 +73,7 @@ func checkUnknownFieldsRec(data []byte, prefix string, typ reflect.Type) error {
 		if !ok {
 			return fmt.Errorf("unknown field '%v%v' in config", prefix, k)
 		}
-		if v != nil && field.Kind() == reflect.Slice &&
-			(field.PkgPath() != "encoding/json" || field.Name() != "RawMessage") {
+		if v != nil && field.Kind() == reflect.Slice && field != rawMessageType {
 			vv := reflect.ValueOf(v)
 			if vv.Type().Kind() != reflect.Slice {
 				return fmt.Errorf("bad json array type '%v%v'", prefix, k)
@@ -94,6 +93,10 @@ func checkUnknownFieldsRec(data []byte, prefix string, typ reflect.Type) error {
 	return nil
 }
 
+// rawMessageType is compared by identity: json.RawMessage can be an alias of a type
+// declared in a different package (e.g. with encoding/json/v2), so its name is not reliable.
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
 func checkUnknownFieldsStruct(val interface{}, prefix string, typ reflect.Type) error {
 	if typ.Kind() == reflect.Ptr {
 		typ = typ.Elem()
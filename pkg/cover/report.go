@@ -0,0 +1,333 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package cover
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// LineCoverage is coverage of source lines: file -> line -> number of corpus programs
+// that cover the line. Lines that contain coverage callbacks, but are not covered, have count 0.
+type LineCoverage map[string]map[int]int
+
+// Add adds count to the file:line counter (count 0 just marks the line as instrumented).
+func (cov LineCoverage) Add(file string, line, count int) {
+	lines := cov[file]
+	if lines == nil {
+		lines = make(map[int]int)
+		cov[file] = lines
+	}
+	lines[line] += count
+}
+
+func (cov LineCoverage) files() []string {
+	var files []string
+	for file := range cov {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func sortedLines(lines map[int]int) []int {
+	var res []int
+	for line := range lines {
+		res = append(res, line)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// fileStats returns number of instrumented and covered lines.
+func fileStats(lines map[int]int) (found, hit int) {
+	for _, count := range lines {
+		found++
+		if count != 0 {
+			hit++
+		}
+	}
+	return
+}
+
+// WriteLCOV writes cov in the LCOV tracefile format (as produced by geninfo),
+// so that it can be rendered with genhtml or consumed by other lcov tools.
+func WriteLCOV(w io.Writer, cov LineCoverage) error {
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "TN:\n")
+	for _, file := range cov.files() {
+		lines := cov[file]
+		fmt.Fprintf(buf, "SF:%v\n", file)
+		for _, line := range sortedLines(lines) {
+			fmt.Fprintf(buf, "DA:%v,%v\n", line, lines[line])
+		}
+		found, hit := fileStats(lines)
+		fmt.Fprintf(buf, "LF:%v\nLH:%v\nend_of_record\n", found, hit)
+	}
+	return buf.Flush()
+}
+
+// WriteFileHTML writes source file src annotated with line coverage lines as an HTML page.
+// Covered lines are highlighted with intensity that grows with the number of covering programs,
+// so that hot and rarely reached code is distinguishable at a glance.
+func WriteFileHTML(w io.Writer, name string, src []byte, lines map[int]int) error {
+	maxCount, maxLine := 0, 0
+	for line, count := range lines {
+		if maxCount < count {
+			maxCount = count
+		}
+		if maxLine < line {
+			maxLine = line
+		}
+	}
+	var srcLines [][]byte
+	if len(src) != 0 {
+		srcLines = bytes.Split(bytes.TrimSuffix(src, []byte{'\n'}), []byte{'\n'})
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(srcLines) || i < maxLine; i++ {
+		// Sources may be missing or not match the binary, then only the counters are shown.
+		text := ""
+		if i < len(srcLines) {
+			text = template.HTMLEscapeString(strings.Replace(string(srcLines[i]), "\t", "        ", -1))
+		}
+		count, instrumented := lines[i+1]
+		switch {
+		case !instrumented:
+			fmt.Fprintf(&buf, "<span class='line'>%5v          %v</span>\n", i+1, text)
+		case count == 0:
+			fmt.Fprintf(&buf, "<span class='line uncovered'>%5v %8v %v</span>\n", i+1, 0, text)
+		default:
+			fmt.Fprintf(&buf, "<span class='line covered heat%v'>%5v %8v %v</span>\n",
+				heatLevel(count, maxCount), i+1, count, text)
+		}
+	}
+	found, hit := fileStats(lines)
+	return fileTemplate.Execute(w, fileData{
+		Name:    name,
+		Body:    template.HTML(buf.String()),
+		Found:   found,
+		Hit:     hit,
+		Percent: percent(hit, found),
+	})
+}
+
+const heatLevels = 5
+
+// heatLevel maps count to 0..heatLevels-1 on a log-like scale relative to maxCount.
+func heatLevel(count, maxCount int) int {
+	level := 0
+	for v := maxCount; v > count && level < heatLevels-1; v /= 4 {
+		level++
+	}
+	return heatLevels - 1 - level
+}
+
+func percent(hit, found int) int {
+	if found == 0 {
+		return 0
+	}
+	return hit * 100 / found
+}
+
+// Exporter writes coverage as LCOV data (coverage.info), an index page (index.html)
+// and per-file annotated HTML pages (files/*.html) into a directory.
+// Pages are regenerated incrementally: a page is rewritten only if coverage
+// of the file has changed since the previous Export.
+type Exporter struct {
+	dir    string
+	srcDir string
+	hashes map[string]uint64
+}
+
+// NewExporter creates an exporter that writes into dir.
+// Relative source file names are resolved against srcDir.
+func NewExporter(dir, srcDir string) *Exporter {
+	return &Exporter{
+		dir:    dir,
+		srcDir: srcDir,
+		hashes: make(map[string]uint64),
+	}
+}
+
+// Export writes cov and returns number of rewritten per-file pages.
+func (e *Exporter) Export(cov LineCoverage) (int, error) {
+	if err := osutil.MkdirAll(filepath.Join(e.dir, "files")); err != nil {
+		return 0, err
+	}
+	lcov := new(bytes.Buffer)
+	if err := WriteLCOV(lcov, cov); err != nil {
+		return 0, err
+	}
+	if err := osutil.WriteFile(filepath.Join(e.dir, "coverage.info"), lcov.Bytes()); err != nil {
+		return 0, err
+	}
+	var index indexData
+	updated := 0
+	for _, file := range cov.files() {
+		lines := cov[file]
+		name := e.displayName(file)
+		page := filepath.ToSlash(filepath.Join("files", name+".html"))
+		found, hit := fileStats(lines)
+		index.Files = append(index.Files, indexFile{
+			Name:    name,
+			Link:    page,
+			Found:   found,
+			Hit:     hit,
+			Percent: percent(hit, found),
+		})
+		index.Found += found
+		index.Hit += hit
+		hash := hashLines(lines)
+		if prev, ok := e.hashes[file]; ok && prev == hash {
+			continue
+		}
+		// Sources can be unavailable (e.g. generated files), then src is empty.
+		src, _ := ioutil.ReadFile(e.sourcePath(file))
+		out := new(bytes.Buffer)
+		if err := WriteFileHTML(out, name, src, lines); err != nil {
+			return updated, err
+		}
+		path := filepath.Join(e.dir, filepath.FromSlash(page))
+		if err := osutil.MkdirAll(filepath.Dir(path)); err != nil {
+			return updated, err
+		}
+		if err := osutil.WriteFile(path, out.Bytes()); err != nil {
+			return updated, err
+		}
+		e.hashes[file] = hash
+		updated++
+	}
+	index.Percent = percent(index.Hit, index.Found)
+	out := new(bytes.Buffer)
+	if err := indexTemplate.Execute(out, index); err != nil {
+		return updated, err
+	}
+	if err := osutil.WriteFile(filepath.Join(e.dir, "index.html"), out.Bytes()); err != nil {
+		return updated, err
+	}
+	return updated, nil
+}
+
+func (e *Exporter) sourcePath(file string) string {
+	if filepath.IsAbs(file) || e.srcDir == "" {
+		return file
+	}
+	return filepath.Join(e.srcDir, file)
+}
+
+// displayName returns file name relative to the source dir (if possible)
+// that does not escape the output dir when used as a path.
+func (e *Exporter) displayName(file string) string {
+	name := file
+	if e.srcDir != "" {
+		if rel, err := filepath.Rel(e.srcDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+	}
+	name = filepath.ToSlash(filepath.Clean("/" + name))
+	return strings.TrimPrefix(name, "/")
+}
+
+func hashLines(lines map[int]int) uint64 {
+	h := fnv.New64a()
+	for _, line := range sortedLines(lines) {
+		fmt.Fprintf(h, "%v:%v,", line, lines[line])
+	}
+	return h.Sum64()
+}
+
+type fileData struct {
+	Name    string
+	Body    template.HTML
+	Found   int
+	Hit     int
+	Percent int
+}
+
+type indexData struct {
+	Files   []indexFile
+	Found   int
+	Hit     int
+	Percent int
+}
+
+type indexFile struct {
+	Name    string
+	Link    string
+	Found   int
+	Hit     int
+	Percent int
+}
+
+const reportStyle = `
+		<style>
+			body {
+				background: white;
+				font-family: 'Courier New', Courier, monospace;
+				color: rgb(70, 70, 70);
+			}
+			.line {
+				white-space: pre;
+			}
+			.uncovered {
+				background: rgb(255, 200, 200);
+			}
+			.heat0 { background: rgb(225, 250, 225); }
+			.heat1 { background: rgb(190, 240, 190); }
+			.heat2 { background: rgb(150, 225, 150); }
+			.heat3 { background: rgb(110, 210, 110); }
+			.heat4 { background: rgb(70, 190, 70); }
+			table td {
+				padding-right: 20px;
+			}
+		</style>
+`
+
+var fileTemplate = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+		<title>{{.Name}}</title>` + reportStyle + `
+	</head>
+	<body>
+		<b>{{.Name}}</b>: {{.Hit}}/{{.Found}} lines ({{.Percent}}%)
+		<pre>{{.Body}}</pre>
+	</body>
+</html>
+`))
+
+var indexTemplate = template.Must(template.New("").Parse(`
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta http-equiv="Content-Type" content="text/html; charset=utf-8">
+		<title>coverage</title>` + reportStyle + `
+	</head>
+	<body>
+		<b>Total</b>: {{.Hit}}/{{.Found}} lines ({{.Percent}}%)
+		<table>
+			<tr><th>File</th><th>Lines</th><th>Coverage</th></tr>
+			{{range $f := .Files}}
+			<tr>
+				<td><a href="{{$f.Link}}">{{$f.Name}}</a></td>
+				<td>{{$f.Hit}}/{{$f.Found}}</td>
+				<td>{{$f.Percent}}%</td>
+			</tr>
+			{{end}}
+		</table>
+	</body>
+</html>
+`))
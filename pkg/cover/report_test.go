@@ -0,0 +1,117 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package cover
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteLCOV(t *testing.T) {
+	cov := make(LineCoverage)
+	cov.Add("/src/b.c", 3, 0)
+	cov.Add("/src/a.c", 10, 2)
+	cov.Add("/src/a.c", 2, 1)
+	cov.Add("/src/a.c", 10, 1)
+	cov.Add("/src/a.c", 5, 0)
+	buf := new(bytes.Buffer)
+	if err := WriteLCOV(buf, cov); err != nil {
+		t.Fatal(err)
+	}
+	want := `TN:
+SF:/src/a.c
+DA:2,1
+DA:5,0
+DA:10,3
+LF:3
+LH:2
+end_of_record
+SF:/src/b.c
+DA:3,0
+LF:1
+LH:0
+end_of_record
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syz-cover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	srcDir := filepath.Join(dir, "src")
+	outDir := filepath.Join(dir, "out")
+	if err := os.MkdirAll(filepath.Join(srcDir, "kernel"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	src := "int foo(int x)\n{\n\tif (x < 0)\n\t\treturn 1;\n\treturn 0;\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(srcDir, "kernel", "foo.c"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(srcDir, "kernel", "foo.c")
+	cov := make(LineCoverage)
+	cov.Add(file, 3, 10)
+	cov.Add(file, 4, 0)
+	cov.Add(file, 5, 10)
+	cov.Add("../../missing.h", 1, 1)
+	e := NewExporter(outDir, srcDir)
+	updated, err := e.Export(cov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 2 {
+		t.Fatalf("updated %v pages, want 2", updated)
+	}
+	page, err := ioutil.ReadFile(filepath.Join(outDir, "files", "kernel", "foo.c.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<b>kernel/foo.c</b>: 2/3 lines (66%)",
+		"<span class='line uncovered'>    4        0                 return 1;</span>",
+		"if (x &lt; 0)",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("page does not contain %q:\n%s", want, page)
+		}
+	}
+	// Names that point outside of the source dir must not escape the output dir.
+	if _, err := os.Stat(filepath.Join(outDir, "files", "missing.h.html")); err != nil {
+		t.Errorf("page for a file outside of the source dir is missing: %v", err)
+	}
+	index, err := ioutil.ReadFile(filepath.Join(outDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), `<a href="files/kernel/foo.c.html">kernel/foo.c</a>`) {
+		t.Errorf("index does not link the file page:\n%s", index)
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "coverage.info")); err != nil {
+		t.Errorf("LCOV data is missing: %v", err)
+	}
+
+	updated, err = e.Export(cov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 0 {
+		t.Fatalf("updated %v pages without coverage changes, want 0", updated)
+	}
+	cov.Add(file, 4, 1)
+	updated, err = e.Export(cov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 1 {
+		t.Fatalf("updated %v pages after coverage change, want 1", updated)
+	}
+}
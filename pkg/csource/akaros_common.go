@@ -9,7 +9,13 @@ var commonHeaderAkaros = `
 #include <pthread.h>
 #include <stdlib.h>
 #endif
-#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT))
+#if defined(SYZ_REPEAT_TIMES)
+#include <sys/wait.h>
+#endif
+#if defined(SYZ_THREADED)
+#include <stdbool.h>
+#endif
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) || defined(SYZ_THREADED)
 #include <errno.h>
 #include <signal.h>
 #include <stdarg.h>
@@ -34,7 +40,8 @@ var commonHeaderAkaros = `
 #if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) ||      \
     defined(SYZ_USE_TMP_DIR) || defined(SYZ_HANDLE_SEGV) || defined(SYZ_TUN_ENABLE) || \
     defined(SYZ_SANDBOX_NAMESPACE) || defined(SYZ_SANDBOX_SETUID) ||                   \
-    defined(SYZ_SANDBOX_NONE) || defined(SYZ_FAULT_INJECTION) || defined(__NR_syz_kvm_setup_cpu)
+    defined(SYZ_SANDBOX_NONE) || defined(SYZ_FAULT_INJECTION) || defined(SYZ_THREADED) ||     \
+    defined(__NR_syz_kvm_setup_cpu)
 __attribute__((noreturn)) static void doexit(int status)
 {
 	_exit(status);
@@ -63,6 +70,17 @@ __attribute__((noreturn)) static void doexit(int status)
 #include <stdarg.h>
 #include <stdio.h>
 #endif
+#if defined(SYZ_TRACE)
+#include <errno.h>
+#include <stdarg.h>
+#include <stdio.h>
+#include <string.h>
+#endif
+#if defined(SYZ_DATA_OFFSET)
+#include <stdio.h>
+#include <stdlib.h>
+#include <sys/mman.h>
+#endif
 
 #if defined(SYZ_EXECUTOR)
 #define exit vsnprintf
@@ -94,7 +112,8 @@ extern unsigned syscall_count;
 
 #if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) ||            \
     defined(SYZ_USE_TMP_DIR) || defined(SYZ_TUN_ENABLE) || defined(SYZ_SANDBOX_NAMESPACE) || \
-    defined(SYZ_SANDBOX_SETUID) || defined(SYZ_FAULT_INJECTION) || defined(__NR_syz_kvm_setup_cpu)
+    defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_SECCOMP) || defined(SYZ_FAULT_INJECTION) || \
+    defined(SYZ_THREADED) || defined(__NR_syz_kvm_setup_cpu)
 const int kFailStatus = 67;
 const int kRetryStatus = 69;
 #endif
@@ -105,7 +124,8 @@ const int kErrorStatus = 68;
 
 #if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) ||            \
     defined(SYZ_USE_TMP_DIR) || defined(SYZ_TUN_ENABLE) || defined(SYZ_SANDBOX_NAMESPACE) || \
-    defined(SYZ_SANDBOX_SETUID) || defined(SYZ_FAULT_INJECTION) || defined(__NR_syz_kvm_setup_cpu)
+    defined(SYZ_SANDBOX_SETUID) || defined(SYZ_SANDBOX_SECCOMP) || defined(SYZ_FAULT_INJECTION) || \
+    defined(SYZ_THREADED) || defined(__NR_syz_kvm_setup_cpu)
 NORETURN static void fail(const char* msg, ...)
 {
 	int e = errno;
@@ -158,6 +178,66 @@ static void debug(const char* msg, ...)
 }
 #endif
 
+#if defined(SYZ_BASE64)
+static void base64_decode(char* dst, const char* src)
+{
+	unsigned acc = 0;
+	int bits = 0;
+
+	for (; *src && *src != '='; src++) {
+		char c = *src;
+		unsigned v;
+		if (c >= 'A' && c <= 'Z')
+			v = c - 'A';
+		else if (c >= 'a' && c <= 'z')
+			v = c - 'a' + 26;
+		else if (c >= '0' && c <= '9')
+			v = c - '0' + 52;
+		else if (c == '+')
+			v = 62;
+		else
+			v = 63;
+		acc = (acc << 6) | v;
+		bits += 6;
+		if (bits >= 8) {
+			bits -= 8;
+			*dst++ = (char)(acc >> bits);
+		}
+	}
+}
+#endif
+
+#if defined(SYZ_TRACE)
+static void trace_call(int call, const char* name, long res, int nargs, ...)
+{
+	int err = errno;
+	va_list args;
+	int i;
+
+	fprintf(stderr, "#%d: %s(", call, name);
+	va_start(args, nargs);
+	for (i = 0; i < nargs; i++)
+		fprintf(stderr, "%s0x%lx", i ? ", " : "", va_arg(args, long));
+	va_end(args);
+	if (res == -1)
+		fprintf(stderr, ") = -1 (errno %d: %s)\n", err, strerror(err));
+	else
+		fprintf(stderr, ") = 0x%lx\n", res);
+	errno = err;
+}
+#endif
+
+#if defined(SYZ_DATA_OFFSET)
+static void setup_data_mapping()
+{
+	void* addr = (void*)SYZ_DATA_OFFSET;
+	if (mmap(addr, SYZ_DATA_SIZE, PROT_READ | PROT_WRITE, MAP_PRIVATE | MAP_ANONYMOUS | MAP_FIXED, -1, 0) != addr) {
+		perror("failed to mmap data area");
+		exit(1);
+	}
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(SYZ_USE_BITMASKS)
 #define BITMASK_LEN(type, bf_len) (type)((1ull << (bf_len)) - 1)
 
@@ -206,6 +286,33 @@ static uint16_t csum_inet_digest(struct csum_inet* csum)
 }
 #endif
 
+#if defined(SYZ_EXECUTOR) || defined(SYZ_USE_CRC32C)
+struct csum_crc32c {
+	uint32_t crc;
+};
+
+static void csum_crc32c_init(struct csum_crc32c* csum)
+{
+	csum->crc = 0xffffffff;
+}
+
+static void csum_crc32c_update(struct csum_crc32c* csum, const uint8_t* data, size_t length)
+{
+	size_t i;
+	int j;
+	for (i = 0; i < length; i++) {
+		csum->crc ^= data[i];
+		for (j = 0; j < 8; j++)
+			csum->crc = (csum->crc >> 1) ^ (0x82f63b78 & -(csum->crc & 1));
+	}
+}
+
+static uint32_t csum_crc32c_digest(struct csum_crc32c* csum)
+{
+	return ~csum->crc;
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(SYZ_HANDLE_SEGV)
 static __thread int skip_segv;
 static __thread jmp_buf segv_env;
@@ -254,7 +361,7 @@ static void install_segv_handler()
 	}
 #endif
 
-#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT))
+#if defined(SYZ_EXECUTOR) || (defined(SYZ_REPEAT) && defined(SYZ_WAIT_REPEAT)) || defined(SYZ_THREADED)
 static uint64_t current_time_ms()
 {
 	struct timespec ts;
@@ -279,13 +386,94 @@ static void use_temporary_dir()
 }
 #endif
 
-#if defined(SYZ_EXECUTOR)
+#if defined(SYZ_EXECUTOR) || defined(SYZ_THREADED)
 static void sleep_ms(uint64_t ms)
 {
 	usleep(ms * 1000);
 }
 #endif
 
+#if defined(SYZ_THREADED)
+struct event_t {
+	pthread_mutex_t mu;
+	pthread_cond_t cv;
+	bool state;
+};
+
+static void event_init(struct event_t* ev)
+{
+	if (pthread_mutex_init(&ev->mu, 0))
+		fail("pthread_mutex_init failed");
+	if (pthread_cond_init(&ev->cv, 0))
+		fail("pthread_cond_init failed");
+	ev->state = false;
+}
+
+static void event_reset(struct event_t* ev)
+{
+	ev->state = false;
+}
+
+static void event_set(struct event_t* ev)
+{
+	pthread_mutex_lock(&ev->mu);
+	if (ev->state)
+		fail("event already set");
+	ev->state = true;
+	pthread_mutex_unlock(&ev->mu);
+	pthread_cond_broadcast(&ev->cv);
+}
+
+static void event_wait(struct event_t* ev)
+{
+	pthread_mutex_lock(&ev->mu);
+	while (!ev->state)
+		pthread_cond_wait(&ev->cv, &ev->mu);
+	pthread_mutex_unlock(&ev->mu);
+}
+
+static bool event_isset(struct event_t* ev)
+{
+	pthread_mutex_lock(&ev->mu);
+	bool res = ev->state;
+	pthread_mutex_unlock(&ev->mu);
+	return res;
+}
+
+static bool event_timedwait(struct event_t* ev, uint64_t timeout_ms)
+{
+	pthread_mutex_lock(&ev->mu);
+	uint64_t start = current_time_ms();
+	for (;;) {
+		if (ev->state)
+			break;
+		uint64_t now = current_time_ms();
+		if (now - start > timeout_ms)
+			break;
+		struct timespec ts;
+		clock_gettime(CLOCK_REALTIME, &ts);
+		uint64_t deadline = (uint64_t)ts.tv_nsec + (timeout_ms - (now - start)) * 1000 * 1000;
+		ts.tv_sec += deadline / 1000000000;
+		ts.tv_nsec = deadline % 1000000000;
+		pthread_cond_timedwait(&ev->cv, &ev->mu, &ts);
+	}
+	bool res = ev->state;
+	pthread_mutex_unlock(&ev->mu);
+	return res;
+}
+
+static void thread_start(void* (*fn)(void*), void* arg)
+{
+	pthread_t th;
+	pthread_attr_t attr;
+	pthread_attr_init(&attr);
+	pthread_attr_setstacksize(&attr, 128 << 10);
+	if (pthread_create(&th, &attr, fn, arg))
+		fail("pthread_create failed");
+	pthread_attr_destroy(&attr);
+}
+#endif
+
 #if defined(SYZ_EXECUTOR) || defined(SYZ_FAULT_INJECTION)
 static int inject_fault(int nth)
 {
@@ -357,7 +545,11 @@ static void test();
 void loop()
 {
 	int iter;
+#if defined(SYZ_REPEAT_TIMES)
+	for (iter = 0; iter < SYZ_REPEAT_TIMES; iter++) {
+#else
 	for (iter = 0;; iter++) {
+#endif
 #ifdef SYZ_USE_TMP_DIR
 		char cwdbuf[256];
 		sprintf(cwdbuf, "./%d", iter);
@@ -382,7 +574,7 @@ void loop()
 			if (res == pid)
 				break;
 			usleep(1000);
-			if (current_time_ms() - start > 5 * 1000) {
+			if (current_time_ms() - start > SYZ_PROGRAM_TIMEOUT_MS) {
 				kill(pid, SIGKILL);
 				while (waitpid(-1, &status, 0) != pid) {
 				}
@@ -397,7 +589,12 @@ void loop()
 #else
 void loop()
 {
+#if defined(SYZ_REPEAT_TIMES)
+	int iter;
+	for (iter = 0; iter < SYZ_REPEAT_TIMES; iter++) {
+#else
 	while (1) {
+#endif
 		test();
 	}
 }
@@ -0,0 +1,309 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+type BuildOpts struct {
+	// Lang is "c" (default) or "c++".
+	Lang string
+	// Compiler is "gcc" (default), "clang" or path to a compiler binary.
+	// gcc is prefixed with the target cross-compiler prefix,
+	// clang is given the corresponding --target.
+	Compiler string
+	// CFlags are additional compiler flags.
+	CFlags []string
+	// Sysroot is passed as --sysroot to the compiler, if set.
+	Sysroot string
+	// Output is the resulting binary path, a temp file is created if empty.
+	Output string
+}
+
+// Build builds a C/C++ program from source src and returns name of the resulting binary.
+// lang can be "c" or "c++".
+func Build(target *prog.Target, lang, src string) (string, error) {
+	return BuildWithOpts(target, src, BuildOpts{Lang: lang})
+}
+
+// BuildWithOpts builds a C/C++ program from source src and returns name of the resulting binary.
+// If the compiler fails, the returned error is *BuildError.
+func BuildWithOpts(target *prog.Target, src string, opts BuildOpts) (string, error) {
+	sysTarget := targets.List[target.OS][target.Arch]
+	compiler, flags := compilerCommand(sysTarget, opts)
+	if _, err := exec.LookPath(compiler); err != nil {
+		return "", NoCompilerErr
+	}
+	bin := opts.Output
+	if bin == "" {
+		f, err := ioutil.TempFile("", "syzkaller")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp file: %v", err)
+		}
+		f.Close()
+		bin = f.Name()
+	}
+	flags = append(flags, "-x", buildLang(opts), "-o", bin, src)
+	flags = append(flags, buildFlags(sysTarget, opts)...)
+	out, err := exec.Command(compiler, append(flags, "-static")...).CombinedOutput()
+	if err != nil && detectLibc(compiler) == "glibc" {
+		// Some distributions don't have static libraries.
+		// musl/uclibc toolchains are used for embedded targets where dynamic
+		// binaries most likely won't run, so we don't fall back for them.
+		out, err = exec.Command(compiler, flags...).CombinedOutput()
+	}
+	if err != nil {
+		os.Remove(bin)
+		data, _ := ioutil.ReadFile(src)
+		return "", &BuildError{
+			Src:         data,
+			Output:      out,
+			Compiler:    compiler,
+			Flags:       flags,
+			Diagnostics: ParseDiagnostics(out),
+		}
+	}
+	return bin, nil
+}
+
+func buildLang(opts BuildOpts) string {
+	if opts.Lang == "" {
+		return "c"
+	}
+	return opts.Lang
+}
+
+// buildFlags returns compiler flags used to build programs for sysTarget
+// (except for language, source and output flags).
+func buildFlags(sysTarget *targets.Target, opts BuildOpts) []string {
+	flags := []string{"-Wall", "-Werror", "-O1", "-g", "-pthread"}
+	flags = append(flags, sysTarget.CrossCFlags...)
+	if sysTarget.PtrSize == 4 {
+		// We do generate uint64's for syscall arguments that overflow longs on 32-bit archs.
+		flags = append(flags, "-Wno-overflow")
+	}
+	if opts.Sysroot != "" {
+		flags = append(flags, "--sysroot="+opts.Sysroot)
+	}
+	return append(flags, opts.CFlags...)
+}
+
+func compilerCommand(sysTarget *targets.Target, opts BuildOpts) (string, []string) {
+	switch opts.Compiler {
+	case "", "gcc":
+		return sysTarget.CCompilerPrefix + "gcc", nil
+	case "clang":
+		var flags []string
+		if triple := strings.TrimSuffix(sysTarget.CCompilerPrefix, "-"); triple != "" {
+			flags = append(flags, "--target="+triple)
+		}
+		return "clang", flags
+	default:
+		return opts.Compiler, nil
+	}
+}
+
+var libcCache = struct {
+	mu   sync.Mutex
+	libc map[string]string
+}{
+	libc: make(map[string]string),
+}
+
+// detectLibc returns "musl", "uclibc" or "glibc" (which is also the default if the libc is unknown)
+// depending on the libc that the compiler targets.
+func detectLibc(compiler string) string {
+	libcCache.mu.Lock()
+	defer libcCache.mu.Unlock()
+	if libc, ok := libcCache.libc[compiler]; ok {
+		return libc
+	}
+	libc := "glibc"
+	out, _ := exec.Command(compiler, "-dumpmachine").CombinedOutput()
+	machine := string(out) + " " + compiler
+	switch {
+	case strings.Contains(machine, "musl"):
+		libc = "musl"
+	case strings.Contains(machine, "uclibc"):
+		libc = "uclibc"
+	}
+	libcCache.libc[compiler] = libc
+	return libc
+}
+
+var NoCompilerErr = errors.New("no target compiler")
+
+// BuildError is returned by BuildWithOpts if the compiler fails.
+// Error returns a short description with the first compiler error,
+// Report returns full details suitable for logs and bug reports.
+type BuildError struct {
+	Src         []byte
+	Output      []byte // raw compiler output
+	Compiler    string
+	Flags       []string
+	Diagnostics []Diagnostic
+}
+
+func (err *BuildError) Error() string {
+	errors := err.Errors()
+	switch len(errors) {
+	case 0:
+		return fmt.Sprintf("failed to build program:\n%s", err.Output)
+	case 1:
+		return fmt.Sprintf("failed to build program: %v", errors[0])
+	default:
+		return fmt.Sprintf("failed to build program: %v (and %v more errors)", errors[0], len(errors)-1)
+	}
+}
+
+// Errors returns only error diagnostics (without warnings and notes).
+func (err *BuildError) Errors() []Diagnostic {
+	var res []Diagnostic
+	for _, diag := range err.Diagnostics {
+		if diag.Severity == "error" || diag.Severity == "fatal error" {
+			res = append(res, diag)
+		}
+	}
+	return res
+}
+
+// Report returns the compiler invocation, compiler output and the source.
+func (err *BuildError) Report() []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "compiler invocation: %v %v\n\n", err.Compiler, strings.Join(err.Flags, " "))
+	fmt.Fprintf(buf, "%s\n", err.Output)
+	fmt.Fprintf(buf, "source:\n%s\n", err.Src)
+	return buf.Bytes()
+}
+
+// Diagnostic is a single compiler error/warning/note.
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity string // "error", "fatal error", "warning" or "note"
+	Message  string
+}
+
+func (diag Diagnostic) String() string {
+	return fmt.Sprintf("%v:%v:%v: %v: %v", diag.File, diag.Line, diag.Column, diag.Severity, diag.Message)
+}
+
+var diagnosticRe = regexp.MustCompile(`^(.+?):([0-9]+):([0-9]+): (error|fatal error|warning|note): (.*)$`)
+
+// ParseDiagnostics extracts gcc/clang diagnostics from the compiler output.
+func ParseDiagnostics(output []byte) []Diagnostic {
+	var res []Diagnostic
+	s := bufio.NewScanner(bytes.NewReader(output))
+	for s.Scan() {
+		match := diagnosticRe.FindStringSubmatch(s.Text())
+		if match == nil {
+			continue
+		}
+		line, _ := strconv.Atoi(match[2])
+		col, _ := strconv.Atoi(match[3])
+		res = append(res, Diagnostic{
+			File:     match[1],
+			Line:     line,
+			Column:   col,
+			Severity: match[4],
+			Message:  match[5],
+		})
+	}
+	return res
+}
+
+// BuildProg generates, formats and builds C program for p.
+// The caller owns the returned binary and is responsible for removing it.
+func BuildProg(p *prog.Prog, opts Options) (string, error) {
+	src, err := Write(p, opts)
+	if err != nil {
+		return "", err
+	}
+	if formatted, err := Format(src); err == nil {
+		src = formatted
+	}
+	srcf, err := osutil.WriteTempFile(src)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(srcf)
+	target := p.Target
+	if opts.Force32Bit {
+		if target, err = CompatTarget(target); err != nil {
+			return "", err
+		}
+	}
+	return Build(target, "c", srcf)
+}
+
+// BuildCache caches binaries built with BuildProg by the program and options,
+// so that repeated requests for the same program (e.g. during reproduction) are cheap.
+// Failed builds are not cached. Binaries are owned by the cache and are removed by Close.
+type BuildCache struct {
+	mu      sync.Mutex
+	entries map[string]*buildCacheEntry
+}
+
+type buildCacheEntry struct {
+	ready chan struct{}
+	bin   string
+	err   error
+}
+
+func NewBuildCache() *BuildCache {
+	return &BuildCache{
+		entries: make(map[string]*buildCacheEntry),
+	}
+}
+
+// Build returns a binary for p built with opts, the binary must not be removed by the caller.
+func (bc *BuildCache) Build(p *prog.Prog, opts Options) (string, error) {
+	key := hash.String(p.Serialize(), []byte(fmt.Sprintf("%v/%v %+v", p.Target.OS, p.Target.Arch, opts)))
+	bc.mu.Lock()
+	ent := bc.entries[key]
+	if ent == nil {
+		ent = &buildCacheEntry{ready: make(chan struct{})}
+		bc.entries[key] = ent
+		bc.mu.Unlock()
+		ent.bin, ent.err = BuildProg(p, opts)
+		if ent.err != nil {
+			// Concurrent waiters get the error, but later requests build again.
+			bc.mu.Lock()
+			delete(bc.entries, key)
+			bc.mu.Unlock()
+		}
+		close(ent.ready)
+	} else {
+		bc.mu.Unlock()
+		<-ent.ready
+	}
+	return ent.bin, ent.err
+}
+
+// Close removes all cached binaries. Build must not be called concurrently with Close.
+func (bc *BuildCache) Close() {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	for key, ent := range bc.entries {
+		os.Remove(ent.bin)
+		delete(bc.entries, key)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+// Build file kinds supported by WriteBuildFile.
+const (
+	BuildFileMake    = "make"
+	BuildFileAndroid = "android"
+)
+
+// BuildFileName returns conventional name of the build file of the given kind.
+func BuildFileName(kind string) string {
+	if kind == BuildFileAndroid {
+		return "Android.bp"
+	}
+	return "Makefile"
+}
+
+// WriteBuildFile generates a build file of the given kind (BuildFileMake or BuildFileAndroid)
+// that builds source file src into binary bin with the same flags that BuildWithOpts uses.
+// src and bin are relative to the build file directory.
+func WriteBuildFile(target *prog.Target, kind, src, bin string, opts BuildOpts) ([]byte, error) {
+	sysTarget := targets.List[target.OS][target.Arch]
+	if sysTarget == nil {
+		return nil, fmt.Errorf("unknown target %v/%v", target.OS, target.Arch)
+	}
+	switch kind {
+	case BuildFileMake:
+		return writeMakefile(target, sysTarget, src, bin, opts), nil
+	case BuildFileAndroid:
+		if target.OS != "linux" {
+			return nil, fmt.Errorf("android build files are not supported on %v", target.OS)
+		}
+		return writeAndroidBp(target, sysTarget, src, bin, opts)
+	default:
+		return nil, fmt.Errorf("unknown build file kind %q, want %v or %v", kind, BuildFileMake, BuildFileAndroid)
+	}
+}
+
+func writeMakefile(target *prog.Target, sysTarget *targets.Target, src, bin string, opts BuildOpts) []byte {
+	compiler, flags := compilerCommand(sysTarget, opts)
+	flags = append(flags, buildFlags(sysTarget, opts)...)
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "# Autogenerated by syzkaller, builds %v for %v/%v.\n\n", src, target.OS, target.Arch)
+	fmt.Fprintf(buf, "CC = %v\n", compiler)
+	fmt.Fprintf(buf, "CFLAGS = -x %v %v\n", buildLang(opts), strings.Join(flags, " "))
+	fmt.Fprintf(buf, "# Remove -static if the toolchain does not have static libraries.\n")
+	fmt.Fprintf(buf, "LDFLAGS = -static\n\n")
+	fmt.Fprintf(buf, "%v: %v\n", bin, src)
+	fmt.Fprintf(buf, "\t$(CC) $(CFLAGS) -o $@ $< $(LDFLAGS)\n\n")
+	fmt.Fprintf(buf, "clean:\n")
+	fmt.Fprintf(buf, "\trm -f %v\n\n", bin)
+	fmt.Fprintf(buf, ".PHONY: clean\n")
+	return buf.Bytes()
+}
+
+// androidArch maps syzkaller arch names to Soong arch names.
+var androidArch = map[string]string{
+	"amd64": "x86_64",
+	"386":   "x86",
+	"arm64": "arm64",
+	"arm":   "arm",
+}
+
+func writeAndroidBp(target *prog.Target, sysTarget *targets.Target, src, bin string, opts BuildOpts) ([]byte, error) {
+	arch := androidArch[target.Arch]
+	if arch == "" {
+		return nil, fmt.Errorf("android build files are not supported on %v", target.Arch)
+	}
+	// Soong chooses the compiler, sysroot and arch flags and links against bionic
+	// (which includes pthread), so only warning/optimization flags are carried over.
+	skip := map[string]bool{"-pthread": true}
+	for _, flag := range sysTarget.CrossCFlags {
+		skip[flag] = true
+	}
+	var flags []string
+	for _, flag := range buildFlags(sysTarget, BuildOpts{CFlags: opts.CFlags}) {
+		if !skip[flag] {
+			flags = append(flags, flag)
+		}
+	}
+	multilib := "64"
+	if sysTarget.PtrSize == 4 {
+		multilib = "32"
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Autogenerated by syzkaller, builds %v for %v/%v.\n\n", src, target.OS, target.Arch)
+	fmt.Fprintf(buf, "cc_binary {\n")
+	fmt.Fprintf(buf, "    name: %q,\n", filepath.Base(bin))
+	fmt.Fprintf(buf, "    srcs: [%q],\n", src)
+	fmt.Fprintf(buf, "    cflags: [\n")
+	for _, flag := range flags {
+		fmt.Fprintf(buf, "        %q,\n", flag)
+	}
+	fmt.Fprintf(buf, "    ],\n")
+	fmt.Fprintf(buf, "    compile_multilib: %q,\n", multilib)
+	fmt.Fprintf(buf, "    enabled: false,\n")
+	fmt.Fprintf(buf, "    arch: {\n")
+	fmt.Fprintf(buf, "        %v: {\n", arch)
+	fmt.Fprintf(buf, "            enabled: true,\n")
+	fmt.Fprintf(buf, "        },\n")
+	fmt.Fprintf(buf, "    },\n")
+	fmt.Fprintf(buf, "    static_executable: true,\n")
+	fmt.Fprintf(buf, "}\n")
+	return buf.Bytes(), nil
+}
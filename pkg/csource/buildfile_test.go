@@ -0,0 +1,97 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestWriteBuildFile(t *testing.T) {
+	for _, target := range prog.AllTargets() {
+		if target.OS != "linux" {
+			continue
+		}
+		sysTarget := targets.List[target.OS][target.Arch]
+		makefile, err := WriteBuildFile(target, BuildFileMake, "repro.c", "repro", BuildOpts{})
+		if err != nil {
+			t.Fatalf("%v: %v", target.Arch, err)
+		}
+		for _, want := range append([]string{"CC = " + sysTarget.CCompilerPrefix + "gcc", "-static"},
+			sysTarget.CrossCFlags...) {
+			if !bytes.Contains(makefile, []byte(want)) {
+				t.Errorf("%v: Makefile does not contain %q:\n%s", target.Arch, want, makefile)
+			}
+		}
+		bp, err := WriteBuildFile(target, BuildFileAndroid, "repro.c", "repro", BuildOpts{})
+		if androidArch[target.Arch] == "" {
+			if err == nil {
+				t.Errorf("%v: no error for Android.bp", target.Arch)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: %v", target.Arch, err)
+		}
+		for _, want := range []string{`name: "repro"`, `srcs: ["repro.c"]`, "static_executable: true"} {
+			if !bytes.Contains(bp, []byte(want)) {
+				t.Errorf("%v: Android.bp does not contain %q:\n%s", target.Arch, want, bp)
+			}
+		}
+	}
+	target, _, _ := initTest(t)
+	if _, err := WriteBuildFile(target, "cmake", "repro.c", "repro", BuildOpts{}); err == nil {
+		t.Errorf("no error for unknown build file kind")
+	}
+}
+
+func TestMakefileBuild(t *testing.T) {
+	target, _, _ := initTest(t)
+	compiler, _ := compilerCommand(targets.List[target.OS][target.Arch], BuildOpts{})
+	if _, err := exec.LookPath(compiler); err != nil {
+		t.Skip(NoCompilerErr)
+	}
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip(err)
+	}
+	p, err := target.Deserialize([]byte("getpid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "syz-csource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	makefile, err := WriteBuildFile(target, BuildFileMake, "repro.c", "repro", BuildOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := osutil.WriteFile(filepath.Join(dir, "repro.c"), src); err != nil {
+		t.Fatal(err)
+	}
+	if err := osutil.WriteFile(filepath.Join(dir, BuildFileName(BuildFileMake)), makefile); err != nil {
+		t.Fatal(err)
+	}
+	// Build dynamically, static libraries may be missing on the host.
+	if _, err := osutil.RunCmd(time.Minute, dir, "make", "LDFLAGS="); err != nil {
+		t.Fatal(err)
+	}
+	if !osutil.IsExist(filepath.Join(dir, "repro")) {
+		t.Fatalf("make did not produce the binary")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+type commonHeader struct {
+	text string
+	// The header implements setup_tun, otherwise EnableTun is ignored.
+	tun bool
+	// The header implements do_sandbox_seccomp.
+	seccomp bool
+	// The header implements KCOV coverage collection (cover_init/cover_reset/cover_collect).
+	cover bool
+	// The header implements bind_to_cpu.
+	affinity bool
+	// The header implements SYZ_REPEAT_TIMEOUT_SEC and remove_temporary_dir.
+	repeatTimeout bool
+	// The header implements watchdog_start.
+	watchdog bool
+	// The header implements setup_rlimits, otherwise MatchExecutorEnv is ignored.
+	rlimits bool
+	// The header implements setup_sysctls.
+	sysctls bool
+}
+
+// commonHeaders maps targets.Target.CommonHeader to the header contents.
+// Adding support for a new OS requires adding executor/common_OS.h,
+// generating it in gen.go, adding it here and setting CommonHeader in sys/targets.
+var commonHeaders = map[string]commonHeader{
+	"linux": {text: commonHeaderLinux, tun: true, seccomp: true, cover: true, affinity: true, repeatTimeout: true,
+		watchdog: true, rlimits: true, sysctls: true},
+	"akaros": {text: commonHeaderAkaros},
+	// Generic fallback for OSes that use syscall numbers but don't have a dedicated header.
+	"posix": {text: commonHeaderPosix, rlimits: true},
+}
+
+func commonHeaderName(target *targets.Target) string {
+	if target.CommonHeader != "" {
+		return target.CommonHeader
+	}
+	if target.SyscallNumbers {
+		return "posix"
+	}
+	return ""
+}
+
+func findCommonHeader(target *targets.Target) (commonHeader, error) {
+	if target == nil {
+		return commonHeader{}, fmt.Errorf("unknown target (supported OSes: %v)",
+			strings.Join(supportedOSes(), ", "))
+	}
+	hdr, ok := commonHeaders[commonHeaderName(target)]
+	if !ok {
+		return commonHeader{}, fmt.Errorf("unsupported OS: %v (supported OSes: %v)",
+			target.OS, strings.Join(supportedOSes(), ", "))
+	}
+	return hdr, nil
+}
+
+// supportedOSes returns list of OSes for which C programs can be generated.
+func supportedOSes() []string {
+	var res []string
+	for OS, archs := range targets.List {
+		for _, target := range archs {
+			if _, ok := commonHeaders[commonHeaderName(target)]; ok {
+				res = append(res, OS)
+			}
+			break
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"fmt"
+
+	"github.com/google/syzkaller/prog"
+)
+
+// compatArchs maps 64-bit archs to 32-bit archs that can run on the same kernel
+// via the compat syscall layer.
+var compatArchs = map[string]map[string]string{
+	"linux": {
+		"amd64": "386",
+		"arm64": "arm",
+	},
+}
+
+// CompatTarget returns the 32-bit target corresponding to the 64-bit target.
+// Programs generated with Options.Force32Bit need to be built for this target.
+func CompatTarget(target *prog.Target) (*prog.Target, error) {
+	if target.PtrSize == 4 {
+		return target, nil
+	}
+	arch := compatArchs[target.OS][target.Arch]
+	if arch == "" {
+		return nil, fmt.Errorf("no 32-bit compat arch for %v/%v", target.OS, target.Arch)
+	}
+	return prog.GetTarget(target.OS, arch)
+}
+
+// convertTo32Bit converts program p to the corresponding 32-bit target.
+// Calls are matched by name, so syscall numbers, pointer and long sizes
+// are adjusted to the 32-bit ABI.
+func convertTo32Bit(p *prog.Prog) (*prog.Prog, error) {
+	target, err := CompatTarget(p.Target)
+	if err != nil {
+		return nil, err
+	}
+	if target == p.Target {
+		return p, nil
+	}
+	p32, err := target.Deserialize(p.Serialize())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert program to %v/%v: %v", target.OS, target.Arch, err)
+	}
+	return p32, nil
+}
@@ -6,40 +6,147 @@ package csource
 
 import (
 	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strings"
-	"unsafe"
+	"time"
 
+	"github.com/google/syzkaller/pkg/execfmt"
 	"github.com/google/syzkaller/prog"
 	"github.com/google/syzkaller/sys/targets"
 )
 
 type Options struct {
-	Threaded bool
-	Collide  bool
-	Repeat   bool
-	Procs    int
-	Sandbox  string
+	Threaded    bool
+	Collide     bool
+	Repeat      bool
+	RepeatTimes int // number of iterations for Repeat, 0 means infinite
+	Procs       int
+	Sandbox     string
+	// Syscalls (names as in descriptions) that fail with EPERM with Sandbox=seccomp.
+	SeccompDeny []string
 
 	Fault     bool // inject fault into FaultCall/FaultNth
 	FaultCall int
 	FaultNth  int
+	// Additional fault injection points besides FaultCall/FaultNth (requires Fault).
+	// Allows to reproduce bugs that require failures in several calls.
+	Faults []FaultPoint
+
+	// ThreadAssignment[i] is the thread that executes the i-th call in Threaded mode.
+	// Calls assigned to the same thread are executed sequentially in program order.
+	// If empty, each call is executed in a separate thread.
+	ThreadAssignment []int
 
 	// These options allow for a more fine-tuned control over the generated C code.
 	EnableTun  bool
+	EnableUSB  bool // emit syz_usb_* pseudo-syscalls that emulate USB devices
 	UseTmpDir  bool
 	HandleSegv bool
 	WaitRepeat bool
 	Debug      bool
+	Trace      bool // print every call with arguments and result to stderr
+
+	// How long to wait for each call in threaded mode (20ms by default) and how long
+	// a single program iteration can run with WaitRepeat before it is killed (5s by default).
+	// Should match the timeouts used for fuzzing on slow targets (see ipc.Config).
+	CallTimeout    time.Duration
+	ProgramTimeout time.Duration
+
+	// Stop repeating after RepeatTimeout (rounded up to seconds, requires Repeat and WaitRepeat):
+	// test processes are killed, temp dirs are unmounted and removed and the program exits
+	// with status 0, so that it can be scripted (e.g. in kernel CI).
+	RepeatTimeout time.Duration
+
+	// Move test processes into dedicated cpu, memory and pids cgroups
+	// and make cgroup hierarchies available inside of the sandbox (requires Sandbox=namespace).
+	EnableCgroups bool
+
+	// UID/GID of test processes inside of the user namespace created by Sandbox=namespace
+	// (0 by default, i.e. root in the namespace), they are mapped to the real UID/GID.
+	// Allows to reproduce permission-dependent bugs with the exact credentials.
+	SandboxUID int
+	SandboxGID int
+
+	// Bind test processes (and threads in Threaded mode) to CPUs round-robin,
+	// many race reproducers trigger only with a particular cross-CPU placement.
+	// CPUs lists the CPUs to use, all online CPUs are used if it is empty.
+	Affinity bool
+	CPUs     []int
+
+	// Collect KCOV coverage around each call and write covered kernel PCs
+	// to CoverFile in the current dir, so that it's visible what kernel code
+	// the program reaches without running it under the fuzzer.
+	Coverage bool
+
+	// Run the program under a watchdog process that monitors the kernel log and exits
+	// with WatchdogCrashStatus on kernel crashes/warnings and with WatchdogHangStatus
+	// on lockups/stalls, so that scripts can tell "crashed", "hung" and "nothing happened" apart.
+	Watchdog bool
+
+	// Apply resource limits of executor test processes (address space, locked memory,
+	// file size, stack, open files, no core dumps) first thing in main, i.e. also without
+	// Sandbox. Some bugs trigger only under these limits and don't reproduce standalone.
+	MatchExecutorEnv bool
+
+	// Address and size of the data area that pointer arguments point to. The area is mapped
+	// first thing in main and the program fails if it can't be mapped. Zero values mean
+	// Target.DataOffset and Target.DataSize. With a different DataOffset all addresses
+	// in the target data area (including integer values that happen to fall into it)
+	// are relocated, this allows to run programs on kernels with a different mmap_min_addr
+	// or with address space layout that conflicts with the default area.
+	DataOffset uint64
+	DataSize   uint64
+
+	// Set these sysctls (names in the dotted form, e.g. "kernel.panic_on_warn") first thing
+	// in main, so that the program runs with the same kernel settings as during fuzzing
+	// (see sysctl in manager config). Failures to set them are ignored.
+	Sysctls map[string]string
 
 	// Generate code for use with repro package to prints log messages,
 	// which allows to distinguish between a hang and an absent crash.
 	Repro bool
+
+	// Prune the common header in Go instead of invoking cpp,
+	// so that programs can be generated on machines without a C preprocessor.
+	Minimal bool
+
+	// Encode large incompressible data with base64 instead of \xNN escapes,
+	// this makes programs with large buffers several times smaller.
+	Base64Data bool
+
+	// Generate a program for the 32-bit compat ABI (e.g. linux/386 for linux/amd64 programs),
+	// the result needs to be built for CompatTarget.
+	Force32Bit bool
+
+	// Append the serialized program as a trailing comment,
+	// it can be extracted from the C source with ExtractProg.
+	EmbedProg bool
+}
+
+// repeatTimeoutGrace is how long (in seconds) programs generated with RepeatTimeout
+// are allowed to clean up before they are killed with SIGALRM.
+const repeatTimeoutGrace = 60
+
+// CoverFile is the file in the current dir where programs generated with Coverage
+// write covered kernel PCs, one hex PC per line.
+const CoverFile = "kcov.pcs"
+
+// Exit statuses of programs generated with Watchdog.
+// They don't clash with the statuses used by the common header (67-69).
+const (
+	WatchdogCrashStatus = 70
+	WatchdogHangStatus  = 71
+)
+
+// FaultPoint describes injection of a fault into Nth operation of the Call-th call.
+type FaultPoint struct {
+	Call int
+	Nth  int
 }
 
 // Check checks if the opts combination is valid or not.
@@ -50,10 +157,75 @@ func (opts Options) Check() error {
 		// Collide requires threaded.
 		return errors.New("Collide without Threaded")
 	}
+	if !opts.Fault && len(opts.Faults) != 0 {
+		return errors.New("Faults without Fault")
+	}
+	if opts.Fault {
+		calls := map[int]bool{opts.FaultCall: true}
+		for _, f := range opts.Faults {
+			if calls[f.Call] {
+				return fmt.Errorf("several fault points for call %v", f.Call)
+			}
+			calls[f.Call] = true
+		}
+	}
+	if opts.RepeatTimes < 0 {
+		return errors.New("negative RepeatTimes")
+	}
+	if opts.CallTimeout < 0 || opts.ProgramTimeout < 0 {
+		return errors.New("negative timeout")
+	}
+	if opts.RepeatTimeout < 0 {
+		return errors.New("negative RepeatTimeout")
+	}
+	if opts.RepeatTimeout != 0 && (!opts.Repeat || !opts.WaitRepeat) {
+		return errors.New("RepeatTimeout without Repeat and WaitRepeat")
+	}
+	if opts.ProgramTimeout != 0 && !opts.WaitRepeat {
+		// This does not affect generated code.
+		return errors.New("ProgramTimeout without WaitRepeat")
+	}
+	if !opts.Threaded && len(opts.ThreadAssignment) != 0 {
+		return errors.New("ThreadAssignment without Threaded")
+	}
+	for _, thread := range opts.ThreadAssignment {
+		if thread < 0 {
+			return errors.New("negative thread in ThreadAssignment")
+		}
+	}
+	if !opts.Repeat && opts.RepeatTimes != 0 {
+		// This does not affect generated code.
+		return errors.New("RepeatTimes without Repeat")
+	}
 	if !opts.Repeat && opts.Procs > 1 {
 		// This does not affect generated code.
 		return errors.New("Procs>1 without Repeat")
 	}
+	if opts.Sandbox != "seccomp" && len(opts.SeccompDeny) != 0 {
+		return errors.New("SeccompDeny without Sandbox=seccomp")
+	}
+	if opts.Sandbox != "namespace" && opts.EnableCgroups {
+		return errors.New("EnableCgroups without Sandbox=namespace")
+	}
+	if opts.SandboxUID < 0 || opts.SandboxGID < 0 {
+		return errors.New("negative SandboxUID/SandboxGID")
+	}
+	if opts.Sandbox != "namespace" && (opts.SandboxUID != 0 || opts.SandboxGID != 0) {
+		return errors.New("SandboxUID/SandboxGID without Sandbox=namespace")
+	}
+	if !opts.Affinity && len(opts.CPUs) != 0 {
+		return errors.New("CPUs without Affinity")
+	}
+	for _, cpu := range opts.CPUs {
+		if cpu < 0 {
+			return errors.New("negative CPU in CPUs")
+		}
+	}
+	for name, val := range opts.Sysctls {
+		if err := CheckSysctl(name, val); err != nil {
+			return err
+		}
+	}
 	if opts.Sandbox == "namespace" && !opts.UseTmpDir {
 		// This is borken and never worked.
 		// This tries to create syz-tmp dir in cwd,
@@ -63,64 +235,233 @@ func (opts Options) Check() error {
 	return nil
 }
 
+// netnsPerProc says if each proc needs own network namespace.
+// Tun devices are named per-proc anyway, but separate namespaces prevent procs
+// from interfering with each other and with the host network.
+func (opts Options) netnsPerProc() bool {
+	return opts.EnableTun && opts.Repeat && opts.Procs > 1
+}
+
+// repeatTimeoutSec returns RepeatTimeout rounded up to seconds.
+func (opts Options) repeatTimeoutSec() uint64 {
+	return uint64((opts.RepeatTimeout + time.Second - 1) / time.Second)
+}
+
+// faultNth returns the fault injection point for the call, if any.
+func (opts Options) faultNth(call int) (int, bool) {
+	if !opts.Fault {
+		return 0, false
+	}
+	if opts.FaultCall == call {
+		return opts.FaultNth, true
+	}
+	for _, f := range opts.Faults {
+		if f.Call == call {
+			return f.Nth, true
+		}
+	}
+	return 0, false
+}
+
 func Write(p *prog.Prog, opts Options) ([]byte, error) {
 	if err := opts.Check(); err != nil {
 		return nil, fmt.Errorf("csource: invalid opts: %v", err)
 	}
-	commonHeader := ""
-	switch p.Target.OS {
-	case "linux":
-		commonHeader = commonHeaderLinux
-	case "akaros":
-		commonHeader = commonHeaderAkaros
-	default:
-		return nil, fmt.Errorf("unsupported OS: %v", p.Target.OS)
+	origProg := p
+	if opts.Force32Bit {
+		var err error
+		if p, err = convertTo32Bit(p); err != nil {
+			return nil, err
+		}
+	}
+	sysTarget := targets.List[p.Target.OS][p.Target.Arch]
+	hdr, err := findCommonHeader(sysTarget)
+	if err != nil {
+		return nil, err
+	}
+	if !hdr.tun {
+		opts.EnableTun = false
+	}
+	if !hdr.rlimits {
+		opts.MatchExecutorEnv = false
+	}
+	if opts.Sandbox == "seccomp" && !hdr.seccomp {
+		return nil, fmt.Errorf("seccomp sandbox is not supported on %v", p.Target.OS)
+	}
+	if opts.Coverage && !hdr.cover {
+		return nil, fmt.Errorf("coverage is not supported on %v", p.Target.OS)
+	}
+	if opts.Affinity && !hdr.affinity {
+		return nil, fmt.Errorf("affinity is not supported on %v", p.Target.OS)
+	}
+	if opts.Watchdog && !hdr.watchdog {
+		return nil, fmt.Errorf("watchdog is not supported on %v", p.Target.OS)
+	}
+	if opts.RepeatTimeout != 0 && !hdr.repeatTimeout {
+		return nil, fmt.Errorf("repeat timeout is not supported on %v", p.Target.OS)
+	}
+	if len(opts.Sysctls) != 0 && !hdr.sysctls {
+		return nil, fmt.Errorf("sysctls are not supported on %v", p.Target.OS)
+	}
+	seccompDeny, err := SeccompDenyNumbers(p.Target, opts.SeccompDeny)
+	if err != nil {
+		return nil, err
 	}
 	ctx := &context{
-		p:         p,
-		opts:      opts,
-		target:    p.Target,
-		sysTarget: targets.List[p.Target.OS][p.Target.Arch],
-		w:         new(bytes.Buffer),
-		calls:     make(map[string]uint64),
+		p:          p,
+		opts:       opts,
+		target:     p.Target,
+		sysTarget:  sysTarget,
+		w:          new(bytes.Buffer),
+		calls:      make(map[string]uint64),
+		dataOffset: opts.DataOffset,
+		dataSize:   opts.DataSize,
+	}
+	if ctx.dataOffset == 0 {
+		ctx.dataOffset = p.Target.DataOffset
+	}
+	if ctx.dataSize == 0 {
+		ctx.dataSize = p.Target.DataSize()
+	}
+	if ctx.dataOffset%p.Target.PageSize != 0 || ctx.dataSize%p.Target.PageSize != 0 ||
+		ctx.dataOffset+ctx.dataSize < ctx.dataOffset {
+		return nil, fmt.Errorf("bad data area 0x%x/0x%x", ctx.dataOffset, ctx.dataSize)
+	}
+	if len(opts.ThreadAssignment) != 0 && len(opts.ThreadAssignment) != len(p.Calls) {
+		return nil, fmt.Errorf("ThreadAssignment has %v entries, but program has %v calls",
+			len(opts.ThreadAssignment), len(p.Calls))
+	}
+	if err := ctx.checkPseudoCalls(); err != nil {
+		return nil, err
 	}
 	for _, c := range p.Calls {
+		if !ctx.emitCall(c.Meta.CallName) {
+			// Don't pull in implementation of calls that are not emitted.
+			continue
+		}
 		ctx.calls[c.Meta.CallName] = c.Meta.NR
 	}
 
 	ctx.print("// autogenerated by syzkaller (http://github.com/google/syzkaller)\n\n")
+	ctx.printf("#define SYZ_DATA_OFFSET 0x%x\n", ctx.dataOffset)
+	ctx.printf("#define SYZ_DATA_SIZE 0x%x\n\n", ctx.dataSize)
+	if opts.RepeatTimes != 0 {
+		// This is used by loop in the common header, so must go before it.
+		ctx.printf("#define SYZ_REPEAT_TIMES %v\n\n", opts.RepeatTimes)
+	}
+	if seccompDeny != "" {
+		ctx.printf("#define SYZ_SECCOMP_DENY %v\n\n", seccompDeny)
+	}
+	if opts.Coverage {
+		ctx.printf("#define SYZ_COVER_FILE %q\n\n", CoverFile)
+	}
+	if opts.Watchdog {
+		ctx.printf("#define SYZ_WATCHDOG_CRASH_STATUS %v\n", WatchdogCrashStatus)
+		ctx.printf("#define SYZ_WATCHDOG_HANG_STATUS %v\n\n", WatchdogHangStatus)
+	}
+	if len(opts.CPUs) != 0 {
+		var cpus []string
+		for _, cpu := range opts.CPUs {
+			cpus = append(cpus, fmt.Sprint(cpu))
+		}
+		ctx.printf("#define SYZ_CPUS %v\n\n", strings.Join(cpus, ", "))
+	}
+	if len(opts.Sysctls) != 0 {
+		var names, sysctls []string
+		for name := range opts.Sysctls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sysctls = append(sysctls, fmt.Sprintf("{\"%v\", \"%v\"}", SysctlPath(name), opts.Sysctls[name]))
+		}
+		ctx.printf("#define SYZ_SYSCTLS %v\n\n", strings.Join(sysctls, ", "))
+	}
+	if opts.SandboxUID != 0 {
+		ctx.printf("#define SYZ_SANDBOX_UID %v\n\n", opts.SandboxUID)
+	}
+	if opts.SandboxGID != 0 {
+		ctx.printf("#define SYZ_SANDBOX_GID %v\n\n", opts.SandboxGID)
+	}
+	if opts.Repeat && opts.WaitRepeat {
+		programTimeout := opts.ProgramTimeout
+		if programTimeout == 0 {
+			programTimeout = 5 * time.Second
+		}
+		ctx.printf("#define SYZ_PROGRAM_TIMEOUT_MS %v\n\n", uint64(programTimeout/time.Millisecond))
+	}
+	if opts.RepeatTimeout != 0 {
+		ctx.printf("#define SYZ_REPEAT_TIMEOUT_SEC %v\n\n", opts.repeatTimeoutSec())
+	}
+
+	// Calls are generated before the common header is preprocessed,
+	// because they determine which helpers (e.g. base64_decode) are needed.
+	exec := make([]byte, prog.ExecBufferSize)
+	progSize, err := ctx.p.SerializeForExec(exec, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize program: %v", err)
+	}
+	decoded, err := execfmt.Decode(ctx.target, exec[:progSize])
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.checkDataArea(decoded); err != nil {
+		return nil, err
+	}
+	// The first pass finds results that are actually used by the emitted code
+	// (e.g. results consumed only by calls that are not emitted are dead),
+	// the second pass does not store the dead results.
+	_, _, used := ctx.generateCalls(decoded, nil)
+	calls, vars, _ := ctx.generateCalls(decoded, used)
+	ctx.vars = vars
 
-	hdr, err := ctx.preprocessCommonHeader(commonHeader)
+	text, err := ctx.preprocessCommonHeader(hdr.text)
 	if err != nil {
 		return nil, err
 	}
-	ctx.print(hdr)
+	ctx.print(text)
 	ctx.print("\n")
 
 	ctx.generateSyscallDefines()
 
-	exec := make([]byte, prog.ExecBufferSize)
-	progSize, err := ctx.p.SerializeForExec(exec, 0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to serialize program: %v", err)
+	// On 32-bit targets long can't hold 64-bit values that are copied out and then copied in.
+	varType := "long"
+	if p.Target.PtrSize == 4 {
+		varType = "uint64_t"
+	}
+	for _, v := range vars {
+		ctx.printf("%v %v;\n", varType, v)
+	}
+
+	// __WALL is required to wait for children created with clone on linux,
+	// but it does not exist on other OSes.
+	waitFlags := "0"
+	if p.Target.OS == "linux" {
+		waitFlags = "__WALL"
 	}
-	calls, nvar := ctx.generateCalls(exec[:progSize])
-	ctx.printf("long r[%v];\n", nvar)
 
 	if !opts.Repeat {
 		ctx.generateTestFunc(calls, "loop")
 
 		ctx.print("int main()\n{\n")
+		ctx.generateWatchdog()
+		ctx.generateExecutorEnv()
+		if opts.Coverage {
+			ctx.printf("\tcover_init();\n")
+		}
 		if opts.HandleSegv {
 			ctx.printf("\tinstall_segv_handler();\n")
 		}
 		if opts.UseTmpDir {
 			ctx.printf("\tuse_temporary_dir();\n")
 		}
+		if opts.Affinity {
+			ctx.printf("\tbind_to_cpu(0);\n")
+		}
 		if opts.Sandbox != "" {
 			ctx.printf("\tint pid = do_sandbox_%v(0, %v);\n", opts.Sandbox, opts.EnableTun)
 			ctx.print("\tint status = 0;\n")
-			ctx.print("\twhile (waitpid(pid, &status, __WALL) != pid) {}\n")
+			ctx.printf("\twhile (waitpid(pid, &status, %v) != pid) {}\n", waitFlags)
 		} else {
 			if opts.EnableTun {
 				ctx.printf("\tsetup_tun(0, %v);\n", opts.EnableTun)
@@ -132,48 +473,86 @@ func Write(p *prog.Prog, opts Options) ([]byte, error) {
 		ctx.generateTestFunc(calls, "test")
 		if opts.Procs <= 1 {
 			ctx.print("int main()\n{\n")
+			ctx.generateWatchdog()
+			ctx.generateExecutorEnv()
+			ctx.generateRepeatTimeout()
+			if opts.Coverage {
+				ctx.printf("\tcover_init();\n")
+			}
 			if opts.HandleSegv {
 				ctx.printf("\tinstall_segv_handler();\n")
 			}
 			if opts.UseTmpDir {
 				ctx.printf("\tuse_temporary_dir();\n")
 			}
+			if opts.Affinity {
+				ctx.printf("\tbind_to_cpu(0);\n")
+			}
 			if opts.Sandbox != "" {
 				ctx.printf("\tint pid = do_sandbox_%v(0, %v);\n", opts.Sandbox, opts.EnableTun)
 				ctx.print("\tint status = 0;\n")
-				ctx.print("\twhile (waitpid(pid, &status, __WALL) != pid) {}\n")
+				ctx.printf("\twhile (waitpid(pid, &status, %v) != pid) {}\n", waitFlags)
 			} else {
 				if opts.EnableTun {
 					ctx.printf("\tsetup_tun(0, %v);\n", opts.EnableTun)
 				}
 				ctx.print("\tloop();\n")
 			}
+			if opts.RepeatTimeout != 0 && opts.UseTmpDir {
+				ctx.print("\tremove_temporary_dir();\n")
+			}
 			ctx.print("\treturn 0;\n}\n")
 		} else {
 			ctx.print("int main()\n{\n")
+			ctx.generateWatchdog()
+			ctx.generateExecutorEnv()
+			ctx.generateRepeatTimeout()
+			if opts.Coverage {
+				// The coverage table and file are shared by all procs.
+				ctx.printf("\tcover_init();\n")
+			}
 			ctx.print("\tint i;")
 			ctx.printf("\tfor (i = 0; i < %v; i++) {\n", opts.Procs)
 			ctx.print("\t\tif (fork() == 0) {\n")
+			if opts.RepeatTimeout != 0 {
+				// Test processes die together with main if it's killed by the alarm.
+				ctx.print("\t\t\tprctl(PR_SET_PDEATHSIG, SIGKILL, 0, 0, 0);\n")
+			}
+			if opts.netnsPerProc() {
+				ctx.printf("\t\t\tsetup_net_namespace();\n")
+			}
 			if opts.HandleSegv {
 				ctx.printf("\t\t\tinstall_segv_handler();\n")
 			}
 			if opts.UseTmpDir {
 				ctx.printf("\t\t\tuse_temporary_dir();\n")
 			}
+			if opts.Affinity {
+				ctx.printf("\t\t\taffinity_base = i;\n")
+				ctx.printf("\t\t\tbind_to_cpu(0);\n")
+			}
 			if opts.Sandbox != "" {
 				ctx.printf("\t\t\tint pid = do_sandbox_%v(i, %v);\n", opts.Sandbox, opts.EnableTun)
 				ctx.print("\t\t\tint status = 0;\n")
-				ctx.print("\t\t\twhile (waitpid(pid, &status, __WALL) != pid) {}\n")
+				ctx.printf("\t\t\twhile (waitpid(pid, &status, %v) != pid) {}\n", waitFlags)
 			} else {
 				if opts.EnableTun {
 					ctx.printf("\t\t\tsetup_tun(i, %v);\n", opts.EnableTun)
 				}
 				ctx.print("\t\t\tloop();\n")
 			}
+			if opts.RepeatTimeout != 0 && opts.UseTmpDir {
+				ctx.print("\t\t\tremove_temporary_dir();\n")
+			}
 			ctx.print("\t\t\treturn 0;\n")
 			ctx.print("\t\t}\n")
 			ctx.print("\t}\n")
-			ctx.print("\tsleep(1000000);\n")
+			if opts.RepeatTimes != 0 || opts.RepeatTimeout != 0 {
+				ctx.print("\tint status = 0;\n")
+				ctx.print("\twhile (waitpid(-1, &status, 0) != -1) {}\n")
+			} else {
+				ctx.print("\tsleep(1000000);\n")
+			}
 			ctx.print("\treturn 0;\n}\n")
 		}
 	}
@@ -202,16 +581,27 @@ func Write(p *prog.Prog, opts Options) ([]byte, error) {
 		out1 = out2
 	}
 
+	if opts.EmbedProg {
+		embedded, err := embedProg(origProg)
+		if err != nil {
+			return nil, err
+		}
+		out1 = append(out1, embedded...)
+	}
 	return out1, nil
 }
 
 type context struct {
-	p         *prog.Prog
-	opts      Options
-	target    *prog.Target
-	sysTarget *targets.Target
-	w         *bytes.Buffer
-	calls     map[string]uint64 // CallName -> NR
+	p          *prog.Prog
+	opts       Options
+	target     *prog.Target
+	sysTarget  *targets.Target
+	w          *bytes.Buffer
+	calls      map[string]uint64 // CallName -> NR
+	vars       []string          // variables that hold call results
+	useBase64  bool              // the program needs base64_decode
+	dataOffset uint64            // Options.DataOffset or the target default
+	dataSize   uint64            // Options.DataSize or the target default
 }
 
 func (ctx *context) print(str string) {
@@ -222,6 +612,114 @@ func (ctx *context) printf(str string, args ...interface{}) {
 	ctx.print(fmt.Sprintf(str, args...))
 }
 
+// generateWatchdog starts the watchdog first thing in main,
+// so that everything else (including the RepeatTimeout alarm) happens in the test process.
+func (ctx *context) generateWatchdog() {
+	if ctx.opts.Watchdog {
+		ctx.print("\twatchdog_start();\n")
+	}
+}
+
+// relocate moves address v from the target data area into the data area at ctx.dataOffset.
+// Values outside of the target data area are returned as is.
+func (ctx *context) relocate(v uint64) uint64 {
+	if v >= ctx.target.DataOffset && v-ctx.target.DataOffset < ctx.target.DataSize() {
+		return v - ctx.target.DataOffset + ctx.dataOffset
+	}
+	return v
+}
+
+// checkDataArea checks that memory accessed by the program fits into the data area,
+// which can be smaller than the target data area if Options.DataSize is set.
+// Addresses passed to calls must point into the data area as well.
+func (ctx *context) checkDataArea(exec *execfmt.Prog) error {
+	check := func(addr, size uint64) error {
+		if addr < ctx.target.DataOffset || addr-ctx.target.DataOffset >= ctx.target.DataSize() {
+			return nil
+		}
+		if off := addr - ctx.target.DataOffset; off+size > ctx.dataSize {
+			return fmt.Errorf("program accesses 0x%x/0x%x outside of data area of size 0x%x",
+				off, size, ctx.dataSize)
+		}
+		return nil
+	}
+	for _, instr := range exec.Instrs {
+		var err error
+		switch instr := instr.(type) {
+		case *execfmt.Copyin:
+			switch arg := instr.Arg.(type) {
+			case *execfmt.ConstArg:
+				err = check(instr.Addr, arg.Size)
+			case *execfmt.ResultArg:
+				err = check(instr.Addr, arg.Size)
+			case *execfmt.DataArg:
+				err = check(instr.Addr, uint64(len(arg.Data)))
+			case *execfmt.CsumArg:
+				err = check(instr.Addr, arg.Size)
+				for _, chunk := range arg.Chunks {
+					if err == nil && chunk.Kind == prog.ExecArgCsumChunkData {
+						err = check(chunk.Value, chunk.Size)
+					}
+				}
+			}
+		case *execfmt.Copyout:
+			err = check(instr.Addr, instr.Size)
+		case *execfmt.Call:
+			for _, arg := range instr.Args {
+				if arg, ok := arg.(*execfmt.ConstArg); ok && err == nil {
+					err = check(arg.Value, 1)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateExecutorEnv applies sysctls and executor resource limits and maps the data area in main,
+// so that they are in effect for all test processes.
+func (ctx *context) generateExecutorEnv() {
+	if len(ctx.opts.Sysctls) != 0 {
+		ctx.print("\tsetup_sysctls();\n")
+	}
+	if ctx.opts.MatchExecutorEnv {
+		ctx.print("\tsetup_rlimits();\n")
+	}
+	// The data area is mapped after sysctls, as they may affect it (vm.mmap_min_addr).
+	ctx.print("\tsetup_data_mapping();\n")
+}
+
+// SysctlPath returns /proc/sys file for the sysctl name in the dotted form.
+func SysctlPath(name string) string {
+	return "/proc/sys/" + strings.Replace(name, ".", "/", -1)
+}
+
+var sysctlNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)+$`)
+
+// CheckSysctl checks that the sysctl name and value can be safely embedded
+// into C programs and shell commands.
+func CheckSysctl(name, val string) error {
+	if !sysctlNameRe.MatchString(name) {
+		return fmt.Errorf("bad sysctl name %q", name)
+	}
+	for _, c := range val {
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '\'' {
+			return fmt.Errorf("bad value %q for sysctl %v", val, name)
+		}
+	}
+	return nil
+}
+
+// generateRepeatTimeout emits a backstop alarm for RepeatTimeout: test processes stop
+// on their own after the timeout (see loop), the alarm kills the program if cleanup hangs.
+func (ctx *context) generateRepeatTimeout() {
+	if ctx.opts.RepeatTimeout != 0 {
+		ctx.printf("\talarm(%v);\n", ctx.opts.repeatTimeoutSec()+repeatTimeoutGrace)
+	}
+}
+
 func (ctx *context) generateTestFunc(calls []string, name string) {
 	opts := ctx.opts
 	if !opts.Threaded && !opts.Collide {
@@ -233,26 +731,97 @@ func (ctx *context) generateTestFunc(calls []string, name string) {
 		if opts.Repro {
 			ctx.printf("\tsyscall(SYS_write, 1, \"executing program\\n\", strlen(\"executing program\\n\"));\n")
 		}
-		ctx.printf("\tmemset(r, -1, sizeof(r));\n")
+		ctx.resetVars()
 		for _, c := range calls {
 			ctx.printf("%s", c)
 		}
 		ctx.printf("}\n\n")
 	} else {
-		ctx.printf("void *thr(void *arg)\n{\n")
-		ctx.printf("\tswitch ((long)arg) {\n")
-		for i, c := range calls {
+		// Calls are executed in worker threads and synchronized with events
+		// the same way the executor does it: each call is started in a free thread,
+		// then we wait for its completion for up to 20ms before starting the next call.
+		threads := ctx.threadCalls(calls)
+		ctx.printf("void execute_call(int call)\n{\n")
+		ctx.printf("\tswitch (call) {\n")
+		for i, thread := range threads {
 			ctx.printf("\tcase %v:\n", i)
-			ctx.printf("%s", strings.Replace(c, "\t", "\t\t", -1))
+			for _, c := range thread {
+				ctx.printf("%s", strings.Replace(c, "\t", "\t\t", -1))
+			}
 			ctx.printf("\t\tbreak;\n")
 		}
 		ctx.printf("\t}\n")
+		ctx.printf("}\n\n")
+
+		ctx.printf("struct thread_t {\n")
+		ctx.printf("\tint created, call;\n")
+		ctx.printf("\tstruct event_t ready, done;\n")
+		ctx.printf("};\n\n")
+		ctx.printf("static struct thread_t threads[16];\n")
+		ctx.printf("static int running;\n")
+		if opts.Collide {
+			ctx.printf("static int collide;\n")
+		}
+		ctx.printf("\n")
+
+		ctx.printf("static void* thr(void* arg)\n{\n")
+		ctx.printf("\tstruct thread_t* th = (struct thread_t*)arg;\n")
+		if opts.Affinity {
+			// The main thread is bound to the first CPU, worker threads take the next ones.
+			ctx.printf("\tbind_to_cpu(1 + (int)(th - threads));\n")
+		}
+		ctx.printf("\tfor (;;) {\n")
+		ctx.printf("\t\tevent_wait(&th->ready);\n")
+		ctx.printf("\t\tevent_reset(&th->ready);\n")
+		ctx.printf("\t\texecute_call(th->call);\n")
+		ctx.printf("\t\t__atomic_fetch_sub(&running, 1, __ATOMIC_RELAXED);\n")
+		ctx.printf("\t\tevent_set(&th->done);\n")
+		ctx.printf("\t}\n")
 		ctx.printf("\treturn 0;\n}\n\n")
 
+		// running is not reset here: with Collide threads started in the previous pass
+		// can still be running and will decrement it when they finish.
+		ctx.printf("static void execute(int num_calls)\n{\n")
+		ctx.printf("\tint call, thread, i;\n")
+		ctx.printf("\tfor (call = 0; call < num_calls; call++) {\n")
+		ctx.printf("\t\tfor (thread = 0; thread < (int)(sizeof(threads) / sizeof(threads[0])); thread++) {\n")
+		ctx.printf("\t\t\tstruct thread_t* th = &threads[thread];\n")
+		ctx.printf("\t\t\tif (!th->created) {\n")
+		ctx.printf("\t\t\t\tth->created = 1;\n")
+		ctx.printf("\t\t\t\tevent_init(&th->ready);\n")
+		ctx.printf("\t\t\t\tevent_init(&th->done);\n")
+		ctx.printf("\t\t\t\tevent_set(&th->done);\n")
+		ctx.printf("\t\t\t\tthread_start(thr, th);\n")
+		ctx.printf("\t\t\t}\n")
+		ctx.printf("\t\t\tif (!event_isset(&th->done))\n")
+		ctx.printf("\t\t\t\tcontinue;\n")
+		ctx.printf("\t\t\tevent_reset(&th->done);\n")
+		ctx.printf("\t\t\tth->call = call;\n")
+		ctx.printf("\t\t\t__atomic_fetch_add(&running, 1, __ATOMIC_RELAXED);\n")
+		ctx.printf("\t\t\tevent_set(&th->ready);\n")
+		if opts.Collide {
+			// Don't wait for every other call, this is what makes them collide.
+			ctx.printf("\t\t\tif (collide && call %% 2)\n")
+			ctx.printf("\t\t\t\tbreak;\n")
+		}
+		callTimeout := opts.CallTimeout
+		if callTimeout == 0 {
+			callTimeout = 20 * time.Millisecond
+		}
+		ctx.printf("\t\t\tevent_timedwait(&th->done, %v);\n", uint64(callTimeout/time.Millisecond))
+		ctx.printf("\t\t\tbreak;\n")
+		ctx.printf("\t\t}\n")
+		// All threads are blocked in previous calls, the executor fails in this case too.
+		ctx.printf("\t\tif (thread == (int)(sizeof(threads) / sizeof(threads[0])))\n")
+		ctx.printf("\t\t\tfail(\"out of threads\");\n")
+		ctx.printf("\t}\n")
+		// Give blocked calls some additional time to finish, they could have been
+		// just unblocked by the last call.
+		ctx.printf("\tfor (i = 0; i < 100 && __atomic_load_n(&running, __ATOMIC_RELAXED); i++)\n")
+		ctx.printf("\t\tsleep_ms(1);\n")
+		ctx.printf("}\n\n")
+
 		ctx.printf("void %v()\n{\n", name)
-		ctx.printf("\tlong i;\n")
-		ctx.printf("\tpthread_t th[%v];\n", 2*len(calls))
-		ctx.printf("\n")
 		if opts.Debug {
 			// Use debug to avoid: error: ‘debug’ defined but not used.
 			ctx.printf("\tdebug(\"%v\\n\");\n", name)
@@ -260,67 +829,124 @@ func (ctx *context) generateTestFunc(calls []string, name string) {
 		if opts.Repro {
 			ctx.printf("\tsyscall(SYS_write, 1, \"executing program\\n\", strlen(\"executing program\\n\"));\n")
 		}
-		ctx.printf("\tmemset(r, -1, sizeof(r));\n")
+		ctx.resetVars()
+		ctx.printf("\texecute(%v);\n", len(threads))
 		if opts.Collide {
-			ctx.printf("\tsrand(getpid());\n")
+			ctx.printf("\tcollide = 1;\n")
+			ctx.printf("\texecute(%v);\n", len(threads))
 		}
-		ctx.printf("\tfor (i = 0; i < %v; i++) {\n", len(calls))
-		ctx.printf("\t\tpthread_create(&th[i], 0, thr, (void*)i);\n")
-		ctx.printf("\t\tusleep(rand()%%10000);\n")
-		ctx.printf("\t}\n")
-		if opts.Collide {
-			ctx.printf("\tfor (i = 0; i < %v; i++) {\n", len(calls))
-			ctx.printf("\t\tpthread_create(&th[%v+i], 0, thr, (void*)i);\n", len(calls))
-			ctx.printf("\t\tif (rand()%%2)\n")
-			ctx.printf("\t\t\tusleep(rand()%%10000);\n")
-			ctx.printf("\t}\n")
-		}
-		ctx.printf("\tusleep(rand()%%100000);\n")
 		ctx.printf("}\n\n")
 	}
 }
 
+func (ctx *context) resetVars() {
+	for _, v := range ctx.vars {
+		ctx.printf("\t%v = -1;\n", v)
+	}
+}
+
+// threadCalls groups calls by threads they need to be executed in.
+// By default each call is executed in a separate thread.
+func (ctx *context) threadCalls(calls []string) [][]string {
+	if len(ctx.opts.ThreadAssignment) == 0 {
+		var threads [][]string
+		for _, c := range calls {
+			threads = append(threads, []string{c})
+		}
+		return threads
+	}
+	var threads [][]string
+	index := make(map[int]int)
+	for i, c := range calls {
+		id := ctx.opts.ThreadAssignment[i]
+		idx, ok := index[id]
+		if !ok {
+			idx = len(threads)
+			index[id] = idx
+			threads = append(threads, nil)
+		}
+		threads[idx] = append(threads[idx], c)
+	}
+	return threads
+}
+
+// sortedCalls returns names of calls used in the program in sorted order,
+// so that the generated program does not depend on map iteration order.
+func (ctx *context) sortedCalls() []string {
+	var names []string
+	for name := range ctx.calls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (ctx *context) generateSyscallDefines() {
 	prefix := ctx.sysTarget.SyscallPrefix
-	for name, nr := range ctx.calls {
-		if strings.HasPrefix(name, "syz_") || !ctx.sysTarget.NeedSyscallDefine(nr) {
+	for _, name := range ctx.sortedCalls() {
+		nr := ctx.calls[name]
+		if isPseudoCall(name) || !ctx.sysTarget.NeedSyscallDefine(nr) {
 			continue
 		}
 		ctx.printf("#ifndef %v%v\n", prefix, name)
 		ctx.printf("#define %v%v %v\n", prefix, name, nr)
 		ctx.printf("#endif\n")
 	}
-	if ctx.target.OS == "linux" && ctx.target.PtrSize == 4 {
-		// This is a dirty hack.
-		// On 32-bit linux mmap translated to old_mmap syscall which has a different signature.
-		// mmap2 has the right signature. executor translates mmap to mmap2, do the same here.
-		ctx.printf("#undef __NR_mmap\n")
-		ctx.printf("#define __NR_mmap __NR_mmap2\n")
+	// Executor does the same remapping (see syz-sysgen).
+	var remapped []string
+	for name := range ctx.sysTarget.SyscallRemap {
+		remapped = append(remapped, name)
+	}
+	sort.Strings(remapped)
+	for _, name := range remapped {
+		ctx.printf("#undef %v%v\n", prefix, name)
+		ctx.printf("#define %v%v %v%v\n", prefix, name, prefix, ctx.sysTarget.SyscallRemap[name])
 	}
 	ctx.printf("\n")
 }
 
-func (ctx *context) generateCalls(exec []byte) ([]string, int) {
-	read := func() uint64 {
-		if len(exec) < 8 {
-			panic("exec program overflow")
+// generateCalls returns C code for each call of the decoded program,
+// names of variables that hold results of the calls and the set of results
+// that are read by the emitted code. If live is not nil, only results
+// in live are stored into variables.
+func (ctx *context) generateCalls(exec *execfmt.Prog, live map[int]bool) ([]string, []string, map[int]bool) {
+	// Results are stored in variables named after resource types,
+	// so that it's easier to follow data flow in the program.
+	results := ctx.p.ExecResults()
+	var vars []string
+	varNames := make(map[int]string)
+	varName := func(idx, call int) string {
+		if name, ok := varNames[idx]; ok {
+			return name
 		}
-		v := *(*uint64)(unsafe.Pointer(&exec[0]))
-		exec = exec[8:]
-		return v
+		name := resultName(results[idx], ctx.p.Calls[call].Meta.CallName, idx)
+		varNames[idx] = name
+		vars = append(vars, name)
+		return name
+	}
+	used := make(map[int]bool)
+	isLive := func(idx int) bool {
+		return results[idx] != nil && (live == nil || live[idx])
 	}
-	resultRef := func() string {
-		arg := read()
-		res := fmt.Sprintf("r[%v]", arg)
-		if opDiv := read(); opDiv != 0 {
-			res = fmt.Sprintf("%v/%v", res, opDiv)
+	resultRef := func(arg *execfmt.ResultArg, emit bool) string {
+		if !emit {
+			return ""
 		}
-		if opAdd := read(); opAdd != 0 {
-			res = fmt.Sprintf("%v+%v", res, opAdd)
+		used[arg.Index] = true
+		res := varName(arg.Index, 0)
+		if arg.OpDiv != 0 {
+			res = fmt.Sprintf("%v/%v", res, arg.OpDiv)
+		}
+		if arg.OpAdd != 0 {
+			res = fmt.Sprintf("%v+%v", res, arg.OpAdd)
 		}
 		return res
 	}
+	// Original program lines are attached to the calls as comments
+	// to simplify manual editing of the resulting programs.
+	progLines := strings.Split(string(ctx.p.Serialize()), "\n")
 	lastCall := 0
+	lastCallStart := -1
 	seenCall := false
 	var calls []string
 	w := new(bytes.Buffer)
@@ -331,137 +957,250 @@ func (ctx *context) generateCalls(exec []byte) ([]string, int) {
 			w = new(bytes.Buffer)
 		}
 	}
-	n := 0
-loop:
-	for ; ; n++ {
-		switch instr := read(); instr {
-		case prog.ExecInstrEOF:
-			break loop
-		case prog.ExecInstrCopyin:
+	for n, instr := range exec.Instrs {
+		switch instr := instr.(type) {
+		case *execfmt.Copyin:
 			newCall()
-			addr := read()
-			typ := read()
-			size := read()
-			switch typ {
-			case prog.ExecArgConst:
-				arg := read()
-				bfOff := read()
-				bfLen := read()
-				if bfOff == 0 && bfLen == 0 {
-					fmt.Fprintf(w, "\tNONFAILING(*(uint%v_t*)0x%x = (uint%v_t)0x%x);\n", size*8, addr, size*8, arg)
+			addr := ctx.relocate(instr.Addr)
+			switch arg := instr.Arg.(type) {
+			case *execfmt.ConstArg:
+				size := arg.Size
+				if arg.BitfieldOffset == 0 && arg.BitfieldLength == 0 {
+					fmt.Fprintf(w, "\tNONFAILING(*(uint%v_t*)0x%x = (uint%v_t)0x%x);\n", size*8, addr, size*8, ctx.relocate(arg.Value))
 				} else {
-					fmt.Fprintf(w, "\tNONFAILING(STORE_BY_BITMASK(uint%v_t, 0x%x, 0x%x, %v, %v));\n", size*8, addr, arg, bfOff, bfLen)
-				}
-			case prog.ExecArgResult:
-				fmt.Fprintf(w, "\tNONFAILING(*(uint%v_t*)0x%x = %v);\n", size*8, addr, resultRef())
-			case prog.ExecArgData:
-				data := exec[:size]
-				exec = exec[(size+7)/8*8:]
-				var esc []byte
-				for _, v := range data {
-					hex := func(v byte) byte {
-						if v < 10 {
-							return '0' + v
-						}
-						return 'a' + v - 10
-					}
-					esc = append(esc, '\\', 'x', hex(v>>4), hex(v<<4>>4))
+					fmt.Fprintf(w, "\tNONFAILING(STORE_BY_BITMASK(uint%v_t, 0x%x, 0x%x, %v, %v));\n", size*8, addr, arg.Value, arg.BitfieldOffset, arg.BitfieldLength)
 				}
-				fmt.Fprintf(w, "\tNONFAILING(memcpy((void*)0x%x, \"%s\", %v));\n", addr, esc, size)
-			case prog.ExecArgCsum:
-				csum_kind := read()
-				switch csum_kind {
+			case *execfmt.ResultArg:
+				fmt.Fprintf(w, "\tNONFAILING(*(uint%v_t*)0x%x = %v);\n", arg.Size*8, addr, resultRef(arg, true))
+			case *execfmt.DataArg:
+				ctx.copyinData(w, addr, arg.Data)
+			case *execfmt.CsumArg:
+				var csum_name string
+				var csum_bits int
+				switch arg.Kind {
 				case prog.ExecArgCsumInet:
-					fmt.Fprintf(w, "\tstruct csum_inet csum_%d;\n", n)
-					fmt.Fprintf(w, "\tcsum_inet_init(&csum_%d);\n", n)
-					csumChunksNum := read()
-					for i := uint64(0); i < csumChunksNum; i++ {
-						chunk_kind := read()
-						chunk_value := read()
-						chunk_size := read()
-						switch chunk_kind {
-						case prog.ExecArgCsumChunkData:
-							fmt.Fprintf(w, "\tNONFAILING(csum_inet_update(&csum_%d, (const uint8_t*)0x%x, %d));\n", n, chunk_value, chunk_size)
-						case prog.ExecArgCsumChunkConst:
-							fmt.Fprintf(w, "\tuint%d_t csum_%d_chunk_%d = 0x%x;\n", chunk_size*8, n, i, chunk_value)
-							fmt.Fprintf(w, "\tcsum_inet_update(&csum_%d, (const uint8_t*)&csum_%d_chunk_%d, %d);\n", n, n, i, chunk_size)
-						default:
-							panic(fmt.Sprintf("unknown checksum chunk kind %v", chunk_kind))
-						}
-					}
-					fmt.Fprintf(w, "\tNONFAILING(*(uint16_t*)0x%x = csum_inet_digest(&csum_%d));\n", addr, n)
+					csum_name, csum_bits = "csum_inet", 16
+				case prog.ExecArgCsumCrc32c:
+					csum_name, csum_bits = "csum_crc32c", 32
 				default:
-					panic(fmt.Sprintf("unknown csum kind %v", csum_kind))
+					panic(fmt.Sprintf("unknown csum kind %v", arg.Kind))
+				}
+				fmt.Fprintf(w, "\tstruct %v csum_%d;\n", csum_name, n)
+				fmt.Fprintf(w, "\t%v_init(&csum_%d);\n", csum_name, n)
+				for i, chunk := range arg.Chunks {
+					switch chunk.Kind {
+					case prog.ExecArgCsumChunkData:
+						fmt.Fprintf(w, "\tNONFAILING(%v_update(&csum_%d, (const uint8_t*)0x%x, %d));\n", csum_name, n, ctx.relocate(chunk.Value), chunk.Size)
+					case prog.ExecArgCsumChunkConst:
+						fmt.Fprintf(w, "\tuint%d_t csum_%d_chunk_%d = 0x%x;\n", chunk.Size*8, n, i, chunk.Value)
+						fmt.Fprintf(w, "\t%v_update(&csum_%d, (const uint8_t*)&csum_%d_chunk_%d, %d);\n", csum_name, n, n, i, chunk.Size)
+					default:
+						panic(fmt.Sprintf("unknown checksum chunk kind %v", chunk.Kind))
+					}
 				}
+				fmt.Fprintf(w, "\tNONFAILING(*(uint%d_t*)0x%x = %v_digest(&csum_%d));\n", csum_bits, addr, csum_name, n)
 			default:
-				panic(fmt.Sprintf("bad argument type %v", instr))
+				panic(fmt.Sprintf("bad argument type %T", arg))
 			}
-		case prog.ExecInstrCopyout:
-			addr := read()
-			size := read()
-			fmt.Fprintf(w, "\tif (r[%v] != -1)\n", lastCall)
-			fmt.Fprintf(w, "\t\tNONFAILING(r[%v] = *(uint%v_t*)0x%x);\n", n, size*8, addr)
-		default:
-			// Normal syscall.
+		case *execfmt.Copyout:
+			if !isLive(n) {
+				// Nobody reads the copied out value.
+				break
+			}
+			call := len(calls)
+			if lastCallStart >= 0 {
+				// The call result is not used by other calls,
+				// but we still need it to check if the call has succeeded.
+				data := w.Bytes()
+				neww := new(bytes.Buffer)
+				neww.Write(data[:lastCallStart])
+				fmt.Fprintf(neww, "\t%v = ", varName(lastCall, call))
+				neww.Write(data[lastCallStart+1:])
+				w = neww
+				lastCallStart = -1
+			}
+			fmt.Fprintf(w, "\tif (%v != -1)\n", varName(lastCall, call))
+			fmt.Fprintf(w, "\t\tNONFAILING(%v = *(uint%v_t*)0x%x);\n", varName(n, call), instr.Size*8, ctx.relocate(instr.Addr))
+		case *execfmt.Call:
 			newCall()
-			if ctx.opts.Fault && ctx.opts.FaultCall == len(calls) {
+			meta := instr.Meta
+			emitCall := ctx.emitCall(meta.CallName)
+			if emitCall && ctx.opts.Coverage {
+				// Goes before fault injection, because the first reset in a thread opens kcov
+				// and that must not consume the injected fault.
+				fmt.Fprintf(w, "\tcover_reset();\n")
+			}
+			if nth, ok := ctx.opts.faultNth(len(calls)); ok {
 				fmt.Fprintf(w, "\twrite_file(\"/sys/kernel/debug/failslab/ignore-gfp-wait\", \"N\");\n")
 				fmt.Fprintf(w, "\twrite_file(\"/sys/kernel/debug/fail_futex/ignore-private\", \"N\");\n")
-				fmt.Fprintf(w, "\tinject_fault(%v);\n", ctx.opts.FaultNth)
-			}
-			meta := ctx.target.Syscalls[instr]
-			emitCall := true
-			if meta.CallName == "syz_test" {
-				emitCall = false
+				fmt.Fprintf(w, "\tinject_fault(%v);\n", nth)
 			}
-			if !ctx.opts.EnableTun && (meta.CallName == "syz_emit_ethernet" ||
-				meta.CallName == "syz_extract_tcp_res") {
-				emitCall = false
-			}
-			native := !strings.HasPrefix(meta.CallName, "syz_")
+			native := !isPseudoCall(meta.CallName)
+			lastCallStart = -1
+			var traceArgs []string
 			if emitCall {
+				fmt.Fprintf(w, "\t")
+				if isLive(n) || ctx.opts.Trace {
+					// Trace needs the result even if it's not used by other calls.
+					fmt.Fprintf(w, "%v = ", varName(n, len(calls)))
+				} else {
+					// Remember where the call starts in case we need to assign the result later.
+					lastCallStart = w.Len() - 1
+				}
 				if native {
-					fmt.Fprintf(w, "\tr[%v] = syscall(%v%v",
-						n, ctx.sysTarget.SyscallPrefix, meta.CallName)
+					fmt.Fprintf(w, "syscall(%v%v", ctx.sysTarget.SyscallPrefix, meta.CallName)
 				} else {
-					fmt.Fprintf(w, "\tr[%v] = %v(", n, meta.CallName)
+					fmt.Fprintf(w, "%v(", meta.CallName)
 				}
 			}
-			nargs := read()
-			for i := uint64(0); i < nargs; i++ {
-				typ := read()
-				size := read()
-				_ = size
+			for i, arg := range instr.Args {
 				if emitCall && (native || i > 0) {
 					fmt.Fprintf(w, ", ")
 				}
-				switch typ {
-				case prog.ExecArgConst:
-					value := read()
+				switch arg := arg.(type) {
+				case *execfmt.ConstArg:
+					// Bitfields can't be args of a normal syscall, so just ignore them.
+					value := ctx.relocate(arg.Value)
 					if emitCall {
 						fmt.Fprintf(w, "0x%xul", value)
+						traceArgs = append(traceArgs, fmt.Sprintf("0x%xul", value))
 					}
-					// Bitfields can't be args of a normal syscall, so just ignore them.
-					read() // bit field offset
-					read() // bit field length
-				case prog.ExecArgResult:
-					ref := resultRef()
+				case *execfmt.ResultArg:
+					ref := resultRef(arg, emitCall)
 					if emitCall {
+						if ctx.target.PtrSize == 4 {
+							// Variables are uint64_t, but syscall arguments are longs.
+							ref = fmt.Sprintf("(long)(%v)", ref)
+						}
 						fmt.Fprintf(w, "%v", ref)
+						traceArgs = append(traceArgs, fmt.Sprintf("(long)(%v)", ref))
 					}
 				default:
-					panic(fmt.Sprintf("unknown arg type %v", typ))
+					panic(fmt.Sprintf("unknown arg type %T", arg))
 				}
 			}
 			if emitCall {
-				fmt.Fprintf(w, ");\n")
+				fmt.Fprintf(w, ");")
+				if idx := len(calls); idx < len(progLines) {
+					fmt.Fprintf(w, " // %v", progLines[idx])
+				}
+				fmt.Fprintf(w, "\n")
+				if ctx.opts.Coverage {
+					fmt.Fprintf(w, "\tcover_collect();\n")
+				}
+				if ctx.opts.Trace {
+					used[n] = true
+					fmt.Fprintf(w, "\ttrace_call(%v, \"%v\", (long)%v, %v",
+						len(calls), meta.Name, varName(n, len(calls)), len(traceArgs))
+					for _, arg := range traceArgs {
+						fmt.Fprintf(w, ", %v", arg)
+					}
+					fmt.Fprintf(w, ");\n")
+				}
 			}
 			lastCall = n
 			seenCall = true
 		}
 	}
 	newCall()
-	return calls, n
+	return calls, vars, used
+}
+
+const (
+	// Runs of equal bytes of at least this size are emitted as memset.
+	memsetThreshold = 32
+	// Literal data of at least this size is emitted as base64 with Options.Base64Data.
+	base64Threshold = 128
+)
+
+// copyinData emits copying of data to addr. Long runs of equal bytes (e.g. zeros)
+// are emitted as memset, so that programs with large buffers stay small.
+func (ctx *context) copyinData(w *bytes.Buffer, addr uint64, data []byte) {
+	for len(data) != 0 {
+		lit := 0
+		for lit < len(data) {
+			run := runLength(data[lit:])
+			if run >= memsetThreshold {
+				break
+			}
+			lit += run
+		}
+		if lit != 0 {
+			ctx.copyinLiteral(w, addr, data[:lit])
+			addr += uint64(lit)
+			data = data[lit:]
+		}
+		if len(data) != 0 {
+			run := runLength(data)
+			fmt.Fprintf(w, "\tNONFAILING(memset((void*)0x%x, 0x%x, %v));\n", addr, data[0], run)
+			addr += uint64(run)
+			data = data[run:]
+		}
+	}
+}
+
+func (ctx *context) copyinLiteral(w *bytes.Buffer, addr uint64, data []byte) {
+	if ctx.opts.Base64Data && len(data) >= base64Threshold {
+		ctx.useBase64 = true
+		fmt.Fprintf(w, "\tNONFAILING(base64_decode((char*)0x%x, \"%v\"));\n",
+			addr, base64.StdEncoding.EncodeToString(data))
+		return
+	}
+	fmt.Fprintf(w, "\tNONFAILING(memcpy((void*)0x%x, \"%s\", %v));\n", addr, escapeData(data), len(data))
+}
+
+// runLength returns number of leading bytes in data equal to data[0].
+func runLength(data []byte) int {
+	n := 1
+	for n < len(data) && data[n] == data[0] {
+		n++
+	}
+	return n
+}
+
+// escapeData escapes data for a C string literal.
+func escapeData(data []byte) []byte {
+	var esc []byte
+	for _, v := range data {
+		hex := func(v byte) byte {
+			if v < 10 {
+				return '0' + v
+			}
+			return 'a' + v - 10
+		}
+		esc = append(esc, '\\', 'x', hex(v>>4), hex(v<<4>>4))
+	}
+	return esc
+}
+
+// resultName returns C variable name for the result produced by arg at instruction idx
+// of call callName. Resources are named after their kind (e.g. fd_sock3),
+// other results are named after the call (e.g. res_ioctl5).
+func resultName(arg prog.Arg, callName string, idx int) string {
+	if arg != nil {
+		if res, ok := arg.Type().(*prog.ResourceType); ok {
+			kind := res.Desc.Kind
+			name := kind[0]
+			if last := kind[len(kind)-1]; len(kind) > 1 {
+				if strings.HasPrefix(last, name+"_") {
+					name = last
+				} else {
+					name += "_" + last
+				}
+			}
+			return fmt.Sprintf("%v%v", identifier(name), idx)
+		}
+	}
+	return fmt.Sprintf("res_%v%v", identifier(callName), idx)
+}
+
+func identifier(name string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' {
+			return c
+		}
+		return '_'
+	}, name)
 }
 
 func (ctx *context) preprocessCommonHeader(commonHeader string) (string, error) {
@@ -469,9 +1208,13 @@ func (ctx *context) preprocessCommonHeader(commonHeader string) (string, error)
 	if prog.RequiresBitmasks(ctx.p) {
 		defines = append(defines, "SYZ_USE_BITMASKS")
 	}
-	if prog.RequiresChecksums(ctx.p) {
+	inetCsum, crc32cCsum := prog.RequiresChecksums(ctx.p)
+	if inetCsum {
 		defines = append(defines, "SYZ_USE_CHECKSUMS")
 	}
+	if crc32cCsum {
+		defines = append(defines, "SYZ_USE_CRC32C")
+	}
 	opts := ctx.opts
 	switch opts.Sandbox {
 	case "":
@@ -482,6 +1225,11 @@ func (ctx *context) preprocessCommonHeader(commonHeader string) (string, error)
 		defines = append(defines, "SYZ_SANDBOX_SETUID")
 	case "namespace":
 		defines = append(defines, "SYZ_SANDBOX_NAMESPACE")
+	case "seccomp":
+		defines = append(defines, "SYZ_SANDBOX_SECCOMP")
+		if len(opts.SeccompDeny) != 0 {
+			defines = append(defines, "SYZ_SECCOMP_DENY")
+		}
 	default:
 		return "", fmt.Errorf("unknown sandbox mode: %v", opts.Sandbox)
 	}
@@ -494,12 +1242,49 @@ func (ctx *context) preprocessCommonHeader(commonHeader string) (string, error)
 	if opts.Repeat {
 		defines = append(defines, "SYZ_REPEAT")
 	}
+	if opts.RepeatTimes != 0 {
+		defines = append(defines, "SYZ_REPEAT_TIMES")
+	}
+	if opts.RepeatTimeout != 0 {
+		defines = append(defines, "SYZ_REPEAT_TIMEOUT_SEC")
+	}
 	if opts.Fault {
 		defines = append(defines, "SYZ_FAULT_INJECTION")
 	}
 	if opts.EnableTun {
 		defines = append(defines, "SYZ_TUN_ENABLE")
 	}
+	if opts.netnsPerProc() {
+		defines = append(defines, "SYZ_TUN_NETNS")
+	}
+	if opts.EnableCgroups {
+		defines = append(defines, "SYZ_ENABLE_CGROUPS")
+	}
+	if opts.SandboxUID != 0 {
+		defines = append(defines, "SYZ_SANDBOX_UID")
+	}
+	if opts.SandboxGID != 0 {
+		defines = append(defines, "SYZ_SANDBOX_GID")
+	}
+	if opts.Coverage {
+		defines = append(defines, "SYZ_COVERAGE")
+	}
+	if opts.Affinity {
+		defines = append(defines, "SYZ_AFFINITY")
+	}
+	if opts.Watchdog {
+		defines = append(defines, "SYZ_WATCHDOG")
+	}
+	if opts.MatchExecutorEnv {
+		defines = append(defines, "SYZ_MATCH_EXECUTOR_ENV")
+	}
+	if len(opts.Sysctls) != 0 {
+		defines = append(defines, "SYZ_SYSCTLS")
+	}
+	defines = append(defines, "SYZ_DATA_OFFSET", "SYZ_DATA_SIZE")
+	if len(opts.CPUs) != 0 {
+		defines = append(defines, "SYZ_CPUS")
+	}
 	if opts.UseTmpDir {
 		defines = append(defines, "SYZ_USE_TMP_DIR")
 	}
@@ -512,11 +1297,20 @@ func (ctx *context) preprocessCommonHeader(commonHeader string) (string, error)
 	if opts.Debug {
 		defines = append(defines, "SYZ_DEBUG")
 	}
-	for name, _ := range ctx.calls {
+	if opts.Trace {
+		defines = append(defines, "SYZ_TRACE")
+	}
+	if ctx.useBase64 {
+		defines = append(defines, "SYZ_BASE64")
+	}
+	for _, name := range ctx.sortedCalls() {
 		defines = append(defines, "__NR_"+name)
 	}
 	defines = append(defines, ctx.sysTarget.CArch...)
 
+	if opts.Minimal {
+		return preprocess(commonHeader, defines)
+	}
 	cmd := exec.Command("cpp", "-nostdinc", "-undef", "-fdirectives-only", "-dDI", "-E", "-P", "-")
 	for _, def := range defines {
 		cmd.Args = append(cmd.Args, "-D"+def)
@@ -554,44 +1348,6 @@ func (ctx *context) preprocessCommonHeader(commonHeader string) (string, error)
 	return out, nil
 }
 
-// Build builds a C/C++ program from source src and returns name of the resulting binary.
-// lang can be "c" or "c++".
-func Build(target *prog.Target, lang, src string) (string, error) {
-	bin, err := ioutil.TempFile("", "syzkaller")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %v", err)
-	}
-	bin.Close()
-	sysTarget := targets.List[target.OS][target.Arch]
-	compiler := sysTarget.CCompilerPrefix + "gcc"
-	if _, err := exec.LookPath(compiler); err != nil {
-		return "", NoCompilerErr
-	}
-	flags := []string{
-		"-x", lang, "-Wall", "-Werror", "-O1", "-g", "-o", bin.Name(),
-		src, "-pthread",
-	}
-	flags = append(flags, sysTarget.CrossCFlags...)
-	if sysTarget.PtrSize == 4 {
-		// We do generate uint64's for syscall arguments that overflow longs on 32-bit archs.
-		flags = append(flags, "-Wno-overflow")
-	}
-	out, err := exec.Command(compiler, append(flags, "-static")...).CombinedOutput()
-	if err != nil {
-		// Some distributions don't have static libraries.
-		out, err = exec.Command(compiler, flags...).CombinedOutput()
-	}
-	if err != nil {
-		os.Remove(bin.Name())
-		data, _ := ioutil.ReadFile(src)
-		return "", fmt.Errorf("failed to build program:\n%s\n%s\ncompiler invocation: %v %v\n",
-			data, out, compiler, flags)
-	}
-	return bin.Name(), nil
-}
-
-var NoCompilerErr = errors.New("no target compiler")
-
 // Format reformats C source using clang-format.
 func Format(src []byte) ([]byte, error) {
 	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
@@ -4,17 +4,22 @@
 package csource
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"reflect"
+	"regexp"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/prog"
 	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/sys/targets"
 )
 
 func initTest(t *testing.T) (*prog.Target, rand.Source, int) {
@@ -36,19 +41,66 @@ func enumerateField(opt Options, field int) []Options {
 	fldName := s.Type().Field(field).Name
 	fld := s.Field(field)
 	if fldName == "Sandbox" {
-		for _, sandbox := range []string{"", "none", "setuid", "namespace"} {
+		for _, sandbox := range []string{"", "none", "setuid", "namespace", "seccomp"} {
 			fld.SetString(sandbox)
 			opts = append(opts, opt)
 		}
+	} else if fldName == "SeccompDeny" {
+		opts = append(opts, opt)
+		opt.SeccompDeny = []string{"getpid", "mmap"}
+		opts = append(opts, opt)
+	} else if fldName == "RepeatTimes" {
+		for _, times := range []int64{0, 3} {
+			fld.SetInt(times)
+			opts = append(opts, opt)
+		}
 	} else if fldName == "Procs" {
 		for _, procs := range []int64{1, 4} {
 			fld.SetInt(procs)
 			opts = append(opts, opt)
 		}
+	} else if fldName == "CallTimeout" {
+		for _, timeout := range []time.Duration{0, 100 * time.Millisecond} {
+			fld.SetInt(int64(timeout))
+			opts = append(opts, opt)
+		}
+	} else if fldName == "ProgramTimeout" {
+		for _, timeout := range []time.Duration{0, 10 * time.Second} {
+			fld.SetInt(int64(timeout))
+			opts = append(opts, opt)
+		}
+	} else if fldName == "RepeatTimeout" {
+		// Tested separately in TestRepeatTimeout.
+		opts = append(opts, opt)
 	} else if fldName == "FaultCall" {
 		opts = append(opts, opt)
 	} else if fldName == "FaultNth" {
 		opts = append(opts, opt)
+	} else if fldName == "Faults" {
+		opts = append(opts, opt)
+	} else if fldName == "ThreadAssignment" {
+		opts = append(opts, opt)
+	} else if fldName == "SandboxUID" || fldName == "SandboxGID" {
+		// Tested separately in TestSandboxUID.
+		opts = append(opts, opt)
+	} else if fldName == "Watchdog" {
+		// Tested separately in TestWatchdog.
+		opts = append(opts, opt)
+	} else if fldName == "MatchExecutorEnv" {
+		// Tested separately in TestMatchExecutorEnv.
+		opts = append(opts, opt)
+	} else if fldName == "Sysctls" {
+		// Tested separately in TestSysctls.
+		opts = append(opts, opt)
+	} else if fldName == "DataOffset" || fldName == "DataSize" {
+		// Tested separately in TestDataMapping.
+		opts = append(opts, opt)
+	} else if fldName == "CPUs" {
+		// Tested separately in TestAffinity.
+		opts = append(opts, opt)
+	} else if fldName == "Force32Bit" {
+		// Requires a 32-bit compiler, tested separately.
+		opts = append(opts, opt)
 	} else if fld.Kind() == reflect.Bool {
 		for _, v := range []bool{false, true} {
 			fld.SetBool(v)
@@ -75,17 +127,31 @@ func allOptionsSingle() []Options {
 	return opts
 }
 
-func allOptionsPermutations() []Options {
-	opts := []Options{Options{}}
+// randomOptions returns a random valid combination of options.
+// The full product of all fields is too large to test exhaustively
+// (and grows with every new field), so tests sample from it instead.
+func randomOptions(r *rand.Rand) Options {
 	fields := reflect.TypeOf(Options{}).NumField()
-	for i := 0; i < fields; i++ {
-		var newOpts []Options
-		for _, opt := range opts {
-			newOpts = append(newOpts, enumerateField(opt, i)...)
+retry:
+	for {
+		opt := Options{}
+		for i := 0; i < fields; i++ {
+			opts := enumerateField(opt, i)
+			if len(opts) == 0 {
+				continue retry
+			}
+			opt = opts[r.Intn(len(opts))]
 		}
-		opts = newOpts
+		return opt
 	}
-	return opts
+}
+
+// optionsSamples returns the number of random option combinations to test.
+func optionsSamples(short, full int) int {
+	if testing.Short() {
+		return short
+	}
+	return full
 }
 
 func TestOne(t *testing.T) {
@@ -135,14 +201,9 @@ func TestOptions(t *testing.T) {
 	syzProg := target.GenerateAllSyzProg(rs)
 	t.Logf("syz program:\n%s\n", syzProg.Serialize())
 	permutations := allOptionsSingle()
-	allPermutations := allOptionsPermutations()
-	if testing.Short() {
-		r := rand.New(rs)
-		for i := 0; i < 32; i++ {
-			permutations = append(permutations, allPermutations[r.Intn(len(allPermutations))])
-		}
-	} else {
-		permutations = allPermutations
+	r := rand.New(rs)
+	for i := optionsSamples(32, 512); i > 0; i-- {
+		permutations = append(permutations, randomOptions(r))
 	}
 	for i, opts := range permutations {
 		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
@@ -157,6 +218,71 @@ func TestOptions(t *testing.T) {
 	}
 }
 
+// TestOptionsAllTargets compiles random programs under a random subset of valid
+// option combinations for every target that has a compiler.
+func TestOptionsAllTargets(t *testing.T) {
+	t.Parallel()
+	seed := time.Now().UnixNano()
+	t.Logf("seed=%v", seed)
+	var permutations []Options
+	r := rand.New(rand.NewSource(seed))
+	for i := optionsSamples(8, 128); i > 0; i-- {
+		permutations = append(permutations, randomOptions(r))
+	}
+	for _, target := range prog.AllTargets() {
+		if target.OS != runtime.GOOS {
+			continue // we don't have cross-OS toolchains
+		}
+		target := target
+		t.Run(target.OS+"/"+target.Arch, func(t *testing.T) {
+			if target.OS == "linux" && target.Arch == "arm" {
+				t.Skip("broken") // see TestOne
+			}
+			if err := probeToolchain(target); err != nil {
+				t.Skip(err)
+			}
+			sysTarget := targets.List[target.OS][target.Arch]
+			hdr, err := findCommonHeader(sysTarget)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Parallel()
+			rs := rand.NewSource(seed)
+			syzProg := target.GenerateAllSyzProg(rs)
+			for i, opts := range permutations {
+				if opts.Sandbox == "seccomp" && !hdr.seccomp {
+					continue
+				}
+				opts := opts
+				t.Run(fmt.Sprint(i), func(t *testing.T) {
+					t.Logf("opts: %+v", opts)
+					testOne(t, target.Generate(rs, 10, nil), opts)
+					if len(syzProg.Calls) != 0 {
+						testOne(t, syzProg, opts)
+					}
+				})
+			}
+		})
+	}
+}
+
+// probeToolchain checks that we can build programs for the target
+// (e.g. the cross-compiler is installed and 32-bit libc is present for -m32).
+func probeToolchain(target *prog.Target) error {
+	src, err := osutil.WriteTempFile([]byte("#include <sys/syscall.h>\n#include <unistd.h>\n" +
+		"int main() { return syscall(SYS_getpid) == -1; }\n"))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(src)
+	bin, err := Build(target, "c", src)
+	if err != nil {
+		return fmt.Errorf("can't build for %v/%v: %v", target.OS, target.Arch, err)
+	}
+	os.Remove(bin)
+	return nil
+}
+
 func testOne(t *testing.T, p *prog.Prog, opts Options) {
 	src, err := Write(p, opts)
 	if err != nil {
@@ -175,7 +301,1140 @@ func testOne(t *testing.T, p *prog.Prog, opts Options) {
 	}
 	if err != nil {
 		t.Logf("program:\n%s\n", p.Serialize())
+		if buildErr, ok := err.(*BuildError); ok {
+			t.Logf("%s", buildErr.Report())
+		}
 		t.Fatalf("%v", err)
 	}
 	defer os.Remove(bin)
 }
+
+func TestCallComments(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte("r0 = getpid()\nclose(r0)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"// r0 = getpid()\n", "// close(r0)\n"} {
+		if !strings.Contains(string(src), line) {
+			t.Errorf("program does not contain %q:\n%s", line, src)
+		}
+	}
+}
+
+func TestPseudoCalls(t *testing.T) {
+	headers := make(map[string]string)
+	for _, target := range prog.AllTargets() {
+		if hdr, err := findCommonHeader(targets.List[target.OS][target.Arch]); err == nil {
+			headers[target.OS] = hdr.text
+		}
+	}
+	for os, calls := range pseudoCalls {
+		for name, pc := range calls {
+			if pc.noop {
+				continue
+			}
+			if !strings.Contains(headers[os], " "+name+"(") {
+				t.Errorf("%v: pseudo-syscall %v is not implemented in the common header", os, name)
+			}
+		}
+	}
+	for _, target := range prog.AllTargets() {
+		if headers[target.OS] == "" {
+			continue
+		}
+		for _, c := range target.Syscalls {
+			if !isPseudoCall(c.CallName) {
+				continue
+			}
+			if _, ok := pseudoCalls[target.OS][c.CallName]; !ok {
+				t.Errorf("%v: pseudo-syscall %v is not registered", target.OS, c.CallName)
+			}
+		}
+	}
+}
+
+func TestUnsupportedOS(t *testing.T) {
+	t.Parallel()
+	for _, target := range prog.AllTargets() {
+		if target.OS != "windows" {
+			continue
+		}
+		p := target.Generate(rand.NewSource(0), 1, nil)
+		_, err := Write(p, Options{})
+		if err == nil || !strings.Contains(err.Error(), "supported OSes: akaros, freebsd, linux") {
+			t.Fatalf("want unsupported OS error, got: %v", err)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	_, rs, _ := initTest(t)
+	// arm64 emits defines for all syscalls.
+	target, err := prog.GetTarget("linux", "arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := target.GenerateAllSyzProg(rs)
+	for i := 0; i < 5; i++ {
+		p1 := target.Generate(rs, 20, nil)
+		p.Calls = append(p.Calls, p1.Calls...)
+	}
+	opts := Options{Minimal: true}
+	src0, err := Write(p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		src, err := Write(p, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(src, src0) {
+			t.Fatalf("non-deterministic output:\n%s\n\nvs:\n%s", src0, src)
+		}
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	target, rs, _ := initTest(t)
+	p := target.Generate(rs, 5, nil)
+	opts := Options{Threaded: true, Repeat: true}
+	bc := NewBuildCache()
+	bin, err := bc.Build(p, opts)
+	if err == NoCompilerErr {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin1, err := bc.Build(p.Clone(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin != bin1 {
+		t.Fatalf("same program is built twice: %v and %v", bin, bin1)
+	}
+	opts.Collide = true
+	bin2, err := bc.Build(p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin == bin2 {
+		t.Fatalf("different options produced the same binary")
+	}
+	// Failures are not cached.
+	bad := Options{Collide: true}
+	if _, err := bc.Build(p, bad); err == nil {
+		t.Fatalf("no error for invalid options")
+	}
+	if len(bc.entries) != 2 {
+		t.Fatalf("cache has %v entries, want 2", len(bc.entries))
+	}
+	bc.Close()
+	for _, f := range []string{bin, bin2} {
+		if osutil.IsExist(f) {
+			t.Errorf("binary %v is not removed on Close", f)
+		}
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	out := []byte(`/tmp/syzkaller123: In function 'main':
+/tmp/syzkaller123:10:3: error: implicit declaration of function 'foo' [-Werror=implicit-function-declaration]
+   foo();
+   ^~~
+/tmp/syzkaller123:12:1: warning: control reaches end of non-void function [-Wreturn-type]
+cc1: all warnings being treated as errors
+`)
+	want := []Diagnostic{
+		{"/tmp/syzkaller123", 10, 3, "error",
+			"implicit declaration of function 'foo' [-Werror=implicit-function-declaration]"},
+		{"/tmp/syzkaller123", 12, 1, "warning", "control reaches end of non-void function [-Wreturn-type]"},
+	}
+	got := ParseDiagnostics(out)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	target, _, _ := initTest(t)
+	srcf, err := osutil.WriteTempFile([]byte("int main() { return foo; }\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(srcf)
+	_, err = BuildWithOpts(target, srcf, BuildOpts{})
+	if err == NoCompilerErr {
+		t.Skip(err)
+	}
+	buildErr, ok := err.(*BuildError)
+	if !ok {
+		t.Fatalf("want *BuildError, got %T: %v", err, err)
+	}
+	if len(buildErr.Diagnostics) == 0 || buildErr.Diagnostics[0].Line != 1 ||
+		buildErr.Diagnostics[0].Severity != "error" {
+		t.Fatalf("bad diagnostics: %+v\n%s", buildErr.Diagnostics, buildErr.Output)
+	}
+	if msg := buildErr.Error(); strings.Contains(msg, "return foo") || !strings.Contains(msg, "'foo'") {
+		t.Errorf("bad error message: %v", msg)
+	}
+	if report := buildErr.Report(); !bytes.Contains(report, []byte("return foo")) {
+		t.Errorf("report does not contain source:\n%s", report)
+	}
+}
+
+func TestMultipleFaults(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getpid()\ngetpid()\ngetpid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{
+		Fault:     true,
+		FaultCall: 0,
+		FaultNth:  3,
+		Faults:    []FaultPoint{{Call: 2, Nth: 5}},
+	}
+	src, err := Write(p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"inject_fault(3);", "inject_fault(5);"} {
+		if !strings.Contains(string(src), line) {
+			t.Errorf("program does not contain %q:\n%s", line, src)
+		}
+	}
+	if n := strings.Count(string(src), "inject_fault("); n != 3 {
+		t.Errorf("want 2 inject_fault calls + definition, got %v:\n%s", n, src)
+	}
+	opts.Faults = append(opts.Faults, FaultPoint{Call: 0, Nth: 1})
+	if err := opts.Check(); err == nil {
+		t.Errorf("duplicate fault point is not detected")
+	}
+}
+
+func TestThreadAssignment(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getpid()\ngetuid()\ngetgid()\ngettid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{
+		Threaded:         true,
+		Collide:          true,
+		ThreadAssignment: []int{5, 0, 5, 1},
+	}
+	src, err := Write(p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// getpid and getgid must go to the same thread.
+	if !regexp.MustCompile(`case 0:\n[^\n]*getpid[^\n]*\n[^\n]*getgid`).Match(src) {
+		t.Errorf("bad thread assignment:\n%s", src)
+	}
+	if n := strings.Count(string(src), "execute(3);"); n != 2 {
+		t.Errorf("want 3 thread groups executed twice (with collide), got %v:\n%s", n, src)
+	}
+	opts.ThreadAssignment = opts.ThreadAssignment[:3]
+	if _, err := Write(p, opts); err == nil {
+		t.Errorf("bad ThreadAssignment length is not detected")
+	}
+	srcf, err := osutil.WriteTempFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(srcf)
+	bin, err := Build(p.Target, "c", srcf)
+	if err == NoCompilerErr {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(bin)
+}
+
+func TestThreadedRun(t *testing.T) {
+	target, _, _ := initTest(t)
+	if target.OS != runtime.GOOS || target.Arch != runtime.GOARCH {
+		t.Skip("can't run programs for non-host target")
+	}
+	// The second call blocks forever, the rest must still be executed in order
+	// and the program must finish.
+	p, err := target.Deserialize([]byte("getpid()\npause()\ngetuid()\ngetgid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{
+		Threaded: true,
+		Collide:  true,
+		Repro:    true,
+	}
+	src, err := Write(p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcf, err := osutil.WriteTempFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(srcf)
+	bin, err := Build(p.Target, "c", srcf)
+	if err == NoCompilerErr {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(bin)
+	out, err := osutil.RunCmd(time.Minute, "", bin)
+	if err != nil {
+		t.Fatalf("program failed: %v\n%s", err, out)
+	}
+}
+
+func TestChecksumRun(t *testing.T) {
+	target, _, _ := initTest(t)
+	if target.OS != runtime.GOOS || target.Arch != runtime.GOARCH {
+		t.Skip("can't run programs for non-host target")
+	}
+	// The packet is written to stdout after the crc32c checksum is filled in.
+	p, err := target.Deserialize([]byte(`
+mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)
+syz_test$csum_sctp(&(0x7f0000000000)={0x1, 0x2, 0x0, "aabb"})
+write(0x1, &(0x7f0000000000)="", 0xe)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(src, []byte("csum_crc32c_digest(")) {
+		t.Fatalf("no crc32c checksum in the program:\n%s", src)
+	}
+	srcf, err := osutil.WriteTempFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(srcf)
+	bin, err := Build(p.Target, "c", srcf)
+	if err == NoCompilerErr {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(bin)
+	out, err := osutil.RunCmd(time.Minute, "", bin)
+	if err != nil {
+		t.Fatalf("program failed: %v\n%s", err, out)
+	}
+	want := "\x00\x00\x00\x01\x00\x00\x00\x02\xff\xaa\x99\x24\xaa\xbb"
+	if string(out) != want {
+		t.Fatalf("got packet %q, want %q\n%s", out, want, src)
+	}
+}
+
+func TestRepeatTimeout(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getpid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{
+		Threaded:      true,
+		Repeat:        true,
+		Procs:         2,
+		Sandbox:       "none",
+		UseTmpDir:     true,
+		WaitRepeat:    true,
+		RepeatTimeout: time.Second,
+	}
+	src, err := Write(p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcf, err := osutil.WriteTempFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(srcf)
+	bin, err := Build(p.Target, "c", srcf)
+	if err == NoCompilerErr {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(bin)
+	dir, err := ioutil.TempDir("", "syz-csource-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Now()
+	out, err := osutil.RunCmd(time.Minute, dir, bin)
+	if err != nil {
+		t.Fatalf("program failed: %v\n%s", err, out)
+	}
+	if took := time.Since(start); took > 30*time.Second {
+		t.Fatalf("program ran for %v", took)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		t.Errorf("temp dir is not removed: %v", f.Name())
+	}
+	if _, err := Write(p, Options{Repeat: true, RepeatTimeout: time.Second}); err == nil {
+		t.Errorf("no error for RepeatTimeout without WaitRepeat")
+	}
+}
+
+func TestSeccompDeny(t *testing.T) {
+	target, _, _ := initTest(t)
+	nrs, err := SeccompDenyNumbers(target, []string{"getpid", "getuid", "getpid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getpid, getuid := target.SyscallMap["getpid"].NR, target.SyscallMap["getuid"].NR
+	if getpid > getuid {
+		getpid, getuid = getuid, getpid
+	}
+	if want := fmt.Sprintf("%v,%v", getpid, getuid); nrs != want {
+		t.Fatalf("got %q, want %q", nrs, want)
+	}
+	for _, name := range []string{"syz_open_dev", "nonexistent"} {
+		if _, err := SeccompDenyNumbers(target, []string{name}); err == nil {
+			t.Errorf("no error for %v", name)
+		}
+	}
+	if err := (Options{Sandbox: "none", SeccompDeny: []string{"getpid"}}).Check(); err == nil {
+		t.Errorf("SeccompDeny without seccomp sandbox is not detected")
+	}
+}
+
+func TestResultNames(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte(`r0 = socket$inet_tcp(0x2, 0x1, 0x0)
+pipe(&(0x7f0000000000)={<r1=>0xffffffffffffffff, <r2=>0xffffffffffffffff})
+close(r0)
+close(r1)
+dup(r2)
+getpid()
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"long fd_sock_tcp0;",
+		"fd_sock_tcp0 = syscall(__NR_socket",
+		"res_pipe1 = syscall(__NR_pipe",
+		"if (res_pipe1 != -1)",
+		"fd2 = *(uint32_t*)0x20000000;",
+		"syscall(__NR_close, fd_sock_tcp0);",
+		"syscall(__NR_dup, fd3);",
+		"\tsyscall(__NR_getpid);",
+	} {
+		if !strings.Contains(string(src), line) {
+			t.Errorf("program does not contain %q:\n%s", line, src)
+		}
+	}
+	if strings.Contains(string(src), "r[") {
+		t.Errorf("program uses r[]:\n%s", src)
+	}
+}
+
+func TestSyscallRemap(t *testing.T) {
+	t.Parallel()
+	for _, target := range prog.AllTargets() {
+		if target.OS != "linux" {
+			continue
+		}
+		sysTarget := targets.List[target.OS][target.Arch]
+		p, err := target.Deserialize([]byte("mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		src, err := Write(p, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		remapped := strings.Contains(string(src), "#define __NR_mmap __NR_mmap2\n")
+		if want := sysTarget.SyscallRemap["mmap"] == "mmap2"; remapped != want {
+			t.Errorf("%v/%v: mmap remapped=%v, want %v", target.OS, target.Arch, remapped, want)
+		}
+	}
+	for _, arch := range []string{"386", "arm"} {
+		if targets.List["linux"][arch].SyscallRemap["mmap"] != "mmap2" {
+			t.Errorf("linux/%v: mmap is not remapped to mmap2", arch)
+		}
+	}
+}
+
+func TestForce32Bit(t *testing.T) {
+	t.Parallel()
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte(`mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)
+r0 = socket$inet_tcp(0x2, 0x1, 0x0)
+close(r0)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Write(p, Options{Force32Bit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"#define __NR_mmap __NR_mmap2",
+		"uint64_t fd_sock_tcp1;",
+		"syscall(__NR_close, (long)(fd_sock_tcp1));",
+	} {
+		if !strings.Contains(string(src), line) {
+			t.Errorf("program does not contain %q:\n%s", line, src)
+		}
+	}
+	compat, err := CompatTarget(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compat.Arch != "386" {
+		t.Fatalf("compat target for amd64 is %v", compat.Arch)
+	}
+	ppc, err := prog.GetTarget("linux", "ppc64le")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CompatTarget(ppc); err == nil {
+		t.Errorf("no error for ppc64le compat target")
+	}
+}
+
+func TestEnableUSB(t *testing.T) {
+	target, rs, _ := initTest(t)
+	p := target.GenerateAllSyzProg(rs)
+	for _, enable := range []bool{false, true} {
+		opts := Options{Threaded: true, EnableUSB: enable}
+		src, err := Write(p, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(src), "syz_usb_connect("); got != enable {
+			t.Errorf("EnableUSB=%v, but syz_usb_connect emitted=%v", enable, got)
+		}
+		if enable {
+			testOne(t, p, opts)
+		}
+	}
+}
+
+func TestNetNamespacePerProc(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte("socket$inet_tcp(0x2, 0x1, 0x0)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, procs := range []int{1, 4} {
+		opts := Options{Repeat: true, Procs: procs, Sandbox: "none", EnableTun: true}
+		src, err := Write(p, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := procs > 1
+		if got := strings.Contains(string(src), "\tsetup_net_namespace();\n"); got != want {
+			t.Errorf("procs=%v: net namespace setup emitted=%v, want %v", procs, got, want)
+		}
+		testOne(t, p, opts)
+	}
+}
+
+func TestEnableCgroups(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getpid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, enable := range []bool{false, true} {
+		opts := Options{Repeat: true, Procs: 2, Sandbox: "namespace", UseTmpDir: true, EnableCgroups: enable}
+		src, err := Write(p, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(src), "\tsetup_cgroups(executor_pid);\n"); got != enable {
+			t.Errorf("EnableCgroups=%v, but cgroup setup emitted=%v", enable, got)
+		}
+		testOne(t, p, opts)
+	}
+	if _, err := Write(p, Options{Sandbox: "none", EnableCgroups: true}); err == nil {
+		t.Errorf("no error for EnableCgroups without Sandbox=namespace")
+	}
+}
+
+func TestSandboxUID(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getuid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Repeat: true, Procs: 1, Sandbox: "namespace", UseTmpDir: true, SandboxUID: 1000, SandboxGID: 100}
+	src, err := Write(p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, def := range []string{"#define SYZ_SANDBOX_UID 1000\n", "#define SYZ_SANDBOX_GID 100\n"} {
+		if !strings.Contains(string(src), def) {
+			t.Errorf("%q is not emitted", def)
+		}
+	}
+	testOne(t, p, opts)
+	if _, err := Write(p, Options{Sandbox: "setuid", SandboxUID: 1000}); err == nil {
+		t.Errorf("no error for SandboxUID without Sandbox=namespace")
+	}
+}
+
+func TestAffinity(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getpid()\nclose(0xffffffffffffffff)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []Options{
+		{Affinity: true},
+		{Affinity: true, CPUs: []int{0}, Threaded: true, Collide: true},
+		{Affinity: true, CPUs: []int{1, 0}, Threaded: true, Repeat: true, Procs: 3, Sandbox: "none"},
+	} {
+		src, err := Write(p, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(src), "bind_to_cpu(0);\n") {
+			t.Errorf("opts %+v: bind_to_cpu is not called", opts)
+		}
+		if got := strings.Contains(string(src), "bind_to_cpu(1 + "); got != opts.Threaded {
+			t.Errorf("opts %+v: threads are bound=%v", opts, got)
+		}
+		if len(opts.CPUs) != 0 && !strings.Contains(string(src), "#define SYZ_CPUS 1, 0\n") &&
+			!strings.Contains(string(src), "#define SYZ_CPUS 0\n") {
+			t.Errorf("opts %+v: SYZ_CPUS is not defined", opts)
+		}
+		testOne(t, p, opts)
+	}
+	if _, err := Write(p, Options{CPUs: []int{0}}); err == nil {
+		t.Errorf("no error for CPUs without Affinity")
+	}
+	if _, err := Write(p, Options{Affinity: true, CPUs: []int{-1}}); err == nil {
+		t.Errorf("no error for negative CPU")
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	target, rs, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getpid()\nclose(0xffffffffffffffff)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []Options{
+		{Coverage: true},
+		{Coverage: true, Threaded: true, Repeat: true, Procs: 2, Sandbox: "none", Fault: true, FaultCall: 1},
+	} {
+		src, err := Write(p, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, re := range []string{
+			`#define SYZ_COVER_FILE "kcov.pcs"`,
+			`\tcover_init\(\);\n`,
+			`\t+cover_reset\(\);\n(\t+.*\n)*\t+.*getpid.*\n\t+cover_collect\(\);\n`,
+			`\t+cover_reset\(\);\n(\t+.*\n)*\t+.*close.*\n\t+cover_collect\(\);\n`,
+		} {
+			if !regexp.MustCompile(re).Match(src) {
+				t.Errorf("opts %+v: output does not match %q:\n%s", opts, re, src)
+			}
+		}
+		testOne(t, p, opts)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(src, []byte("cover_")) {
+		t.Errorf("coverage code emitted without Coverage:\n%s", src)
+	}
+	akaros, err := prog.GetTarget("akaros", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Write(akaros.Generate(rs, 1, nil), Options{Coverage: true}); err == nil {
+		t.Errorf("no error for Coverage on akaros")
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	target, rs, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getpid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []Options{
+		{Watchdog: true},
+		{Watchdog: true, Threaded: true, Repeat: true, Procs: 2, Sandbox: "none", WaitRepeat: true,
+			RepeatTimeout: 10 * time.Second},
+	} {
+		src, err := Write(p, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, re := range []string{
+			`#define SYZ_WATCHDOG_CRASH_STATUS 70\n`,
+			`#define SYZ_WATCHDOG_HANG_STATUS 71\n`,
+			`int main\(\)\n{\n\twatchdog_start\(\);\n`,
+		} {
+			if !regexp.MustCompile(re).Match(src) {
+				t.Errorf("opts %+v: output does not match %q:\n%s", opts, re, src)
+			}
+		}
+		testOne(t, p, opts)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(src, []byte("watchdog")) {
+		t.Errorf("watchdog code emitted without Watchdog:\n%s", src)
+	}
+	akaros, err := prog.GetTarget("akaros", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Write(akaros.Generate(rs, 1, nil), Options{Watchdog: true}); err == nil {
+		t.Errorf("no error for Watchdog on akaros")
+	}
+}
+
+func TestMatchExecutorEnv(t *testing.T) {
+	target, rs, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getpid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []Options{
+		{MatchExecutorEnv: true},
+		{MatchExecutorEnv: true, Watchdog: true},
+		{MatchExecutorEnv: true, Threaded: true, Repeat: true, Procs: 2, Sandbox: "setuid", WaitRepeat: true},
+	} {
+		src, err := Write(p, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, re := range []string{
+			`static void setup_rlimits\(\)`,
+			`setrlimit\(RLIMIT_NOFILE, &rlim\);`,
+			`int main\(\)\n{\n(?:\twatchdog_start\(\);\n)?\tsetup_rlimits\(\);\n`,
+		} {
+			if !regexp.MustCompile(re).Match(src) {
+				t.Errorf("opts %+v: output does not match %q:\n%s", opts, re, src)
+			}
+		}
+		testOne(t, p, opts)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(src, []byte("setrlimit")) {
+		t.Errorf("resource limits emitted without MatchExecutorEnv and Sandbox:\n%s", src)
+	}
+	akaros, err := prog.GetTarget("akaros", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err = Write(akaros.Generate(rs, 1, nil), Options{MatchExecutorEnv: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(src, []byte("setup_rlimits")) {
+		t.Errorf("resource limits emitted on akaros:\n%s", src)
+	}
+}
+
+func TestSysctls(t *testing.T) {
+	target, rs, _ := initTest(t)
+	p, err := target.Deserialize([]byte("getpid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sysctls := map[string]string{
+		"kernel.panic_on_warn":    "1",
+		"net.core.bpf_jit_enable": "2",
+	}
+	for _, opts := range []Options{
+		{Sysctls: sysctls},
+		{Sysctls: sysctls, MatchExecutorEnv: true},
+		{Sysctls: sysctls, Threaded: true, Repeat: true, Procs: 2, Sandbox: "none", WaitRepeat: true},
+	} {
+		src, err := Write(p, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, re := range []string{
+			`#define SYZ_SYSCTLS {"/proc/sys/kernel/panic_on_warn", "1"}, {"/proc/sys/net/core/bpf_jit_enable", "2"}\n`,
+			`static void setup_sysctls\(\)`,
+			`int main\(\)\n{\n\tsetup_sysctls\(\);\n`,
+		} {
+			if !regexp.MustCompile(re).Match(src) {
+				t.Errorf("opts %+v: output does not match %q:\n%s", opts, re, src)
+			}
+		}
+		testOne(t, p, opts)
+	}
+	for _, bad := range []map[string]string{
+		{"kernel": "1"},
+		{"kernel/panic_on_warn": "1"},
+		{"kernel.panic_on_warn": "1\"); system(\"id"},
+		{"kernel.core_pattern": "|/bin/sh\n"},
+	} {
+		if _, err := Write(p, Options{Sysctls: bad}); err == nil {
+			t.Errorf("no error for sysctls %q", bad)
+		}
+	}
+	akaros, err := prog.GetTarget("akaros", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Write(akaros.Generate(rs, 1, nil), Options{Sysctls: sysctls}); err == nil {
+		t.Errorf("no error for Sysctls on akaros")
+	}
+}
+
+func TestDataMapping(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte(`pipe(&(0x7f0000000000)={<r0=>0xffffffffffffffff, <r1=>0xffffffffffffffff})
+write(r1, &(0x7f0000000000+0x40)="01020304", 0x4)
+read(r0, &(0x7f0000001000)="", 0x20000040)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, re := range []string{
+		fmt.Sprintf(`#define SYZ_DATA_OFFSET 0x%x\n#define SYZ_DATA_SIZE 0x%x\n`, target.DataOffset, target.DataSize()),
+		`\tsetup_data_mapping\(\);\n`,
+		`, 0x20000040ul\)`,
+	} {
+		if !regexp.MustCompile(re).Match(src) {
+			t.Errorf("output does not match %q:\n%s", re, src)
+		}
+	}
+	opts := Options{DataOffset: 0x40000000, DataSize: 2 << 20}
+	src, err = Write(p, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, re := range []string{
+		`#define SYZ_DATA_OFFSET 0x40000000\n#define SYZ_DATA_SIZE 0x200000\n`,
+		`\(__NR_pipe, 0x40000000ul\)`,
+		`memcpy\(\(void\*\)0x40000040, "\\x01\\x02\\x03\\x04", 4\)`,
+		`, 0x40001000ul, 0x40000040ul\)`,
+	} {
+		if !regexp.MustCompile(re).Match(src) {
+			t.Errorf("output does not match %q:\n%s", re, src)
+		}
+	}
+	// Call comments contain the original program, the code must not use the default area.
+	code := regexp.MustCompile(`//.*`).ReplaceAll(src, nil)
+	if regexp.MustCompile(`0x20000[0-9a-f]{3}\b`).Match(code) {
+		t.Errorf("output contains addresses from the default data area:\n%s", src)
+	}
+	testOne(t, p, opts)
+	for _, bad := range []Options{
+		{DataOffset: 0x40000001},
+		{DataSize: 100},
+		{DataOffset: ^uint64(0) - 0xfff},
+		// The program does not fit into a single page.
+		{DataOffset: 0x40000000, DataSize: 0x1000},
+	} {
+		if _, err := Write(p, bad); err == nil {
+			t.Errorf("no error for data area 0x%x/0x%x", bad.DataOffset, bad.DataSize)
+		}
+	}
+}
+
+func TestEmbedProg(t *testing.T) {
+	target, rs, iters := initTest(t)
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, nil)
+		src, err := Write(p, Options{EmbedProg: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, ok := ExtractProg(src)
+		if !ok {
+			t.Fatalf("no embedded program in:\n%s", src)
+		}
+		if want := p.Serialize(); !bytes.Equal(data, want) {
+			t.Fatalf("extracted program differs:\n%s\nwant:\n%s", data, want)
+		}
+		testOne(t, p, Options{EmbedProg: true})
+	}
+	if _, ok := ExtractProg([]byte("int main() {}\n")); ok {
+		t.Fatalf("extracted program from source without embedded program")
+	}
+}
+
+func TestTrace(t *testing.T) {
+	target, _, _ := initTest(t)
+	if target.OS != runtime.GOOS || target.Arch != runtime.GOARCH {
+		t.Skip("can't run programs for non-host target")
+	}
+	p, err := target.Deserialize([]byte("getpid()\nclose(0xffffffffffffffff)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Write(p, Options{Trace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcf, err := osutil.WriteTempFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(srcf)
+	bin, err := Build(p.Target, "c", srcf)
+	if err == NoCompilerErr {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(bin)
+	out, err := osutil.RunCmd(time.Minute, "", bin)
+	if err != nil {
+		t.Fatalf("program failed: %v\n%s", err, out)
+	}
+	for _, re := range []string{
+		`#0: getpid\(\) = 0x[0-9a-f]+\n`,
+		`#1: close\(0xffffffffffffffff\) = -1 \(errno 9: .*\)\n`,
+	} {
+		if !regexp.MustCompile(re).Match(out) {
+			t.Errorf("output does not match %q:\n%s", re, out)
+		}
+	}
+}
+
+func TestWriteModule(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte(
+		"mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+			"r0 = open(&(0x7f0000000000)=\"2e2f66696c653000\", 0x0, 0x0)\n" +
+			"close(r0)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := WriteModule(p, ModuleOptions{Name: "repro"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, re := range []string{
+		`memcpy\(\(void\*\)DATA\(0x0\), "\\x2e\\x2f`,
+		fmt.Sprintf(`r\[\d+\] = syz_syscall\(%v, DATA\(0x0\), 0x0ul, 0x0ul, 0, 0, 0\); // open\n`,
+			target.SyscallMap["open"].NR),
+		fmt.Sprintf(`syz_syscall\(%v, r\[\d+\], 0, 0, 0, 0, 0\); // close\n`, target.SyscallMap["close"].NR),
+		`kthread_run\(syz_thread_fn, NULL, SYZ_NAME\)`,
+		`#define SYZ_NAME "repro"\n`,
+	} {
+		if !regexp.MustCompile(re).Match(src) {
+			t.Errorf("module does not match %q:\n%s", re, src)
+		}
+	}
+	if bytes.Contains(src, []byte("// mmap\n")) {
+		t.Errorf("module contains mmap call:\n%s", src)
+	}
+	p, err = target.Deserialize([]byte("syz_open_pts(0xffffffffffffffff, 0x0)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteModule(p, ModuleOptions{}); err == nil {
+		t.Errorf("no error for pseudo-syscall")
+	}
+}
+
+func TestCompressData(t *testing.T) {
+	target, rs, _ := initTest(t)
+	if target.OS != runtime.GOOS || target.Arch != runtime.GOARCH {
+		t.Skip("can't run programs for non-host target")
+	}
+	r := rand.New(rs)
+	var data []byte
+	for _, chunk := range []struct {
+		size   int
+		random bool
+		val    byte
+	}{{200, true, 0}, {1000, false, 0}, {10, true, 0}, {50, false, 'A'}, {150, true, 0}} {
+		for i := 0; i < chunk.size; i++ {
+			v := chunk.val
+			if chunk.random {
+				v = byte(r.Intn(256))
+			}
+			data = append(data, v)
+		}
+	}
+	p, err := target.Deserialize([]byte(fmt.Sprintf(
+		"mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n"+
+			"write(0x1, &(0x7f0000000000)=\"%x\", 0x%x)\n", data, len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, base64 := range []bool{false, true} {
+		src, err := Write(p, Options{Base64Data: base64})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, re := range []string{`memset\(\(void\*\)0x[0-9a-f]+, 0x0, 1000\)`, `memset\(\(void\*\)0x[0-9a-f]+, 0x41, 50\)`} {
+			if !regexp.MustCompile(re).Match(src) {
+				t.Errorf("base64=%v: program does not match %q", base64, re)
+			}
+		}
+		if got := bytes.Contains(src, []byte("base64_decode((char*)")); got != base64 {
+			t.Errorf("base64=%v: base64_decode used=%v", base64, got)
+		}
+		srcf, err := osutil.WriteTempFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(srcf)
+		bin, err := Build(p.Target, "c", srcf)
+		if err == NoCompilerErr {
+			t.Skip(err)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(bin)
+		out, err := osutil.RunCmd(time.Minute, "", bin)
+		if err != nil {
+			t.Fatalf("program failed: %v\n%s", err, out)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("base64=%v: program wrote wrong data:\n%x\nwant:\n%x", base64, out, data)
+		}
+	}
+}
+
+func TestUnusedResults(t *testing.T) {
+	target, _, _ := initTest(t)
+	// r0 is used only by syz_usb_control_io, which is not emitted without EnableUSB.
+	p, err := target.Deserialize([]byte(`pipe(&(0x7f0000000000)={<r0=>0xffffffffffffffff, <r1=>0xffffffffffffffff})
+syz_usb_control_io(r0, 0x0)
+close(r1)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"res_pipe0 = syscall(__NR_pipe",
+		"if (res_pipe0 != -1)",
+		"fd2 = *(uint32_t*)0x20000004;",
+		"syscall(__NR_close, fd2);",
+	} {
+		if !strings.Contains(string(src), line) {
+			t.Errorf("program does not contain %q:\n%s", line, src)
+		}
+	}
+	for _, word := range []string{"fd1", "*(uint32_t*)0x20000000;"} {
+		if strings.Contains(string(src), word) {
+			t.Errorf("program contains %q:\n%s", word, src)
+		}
+	}
+	srcf, err := osutil.WriteTempFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(srcf)
+	bin, err := Build(p.Target, "c", srcf)
+	if err == NoCompilerErr {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(bin)
+}
+
+func TestWriteVariants(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte("r0 = getpid()\nclose(r0)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := Options{
+		Threaded:   true,
+		Collide:    true,
+		Repeat:     true,
+		Procs:      4,
+		Sandbox:    "none",
+		Fault:      true,
+		FaultCall:  1,
+		FaultNth:   2,
+		WaitRepeat: true,
+		Repro:      true,
+	}
+	variants, err := WriteVariants(p, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, v := range variants {
+		names = append(names, v.Name)
+		src := string(v.Source)
+		if !strings.HasPrefix(src, "// autogenerated by syzkaller") ||
+			!strings.Contains(src, "// Reproducer variant: "+v.Name+".\n") ||
+			!strings.Contains(src, "// All variants of this reproducer: single, threaded, fault, repeat.\n") {
+			t.Errorf("%v: bad header:\n%s", v.Name, src)
+		}
+		if v.Opts.Repro || v.Opts.Sandbox != "none" {
+			t.Errorf("%v: bad options %+v", v.Name, v.Opts)
+		}
+		if got := strings.Contains(src, "inject_fault(2);"); got != v.Opts.Fault {
+			t.Errorf("%v: fault injection %v, want %v", v.Name, got, v.Opts.Fault)
+		}
+	}
+	if got := strings.Join(names, " "); got != "single threaded fault repeat" {
+		t.Fatalf("got variants %q", got)
+	}
+	if variants[0].Opts.Threaded || variants[0].Opts.Repeat || !variants[1].Opts.Threaded ||
+		variants[1].Opts.Fault || !variants[2].Opts.Fault || !variants[3].Opts.Repeat {
+		t.Errorf("bad variant options: %+v %+v %+v %+v",
+			variants[0].Opts, variants[1].Opts, variants[2].Opts, variants[3].Opts)
+	}
+	base.Fault = false
+	if variants, err = WriteVariants(p, base); err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != 3 {
+		t.Errorf("got %v variants without fault, want 3", len(variants))
+	}
+}
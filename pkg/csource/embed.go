@@ -0,0 +1,45 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/syzkaller/prog"
+)
+
+const (
+	embeddedProgStart = "/* syz program:\n"
+	embeddedProgEnd   = "*/\n"
+)
+
+// embedProg serializes p into a trailing block comment for Options.EmbedProg.
+func embedProg(p *prog.Prog) ([]byte, error) {
+	data := p.Serialize()
+	if bytes.Contains(data, []byte("*/")) {
+		return nil, fmt.Errorf("serialized program contains comment terminator")
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("\n" + embeddedProgStart)
+	buf.Write(data)
+	buf.WriteString(embeddedProgEnd)
+	return buf.Bytes(), nil
+}
+
+// ExtractProg returns serialized syzkaller program embedded into C source
+// generated with Options.EmbedProg. The second result is false if src
+// does not contain an embedded program.
+func ExtractProg(src []byte) ([]byte, bool) {
+	pos := bytes.LastIndex(src, []byte(embeddedProgStart))
+	if pos == -1 {
+		return nil, false
+	}
+	data := src[pos+len(embeddedProgStart):]
+	end := bytes.Index(data, []byte(embeddedProgEnd))
+	if end == -1 {
+		return nil, false
+	}
+	return data[:end], true
+}
@@ -9,4 +9,7 @@
 //go:generate bash -c "echo -e '// AUTOGENERATED FROM executor/common_akaros.h\npackage csource\nvar commonHeaderAkaros = `' > akaros_common.go; cat ../../executor/common_akaros.h | sed -e '/#include \"common.h\"/ {' -e 'r ../../executor/common.h' -e 'd' -e '}' - | egrep -v '^[   ]*//' | sed '/^[ 	]*\\/\\/.*/d' | sed 's#[ 	]*//.*##g' >> akaros_common.go; echo '`' >> akaros_common.go"
 //go:generate go fmt akaros_common.go
 
+//go:generate bash -c "echo -e '// AUTOGENERATED FROM executor/common_posix.h\npackage csource\nvar commonHeaderPosix = `' > posix_common.go; cat ../../executor/common_posix.h | sed -e '/#include \"common.h\"/ {' -e 'r ../../executor/common.h' -e 'd' -e '}' - | egrep -v '^[   ]*//' | sed '/^[ 	]*\\/\\/.*/d' | sed 's#[ 	]*//.*##g' >> posix_common.go; echo '`' >> posix_common.go"
+//go:generate go fmt posix_common.go
+
 package csource
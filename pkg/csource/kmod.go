@@ -0,0 +1,310 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/syzkaller/pkg/execfmt"
+	"github.com/google/syzkaller/prog"
+)
+
+// ModuleOptions control generation of kernel module reproducers.
+type ModuleOptions struct {
+	Name   string // module and kernel thread name, "syz_repro" if empty
+	Repeat bool   // repeat the program until the module is unloaded
+}
+
+// Number of pages in the program data area (matches prog's maxPages).
+const moduleDataPages = 4 << 10
+
+// WriteModule generates a loadable Linux kernel module that executes program p
+// from a kernel thread started with kthread_run. This allows to trigger the bug
+// early at boot and with extra in-kernel instrumentation.
+// Program memory is allocated with vmalloc and constants pointing into the program
+// data area are relocated into it. Calls are invoked via sys_call_table with
+// KERNEL_DS address limit, so the module works only on kernels that have set_fs
+// and allow to look up sys_call_table with kallsyms_lookup_name.
+// Pseudo-syscalls and checksums are not supported.
+func WriteModule(p *prog.Prog, opts ModuleOptions) ([]byte, error) {
+	if p.Target.OS != "linux" {
+		return nil, fmt.Errorf("kernel modules are not supported on %v", p.Target.OS)
+	}
+	if opts.Name == "" {
+		opts.Name = "syz_repro"
+	}
+	for _, c := range p.Calls {
+		if isPseudoCall(c.Meta.CallName) && !pseudoCalls["linux"][c.Meta.CallName].noop {
+			return nil, fmt.Errorf("pseudo-syscall %v is not supported in kernel modules", c.Meta.CallName)
+		}
+		if len(c.Args) > 6 {
+			return nil, fmt.Errorf("syscall %v has more than 6 arguments", c.Meta.Name)
+		}
+	}
+	exec := make([]byte, prog.ExecBufferSize)
+	progSize, err := p.SerializeForExec(exec, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize program: %v", err)
+	}
+	decoded, err := execfmt.Decode(p.Target, exec[:progSize])
+	if err != nil {
+		return nil, err
+	}
+	ctx := &moduleContext{
+		p:        p,
+		dataSize: moduleDataPages * p.Target.PageSize,
+	}
+	calls, nvar, err := ctx.generateCalls(decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	w := new(bytes.Buffer)
+	fmt.Fprintf(w, "// autogenerated by syzkaller (http://github.com/google/syzkaller)\n")
+	fmt.Fprintf(w, "// Build as an external module with Kbuild file containing \"obj-m += %v.o\".\n\n", opts.Name)
+	w.WriteString(moduleHeader)
+	fmt.Fprintf(w, "#define SYZ_DATA_SIZE 0x%x\n", ctx.dataSize)
+	fmt.Fprintf(w, "#define SYZ_REPEAT %v\n", boolToInt(opts.Repeat))
+	fmt.Fprintf(w, "#define SYZ_NAME %q\n\n", opts.Name)
+	w.WriteString(moduleHelpers)
+	fmt.Fprintf(w, "static long r[%v];\n\n", nvar)
+	w.WriteString("static void syz_execute(void)\n{\n")
+	w.WriteString("\tunsigned i;\n\n")
+	w.WriteString("\tfor (i = 0; i < ARRAY_SIZE(r); i++)\n\t\tr[i] = -1;\n")
+	for _, c := range calls {
+		w.WriteString(c)
+	}
+	w.WriteString("}\n\n")
+	w.WriteString(moduleMain)
+	return w.Bytes(), nil
+}
+
+func boolToInt(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+type moduleContext struct {
+	p        *prog.Prog
+	dataSize uint64
+}
+
+// value returns C expression for the const v, relocating pointers into the data area.
+func (ctx *moduleContext) value(v uint64) string {
+	if off := v - ctx.p.Target.DataOffset; v >= ctx.p.Target.DataOffset && off < ctx.dataSize {
+		return fmt.Sprintf("DATA(0x%x)", off)
+	}
+	return fmt.Sprintf("0x%xul", v)
+}
+
+func (ctx *moduleContext) addr(addr, size uint64) (string, error) {
+	off := addr - ctx.p.Target.DataOffset
+	if addr < ctx.p.Target.DataOffset || off+size > ctx.dataSize {
+		return "", fmt.Errorf("address 0x%x is outside of the data area", addr)
+	}
+	return fmt.Sprintf("DATA(0x%x)", off), nil
+}
+
+func (ctx *moduleContext) generateCalls(exec *execfmt.Prog) ([]string, int, error) {
+	resultRef := func(arg *execfmt.ResultArg) string {
+		res := fmt.Sprintf("r[%v]", arg.Index)
+		if arg.OpDiv != 0 {
+			res = fmt.Sprintf("%v/%v", res, arg.OpDiv)
+		}
+		if arg.OpAdd != 0 {
+			res = fmt.Sprintf("%v+%v", res, arg.OpAdd)
+		}
+		return res
+	}
+	lastCall := 0
+	seenCall := false
+	var calls []string
+	w := new(bytes.Buffer)
+	newCall := func() {
+		if seenCall {
+			seenCall = false
+			calls = append(calls, w.String())
+			w = new(bytes.Buffer)
+		}
+	}
+	for n, instr := range exec.Instrs {
+		switch instr := instr.(type) {
+		case *execfmt.Copyin:
+			newCall()
+			switch arg := instr.Arg.(type) {
+			case *execfmt.ConstArg:
+				ptr, err := ctx.addr(instr.Addr, arg.Size)
+				if err != nil {
+					return nil, 0, err
+				}
+				fmt.Fprintf(w, "\tSTORE_BY_BITMASK(uint%v_t, %v, %v, %v, %v);\n",
+					arg.Size*8, ptr, ctx.value(arg.Value), arg.BitfieldOffset, arg.BitfieldLength)
+			case *execfmt.ResultArg:
+				ptr, err := ctx.addr(instr.Addr, arg.Size)
+				if err != nil {
+					return nil, 0, err
+				}
+				fmt.Fprintf(w, "\t*(uint%v_t*)%v = %v;\n", arg.Size*8, ptr, resultRef(arg))
+			case *execfmt.DataArg:
+				size := uint64(len(arg.Data))
+				ptr, err := ctx.addr(instr.Addr, size)
+				if err != nil {
+					return nil, 0, err
+				}
+				fmt.Fprintf(w, "\tmemcpy((void*)%v, \"%s\", %v);\n", ptr, escapeData(arg.Data), size)
+			case *execfmt.CsumArg:
+				return nil, 0, fmt.Errorf("checksums are not supported in kernel modules")
+			default:
+				panic(fmt.Sprintf("bad argument type %T", arg))
+			}
+		case *execfmt.Copyout:
+			ptr, err := ctx.addr(instr.Addr, instr.Size)
+			if err != nil {
+				return nil, 0, err
+			}
+			fmt.Fprintf(w, "\tif (r[%v] != -1)\n", lastCall)
+			fmt.Fprintf(w, "\t\tr[%v] = *(uint%v_t*)%v;\n", n, instr.Size*8, ptr)
+		case *execfmt.Call:
+			newCall()
+			meta := instr.Meta
+			// Pseudo-syscalls are rejected by the caller, so this can only be a noop one.
+			// The data area is preallocated, so mmap calls are not needed.
+			emitCall := !isPseudoCall(meta.CallName) && meta != ctx.p.Target.MmapSyscall
+			var args []string
+			for _, arg := range instr.Args {
+				switch arg := arg.(type) {
+				case *execfmt.ConstArg:
+					// Bitfields can't be args of a normal syscall, so just ignore them.
+					args = append(args, ctx.value(arg.Value))
+				case *execfmt.ResultArg:
+					args = append(args, resultRef(arg))
+				default:
+					panic(fmt.Sprintf("unknown arg type %T", arg))
+				}
+			}
+			if emitCall {
+				for len(args) < 6 {
+					args = append(args, "0")
+				}
+				fmt.Fprintf(w, "\tr[%v] = syz_syscall(%v", n, meta.NR)
+				for _, arg := range args {
+					fmt.Fprintf(w, ", %v", arg)
+				}
+				fmt.Fprintf(w, "); // %v\n", meta.Name)
+			}
+			lastCall = n
+			seenCall = true
+		}
+	}
+	newCall()
+	return calls, len(exec.Instrs), nil
+}
+
+const moduleHeader = `#include <linux/delay.h>
+#include <linux/err.h>
+#include <linux/kallsyms.h>
+#include <linux/kernel.h>
+#include <linux/kthread.h>
+#include <linux/module.h>
+#include <linux/ptrace.h>
+#include <linux/sched.h>
+#include <linux/string.h>
+#include <linux/uaccess.h>
+#include <linux/vmalloc.h>
+
+MODULE_LICENSE("GPL");
+MODULE_DESCRIPTION("syzkaller reproducer");
+
+`
+
+const moduleHelpers = `#define BITMASK_LEN(type, bf_len) (type)((1ull << (bf_len)) - 1)
+#define BITMASK_LEN_OFF(type, bf_off, bf_len) (type)(BITMASK_LEN(type, (bf_len)) << (bf_off))
+#define STORE_BY_BITMASK(type, addr, val, bf_off, bf_len)                         \
+	if ((bf_off) == 0 && (bf_len) == 0) {                                     \
+		*(type*)(addr) = (type)(val);                                     \
+	} else {                                                                  \
+		type new_val = *(type*)(addr);                                    \
+		new_val &= ~BITMASK_LEN_OFF(type, (bf_off), (bf_len));            \
+		new_val |= ((type)(val)&BITMASK_LEN(type, (bf_len))) << (bf_off); \
+		*(type*)(addr) = new_val;                                         \
+	}
+
+static char* syz_data;
+static unsigned long* syz_sys_call_table;
+static struct task_struct* syz_thread;
+
+#define DATA(off) ((unsigned long)syz_data + (off))
+
+// syz_syscall invokes syscall nr via sys_call_table and returns -1 on failure
+// (like syscall(2) does in user-space programs).
+static long syz_syscall(int nr, unsigned long a0, unsigned long a1, unsigned long a2,
+			unsigned long a3, unsigned long a4, unsigned long a5)
+{
+	long res;
+#if defined(CONFIG_ARCH_HAS_SYSCALL_WRAPPER) && defined(CONFIG_X86_64)
+	struct pt_regs regs;
+
+	memset(&regs, 0, sizeof(regs));
+	regs.di = a0;
+	regs.si = a1;
+	regs.dx = a2;
+	regs.r10 = a3;
+	regs.r8 = a4;
+	regs.r9 = a5;
+	res = ((long (*)(const struct pt_regs*))syz_sys_call_table[nr])(&regs);
+#else
+	res = ((long (*)(unsigned long, unsigned long, unsigned long, unsigned long,
+			 unsigned long, unsigned long))syz_sys_call_table[nr])(a0, a1, a2, a3, a4, a5);
+#endif
+	if (IS_ERR_VALUE(res))
+		return -1;
+	return res;
+}
+
+`
+
+const moduleMain = `static int syz_thread_fn(void* arg)
+{
+	// Let syscalls accept kernel pointers into syz_data.
+	mm_segment_t old_fs = get_fs();
+
+	set_fs(KERNEL_DS);
+	do {
+		syz_execute();
+		cond_resched();
+	} while (SYZ_REPEAT && !kthread_should_stop());
+	set_fs(old_fs);
+	while (!kthread_should_stop())
+		msleep(100);
+	return 0;
+}
+
+static int __init syz_init(void)
+{
+	syz_sys_call_table = (unsigned long*)kallsyms_lookup_name("sys_call_table");
+	if (!syz_sys_call_table)
+		return -ENOENT;
+	syz_data = vzalloc(SYZ_DATA_SIZE);
+	if (!syz_data)
+		return -ENOMEM;
+	syz_thread = kthread_run(syz_thread_fn, NULL, SYZ_NAME);
+	if (IS_ERR(syz_thread)) {
+		vfree(syz_data);
+		return PTR_ERR(syz_thread);
+	}
+	return 0;
+}
+
+static void __exit syz_exit(void)
+{
+	kthread_stop(syz_thread);
+	vfree(syz_data);
+}
+
+module_init(syz_init);
+module_exit(syz_exit);
+`
@@ -0,0 +1,284 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// The test is in a separate package because it uses ipc, which imports csource.
+package csource_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/csource"
+	"github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+	"github.com/google/syzkaller/vm"
+)
+
+var flagParityVM = flag.String("parity_vm", "", "manager config to run the parity test in a VM (e.g. qemu)")
+
+// Programs for the parity test. They must be deterministic,
+// i.e. give the same results regardless of the environment they run in
+// (e.g. must not refer to hardcoded fds, because executor has own fds open).
+var parityProgs = []string{
+	`mmap(&(0x7f0000000000/0x3000)=nil, 0x3000, 0x3, 0x32, 0xffffffffffffffff, 0x0)
+close(0xffffffffffffffff)
+mmap(&(0x7f0000004000/0x1000)=nil, 0x1000, 0x3, 0x3, 0xffffffffffffffff, 0x0)
+socket(0xffff, 0x1, 0x0)
+getpid()
+`,
+	`mmap(&(0x7f0000000000/0x3000)=nil, 0x3000, 0x3, 0x32, 0xffffffffffffffff, 0x0)
+pipe(&(0x7f0000000000)={<r0=>0xffffffffffffffff, <r1=>0xffffffffffffffff})
+write(r1, &(0x7f0000001000)="0102030405", 0x5)
+read(r0, &(0x7f0000002000)="", 0x10)
+close(r0)
+close(r0)
+dup(r0)
+read(r1, &(0x7f0000002000)="", 0x10)
+`,
+	`mmap(&(0x7f0000000000/0x3000)=nil, 0x3000, 0x3, 0x32, 0xffffffffffffffff, 0x0)
+r0 = open(&(0x7f0000000000)="2f73797a6b616c6c65722d6e6f6e6578697374656e742d66696c6500", 0x0, 0x0)
+read(r0, &(0x7f0000001000)="", 0x10)
+close(r0)
+`,
+}
+
+// TestParity runs the same programs through syz-executor and through
+// the C reproducer and checks that the same calls were executed
+// and they returned the same errno values.
+func TestParity(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("executor is built only for linux")
+	}
+	target, err := prog.GetTarget(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor, err := csource.Build(target, "c++", filepath.FromSlash("../../executor/executor_linux.cc"))
+	if err == csource.NoCompilerErr {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(executor)
+	cfg, err := ipc.DefaultConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Flags &= ipc.FlagUseShmem | ipc.FlagUseForkServer
+	env, err := ipc.MakeEnv(executor, 0, cfg)
+	if err != nil {
+		t.Fatalf("failed to create env: %v", err)
+	}
+	defer env.Close()
+	for i, text := range parityProgs {
+		p := parseParityProg(t, target, text)
+		output, info, failed, hanged, err := env.Exec(&ipc.ExecOpts{Flags: ipc.FlagCollectErrno}, p)
+		if err != nil || failed || hanged {
+			t.Fatalf("program #%v: executor failed (failed=%v hanged=%v): %v\n%s",
+				i, failed, hanged, err, output)
+		}
+		want := make(map[int]int)
+		for call, inf := range info {
+			if inf.Errno != -1 {
+				want[call] = inf.Errno
+			}
+		}
+		bin := buildParityRepro(t, p)
+		out, err := osutil.RunCmd(time.Minute, "", bin)
+		os.Remove(bin)
+		if err != nil {
+			t.Fatalf("program #%v: reproducer failed: %v\n%s", i, err, out)
+		}
+		if diff := compareParity(p, want, parseReproTrace(out)); diff != "" {
+			t.Errorf("program #%v diverged:\n%s\n%s", i, text, diff)
+		}
+	}
+}
+
+// TestParityVM does the same as TestParity, but runs the programs inside of a VM
+// described by the manager config passed in -parity_vm flag. This allows to check
+// parity on a real kernel build (with coverage, tun, etc) and for non-host targets.
+func TestParityVM(t *testing.T) {
+	if *flagParityVM == "" {
+		t.Skip("-parity_vm is not set")
+	}
+	cfg, err := mgrconfig.LoadFile(*flagParityVM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := prog.GetTarget(cfg.TargetOS, cfg.TargetArch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := vm.Create(cfg.Type, mgrconfig.CreateVMEnv(cfg, false))
+	if err != nil {
+		t.Fatalf("failed to create VM pool: %v", err)
+	}
+	inst, err := pool.Create(0)
+	if err != nil {
+		t.Fatalf("failed to create VM: %v", err)
+	}
+	defer inst.Close()
+	execprog := copyToVM(t, inst, cfg.SyzExecprogBin)
+	executor := copyToVM(t, inst, cfg.SyzExecutorBin)
+	for i, text := range parityProgs {
+		p := parseParityProg(t, target, text)
+		progFile, err := osutil.WriteTempFile(p.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+		vmProgFile := copyToVM(t, inst, progFile)
+		os.Remove(progFile)
+		out := runInVM(t, inst, fmt.Sprintf("%v -executor=%v -arch=%v -procs=1 -repeat=1 -errno %v",
+			execprog, executor, cfg.TargetArch, vmProgFile))
+		want := parseExecprogErrno(out)
+		bin := buildParityRepro(t, p)
+		vmBin := copyToVM(t, inst, bin)
+		os.Remove(bin)
+		got := parseReproTrace(runInVM(t, inst, vmBin))
+		if diff := compareParity(p, want, got); diff != "" {
+			t.Errorf("program #%v diverged:\n%s\n%s", i, text, diff)
+		}
+	}
+}
+
+func parseParityProg(t *testing.T, target *prog.Target, text string) *prog.Prog {
+	p, err := target.Deserialize([]byte(text))
+	if err != nil {
+		t.Fatalf("failed to parse program: %v\n%s", err, text)
+	}
+	return p
+}
+
+func buildParityRepro(t *testing.T, p *prog.Prog) string {
+	src, err := csource.Write(p, csource.Options{Trace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcf, err := osutil.WriteTempFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(srcf)
+	bin, err := csource.Build(p.Target, "c", srcf)
+	if err == csource.NoCompilerErr {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func copyToVM(t *testing.T, inst *vm.Instance, file string) string {
+	vmFile, err := inst.Copy(file)
+	if err != nil {
+		t.Fatalf("failed to copy %v to VM: %v", file, err)
+	}
+	return vmFile
+}
+
+func runInVM(t *testing.T, inst *vm.Instance, command string) []byte {
+	outc, errc, err := inst.Run(5*time.Minute, nil, command)
+	if err != nil {
+		t.Fatalf("failed to run %v: %v", command, err)
+	}
+	var output []byte
+	for {
+		select {
+		case out := <-outc:
+			output = append(output, out...)
+		case err := <-errc:
+			if err != nil {
+				t.Fatalf("failed to run %v: %v\n%s", command, err, output)
+			}
+			// Console and command output are merged, so collect what's left.
+			for {
+				select {
+				case out := <-outc:
+					output = append(output, out...)
+				case <-time.After(time.Second):
+					return output
+				}
+			}
+		}
+	}
+}
+
+var (
+	reproTraceRe = regexp.MustCompile(`(?m)^#([0-9]+): [^ ]+\(.*\) = (?:-1 \(errno ([0-9]+):|0x[0-9a-f]+$)`)
+	execprogRe   = regexp.MustCompile(`(?m)^call #([0-9]+): errno ([0-9]+)$`)
+)
+
+// parseReproTrace parses output of a reproducer built with Options.Trace
+// and returns errno for each executed call.
+func parseReproTrace(output []byte) map[int]int {
+	return parseErrnos(reproTraceRe, output)
+}
+
+// parseExecprogErrno parses output of syz-execprog -errno.
+func parseExecprogErrno(output []byte) map[int]int {
+	return parseErrnos(execprogRe, output)
+}
+
+func parseErrnos(re *regexp.Regexp, output []byte) map[int]int {
+	res := make(map[int]int)
+	for _, match := range re.FindAllSubmatch(bytes.Replace(output, []byte("\r"), nil, -1), -1) {
+		call, _ := strconv.Atoi(string(match[1]))
+		errno := 0
+		if len(match[2]) != 0 {
+			errno, _ = strconv.Atoi(string(match[2]))
+		}
+		res[call] = errno
+	}
+	return res
+}
+
+// compareParity returns description of differences between calls executed
+// by executor and reproducer, or an empty string if there are none.
+func compareParity(p *prog.Prog, executor, repro map[int]int) string {
+	calls := make(map[int]bool)
+	for call := range executor {
+		calls[call] = true
+	}
+	for call := range repro {
+		calls[call] = true
+	}
+	var sorted []int
+	for call := range calls {
+		sorted = append(sorted, call)
+	}
+	sort.Ints(sorted)
+	diff := new(bytes.Buffer)
+	for _, call := range sorted {
+		name := "?"
+		if call < len(p.Calls) {
+			name = p.Calls[call].Meta.Name
+		}
+		errno0, ok0 := executor[call]
+		errno1, ok1 := repro[call]
+		switch {
+		case !ok0:
+			fmt.Fprintf(diff, "call #%v %v: executed only by reproducer\n", call, name)
+		case !ok1:
+			fmt.Fprintf(diff, "call #%v %v: executed only by executor\n", call, name)
+		case errno0 != errno1:
+			fmt.Fprintf(diff, "call #%v %v: executor errno %v, reproducer errno %v\n",
+				call, name, errno0, errno1)
+		}
+	}
+	return diff.String()
+}
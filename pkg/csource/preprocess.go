@@ -0,0 +1,292 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// preprocess is a minimal C preprocessor sufficient for the common headers.
+// It evaluates conditional directives (#if/#ifdef/#ifndef/#elif/#else/#endif)
+// given the set of defines and strips inactive regions, but leaves all other
+// directives (#include, #define, etc) and code intact.
+// It is used instead of cpp in Minimal mode, so that programs can be generated
+// on machines without a C preprocessor.
+func preprocess(src string, defines []string) (string, error) {
+	defined := make(map[string]bool)
+	for _, def := range defines {
+		defined[def] = true
+	}
+	type frame struct {
+		parent bool // the enclosing region is active
+		taken  bool // one of the branches was already taken
+		active bool // the current branch is active
+		inElse bool
+	}
+	var stack []frame
+	active := func() bool {
+		return len(stack) == 0 || stack[len(stack)-1].active
+	}
+	out := new(bytes.Buffer)
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#") {
+			if active() {
+				out.WriteString(line)
+				out.WriteByte('\n')
+			}
+			continue
+		}
+		// Collect continuation lines of the directive.
+		raw := []string{line}
+		for strings.HasSuffix(lines[i], "\\") && i+1 < len(lines) {
+			i++
+			raw = append(raw, lines[i])
+		}
+		logical := ""
+		for _, ln := range raw {
+			logical += strings.TrimSuffix(ln, "\\") + " "
+		}
+		directive, args := splitDirective(logical)
+		lineno := i + 1
+		switch directive {
+		case "if", "ifdef", "ifndef":
+			cond := false
+			if active() {
+				var err error
+				switch directive {
+				case "if":
+					cond, err = evalCondition(args, defined)
+				case "ifdef":
+					cond = defined[args]
+				case "ifndef":
+					cond = !defined[args]
+				}
+				if err != nil {
+					return "", fmt.Errorf("line %v: %v", lineno, err)
+				}
+			}
+			stack = append(stack, frame{
+				parent: active(),
+				taken:  cond,
+				active: active() && cond,
+			})
+		case "elif", "else":
+			if len(stack) == 0 {
+				return "", fmt.Errorf("line %v: #%v without #if", lineno, directive)
+			}
+			f := &stack[len(stack)-1]
+			if f.inElse {
+				return "", fmt.Errorf("line %v: #%v after #else", lineno, directive)
+			}
+			cond := true
+			if directive == "else" {
+				f.inElse = true
+			} else if f.parent && !f.taken {
+				var err error
+				cond, err = evalCondition(args, defined)
+				if err != nil {
+					return "", fmt.Errorf("line %v: %v", lineno, err)
+				}
+			}
+			f.active = f.parent && !f.taken && cond
+			f.taken = f.taken || f.active
+		case "endif":
+			if len(stack) == 0 {
+				return "", fmt.Errorf("line %v: #endif without #if", lineno)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if !active() {
+				continue
+			}
+			switch directive {
+			case "define":
+				if name := macroName(args); name != "" {
+					defined[name] = true
+				}
+			case "undef":
+				delete(defined, args)
+			}
+			for _, ln := range raw {
+				out.WriteString(ln)
+				out.WriteByte('\n')
+			}
+		}
+	}
+	if len(stack) != 0 {
+		return "", fmt.Errorf("unterminated #if")
+	}
+	return out.String(), nil
+}
+
+func splitDirective(line string) (string, string) {
+	line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+	pos := strings.IndexAny(line, " \t(")
+	if pos == -1 {
+		return line, ""
+	}
+	return line[:pos], strings.TrimSpace(line[pos:])
+}
+
+func macroName(args string) string {
+	end := 0
+	for end < len(args) && isIdentChar(args[end]) {
+		end++
+	}
+	return args[:end]
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// evalCondition evaluates #if expression. Only defined(), !, &&, ||, parenthesis,
+// integer literals and identifiers (defined identifiers are 1, undefined are 0) are supported.
+func evalCondition(expr string, defined map[string]bool) (bool, error) {
+	p := &condParser{defined: defined}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')' || c == '!':
+			p.toks = append(p.toks, expr[i:i+1])
+			i++
+		case strings.HasPrefix(expr[i:], "&&") || strings.HasPrefix(expr[i:], "||"):
+			p.toks = append(p.toks, expr[i:i+2])
+			i += 2
+		case isIdentChar(c):
+			start := i
+			for i < len(expr) && isIdentChar(expr[i]) {
+				i++
+			}
+			p.toks = append(p.toks, expr[start:i])
+		default:
+			return false, fmt.Errorf("unsupported expression: %v", expr)
+		}
+	}
+	v, err := p.parseOr()
+	if err != nil {
+		return false, fmt.Errorf("%v in expression: %v", err, expr)
+	}
+	if p.pos != len(p.toks) {
+		return false, fmt.Errorf("trailing tokens in expression: %v", expr)
+	}
+	return v != 0, nil
+}
+
+type condParser struct {
+	toks    []string
+	pos     int
+	defined map[string]bool
+}
+
+func (p *condParser) peek() string {
+	if p.pos == len(p.toks) {
+		return ""
+	}
+	return p.toks[p.pos]
+}
+
+func (p *condParser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *condParser) parseOr() (int64, error) {
+	v, err := p.parseAnd()
+	if err != nil {
+		return 0, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		v1, err := p.parseAnd()
+		if err != nil {
+			return 0, err
+		}
+		v = bool2int(v != 0 || v1 != 0)
+	}
+	return v, nil
+}
+
+func (p *condParser) parseAnd() (int64, error) {
+	v, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		v1, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		v = bool2int(v != 0 && v1 != 0)
+	}
+	return v, nil
+}
+
+func (p *condParser) parseUnary() (int64, error) {
+	if p.peek() == "!" {
+		p.next()
+		v, err := p.parseUnary()
+		return bool2int(v == 0), err
+	}
+	return p.parsePrimary()
+}
+
+func (p *condParser) parsePrimary() (int64, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return 0, fmt.Errorf("unexpected end")
+	case tok == "(":
+		v, err := p.parseOr()
+		if err != nil {
+			return 0, err
+		}
+		if p.next() != ")" {
+			return 0, fmt.Errorf("missing )")
+		}
+		return v, nil
+	case tok == "defined":
+		paren := p.peek() == "("
+		if paren {
+			p.next()
+		}
+		name := p.next()
+		if name == "" || !isIdentChar(name[0]) {
+			return 0, fmt.Errorf("bad defined operand")
+		}
+		if paren && p.next() != ")" {
+			return 0, fmt.Errorf("missing )")
+		}
+		return bool2int(p.defined[name]), nil
+	case tok[0] >= '0' && tok[0] <= '9':
+		v, err := strconv.ParseInt(strings.TrimRight(tok, "uUlL"), 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("bad number %v", tok)
+		}
+		return v, nil
+	case isIdentChar(tok[0]):
+		return bool2int(p.defined[tok]), nil
+	default:
+		return 0, fmt.Errorf("unexpected %v", tok)
+	}
+}
+
+func bool2int(v bool) int64 {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/google/syzkaller/sys/targets"
+)
+
+func TestPreprocess(t *testing.T) {
+	defines := map[string]bool{"A": true, "B": true}
+	tests := []struct {
+		expr string
+		res  bool
+	}{
+		{"defined(A)", true},
+		{"defined(C)", false},
+		{"defined A", true},
+		{"!defined(A)", false},
+		{"defined(A) && defined(C)", false},
+		{"defined(A) || defined(C)", true},
+		{"defined(C) || (defined(A) && !defined(D))", true},
+		{"A && B", true},
+		{"C", false},
+		{"0", false},
+		{"1", true},
+	}
+	for _, test := range tests {
+		res, err := evalCondition(test.expr, defines)
+		if err != nil {
+			t.Errorf("%v: %v", test.expr, err)
+			continue
+		}
+		if res != test.res {
+			t.Errorf("%v: got %v, want %v", test.expr, res, test.res)
+		}
+	}
+	for _, expr := range []string{"", "defined(", "(A", "A B", "A + B"} {
+		if _, err := evalCondition(expr, defines); err == nil {
+			t.Errorf("%v: no error", expr)
+		}
+	}
+
+	src := `
+#if defined(A)
+a
+#ifdef C
+c
+#elif defined(B) || \
+	defined(C)
+#define X 1
+b
+#else
+notb
+#endif
+#endif
+#ifndef X
+nox
+#else
+x
+#endif
+`
+	out, err := preprocess(src, []string{"A", "B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\na\n#define X 1\nb\nx\n\n"
+	if out != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", out, want)
+	}
+	for _, bad := range []string{"#if A\n", "#endif\n", "#else\n", "#if A\n#else\n#else\n#endif\n"} {
+		if _, err := preprocess(bad, nil); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
+
+// TestPreprocessCpp checks that Minimal mode produces the same headers as cpp.
+func TestPreprocessCpp(t *testing.T) {
+	if _, err := exec.LookPath("cpp"); err != nil {
+		t.Skip("cpp is not installed")
+	}
+	target, rs, _ := initTest(t)
+	p := target.GenerateAllSyzProg(rs)
+	for _, opts := range allOptionsSingle() {
+		ctx := &context{
+			p:         p,
+			opts:      opts,
+			target:    target,
+			sysTarget: targets.List[target.OS][target.Arch],
+			calls:     make(map[string]uint64),
+		}
+		for _, c := range p.Calls {
+			ctx.calls[c.Meta.CallName] = c.Meta.NR
+		}
+		want, err := ctx.preprocessCommonHeader(commonHeaderLinux)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.opts.Minimal = true
+		got, err := ctx.preprocessCommonHeader(commonHeaderLinux)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if normalizeHeader(got) != normalizeHeader(want) {
+			t.Fatalf("opts %+v: headers differ\ncpp:\n%v\nminimal:\n%v", opts, want, got)
+		}
+	}
+}
+
+// normalizeHeader removes all whitespaces and line continuations,
+// because cpp reformats #define's.
+func normalizeHeader(hdr string) string {
+	hdr = strings.Replace(hdr, "\\\n", "", -1)
+	return strings.Join(strings.Fields(hdr), "")
+}
@@ -0,0 +1,82 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pseudoCall describes a syz_ pseudo-syscall implemented in the OS common header.
+// Implementation of the call is pulled into the generated program by the __NR_syz_foo define,
+// so the header must guard the implementation and all support code it needs with
+// defined(__NR_syz_foo).
+type pseudoCall struct {
+	// The call is not emitted at all (e.g. it's used only for testing of the executor).
+	noop bool
+	// The call requires Options.EnableTun, otherwise it's not emitted.
+	tun bool
+	// The call requires Options.EnableUSB, otherwise it's not emitted.
+	usb bool
+}
+
+// pseudoCalls holds pseudo-syscalls supported in C programs for each OS.
+// Adding a new pseudo-syscall to the common header requires adding it here as well.
+var pseudoCalls = map[string]map[string]pseudoCall{
+	"linux": {
+		"syz_test":               {noop: true},
+		"syz_open_dev":           {},
+		"syz_open_pts":           {},
+		"syz_fuse_mount":         {},
+		"syz_fuseblk_mount":      {},
+		"syz_kvm_setup_cpu":      {},
+		"syz_mount_image":        {},
+		"syz_emit_ethernet":      {tun: true},
+		"syz_extract_tcp_res":    {tun: true},
+		"syz_usb_connect":        {usb: true},
+		"syz_usb_control_io":     {usb: true},
+		"syz_usb_disconnect":     {usb: true},
+		"syz_binder_open":        {},
+		"syz_binder_transaction": {},
+		"syz_binder_read":        {},
+		"syz_ashmem_create":      {},
+	},
+}
+
+// checkPseudoCalls verifies that all pseudo-syscalls used in the program
+// have C implementations for the target OS.
+func (ctx *context) checkPseudoCalls() error {
+	for _, c := range ctx.p.Calls {
+		if !isPseudoCall(c.Meta.CallName) {
+			continue
+		}
+		if _, ok := pseudoCalls[ctx.target.OS][c.Meta.CallName]; !ok {
+			return fmt.Errorf("pseudo-syscall %v is not supported on %v",
+				c.Meta.CallName, ctx.target.OS)
+		}
+	}
+	return nil
+}
+
+// emitCall says if the call needs to be emitted with the given options.
+func (ctx *context) emitCall(callName string) bool {
+	if !isPseudoCall(callName) {
+		return true
+	}
+	pc := pseudoCalls[ctx.target.OS][callName]
+	if pc.noop {
+		return false
+	}
+	if pc.tun && !ctx.opts.EnableTun {
+		return false
+	}
+	if pc.usb && !ctx.opts.EnableUSB {
+		return false
+	}
+	return true
+}
+
+func isPseudoCall(callName string) bool {
+	return strings.HasPrefix(callName, "syz_")
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/syzkaller/prog"
+)
+
+// SeccompDenyNumbers converts syscall names denied in the seccomp sandbox
+// to a comma-separated list of syscall numbers for the target.
+// The result is used both in generated C programs and as ipc -seccomp_deny flag.
+// A name can be either a call name (e.g. "open") or a full syscall name (e.g. "open$dir").
+func SeccompDenyNumbers(target *prog.Target, names []string) (string, error) {
+	nrs := make(map[uint64]bool)
+	for _, name := range names {
+		if isPseudoCall(name) {
+			return "", fmt.Errorf("can't deny pseudo-syscall %v", name)
+		}
+		found := false
+		for _, c := range target.Syscalls {
+			if c.CallName == name || c.Name == name {
+				nrs[c.NR] = true
+				found = true
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("unknown syscall %v in seccomp deny list", name)
+		}
+	}
+	var sorted []uint64
+	for nr := range nrs {
+		sorted = append(sorted, nr)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var res []string
+	for _, nr := range sorted {
+		res = append(res, fmt.Sprint(nr))
+	}
+	return strings.Join(res, ","), nil
+}
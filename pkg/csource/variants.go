@@ -0,0 +1,139 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package csource
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/google/syzkaller/prog"
+)
+
+// Variant is a single reproducer generated by WriteVariants.
+type Variant struct {
+	Name   string // short name suitable for file names (e.g. "threaded")
+	Opts   Options
+	Source []byte
+}
+
+type variantDesc struct {
+	name string
+	desc string
+	opts func(base Options) (Options, bool)
+}
+
+var variantDescs = []variantDesc{
+	{
+		name: "single",
+		desc: "Executes all calls once sequentially in a single thread.\n" +
+			"The easiest variant to debug, but races between calls don't happen here.",
+		opts: func(base Options) (Options, bool) {
+			return singleThreaded(base), true
+		},
+	},
+	{
+		name: "threaded",
+		desc: "Executes each call once in a separate thread (like the fuzzer does),\n" +
+			"use it if the single-threaded variant does not trigger the bug.",
+		opts: func(base Options) (Options, bool) {
+			return threaded(base), true
+		},
+	},
+	{
+		name: "fault",
+		desc: "Same as threaded, but also injects faults (e.g. failing memory allocations)\n" +
+			"into the kernel code executed by the chosen calls. Requires CONFIG_FAULT_INJECTION.",
+		opts: func(base Options) (Options, bool) {
+			if !base.Fault {
+				return Options{}, false
+			}
+			opts := threaded(base)
+			opts.Fault = true
+			opts.FaultCall = base.FaultCall
+			opts.FaultNth = base.FaultNth
+			opts.Faults = base.Faults
+			return opts, true
+		},
+	},
+	{
+		name: "repeat",
+		desc: "Executes the program repeatedly in a loop in the same way the fuzzer did\n" +
+			"when the bug was found. Use it for bugs that don't happen on every execution.",
+		opts: func(base Options) (Options, bool) {
+			opts := base
+			opts.Repro = false
+			opts.Repeat = true
+			opts.WaitRepeat = true
+			return opts, true
+		},
+	},
+}
+
+// singleThreaded returns base options with threading, fault injection and repetition disabled.
+func singleThreaded(base Options) Options {
+	opts := base
+	opts.Threaded = false
+	opts.Collide = false
+	opts.ThreadAssignment = nil
+	opts.Fault = false
+	opts.FaultCall = 0
+	opts.FaultNth = 0
+	opts.Faults = nil
+	opts.Repeat = false
+	opts.RepeatTimes = 0
+	opts.Procs = 1
+	opts.WaitRepeat = false
+	opts.ProgramTimeout = 0
+	opts.RepeatTimeout = 0
+	opts.Repro = false
+	return opts
+}
+
+func threaded(base Options) Options {
+	opts := singleThreaded(base)
+	opts.Threaded = true
+	opts.Collide = base.Collide
+	opts.ThreadAssignment = base.ThreadAssignment
+	return opts
+}
+
+// WriteVariants generates a set of reproducers for p with different execution modes
+// (single-threaded, threaded, threaded with fault injection, repeated), so that
+// the whole bundle can be attached to a bug report and kernel developers can pick
+// the one that is easiest to work with. Environment options (sandbox, tun, etc)
+// are taken from base. The fault variant is generated only if base.Fault is set.
+// Each source starts with a comment that describes the variant and lists the others.
+func WriteVariants(p *prog.Prog, base Options) ([]*Variant, error) {
+	var variants []*Variant
+	var descs []variantDesc
+	for _, desc := range variantDescs {
+		opts, ok := desc.opts(base)
+		if !ok {
+			continue
+		}
+		variants = append(variants, &Variant{Name: desc.name, Opts: opts})
+		descs = append(descs, desc)
+	}
+	var names []string
+	for _, v := range variants {
+		names = append(names, v.Name)
+	}
+	for i, v := range variants {
+		src, err := Write(p, v.Opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %v variant: %v", v.Name, err)
+		}
+		hdr := new(bytes.Buffer)
+		fmt.Fprintf(hdr, "// Reproducer variant: %v.\n", v.Name)
+		for _, ln := range strings.Split(descs[i].desc, "\n") {
+			fmt.Fprintf(hdr, "// %v\n", ln)
+		}
+		fmt.Fprintf(hdr, "// All variants of this reproducer: %v.\n", strings.Join(names, ", "))
+		// Insert the header after the "autogenerated by syzkaller" line.
+		pos := bytes.IndexByte(src, '\n') + 1
+		v.Source = append(append(append([]byte{}, src[:pos]...), hdr.Bytes()...), src[pos:]...)
+	}
+	return variants, nil
+}
@@ -149,3 +149,43 @@ func tempFile(t *testing.T) string {
 	f.Close()
 	return f.Name()
 }
+
+func TestExportImport(t *testing.T) {
+	fn := tempFile(t)
+	defer os.Remove(fn)
+	db, err := Open(fn)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	db.Save("a", []byte("getpid()\n"), 1508112345)
+	db.Save("b", []byte("# comment\nclose(0x1)\n"), 42)
+	db.Save("c", nil, 0)
+	dir, err := ioutil.TempDir("", "syz-db-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := Export(db, dir); err != nil {
+		t.Fatal(err)
+	}
+	records, err := Import(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(db.Records) {
+		t.Fatalf("imported %v records, want %v", len(records), len(db.Records))
+	}
+	for _, rec := range records {
+		orig := db.Records[rec.Key]
+		if !bytes.Equal(rec.Val, orig.Val) || rec.Meta.Seq != orig.Seq {
+			t.Errorf("record %v: got %q/%v, want %q/%v", rec.Key, rec.Val, rec.Meta.Seq, orig.Val, orig.Seq)
+		}
+		if wantTime := orig.Seq >= minTimestamp; wantTime != !rec.Meta.Time.IsZero() ||
+			wantTime && rec.Meta.Time.Unix() != int64(orig.Seq) {
+			t.Errorf("record %v: bad time %v for seq %v", rec.Key, rec.Meta.Time, orig.Seq)
+		}
+	}
+	if _, _, err := ParseExported([]byte("# syz-db: seq x\ngetpid()\n")); err == nil {
+		t.Errorf("no error for bad seq")
+	}
+}
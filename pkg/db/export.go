@@ -0,0 +1,141 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// Portable corpus format.
+// Export writes every record as a separate textual file <key>.syz in a directory.
+// Record metadata is stored in a header of "# syz-db: name value" comment lines,
+// which are ignored by program deserialization, so the files can be also passed
+// to syz-execprog directly. Unlike the binary database, the format does not depend
+// on the database version and can be diffed, reviewed and stored in git.
+
+const (
+	ExportExt    = ".syz"
+	exportPrefix = "# syz-db: "
+)
+
+// ExportMeta is metadata of an exported record.
+type ExportMeta struct {
+	Seq uint64
+	// Time when the record was added. syz-manager stores it as seq in corpus database,
+	// so it is derived from Seq for seqs that look like a timestamp.
+	Time time.Time
+}
+
+// Exported is a record read from a portable corpus directory.
+type Exported struct {
+	Key  string
+	Val  []byte
+	Meta ExportMeta
+}
+
+// minTimestamp is the smallest seq that is considered to be a unix timestamp (2001).
+const minTimestamp = 1e9
+
+// Export writes all records of db into dir in the portable format.
+func Export(db *DB, dir string) error {
+	if err := osutil.MkdirAll(dir); err != nil {
+		return err
+	}
+	for key, rec := range db.Records {
+		meta := ExportMeta{Seq: rec.Seq}
+		if rec.Seq >= minTimestamp {
+			meta.Time = time.Unix(int64(rec.Seq), 0).UTC()
+		}
+		if err := osutil.WriteFile(filepath.Join(dir, key+ExportExt), SerializeExported(rec.Val, meta)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import reads all records exported into dir by Export.
+// Records are returned sorted by key.
+func Import(dir string) ([]*Exported, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []*Exported
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ExportExt) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		val, meta, err := ParseExported(data)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", file.Name(), err)
+		}
+		res = append(res, &Exported{
+			Key:  strings.TrimSuffix(file.Name(), ExportExt),
+			Val:  val,
+			Meta: meta,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Key < res[j].Key
+	})
+	return res, nil
+}
+
+// SerializeExported returns contents of an exported record file.
+func SerializeExported(val []byte, meta ExportMeta) []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%vseq %v\n", exportPrefix, meta.Seq)
+	if !meta.Time.IsZero() {
+		fmt.Fprintf(buf, "%vtime %v\n", exportPrefix, meta.Time.Format(time.RFC3339))
+	}
+	buf.Write(val)
+	return buf.Bytes()
+}
+
+// ParseExported splits contents of an exported record file into value and metadata.
+// Unknown metadata entries are ignored for forward compatibility.
+func ParseExported(data []byte) ([]byte, ExportMeta, error) {
+	var meta ExportMeta
+	s := bufio.NewScanner(bytes.NewReader(data))
+	pos := 0
+	for s.Scan() {
+		ln := s.Text()
+		if !strings.HasPrefix(ln, exportPrefix) {
+			break
+		}
+		pos += len(ln) + 1
+		parts := strings.SplitN(ln[len(exportPrefix):], " ", 2)
+		if len(parts) != 2 {
+			return nil, meta, fmt.Errorf("bad metadata line %q", ln)
+		}
+		var err error
+		switch parts[0] {
+		case "seq":
+			meta.Seq, err = strconv.ParseUint(parts[1], 10, 64)
+		case "time":
+			meta.Time, err = time.Parse(time.RFC3339, parts[1])
+		}
+		if err != nil {
+			return nil, meta, fmt.Errorf("bad metadata line %q: %v", ln, err)
+		}
+	}
+	if pos > len(data) {
+		pos = len(data)
+	}
+	return data[pos:], meta, nil
+}
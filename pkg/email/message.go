@@ -0,0 +1,59 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Attachment is a file attached to an outgoing email.
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+// FormMessage returns an RFC 822 message with a plain text body and optional attachments,
+// suitable for sending with net/smtp.SendMail.
+func FormMessage(from string, to []string, subject, body string, attachments []Attachment) []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "From: %v\r\n", from)
+	fmt.Fprintf(buf, "To: %v\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(buf, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(buf, "Date: %v\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	if len(attachments) == 0 {
+		fmt.Fprintf(buf, "Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+		buf.WriteString(body)
+		return buf.Bytes()
+	}
+	mw := multipart.NewWriter(buf)
+	fmt.Fprintf(buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+	w, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"text/plain; charset=\"utf-8\""},
+	})
+	w.Write([]byte(body))
+	for _, a := range attachments {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=\"utf-8\""},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		enc := base64.StdEncoding.EncodeToString(a.Data)
+		// Lines must not be longer than 998 chars.
+		for len(enc) > 76 {
+			fmt.Fprintf(w, "%v\r\n", enc[:76])
+			enc = enc[76:]
+		}
+		fmt.Fprintf(w, "%v\r\n", enc)
+	}
+	mw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package email
+
+import (
+	"bytes"
+	"net/mail"
+	"reflect"
+	"testing"
+)
+
+func TestFormMessage(t *testing.T) {
+	body := "KASAN: use-after-free Read in foo\n\nreport\n"
+	attachments := []Attachment{
+		{"repro.syz", []byte("getpid()\n")},
+		{"repro.c", bytes.Repeat([]byte("int main() {}\n"), 100)},
+	}
+	for _, attach := range [][]Attachment{nil, attachments} {
+		data := FormMessage("syzkaller <syzkaller@example.com>", []string{"a@example.com", "b@example.com"},
+			"[syzkaller] KASAN: use-after-free Read in foo", body, attach)
+		email, err := Parse(bytes.NewReader(data), "syzkaller@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if email.Subject != "[syzkaller] KASAN: use-after-free Read in foo" {
+			t.Errorf("bad subject: %q", email.Subject)
+		}
+		if email.Body != body {
+			t.Errorf("bad body: %q, want %q", email.Body, body)
+		}
+		if want := []string{"a@example.com", "b@example.com"}; !reflect.DeepEqual(email.Cc, want) {
+			t.Errorf("bad recipients: %q, want %q", email.Cc, want)
+		}
+		msg, err := mail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, got, err := parseBody(msg.Body, msg.Header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(attach) {
+			t.Fatalf("got %v attachments, want %v", len(got), len(attach))
+		}
+		for i := range got {
+			if !bytes.Equal(got[i], attach[i].Data) {
+				t.Errorf("attachment %v: got %q, want %q", i, got[i], attach[i].Data)
+			}
+		}
+	}
+}
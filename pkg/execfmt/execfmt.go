@@ -0,0 +1,263 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package execfmt decodes programs serialized by prog.SerializeForExec.
+// The format is produced by prog and consumed by the executor and by a number of Go packages
+// (csource, ipc, etc). Go consumers must use this package instead of parsing the format manually,
+// so that the format is decoded in a single place and format changes are caught by the version check.
+package execfmt
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/syzkaller/prog"
+)
+
+// Prog is a decoded program. Instrs are indexed by instruction index,
+// that is, ResultArg.Index refers to Instrs[ResultArg.Index].
+type Prog struct {
+	Instrs []Instr
+}
+
+// Instr is one of *Copyin, *Copyout or *Call.
+type Instr interface {
+	instr()
+}
+
+// Copyin stores Arg at Addr before the next call.
+type Copyin struct {
+	Addr uint64
+	Arg  Arg
+}
+
+// Copyout loads Size bytes at Addr after the previous call,
+// the value can be referenced by ResultArg.
+type Copyout struct {
+	Addr uint64
+	Size uint64
+}
+
+// Call invokes a syscall with Args (each of them is either *ConstArg or *ResultArg).
+type Call struct {
+	Meta *prog.Syscall
+	Args []Arg
+}
+
+func (*Copyin) instr()  {}
+func (*Copyout) instr() {}
+func (*Call) instr()    {}
+
+// Arg is one of *ConstArg, *ResultArg, *DataArg or *CsumArg.
+type Arg interface {
+	arg()
+}
+
+type ConstArg struct {
+	Size           uint64
+	Value          uint64
+	BitfieldOffset uint64
+	BitfieldLength uint64
+}
+
+// ResultArg refers to the result of a preceding *Call or *Copyout instruction.
+type ResultArg struct {
+	Size  uint64
+	Index int
+	OpDiv uint64
+	OpAdd uint64
+}
+
+type DataArg struct {
+	Data []byte
+}
+
+type CsumArg struct {
+	Size   uint64
+	Kind   uint64 // prog.ExecArgCsumInet or prog.ExecArgCsumCrc32c
+	Chunks []CsumChunk
+}
+
+// CsumChunk is either a data chunk (Value is address of the data) or a const chunk.
+type CsumChunk struct {
+	Kind  uint64 // prog.ExecArgCsumChunkData or prog.ExecArgCsumChunkConst
+	Value uint64
+	Size  uint64
+}
+
+func (*ConstArg) arg()  {}
+func (*ResultArg) arg() {}
+func (*DataArg) arg()   {}
+func (*CsumArg) arg()   {}
+
+// CheckHeader checks that data starts with a header of the supported format version.
+func CheckHeader(data []byte) error {
+	if len(data) < 16 {
+		return fmt.Errorf("exec program is too short (%v bytes)", len(data))
+	}
+	if magic := binary.LittleEndian.Uint64(data); magic != prog.ExecMagic {
+		return fmt.Errorf("bad exec program magic 0x%x, want 0x%x", magic, prog.ExecMagic)
+	}
+	if ver := binary.LittleEndian.Uint64(data[8:]); ver != prog.ExecVersion {
+		return fmt.Errorf("unsupported exec format version %v, want %v", ver, prog.ExecVersion)
+	}
+	return nil
+}
+
+// Decode decodes program serialized for target.
+// data must contain exactly one program (the ExecInstrEOF instruction must be the last word).
+func Decode(target *prog.Target, data []byte) (*Prog, error) {
+	if err := CheckHeader(data); err != nil {
+		return nil, err
+	}
+	d := &decoder{
+		target: target,
+		data:   data,
+		pos:    16,
+		p:      new(Prog),
+	}
+	if err := d.decode(); err != nil {
+		return nil, fmt.Errorf("bad exec program at offset %v (instruction %v): %v",
+			d.pos, len(d.p.Instrs), err)
+	}
+	return d.p, nil
+}
+
+const maxArgs = 9 // kMaxArgs in executor
+
+type decoder struct {
+	target *prog.Target
+	data   []byte
+	pos    int
+	p      *Prog
+	err    error
+}
+
+func (d *decoder) decode() error {
+	for {
+		instr := d.read()
+		if d.err != nil {
+			return d.err
+		}
+		switch instr {
+		case prog.ExecInstrEOF:
+			if d.pos != len(d.data) {
+				return fmt.Errorf("%v trailing bytes after EOF", len(d.data)-d.pos)
+			}
+			return nil
+		case prog.ExecInstrCopyin:
+			addr := d.read()
+			arg := d.readArg(true)
+			d.p.Instrs = append(d.p.Instrs, &Copyin{Addr: addr, Arg: arg})
+		case prog.ExecInstrCopyout:
+			addr := d.read()
+			size := d.readSize()
+			d.p.Instrs = append(d.p.Instrs, &Copyout{Addr: addr, Size: size})
+		default:
+			if instr >= uint64(len(d.target.Syscalls)) {
+				return fmt.Errorf("bad syscall ID %v", instr)
+			}
+			c := &Call{Meta: d.target.Syscalls[instr]}
+			nargs := d.read()
+			if d.err == nil && nargs > maxArgs {
+				return fmt.Errorf("call %v has %v args", c.Meta.Name, nargs)
+			}
+			for i := uint64(0); i < nargs && d.err == nil; i++ {
+				c.Args = append(c.Args, d.readArg(false))
+			}
+			d.p.Instrs = append(d.p.Instrs, c)
+		}
+		if d.err != nil {
+			return d.err
+		}
+	}
+}
+
+func (d *decoder) readArg(copyin bool) Arg {
+	typ := d.read()
+	switch typ {
+	case prog.ExecArgConst:
+		return &ConstArg{
+			Size:           d.readSize(),
+			Value:          d.read(),
+			BitfieldOffset: d.read(),
+			BitfieldLength: d.read(),
+		}
+	case prog.ExecArgResult:
+		arg := &ResultArg{Size: d.readSize()}
+		idx := d.read()
+		if d.err == nil && idx >= uint64(len(d.p.Instrs)) {
+			d.fail("result refers to instruction %v", idx)
+		}
+		arg.Index = int(idx)
+		arg.OpDiv = d.read()
+		arg.OpAdd = d.read()
+		return arg
+	case prog.ExecArgData:
+		if !copyin {
+			d.fail("data arg in a call")
+			return nil
+		}
+		size := d.read()
+		if d.err != nil {
+			return nil
+		}
+		padded := (size + 7) / 8 * 8
+		if size > uint64(len(d.data)-d.pos) || padded > uint64(len(d.data)-d.pos) {
+			d.fail("data arg of size %v overflows the program", size)
+			return nil
+		}
+		arg := &DataArg{Data: d.data[d.pos : d.pos+int(size)]}
+		d.pos += int(padded)
+		return arg
+	case prog.ExecArgCsum:
+		if !copyin {
+			d.fail("checksum arg in a call")
+			return nil
+		}
+		arg := &CsumArg{Size: d.readSize(), Kind: d.read()}
+		if d.err == nil && arg.Kind != prog.ExecArgCsumInet && arg.Kind != prog.ExecArgCsumCrc32c {
+			d.fail("unknown checksum kind %v", arg.Kind)
+		}
+		nchunks := d.read()
+		for i := uint64(0); i < nchunks && d.err == nil; i++ {
+			chunk := CsumChunk{Kind: d.read(), Value: d.read(), Size: d.read()}
+			if d.err == nil && chunk.Kind != prog.ExecArgCsumChunkData &&
+				chunk.Kind != prog.ExecArgCsumChunkConst {
+				d.fail("unknown checksum chunk kind %v", chunk.Kind)
+			}
+			arg.Chunks = append(arg.Chunks, chunk)
+		}
+		return arg
+	default:
+		d.fail("unknown arg type %v", typ)
+		return nil
+	}
+}
+
+func (d *decoder) readSize() uint64 {
+	size := d.read()
+	if d.err == nil && size != 1 && size != 2 && size != 4 && size != 8 {
+		d.fail("bad arg size %v", size)
+	}
+	return size
+}
+
+func (d *decoder) read() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data)-d.pos < 8 {
+		d.fail("unexpected end of program")
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(d.data[d.pos:])
+	d.pos += 8
+	return v
+}
+
+func (d *decoder) fail(msg string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(msg, args...)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package execfmt
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+)
+
+func serialize(t *testing.T, p *prog.Prog) []byte {
+	buf := make([]byte, prog.ExecBufferSize)
+	n, err := p.SerializeForExec(buf, 0)
+	if err != nil {
+		t.Fatalf("failed to serialize: %v", err)
+	}
+	return buf[:n]
+}
+
+func TestDecodeRandom(t *testing.T) {
+	seed := time.Now().UnixNano()
+	t.Logf("seed=%v", seed)
+	iters := 100
+	if testing.Short() {
+		iters = 10
+	}
+	for _, target := range prog.AllTargets() {
+		target := target
+		rs := rand.NewSource(seed)
+		t.Run(target.OS+"/"+target.Arch, func(t *testing.T) {
+			t.Parallel()
+			for i := 0; i < iters; i++ {
+				p := target.Generate(rs, 10, nil)
+				ep, err := Decode(target, serialize(t, p))
+				if err != nil {
+					t.Fatalf("failed to decode: %v\n%s", err, p.Serialize())
+				}
+				var calls []*Call
+				for _, instr := range ep.Instrs {
+					if c, ok := instr.(*Call); ok {
+						calls = append(calls, c)
+					}
+				}
+				if len(calls) != len(p.Calls) {
+					t.Fatalf("decoded %v calls, want %v\n%s", len(calls), len(p.Calls), p.Serialize())
+				}
+				for ci, c := range calls {
+					if c.Meta != p.Calls[ci].Meta || len(c.Args) != len(p.Calls[ci].Args) {
+						t.Fatalf("call %v: decoded %v(%v args), want %v(%v args)", ci,
+							c.Meta.Name, len(c.Args), p.Calls[ci].Meta.Name, len(p.Calls[ci].Args))
+					}
+				}
+				for idx := range p.ExecResults() {
+					switch ep.Instrs[idx].(type) {
+					case *Call, *Copyout:
+					default:
+						t.Fatalf("result %v refers to %T instruction", idx, ep.Instrs[idx])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte(
+		"r0 = syz_test$res0()\n" +
+			"syz_test$res1(r0)\n" +
+			"syz_test$opt1(&(0x7f0000000000)=0x5)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep, err := Decode(target, serialize(t, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ep.Instrs) != 4 {
+		t.Fatalf("decoded %v instructions, want 4", len(ep.Instrs))
+	}
+	if c, ok := ep.Instrs[0].(*Call); !ok || c.Meta.Name != "syz_test$res0" || len(c.Args) != 0 {
+		t.Fatalf("bad instruction 0: %+v", ep.Instrs[0])
+	}
+	c, ok := ep.Instrs[1].(*Call)
+	if !ok || c.Meta.Name != "syz_test$res1" || len(c.Args) != 1 {
+		t.Fatalf("bad instruction 1: %+v", ep.Instrs[1])
+	}
+	if res, ok := c.Args[0].(*ResultArg); !ok || res.Index != 0 || res.Size != 4 {
+		t.Fatalf("bad result arg: %+v", c.Args[0])
+	}
+	copyin, ok := ep.Instrs[2].(*Copyin)
+	if !ok || copyin.Addr != target.DataOffset {
+		t.Fatalf("bad instruction 2: %+v", ep.Instrs[2])
+	}
+	if arg, ok := copyin.Arg.(*ConstArg); !ok || arg.Value != 5 || arg.Size != 8 {
+		t.Fatalf("bad copyin arg: %+v", copyin.Arg)
+	}
+	c, ok = ep.Instrs[3].(*Call)
+	if !ok || c.Meta.Name != "syz_test$opt1" || len(c.Args) != 1 {
+		t.Fatalf("bad instruction 3: %+v", ep.Instrs[3])
+	}
+	if arg, ok := c.Args[0].(*ConstArg); !ok || arg.Value != target.DataOffset || arg.Size != 8 {
+		t.Fatalf("bad pointer arg: %+v", c.Args[0])
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte("syz_test$int(0x1, 0x2, 0x3, 0x4, 0x5)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := serialize(t, p)
+	if _, err := Decode(target, valid); err != nil {
+		t.Fatal(err)
+	}
+	modify := func(f func(data []byte) []byte) []byte {
+		return f(append([]byte{}, valid...))
+	}
+	put := func(data []byte, word int, v uint64) []byte {
+		binary.LittleEndian.PutUint64(data[word*8:], v)
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"magic", modify(func(data []byte) []byte { return put(data, 0, 0xbadc0ffeebadface) })},
+		{"version", modify(func(data []byte) []byte { return put(data, 1, prog.ExecVersion+1) })},
+		{"truncated", valid[:len(valid)-8]},
+		{"trailing", append(append([]byte{}, valid...), valid[:8]...)},
+		{"syscall", modify(func(data []byte) []byte { return put(data, 2, 1<<20) })},
+		{"nargs", modify(func(data []byte) []byte { return put(data, 3, 100) })},
+		{"arg type", modify(func(data []byte) []byte { return put(data, 4, 42) })},
+		{"arg size", modify(func(data []byte) []byte { return put(data, 5, 3) })},
+		{"data in call", modify(func(data []byte) []byte { return put(data, 4, prog.ExecArgData) })},
+	}
+	for _, test := range tests {
+		if _, err := Decode(target, test.data); err == nil {
+			t.Errorf("%v: decoded bad program", test.name)
+		} else {
+			t.Logf("%v: %v", test.name, err)
+		}
+	}
+}
+
+// TestExecutorVersion checks that the executor is updated together with the format.
+func TestExecutorVersion(t *testing.T) {
+	data, err := ioutil.ReadFile("../../executor/executor.h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]uint64{
+		"kExecMagic":   prog.ExecMagic,
+		"kExecVersion": prog.ExecVersion,
+	} {
+		re := regexp.MustCompile(`const uint64_t ` + name + ` = (0x[0-9a-f]+|[0-9]+);`)
+		match := re.FindSubmatch(data)
+		if match == nil {
+			t.Fatalf("can't find %v in executor.h", name)
+		}
+		v, err := strconv.ParseUint(string(match[1]), 0, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != want {
+			t.Errorf("executor %v = 0x%x, but prog uses 0x%x", name, v, want)
+		}
+	}
+}
@@ -83,6 +83,54 @@ func NewContext() (*Context, error) {
 }
 
 func (ctx *Context) CreateInstance(name, machineType, image, sshkey string) (string, error) {
+	prefix := "https://www.googleapis.com/compute/v1/projects/" + ctx.ProjectID
+	disk := &compute.AttachedDisk{
+		AutoDelete: true,
+		Boot:       true,
+		Type:       "PERSISTENT",
+		InitializeParams: &compute.AttachedDiskInitializeParams{
+			DiskName:    name,
+			SourceImage: prefix + "/global/images/" + image,
+		},
+	}
+	return ctx.createInstance(name, machineType, disk, sshkey)
+}
+
+// CreateInstanceFromSnapshot is the same as CreateInstance, but the boot disk
+// of the instance is restored from the snapshot created with CreateSnapshot.
+func (ctx *Context) CreateInstanceFromSnapshot(name, machineType, snapshot, sshkey string) (string, error) {
+	prefix := "https://www.googleapis.com/compute/v1/projects/" + ctx.ProjectID
+	// The disk can be left from a previous instance that was not deleted properly.
+	if err := ctx.DeleteDisk(name); err != nil {
+		return "", err
+	}
+	disk := &compute.Disk{
+		Name:           name,
+		SourceSnapshot: prefix + "/global/snapshots/" + snapshot,
+	}
+	<-ctx.apiRateGate
+	op, err := ctx.computeService.Disks.Insert(ctx.ProjectID, ctx.ZoneID, disk).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to create disk: %v", err)
+	}
+	if err := ctx.waitForCompletion("zone", "create disk", op.Name, false); err != nil {
+		return "", err
+	}
+	attached := &compute.AttachedDisk{
+		AutoDelete: true,
+		Boot:       true,
+		Type:       "PERSISTENT",
+		Source:     prefix + "/zones/" + ctx.ZoneID + "/disks/" + name,
+	}
+	ip, err := ctx.createInstance(name, machineType, attached, sshkey)
+	if err != nil {
+		ctx.DeleteDisk(name)
+		return "", err
+	}
+	return ip, nil
+}
+
+func (ctx *Context) createInstance(name, machineType string, disk *compute.AttachedDisk, sshkey string) (string, error) {
 	prefix := "https://www.googleapis.com/compute/v1/projects/" + ctx.ProjectID
 	sshkeyAttr := "syzkaller:" + sshkey
 	oneAttr := "1"
@@ -91,17 +139,7 @@ func (ctx *Context) CreateInstance(name, machineType, image, sshkey string) (str
 		Name:        name,
 		Description: "syzkaller worker",
 		MachineType: prefix + "/zones/" + ctx.ZoneID + "/machineTypes/" + machineType,
-		Disks: []*compute.AttachedDisk{
-			{
-				AutoDelete: true,
-				Boot:       true,
-				Type:       "PERSISTENT",
-				InitializeParams: &compute.AttachedDiskInitializeParams{
-					DiskName:    name,
-					SourceImage: prefix + "/global/images/" + image,
-				},
-			},
-		},
+		Disks:       []*compute.AttachedDisk{disk},
 		Metadata: &compute.Metadata{
 			Items: []*compute.MetadataItems{
 				{
@@ -228,6 +266,52 @@ func (ctx *Context) DeleteImage(imageName string) error {
 	return nil
 }
 
+// CreateSnapshot creates snapshot snapshotName of the boot disk of instance instanceName.
+func (ctx *Context) CreateSnapshot(snapshotName, instanceName string) error {
+	snapshot := &compute.Snapshot{
+		Name: snapshotName,
+	}
+	<-ctx.apiRateGate
+	op, err := ctx.computeService.Disks.CreateSnapshot(ctx.ProjectID, ctx.ZoneID, instanceName, snapshot).Do()
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %v", err)
+	}
+	if err := ctx.waitForCompletion("zone", "create snapshot", op.Name, false); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ctx *Context) DeleteSnapshot(snapshotName string) error {
+	<-ctx.apiRateGate
+	op, err := ctx.computeService.Snapshots.Delete(ctx.ProjectID, snapshotName).Do()
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == 404 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot: %v", err)
+	}
+	if err := ctx.waitForCompletion("global", "delete snapshot", op.Name, true); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ctx *Context) DeleteDisk(diskName string) error {
+	<-ctx.apiRateGate
+	op, err := ctx.computeService.Disks.Delete(ctx.ProjectID, ctx.ZoneID, diskName).Do()
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == 404 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete disk: %v", err)
+	}
+	if err := ctx.waitForCompletion("zone", "delete disk", op.Name, true); err != nil {
+		return err
+	}
+	return nil
+}
+
 type resourcePoolExhaustedError string
 
 func (err resourcePoolExhaustedError) Error() string {
@@ -5,8 +5,12 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
@@ -94,3 +98,146 @@ func ListRecentCommits(dir, baseCommit string) ([]string, error) {
 	}
 	return strings.Split(string(output), "\n"), nil
 }
+
+// Commit describes a single git commit.
+type Commit struct {
+	Hash   string
+	Title  string
+	Author string
+	Date   time.Time
+}
+
+// GetCommit returns description of the specified commit.
+func GetCommit(dir, commit string) (*Commit, error) {
+	output, err := osutil.RunCmd(timeout, dir, "git", "log", "-n", "1",
+		"--pretty=format:%H%n%s%n%ae%n%ct", commit)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(output), "\n")
+	if len(lines) < 4 {
+		return nil, fmt.Errorf("unexpected git log output: %q", output)
+	}
+	unix, err := strconv.ParseInt(strings.TrimSpace(lines[3]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse commit date %q: %v", lines[3], err)
+	}
+	com := &Commit{
+		Hash:   lines[0],
+		Title:  lines[1],
+		Author: lines[2],
+		Date:   time.Unix(unix, 0),
+	}
+	return com, nil
+}
+
+// Checkout checkouts the specified commit in dir.
+func Checkout(dir, commit string) error {
+	_, err := osutil.RunCmd(timeout, dir, "git", "checkout", commit)
+	return err
+}
+
+type BisectResult int
+
+const (
+	BisectOld BisectResult = iota
+	BisectNew
+	BisectSkip
+)
+
+// Bisect finds the first commit in (old, new] range that has the "new" property.
+// pred is invoked with the commit under test checked out in dir and says if the
+// commit has the old or the new property, or that it can't be tested.
+// Returns the first new commit, or the set of candidate commits if some
+// commits had to be skipped. trace receives git bisect output.
+func Bisect(dir, old, new string, trace io.Writer, pred func() (BisectResult, error)) ([]*Commit, error) {
+	osutil.RunCmd(timeout, dir, "git", "bisect", "reset")
+	defer osutil.RunCmd(timeout, dir, "git", "bisect", "reset")
+	output, err := runBisect(dir, "start", "--term-old=old", "--term-new=new", new, old)
+	if err != nil {
+		return nil, err
+	}
+	trace.Write(output)
+	for {
+		hashes, done := parseBisectOutput(output)
+		if done {
+			var commits []*Commit
+			for _, hash := range hashes {
+				com, err := GetCommit(dir, hash)
+				if err != nil {
+					return nil, err
+				}
+				commits = append(commits, com)
+			}
+			return commits, nil
+		}
+		res, err := pred()
+		if err != nil {
+			return nil, err
+		}
+		term := "skip"
+		switch res {
+		case BisectOld:
+			term = "old"
+		case BisectNew:
+			term = "new"
+		}
+		output, err = runBisect(dir, term)
+		if err != nil {
+			return nil, err
+		}
+		trace.Write(output)
+	}
+}
+
+// runBisect runs git bisect command and returns its output.
+// git bisect exits with non-zero status when only skipped commits are left,
+// so we need to look at the output regardless of the exit status.
+func runBisect(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"bisect"}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if _, done := parseBisectOutput(output); err != nil && !done {
+		return nil, fmt.Errorf("git bisect %v failed: %v\n%s", args, err, output)
+	}
+	return output, nil
+}
+
+func parseBisectOutput(output []byte) ([]string, bool) {
+	lines := strings.Split(string(output), "\n")
+	for i, line := range lines {
+		if strings.HasSuffix(line, " is the first new commit") {
+			return []string{strings.Fields(line)[0]}, true
+		}
+		if strings.HasPrefix(line, "The first new commit could be any of:") {
+			var hashes []string
+			for _, hash := range lines[i+1:] {
+				hash = strings.TrimSpace(hash)
+				if len(hash) != 40 {
+					break
+				}
+				hashes = append(hashes, hash)
+			}
+			return hashes, true
+		}
+	}
+	return nil, false
+}
+
+// Patch applies the patch to the repository in dir.
+func Patch(dir string, patch []byte) error {
+	// Do --dry-run first to not mess with partially consistent state.
+	cmd := exec.Command("patch", "-p1", "--force", "--ignore-whitespace", "--dry-run")
+	cmd.Stdin = bytes.NewReader(patch)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply patch:\n%s", output)
+	}
+	cmd = exec.Command("patch", "-p1", "--force", "--ignore-whitespace")
+	cmd.Stdin = bytes.NewReader(patch)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply patch after dry run:\n%s", output)
+	}
+	return nil
+}
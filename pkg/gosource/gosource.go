@@ -0,0 +1,339 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package gosource generates Go programs equivalent to syzkaller programs.
+// The programs use only syscall package and can be embedded into Go-based test harnesses.
+// Pseudo-syscalls (syz_*) are not supported.
+package gosource
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/execfmt"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+type Options struct {
+	Threaded bool // execute each call in a separate goroutine locked to an OS thread
+	Repeat   bool // repeat the program infinitely
+}
+
+// Supported returns an error if Go programs can't be generated for the target.
+func Supported(target *prog.Target) error {
+	sysTarget := targets.List[target.OS][target.Arch]
+	if sysTarget == nil || !sysTarget.SyscallNumbers {
+		return fmt.Errorf("unsupported target %v/%v", target.OS, target.Arch)
+	}
+	switch target.OS {
+	case "linux", "freebsd":
+		return nil
+	default:
+		return fmt.Errorf("unsupported OS: %v", target.OS)
+	}
+}
+
+func Write(p *prog.Prog, opts Options) ([]byte, error) {
+	if err := Supported(p.Target); err != nil {
+		return nil, err
+	}
+	for _, c := range p.Calls {
+		if c.Meta.CallName == "syz_test" {
+			continue // not emitted
+		}
+		if strings.HasPrefix(c.Meta.CallName, "syz_") {
+			return nil, fmt.Errorf("pseudo-syscall %v is not supported", c.Meta.CallName)
+		}
+		if len(c.Args) > 6 {
+			return nil, fmt.Errorf("syscall %v has more than 6 arguments", c.Meta.Name)
+		}
+	}
+	exec := make([]byte, prog.ExecBufferSize)
+	progSize, err := p.SerializeForExec(exec, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize program: %v", err)
+	}
+	decoded, err := execfmt.Decode(p.Target, exec[:progSize])
+	if err != nil {
+		return nil, err
+	}
+	ctx := &context{
+		p:    p,
+		opts: opts,
+		w:    new(bytes.Buffer),
+	}
+	calls, nvar := ctx.generateCalls(decoded)
+
+	ctx.print("// autogenerated by syzkaller (http://github.com/google/syzkaller)\n\n")
+	ctx.print("package main\n\n")
+	ctx.print("import (\n")
+	if opts.Threaded {
+		ctx.print("\t\"math/rand\"\n")
+		ctx.print("\t\"runtime\"\n")
+	}
+	ctx.print("\t\"runtime/debug\"\n")
+	if opts.Threaded {
+		ctx.print("\t\"sync\"\n")
+	}
+	ctx.print("\t\"syscall\"\n")
+	if opts.Threaded {
+		ctx.print("\t\"time\"\n")
+	}
+	ctx.print("\t\"unsafe\"\n")
+	ctx.print(")\n\n")
+	ctx.printf("var r [%v]uintptr\n\n", nvar)
+	ctx.print(helperNonfailing)
+	if ctx.needBitmask {
+		ctx.print(helperBitmask)
+	}
+	if ctx.needCsum {
+		ctx.print(helperCsum)
+	}
+	if ctx.needCrc32c {
+		ctx.print(helperCrc32c)
+	}
+	// Programs without memory accesses don't use unsafe otherwise.
+	ctx.print("var _ = unsafe.Pointer(nil)\n\n")
+	for i, c := range calls {
+		ctx.printf("func call%v() {\n%v}\n\n", i, c)
+	}
+	ctx.print("func loop() {\n")
+	ctx.print("\tfor i := range r {\n\t\tr[i] = ^uintptr(0)\n\t}\n")
+	if !opts.Threaded {
+		for i := range calls {
+			ctx.printf("\tcall%v()\n", i)
+		}
+	} else {
+		ctx.print("\tvar wg sync.WaitGroup\n")
+		ctx.printf("\tfor _, call := range []func(){")
+		for i := range calls {
+			if i != 0 {
+				ctx.print(", ")
+			}
+			ctx.printf("call%v", i)
+		}
+		ctx.print("} {\n")
+		ctx.print("\t\twg.Add(1)\n")
+		ctx.print("\t\tgo func(call func()) {\n")
+		ctx.print("\t\t\tdefer wg.Done()\n")
+		ctx.print("\t\t\truntime.LockOSThread()\n")
+		ctx.print("\t\t\tcall()\n")
+		ctx.print("\t\t}(call)\n")
+		ctx.print("\t\ttime.Sleep(time.Duration(rand.Intn(10000)) * time.Microsecond)\n")
+		ctx.print("\t}\n")
+		ctx.print("\twg.Wait()\n")
+	}
+	ctx.print("}\n\n")
+	ctx.print("func main() {\n")
+	ctx.print("\tdebug.SetPanicOnFault(true)\n")
+	if opts.Repeat {
+		ctx.print("\tfor {\n\t\tloop()\n\t}\n")
+	} else {
+		ctx.print("\tloop()\n")
+	}
+	ctx.print("}\n")
+
+	src, err := format.Source(ctx.w.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated program: %v\n%s", err, ctx.w.Bytes())
+	}
+	return src, nil
+}
+
+type context struct {
+	p           *prog.Prog
+	opts        Options
+	w           *bytes.Buffer
+	needBitmask bool
+	needCsum    bool
+	needCrc32c  bool
+}
+
+func (ctx *context) print(str string) {
+	ctx.w.WriteString(str)
+}
+
+func (ctx *context) printf(str string, args ...interface{}) {
+	ctx.print(fmt.Sprintf(str, args...))
+}
+
+func (ctx *context) generateCalls(exec *execfmt.Prog) ([]string, int) {
+	resultRef := func(arg *execfmt.ResultArg) string {
+		res := fmt.Sprintf("r[%v]", arg.Index)
+		if arg.OpDiv != 0 {
+			res = fmt.Sprintf("%v/%v", res, arg.OpDiv)
+		}
+		if arg.OpAdd != 0 {
+			res = fmt.Sprintf("%v+%v", res, arg.OpAdd)
+		}
+		return res
+	}
+	constMask := ^uint64(0)
+	if ctx.p.Target.PtrSize == 4 {
+		constMask = 1<<32 - 1
+	}
+	ptr := func(size, addr uint64) string {
+		return fmt.Sprintf("*(*uint%v)(unsafe.Pointer(uintptr(0x%x)))", size*8, addr)
+	}
+	lastCall := 0
+	seenCall := false
+	var calls []string
+	w := new(bytes.Buffer)
+	newCall := func() {
+		if seenCall {
+			seenCall = false
+			calls = append(calls, w.String())
+			w = new(bytes.Buffer)
+		}
+	}
+	for n, instr := range exec.Instrs {
+		switch instr := instr.(type) {
+		case *execfmt.Copyin:
+			newCall()
+			addr := instr.Addr
+			switch arg := instr.Arg.(type) {
+			case *execfmt.ConstArg:
+				size, val := arg.Size, arg.Value
+				if arg.BitfieldOffset == 0 && arg.BitfieldLength == 0 {
+					if size < 8 {
+						val &= 1<<(size*8) - 1
+					}
+					fmt.Fprintf(w, "\tnonfailing(func() { %v = 0x%x })\n", ptr(size, addr), val)
+				} else {
+					ctx.needBitmask = true
+					fmt.Fprintf(w, "\tnonfailing(func() { storeByBitmask(0x%x, %v, 0x%x, %v, %v) })\n",
+						addr, size, val, arg.BitfieldOffset, arg.BitfieldLength)
+				}
+			case *execfmt.ResultArg:
+				fmt.Fprintf(w, "\tnonfailing(func() { %v = uint%v(%v) })\n", ptr(arg.Size, addr), arg.Size*8, resultRef(arg))
+			case *execfmt.DataArg:
+				if size := len(arg.Data); size != 0 {
+					fmt.Fprintf(w, "\tnonfailing(func() { copy((*[%v]byte)(unsafe.Pointer(uintptr(0x%x)))[:], %q) })\n",
+						size, addr, string(arg.Data))
+				}
+			case *execfmt.CsumArg:
+				var update, initial, digest string
+				var csumSize uint64
+				switch arg.Kind {
+				case prog.ExecArgCsumInet:
+					ctx.needCsum = true
+					update, initial, digest, csumSize = "csumInetUpdate", "0", "uint16(^csum%v)", 2
+				case prog.ExecArgCsumCrc32c:
+					ctx.needCrc32c = true
+					update, initial, digest, csumSize = "crc32cUpdate", "0xffffffff", "^csum%v", 4
+				default:
+					panic(fmt.Sprintf("unknown csum kind %v", arg.Kind))
+				}
+				fmt.Fprintf(w, "\tcsum%v := uint32(%v)\n", n, initial)
+				for i, chunk := range arg.Chunks {
+					switch chunk.Kind {
+					case prog.ExecArgCsumChunkData:
+						fmt.Fprintf(w, "\tnonfailing(func() { %v(&csum%v, 0x%x, %v) })\n",
+							update, n, chunk.Value, chunk.Size)
+					case prog.ExecArgCsumChunkConst:
+						fmt.Fprintf(w, "\tcsum%vChunk%v := uint%v(0x%x)\n", n, i, chunk.Size*8, chunk.Value)
+						fmt.Fprintf(w, "\t%v(&csum%v, uintptr(unsafe.Pointer(&csum%vChunk%v)), %v)\n",
+							update, n, n, i, chunk.Size)
+					default:
+						panic(fmt.Sprintf("unknown checksum chunk kind %v", chunk.Kind))
+					}
+				}
+				fmt.Fprintf(w, "\tnonfailing(func() { %v = %v })\n", ptr(csumSize, addr), fmt.Sprintf(digest, n))
+			default:
+				panic(fmt.Sprintf("bad argument type %T", arg))
+			}
+		case *execfmt.Copyout:
+			fmt.Fprintf(w, "\tif r[%v] != ^uintptr(0) {\n", lastCall)
+			fmt.Fprintf(w, "\t\tnonfailing(func() { r[%v] = uintptr(%v) })\n", n, ptr(instr.Size, instr.Addr))
+			fmt.Fprintf(w, "\t}\n")
+		case *execfmt.Call:
+			newCall()
+			meta := instr.Meta
+			emitCall := meta.CallName != "syz_test"
+			var args []string
+			for _, arg := range instr.Args {
+				switch arg := arg.(type) {
+				case *execfmt.ConstArg:
+					// Bitfields can't be args of a normal syscall, so just ignore them.
+					args = append(args, fmt.Sprintf("0x%x", arg.Value&constMask))
+				case *execfmt.ResultArg:
+					args = append(args, resultRef(arg))
+				default:
+					panic(fmt.Sprintf("unknown arg type %T", arg))
+				}
+			}
+			if emitCall {
+				for len(args) < 6 {
+					args = append(args, "0")
+				}
+				fmt.Fprintf(w, "\tif res, _, errno := syscall.Syscall6(%v, %v); errno == 0 { // %v\n",
+					meta.NR, strings.Join(args, ", "), meta.CallName)
+				fmt.Fprintf(w, "\t\tr[%v] = res\n", n)
+				fmt.Fprintf(w, "\t}\n")
+			}
+			lastCall = n
+			seenCall = true
+		}
+	}
+	newCall()
+	return calls, len(exec.Instrs)
+}
+
+const helperNonfailing = `// nonfailing executes f ignoring faults on bad addresses
+// (programs can contain unmapped/protected addresses).
+func nonfailing(f func()) {
+	defer func() {
+		recover()
+	}()
+	f()
+}
+
+`
+
+const helperBitmask = `func storeByBitmask(addr uintptr, size int, val uint64, bfOff, bfLen uint) {
+	mask := uint64(1)<<bfLen - 1
+	switch size {
+	case 1:
+		p := (*uint8)(unsafe.Pointer(addr))
+		*p = *p&^uint8(mask<<bfOff) | uint8((val&mask)<<bfOff)
+	case 2:
+		p := (*uint16)(unsafe.Pointer(addr))
+		*p = *p&^uint16(mask<<bfOff) | uint16((val&mask)<<bfOff)
+	case 4:
+		p := (*uint32)(unsafe.Pointer(addr))
+		*p = *p&^uint32(mask<<bfOff) | uint32((val&mask)<<bfOff)
+	case 8:
+		p := (*uint64)(unsafe.Pointer(addr))
+		*p = *p&^uint64(mask<<bfOff) | uint64((val&mask)<<bfOff)
+	}
+}
+
+`
+
+const helperCsum = `func csumInetUpdate(acc *uint32, addr uintptr, length int) {
+	for i := 0; i+1 < length; i += 2 {
+		*acc += uint32(*(*uint16)(unsafe.Pointer(addr + uintptr(i))))
+	}
+	if length&1 != 0 {
+		*acc += uint32(*(*uint8)(unsafe.Pointer(addr + uintptr(length-1))))
+	}
+	for *acc > 0xffff {
+		*acc = *acc&0xffff + *acc>>16
+	}
+}
+
+`
+
+const helperCrc32c = `func crc32cUpdate(crc *uint32, addr uintptr, length int) {
+	for i := 0; i < length; i++ {
+		*crc ^= uint32(*(*uint8)(unsafe.Pointer(addr + uintptr(i))))
+		for j := 0; j < 8; j++ {
+			*crc = *crc>>1 ^ 0x82f63b78&-(*crc&1)
+		}
+	}
+}
+
+`
@@ -0,0 +1,81 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package gosource
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+)
+
+func TestGenerate(t *testing.T) {
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := time.Now().UnixNano()
+	t.Logf("seed=%v", seed)
+	rs := rand.NewSource(seed)
+	iters := 20
+	if testing.Short() {
+		iters = 5
+	}
+	dir, err := ioutil.TempDir("", "syz-gosource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// Pseudo-syscalls are not supported.
+	enabled := make(map[*prog.Syscall]bool)
+	for _, c := range target.Syscalls {
+		if !strings.HasPrefix(c.CallName, "syz_") {
+			enabled[c] = true
+		}
+	}
+	ct := target.BuildChoiceTable(target.CalculatePriorities(nil), enabled)
+	build := runtime.GOOS == "linux" && runtime.GOARCH == "amd64"
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, ct)
+		for _, opts := range []Options{{}, {Threaded: true, Repeat: true}} {
+			src, err := Write(p, opts)
+			if err != nil {
+				t.Fatalf("failed to generate program: %v\n%s", err, p.Serialize())
+			}
+			if !build {
+				continue
+			}
+			file := filepath.Join(dir, "prog.go")
+			if err := ioutil.WriteFile(file, src, 0644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), "vet", "-unsafeptr=false", file)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("failed to vet program: %v\n%s\n%s", err, out, src)
+			}
+		}
+	}
+}
+
+func TestPseudoSyscall(t *testing.T) {
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte("syz_open_pts(0x0, 0x0)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Write(p, Options{}); err == nil {
+		t.Fatal("pseudo-syscall is not rejected")
+	}
+}
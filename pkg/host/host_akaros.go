@@ -6,6 +6,8 @@
 package host
 
 import (
+	"fmt"
+
 	"github.com/google/syzkaller/prog"
 )
 
@@ -21,3 +23,7 @@ func DetectSupportedSyscalls(target *prog.Target) (map[*prog.Syscall]bool, error
 func EnableFaultInjection() error {
 	return nil
 }
+
+func KernelRanges() ([][2]uint64, error) {
+	return nil, fmt.Errorf("kernel leak detection is not supported")
+}
@@ -93,12 +93,21 @@ func isSupportedSyzkall(c *prog.Syscall) bool {
 		return osutil.IsExist("/dev/fuse")
 	case "syz_fuseblk_mount":
 		return osutil.IsExist("/dev/fuse") && syscall.Getuid() == 0
+	case "syz_mount_image":
+		return osutil.IsExist("/dev/loop-control") && syscall.Getuid() == 0
 	case "syz_emit_ethernet", "syz_extract_tcp_res":
 		fd, err := syscall.Open("/dev/net/tun", syscall.O_RDWR, 0)
 		if err == nil {
 			syscall.Close(fd)
 		}
 		return err == nil && syscall.Getuid() == 0
+	case "syz_usb_connect", "syz_usb_control_io", "syz_usb_disconnect":
+		return osutil.IsExist("/dev/raw-gadget") && syscall.Getuid() == 0
+	case "syz_binder_open", "syz_binder_transaction", "syz_binder_read":
+		return osutil.IsExist("/dev/binder") || osutil.IsExist("/dev/hwbinder") ||
+			osutil.IsExist("/dev/vndbinder")
+	case "syz_ashmem_create":
+		return osutil.IsExist("/dev/ashmem")
 	case "syz_kvm_setup_cpu":
 		switch c.Name {
 		case "syz_kvm_setup_cpu$x86":
@@ -170,3 +179,63 @@ func EnableFaultInjection() error {
 	}
 	return nil
 }
+
+// KernelRanges returns address ranges of the kernel image and modules.
+// Ranges are taken from /proc/kallsyms (requires root and kptr_restrict=0 or 1),
+// if it's not available a per-arch default that accounts for KASLR is used.
+func KernelRanges() ([][2]uint64, error) {
+	kallsyms, _ := ioutil.ReadFile("/proc/kallsyms")
+	if ranges := parseKernelRanges(kallsyms); len(ranges) != 0 {
+		return ranges, nil
+	}
+	if ranges := defaultKernelRanges[runtime.GOARCH]; len(ranges) != 0 {
+		return ranges, nil
+	}
+	return nil, fmt.Errorf("kallsyms are not available and no default kernel ranges for %v", runtime.GOARCH)
+}
+
+var defaultKernelRanges = map[string][][2]uint64{
+	// Kernel text mapping (the image is randomly placed inside of it with KASLR) and modules.
+	"amd64": {{0xffffffff80000000, 0xffffffffff000000}},
+}
+
+// parseKernelRanges returns [start, end) ranges covering kernel image and module symbols in kallsyms.
+func parseKernelRanges(kallsyms []byte) [][2]uint64 {
+	const (
+		pageSize   = 4 << 10
+		kernelHalf = uint64(^uintptr(0)>>1) + 1
+	)
+	var image, modules [2]uint64
+	for _, line := range bytes.Split(kallsyms, []byte{'\n'}) {
+		fields := bytes.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		addr, err := strconv.ParseUint(string(fields[0]), 16, 64)
+		// Absolute and per-cpu symbols have small addresses, and if kallsyms are restricted,
+		// all addresses are 0. Skip them.
+		if err != nil || addr < kernelHalf {
+			continue
+		}
+		r := &image
+		if len(fields) > 3 && fields[3][0] == '[' {
+			r = &modules
+		}
+		if r[0] == 0 || addr < r[0] {
+			r[0] = addr
+		}
+		if addr >= r[1] {
+			r[1] = addr + 1
+		}
+	}
+	var ranges [][2]uint64
+	for _, r := range [][2]uint64{image, modules} {
+		if r[0] != 0 {
+			// Symbols don't include the last function/object size, so round up to page.
+			r[0] &^= pageSize - 1
+			r[1] = (r[1] + pageSize - 1) &^ (pageSize - 1)
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
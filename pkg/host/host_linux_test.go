@@ -6,6 +6,7 @@
 package host
 
 import (
+	"reflect"
 	"runtime"
 	"syscall"
 	"testing"
@@ -84,3 +85,29 @@ func TestSupportedSyscalls(t *testing.T) {
 		}
 	}
 }
+
+func TestParseKernelRanges(t *testing.T) {
+	kallsyms := `0000000000000000 A irq_stack_union
+0000000000000000 A __per_cpu_start
+ffffffff81000000 T _stext
+ffffffff81000110 t secondary_startup_64
+ffffffff82a3c000 D init_task
+ffffffff8304d5e8 B _end
+ffffffffa0000000 t nf_nat_ipv4_fn	[nf_nat_ipv4]
+ffffffffa0012340 d nf_nat_ipv4_ops	[nf_nat_ipv4]
+`
+	want := [][2]uint64{
+		{0xffffffff81000000, 0xffffffff8304e000},
+		{0xffffffffa0000000, 0xffffffffa0013000},
+	}
+	got := parseKernelRanges([]byte(kallsyms))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got ranges %x, want %x", got, want)
+	}
+	restricted := `0000000000000000 T _stext
+0000000000000000 t secondary_startup_64
+`
+	if got := parseKernelRanges([]byte(restricted)); len(got) != 0 {
+		t.Fatalf("got ranges %x for restricted kallsyms", got)
+	}
+}
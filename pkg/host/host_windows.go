@@ -4,6 +4,8 @@
 package host
 
 import (
+	"fmt"
+
 	"github.com/google/syzkaller/prog"
 )
 
@@ -19,3 +21,7 @@ func DetectSupportedSyscalls(target *prog.Target) (map[*prog.Syscall]bool, error
 func EnableFaultInjection() error {
 	return nil
 }
+
+func KernelRanges() ([][2]uint64, error) {
+	return nil, fmt.Errorf("kernel leak detection is not supported")
+}
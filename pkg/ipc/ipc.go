@@ -13,12 +13,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 
+	"github.com/google/syzkaller/pkg/execfmt"
 	"github.com/google/syzkaller/pkg/host"
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/prog"
@@ -37,6 +39,9 @@ const (
 	FlagEnableFault                          // enable fault injection support
 	FlagUseShmem                             // use shared memory instead of pipes for communication
 	FlagUseForkServer                        // use extended protocol with handshake
+	FlagSandboxSeccomp                       // install seccomp filter denying Config.SeccompDeny syscalls
+	FlagDetectLeaks                          // detect kernel pointers leaked to user-space (see Config.KernelRanges)
+	FlagResourceLeaks                        // measure kernel objects after each program (see Env.Resources)
 )
 
 // Per-exec flags for ExecOpts.Flags:
@@ -45,6 +50,7 @@ const (
 	FlagDedupCover                       // deduplicate coverage in executor
 	FlagInjectFault                      // inject a fault in this execution (see ExecOpts)
 	FlagCollectComps                     // collect KCOV comparisons
+	FlagCollectErrno                     // return per-call info with errno even if signal is not collected
 )
 
 const (
@@ -59,12 +65,18 @@ var (
 	flagThreaded    = flag.Bool("threaded", true, "use threaded mode in executor")
 	flagCollide     = flag.Bool("collide", true, "collide syscalls to provoke data races")
 	flagSignal      = flag.Bool("cover", true, "collect feedback signals (coverage)")
-	flagSandbox     = flag.String("sandbox", "none", "sandbox for fuzzing (none/setuid/namespace)")
+	flagSandbox     = flag.String("sandbox", "none", "sandbox for fuzzing (none/setuid/namespace/seccomp)")
+	flagSeccompDeny = flag.String("seccomp_deny", "", "comma-separated syscall numbers denied in seccomp sandbox")
 	flagDebug       = flag.Bool("debug", false, "debug output from executor")
 	flagTimeout     = flag.Duration("timeout", 0, "execution timeout")
+	flagCallTimeout = flag.Duration("call_timeout", 0, "max time to wait for a single call in threaded mode")
+	flagProgTimeout = flag.Duration("program_timeout", 0, "max time executor lets a single program run")
+	flagGrace       = flag.Duration("shutdown_grace", 0, "time to wait for executor to exit on abort before SIGKILL")
 	flagAbortSignal = flag.Int("abort_signal", 0, "initial signal to send to executor in error conditions; upgrades to SIGKILL if executor does not exit")
 	flagBufferSize  = flag.Uint64("buffer_size", 0, "internal buffer size (in bytes) for executor output")
 	flagIPC         = flag.String("ipc", "", "ipc scheme (pipe/shmem)")
+	flagKaslrLeak   = flag.Bool("kaslr_leak", false, "detect kernel pointers leaked to user-space")
+	flagResLeak     = flag.Bool("resource_leak", false, "detect programs that leak kernel objects (files, sockets, etc)")
 )
 
 type ExecOpts struct {
@@ -89,11 +101,30 @@ type Config struct {
 	// Timeout is the execution timeout for a single program.
 	Timeout time.Duration
 
+	// CallTimeout is how long executor waits for a single call in threaded mode
+	// before moving on to the next call (20ms by default).
+	CallTimeout time.Duration
+
+	// ProgramTimeout is how long executor lets a single program run before killing
+	// the test process (3s by default). Timeout is adjusted to be larger than this.
+	ProgramTimeout time.Duration
+
+	// ShutdownGrace is how long to wait for executor to exit after AbortSignal
+	// before sending SIGKILL (5s by default).
+	ShutdownGrace time.Duration
+
 	// AbortSignal is the signal to send to the executor in error conditions.
 	AbortSignal int
 
 	// BufferSize is the size of the internal buffer for executor output.
 	BufferSize uint64
+
+	// SeccompDeny is the list of syscall numbers denied with FlagSandboxSeccomp.
+	SeccompDeny []uint64
+
+	// KernelRanges are [start, end) address ranges of kernel text and modules.
+	// With FlagDetectLeaks values from these ranges returned by calls are reported as leaks.
+	KernelRanges [][2]uint64
 }
 
 func DefaultConfig() (Config, error) {
@@ -113,13 +144,38 @@ func DefaultConfig() (Config, error) {
 		c.Flags |= FlagSandboxSetuid
 	case "namespace":
 		c.Flags |= FlagSandboxNamespace
+	case "seccomp":
+		c.Flags |= FlagSandboxSeccomp
 	default:
-		return Config{}, fmt.Errorf("flag sandbox must contain one of none/setuid/namespace")
+		return Config{}, fmt.Errorf("flag sandbox must contain one of none/setuid/namespace/seccomp")
+	}
+	if *flagSeccompDeny != "" {
+		for _, nr := range strings.Split(*flagSeccompDeny, ",") {
+			v, err := strconv.ParseUint(nr, 10, 64)
+			if err != nil {
+				return Config{}, fmt.Errorf("bad seccomp_deny syscall number %q", nr)
+			}
+			c.SeccompDeny = append(c.SeccompDeny, v)
+		}
 	}
 	if *flagDebug {
 		c.Flags |= FlagDebug
 	}
+	if *flagKaslrLeak {
+		ranges, err := host.KernelRanges()
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to detect kernel ranges: %v", err)
+		}
+		c.Flags |= FlagDetectLeaks
+		c.KernelRanges = ranges
+	}
+	if *flagResLeak {
+		c.Flags |= FlagResourceLeaks
+	}
 	c.Timeout = *flagTimeout
+	c.CallTimeout = *flagCallTimeout
+	c.ProgramTimeout = *flagProgTimeout
+	c.ShutdownGrace = *flagGrace
 	c.AbortSignal = *flagAbortSignal
 	c.BufferSize = *flagBufferSize
 
@@ -147,9 +203,24 @@ type CallInfo struct {
 	Signal []uint32 // feedback signal, filled if FlagSignal is set
 	Cover  []uint32 // per-call coverage, filled if FlagSignal is set and cover == true,
 	//if dedup == false, then cov effectively contains a trace, otherwise duplicates are removed
-	Comps         prog.CompMap // per-call comparison operands
-	Errno         int          // call errno (0 if the call was successful)
+	Comps         prog.CompMap  // per-call comparison operands
+	Errno         int           // call errno (0 if the call was successful)
+	Duration      time.Duration // time spent in the call itself (without copyin/copyout)
 	FaultInjected bool
+	Leak          uint64 // kernel pointer leaked by the call (0 if none), filled if FlagDetectLeaks is set
+}
+
+// FormatLeaks returns description of kernel pointers leaked by calls of p
+// (in the format recognized by report package), or "" if there are no leaks.
+func FormatLeaks(p *prog.Prog, info []CallInfo) string {
+	buf := new(bytes.Buffer)
+	for i, inf := range info {
+		if inf.Leak != 0 && i < len(p.Calls) {
+			fmt.Fprintf(buf, "KASLR leak: call #%v %v leaked kernel pointer 0x%x\n",
+				i, p.Calls[i].Meta.Name, inf.Leak)
+		}
+	}
+	return buf.String()
 }
 
 func GetCompMaps(info []CallInfo) []prog.CompMap {
@@ -171,6 +242,9 @@ type Env struct {
 	pid     int
 	config  Config
 
+	resources   Resources
+	resourcesOk bool
+
 	StatExecs    uint64
 	StatRestarts uint64
 }
@@ -182,11 +256,33 @@ const (
 	compConstMask = 1
 )
 
+const (
+	// Default Config.CallTimeout and Config.ProgramTimeout values.
+	// Note: sys knows about the call timeout when it generates timespec/timeval values.
+	DefaultCallTimeout    = 20 * time.Millisecond
+	DefaultProgramTimeout = 3 * time.Second
+)
+
 func MakeEnv(bin string, pid int, config Config) (*Env, error) {
-	const (
-		executorTimeout = 5 * time.Second
-		minTimeout      = executorTimeout + 2*time.Second
-	)
+	if config.CallTimeout == 0 {
+		config.CallTimeout = DefaultCallTimeout
+	}
+	if config.ProgramTimeout == 0 {
+		config.ProgramTimeout = DefaultProgramTimeout
+	}
+	if config.ShutdownGrace == 0 {
+		config.ShutdownGrace = 5 * time.Second
+	}
+	if config.CallTimeout < time.Millisecond || config.ProgramTimeout < config.CallTimeout {
+		return nil, fmt.Errorf("bad timeouts: call timeout %v, program timeout %v",
+			config.CallTimeout, config.ProgramTimeout)
+	}
+	if config.Flags&FlagDetectLeaks != 0 && len(config.KernelRanges) == 0 {
+		return nil, fmt.Errorf("leak detection is enabled, but kernel ranges are not set")
+	}
+	// Executor kills the test process after ProgramTimeout, but it takes some time.
+	executorTimeout := config.ProgramTimeout + 2*time.Second
+	minTimeout := executorTimeout + 2*time.Second
 	if config.Timeout == 0 {
 		// Executor protects against most hangs, so we use quite large timeout here.
 		// Executor can be slow due to global locks in namespaces and other things,
@@ -304,11 +400,23 @@ func (env *Env) Exec(opts *ExecOpts, p *prog.Prog) (output []byte, info []CallIn
 		err0 = fmt.Errorf("executor %v: failed to serialize: %v", env.pid, err)
 		return
 	}
+	// Catch format mismatches before the program reaches the executor.
+	// Full decoding is too expensive to be done for every execution.
+	if env.config.Flags&FlagDebug != 0 {
+		_, err = execfmt.Decode(p.Target, env.in[:progSize])
+	} else {
+		err = execfmt.CheckHeader(env.in[:progSize])
+	}
+	if err != nil {
+		err0 = fmt.Errorf("executor %v: %v", env.pid, err)
+		return
+	}
 	var progData []byte
 	if env.config.Flags&FlagUseShmem == 0 {
 		progData = env.in[:progSize]
 	}
-	needOutput := env.config.Flags&FlagSignal != 0 || opts.Flags&FlagCollectComps != 0
+	needOutput := env.config.Flags&(FlagSignal|FlagDetectLeaks) != 0 ||
+		opts.Flags&(FlagCollectComps|FlagCollectErrno) != 0
 	if needOutput && env.out != nil {
 		// Zero out the first two words (ncmd and nsig), so that we don't have garbage there
 		// if executor crashes before writing non-garbage there.
@@ -318,6 +426,8 @@ func (env *Env) Exec(opts *ExecOpts, p *prog.Prog) (output []byte, info []CallIn
 	}
 
 	atomic.AddUint64(&env.StatExecs, 1)
+	env.resourcesOk = false
+	restarted := env.cmd == nil
 	if env.cmd == nil {
 		atomic.AddUint64(&env.StatRestarts, 1)
 		env.cmd, err0 = makeCommand(env.pid, env.bin, env.config, env.inFile, env.outFile)
@@ -332,6 +442,11 @@ func (env *Env) Exec(opts *ExecOpts, p *prog.Prog) (output []byte, info []CallIn
 		env.cmd = nil
 		return
 	}
+	if env.config.Flags&FlagResourceLeaks != 0 && !restarted && !failed && !hanged {
+		// Counts measured after the first execution in a new executor
+		// are not comparable with the previous ones.
+		env.resources, env.resourcesOk = env.cmd.resources, true
+	}
 
 	if needOutput && env.out != nil {
 		info, err0 = env.readOutCoverage(p)
@@ -389,8 +504,10 @@ func (env *Env) readOutCoverage(p *prog.Prog) (info []CallInfo, err0 error) {
 		return buf.String()
 	}
 	for i := uint32(0); i < ncmd; i++ {
-		var callIndex, callNum, errno, faultInjected, signalSize, coverSize, compsSize uint32
-		if !readOut(&callIndex) || !readOut(&callNum) || !readOut(&errno) || !readOut(&faultInjected) || !readOut(&signalSize) || !readOut(&coverSize) || !readOut(&compsSize) {
+		var callIndex, callNum, errno, faultInjected, duration, leakLo, leakHi, signalSize, coverSize, compsSize uint32
+		if !readOut(&callIndex) || !readOut(&callNum) || !readOut(&errno) || !readOut(&faultInjected) ||
+			!readOut(&duration) || !readOut(&leakLo) || !readOut(&leakHi) ||
+			!readOut(&signalSize) || !readOut(&coverSize) || !readOut(&compsSize) {
 			err0 = fmt.Errorf("executor %v: failed to read output coverage", env.pid)
 			return
 		}
@@ -411,7 +528,9 @@ func (env *Env) readOutCoverage(p *prog.Prog) (info []CallInfo, err0 error) {
 			return
 		}
 		info[callIndex].Errno = int(errno)
+		info[callIndex].Duration = time.Duration(duration) * time.Microsecond
 		info[callIndex].FaultInjected = faultInjected != 0
+		info[callIndex].Leak = uint64(leakLo) | uint64(leakHi)<<32
 		if signalSize > uint32(len(out)) {
 			err0 = fmt.Errorf("executor %v: failed to read output signal: record %v, call %v, signalsize=%v coversize=%v",
 				env.pid, i, callIndex, signalSize, coverSize)
@@ -489,6 +608,8 @@ type command struct {
 	exited   chan struct{}
 	inrp     *os.File
 	outwp    *os.File
+
+	resources Resources // resources measured after the last execution
 }
 
 const (
@@ -513,7 +634,10 @@ type executeReq struct {
 	pid       uint64
 	faultCall uint64
 	faultNth  uint64
-	progSize  uint64
+	// Timeouts in milliseconds.
+	callTimeout    uint64
+	programTimeout uint64
+	progSize       uint64
 	// prog follows on pipe or in shmem
 }
 
@@ -521,16 +645,20 @@ type executeReply struct {
 	magic uint32
 	// If done is 0, then this is call completion message followed by callReply.
 	// If done is 1, then program execution is finished and status is set.
-	done   uint32
-	status uint32
+	done      uint32
+	status    uint32
+	pad       uint32
+	resources Resources // filled if FlagResourceLeaks is set
 }
 
 type callReply struct {
 	callIndex     uint32
 	callNum       uint32
 	errno         uint32
-	blocked       uint32
 	faultInjected uint32
+	duration      uint32
+	leakLo        uint32
+	leakHi        uint32
 	signalSize    uint32
 	coverSize     uint32
 	compsSize     uint32
@@ -591,6 +719,20 @@ func makeCommand(pid int, bin []string, config Config, inFile *os.File, outFile
 		cmd.ExtraFiles = []*os.File{inFile, outFile}
 	}
 	cmd.Env = []string{}
+	if config.Flags&FlagSandboxSeccomp != 0 && len(config.SeccompDeny) != 0 {
+		var deny []string
+		for _, nr := range config.SeccompDeny {
+			deny = append(deny, strconv.FormatUint(nr, 10))
+		}
+		cmd.Env = append(cmd.Env, "SYZ_SECCOMP_DENY="+strings.Join(deny, ","))
+	}
+	if config.Flags&FlagDetectLeaks != 0 {
+		var ranges []string
+		for _, r := range config.KernelRanges {
+			ranges = append(ranges, fmt.Sprintf("%x-%x", r[0], r[1]))
+		}
+		cmd.Env = append(cmd.Env, "SYZ_KERNEL_RANGES="+strings.Join(ranges, ","))
+	}
 	cmd.Dir = dir
 	cmd.Stdin = outrp
 	cmd.Stdout = inwp
@@ -715,13 +857,14 @@ func (c *command) handshakeError(err error) error {
 	return err
 }
 
-// abort sends the abort signal to the command and then SIGKILL if wait doesn't return within 5s.
+// abort sends the abort signal to the command and then SIGKILL
+// if wait doesn't return within ShutdownGrace.
 func (c *command) abort() {
 	if osutil.ProcessSignal(c.cmd.Process, c.config.AbortSignal) {
 		return
 	}
 	go func() {
-		t := time.NewTimer(5 * time.Second)
+		t := time.NewTimer(c.config.ShutdownGrace)
 		select {
 		case <-t.C:
 			c.cmd.Process.Kill()
@@ -752,6 +895,9 @@ func (c *command) exec(opts *ExecOpts, progData []byte) (output []byte, failed,
 		faultCall: uint64(opts.FaultCall),
 		faultNth:  uint64(opts.FaultNth),
 		progSize:  uint64(len(progData)),
+
+		callTimeout:    uint64(c.config.CallTimeout / time.Millisecond),
+		programTimeout: uint64(c.config.ProgramTimeout / time.Millisecond),
 	}
 	reqData := (*[unsafe.Sizeof(*req)]byte)(unsafe.Pointer(req))[:]
 	if _, err := c.outwp.Write(reqData); err != nil {
@@ -803,6 +949,7 @@ func (c *command) exec(opts *ExecOpts, progData []byte) (output []byte, failed,
 			}
 			if reply.status == 0 {
 				// Program was OK.
+				c.resources = reply.resources
 				<-hang
 				return
 			}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"syscall"
 	"testing"
 	"time"
 
@@ -123,3 +124,67 @@ func TestExecute(t *testing.T) {
 		}
 	}
 }
+
+func TestCallInfo(t *testing.T) {
+	target, _, _, flags0 := initTest(t)
+	if target.OS != "linux" {
+		t.Skip("the test program is linux-specific")
+	}
+	bin := buildExecutor(t, target)
+	defer os.Remove(bin)
+	env, err := MakeEnv(bin, 0, Config{Flags: flags0, Timeout: timeout})
+	if err != nil {
+		t.Fatalf("failed to create env: %v", err)
+	}
+	defer env.Close()
+	p, err := target.Deserialize([]byte(`mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)
+nanosleep(&(0x7f0000000000)={0x0, 0x989680}, 0x0)
+close(0xffffffffffffffff)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, info, _, _, err := env.Exec(&ExecOpts{Flags: FlagCollectErrno}, p)
+	if err != nil {
+		t.Fatalf("failed to run executor: %v\n%s", err, output)
+	}
+	if len(info) != 3 {
+		t.Fatalf("got %v call infos, want 3", len(info))
+	}
+	if info[1].Errno != 0 || info[1].Duration < 10*time.Millisecond {
+		t.Errorf("nanosleep: errno %v, duration %v, want 0, >=10ms", info[1].Errno, info[1].Duration)
+	}
+	if info[2].Errno != int(syscall.EBADF) {
+		t.Errorf("close: errno %v, want EBADF", info[2].Errno)
+	}
+}
+
+func TestLeakDetector(t *testing.T) {
+	var d LeakDetector
+	var res Resources
+	res[ResourceMemcg] = 100 << 20
+	for i := 0; i < 3*LeakCheckRuns; i++ {
+		res[ResourceFiles] += uint64(i % 2)
+		res[ResourceMemcg] += 4 << 10
+		if leaks := d.Add(res); leaks != "" {
+			t.Fatalf("got leak on iteration %v: %s", i, leaks)
+		}
+	}
+	for i := 0; i < LeakCheckRuns-2; i++ {
+		res[ResourceSockets] += 2
+		if leaks := d.Add(res); leaks != "" {
+			t.Fatalf("got leak on iteration %v: %s", i, leaks)
+		}
+	}
+	res[ResourceSockets] += 2
+	want := fmt.Sprintf("resource leak: sockets grew from 0 to %v in %v executions\n",
+		2*(LeakCheckRuns-1), LeakCheckRuns-1)
+	if leaks := d.Add(res); leaks != want {
+		t.Fatalf("got leaks:\n%s\nwant:\n%s", leaks, want)
+	}
+	// The detector must be reset after a report.
+	res[ResourceSockets] += 2
+	if leaks := d.Add(res); leaks != "" {
+		t.Fatalf("got leak after reset: %s", leaks)
+	}
+}
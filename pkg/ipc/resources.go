@@ -0,0 +1,78 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package ipc
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Resources are global counts of kernel objects measured by executor
+// after a program has finished and its test process has exited.
+// The order must match measure_resources in executor.
+type Resources [NumResources]uint64
+
+const (
+	ResourceFiles       = iota // allocated file handles (/proc/sys/fs/file-nr)
+	ResourceSockets            // sockets in use (/proc/net/sockstat)
+	ResourceLoopDevices        // loop devices bound to a backing file
+	ResourceMemcg              // memory cgroup usage in bytes
+	NumResources
+)
+
+var resourceNames = [NumResources]string{"files", "sockets", "loop devices", "memcg usage"}
+
+// resourceMinGrowth is the min growth of each resource per execution that is considered a leak.
+// Memory cgroup usage is noisy (page cache, slab caches), so small growth is ignored.
+var resourceMinGrowth = [NumResources]uint64{1, 1, 1, 256 << 10}
+
+// LeakCheckRuns is the number of consecutive executions of the same program
+// after which LeakDetector reports monotonically growing resources.
+const LeakCheckRuns = 8
+
+// Resources returns resources measured after the last execution.
+// ok is false if resource leak detection is not enabled, the execution failed
+// or the executor was restarted (in such case LeakDetector must be reset).
+func (env *Env) Resources() (res Resources, ok bool) {
+	return env.resources, env.resourcesOk
+}
+
+// LeakDetector detects programs that leak kernel objects: it is fed with resources
+// measured after consecutive executions of the same program and reports resources
+// that grew after each of the last LeakCheckRuns executions.
+type LeakDetector struct {
+	history []Resources
+}
+
+func (d *LeakDetector) Reset() {
+	d.history = d.history[:0]
+}
+
+// Add adds resources measured after the next execution and returns description
+// of leaked resources (in the format recognized by report package), or "" if there are no leaks.
+// The detector is reset after a leak is reported.
+func (d *LeakDetector) Add(res Resources) string {
+	d.history = append(d.history, res)
+	if len(d.history) > LeakCheckRuns {
+		d.history = d.history[1:]
+	}
+	if len(d.history) < LeakCheckRuns {
+		return ""
+	}
+	buf := new(bytes.Buffer)
+	for r := 0; r < NumResources; r++ {
+		leak := true
+		for i := 1; i < len(d.history) && leak; i++ {
+			leak = d.history[i][r] >= d.history[i-1][r]+resourceMinGrowth[r]
+		}
+		if leak {
+			fmt.Fprintf(buf, "resource leak: %v grew from %v to %v in %v executions\n",
+				resourceNames[r], d.history[0][r], d.history[len(d.history)-1][r], len(d.history)-1)
+		}
+	}
+	if buf.Len() != 0 {
+		d.Reset()
+	}
+	return buf.String()
+}
@@ -0,0 +1,166 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package kconfig parses and minimizes Linux kernel .config files.
+package kconfig
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Config is a parsed .config file.
+// Options keeps the order of the original file, so that serialization
+// produces minimal diffs.
+type Config struct {
+	Options []*Option
+	index   map[string]*Option
+}
+
+// Option is a single config option, Value is "y", "m", a number or a quoted string.
+// Disabled options ("# CONFIG_FOO is not set") have Value == "".
+type Option struct {
+	Name  string
+	Value string
+}
+
+const prefix = "CONFIG_"
+
+var (
+	setRe   = regexp.MustCompile(`^` + prefix + `([A-Za-z0-9_]+)=(.*)$`)
+	unsetRe = regexp.MustCompile(`^# ` + prefix + `([A-Za-z0-9_]+) is not set$`)
+)
+
+// Parse parses contents of a .config file. Comments are dropped.
+func Parse(data []byte) (*Config, error) {
+	cfg := &Config{index: make(map[string]*Option)}
+	for i, ln := range strings.Split(string(data), "\n") {
+		ln = strings.TrimSpace(ln)
+		var name, value string
+		if match := setRe.FindStringSubmatch(ln); match != nil {
+			name, value = match[1], match[2]
+			if value == "" {
+				return nil, fmt.Errorf("line %v: empty value for %v", i+1, name)
+			}
+		} else if match := unsetRe.FindStringSubmatch(ln); match != nil {
+			name = match[1]
+		} else if ln == "" || ln[0] == '#' {
+			continue
+		} else {
+			return nil, fmt.Errorf("line %v: can't parse %q", i+1, ln)
+		}
+		if opt := cfg.index[name]; opt != nil {
+			// Later definitions override earlier ones, as in kconfig.
+			opt.Value = value
+			continue
+		}
+		cfg.add(name, value)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) add(name, value string) {
+	opt := &Option{Name: name, Value: value}
+	cfg.Options = append(cfg.Options, opt)
+	cfg.index[name] = opt
+}
+
+// Value returns value of the option, or "" if the option is not set.
+func (cfg *Config) Value(name string) string {
+	if opt := cfg.index[name]; opt != nil {
+		return opt.Value
+	}
+	return ""
+}
+
+// Set sets option name to value (empty value disables the option).
+func (cfg *Config) Set(name, value string) {
+	if opt := cfg.index[name]; opt != nil {
+		opt.Value = value
+		return
+	}
+	cfg.add(name, value)
+}
+
+// Clone returns a deep copy of the config.
+func (cfg *Config) Clone() *Config {
+	clone := &Config{index: make(map[string]*Option)}
+	for _, opt := range cfg.Options {
+		clone.add(opt.Name, opt.Value)
+	}
+	return clone
+}
+
+// Serialize returns the config in .config format.
+func (cfg *Config) Serialize() []byte {
+	buf := new(bytes.Buffer)
+	for _, opt := range cfg.Options {
+		if opt.Value == "" {
+			fmt.Fprintf(buf, "# %v%v is not set\n", prefix, opt.Name)
+		} else {
+			fmt.Fprintf(buf, "%v%v=%v\n", prefix, opt.Name, opt.Value)
+		}
+	}
+	return buf.Bytes()
+}
+
+// Minimize disables as many options as possible that are enabled in full
+// but not in base, such that pred still returns true for the resulting config.
+// pred is called at most maxSteps times, when the budget is exhausted
+// the remaining undecided options are left enabled.
+// pred returns false for configs that can't be tested (e.g. the kernel does not build).
+func Minimize(base, full *Config, maxSteps int, pred func(*Config) (bool, error)) (*Config, error) {
+	var candidates []string
+	for _, opt := range full.Options {
+		if opt.Value != "" && base.Value(opt.Name) == "" {
+			candidates = append(candidates, opt.Name)
+		}
+	}
+	m := &minimizer{
+		cur:      full.Clone(),
+		pred:     pred,
+		maxSteps: maxSteps,
+	}
+	if err := m.minimize(candidates); err != nil {
+		return nil, err
+	}
+	return m.cur, nil
+}
+
+type minimizer struct {
+	cur      *Config
+	pred     func(*Config) (bool, error)
+	steps    int
+	maxSteps int
+}
+
+// minimize tries to disable all options at once,
+// if that breaks the predicate it recurses into halves.
+func (m *minimizer) minimize(options []string) error {
+	if len(options) == 0 || m.steps >= m.maxSteps {
+		return nil
+	}
+	candidate := m.cur.Clone()
+	for _, name := range options {
+		candidate.Set(name, "")
+	}
+	m.steps++
+	ok, err := m.pred(candidate)
+	if err != nil {
+		return err
+	}
+	if ok {
+		m.cur = candidate
+		return nil
+	}
+	if len(options) == 1 {
+		return nil
+	}
+	half := len(options) / 2
+	if err := m.minimize(options[:half]); err != nil {
+		return err
+	}
+	return m.minimize(options[half:])
+}
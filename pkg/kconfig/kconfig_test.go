@@ -0,0 +1,93 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package kconfig
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data := []byte(`
+#
+# Automatically generated file; DO NOT EDIT.
+#
+CONFIG_64BIT=y
+# CONFIG_KASAN is not set
+CONFIG_NR_CPUS=64
+CONFIG_CMDLINE="foo bar"
+CONFIG_FOO=m
+CONFIG_64BIT=n
+`)
+	cfg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `CONFIG_64BIT=n
+# CONFIG_KASAN is not set
+CONFIG_NR_CPUS=64
+CONFIG_CMDLINE="foo bar"
+CONFIG_FOO=m
+`
+	if got := string(cfg.Serialize()); got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+	if cfg.Value("FOO") != "m" || cfg.Value("KASAN") != "" || cfg.Value("BAR") != "" {
+		t.Fatalf("bad values")
+	}
+	if _, err := Parse([]byte("CONFIG_FOO")); err == nil {
+		t.Fatalf("parsed bad config")
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	base := &Config{index: make(map[string]*Option)}
+	base.Set("BASE", "y")
+	full := base.Clone()
+	for i := 0; i < 100; i++ {
+		full.Set(fmt.Sprintf("OPT%v", i), "y")
+	}
+	needed := []string{"OPT3", "OPT42", "OPT43", "OPT99"}
+	steps := 0
+	pred := func(cfg *Config) (bool, error) {
+		steps++
+		if cfg.Value("BASE") != "y" {
+			t.Fatalf("base option is disabled")
+		}
+		for _, name := range needed {
+			if cfg.Value(name) == "" {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	res, err := Minimize(base, full, 1000, pred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled := 0
+	for _, opt := range res.Options {
+		if opt.Value != "" {
+			enabled++
+		}
+	}
+	if enabled != len(needed)+1 {
+		t.Fatalf("got %v enabled options, want %v:\n%s", enabled, len(needed)+1, res.Serialize())
+	}
+	if ok, _ := pred(res); !ok {
+		t.Fatalf("minimized config does not satisfy predicate")
+	}
+	if steps > 60 {
+		t.Fatalf("too many steps: %v", steps)
+	}
+
+	// With a limited budget the result must still satisfy the predicate.
+	res, err = Minimize(base, full, 5, pred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := pred(res); !ok {
+		t.Fatalf("minimized config does not satisfy predicate")
+	}
+}
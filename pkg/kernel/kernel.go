@@ -24,10 +24,16 @@ import (
 	"github.com/google/syzkaller/pkg/osutil"
 )
 
-func Build(dir, compiler, config string) error {
+// Build builds kernel in dir with the specified compiler and config.
+// If ccache is not empty, it is used as compiler launcher
+// (this makes rebuilds after small changes much faster).
+func Build(dir, compiler, ccache, config string) error {
 	if err := osutil.CopyFile(config, filepath.Join(dir, ".config")); err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
+	if ccache != "" {
+		compiler = ccache + " " + compiler
+	}
 	return build(dir, compiler)
 }
 
@@ -45,6 +51,14 @@ func build(dir, compiler string) error {
 	return nil
 }
 
+// DefConfig returns the default config (make defconfig) for the kernel in dir.
+func DefConfig(dir string) ([]byte, error) {
+	if _, err := osutil.RunCmd(10*time.Minute, dir, "make", "defconfig"); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(filepath.Join(dir, ".config"))
+}
+
 // CreateImage creates a disk image that is suitable for syzkaller.
 // Kernel is taken from kernelDir, userspace system is taken from userspaceDir.
 // If cmdlineFile is not empty, contents of the file are appended to the kernel command line.
@@ -0,0 +1,14 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package kmemleak drives the kernel memory leak detector (CONFIG_KMEMLEAK)
+// via /sys/kernel/debug/kmemleak.
+package kmemleak
+
+// ScanMarker is printed by fuzzer after every leak scan.
+// Leaks reported by a scan are caused by programs executed after the preceding scans,
+// so the markers delimit the window of programs that need to be considered for reproduction.
+const ScanMarker = "kmemleak: scan done"
+
+// ReportHeader precedes leak reports in fuzzer/execprog output.
+const ReportHeader = "BUG: memory leak"
@@ -0,0 +1,94 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package kmemleak
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+const file = "/sys/kernel/debug/kmemleak"
+
+// Init turns off automatic kmemleak scanning (scans are done explicitly with Scan),
+// or turns kmemleak off completely if enable is not set.
+func Init(enable bool) error {
+	fd, err := syscall.Open(file, syscall.O_RDWR, 0)
+	if err != nil {
+		if !enable {
+			return nil
+		}
+		return fmt.Errorf("%v is missing (%v). Enable CONFIG_KMEMLEAK and mount debugfs", file, err)
+	}
+	defer syscall.Close(fd)
+	what := "scan=off"
+	if !enable {
+		what = "off"
+	}
+	if _, err := syscall.Write(fd, []byte(what)); err != nil {
+		// kmemleak returns EBUSY when kmemleak is already turned off.
+		if err != syscall.EBUSY {
+			return fmt.Errorf("failed to write %q to %v: %v", what, file, err)
+		}
+	}
+	return nil
+}
+
+var buf []byte
+
+// Scan scans memory for leaks and clears the list of found leaks.
+// If report is set, it returns reports of leaks found since the previous Scan.
+func Scan(report bool) ([]byte, error) {
+	fd, err := syscall.Open(file, syscall.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+	scan := func() error {
+		if _, err := syscall.Write(fd, []byte("scan")); err != nil {
+			return fmt.Errorf("failed to scan: %v", err)
+		}
+		return nil
+	}
+	// Kmemleak has false positives. To mitigate most of them, it checksums
+	// potentially leaked objects, and reports them only on the next scan
+	// iff the checksum does not change. Because of that we do the following
+	// intricate dance:
+	// Scan, sleep, scan again. At this point we can get some leaks.
+	// If there are leaks, we sleep and scan again, this can remove
+	// false leaks. Then, read kmemleak again. If we get leaks now, then
+	// hopefully these are true positives during the previous testing cycle.
+	if err := scan(); err != nil {
+		return nil, err
+	}
+	time.Sleep(time.Second)
+	if err := scan(); err != nil {
+		return nil, err
+	}
+	var leaks []byte
+	if report {
+		if buf == nil {
+			buf = make([]byte, 128<<10)
+		}
+		n, err := syscall.Read(fd, buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v: %v", file, err)
+		}
+		if n != 0 {
+			time.Sleep(time.Second)
+			if err := scan(); err != nil {
+				return nil, err
+			}
+			n, err = syscall.Read(fd, buf)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %v: %v", file, err)
+			}
+			leaks = append([]byte{}, buf[:n]...)
+		}
+	}
+	if _, err := syscall.Write(fd, []byte("clear")); err != nil {
+		return nil, fmt.Errorf("failed to clear: %v", err)
+	}
+	return leaks, nil
+}
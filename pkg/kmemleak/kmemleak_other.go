@@ -0,0 +1,22 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// +build !linux
+
+package kmemleak
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func Init(enable bool) error {
+	if enable {
+		return fmt.Errorf("leak checking is not supported on %v", runtime.GOOS)
+	}
+	return nil
+}
+
+func Scan(report bool) ([]byte, error) {
+	return nil, fmt.Errorf("leak checking is not supported on %v", runtime.GOOS)
+}
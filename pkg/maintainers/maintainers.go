@@ -0,0 +1,169 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package maintainers parses Linux kernel MAINTAINERS file
+// and maps source files to subsystems.
+package maintainers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Subsystem is a single entry of the MAINTAINERS file.
+type Subsystem struct {
+	Name        string
+	Maintainers []string // emails of maintainers (M:)
+	Lists       []string // mailing lists (L:)
+	Status      string   // S:
+	files       []string // F: patterns
+	excludes    []string // X: patterns
+	regexps     []*regexp.Regexp
+}
+
+type Maintainers struct {
+	Subsystems []*Subsystem
+}
+
+// ParseFile parses MAINTAINERS file.
+func ParseFile(file string) (*Maintainers, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maintainers file: %v", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses contents of MAINTAINERS file.
+// Entries are separated by empty lines, the first line of an entry
+// is the subsystem name and the rest are "T:\tvalue" lines.
+// Blocks that don't look like entries (e.g. the preamble) are skipped.
+func Parse(data []byte) (*Maintainers, error) {
+	m := new(Maintainers)
+	var cur *Subsystem
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		ln := strings.TrimRight(s.Text(), " \t")
+		if ln == "" {
+			cur = nil
+			continue
+		}
+		if !isTagLine(ln) {
+			if ln[0] == ' ' || ln[0] == '\t' {
+				cur = nil
+				continue
+			}
+			cur = &Subsystem{Name: ln}
+			m.Subsystems = append(m.Subsystems, cur)
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		val := strings.TrimSpace(ln[2:])
+		switch ln[0] {
+		case 'M':
+			if addr, err := mail.ParseAddress(val); err == nil {
+				cur.Maintainers = append(cur.Maintainers, addr.Address)
+			}
+		case 'L':
+			if addr := strings.Fields(val); len(addr) != 0 {
+				cur.Lists = append(cur.Lists, addr[0])
+			}
+		case 'S':
+			cur.Status = val
+		case 'F':
+			cur.files = append(cur.files, val)
+		case 'X':
+			cur.excludes = append(cur.excludes, val)
+		case 'N':
+			re, err := regexp.Compile(val)
+			if err != nil {
+				return nil, fmt.Errorf("bad N: regexp %q in %v: %v", val, cur.Name, err)
+			}
+			cur.regexps = append(cur.regexps, re)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	// Drop entries that were not followed by any tags,
+	// and the catch-all entry which is not a subsystem.
+	subsystems := m.Subsystems[:0]
+	for _, ss := range m.Subsystems {
+		if ss.Name != theRest && (len(ss.files) != 0 || len(ss.regexps) != 0) {
+			subsystems = append(subsystems, ss)
+		}
+	}
+	m.Subsystems = subsystems
+	return m, nil
+}
+
+const theRest = "THE REST"
+
+func isTagLine(ln string) bool {
+	return len(ln) >= 2 && ln[0] >= 'A' && ln[0] <= 'Z' && ln[1] == ':'
+}
+
+// Match returns the most specific subsystem responsible for the file
+// (the one with the longest matching F: pattern), or nil.
+func (m *Maintainers) Match(file string) *Subsystem {
+	var best *Subsystem
+	bestDepth := 0
+	for _, ss := range m.Subsystems {
+		if depth := ss.match(file); depth > bestDepth {
+			best, bestDepth = ss, depth
+		}
+	}
+	return best
+}
+
+// match returns depth of the most specific pattern of the subsystem
+// that matches the file, or 0 if the file does not belong to the subsystem.
+func (ss *Subsystem) match(file string) int {
+	for _, pattern := range ss.excludes {
+		if matchPattern(pattern, file) {
+			return 0
+		}
+	}
+	depth := 0
+	for _, pattern := range ss.files {
+		if matchPattern(pattern, file) {
+			if d := len(strings.Split(strings.Trim(pattern, "/"), "/")); depth < d {
+				depth = d
+			}
+		}
+	}
+	if depth == 0 {
+		for _, re := range ss.regexps {
+			if re.MatchString(file) {
+				// Keyword matches are weaker than any explicit file pattern.
+				depth = 1
+				break
+			}
+		}
+	}
+	return depth
+}
+
+// matchPattern matches file against F:/X: pattern.
+// Trailing slash matches all files in and below the dir, wildcards don't match subdirs.
+func matchPattern(pattern, file string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(file, pattern)
+	}
+	if ok, _ := path.Match(pattern, file); ok {
+		return true
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		// Directory without trailing slash.
+		return strings.HasPrefix(file, pattern+"/")
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package maintainers
+
+import (
+	"testing"
+)
+
+const testMaintainers = `
+List of maintainers and how to submit kernel changes
+
+Descriptions of section entries:
+
+	M: Mail patch to: FullName <address@domain>
+	F: Files and directories with wildcard patterns.
+
+Maintainers List (try to look for most precise areas first)
+
+		-----------------------------------
+
+EXT4 FILE SYSTEM
+M:	"Theodore Ts'o" <tytso@mit.edu>
+M:	Andreas Dilger <adilger.kernel@dilger.ca>
+L:	linux-ext4@vger.kernel.org
+S:	Maintained
+F:	Documentation/filesystems/ext4.txt
+F:	fs/ext4/
+
+FILESYSTEMS (VFS and infrastructure)
+M:	Alexander Viro <viro@zeniv.linux.org.uk>
+L:	linux-fsdevel@vger.kernel.org
+S:	Maintained
+F:	fs/*
+
+NETWORKING [GENERAL]
+M:	"David S. Miller" <davem@davemloft.net>
+L:	netdev@vger.kernel.org
+S:	Maintained
+F:	net/
+X:	net/bluetooth/
+
+NETWORKING [IPv4/IPv6]
+M:	"David S. Miller" <davem@davemloft.net>
+L:	netdev@vger.kernel.org
+S:	Maintained
+F:	net/ipv4/
+F:	net/ipv6
+
+KVM
+L:	kvm@vger.kernel.org (moderated for non-subscribers)
+S:	Supported
+N:	kvm
+
+THE REST
+M:	Linus Torvalds <torvalds@linux-foundation.org>
+L:	linux-kernel@vger.kernel.org
+S:	Buried alive in reporters
+F:	*
+F:	*/
+`
+
+func TestMatch(t *testing.T) {
+	m, err := Parse([]byte(testMaintainers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Subsystems) != 5 {
+		for _, ss := range m.Subsystems {
+			t.Logf("%q", ss.Name)
+		}
+		t.Fatalf("got %v subsystems, want 5", len(m.Subsystems))
+	}
+	ext4 := m.Subsystems[0]
+	if ext4.Name != "EXT4 FILE SYSTEM" || ext4.Status != "Maintained" ||
+		len(ext4.Maintainers) != 2 || ext4.Maintainers[1] != "adilger.kernel@dilger.ca" ||
+		len(ext4.Lists) != 1 || ext4.Lists[0] != "linux-ext4@vger.kernel.org" {
+		t.Fatalf("bad ext4 entry: %+v", ext4)
+	}
+	tests := []struct {
+		file      string
+		subsystem string
+	}{
+		{"fs/ext4/inode.c", "EXT4 FILE SYSTEM"},
+		{"fs/namei.c", "FILESYSTEMS (VFS and infrastructure)"},
+		{"fs/btrfs/inode.c", ""},
+		{"net/core/dev.c", "NETWORKING [GENERAL]"},
+		{"net/ipv4/tcp.c", "NETWORKING [IPv4/IPv6]"},
+		{"net/ipv6/route.c", "NETWORKING [IPv4/IPv6]"},
+		{"net/bluetooth/hci_core.c", ""},
+		{"arch/x86/kvm/x86.c", "KVM"},
+		{"Makefile", ""},
+	}
+	for _, test := range tests {
+		ss := m.Match(test.file)
+		name := ""
+		if ss != nil {
+			name = ss.Name
+		}
+		if name != test.subsystem {
+			t.Errorf("%v: got subsystem %q, want %q", test.file, name, test.subsystem)
+		}
+	}
+}
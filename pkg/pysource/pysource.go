@@ -0,0 +1,305 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package pysource generates Python programs equivalent to syzkaller programs.
+// The programs use only ctypes and raw syscall numbers, so they can be run and tweaked
+// on targets where installing a compiler is painful (e.g. small rootfs with python preinstalled).
+// Pseudo-syscalls (syz_*) are not supported.
+package pysource
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/syzkaller/pkg/execfmt"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys/targets"
+)
+
+type Options struct {
+	Repeat bool // repeat the program infinitely
+}
+
+// Supported returns an error if Python programs can't be generated for the target.
+func Supported(target *prog.Target) error {
+	sysTarget := targets.List[target.OS][target.Arch]
+	if sysTarget == nil || !sysTarget.SyscallNumbers {
+		return fmt.Errorf("unsupported target %v/%v", target.OS, target.Arch)
+	}
+	// Memory is accessed via /proc/self/mem.
+	if target.OS != "linux" {
+		return fmt.Errorf("unsupported OS: %v", target.OS)
+	}
+	return nil
+}
+
+func Write(p *prog.Prog, opts Options) ([]byte, error) {
+	if err := Supported(p.Target); err != nil {
+		return nil, err
+	}
+	for _, c := range p.Calls {
+		if c.Meta.CallName == "syz_test" {
+			continue // not emitted
+		}
+		if strings.HasPrefix(c.Meta.CallName, "syz_") {
+			return nil, fmt.Errorf("pseudo-syscall %v is not supported", c.Meta.CallName)
+		}
+		if len(c.Args) > 6 {
+			return nil, fmt.Errorf("syscall %v has more than 6 arguments", c.Meta.Name)
+		}
+	}
+	exec := make([]byte, prog.ExecBufferSize)
+	progSize, err := p.SerializeForExec(exec, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize program: %v", err)
+	}
+	decoded, err := execfmt.Decode(p.Target, exec[:progSize])
+	if err != nil {
+		return nil, err
+	}
+	ctx := &context{
+		p:    p,
+		opts: opts,
+		w:    new(bytes.Buffer),
+	}
+	calls, nvar := ctx.generateCalls(decoded)
+
+	ctx.print("#!/usr/bin/env python3\n")
+	ctx.print("# autogenerated by syzkaller (http://github.com/google/syzkaller)\n\n")
+	ctx.print("import ctypes\n")
+	ctx.print("import os\n\n")
+	ctx.print(helperCommon)
+	if ctx.needBitmask {
+		ctx.print(helperBitmask)
+	}
+	if ctx.needCsum {
+		ctx.print(helperCsum)
+	}
+	if ctx.needCrc32c {
+		ctx.print(helperCrc32c)
+	}
+	ctx.printf("r = [%v] * %v\n\n\n", resDefault, nvar)
+	for i, c := range calls {
+		if c == "" {
+			c = "    pass\n"
+		}
+		ctx.printf("def call%v():\n%v\n\n", i, c)
+	}
+	ctx.print("def loop():\n")
+	ctx.print("    for i in range(len(r)):\n")
+	ctx.printf("        r[i] = %v\n", resDefault)
+	for i := range calls {
+		ctx.printf("    call%v()\n", i)
+	}
+	ctx.print("\n\n")
+	if opts.Repeat {
+		ctx.print("while True:\n    loop()\n")
+	} else {
+		ctx.print("loop()\n")
+	}
+	return ctx.w.Bytes(), nil
+}
+
+// resDefault is the value of results of failed calls (-1 as returned by syscall helper).
+const resDefault = "0xffffffffffffffff"
+
+type context struct {
+	p           *prog.Prog
+	opts        Options
+	w           *bytes.Buffer
+	needBitmask bool
+	needCsum    bool
+	needCrc32c  bool
+}
+
+func (ctx *context) print(str string) {
+	ctx.w.WriteString(str)
+}
+
+func (ctx *context) printf(str string, args ...interface{}) {
+	ctx.print(fmt.Sprintf(str, args...))
+}
+
+func (ctx *context) generateCalls(exec *execfmt.Prog) ([]string, int) {
+	resultRef := func(arg *execfmt.ResultArg) string {
+		res := fmt.Sprintf("r[%v]", arg.Index)
+		if arg.OpDiv != 0 {
+			res = fmt.Sprintf("%v // %v", res, arg.OpDiv)
+		}
+		if arg.OpAdd != 0 {
+			res = fmt.Sprintf("%v + %v", res, arg.OpAdd)
+		}
+		return res
+	}
+	constMask := ^uint64(0)
+	if ctx.p.Target.PtrSize == 4 {
+		constMask = 1<<32 - 1
+	}
+	lastCall := 0
+	seenCall := false
+	var calls []string
+	w := new(bytes.Buffer)
+	newCall := func() {
+		if seenCall {
+			seenCall = false
+			calls = append(calls, w.String())
+			w = new(bytes.Buffer)
+		}
+	}
+	for n, instr := range exec.Instrs {
+		switch instr := instr.(type) {
+		case *execfmt.Copyin:
+			newCall()
+			addr := instr.Addr
+			switch arg := instr.Arg.(type) {
+			case *execfmt.ConstArg:
+				if arg.BitfieldOffset == 0 && arg.BitfieldLength == 0 {
+					fmt.Fprintf(w, "    store(0x%x, %v, 0x%x)\n", addr, arg.Size, arg.Value)
+				} else {
+					ctx.needBitmask = true
+					fmt.Fprintf(w, "    store_by_bitmask(0x%x, %v, 0x%x, %v, %v)\n",
+						addr, arg.Size, arg.Value, arg.BitfieldOffset, arg.BitfieldLength)
+				}
+			case *execfmt.ResultArg:
+				fmt.Fprintf(w, "    store(0x%x, %v, %v)\n", addr, arg.Size, resultRef(arg))
+			case *execfmt.DataArg:
+				if len(arg.Data) != 0 {
+					fmt.Fprintf(w, "    write_mem(0x%x, bytes.fromhex(\"%v\"))\n", addr, hex.EncodeToString(arg.Data))
+				}
+			case *execfmt.CsumArg:
+				var fn string
+				var csumSize uint64
+				switch arg.Kind {
+				case prog.ExecArgCsumInet:
+					ctx.needCsum = true
+					fn, csumSize = "csum_inet", 2
+				case prog.ExecArgCsumCrc32c:
+					ctx.needCrc32c = true
+					fn, csumSize = "crc32c", 4
+				default:
+					panic(fmt.Sprintf("unknown csum kind %v", arg.Kind))
+				}
+				var chunks []string
+				for _, chunk := range arg.Chunks {
+					switch chunk.Kind {
+					case prog.ExecArgCsumChunkData:
+						chunks = append(chunks, fmt.Sprintf("read_mem(0x%x, %v)", chunk.Value, chunk.Size))
+					case prog.ExecArgCsumChunkConst:
+						chunks = append(chunks, fmt.Sprintf("(0x%x).to_bytes(%v, \"little\")",
+							chunk.Value, chunk.Size))
+					default:
+						panic(fmt.Sprintf("unknown checksum chunk kind %v", chunk.Kind))
+					}
+				}
+				fmt.Fprintf(w, "    store(0x%x, %v, %v([%v]))\n", addr, csumSize, fn, strings.Join(chunks, ", "))
+			default:
+				panic(fmt.Sprintf("bad argument type %T", arg))
+			}
+		case *execfmt.Copyout:
+			fmt.Fprintf(w, "    if r[%v] != %v:\n", lastCall, resDefault)
+			fmt.Fprintf(w, "        r[%v] = load(0x%x, %v, r[%v])\n", n, instr.Addr, instr.Size, n)
+		case *execfmt.Call:
+			newCall()
+			meta := instr.Meta
+			emitCall := meta.CallName != "syz_test"
+			var args []string
+			for _, arg := range instr.Args {
+				switch arg := arg.(type) {
+				case *execfmt.ConstArg:
+					// Bitfields can't be args of a normal syscall, so just ignore them.
+					args = append(args, fmt.Sprintf("0x%x", arg.Value&constMask))
+				case *execfmt.ResultArg:
+					args = append(args, resultRef(arg))
+				default:
+					panic(fmt.Sprintf("unknown arg type %T", arg))
+				}
+			}
+			if emitCall {
+				fmt.Fprintf(w, "    r[%v] = syscall(%v", n, meta.NR)
+				for _, arg := range args {
+					fmt.Fprintf(w, ", %v", arg)
+				}
+				fmt.Fprintf(w, ")  # %v\n", meta.CallName)
+			}
+			lastCall = n
+			seenCall = true
+		}
+	}
+	newCall()
+	return calls, len(exec.Instrs)
+}
+
+// Memory is accessed via /proc/self/mem, so that accesses to bad addresses
+// (programs can contain unmapped/protected addresses) fail instead of crashing the interpreter.
+const helperCommon = `libc = ctypes.CDLL(None, use_errno=True)
+libc.syscall.restype = ctypes.c_long
+mem = os.open("/proc/self/mem", os.O_RDWR)
+
+
+def syscall(nr, *args):
+    res = libc.syscall(ctypes.c_long(nr), *[ctypes.c_ulong(a & 0xffffffffffffffff) for a in args])
+    return res & 0xffffffffffffffff
+
+
+def write_mem(addr, data):
+    try:
+        os.pwrite(mem, data, addr)
+    except (OSError, OverflowError):
+        pass
+
+
+def read_mem(addr, size):
+    try:
+        return os.pread(mem, size, addr)
+    except (OSError, OverflowError):
+        return b""
+
+
+def store(addr, size, val):
+    write_mem(addr, (val & ((1 << (size * 8)) - 1)).to_bytes(size, "little"))
+
+
+def load(addr, size, default):
+    data = read_mem(addr, size)
+    if len(data) != size:
+        return default
+    return int.from_bytes(data, "little")
+
+
+`
+
+const helperBitmask = `def store_by_bitmask(addr, size, val, bf_off, bf_len):
+    mask = ((1 << bf_len) - 1) << bf_off
+    old = load(addr, size, 0)
+    store(addr, size, (old & ~mask) | ((val << bf_off) & mask))
+
+
+`
+
+const helperCsum = `def csum_inet(chunks):
+    acc = 0
+    for data in chunks:
+        for i in range(0, len(data) - 1, 2):
+            acc += data[i] | (data[i + 1] << 8)
+        if len(data) & 1:
+            acc += data[-1]
+        while acc > 0xffff:
+            acc = (acc & 0xffff) + (acc >> 16)
+    return ~acc & 0xffff
+
+
+`
+
+const helperCrc32c = `def crc32c(chunks):
+    crc = 0xffffffff
+    for data in chunks:
+        for b in data:
+            crc ^= b
+            for _ in range(8):
+                crc = (crc >> 1) ^ (0x82f63b78 & -(crc & 1))
+    return ~crc & 0xffffffff
+
+
+`
@@ -0,0 +1,120 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package pysource
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+)
+
+func TestGenerate(t *testing.T) {
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := time.Now().UnixNano()
+	t.Logf("seed=%v", seed)
+	rs := rand.NewSource(seed)
+	iters := 20
+	if testing.Short() {
+		iters = 5
+	}
+	dir, err := ioutil.TempDir("", "syz-pysource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// Pseudo-syscalls are not supported.
+	enabled := make(map[*prog.Syscall]bool)
+	for _, c := range target.Syscalls {
+		if !strings.HasPrefix(c.CallName, "syz_") {
+			enabled[c] = true
+		}
+	}
+	ct := target.BuildChoiceTable(target.CalculatePriorities(nil), enabled)
+	python, _ := exec.LookPath("python3")
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, ct)
+		for _, opts := range []Options{{}, {Repeat: true}} {
+			src, err := Write(p, opts)
+			if err != nil {
+				t.Fatalf("failed to generate program: %v\n%s", err, p.Serialize())
+			}
+			if python == "" {
+				continue
+			}
+			file := filepath.Join(dir, "prog.py")
+			if err := ioutil.WriteFile(file, src, 0644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command(python, "-m", "py_compile", file)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("failed to compile program: %v\n%s\n%s", err, out, src)
+			}
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil || runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("python3 on linux/amd64 is required")
+	}
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte(`
+mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)
+pipe(&(0x7f0000000000)={<r0=>0xffffffffffffffff, <r1=>0xffffffffffffffff})
+write(r1, &(0x7f0000000000+0x100)="73797a6b616c6c6572", 0x9)
+splice(r0, 0x0, 0x1, 0x0, 0x9, 0x0)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Write(p, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := osutil.WriteTempFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file)
+	// The data travels through the pipe created by the program to stdout (fd 1),
+	// which checks copyout of results and their use in subsequent calls.
+	out, err := osutil.RunCmd(time.Minute, "", python, file)
+	if err != nil {
+		t.Fatalf("failed to run program: %v\n%s", err, src)
+	}
+	if string(out) != "syzkaller" {
+		t.Fatalf("got output %q, want %q\n%s", out, "syzkaller", src)
+	}
+}
+
+func TestPseudoSyscall(t *testing.T) {
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte("syz_open_pts(0x0, 0x0)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Write(p, Options{}); err == nil {
+		t.Fatal("pseudo-syscall is not rejected")
+	}
+}
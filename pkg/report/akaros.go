@@ -50,3 +50,7 @@ func (ctx *akaros) ExtractGuiltyFile(report []byte) string {
 func (ctx *akaros) GetMaintainers(file string) ([]string, error) {
 	panic("not implemented")
 }
+
+func (ctx *akaros) GetSubsystem(file string) (string, error) {
+	panic("not implemented")
+}
@@ -89,6 +89,10 @@ func (ctx *freebsd) GetMaintainers(file string) ([]string, error) {
 	return nil, fmt.Errorf("not implemented")
 }
 
+func (ctx *freebsd) GetSubsystem(file string) (string, error) {
+	return "", fmt.Errorf("not implemented")
+}
+
 var freebsdOopses = []*oops{
 	&oops{
 		[]byte("Fatal trap"),
@@ -50,3 +50,7 @@ func (ctx *fuchsia) ExtractGuiltyFile(report []byte) string {
 func (ctx *fuchsia) GetMaintainers(file string) ([]string, error) {
 	panic("not implemented")
 }
+
+func (ctx *fuchsia) GetSubsystem(file string) (string, error) {
+	panic("not implemented")
+}
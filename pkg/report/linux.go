@@ -12,8 +12,10 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/syzkaller/pkg/maintainers"
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/pkg/symbolizer"
 )
@@ -23,11 +25,15 @@ type linux struct {
 	kernelObj           string
 	vmlinux             string
 	symbols             map[string][]symbolizer.Symbol
+	symbolCache         symbolizer.Cache
 	ignores             []*regexp.Regexp
 	consoleOutputRe     *regexp.Regexp
 	questionableRe      *regexp.Regexp
 	guiltyFileBlacklist []*regexp.Regexp
 	eoi                 []byte
+	maintainersOnce     sync.Once
+	maintainers         *maintainers.Maintainers
+	maintainersErr      error
 }
 
 func ctorLinux(kernelSrc, kernelObj string, symbols map[string][]symbolizer.Symbol,
@@ -59,6 +65,9 @@ func ctorLinux(kernelSrc, kernelObj string, symbols map[string][]symbolizer.Symb
 		regexp.MustCompile(`^mm/percpu.*`),
 		regexp.MustCompile(`^mm/vmalloc.c`),
 		regexp.MustCompile(`^mm/page_alloc.c`),
+		regexp.MustCompile(`^mm/sl.b.c`),
+		regexp.MustCompile(`^mm/slab_common.c`),
+		regexp.MustCompile(`^mm/util.c`),
 		regexp.MustCompile(`^kernel/rcu/.*`),
 		regexp.MustCompile(`^arch/.*/kernel/traps.c`),
 		regexp.MustCompile(`^kernel/locking/*`),
@@ -150,6 +159,11 @@ func (ctx *linux) Parse(output []byte) (desc string, text []byte, start int, end
 		return
 	}
 	desc = extractDescription(output[start:], oops)
+	if bytes.Equal(oops.header, leakHeader) {
+		if leakDesc := extractLeakDescription(output[start:]); leakDesc != "" {
+			desc = leakDesc
+		}
+	}
 	// Executor PIDs are not interesting.
 	desc = executorRe.ReplaceAllLiteralString(desc, "syz-executor")
 	// Replace that everything looks like an address with "ADDR",
@@ -170,6 +184,9 @@ func (ctx *linux) Parse(output []byte) (desc string, text []byte, start int, end
 func (ctx *linux) Symbolize(text []byte) ([]byte, error) {
 	symb := symbolizer.NewSymbolizer()
 	defer symb.Close()
+	symbFunc := func(bin string, pc uint64) ([]symbolizer.Frame, error) {
+		return ctx.symbolCache.Symbolize(symb.Symbolize, bin, pc)
+	}
 	// Strip vmlinux location from all paths.
 	strip, _ := filepath.Abs(ctx.vmlinux)
 	strip = filepath.Dir(strip) + string(filepath.Separator)
@@ -178,7 +195,7 @@ func (ctx *linux) Symbolize(text []byte) ([]byte, error) {
 	// so we can infer correct strip prefix from it.
 	if covSymbols := ctx.symbols["__sanitizer_cov_trace_pc"]; len(covSymbols) != 0 {
 		for _, covSymb := range covSymbols {
-			frames, _ := symb.Symbolize(ctx.vmlinux, covSymb.Addr)
+			frames, _ := symbFunc(ctx.vmlinux, covSymb.Addr)
 			if len(frames) > 0 {
 				file := frames[len(frames)-1].File
 				if idx := strings.Index(file, "kernel/kcov.c"); idx != -1 {
@@ -193,7 +210,7 @@ func (ctx *linux) Symbolize(text []byte) ([]byte, error) {
 	for s.Scan() {
 		line := append([]byte{}, s.Bytes()...)
 		line = append(line, '\n')
-		line = symbolizeLine(symb.Symbolize, ctx.symbols, ctx.vmlinux, strip, line)
+		line = symbolizeLine(symbFunc, ctx.symbols, ctx.vmlinux, strip, line)
 		symbolized = append(symbolized, line...)
 	}
 	return symbolized, nil
@@ -324,6 +341,20 @@ func (ctx *linux) getMaintainers(file string, blame bool) ([]string, error) {
 	return mtrs, nil
 }
 
+func (ctx *linux) GetSubsystem(file string) (string, error) {
+	ctx.maintainersOnce.Do(func() {
+		ctx.maintainers, ctx.maintainersErr = maintainers.ParseFile(filepath.Join(ctx.kernelSrc, "MAINTAINERS"))
+	})
+	if ctx.maintainersErr != nil {
+		return "", ctx.maintainersErr
+	}
+	ss := ctx.maintainers.Match(file)
+	if ss == nil {
+		return "", nil
+	}
+	return ss.Name, nil
+}
+
 func (ctx *linux) extractFiles(report []byte) []string {
 	matches := filenameRe.FindAll(report, -1)
 	var files []string
@@ -333,6 +364,35 @@ func (ctx *linux) extractFiles(report []byte) []string {
 	return files
 }
 
+// extractLeakDescription returns title of a kmemleak report based on the allocation stack:
+// the first backtrace frame that is not a generic allocation function. Object size is not
+// included, because the same leak frequently happens with objects of different sizes.
+func extractLeakDescription(report []byte) string {
+	pos := bytes.Index(report, []byte("backtrace:"))
+	if pos == -1 {
+		return ""
+	}
+	for _, line := range bytes.Split(report[pos:], []byte{'\n'})[1:] {
+		match := leakFrameRe.FindSubmatch(line)
+		if match == nil {
+			break
+		}
+		if !leakAllocRe.Match(match[1]) {
+			return fmt.Sprintf("memory leak in %s", match[1])
+		}
+	}
+	return ""
+}
+
+var (
+	leakHeader  = []byte("unreferenced object")
+	leakFrameRe = regexp.MustCompile(`^(?:\[ *[0-9]+\.[0-9]+\] )?\s*\[\<[0-9a-f]+\>\] ([a-zA-Z0-9_]+)(?:\.|\+)`)
+	// Allocation functions and wrappers that don't identify the leak.
+	leakAllocRe = regexp.MustCompile(`^(?:create_object|kmemleak_.*|slab_.*|kmem_cache_alloc.*|` +
+		`_*kmalloc.*|kzalloc.*|kcalloc.*|_*krealloc|kmemdup.*|kstrdup.*|kvmalloc.*|kvzalloc.*|` +
+		`_*vmalloc.*|vzalloc.*|_*alloc_pages.*|_*alloc_skb|_*(?:dev|netdev)_alloc_skb|sock_kmalloc)$`)
+)
+
 var (
 	filenameRe       = regexp.MustCompile(`[a-zA-Z0-9_\-\./]*[a-zA-Z0-9_\-]+\.(c|h):[0-9]+`)
 	linuxSymbolizeRe = regexp.MustCompile(`(?:\[\<(?:[0-9a-f]+)\>\])?[ \t]+(?:[0-9]+:)?([a-zA-Z0-9_.]+)\+0x([0-9a-f]+)/0x([0-9a-f]+)`)
@@ -650,7 +710,7 @@ var linuxOopses = []*oops{
 		[]*regexp.Regexp{},
 	},
 	&oops{
-		[]byte("unreferenced object"),
+		leakHeader,
 		[]oopsFormat{
 			{
 				compile("unreferenced object {{ADDR}} \\(size ([0-9]+)\\):(?:.*\n.*)+backtrace:.*\n.*{{PC}}.*\n.*{{PC}}.*\n.*{{PC}} {{FUNC}}"),
@@ -664,4 +724,26 @@ var linuxOopses = []*oops{
 		[]oopsFormat{},
 		[]*regexp.Regexp{},
 	},
+	&oops{
+		// Printed by fuzzer/execprog when executor detects a kernel pointer leaked to user-space.
+		[]byte("KASLR leak:"),
+		[]oopsFormat{
+			{
+				compile("KASLR leak: call #[0-9]+ ([^ ]+) leaked kernel pointer"),
+				"KASLR leak in %[1]v",
+			},
+		},
+		[]*regexp.Regexp{},
+	},
+	&oops{
+		// Printed by fuzzer/execprog when a program leaks kernel objects with every execution.
+		[]byte("resource leak:"),
+		[]oopsFormat{
+			{
+				compile("resource leak: ([a-z ]+) grew from [0-9]+ to [0-9]+"),
+				"resource leak: %[1]v",
+			},
+		},
+		[]*regexp.Regexp{},
+	},
 }
@@ -467,7 +467,7 @@ unreferenced object 0xffff880039a55260 (size 64):
     [<ffffffff8544616c>] sctp_setsockopt+0x15c/0x36c0 net/sctp/socket.c:3702 
     [<ffffffff848a2035>] sock_common_setsockopt+0x95/0xd0 net/core/sock.c:2645 
     [<ffffffff8489f1d8>] SyS_setsockopt+0x158/0x240 net/socket.c:1736 
-`: `memory leak in ipv6_setsockopt (size 64)`,
+`: `memory leak in do_ipv6_setsockopt`,
 
 		`
 unreferenced object 0xffff8800342540c0 (size 1864): 
@@ -482,7 +482,7 @@ unreferenced object 0xffff8800342540c0 (size 1864):
     [<ffffffff84b6d36a>] sk_alloc+0x3a/0x6b0 net/core/sock.c:1419 
     [<ffffffff850c6d57>] inet6_create+0x2d7/0x1000 net/ipv6/af_inet6.c:173 
     [<ffffffff84b5f47c>] __sock_create+0x37c/0x640 net/socket.c:1162 
-`: `memory leak in sk_prot_alloc (size 1864)`,
+`: `memory leak in sk_prot_alloc`,
 
 		`
 unreferenced object 0xffff880133c63800 (size 1024):
@@ -493,7 +493,7 @@ unreferenced object 0xffff880133c63800 (size 1024):
     [<ffffffff810f32a3>] __kmalloc+0x113/0x200
     [<ffffffff811aa061>] ext4_mb_init+0x1b1/0x570
     [<ffffffff8119b3d2>] ext4_fill_super+0x1de2/0x26d0
-`: `memory leak in __kmalloc (size 1024)`,
+`: `memory leak in ext4_mb_init`,
 
 		`
 unreferenced object 0xc625e000 (size 2048):
@@ -505,7 +505,7 @@ unreferenced object 0xc625e000 (size 2048):
     [<c01d8490>] dev_alloc_skb+0x18/0x3c
     [<c0198b48>] eth_rx_fill+0xd8/0x3fc
     [<c019ac74>] mv_eth_start_internals+0x30/0xf8
-`: `memory leak in __alloc_skb (size 2048)`,
+`: `memory leak in eth_rx_fill`,
 
 		`
 unreferenced object 0xdb8040c0 (size 20):
@@ -517,7 +517,17 @@ unreferenced object 0xdb8040c0 (size 20):
     [<c0a86a62>] start_kernel+0x2da/0x38d
     [<c0a86090>] i386_start_kernel+0x7f/0x98
     [<ffffffff>] 0xffffffff
-`: `memory leak in debug_objects_mem_init (size 20)`,
+`: `memory leak in debug_objects_mem_init`,
+
+		`
+[   92.841239] unreferenced object 0xffff88003a1c7a40 (size 512):
+[   92.841239]   comm "syz-executor3", pid 6054, jiffies 4295015622 (age 14.350s)
+[   92.841239]   backtrace:
+[   92.841239]     [<ffffffff85c73a22>] kmemleak_alloc+0x72/0xc0 mm/kmemleak.c:915
+[   92.841239]     [<ffffffff816cc14d>] kmem_cache_alloc+0x12d/0x2c0 mm/slub.c:2607
+[   92.841239]     [<ffffffff84b642c9>] sk_prot_alloc+0x69/0x340 net/core/sock.c:1344
+[   92.841239]     [<ffffffff84b6d36a>] sk_alloc+0x3a/0x6b0 net/core/sock.c:1419
+`: `memory leak in sk_prot_alloc`,
 
 		`
 BUG: sleeping function called from invalid context at include/linux/wait.h:1095 
@@ -780,6 +790,21 @@ other info that might help us debug this:
 		`
 BUG: workqueue lockup - pool cpus=0 node=0 flags=0x0 nice=0 stuck for 32s!
 `: `BUG: workqueue lockup`,
+
+		`
+2017/11/03 10:12:55 executing program 3:
+mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)
+r0 = socket$inet6(0xa, 0x1, 0x0)
+getsockopt$inet6_buf(r0, 0x29, 0x3b, &(0x7f0000000000)="", &(0x7f0000000fc0)=0x40)
+2017/11/03 10:12:55 KASLR leak: call #2 getsockopt$inet6_buf leaked kernel pointer 0xffffffff8412d8a0
+`: `KASLR leak in getsockopt$inet6_buf`,
+
+		`
+2018/01/12 08:41:02 executing program 1:
+r0 = socket$inet_tcp(0x2, 0x1, 0x0)
+setsockopt$inet_tcp_int(r0, 0x6, 0x13, &(0x7f0000000000)=0x1, 0x4)
+2018/01/12 08:41:02 resource leak: sockets grew from 153 to 160 in 7 executions
+`: `resource leak: sockets`,
 	}
 	testParse(t, "linux", tests)
 }
@@ -2131,6 +2156,26 @@ Call Trace:
  [<ffffffff829a50bc>] do_ipt_set_ctl+0x21c/0x430 net/ipv4/netfilter/ip_tables.c:1687
  [<ffffffff827436ac>] nf_sockopt net/netfilter/nf_sockopt.c:105 [inline]
 `: `net/netfilter/x_tables.c`,
+		`
+WARNING: CPU: 1 PID: 3072 at mm/slab_common.c:1012 kmalloc_slab+0x5d/0x70 mm/slab_common.c:1012
+Kernel panic - not syncing: panic_on_warn set ...
+
+Call Trace:
+ __dump_stack lib/dump_stack.c:16 [inline]
+ dump_stack+0x194/0x257 lib/dump_stack.c:52
+ panic+0x1e4/0x41c kernel/panic.c:183
+ __warn+0x1c4/0x1e0 kernel/panic.c:546
+ report_bug+0x211/0x2d0 lib/bug.c:183
+ do_invalid_op+0x1b/0x20 arch/x86/kernel/traps.c:323
+ invalid_op+0x18/0x40 arch/x86/entry/entry_64.S:1079
+ __do_kmalloc mm/slab.c:3710 [inline]
+ __kmalloc+0x25/0x760 mm/slab.c:3727
+ kmalloc include/linux/slab.h:504 [inline]
+ kmemdup+0x23/0x50 mm/util.c:117
+ kmemdup include/linux/string.h:414 [inline]
+ sctp_setsockopt_auth_key net/sctp/socket.c:3503 [inline]
+ sctp_setsockopt+0x7c1/0x5c80 net/sctp/socket.c:4076
+`: `net/sctp/socket.c`,
 	}
 	reporter, err := NewReporter("linux", "", "", nil, nil)
 	if err != nil {
@@ -29,6 +29,9 @@ type Reporter interface {
 	ExtractConsoleOutput(output []byte) (result []byte)
 	ExtractGuiltyFile(report []byte) string
 	GetMaintainers(file string) ([]string, error)
+	// GetSubsystem returns name of the subsystem responsible for the file
+	// (empty if the file does not belong to any subsystem).
+	GetSubsystem(file string) (string, error)
 }
 
 // NewReporter creates reporter for the specified OS:
@@ -168,3 +171,38 @@ func replace(where []byte, start, end int, what []byte) []byte {
 	}
 	return where
 }
+
+// Number of top stack frames that constitute crash stack signature.
+const stackSignatureFrames = 4
+
+var (
+	stackFrameRe = regexp.MustCompile(`^[ \t]*(?:\[\<[0-9a-f]+\>\][ \t]+)?([a-zA-Z_][a-zA-Z0-9_.]*)\+0x[0-9a-f]+/0x[0-9a-f]+`)
+	timestampRe  = regexp.MustCompile(`^(?:\<[0-9]+\>)?\[ *[0-9]+\.[0-9]+\] `)
+	// Frames of crash reporting machinery that are not related to the bug.
+	stackSignatureSkip = regexp.MustCompile(`^(?:__dump_stack|dump_stack|show_stack|print_address_description|` +
+		`kasan_.*|__kasan_.*|kmsan_.*|__msan_.*|check_memory_region|__asan_.*|ubsan_.*|__ubsan_.*|` +
+		`panic|__warn|warn_slowpath_.*|report_bug|fixup_bug|do_trap|do_error_trap|do_invalid_op|invalid_op|` +
+		`lockdep_.*|__lock_acquire|lock_acquire|print_.*_bug)$`)
+)
+
+// StackSignature returns signature of the crash report that is based on the top
+// frames of the crash stack rather than on the title: function names are
+// normalized (offsets, addresses, timestamps and compiler-generated suffixes
+// like .isra.0 are stripped) and frames of the crash reporting machinery and
+// unreliable frames (marked with ?) are skipped. Reports of the same bug that
+// have slightly different titles usually have the same signature.
+// Returns empty string if the report does not contain a stack.
+func StackSignature(report []byte) string {
+	if pos := bytes.Index(report, []byte("Call Trace:")); pos != -1 {
+		report = report[pos:]
+	}
+	frames, _, _ := parseStacks(report)
+	if len(frames) > stackSignatureFrames {
+		frames = frames[:stackSignatureFrames]
+	}
+	if len(frames) < 2 {
+		// A single frame is not enough to tell that two crashes are the same.
+		return ""
+	}
+	return strings.Join(frames, " ")
+}
@@ -72,3 +72,56 @@ func testParse(t *testing.T, os string, tests map[string]string) {
 		}
 	}
 }
+
+func TestStackSignature(t *testing.T) {
+	const report1 = `[   41.291542] BUG: KASAN: use-after-free in skb_release_data+0x3b0/0x3d0
+[   41.291548] Read of size 8 at addr ffff88003c8a4c88 by task syz-executor0/5391
+[   41.291565] Call Trace:
+[   41.291572]  __dump_stack lib/dump_stack.c:16 [inline]
+[   41.291577]  dump_stack+0x194/0x257 lib/dump_stack.c:52
+[   41.291589]  print_address_description+0x73/0x250 mm/kasan/report.c:252
+[   41.291600]  kasan_report+0x23b/0x360 mm/kasan/report.c:409
+[   41.291605]  ? skb_release_data+0x3b0/0x3d0
+[   41.291612]  __asan_report_load8_noabort+0x14/0x20 mm/kasan/report.c:430
+[   41.291618]  skb_release_data+0x3b0/0x3d0 net/core/skbuff.c:570
+[   41.291625]  skb_release_all+0x4a/0x60 net/core/skbuff.c:631
+[   41.291631]  __kfree_skb+0x15/0x20 net/core/skbuff.c:645
+[   41.291636]  sctp_chunk_put.isra.12+0x8d/0x130 net/sctp/sm_make_chunk.c:1476
+[   41.291642]  sctp_ulpevent_free+0x2a/0x40 net/sctp/ulpevent.c:1009
+`
+	// The same bug detected as a different access with a different title.
+	const report2 = `BUG: KASAN: double-free or invalid-free in skb_release_data+0x3b0/0x3d0
+
+Call Trace:
+ [<ffffffff8d5b5a6c>] dump_stack+0x194/0x257
+ [<ffffffff8d1cd7e7>] kasan_report_double_free+0x55/0x80
+ [<ffffffff8d1cd9b2>] kasan_slab_free+0xa2/0xc0
+ [<ffffffff8e0d30a0>] skb_release_data+0x3b0/0x3d0
+ [<ffffffff8e0d3130>] skb_release_all+0x4a/0x60
+ [<ffffffff8e0d3a15>] __kfree_skb+0x15/0x20
+ [<ffffffff8e46f9bd>] sctp_chunk_put.isra.3+0x8d/0x130
+ [<ffffffff8e4a0b5a>] sctp_ulpevent_free+0x2a/0x40
+`
+	const report3 = `WARNING: CPU: 1 PID: 3024 at net/core/skbuff.c:570 skb_release_data+0x3b0/0x3d0
+Call Trace:
+ __warn+0x1c4/0x1e0 kernel/panic.c:546
+ warn_slowpath_null+0x2c/0x40 kernel/panic.c:589
+ skb_release_data+0x3b0/0x3d0 net/core/skbuff.c:570
+ skb_release_all+0x4a/0x60 net/core/skbuff.c:631
+ kfree_skb+0x15/0x20 net/core/skbuff.c:700
+ tcp_close+0x8d/0x130 net/ipv4/tcp.c:2300
+`
+	const want = "skb_release_data skb_release_all __kfree_skb sctp_chunk_put"
+	if sig := StackSignature([]byte(report1)); sig != want {
+		t.Errorf("report1: got %q, want %q", sig, want)
+	}
+	if sig := StackSignature([]byte(report2)); sig != want {
+		t.Errorf("report2: got %q, want %q", sig, want)
+	}
+	if sig := StackSignature([]byte(report3)); sig == want || sig == "" {
+		t.Errorf("report3: got %q", sig)
+	}
+	if sig := StackSignature([]byte("BUG: soft lockup\nno stack here\n")); sig != "" {
+		t.Errorf("got %q for report without stack", sig)
+	}
+}
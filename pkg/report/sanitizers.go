@@ -0,0 +1,156 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Report is a structured representation of a crash report.
+type Report struct {
+	Title string
+	// Sanitizer that detected the bug: "KASAN", "KMSAN", "UBSAN" or empty.
+	Sanitizer string
+	// Bug type, e.g. "use-after-free", "uninit-value" or "shift-out-of-bounds".
+	Type string
+	// Access type for memory access bugs: "read" or "write".
+	Access     string
+	AccessSize int
+	// Accessed (corrupted) address, 0 if unknown.
+	Addr uint64
+	// Functions of the crash stack, top frames first.
+	Frames []string
+	// Stacks where the memory was allocated and freed (KASAN),
+	// or where the uninit value was created (KMSAN).
+	AllocFrames []string
+	FreeFrames  []string
+}
+
+var (
+	sanitizerTitleRe = regexp.MustCompile(`(?:BUG: )?(KASAN|KMSAN): ([a-z\-]+)`)
+	ubsanTitleRe     = regexp.MustCompile(`UBSAN: Undefined behaviour in`)
+	kasanAccessRe    = regexp.MustCompile(`(Read|Write) of size ([0-9]+) at addr ([0-9a-f]+)`)
+	kasanAddrRe      = regexp.MustCompile(`(?:on|at) address ([0-9a-f]+)`)
+	// UBSAN prints the bug type in free form on the line after the title.
+	ubsanTypes = []struct {
+		re  *regexp.Regexp
+		typ string
+	}{
+		{regexp.MustCompile(`^shift exponent|^left shift of`), "shift-out-of-bounds"},
+		{regexp.MustCompile(`^signed integer overflow`), "integer-overflow"},
+		{regexp.MustCompile(`^negation of`), "integer-overflow"},
+		{regexp.MustCompile(`^index .* is out of range`), "array-index-out-of-bounds"},
+		{regexp.MustCompile(`^division by zero`), "division-by-zero"},
+		{regexp.MustCompile(`^load of value .* is not a valid value`), "invalid-load"},
+		{regexp.MustCompile(`misaligned address`), "misaligned-access"},
+		{regexp.MustCompile(`null pointer`), "null-ptr-deref"},
+		{regexp.MustCompile(`^execution reached an unreachable program point`), "unreachable"},
+		{regexp.MustCompile(`^pointer index expression`), "pointer-overflow"},
+	}
+	// Headers of sections of sanitizer reports that contain stacks.
+	allocStackRe = regexp.MustCompile(`^(?:Allocated by task|Uninit was created at:|origin:)`)
+	freeStackRe  = regexp.MustCompile(`^Freed by task`)
+	// Headers of sections that end the current stack.
+	endStackRe = regexp.MustCompile(`^(?:The buggy address|Memory state around|Uninit was stored|Local variable|` +
+		`Bytes [0-9]+-[0-9]+ of|Disabling lock debugging|Kernel panic|=====)`)
+	// Frames of sanitizer runtime in allocation/free stacks.
+	allocStackSkip = regexp.MustCompile(`^(?:save_stack|save_stack_trace|set_track|depot_save_stack|` +
+		`kasan_.*|__kasan_.*|kmsan_.*|__msan_.*)$`)
+)
+
+// ParseReport returns structured information about the crash report text
+// (as returned by Reporter.Parse) with the given title. KASAN, KMSAN and UBSAN
+// reports are recognized, for other reports only the title and frames are filled.
+func ParseReport(title string, text []byte) *Report {
+	rep := &Report{
+		Title: title,
+	}
+	if match := sanitizerTitleRe.FindSubmatch(text); match != nil {
+		rep.Sanitizer = string(match[1])
+		rep.Type = string(match[2])
+	} else if loc := ubsanTitleRe.FindIndex(text); loc != nil {
+		rep.Sanitizer = "UBSAN"
+		rep.Type = ubsanType(text[loc[1]:])
+	}
+	if match := kasanAccessRe.FindSubmatch(text); match != nil {
+		rep.Access = strings.ToLower(string(match[1]))
+		rep.AccessSize, _ = strconv.Atoi(string(match[2]))
+		rep.Addr, _ = strconv.ParseUint(string(match[3]), 16, 64)
+	} else if match := kasanAddrRe.FindSubmatch(text); match != nil && rep.Sanitizer == "KASAN" {
+		rep.Addr, _ = strconv.ParseUint(string(match[1]), 16, 64)
+	}
+	rep.Frames, rep.AllocFrames, rep.FreeFrames = parseStacks(text)
+	return rep
+}
+
+func ubsanType(text []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(text))
+	s.Scan() // the rest of the title line
+	if !s.Scan() {
+		return ""
+	}
+	line := s.Bytes()
+	if loc := timestampRe.FindIndex(line); loc != nil {
+		line = line[loc[1]:]
+	}
+	for _, t := range ubsanTypes {
+		if t.re.Match(line) {
+			return t.typ
+		}
+	}
+	return "undefined-behavior"
+}
+
+// parseStacks splits the report into the crash, allocation and free stacks
+// and returns function names of the frames in each of them.
+func parseStacks(text []byte) (frames, alloc, free []string) {
+	cur := &frames
+	for s := bufio.NewScanner(bytes.NewReader(text)); s.Scan(); {
+		line := s.Bytes()
+		if loc := timestampRe.FindIndex(line); loc != nil {
+			line = line[loc[1]:]
+		}
+		switch {
+		case bytes.HasPrefix(line, []byte("Call Trace:")):
+			cur = &frames
+			continue
+		case allocStackRe.Match(line):
+			cur = &alloc
+			continue
+		case freeStackRe.Match(line):
+			cur = &free
+			continue
+		case endStackRe.Match(line):
+			cur = nil
+			continue
+		case cur == nil:
+			continue
+		}
+		fn := parseFrame(line)
+		if fn == "" || stackSignatureSkip.MatchString(fn) ||
+			cur != &frames && allocStackSkip.MatchString(fn) {
+			continue
+		}
+		*cur = append(*cur, fn)
+	}
+	return
+}
+
+// parseFrame returns normalized function name of the stack frame in line,
+// or an empty string if the line is not a (reliable) stack frame.
+func parseFrame(line []byte) string {
+	match := stackFrameRe.FindSubmatch(line)
+	if match == nil {
+		return ""
+	}
+	fn := string(match[1])
+	if pos := strings.IndexByte(fn, '.'); pos != -1 {
+		fn = fn[:pos]
+	}
+	return fn
+}
@@ -0,0 +1,118 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package report
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		text string
+		rep  Report
+	}{
+		{
+			text: `[   41.291542] BUG: KASAN: use-after-free in skb_release_data+0x3b0/0x3d0
+[   41.291548] Write of size 8 at addr ffff88003c8a4c88 by task syz-executor0/5391
+[   41.291565] Call Trace:
+[   41.291577]  dump_stack+0x194/0x257 lib/dump_stack.c:52
+[   41.291589]  print_address_description+0x73/0x250 mm/kasan/report.c:252
+[   41.291600]  kasan_report+0x23b/0x360 mm/kasan/report.c:409
+[   41.291605]  ? skb_release_data+0x3b0/0x3d0
+[   41.291618]  skb_release_data+0x3b0/0x3d0 net/core/skbuff.c:570
+[   41.291625]  skb_release_all+0x4a/0x60 net/core/skbuff.c:631
+[   41.291632] 
+[   41.291633] Allocated by task 5391:
+[   41.291640]  save_stack+0x43/0xd0 mm/kasan/kasan.c:447
+[   41.291645]  kasan_kmalloc+0xad/0xe0 mm/kasan/kasan.c:551
+[   41.291650]  __kmalloc_node_track_caller+0x47/0x70 mm/slab.c:3688
+[   41.291655]  __alloc_skb+0xf1/0x780 net/core/skbuff.c:219
+[   41.291660] 
+[   41.291661] Freed by task 5392:
+[   41.291665]  save_stack+0x43/0xd0 mm/kasan/kasan.c:447
+[   41.291670]  kasan_slab_free+0x71/0xc0 mm/kasan/kasan.c:524
+[   41.291675]  kfree+0xca/0x250 mm/slab.c:3820
+[   41.291680]  skb_free_head+0x74/0xb0 net/core/skbuff.c:554
+[   41.291685] 
+[   41.291690] The buggy address belongs to the object at ffff88003c8a4c80
+[   41.291695]  foo+0x1/0x2
+`,
+			rep: Report{
+				Title:       "title",
+				Sanitizer:   "KASAN",
+				Type:        "use-after-free",
+				Access:      "write",
+				AccessSize:  8,
+				Addr:        0xffff88003c8a4c88,
+				Frames:      []string{"skb_release_data", "skb_release_all"},
+				AllocFrames: []string{"__kmalloc_node_track_caller", "__alloc_skb"},
+				FreeFrames:  []string{"kfree", "skb_free_head"},
+			},
+		},
+		{
+			text: `BUG: KMSAN: uninit-value in __netif_receive_skb_core+0x1d1/0x2d40
+CPU: 0 PID: 3002 Comm: syz-executor0 Not tainted 4.13.0+ #1
+Call Trace:
+ __dump_stack lib/dump_stack.c:16 [inline]
+ dump_stack+0x172/0x1c0 lib/dump_stack.c:52
+ kmsan_report+0x145/0x3d0 mm/kmsan/kmsan.c:1016
+ __msan_warning_32+0x69/0xb0 mm/kmsan/kmsan_instr.c:766
+ __netif_receive_skb_core+0x1d1/0x2d40 net/core/dev.c:4330
+ __netif_receive_skb+0x74/0x1a0 net/core/dev.c:4511
+origin:
+ save_stack_trace+0x37/0x40 arch/x86/kernel/stacktrace.c:59
+ kmsan_internal_poison_shadow+0xb8/0x1b0 mm/kmsan/kmsan.c:302
+ kmsan_kmalloc+0x94/0x100 mm/kmsan/kmsan.c:337
+ __kmalloc_reserve net/core/skbuff.c:138 [inline]
+ __alloc_skb+0x2cd/0x740 net/core/skbuff.c:231
+================================================================================
+`,
+			rep: Report{
+				Title:       "title",
+				Sanitizer:   "KMSAN",
+				Type:        "uninit-value",
+				Frames:      []string{"__netif_receive_skb_core", "__netif_receive_skb"},
+				AllocFrames: []string{"__alloc_skb"},
+			},
+		},
+		{
+			text: `================================================================================
+UBSAN: Undefined behaviour in net/ipv4/tcp_input.c:3425:17
+shift exponent 65 is too large for 64-bit type 'long unsigned int'
+CPU: 1 PID: 3134 Comm: syz-executor6 Not tainted 4.14.0-rc2+ #1
+Call Trace:
+ dump_stack+0x194/0x257 lib/dump_stack.c:52
+ ubsan_epilogue+0xe/0x81 lib/ubsan.c:164
+ __ubsan_handle_shift_out_of_bounds+0x2b9/0x3b6 lib/ubsan.c:421
+ tcp_ack+0x41f5/0x5d80 net/ipv4/tcp_input.c:3425
+ tcp_rcv_state_process+0xe30/0x4c70 net/ipv4/tcp_input.c:5900
+================================================================================
+`,
+			rep: Report{
+				Title:     "title",
+				Sanitizer: "UBSAN",
+				Type:      "shift-out-of-bounds",
+				Frames:    []string{"tcp_ack", "tcp_rcv_state_process"},
+			},
+		},
+		{
+			text: `WARNING: CPU: 1 PID: 3024 at net/core/skbuff.c:570 skb_release_data+0x3b0/0x3d0
+Call Trace:
+ __warn+0x1c4/0x1e0 kernel/panic.c:546
+ skb_release_data+0x3b0/0x3d0 net/core/skbuff.c:570
+`,
+			rep: Report{
+				Title:  "title",
+				Frames: []string{"skb_release_data"},
+			},
+		},
+	}
+	for i, test := range tests {
+		rep := ParseReport("title", []byte(test.text))
+		if !reflect.DeepEqual(*rep, test.rep) {
+			t.Errorf("report #%v:\ngot:  %+v\nwant: %+v", i, *rep, test.rep)
+		}
+	}
+}
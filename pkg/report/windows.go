@@ -50,3 +50,7 @@ func (ctx *windows) ExtractGuiltyFile(report []byte) string {
 func (ctx *windows) GetMaintainers(file string) ([]string, error) {
 	panic("not implemented")
 }
+
+func (ctx *windows) GetSubsystem(file string) (string, error) {
+	panic("not implemented")
+}
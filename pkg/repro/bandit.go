@@ -0,0 +1,122 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package repro
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// Candidates are dropped once we are confident (banditConfidence) that their crash
+	// probability is below banditMinProb, testing them further is mostly a waste of VM time.
+	banditMinProb    = 0.1
+	banditConfidence = 0.95
+	// Reproducers that crash with probability p are retried during minimization and simplification
+	// so that a crash is missed with probability at most 1-retryConfidence, but at most maxRetries times.
+	retryConfidence = 0.9
+	maxRetries      = 10
+)
+
+type banditArm struct {
+	// Prior failures pseudo-count: candidates that are less likely to be guilty
+	// (e.g. programs executed long before the crash) start with a lower expected probability.
+	prior     int
+	successes int
+	failures  int
+	dropped   bool
+}
+
+// bandit allocates test runs among reproduction candidates (multi-armed bandit with Thompson sampling).
+// Crash probability of every candidate is modelled with Beta(1+successes, 1+prior+failures) posterior,
+// every run goes to the candidate with the largest probability sampled from its posterior.
+// As the result candidates that failed many times get fewer runs, but are not abandoned
+// while they still may be the guilty one, and hopeless candidates are dropped altogether.
+type bandit struct {
+	arms []*banditArm
+	rnd  *rand.Rand
+}
+
+// newBandit creates a bandit for n candidates ordered by decreasing prior likelihood to be guilty.
+func newBandit(n int, rnd *rand.Rand) *bandit {
+	b := &bandit{rnd: rnd}
+	for i := 0; i < n; i++ {
+		b.arms = append(b.arms, &banditArm{prior: i})
+	}
+	return b
+}
+
+// next returns the candidate to test next, or -1 if all candidates are dropped.
+func (b *bandit) next() int {
+	best, bestProb := -1, -1.0
+	for i, arm := range b.arms {
+		if arm.dropped {
+			continue
+		}
+		prob := betaSample(b.rnd, 1+arm.successes, 1+arm.prior+arm.failures)
+		if bestProb < prob {
+			best, bestProb = i, prob
+		}
+	}
+	return best
+}
+
+// record updates the candidate posterior with a test result.
+func (b *bandit) record(i int, crashed bool) {
+	arm := b.arms[i]
+	if crashed {
+		arm.successes++
+		return
+	}
+	arm.failures++
+	if arm.successes == 0 && upperBound(arm.failures) < banditMinProb {
+		arm.dropped = true
+	}
+}
+
+// prob returns the estimated crash probability of the candidate.
+func (b *bandit) prob(i int) float64 {
+	arm := b.arms[i]
+	if arm.successes+arm.failures == 0 {
+		return 0
+	}
+	return float64(arm.successes) / float64(arm.successes+arm.failures)
+}
+
+// upperBound returns the upper bound (with banditConfidence) of crash probability
+// of a candidate that did not crash in n runs.
+func upperBound(n int) float64 {
+	return 1 - math.Pow(1-banditConfidence, 1/float64(n))
+}
+
+// retriesFor returns number of runs required to observe a crash with probability retryConfidence
+// for a reproducer that crashes with probability p.
+func retriesFor(p float64) int {
+	if p <= 0 {
+		return maxRetries
+	}
+	if p >= 1 {
+		return 1
+	}
+	n := int(math.Ceil(math.Log(1-retryConfidence) / math.Log(1-p)))
+	if n < 1 {
+		n = 1
+	}
+	if n > maxRetries {
+		n = maxRetries
+	}
+	return n
+}
+
+// betaSample samples Beta(a, b) distribution for integer a and b
+// as the a-th smallest of a+b-1 uniform samples.
+func betaSample(rnd *rand.Rand, a, b int) float64 {
+	samples := make([]float64, a+b-1)
+	for i := range samples {
+		samples[i] = rnd.Float64()
+	}
+	sort.Float64s(samples)
+	return samples[a-1]
+}
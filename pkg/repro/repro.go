@@ -6,12 +6,15 @@ package repro
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"os"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/syzkaller/pkg/csource"
+	"github.com/google/syzkaller/pkg/kmemleak"
 	. "github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/pkg/report"
@@ -27,6 +30,7 @@ type Stats struct {
 	SimplifyProgTime time.Duration
 	ExtractCTime     time.Duration
 	SimplifyCTime    time.Duration
+	MeasureTime      time.Duration
 }
 
 type Result struct {
@@ -34,7 +38,13 @@ type Result struct {
 	Duration time.Duration
 	Opts     csource.Options
 	CRepro   bool
-	Stats    Stats
+	// Formatted C reproducer, filled if CRepro is set.
+	CSource []byte
+	// The final reproducer triggered the crash Successes times out of Runs.
+	// Runs is 0 if reliability was not measured.
+	Successes int
+	Runs      int
+	Stats     Stats
 	// Description, log and report of the final crash that we reproduced.
 	// Can be different from what we started reproducing.
 	Desc   string
@@ -46,14 +56,26 @@ type context struct {
 	cfg          *mgrconfig.Config
 	reporter     report.Reporter
 	crashDesc    string
+	leak         bool // reproducing a kmemleak report
 	instances    chan *instance
 	bootRequests chan int
+	parallel     int // number of tests that can run concurrently (number of VMs)
+	retries      int // number of runs of every minimization/simplification test (for flaky reproducers)
+	builds       *csource.BuildCache
+	mu           sync.Mutex
 	stats        Stats
 	desc         string
 	log          []byte
 	report       []byte
 }
 
+// crash describes a crash triggered by a single test run.
+type crash struct {
+	desc   string
+	log    []byte
+	report []byte
+}
+
 type instance struct {
 	*vm.Instance
 	index       int
@@ -80,13 +102,22 @@ func Run(crashLog []byte, cfg *mgrconfig.Config, reporter report.Reporter, vmPoo
 		crashDesc = "hang"
 	}
 
+	leak := strings.HasPrefix(crashDesc, "memory leak in ")
+	if leak {
+		entries = leakWindow(crashLog, entries, crashStart)
+	}
+
 	ctx := &context{
 		cfg:          cfg,
 		reporter:     reporter,
 		crashDesc:    crashDesc,
+		leak:         leak,
 		instances:    make(chan *instance, len(vmIndexes)),
 		bootRequests: make(chan int, len(vmIndexes)),
+		parallel:     len(vmIndexes),
+		builds:       csource.NewBuildCache(),
 	}
+	defer ctx.builds.Close()
 	ctx.reproLog(0, "%v programs, %v VMs", len(entries), len(vmIndexes))
 	var wg sync.WaitGroup
 	wg.Add(len(vmIndexes))
@@ -185,11 +216,6 @@ func (ctx *context) repro(entries []*prog.LogEntry, crashStart int) (*Result, er
 	if res == nil {
 		return nil, nil
 	}
-	defer func() {
-		if res != nil {
-			res.Opts.Repro = false
-		}
-	}()
 	res, err = ctx.minimizeProg(res)
 	if err != nil {
 		return nil, err
@@ -217,9 +243,67 @@ func (ctx *context) repro(entries []*prog.LogEntry, crashStart int) (*Result, er
 		}
 	}
 
+	ctx.measureReliability(res)
+
+	res.Opts.Repro = false
+	if res.CRepro {
+		src, err := csource.Write(res.Prog, res.Opts)
+		if err != nil {
+			ctx.reproLog(0, "failed to write C source: %v", err)
+			res.CRepro = false
+		} else {
+			if formatted, err := csource.Format(src); err == nil {
+				src = formatted
+			}
+			res.CSource = src
+		}
+	}
 	return res, nil
 }
 
+// Number of runs of the final reproducer used to measure its reliability.
+const reliabilityRuns = 5
+
+// measureReliability runs the final reproducer (C if available) several times
+// to find out how reliably it triggers the crash. Flaky reproducers should not
+// be presented as definitive.
+func (ctx *context) measureReliability(res *Result) {
+	ctx.reproLog(2, "measuring reproducer reliability")
+	start := time.Now()
+	defer func() {
+		ctx.stats.MeasureTime = time.Since(start)
+	}()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(reliabilityRuns)
+	for i := 0; i < reliabilityRuns; i++ {
+		go func() {
+			defer wg.Done()
+			var rep *crash
+			var err error
+			if res.CRepro {
+				rep, err = ctx.testCProg(res.Prog, res.Duration, res.Opts)
+			} else {
+				rep, err = ctx.testProg(res.Prog, res.Duration, res.Opts)
+			}
+			if err != nil {
+				// Don't count runs that failed for unrelated reasons (e.g. VM failures).
+				ctx.reproLog(1, "reliability run failed: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			res.Runs++
+			if rep != nil {
+				res.Successes++
+			}
+		}()
+	}
+	wg.Wait()
+	ctx.reproLog(2, "reproducer triggered the crash %v times out of %v", res.Successes, res.Runs)
+}
+
 func (ctx *context) extractProg(entries []*prog.LogEntry) (*Result, error) {
 	ctx.reproLog(2, "extracting reproducer from %v programs", len(entries))
 	start := time.Now()
@@ -228,26 +312,41 @@ func (ctx *context) extractProg(entries []*prog.LogEntry) (*Result, error) {
 	}()
 
 	// Extract last program on every proc.
-	procs := make(map[int]int)
-	for i, ent := range entries {
-		procs[ent.Proc] = i
-	}
-	var indices []int
-	for _, idx := range procs {
-		indices = append(indices, idx)
-	}
-	sort.Ints(indices)
 	var lastEntries []*prog.LogEntry
-	for i := len(indices) - 1; i >= 0; i-- {
-		lastEntries = append(lastEntries, entries[indices[i]])
+	for _, procEnts := range procEntries(entries) {
+		lastEntries = append(lastEntries, procEnts[len(procEnts)-1])
+	}
+	sort.Slice(lastEntries, func(i, j int) bool {
+		return lastEntries[i].Start > lastEntries[j].Start
+	})
+	// Last programs of all procs in log order, they may have been executed concurrently.
+	overlapped := reverseEntries(append([]*prog.LogEntry{}, lastEntries...))
+	if ctx.leak {
+		// Leaks are detected only by periodic scans, so any program in the window can be guilty.
+		lastEntries = reverseEntries(append([]*prog.LogEntry{}, entries...))
 	}
 
-	// The shortest duration is 10 seconds to detect simple crashes (i.e. no races and no hangs).
-	// The longest duration is 5 minutes to catch races and hangs. Note that this value must be larger
-	// than hang/no output detection duration in vm.MonitorExecution, which is currently set to 3 mins.
-	timeouts := []time.Duration{10 * time.Second, 1 * time.Minute, 5 * time.Minute}
+	if ctx.cfg.Repro_Budget != 0 {
+		deadline := time.Now().Add(time.Duration(ctx.cfg.Repro_Budget) * time.Minute)
+		res, err := ctx.extractProgBandit(lastEntries, overlapped, deadline)
+		if err != nil || res != nil {
+			return res, err
+		}
+		if time.Now().After(deadline) {
+			ctx.reproLog(0, "failed to extract reproducer within the time budget")
+			return nil, nil
+		}
+		// All candidates turned out to be hopeless, the crash is probably caused
+		// by several programs from the log.
+		res, err = ctx.extractProgBisect(reverseEntries(entries), reproTimeouts[len(reproTimeouts)-1])
+		if err != nil || res != nil {
+			return res, err
+		}
+		ctx.reproLog(0, "failed to extract reproducer")
+		return nil, nil
+	}
 
-	for _, timeout := range timeouts {
+	for _, timeout := range reproTimeouts {
 		// Execute each program separately to detect simple crashes caused by a single program.
 		// Programs are executed in reverse order, usually the last program is the guilty one.
 		res, err := ctx.extractProgSingle(reverseEntries(lastEntries), timeout)
@@ -259,6 +358,19 @@ func (ctx *context) extractProg(entries []*prog.LogEntry) (*Result, error) {
 			return res, nil
 		}
 
+		// Execute last programs of all procs together to detect crashes caused by overlapped
+		// programs, this is faster and more reliable than bisecting the whole log.
+		if len(overlapped) > 1 {
+			res, err = ctx.extractProgBisect(overlapped, timeout)
+			if err != nil {
+				return nil, err
+			}
+			if res != nil {
+				ctx.reproLog(3, "found reproducer with %d syscalls", len(res.Prog.Calls))
+				return res, nil
+			}
+		}
+
 		// Execute all programs and bisect the log to find multiple guilty programs.
 		res, err = ctx.extractProgBisect(reverseEntries(entries), timeout)
 		if err != nil {
@@ -274,47 +386,171 @@ func (ctx *context) extractProg(entries []*prog.LogEntry) (*Result, error) {
 	return nil, nil
 }
 
+// The shortest duration is 10 seconds to detect simple crashes (i.e. no races and no hangs).
+// The longest duration is 5 minutes to catch races and hangs. Note that this value must be larger
+// than hang/no output detection duration in vm.MonitorExecution, which is currently set to 3 mins.
+var reproTimeouts = []time.Duration{10 * time.Second, 1 * time.Minute, 5 * time.Minute}
+
+// extractProgBandit tests last programs of every proc separately and together until the deadline,
+// allocating runs among them according to their estimated probability to
+// reproduce the crash (see bandit). Subsequent runs of the same candidate use increasing timeouts.
+// Once a candidate crashes, its estimated crash probability determines number of retries
+// of every test during minimization and simplification.
+func (ctx *context) extractProgBandit(lastEntries, overlapped []*prog.LogEntry, deadline time.Time) (*Result, error) {
+	type candidate struct {
+		entries []*prog.LogEntry
+		opts    csource.Options
+	}
+	// Candidates are ordered by decreasing prior likelihood: usually the last program is the guilty one.
+	var cands []candidate
+	for _, ent := range lastEntries {
+		opts := ctx.createDefaultOps()
+		opts.Fault = ent.Fault
+		opts.FaultCall = ent.FaultCall
+		opts.FaultNth = ent.FaultNth
+		if opts.FaultCall < 0 || opts.FaultCall >= len(ent.P.Calls) {
+			opts.FaultCall = len(ent.P.Calls) - 1
+		}
+		cands = append(cands, candidate{[]*prog.LogEntry{ent}, opts})
+	}
+	if len(overlapped) > 1 {
+		cands = append(cands, candidate{overlapped, ctx.createDefaultOps()})
+	}
+	ctx.reproLog(3, "bandit: testing %v candidates within %v minutes", len(cands), ctx.cfg.Repro_Budget)
+
+	b := newBandit(len(cands), rand.New(rand.NewSource(time.Now().UnixNano())))
+	started := make([]int, len(cands))
+	type result struct {
+		idx      int
+		duration time.Duration
+		rep      *crash
+		err      error
+	}
+	results := make(chan result, ctx.parallel)
+	found, running := -1, 0
+	var foundDuration time.Duration
+	var foundCrash *crash
+	var err error
+	for {
+		for err == nil && found == -1 && running < ctx.parallel {
+			idx := b.next()
+			if idx == -1 || time.Now().After(deadline) {
+				break
+			}
+			duration := reproTimeouts[len(reproTimeouts)-1]
+			if started[idx] < len(reproTimeouts) {
+				duration = reproTimeouts[started[idx]]
+			}
+			started[idx]++
+			running++
+			go func(idx int, duration time.Duration) {
+				var rep *crash
+				var err error
+				if cand := cands[idx]; len(cand.entries) == 1 {
+					rep, err = ctx.testProg(cand.entries[0].P, duration, cand.opts)
+				} else {
+					rep, err = ctx.testProgs(cand.entries, duration, cand.opts)
+				}
+				results <- result{idx, duration, rep, err}
+			}(idx, duration)
+		}
+		if running == 0 {
+			break
+		}
+		res := <-results
+		running--
+		if res.err != nil {
+			if err == nil {
+				err = res.err
+			}
+			continue
+		}
+		b.record(res.idx, res.rep != nil)
+		if res.rep != nil && found == -1 {
+			found, foundDuration, foundCrash = res.idx, res.duration, res.rep
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if found == -1 {
+		ctx.reproLog(3, "bandit: failed to extract reproducer")
+		return nil, nil
+	}
+	ctx.saveCrash(foundCrash)
+	ctx.retries = retriesFor(b.prob(found))
+	ctx.reproLog(3, "bandit: candidate %v crashed, estimated probability %.2f, using %v retries",
+		found, b.prob(found), ctx.retries)
+	cand := cands[found]
+	if len(cand.entries) != 1 {
+		return ctx.extractProgBisect(cand.entries, foundDuration)
+	}
+	res := &Result{
+		Prog:     cand.entries[0].P,
+		Duration: foundDuration * 3 / 2,
+		Opts:     cand.opts,
+	}
+	ctx.reproLog(3, "found reproducer with %d syscalls", len(res.Prog.Calls))
+	return res, nil
+}
+
 func (ctx *context) createDefaultOps() csource.Options {
 	opts := csource.Options{
-		Threaded:   true,
-		Collide:    true,
-		Repeat:     true,
-		Procs:      ctx.cfg.Procs,
-		Sandbox:    ctx.cfg.Sandbox,
-		EnableTun:  true,
-		UseTmpDir:  true,
-		HandleSegv: true,
-		WaitRepeat: true,
-		Repro:      true,
+		Threaded:    true,
+		Collide:     true,
+		Repeat:      true,
+		Procs:       ctx.cfg.Procs,
+		Sandbox:     ctx.cfg.Sandbox,
+		SeccompDeny: ctx.cfg.Seccomp_Deny,
+		EnableTun:   true,
+		EnableUSB:   true,
+		UseTmpDir:   true,
+		HandleSegv:  true,
+		WaitRepeat:  true,
+		Repro:       true,
+
+		MatchExecutorEnv: true,
+		Sysctls:          ctx.cfg.Sysctl,
+
+		CallTimeout:    time.Duration(ctx.cfg.Call_Timeout) * time.Millisecond,
+		ProgramTimeout: time.Duration(ctx.cfg.Program_Timeout) * time.Millisecond,
+		Base64Data:     true,
+		EmbedProg:      true,
 	}
+	// Bugs in cgroup-interacting code paths may not reproduce outside of cgroups,
+	// simplification drops this if it is not needed.
+	opts.EnableCgroups = opts.Sandbox == "namespace"
 	return opts
 }
 
 func (ctx *context) extractProgSingle(entries []*prog.LogEntry, duration time.Duration) (*Result, error) {
 	ctx.reproLog(3, "single: executing %d programs separately with timeout %s", len(entries), duration)
 
-	opts := ctx.createDefaultOps()
-
+	var allOpts []csource.Options
 	for _, ent := range entries {
+		opts := ctx.createDefaultOps()
 		opts.Fault = ent.Fault
 		opts.FaultCall = ent.FaultCall
 		opts.FaultNth = ent.FaultNth
 		if opts.FaultCall < 0 || opts.FaultCall >= len(ent.P.Calls) {
 			opts.FaultCall = len(ent.P.Calls) - 1
 		}
-		crashed, err := ctx.testProg(ent.P, duration, opts)
-		if err != nil {
-			return nil, err
-		}
-		if crashed {
-			res := &Result{
-				Prog:     ent.P,
-				Duration: duration * 3 / 2,
-				Opts:     opts,
-			}
-			ctx.reproLog(3, "single: successfully extracted reproducer")
-			return res, nil
+		allOpts = append(allOpts, opts)
+	}
+	idx, err := ctx.testParallel(len(entries), func(i int) (*crash, error) {
+		return ctx.testProg(entries[i].P, duration, allOpts[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+	if idx != -1 {
+		res := &Result{
+			Prog:     entries[idx].P,
+			Duration: duration * 3 / 2,
+			Opts:     allOpts[idx],
 		}
+		ctx.reproLog(3, "single: successfully extracted reproducer")
+		return res, nil
 	}
 
 	ctx.reproLog(3, "single: failed to extract reproducer")
@@ -331,7 +567,7 @@ func (ctx *context) extractProgBisect(entries []*prog.LogEntry, baseDuration tim
 	}
 
 	// Bisect the log to find multiple guilty programs.
-	entries, err := ctx.bisectProgs(entries, func(progs []*prog.LogEntry) (bool, error) {
+	entries, err := ctx.bisectProgs(entries, func(progs []*prog.LogEntry) (*crash, error) {
 		return ctx.testProgs(progs, duration(len(progs)), opts)
 	})
 	if err != nil {
@@ -357,11 +593,12 @@ func (ctx *context) extractProgBisect(entries []*prog.LogEntry, baseDuration tim
 
 	// Execute the program without fault injection.
 	dur := duration(len(entries)) * 3 / 2
-	crashed, err := ctx.testProg(prog, dur, opts)
+	rep, err := ctx.testProg(prog, dur, opts)
 	if err != nil {
 		return nil, err
 	}
-	if crashed {
+	if rep != nil {
+		ctx.saveCrash(rep)
 		res := &Result{
 			Prog:     prog,
 			Duration: dur,
@@ -380,11 +617,12 @@ func (ctx *context) extractProgBisect(entries []*prog.LogEntry, baseDuration tim
 			if entry.FaultCall < 0 || entry.FaultCall >= len(entry.P.Calls) {
 				opts.FaultCall = calls + len(entry.P.Calls) - 1
 			}
-			crashed, err := ctx.testProg(prog, dur, opts)
+			rep, err := ctx.testProg(prog, dur, opts)
 			if err != nil {
 				return nil, err
 			}
-			if crashed {
+			if rep != nil {
+				ctx.saveCrash(rep)
 				res := &Result{
 					Prog:     prog,
 					Duration: dur,
@@ -414,12 +652,18 @@ func (ctx *context) minimizeProg(res *Result) (*Result, error) {
 		call = res.Opts.FaultCall
 	}
 	res.Prog, res.Opts.FaultCall = prog.Minimize(res.Prog, call, func(p1 *prog.Prog, callIndex int) bool {
-		crashed, err := ctx.testProg(p1, res.Duration, res.Opts)
+		rep, err := ctx.testRepeated(func() (*crash, error) {
+			return ctx.testProg(p1, res.Duration, res.Opts)
+		})
 		if err != nil {
 			ctx.reproLog(0, "minimization failed with %v", err)
 			return false
 		}
-		return crashed
+		if rep == nil {
+			return false
+		}
+		ctx.saveCrash(rep)
+		return true
 	}, true)
 
 	return res, nil
@@ -433,24 +677,28 @@ func (ctx *context) simplifyProg(res *Result) (*Result, error) {
 		ctx.stats.SimplifyProgTime = time.Since(start)
 	}()
 
-	for _, simplify := range progSimplifies {
-		opts := res.Opts
-		if simplify(&opts) {
-			crashed, err := ctx.testProg(res.Prog, res.Duration, opts)
-			if err != nil {
-				return nil, err
-			}
-			if crashed {
-				res.Opts = opts
-				// Simplification successfull, try extracting C repro.
-				res, err := ctx.extractC(res)
-				if err != nil {
-					return nil, err
-				}
-				if res.CRepro {
-					return res, nil
-				}
-			}
+	for start := 0; ; {
+		opts, idx, err := ctx.nextSimplification(progSimplifies, start, res.Opts,
+			func(opts csource.Options) (*crash, error) {
+				return ctx.testRepeated(func() (*crash, error) {
+					return ctx.testProg(res.Prog, res.Duration, opts)
+				})
+			})
+		if err != nil {
+			return nil, err
+		}
+		if idx == -1 {
+			break
+		}
+		start = idx + 1
+		res.Opts = opts
+		// Simplification successfull, try extracting C repro.
+		res, err = ctx.extractC(res)
+		if err != nil {
+			return nil, err
+		}
+		if res.CRepro {
+			return res, nil
 		}
 	}
 
@@ -459,17 +707,27 @@ func (ctx *context) simplifyProg(res *Result) (*Result, error) {
 
 // Try triggering crash with a C reproducer.
 func (ctx *context) extractC(res *Result) (*Result, error) {
+	if ctx.leak {
+		// C reproducers don't scan for memory leaks.
+		ctx.reproLog(2, "not extracting C reproducer for memory leak")
+		return res, nil
+	}
 	ctx.reproLog(2, "extracting C reproducer")
 	start := time.Now()
 	defer func() {
 		ctx.stats.ExtractCTime = time.Since(start)
 	}()
 
-	crashed, err := ctx.testCProg(res.Prog, res.Duration, res.Opts)
+	rep, err := ctx.testRepeated(func() (*crash, error) {
+		return ctx.testCProg(res.Prog, res.Duration, res.Opts)
+	})
 	if err != nil {
 		return nil, err
 	}
-	res.CRepro = crashed
+	if rep != nil {
+		ctx.saveCrash(rep)
+		res.CRepro = true
+	}
 	return res, nil
 }
 
@@ -481,22 +739,116 @@ func (ctx *context) simplifyC(res *Result) (*Result, error) {
 		ctx.stats.SimplifyCTime = time.Since(start)
 	}()
 
-	for _, simplify := range cSimplifies {
-		opts := res.Opts
-		if simplify(&opts) {
-			crashed, err := ctx.testCProg(res.Prog, res.Duration, opts)
-			if err != nil {
-				return nil, err
-			}
-			if crashed {
-				res.Opts = opts
-			}
+	for start := 0; ; {
+		opts, idx, err := ctx.nextSimplification(cSimplifies, start, res.Opts,
+			func(opts csource.Options) (*crash, error) {
+				return ctx.testRepeated(func() (*crash, error) {
+					return ctx.testCProg(res.Prog, res.Duration, opts)
+				})
+			})
+		if err != nil {
+			return nil, err
 		}
+		if idx == -1 {
+			break
+		}
+		start = idx + 1
+		res.Opts = opts
 	}
 	return res, nil
 }
 
-func (ctx *context) testProg(p *prog.Prog, duration time.Duration, opts csource.Options) (crashed bool, err error) {
+// nextSimplification finds the first simplification in simplifies[start:] that,
+// applied to opts, still triggers the crash. Applicable simplifications are tested
+// speculatively in parallel against the same opts. Since all simplifications before
+// the found one have failed, the result is the same as with serial testing.
+// Returns the simplified options and index of the simplification, or -1 if none works.
+func (ctx *context) nextSimplification(simplifies []Simplify, start int, opts csource.Options,
+	test func(opts csource.Options) (*crash, error)) (csource.Options, int, error) {
+	var candidates []csource.Options
+	var indices []int
+	for i := start; i < len(simplifies); i++ {
+		candidate := opts
+		if simplifies[i](&candidate) {
+			candidates = append(candidates, candidate)
+			indices = append(indices, i)
+		}
+	}
+	idx, err := ctx.testParallel(len(candidates), func(i int) (*crash, error) {
+		return test(candidates[i])
+	})
+	if err != nil || idx == -1 {
+		return opts, -1, err
+	}
+	return candidates[idx], indices[idx], nil
+}
+
+// testParallel runs tests 0..n-1 concurrently on the available VMs and returns
+// index of the first (in order) test that crashed, or -1 if none crashed.
+// Tests after a crashed one are not started (their results are not needed),
+// but all tests before it are completed, so the result does not depend on
+// the number of VMs and is the same as with serial execution.
+// Only the crash of the returned test is saved as the current crash.
+func (ctx *context) testParallel(n int, test func(i int) (*crash, error)) (int, error) {
+	parallel := ctx.parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	type result struct {
+		idx int
+		rep *crash
+		err error
+	}
+	results := make(chan result, n)
+	reps := make([]*crash, n)
+	first := n
+	next, running := 0, 0
+	var err error
+	for {
+		for err == nil && running < parallel && next < first {
+			go func(idx int) {
+				rep, err := test(idx)
+				results <- result{idx, rep, err}
+			}(next)
+			next++
+			running++
+		}
+		if running == 0 {
+			break
+		}
+		res := <-results
+		running--
+		if res.err != nil && err == nil {
+			err = res.err
+		}
+		reps[res.idx] = res.rep
+		if res.rep != nil && res.idx < first {
+			first = res.idx
+		}
+	}
+	if err != nil {
+		return -1, err
+	}
+	if first == n {
+		return -1, nil
+	}
+	ctx.saveCrash(reps[first])
+	return first, nil
+}
+
+// testRepeated runs test up to ctx.retries times until it crashes,
+// so that flaky reproducers are not rejected after a single unlucky run.
+func (ctx *context) testRepeated(test func() (*crash, error)) (*crash, error) {
+	for i := 0; i == 0 || i < ctx.retries; i++ {
+		rep, err := test()
+		if err != nil || rep != nil {
+			return rep, err
+		}
+	}
+	return nil, nil
+}
+
+func (ctx *context) testProg(p *prog.Prog, duration time.Duration, opts csource.Options) (rep *crash, err error) {
 	entry := prog.LogEntry{P: p}
 	if opts.Fault {
 		entry.Fault = true
@@ -506,25 +858,25 @@ func (ctx *context) testProg(p *prog.Prog, duration time.Duration, opts csource.
 	return ctx.testProgs([]*prog.LogEntry{&entry}, duration, opts)
 }
 
-func (ctx *context) testProgs(entries []*prog.LogEntry, duration time.Duration, opts csource.Options) (crashed bool, err error) {
+func (ctx *context) testProgs(entries []*prog.LogEntry, duration time.Duration, opts csource.Options) (rep *crash, err error) {
 	inst := <-ctx.instances
 	if inst == nil {
-		return false, fmt.Errorf("all VMs failed to boot")
+		return nil, fmt.Errorf("all VMs failed to boot")
 	}
 	defer ctx.returnInstance(inst)
 	if len(entries) == 0 {
-		return false, fmt.Errorf("no programs to execute")
+		return nil, fmt.Errorf("no programs to execute")
 	}
 
 	pstr := encodeEntries(entries)
 	progFile, err := osutil.WriteTempFile(pstr)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	defer os.Remove(progFile)
 	vmProgFile, err := inst.Copy(progFile)
 	if err != nil {
-		return false, fmt.Errorf("failed to copy to VM: %v", err)
+		return nil, fmt.Errorf("failed to copy to VM: %v", err)
 	}
 
 	repeat := 1
@@ -545,65 +897,72 @@ func (ctx *context) testProgs(entries []*prog.LogEntry, duration time.Duration,
 		}
 		program += "]"
 	}
+	seccompDeny, err := csource.SeccompDenyNumbers(entries[0].P.Target, opts.SeccompDeny)
+	if err != nil {
+		return nil, err
+	}
 	command := fmt.Sprintf("%v -executor %v -arch=%v -cover=0 -procs=%v -repeat=%v"+
-		" -sandbox %v -threaded=%v -collide=%v %v",
+		" -sandbox %v -seccomp_deny=%v -threaded=%v -collide=%v -kaslr_leak=%v -resource_leak=%v -leak=%v%v %v",
 		inst.execprogBin, inst.executorBin, ctx.cfg.TargetArch, opts.Procs, repeat,
-		opts.Sandbox, opts.Threaded, opts.Collide, vmProgFile)
+		opts.Sandbox, seccompDeny, opts.Threaded, opts.Collide, ctx.cfg.Kaslr_Leak,
+		ctx.cfg.Resource_Leak, ctx.leak, ctx.cfg.TimeoutFlags(), vmProgFile)
 	ctx.reproLog(2, "testing program (duration=%v, %+v): %s", duration, opts, program)
-	return ctx.testImpl(inst.Instance, command, duration)
+	return ctx.testImpl(inst.Instance, ctx.cfg.VMCommand(command), duration)
 }
 
-func (ctx *context) testCProg(p *prog.Prog, duration time.Duration, opts csource.Options) (crashed bool, err error) {
-	src, err := csource.Write(p, opts)
-	if err != nil {
-		return false, err
-	}
-	srcf, err := osutil.WriteTempFile(src)
-	if err != nil {
-		return false, err
-	}
-	bin, err := csource.Build(p.Target, "c", srcf)
+func (ctx *context) testCProg(p *prog.Prog, duration time.Duration, opts csource.Options) (rep *crash, err error) {
+	bin, err := ctx.builds.Build(p, opts)
 	if err != nil {
-		return false, err
+		if buildErr, ok := err.(*csource.BuildError); ok {
+			// This is a bug in csource, the full report allows to debug it.
+			ctx.reproLog(0, "%v", buildErr)
+			ctx.reproLog(1, "%s", buildErr.Report())
+		}
+		return nil, err
 	}
-	defer os.Remove(bin)
 	ctx.reproLog(2, "testing compiled C program (duration=%v, %+v): %s", duration, opts, p)
-	crashed, err = ctx.testBin(bin, duration)
+	rep, err = ctx.testBin(bin, duration)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return crashed, nil
+	return rep, nil
 }
 
-func (ctx *context) testBin(bin string, duration time.Duration) (crashed bool, err error) {
+func (ctx *context) testBin(bin string, duration time.Duration) (rep *crash, err error) {
 	inst := <-ctx.instances
 	if inst == nil {
-		return false, fmt.Errorf("all VMs failed to boot")
+		return nil, fmt.Errorf("all VMs failed to boot")
 	}
 	defer ctx.returnInstance(inst)
 
 	bin, err = inst.Copy(bin)
 	if err != nil {
-		return false, fmt.Errorf("failed to copy to VM: %v", err)
+		return nil, fmt.Errorf("failed to copy to VM: %v", err)
 	}
 	return ctx.testImpl(inst.Instance, bin, duration)
 }
 
-func (ctx *context) testImpl(inst *vm.Instance, command string, duration time.Duration) (crashed bool, err error) {
+func (ctx *context) testImpl(inst *vm.Instance, command string, duration time.Duration) (*crash, error) {
 	outc, errc, err := inst.Run(duration, nil, command)
 	if err != nil {
-		return false, fmt.Errorf("failed to run command in VM: %v", err)
+		return nil, fmt.Errorf("failed to run command in VM: %v", err)
 	}
 	desc, report, output, crashed, _ := vm.MonitorExecution(outc, errc, false, ctx.reporter)
 	if !crashed {
 		ctx.reproLog(2, "program did not crash")
-		return false, nil
+		return nil, nil
 	}
-	ctx.desc = desc
-	ctx.log = output
-	ctx.report = report
 	ctx.reproLog(2, "program crashed: %v", desc)
-	return true, nil
+	return &crash{desc, output, report}, nil
+}
+
+// saveCrash remembers rep as the crash triggered by the current reproducer.
+func (ctx *context) saveCrash(rep *crash) {
+	ctx.mu.Lock()
+	ctx.desc = rep.desc
+	ctx.log = rep.log
+	ctx.report = rep.report
+	ctx.mu.Unlock()
 }
 
 func (ctx *context) returnInstance(inst *instance) {
@@ -614,10 +973,12 @@ func (ctx *context) returnInstance(inst *instance) {
 func (ctx *context) reproLog(level int, format string, args ...interface{}) {
 	prefix := fmt.Sprintf("reproducing crash '%v': ", ctx.crashDesc)
 	Logf(level, prefix+format, args...)
+	ctx.mu.Lock()
 	ctx.stats.Log = append(ctx.stats.Log, []byte(fmt.Sprintf(format, args...)+"\n")...)
+	ctx.mu.Unlock()
 }
 
-func (ctx *context) bisectProgs(progs []*prog.LogEntry, pred func([]*prog.LogEntry) (bool, error)) ([]*prog.LogEntry, error) {
+func (ctx *context) bisectProgs(progs []*prog.LogEntry, pred func([]*prog.LogEntry) (*crash, error)) ([]*prog.LogEntry, error) {
 	ctx.reproLog(3, "bisect: bisecting %d programs", len(progs))
 
 	compose := func(guilty1, guilty2 [][]*prog.LogEntry, chunk []*prog.LogEntry) []*prog.LogEntry {
@@ -645,14 +1006,15 @@ func (ctx *context) bisectProgs(progs []*prog.LogEntry, pred func([]*prog.LogEnt
 	}
 
 	ctx.reproLog(3, "bisect: executing all %d programs", len(progs))
-	crashed, err := pred(progs)
+	rep, err := pred(progs)
 	if err != nil {
 		return nil, err
 	}
-	if !crashed {
+	if rep == nil {
 		ctx.reproLog(3, "bisect: didn't crash")
 		return nil, nil
 	}
+	ctx.saveCrash(rep)
 
 	guilty := [][]*prog.LogEntry{progs}
 again:
@@ -670,14 +1032,20 @@ again:
 		chunk2 := chunk[len(chunk)/2 : len(chunk)]
 		ctx.reproLog(3, "bisect: chunk split: <%v> => <%v>, <%v>", len(chunk), len(chunk1), len(chunk2))
 
-		ctx.reproLog(3, "bisect: triggering crash without chunk #1")
-		progs := compose(guilty1, guilty2, chunk2)
-		crashed, err := pred(progs)
+		// Both halves are tested in parallel, eviction of chunk #1 is preferred.
+		ctx.reproLog(3, "bisect: triggering crash without chunk #1 and without chunk #2")
+		candidates := [][]*prog.LogEntry{
+			compose(guilty1, guilty2, chunk2),
+			compose(guilty1, guilty2, chunk1),
+		}
+		evicted, err := ctx.testParallel(len(candidates), func(i int) (*crash, error) {
+			return pred(candidates[i])
+		})
 		if err != nil {
 			return nil, err
 		}
 
-		if crashed {
+		if evicted == 0 {
 			guilty = nil
 			guilty = append(guilty, guilty1...)
 			guilty = append(guilty, chunk2)
@@ -686,14 +1054,7 @@ again:
 			goto again
 		}
 
-		ctx.reproLog(3, "bisect: triggering crash without chunk #2")
-		progs = compose(guilty1, guilty2, chunk1)
-		crashed, err = pred(progs)
-		if err != nil {
-			return nil, err
-		}
-
-		if crashed {
+		if evicted == 1 {
 			guilty = nil
 			guilty = append(guilty, guilty1...)
 			guilty = append(guilty, chunk1)
@@ -725,6 +1086,46 @@ again:
 	return progs, nil
 }
 
+// leakWindow returns entries executed after the two leak scans that precede the leak report
+// at crashStart: kmemleak reports an object only on the second scan that finds it unreferenced.
+func leakWindow(crashLog []byte, entries []*prog.LogEntry, crashStart int) []*prog.LogEntry {
+	var scans []int
+	for pos := 0; ; {
+		idx := bytes.Index(crashLog[pos:], []byte(kmemleak.ScanMarker))
+		if idx == -1 || pos+idx >= crashStart {
+			break
+		}
+		scans = append(scans, pos+idx)
+		pos += idx + len(kmemleak.ScanMarker)
+	}
+	if len(scans) < 2 {
+		return entries
+	}
+	windowStart := scans[len(scans)-2]
+	for i, ent := range entries {
+		if ent.Start > windowStart {
+			return entries[i:]
+		}
+	}
+	return entries
+}
+
+// procEntries groups log entries by proc, entries of each proc are in log order.
+func procEntries(entries []*prog.LogEntry) [][]*prog.LogEntry {
+	procs := make(map[int]int)
+	var res [][]*prog.LogEntry
+	for _, ent := range entries {
+		idx, ok := procs[ent.Proc]
+		if !ok {
+			idx = len(res)
+			procs[ent.Proc] = idx
+			res = append(res, nil)
+		}
+		res[idx] = append(res[idx], ent)
+	}
+	return res
+}
+
 func reverseEntries(entries []*prog.LogEntry) []*prog.LogEntry {
 	last := len(entries) - 1
 	for i := 0; i < len(entries)/2; i++ {
@@ -791,6 +1192,10 @@ var progSimplifies = []Simplify{
 			return false
 		}
 		opts.Sandbox = "none"
+		opts.SeccompDeny = nil
+		opts.EnableCgroups = false
+		opts.SandboxUID = 0
+		opts.SandboxGID = 0
 		return true
 	},
 }
@@ -801,6 +1206,17 @@ var cSimplifies = append(progSimplifies, []Simplify{
 			return false
 		}
 		opts.Sandbox = ""
+		opts.SeccompDeny = nil
+		opts.EnableCgroups = false
+		opts.SandboxUID = 0
+		opts.SandboxGID = 0
+		return true
+	},
+	func(opts *csource.Options) bool {
+		if !opts.EnableCgroups {
+			return false
+		}
+		opts.EnableCgroups = false
 		return true
 	},
 	func(opts *csource.Options) bool {
@@ -810,6 +1226,13 @@ var cSimplifies = append(progSimplifies, []Simplify{
 		opts.EnableTun = false
 		return true
 	},
+	func(opts *csource.Options) bool {
+		if !opts.EnableUSB {
+			return false
+		}
+		opts.EnableUSB = false
+		return true
+	},
 	func(opts *csource.Options) bool {
 		if !opts.UseTmpDir || opts.Sandbox == "namespace" {
 			return false
@@ -824,11 +1247,19 @@ var cSimplifies = append(progSimplifies, []Simplify{
 		opts.HandleSegv = false
 		return true
 	},
+	func(opts *csource.Options) bool {
+		if !opts.MatchExecutorEnv {
+			return false
+		}
+		opts.MatchExecutorEnv = false
+		return true
+	},
 	func(opts *csource.Options) bool {
 		if !opts.WaitRepeat {
 			return false
 		}
 		opts.WaitRepeat = false
+		opts.ProgramTimeout = 0
 		return true
 	},
 }...)
@@ -4,11 +4,15 @@
 package repro
 
 import (
+	"bytes"
+	"fmt"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/syzkaller/pkg/csource"
+	"github.com/google/syzkaller/pkg/kmemleak"
 	"github.com/google/syzkaller/prog"
 )
 
@@ -28,6 +32,7 @@ func TestBisect(t *testing.T) {
 
 	rd, iters := initTest(t)
 	for n := 0; n < iters; n++ {
+		ctx.parallel = 1 + rd.Intn(4)
 		var progs []*prog.LogEntry
 		numTotal := rd.Intn(300)
 		numGuilty := 0
@@ -45,14 +50,17 @@ func TestBisect(t *testing.T) {
 			progs = append(progs, &prog)
 			numGuilty += 1
 		}
-		progs, _ = ctx.bisectProgs(progs, func(p []*prog.LogEntry) (bool, error) {
+		progs, _ = ctx.bisectProgs(progs, func(p []*prog.LogEntry) (*crash, error) {
 			guilty := 0
 			for _, prog := range p {
 				if prog.Proc == 42 {
 					guilty += 1
 				}
 			}
-			return guilty == numGuilty, nil
+			if guilty != numGuilty {
+				return nil, nil
+			}
+			return &crash{}, nil
 		})
 		if len(progs) != numGuilty {
 			t.Fatalf("bisect test failed: wrong number of guilty progs: got: %v, want: %v", len(progs), numGuilty)
@@ -65,6 +73,118 @@ func TestBisect(t *testing.T) {
 	}
 }
 
+func TestProcEntries(t *testing.T) {
+	var entries []*prog.LogEntry
+	for i, proc := range []int{1, 0, 1, 2, 0, 1} {
+		entries = append(entries, &prog.LogEntry{Proc: proc, Start: i})
+	}
+	procs := procEntries(entries)
+	want := [][]int{{0, 2, 5}, {1, 4}, {3}}
+	if len(procs) != len(want) {
+		t.Fatalf("got %v procs, want %v", len(procs), len(want))
+	}
+	for i, procEnts := range procs {
+		var starts []int
+		for _, ent := range procEnts {
+			if ent.Proc != procEnts[0].Proc {
+				t.Fatalf("proc %v: mixed procs %v and %v", i, ent.Proc, procEnts[0].Proc)
+			}
+			starts = append(starts, ent.Start)
+		}
+		if fmt.Sprint(starts) != fmt.Sprint(want[i]) {
+			t.Errorf("proc %v: got entries %v, want %v", i, starts, want[i])
+		}
+	}
+}
+
+func TestLeakWindow(t *testing.T) {
+	log := []byte("executing program 0\n" +
+		kmemleak.ScanMarker + "\n" +
+		"executing program 1\n" +
+		kmemleak.ScanMarker + "\n" +
+		"executing program 2\n" +
+		kmemleak.ScanMarker + "\n" +
+		"executing program 3\n" +
+		"unreferenced object\n" +
+		kmemleak.ScanMarker + "\n" +
+		"executing program 4\n")
+	var entries []*prog.LogEntry
+	for i := 0; i < 5; i++ {
+		start := bytes.Index(log, []byte(fmt.Sprintf("executing program %v", i)))
+		entries = append(entries, &prog.LogEntry{Proc: i, Start: start})
+	}
+	crashStart := bytes.Index(log, []byte("unreferenced object"))
+	window := leakWindow(log, entries, crashStart)
+	var procs []int
+	for _, ent := range window {
+		procs = append(procs, ent.Proc)
+	}
+	// The crash is cut later, programs executed after it are still in the window.
+	if want := []int{2, 3, 4}; fmt.Sprint(procs) != fmt.Sprint(want) {
+		t.Fatalf("got window %v, want %v", procs, want)
+	}
+	if window := leakWindow(log, entries, entries[1].Start); len(window) != len(entries) {
+		t.Fatalf("window without preceding scans has %v entries, want %v", len(window), len(entries))
+	}
+}
+
+func TestTestParallel(t *testing.T) {
+	rd, iters := initTest(t)
+	for n := 0; n < iters; n++ {
+		ctx := &context{parallel: 1 + rd.Intn(8)}
+		crashes := make([]bool, rd.Intn(20))
+		delays := make([]time.Duration, len(crashes))
+		want := -1
+		for i := range crashes {
+			delays[i] = time.Duration(rd.Intn(100)) * time.Microsecond
+			crashes[i] = rd.Intn(5) == 0
+			if crashes[i] && want == -1 {
+				want = i
+			}
+		}
+		var mu sync.Mutex
+		started := make(map[int]bool)
+		got, err := ctx.testParallel(len(crashes), func(i int) (*crash, error) {
+			mu.Lock()
+			if started[i] {
+				t.Errorf("test %v started twice", i)
+			}
+			started[i] = true
+			mu.Unlock()
+			time.Sleep(delays[i])
+			if !crashes[i] {
+				return nil, nil
+			}
+			return &crash{desc: fmt.Sprint(i)}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("crashes %v, parallel %v: got %v, want %v", crashes, ctx.parallel, got, want)
+		}
+		if want != -1 && ctx.desc != fmt.Sprint(want) {
+			t.Fatalf("crashes %v, parallel %v: saved crash of test %v, want %v",
+				crashes, ctx.parallel, ctx.desc, want)
+		}
+		for i := 0; i < want; i++ {
+			if !started[i] {
+				t.Fatalf("test %v before the crashed one is not started", i)
+			}
+		}
+	}
+	ctx := &context{parallel: 3}
+	_, err := ctx.testParallel(5, func(i int) (*crash, error) {
+		if i == 2 {
+			return nil, fmt.Errorf("VM failed")
+		}
+		return nil, nil
+	})
+	if err == nil {
+		t.Fatalf("error is not propagated")
+	}
+}
+
 func TestSimplifies(t *testing.T) {
 	opts := csource.Options{
 		Threaded:   true,
@@ -77,6 +197,8 @@ func TestSimplifies(t *testing.T) {
 		HandleSegv: true,
 		WaitRepeat: true,
 		Repro:      true,
+
+		ProgramTimeout: 10 * time.Second,
 	}
 	var check func(opts csource.Options, i int)
 	check = func(opts csource.Options, i int) {
@@ -93,3 +215,67 @@ func TestSimplifies(t *testing.T) {
 	}
 	check(opts, 0)
 }
+
+func TestBandit(t *testing.T) {
+	rd, iters := initTest(t)
+	probs := []float64{0, 0, 0.3, 0, 0}
+	for n := 0; n < iters/10; n++ {
+		b := newBandit(len(probs), rand.New(rand.NewSource(rd.Int63())))
+		pulls := make([]int, len(probs))
+		found := -1
+		for found == -1 {
+			idx := b.next()
+			if idx == -1 {
+				t.Fatalf("all candidates are dropped, pulls %v", pulls)
+			}
+			pulls[idx]++
+			crashed := rd.Float64() < probs[idx]
+			b.record(idx, crashed)
+			if crashed {
+				found = idx
+			}
+		}
+		if found != 2 {
+			t.Fatalf("found candidate %v", found)
+		}
+		for i, p := range probs {
+			if p == 0 && pulls[i] > 29 {
+				t.Fatalf("hopeless candidate %v is not dropped, pulls %v", i, pulls)
+			}
+		}
+	}
+	b := newBandit(2, rd)
+	for i := 0; i < 100 && !b.arms[0].dropped; i++ {
+		b.record(0, false)
+	}
+	if !b.arms[0].dropped || b.arms[0].failures != 29 {
+		t.Fatalf("candidate is dropped after %v failures", b.arms[0].failures)
+	}
+	if idx := b.next(); idx != 1 {
+		t.Fatalf("dropped candidate is chosen")
+	}
+	b.record(1, true)
+	b.record(1, false)
+	if p := b.prob(1); p != 0.5 {
+		t.Fatalf("bad probability estimate %v", p)
+	}
+}
+
+func TestRetriesFor(t *testing.T) {
+	tests := []struct {
+		prob    float64
+		retries int
+	}{
+		{1, 1},
+		{0.95, 1},
+		{0.5, 4},
+		{0.3, 7},
+		{0.1, 10},
+		{0, 10},
+	}
+	for _, test := range tests {
+		if got := retriesFor(test.prob); got != test.retries {
+			t.Errorf("retriesFor(%v) = %v, want %v", test.prob, got, test.retries)
+		}
+	}
+}
@@ -5,11 +5,17 @@
 // between various parts of the system.
 package rpctype
 
+import "time"
+
 type RpcInput struct {
-	Call   string
-	Prog   []byte
-	Signal []uint32
-	Cover  []uint32
+	Call      string
+	CallIndex int // index of the call that gave new signal
+	Prog      []byte
+	Signal    []uint32
+	Cover     []uint32
+	Errno     int           // errno returned by the call during triage (0 if it succeeded)
+	Duration  time.Duration // max duration of the call during triage
+	Stability int           // percent of the call signal that was stable across triage runs (0 if unknown)
 }
 
 type RpcCandidate struct {
@@ -28,6 +34,10 @@ type ConnectRes struct {
 	Candidates   []RpcCandidate
 	EnabledCalls string
 	NeedCheck    bool
+	// Names of syscalls disabled at runtime, fuzzer does not generate new calls to them.
+	DisabledCalls []string
+	// Weights of choosing syscalls during generation indexed by syscall ID (nil if not used).
+	CallWeights []float32
 }
 
 type CheckArgs struct {
@@ -60,6 +70,13 @@ type PollRes struct {
 	Candidates []RpcCandidate
 	NewInputs  []RpcInput
 	MaxSignal  []uint32
+	// If set, the set of syscalls disabled at runtime has changed to DisabledCalls
+	// and syscall weights have changed to CallWeights.
+	UpdateCalls   bool
+	DisabledCalls []string
+	CallWeights   []float32
+	// Fuzzer needs to scan for memory leaks (with mgrconfig.Config.Leak_Scan_Period).
+	LeakScan bool
 }
 
 type HubConnectArgs struct {
@@ -68,6 +85,9 @@ type HubConnectArgs struct {
 	Key    string
 	// Manager name, must start with Client.
 	Manager string
+	// Corpus domain of the manager (target OS/arch and optional kernel config tag).
+	// Programs and repros are exchanged only between managers with the same domain.
+	Domain string
 	// Manager has started with an empty corpus and requests whole hub corpus.
 	Fresh bool
 	// Set of system call names supported by this manager.
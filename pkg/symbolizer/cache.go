@@ -0,0 +1,44 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package symbolizer
+
+import (
+	"sync"
+)
+
+// Cache caches symbolization results, so that the same PCs (e.g. common stack frames
+// in reports from the same kernel) are not passed to addr2line again.
+// Cache can be used concurrently and with different Symbolizer's.
+type Cache struct {
+	mu    sync.Mutex
+	cache map[cacheKey][]Frame
+}
+
+type cacheKey struct {
+	bin string
+	pc  uint64
+}
+
+// Symbolize returns cached frames for bin/pc, or calls inner and caches the result.
+// Errors are not cached since they are usually transient (e.g. addr2line crashed).
+func (c *Cache) Symbolize(inner func(bin string, pc uint64) ([]Frame, error), bin string, pc uint64) ([]Frame, error) {
+	key := cacheKey{bin, pc}
+	c.mu.Lock()
+	frames, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok {
+		return frames, nil
+	}
+	frames, err := inner(bin, pc)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.cache == nil {
+		c.cache = make(map[cacheKey][]Frame)
+	}
+	c.cache[key] = frames
+	c.mu.Unlock()
+	return frames, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package symbolizer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	called := make(map[cacheKey]int)
+	inner := func(bin string, pc uint64) ([]Frame, error) {
+		called[cacheKey{bin, pc}]++
+		if pc == 0 {
+			return nil, fmt.Errorf("bad pc")
+		}
+		return []Frame{{PC: pc, Func: bin}}, nil
+	}
+	var cache Cache
+	for i := 0; i < 3; i++ {
+		for _, bin := range []string{"vmlinux1", "vmlinux2"} {
+			for _, pc := range []uint64{0, 0x10, 0x20} {
+				frames, err := cache.Symbolize(inner, bin, pc)
+				if pc == 0 {
+					if err == nil {
+						t.Fatalf("no error for pc 0")
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := []Frame{{PC: pc, Func: bin}}; !reflect.DeepEqual(frames, want) {
+					t.Fatalf("got %+v, want %+v", frames, want)
+				}
+			}
+		}
+	}
+	for key, n := range called {
+		want := 1
+		if key.pc == 0 {
+			want = 3 // errors are not cached
+		}
+		if n != want {
+			t.Errorf("%v/0x%x symbolized %v times, want %v", key.bin, key.pc, n, want)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package trace2syz converts strace logs into syzkaller programs.
+// It allows to seed corpus with syscall sequences of real applications.
+//
+// The conversion is heuristic: calls are matched to descriptions by name,
+// integer and flags arguments are converted from numbers and symbolic constants,
+// strings are converted to buffers, file descriptors returned by previous calls
+// are converted to resource references. Everything else gets default values.
+// The log is expected to be produced with strace -f (pid prefixes are optional);
+// calls of every process are converted into separate programs.
+package trace2syz
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/syzkaller/prog"
+)
+
+// MaxCalls is the maximum number of calls in a single program,
+// longer traces are split into several programs.
+const MaxCalls = 30
+
+// Parse converts strace log into programs.
+// Calls that do not have descriptions or can't be converted are skipped.
+func Parse(target *prog.Target, data []byte) ([]*prog.Prog, error) {
+	ctx := &context{
+		target: target,
+		consts: make(map[string]uint64),
+	}
+	for _, c := range target.Consts {
+		ctx.consts[c.Name] = c.Value
+	}
+	var pids []int
+	procs := make(map[int][]*call)
+	unfinished := make(map[int]string)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		pid, ln := splitPid(s.Text())
+		if strings.HasPrefix(ln, "<... ") {
+			// [pid 1] <... read resumed> "abc", 3) = 3
+			pos := strings.Index(ln, "resumed>")
+			if pos == -1 || unfinished[pid] == "" {
+				continue
+			}
+			ln = unfinished[pid] + strings.TrimSpace(ln[pos+len("resumed>"):])
+			delete(unfinished, pid)
+		} else if pos := strings.Index(ln, "<unfinished ...>"); pos != -1 {
+			unfinished[pid] = strings.TrimSpace(ln[:pos])
+			continue
+		}
+		c := parseCall(ln)
+		if c == nil {
+			continue
+		}
+		if procs[pid] == nil {
+			pids = append(pids, pid)
+		}
+		procs[pid] = append(procs[pid], c)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	var progs []*prog.Prog
+	for _, pid := range pids {
+		calls := procs[pid]
+		for len(calls) != 0 {
+			ctx.reset()
+			var lines []string
+			for len(calls) != 0 && len(lines) < MaxCalls {
+				if ln := ctx.convertCall(calls[0]); ln != "" {
+					lines = append(lines, ln)
+				}
+				calls = calls[1:]
+			}
+			if len(lines) == 0 {
+				continue
+			}
+			p := deserialize(target, lines)
+			if p == nil {
+				continue
+			}
+			if ctx.pages != 0 {
+				p.Calls = append([]*prog.Call{target.MakeMmap(0, ctx.pages)}, p.Calls...)
+			}
+			progs = append(progs, p)
+		}
+	}
+	return progs, nil
+}
+
+// deserialize parses converted calls. If some calls are rejected by the parser
+// (e.g. because of an argument that the heuristics got wrong), they are dropped.
+func deserialize(target *prog.Target, lines []string) *prog.Prog {
+	if p, _, err := target.DeserializeFix([]byte(strings.Join(lines, "\n"))); err == nil {
+		return p
+	}
+	var good []string
+	for _, ln := range lines {
+		if _, _, err := target.DeserializeFix([]byte(strings.Join(append(good, ln), "\n"))); err == nil {
+			good = append(good, ln)
+		}
+	}
+	if len(good) == 0 {
+		return nil
+	}
+	p, _, _ := target.DeserializeFix([]byte(strings.Join(good, "\n")))
+	return p
+}
+
+type call struct {
+	name string
+	args []string
+	ret  string
+}
+
+var (
+	pidRe  = regexp.MustCompile(`^(?:\[pid\s+([0-9]+)\]|([0-9]+))\s+`)
+	callRe = regexp.MustCompile(`^([a-z0-9_]+)\(`)
+)
+
+func splitPid(ln string) (int, string) {
+	match := pidRe.FindStringSubmatch(ln)
+	if match == nil {
+		return 0, strings.TrimSpace(ln)
+	}
+	id := match[1] + match[2]
+	pid, _ := strconv.Atoi(id)
+	return pid, strings.TrimSpace(ln[len(match[0]):])
+}
+
+// parseCall parses a single strace line like:
+// open("/dev/null", O_RDWR|O_CLOEXEC) = 3
+func parseCall(ln string) *call {
+	match := callRe.FindStringSubmatch(ln)
+	if match == nil {
+		return nil
+	}
+	c := &call{name: match[1]}
+	rest := ln[len(match[0]):]
+	args, n, ok := splitArgs(rest, ')')
+	if !ok {
+		return nil
+	}
+	c.args = args
+	rest = strings.TrimSpace(rest[n:])
+	if !strings.HasPrefix(rest, "=") {
+		return nil
+	}
+	if ret := strings.Fields(rest[1:]); len(ret) != 0 {
+		c.ret = ret[0]
+	}
+	return c
+}
+
+// splitArgs splits s into top-level comma-separated arguments up to the closing
+// delimiter end, and returns the arguments and the position after the delimiter.
+func splitArgs(s string, end byte) ([]string, int, bool) {
+	var args []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth == 0 {
+				if s[i] != end {
+					return nil, 0, false
+				}
+				if arg := strings.TrimSpace(s[start:i]); arg != "" || len(args) != 0 {
+					args = append(args, arg)
+				}
+				return args, i + 1, true
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+type context struct {
+	target *prog.Target
+	consts map[string]uint64
+	vars   map[uint64]string // resource value -> program variable
+	nvar   int
+	pages  uint64 // number of data pages used by the current program
+}
+
+func (ctx *context) reset() {
+	ctx.vars = make(map[uint64]string)
+	ctx.nvar = 0
+	ctx.pages = 0
+}
+
+// convertCall returns textual representation of the syz call, or "" if c can't be converted.
+func (ctx *context) convertCall(c *call) string {
+	meta := ctx.target.SyscallMap[c.name]
+	if meta == nil || len(c.args) > len(meta.Args) {
+		return ""
+	}
+	for _, typ := range meta.Args {
+		// Traced processes manage their address space themselves,
+		// replaying their mappings would only break the program.
+		if _, ok := typ.(*prog.VmaType); ok {
+			return ""
+		}
+	}
+	buf := new(bytes.Buffer)
+	if _, ok := meta.Ret.(*prog.ResourceType); ok {
+		if val, ok := ctx.parseInt(c.ret); ok && int64(val) >= 0 {
+			v := fmt.Sprintf("r%v", ctx.nvar)
+			ctx.nvar++
+			// Args are converted before the variable is defined.
+			defer func() { ctx.vars[val] = v }()
+			fmt.Fprintf(buf, "%v = ", v)
+		}
+	}
+	fmt.Fprintf(buf, "%v(", c.name)
+	for i, typ := range meta.Args {
+		if i != 0 {
+			buf.WriteString(", ")
+		}
+		arg := ""
+		if i < len(c.args) {
+			arg = c.args[i]
+		}
+		buf.WriteString(ctx.convertArg(typ, arg))
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+func (ctx *context) convertArg(typ prog.Type, arg string) string {
+	switch t := typ.(type) {
+	case *prog.ResourceType:
+		if val, ok := ctx.parseInt(arg); ok {
+			if v := ctx.vars[val]; v != "" {
+				return v
+			}
+			return fmt.Sprintf("0x%x", val)
+		}
+	case *prog.ConstType:
+		return fmt.Sprintf("0x%x", t.Val)
+	case *prog.IntType, *prog.FlagsType, *prog.LenType:
+		if val, ok := ctx.parseInt(arg); ok {
+			return fmt.Sprintf("0x%x", val)
+		}
+	case *prog.PtrType:
+		if arg == "NULL" && t.Optional() {
+			return "0x0"
+		}
+		if buf, ok := t.Type.(*prog.BufferType); ok && strings.HasPrefix(arg, "\"") {
+			if data, ok := ctx.convertString(buf, arg); ok {
+				return fmt.Sprintf("&%v=\"%v\"", ctx.alloc(), hex.EncodeToString(data))
+			}
+		}
+	}
+	return ctx.defaultArg(typ)
+}
+
+// convertString converts strace string literal (e.g. "abc\n\0"...) into buffer data.
+func (ctx *context) convertString(t *prog.BufferType, arg string) ([]byte, bool) {
+	truncated := strings.HasSuffix(arg, "...")
+	arg = strings.TrimSuffix(arg, "...")
+	if len(arg) < 2 || arg[len(arg)-1] != '"' {
+		return nil, false
+	}
+	var data []byte
+	for i := 1; i < len(arg)-1; i++ {
+		if arg[i] != '\\' || i+1 >= len(arg)-1 {
+			data = append(data, arg[i])
+			continue
+		}
+		i++
+		switch arg[i] {
+		case 'n':
+			data = append(data, '\n')
+		case 't':
+			data = append(data, '\t')
+		case 'r':
+			data = append(data, '\r')
+		case 'v':
+			data = append(data, '\v')
+		case 'f':
+			data = append(data, '\f')
+		case 'x':
+			if i+2 < len(arg)-1 {
+				if v, err := strconv.ParseUint(arg[i+1:i+3], 16, 8); err == nil {
+					data = append(data, byte(v))
+					i += 2
+					continue
+				}
+			}
+			data = append(data, 'x')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i
+			for j < i+3 && j < len(arg)-1 && arg[j] >= '0' && arg[j] <= '7' {
+				j++
+			}
+			v, _ := strconv.ParseUint(arg[i:j], 8, 8)
+			data = append(data, byte(v))
+			i = j - 1
+		default:
+			data = append(data, arg[i])
+		}
+	}
+	switch t.Kind {
+	case prog.BufferString, prog.BufferFilename:
+		if !truncated && (len(data) == 0 || data[len(data)-1] != 0) {
+			data = append(data, 0)
+		}
+		if !t.Varlen() && uint64(len(data)) != t.Size() {
+			return nil, false
+		}
+	}
+	if max := ctx.target.PageSize; uint64(len(data)) > max {
+		data = data[:max]
+	}
+	if t.Dir() == prog.DirOut {
+		// Only the size of output buffers matters.
+		data = make([]byte, len(data))
+	}
+	return data, true
+}
+
+// parseInt parses numbers and symbolic flags like "O_RDWR|O_CREAT|0x80000".
+func (ctx *context) parseInt(arg string) (uint64, bool) {
+	if arg == "" {
+		return 0, false
+	}
+	// strace -y prints fds as 3</dev/null>.
+	if pos := strings.IndexByte(arg, '<'); pos > 0 {
+		arg = arg[:pos]
+	}
+	var res uint64
+	for _, part := range strings.Split(arg, "|") {
+		part = strings.TrimSpace(part)
+		if v, err := strconv.ParseInt(part, 0, 64); err == nil {
+			res |= uint64(v)
+		} else if v, err := strconv.ParseUint(part, 0, 64); err == nil {
+			res |= v
+		} else if v, ok := ctx.consts[part]; ok {
+			res |= v
+		} else {
+			return 0, false
+		}
+	}
+	return res, true
+}
+
+// alloc returns address of a fresh data page.
+func (ctx *context) alloc() string {
+	addr := fmt.Sprintf("(0x%x)", dataAddr+ctx.pages*ctx.target.PageSize)
+	ctx.pages++
+	return addr
+}
+
+// dataAddr is the base address of program data in the serialized form.
+const dataAddr = 0x7f0000000000
+
+// defaultArg returns textual representation of the default value of typ.
+func (ctx *context) defaultArg(typ prog.Type) string {
+	switch t := typ.(type) {
+	case *prog.ResourceType:
+		return fmt.Sprintf("0x%x", t.Default())
+	case *prog.ConstType:
+		return fmt.Sprintf("0x%x", t.Val)
+	case *prog.BufferType:
+		if t.Kind == prog.BufferString && !t.Varlen() {
+			return fmt.Sprintf("\"%v\"", hex.EncodeToString(make([]byte, t.Size())))
+		}
+		return "\"\""
+	case *prog.ArrayType:
+		return "[]"
+	case *prog.StructType:
+		return "{}"
+	case *prog.UnionType:
+		return fmt.Sprintf("@%v=%v", t.Fields[0].FieldName(), ctx.defaultArg(t.Fields[0]))
+	case *prog.PtrType:
+		if t.Optional() {
+			return "0x0"
+		}
+		return fmt.Sprintf("&%v=%v", ctx.alloc(), ctx.defaultArg(t.Type))
+	default:
+		return fmt.Sprintf("0x%x", typ.Default())
+	}
+}
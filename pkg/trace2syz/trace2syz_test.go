@@ -0,0 +1,78 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package trace2syz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+)
+
+func TestParse(t *testing.T) {
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := `
+execve("/bin/cat", ["cat", "/etc/passwd"], 0x7ffd3c9c4a68 /* 20 vars */) = 0
+mmap(NULL, 8192, PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_ANONYMOUS, -1, 0) = 0x7f2c5e2a1000
+open("/etc/passwd", O_RDONLY|O_CLOEXEC) = 3
+[pid  1235] dup(3 <unfinished ...>
+read(3, "root:x:0:0:root:/root:/bin/bash\n"..., 131072) = 1024
+[pid  1235] <... dup resumed> ) = 4
+write(1, "root:x:0:0\n", 11) = 11
+open("/nonexistent", O_RDONLY) = -1 ENOENT (No such file or directory)
+unknown_syscall(1, 2) = 0
+close(3) = 0
+--- SIGCHLD {si_signo=SIGCHLD, si_code=CLD_EXITED} ---
++++ exited with 0 +++
+`
+	progs, err := Parse(target, []byte(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progs) != 2 {
+		t.Fatalf("got %v programs, want 2", len(progs))
+	}
+	var calls []string
+	for _, c := range progs[0].Calls {
+		calls = append(calls, c.Meta.Name)
+	}
+	// mmap is added to map the data, the traced mmap is skipped.
+	want := "mmap execve open read write open close"
+	if got := strings.Join(calls, " "); got != want {
+		t.Fatalf("got calls %q, want %q\n%s", got, want, progs[0].Serialize())
+	}
+	text := string(progs[0].Serialize())
+	for _, want := range []string{
+		"r0 = open(&(0x7f0000003000)=\"2f6574632f70617373776400\", 0x80000, 0x0)",
+		"read(r0, ",
+		"close(r0)",
+		"write(0x1, &(0x7f0000005000)=\"726f6f743a783a303a300a\", 0xb)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("program does not contain %q:\n%s", want, text)
+		}
+	}
+	if got := progs[1].Calls[len(progs[1].Calls)-1].Meta.Name; got != "dup" {
+		t.Errorf("second program ends with %v, want dup:\n%s", got, progs[1].Serialize())
+	}
+}
+
+func TestSplit(t *testing.T) {
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := strings.Repeat("getpid() = 1\n", MaxCalls*2+1)
+	progs, err := Parse(target, []byte(trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(progs) != 3 {
+		t.Fatalf("got %v programs, want 3", len(progs))
+	}
+}
@@ -167,14 +167,19 @@ func RequiresBitmasks(p *Prog) bool {
 	return result
 }
 
-func RequiresChecksums(p *Prog) bool {
-	result := false
+// RequiresChecksums returns whether the program needs inet (including pseudo header ones)
+// and crc32c checksum calculation.
+func RequiresChecksums(p *Prog) (inet, crc32c bool) {
 	for _, c := range p.Calls {
 		foreachArg(c, func(arg, _ Arg, _ *[]Arg) {
-			if _, ok := arg.Type().(*CsumType); ok {
-				result = true
+			if typ, ok := arg.Type().(*CsumType); ok {
+				if typ.Kind == CsumCrc32c {
+					crc32c = true
+				} else {
+					inet = true
+				}
 			}
 		})
 	}
-	return result
+	return
 }
@@ -0,0 +1,166 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"sort"
+)
+
+type DepKind int
+
+const (
+	// The call uses a resource produced by the dependency.
+	DepResource DepKind = iota
+	// The call passes pointers into memory mapped (or unmapped) by the dependency.
+	DepMemory
+)
+
+type CallDep struct {
+	Call int // index of the call that the call depends on
+	Kind DepKind
+}
+
+// CallGraph describes how calls of a program are connected with each other.
+// Calls that are not connected by resources, directly or transitively, form independent
+// clusters, and a cluster can be removed from the program without affecting the rest of it,
+// as long as memory dependencies of the remaining calls are preserved.
+type CallGraph struct {
+	// Deps[i] are dependencies of call i on preceding calls sorted by call index.
+	// A call depends on a preceding call at most once, resource dependencies take precedence.
+	Deps [][]CallDep
+}
+
+// CallGraph builds the dependency graph of the program.
+func (p *Prog) CallGraph() *CallGraph {
+	g := &CallGraph{Deps: make([][]CallDep, len(p.Calls))}
+	producers := make(map[Arg]int)
+	// Index of the last call that mapped/unmapped the page, or -1.
+	var mappers [maxPages]int
+	for i := range mappers {
+		mappers[i] = -1
+	}
+	for ci, c := range p.Calls {
+		deps := make(map[int]DepKind)
+		foreachArg(c, func(arg, _ Arg, _ *[]Arg) {
+			switch a := arg.(type) {
+			case *ResultArg:
+				if a.Res == nil {
+					return
+				}
+				if dep, ok := producers[a.Res]; ok && dep != ci {
+					deps[dep] = DepResource
+				}
+			case *PointerArg:
+				lo, hi := p.Target.pointerPages(a)
+				for page := lo; page < hi; page++ {
+					dep := mappers[page]
+					if _, ok := deps[dep]; dep != -1 && !ok {
+						deps[dep] = DepMemory
+					}
+				}
+			}
+		})
+		for dep, kind := range deps {
+			g.Deps[ci] = append(g.Deps[ci], CallDep{dep, kind})
+		}
+		sort.Slice(g.Deps[ci], func(i, j int) bool {
+			return g.Deps[ci][i].Call < g.Deps[ci][j].Call
+		})
+		foreachArgArray(&c.Args, c.Ret, func(arg, _ Arg, _ *[]Arg) {
+			if _, ok := arg.(ArgUsed); ok {
+				producers[arg] = ci
+			}
+		})
+		if start, npages, _ := p.Target.AnalyzeMmap(c); npages != 0 {
+			for page := start; page < start+npages && page < maxPages; page++ {
+				mappers[page] = ci
+			}
+		}
+	}
+	return g
+}
+
+// pointerPages returns the range of pages [lo, hi) that the pointer refers to.
+func (target *Target) pointerPages(a *PointerArg) (uint64, uint64) {
+	var lo, hi uint64
+	if a.PagesNum != 0 {
+		lo, hi = a.PageIndex, a.PageIndex+a.PagesNum
+	} else if a.Res != nil {
+		addr := int64(a.PageIndex*target.PageSize) + int64(a.PageOffset)
+		if addr < 0 {
+			addr = 0
+		}
+		size := int64(a.Res.Size())
+		if size == 0 {
+			size = 1
+		}
+		lo = uint64(addr) / target.PageSize
+		hi = uint64(addr+size-1)/target.PageSize + 1
+	}
+	if hi > maxPages {
+		hi = maxPages
+	}
+	return lo, hi
+}
+
+// Clusters returns groups of calls connected with resource dependencies
+// (memory dependencies don't join clusters, most calls depend on the same mmap).
+// Each cluster is sorted, clusters are sorted by the first call.
+func (g *CallGraph) Clusters() [][]int {
+	parent := make([]int, len(g.Deps))
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range parent {
+		parent[i] = i
+	}
+	for i, deps := range g.Deps {
+		for _, dep := range deps {
+			if dep.Kind == DepResource {
+				parent[find(i)] = find(dep.Call)
+			}
+		}
+	}
+	// Calls are visited in order, so clusters are created in the order of their first calls.
+	idx := make(map[int]int)
+	var clusters [][]int
+	for i := range g.Deps {
+		root := find(i)
+		ci, ok := idx[root]
+		if !ok {
+			ci = len(clusters)
+			idx[root] = ci
+			clusters = append(clusters, nil)
+		}
+		clusters[ci] = append(clusters[ci], i)
+	}
+	return clusters
+}
+
+// Closure returns sorted indices of calls that call i depends on directly or transitively
+// (including call i itself).
+func (g *CallGraph) Closure(i int) []int {
+	seen := make(map[int]bool)
+	var rec func(i int)
+	rec = func(i int) {
+		if seen[i] {
+			return
+		}
+		seen[i] = true
+		for _, dep := range g.Deps[i] {
+			rec(dep.Call)
+		}
+	}
+	rec(i)
+	var res []int
+	for i := range seen {
+		res = append(res, i)
+	}
+	sort.Ints(res)
+	return res
+}
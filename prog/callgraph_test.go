@@ -0,0 +1,112 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCallGraph(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte(
+		"mmap(&(0x7f0000000000/0x2000)=nil, 0x2000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+			"r0 = open(&(0x7f0000000000)=\"2e2f66696c653000\", 0x0, 0x0)\n" +
+			"pipe2(&(0x7f0000001000)={<r1=>0xffffffffffffffff, <r2=>0xffffffffffffffff}, 0x0)\n" +
+			"sched_yield()\n" +
+			"write(r2, &(0x7f0000001000)=\"01\", 0x1)\n" +
+			"dup2(r0, r1)\n" +
+			"close(r0)\n" +
+			"mmap(&(0x7f0000003000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+			"pipe(&(0x7f0000003000)={<r3=>0xffffffffffffffff, <r4=>0xffffffffffffffff})\n" +
+			"close(r4)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := p.CallGraph()
+	wantDeps := [][]CallDep{
+		nil,
+		{{0, DepMemory}},
+		{{0, DepMemory}},
+		nil,
+		{{0, DepMemory}, {2, DepResource}},
+		{{1, DepResource}, {2, DepResource}},
+		{{1, DepResource}},
+		nil,
+		{{7, DepMemory}},
+		{{8, DepResource}},
+	}
+	if !reflect.DeepEqual(g.Deps, wantDeps) {
+		t.Errorf("bad deps:\ngot:  %+v\nwant: %+v", g.Deps, wantDeps)
+	}
+	wantClusters := [][]int{{0}, {1, 2, 4, 5, 6}, {3}, {7}, {8, 9}}
+	if clusters := g.Clusters(); !reflect.DeepEqual(clusters, wantClusters) {
+		t.Errorf("bad clusters:\ngot:  %v\nwant: %v", clusters, wantClusters)
+	}
+	wantClosure := []int{0, 2, 4}
+	if closure := g.Closure(4); !reflect.DeepEqual(closure, wantClosure) {
+		t.Errorf("bad closure:\ngot:  %v\nwant: %v", closure, wantClosure)
+	}
+}
+
+func TestMinimizeClusters(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte(
+		"mmap(&(0x7f0000000000/0x2000)=nil, 0x2000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+			"r0 = open(&(0x7f0000000000)=\"2e2f66696c653000\", 0x0, 0x0)\n" +
+			"dup(r0)\n" +
+			"close(r0)\n" +
+			"pipe2(&(0x7f0000001000)={<r1=>0xffffffffffffffff, <r2=>0xffffffffffffffff}, 0x0)\n" +
+			"write(r2, &(0x7f0000001000)=\"01\", 0x1)\n" +
+			"read(r1, &(0x7f0000001000)=\"\", 0x1)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs []string
+	p1, ci := Minimize(p, 6, func(p1 *Prog, callIndex int) bool {
+		data := string(p1.Serialize())
+		runs = append(runs, data)
+		return p1.Calls[callIndex].Meta.Name == "read" && strings.Contains(data, "mmap(") &&
+			strings.Contains(data, "pipe2(") && strings.Contains(data, "write(r")
+	}, true)
+	// The first run tries to glue mmaps, the second one must remove the open cluster at once.
+	want := "mmap(&(0x7f0000000000/0x2000)=nil, 0x2000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+		"pipe2(&(0x7f0000001000)={<r0=>0xffffffffffffffff, <r1=>0xffffffffffffffff}, 0x0)\n" +
+		"write(r1, &(0x7f0000001000)=\"01\", 0x1)\n" +
+		"read(r0, &(0x7f0000001000)=\"\", 0x1)\n"
+	if len(runs) < 2 || runs[1] != want {
+		t.Fatalf("runs:\n%q\nwant second run:\n%v", runs, want)
+	}
+	if len(p1.Calls) != 4 || ci != 3 {
+		t.Errorf("minimized to call %v:\n%s", ci, p1.Serialize())
+	}
+}
+
+func TestCallGraphRandom(t *testing.T) {
+	target, rs, iters := initTest(t)
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, nil)
+		g := p.CallGraph()
+		seen := make(map[int]bool)
+		for _, cluster := range g.Clusters() {
+			for _, c := range cluster {
+				if seen[c] {
+					t.Fatalf("call %v is in several clusters", c)
+				}
+				seen[c] = true
+			}
+		}
+		if len(seen) != len(p.Calls) {
+			t.Fatalf("clusters contain %v calls, program has %v", len(seen), len(p.Calls))
+		}
+		for c, deps := range g.Deps {
+			for _, dep := range deps {
+				if dep.Call >= c {
+					t.Fatalf("call %v depends on call %v:\n%s", c, dep.Call, p.Serialize())
+				}
+			}
+		}
+	}
+}
@@ -96,15 +96,15 @@ func findCsummedArg(arg Arg, typ *CsumType, parentsMap map[Arg]Arg) Arg {
 }
 
 func calcChecksumsCall(c *Call, pid int) map[Arg]CsumInfo {
-	var inetCsumFields []Arg
+	var plainCsumFields []Arg
 	var pseudoCsumFields []Arg
 
 	// Find all csum fields.
 	foreachArgArray(&c.Args, nil, func(arg, base Arg, _ *[]Arg) {
 		if typ, ok := arg.Type().(*CsumType); ok {
 			switch typ.Kind {
-			case CsumInet:
-				inetCsumFields = append(inetCsumFields, arg)
+			case CsumInet, CsumCrc32c:
+				plainCsumFields = append(plainCsumFields, arg)
 			case CsumPseudo:
 				pseudoCsumFields = append(pseudoCsumFields, arg)
 			default:
@@ -114,7 +114,7 @@ func calcChecksumsCall(c *Call, pid int) map[Arg]CsumInfo {
 	})
 
 	// Return if no csum fields found.
-	if len(inetCsumFields) == 0 && len(pseudoCsumFields) == 0 {
+	if len(plainCsumFields) == 0 && len(pseudoCsumFields) == 0 {
 		return nil
 	}
 
@@ -130,12 +130,12 @@ func calcChecksumsCall(c *Call, pid int) map[Arg]CsumInfo {
 
 	csumMap := make(map[Arg]CsumInfo)
 
-	// Calculate generic inet checksums.
-	for _, arg := range inetCsumFields {
+	// Calculate generic inet and crc32c checksums (both cover just the target buffer).
+	for _, arg := range plainCsumFields {
 		typ, _ := arg.Type().(*CsumType)
 		csummedArg := findCsummedArg(arg, typ, parentsMap)
 		chunk := CsumChunk{CsumChunkArg, csummedArg, 0, 0}
-		info := CsumInfo{Kind: CsumInet, Chunks: make([]CsumChunk, 0)}
+		info := CsumInfo{Kind: typ.Kind, Chunks: make([]CsumChunk, 0)}
 		info.Chunks = append(info.Chunks, chunk)
 		csumMap[arg] = info
 	}
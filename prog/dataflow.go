@@ -0,0 +1,93 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"fmt"
+)
+
+// FixResources fixes broken resource dataflow in the program: arguments that reference resources
+// of an incompatible kind (e.g. a pid passed as fd, which can happen in hand-edited programs)
+// are rewired to the closest preceding compatible resource, or replaced with the default
+// resource value if there is none. It returns descriptions of the done fixes.
+func (p *Prog) FixResources() []string {
+	fixes := p.resourceProblems(nil, true)
+	if debug {
+		if err := p.validate(); err != nil {
+			panic(err)
+		}
+	}
+	return fixes
+}
+
+// resourceProblems returns descriptions of broken resource references in the program.
+// Arguments in dangling reference unknown variables. If fix is set, the problems are fixed.
+func (p *Prog) resourceProblems(dangling map[Arg]bool, fix bool) []string {
+	var problems []string
+	var produced []Arg // resources produced by preceding calls
+	for ci, c := range p.Calls {
+		foreachArg(c, func(arg, _ Arg, _ *[]Arg) {
+			a, ok := arg.(*ResultArg)
+			if !ok || a.Type().Dir() == DirOut {
+				return
+			}
+			typ, ok := a.Type().(*ResourceType)
+			if !ok {
+				return
+			}
+			var what string
+			switch {
+			case dangling[a]:
+				what = "references unknown variable"
+			case a.Res != nil && !p.Target.isCompatibleResourceArg(typ, a.Res):
+				what = fmt.Sprintf("references incompatible resource %v", a.Res.Type().Name())
+			default:
+				return
+			}
+			problem := fmt.Sprintf("call #%v %v: %v argument %v", ci, c.Meta.Name, typ.Name(), what)
+			if !fix {
+				problems = append(problems, problem)
+				return
+			}
+			if a.Res != nil {
+				delete(*a.Res.(ArgUsed).Used(), a)
+				a.Res = nil
+			}
+			a.OpDiv, a.OpAdd = 0, 0
+			for i := len(produced) - 1; i >= 0; i-- {
+				if p.Target.isCompatibleResourceArg(typ, produced[i]) {
+					a.Res = produced[i]
+					a.Val = 0
+					used := produced[i].(ArgUsed).Used()
+					if *used == nil {
+						*used = make(map[Arg]bool)
+					}
+					(*used)[a] = true
+					break
+				}
+			}
+			if a.Res != nil {
+				problem += ", rewired to a preceding " + a.Res.Type().Name()
+			} else {
+				a.Val = typ.Default()
+				problem += ", replaced with default value"
+			}
+			problems = append(problems, problem)
+		})
+		foreachArgArray(&c.Args, c.Ret, func(arg, _ Arg, _ *[]Arg) {
+			if _, ok := arg.Type().(*ResourceType); ok && arg.Type().Dir() != DirIn {
+				if _, ok := arg.(ArgUsed); ok {
+					produced = append(produced, arg)
+				}
+			}
+		})
+	}
+	return problems
+}
+
+// isCompatibleResourceArg returns true if res produces a resource that can be passed as typ.
+func (target *Target) isCompatibleResourceArg(typ *ResourceType, res Arg) bool {
+	resTyp, ok := res.Type().(*ResourceType)
+	return ok && target.isCompatibleResource(typ.Desc.Name, resTyp.Desc.Name)
+}
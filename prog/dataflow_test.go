@@ -0,0 +1,91 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeserializeFix(t *testing.T) {
+	target, _, _ := initTest(t)
+	tests := []struct {
+		in     string
+		out    string
+		fixes  int
+		strict bool // DeserializeStrict accepts the input
+	}{
+		{
+			in: "r0 = open(&(0x7f0000000000)=\"2e2f66696c653000\", 0x0, 0x0)\n" +
+				"close(r0)\n",
+			out: "r0 = open(&(0x7f0000000000)=\"2e2f66696c653000\", 0x0, 0x0)\n" +
+				"close(r0)\n",
+			fixes:  0,
+			strict: true,
+		},
+		{
+			// Reference to result of a removed call.
+			in:    "close(r3)\n",
+			out:   "close(0xffffffffffffffff)\n",
+			fixes: 1,
+		},
+		{
+			in: "r0 = open(&(0x7f0000000000)=\"2e2f66696c653000\", 0x0, 0x0)\n" +
+				"close(r1)\n",
+			out: "r0 = open(&(0x7f0000000000)=\"2e2f66696c653000\", 0x0, 0x0)\n" +
+				"close(r0)\n",
+			fixes: 1,
+		},
+		{
+			// pid passed as fd.
+			in: "r0 = open(&(0x7f0000000000)=\"2e2f66696c653000\", 0x0, 0x0)\n" +
+				"r1 = getpid()\n" +
+				"close(r1)\n",
+			out: "r0 = open(&(0x7f0000000000)=\"2e2f66696c653000\", 0x0, 0x0)\n" +
+				"getpid()\n" +
+				"close(r0)\n",
+			fixes: 1,
+		},
+		{
+			in:    "r0 = getpid()\nclose(r0)\n",
+			out:   "getpid()\nclose(0xffffffffffffffff)\n",
+			fixes: 1,
+		},
+	}
+	for i, test := range tests {
+		p, fixes, err := target.DeserializeFix([]byte(test.in))
+		if err != nil {
+			t.Fatalf("#%v: DeserializeFix failed: %v", i, err)
+		}
+		if len(fixes) != test.fixes {
+			t.Errorf("#%v: got %v fixes, want %v: %q", i, len(fixes), test.fixes, fixes)
+		}
+		if out := string(p.Serialize()); out != test.out {
+			t.Errorf("#%v: got:\n%v\nwant:\n%v", i, out, test.out)
+		}
+		if _, err := target.DeserializeStrict([]byte(test.in)); (err == nil) != test.strict {
+			t.Errorf("#%v: DeserializeStrict returned %v, want ok=%v", i, err, test.strict)
+		}
+		if fixes := p.FixResources(); len(fixes) != 0 {
+			t.Errorf("#%v: fixed program has problems: %q", i, fixes)
+		}
+	}
+}
+
+func TestDeserializeNonResourceVar(t *testing.T) {
+	target, _, _ := initTest(t)
+	data := "mmap(&(0x7f0000000000/0x1000)=nil, <r0=>0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+		"close(r0)\n"
+	_, err := target.Deserialize([]byte(data))
+	if err == nil || !strings.Contains(err.Error(), "not a resource") {
+		t.Fatalf("got error %v, want 'not a resource'", err)
+	}
+	p, fixes, err := target.DeserializeFix([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixes) != 1 || !strings.HasSuffix(string(p.Serialize()), "close(0xffffffffffffffff)\n") {
+		t.Fatalf("bad fix %q:\n%s", fixes, p.Serialize())
+	}
+}
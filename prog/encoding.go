@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 )
 
 // String generates a very compact program description (mostly for debug output).
@@ -121,11 +122,52 @@ func serialize(arg Arg, buf io.Writer, vars map[Arg]int, varSeq *int) {
 	}
 }
 
+// Deserialize parses a program in the textual form.
 func (target *Target) Deserialize(data []byte) (prog *Prog, err error) {
+	return target.deserialize(data, &parser{})
+}
+
+// DeserializeStrict is like Deserialize, but additionally rejects programs with broken resource
+// dataflow (e.g. references to resources of an incompatible kind), which are accepted by Deserialize
+// and silently execute with garbage resource values.
+func (target *Target) DeserializeStrict(data []byte) (*Prog, error) {
+	prog, err := target.deserialize(data, &parser{})
+	if err != nil {
+		return nil, err
+	}
+	if problems := prog.resourceProblems(nil, false); len(problems) != 0 {
+		return nil, fmt.Errorf("broken resource dataflow: %v", strings.Join(problems, "; "))
+	}
+	return prog, nil
+}
+
+// DeserializeFix is like Deserialize, but fixes broken resource dataflow in user-edited programs
+// instead of failing: references to unknown variables (e.g. results of removed calls)
+// and to resources of an incompatible kind are rewired to a preceding compatible resource
+// or replaced with the default resource value. Calls that were renamed in descriptions
+// (see Target.SyscallRenames) are replaced with the current names.
+// It returns descriptions of the done fixes.
+func (target *Target) DeserializeFix(data []byte) (*Prog, []string, error) {
+	p := &parser{
+		fix:      true,
+		dangling: make(map[Arg]bool),
+	}
+	prog, err := target.deserialize(data, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	fixes := append(p.fixes, prog.resourceProblems(p.dangling, true)...)
+	if err := prog.validate(); err != nil {
+		return nil, nil, err
+	}
+	return prog, fixes, nil
+}
+
+func (target *Target) deserialize(data []byte, p *parser) (prog *Prog, err error) {
 	prog = &Prog{
 		Target: target,
 	}
-	p := &parser{r: bufio.NewScanner(bytes.NewReader(data))}
+	p.r = bufio.NewScanner(bytes.NewReader(data))
 	p.r.Buffer(nil, maxLineLen)
 	vars := make(map[string]Arg)
 	for p.Scan() {
@@ -141,8 +183,15 @@ func (target *Target) Deserialize(data []byte) (prog *Prog, err error) {
 
 		}
 		meta := target.SyscallMap[name]
+		if meta == nil && p.fix && target.SyscallRenames[name] != "" {
+			newName := target.SyscallRenames[name]
+			meta = target.SyscallMap[newName]
+			p.fixes = append(p.fixes, fmt.Sprintf("renamed syscall %v to %v", name, newName))
+		}
 		if meta == nil {
-			return nil, fmt.Errorf("unknown syscall %v", name)
+			err := p.errorf("unknown syscall %v", name)
+			err.Suggestions = target.suggestSyscalls(name)
+			return nil, err
 		}
 		c := &Call{
 			Meta: meta,
@@ -152,11 +201,11 @@ func (target *Target) Deserialize(data []byte) (prog *Prog, err error) {
 		p.Parse('(')
 		for i := 0; p.Char() != ')'; i++ {
 			if i >= len(meta.Args) {
-				return nil, fmt.Errorf("wrong call arg count: %v, want %v", i+1, len(meta.Args))
+				return nil, p.errorAt(p.i, "", "wrong call arg count: %v, want %v", i+1, len(meta.Args))
 			}
 			typ := meta.Args[i]
 			if IsPad(typ) {
-				return nil, fmt.Errorf("padding in syscall %v arguments", name)
+				return nil, p.errorAt(p.i, "", "padding in syscall %v arguments", name)
 			}
 			arg, err := target.parseArg(typ, p, vars)
 			if err != nil {
@@ -169,7 +218,7 @@ func (target *Target) Deserialize(data []byte) (prog *Prog, err error) {
 		}
 		p.Parse(')')
 		if !p.EOF() {
-			return nil, fmt.Errorf("tailing data (line #%v)", p.l)
+			return nil, p.errorAt(p.i, p.s[p.i:], "tailing data")
 		}
 		if len(c.Args) < len(meta.Args) {
 			for i := len(c.Args); i < len(meta.Args); i++ {
@@ -177,7 +226,7 @@ func (target *Target) Deserialize(data []byte) (prog *Prog, err error) {
 			}
 		}
 		if len(c.Args) != len(meta.Args) {
-			return nil, fmt.Errorf("wrong call arg count: %v, want %v", len(c.Args), len(meta.Args))
+			return nil, p.errorAt(0, "", "wrong call arg count: %v, want %v", len(c.Args), len(meta.Args))
 		}
 		if r != "" {
 			vars[r] = c.Ret
@@ -209,10 +258,10 @@ func (target *Target) parseArg(typ Type, p *parser, vars map[string]Arg) (Arg, e
 		val := p.Ident()
 		v, err := strconv.ParseUint(val, 0, 64)
 		if err != nil {
-			return nil, fmt.Errorf("wrong arg value '%v': %v", val, err)
+			return nil, p.errorf("wrong arg value '%v': %v", val, err)
 		}
 		switch typ.(type) {
-		case *ConstType, *IntType, *FlagsType, *ProcType, *LenType, *CsumType:
+		case *ConstType, *IntType, *FlagsType, *ProcType, *LenType, *TagType, *CsumType:
 			arg = MakeConstArg(typ, v)
 		case *ResourceType:
 			arg = MakeResultArg(typ, nil, v)
@@ -221,21 +270,33 @@ func (target *Target) parseArg(typ Type, p *parser, vars map[string]Arg) (Arg, e
 		case *VmaType:
 			arg = MakePointerArg(typ, 0, 0, 0, nil)
 		default:
-			return nil, fmt.Errorf("bad const type %+v", typ)
+			return nil, p.errorf("bad const type %+v", typ)
 		}
 	case 'r':
 		id := p.Ident()
 		v, ok := vars[id]
 		if !ok || v == nil {
-			return nil, fmt.Errorf("result %v references unknown variable (vars=%+v)", id, vars)
+			if !p.fix {
+				return nil, p.errorf("result %v references unknown variable (vars=%+v)", id, vars)
+			}
+			v = nil
+		} else if _, ok := v.(ArgUsed); !ok {
+			if !p.fix {
+				return nil, p.errorf("result %v references variable that is not a resource", id)
+			}
+			v = nil
 		}
 		arg = MakeResultArg(typ, v, 0)
+		if v == nil {
+			// Fixed up by resourceProblems after parsing.
+			p.dangling[arg] = true
+		}
 		if p.Char() == '/' {
 			p.Parse('/')
 			op := p.Ident()
 			v, err := strconv.ParseUint(op, 0, 64)
 			if err != nil {
-				return nil, fmt.Errorf("wrong result div op: '%v'", op)
+				return nil, p.errorf("wrong result div op: '%v'", op)
 			}
 			arg.(*ResultArg).OpDiv = v
 		}
@@ -244,7 +305,7 @@ func (target *Target) parseArg(typ Type, p *parser, vars map[string]Arg) (Arg, e
 			op := p.Ident()
 			v, err := strconv.ParseUint(op, 0, 64)
 			if err != nil {
-				return nil, fmt.Errorf("wrong result add op: '%v'", op)
+				return nil, p.errorf("wrong result add op: '%v'", op)
 			}
 			arg.(*ResultArg).OpAdd = v
 		}
@@ -255,7 +316,7 @@ func (target *Target) parseArg(typ Type, p *parser, vars map[string]Arg) (Arg, e
 			typ1 = t1.Type
 		case *VmaType:
 		default:
-			return nil, fmt.Errorf("& arg is not a pointer: %#v", typ)
+			return nil, p.errorAt(p.i, string(p.Char()), "& arg is not a pointer: %#v", typ)
 		}
 		p.Parse('&')
 		page, off, size, err := parseAddr(p, true)
@@ -285,19 +346,19 @@ func (target *Target) parseArg(typ Type, p *parser, vars map[string]Arg) (Arg, e
 		p.Parse('"')
 		data, err := hex.DecodeString(val)
 		if err != nil {
-			return nil, fmt.Errorf("data arg has bad value '%v'", val)
+			return nil, p.errorf("data arg has bad value '%v'", val)
 		}
 		arg = dataArg(typ, data)
 	case '{':
 		t1, ok := typ.(*StructType)
 		if !ok {
-			return nil, fmt.Errorf("'{' arg is not a struct: %#v", typ)
+			return nil, p.errorAt(p.i, string(p.Char()), "'{' arg is not a struct: %#v", typ)
 		}
 		p.Parse('{')
 		var inner []Arg
 		for i := 0; p.Char() != '}'; i++ {
 			if i >= len(t1.Fields) {
-				return nil, fmt.Errorf("wrong struct arg count: %v, want %v", i+1, len(t1.Fields))
+				return nil, p.errorf("wrong struct arg count: %v, want %v", i+1, len(t1.Fields))
 			}
 			fld := t1.Fields[i]
 			if IsPad(fld) {
@@ -321,7 +382,7 @@ func (target *Target) parseArg(typ Type, p *parser, vars map[string]Arg) (Arg, e
 	case '[':
 		t1, ok := typ.(*ArrayType)
 		if !ok {
-			return nil, fmt.Errorf("'[' arg is not an array: %#v", typ)
+			return nil, p.errorAt(p.i, string(p.Char()), "'[' arg is not an array: %#v", typ)
 		}
 		p.Parse('[')
 		var inner []Arg
@@ -340,7 +401,7 @@ func (target *Target) parseArg(typ Type, p *parser, vars map[string]Arg) (Arg, e
 	case '@':
 		t1, ok := typ.(*UnionType)
 		if !ok {
-			return nil, fmt.Errorf("'@' arg is not a union: %#v", typ)
+			return nil, p.errorAt(p.i, string(p.Char()), "'@' arg is not a union: %#v", typ)
 		}
 		p.Parse('@')
 		name := p.Ident()
@@ -353,7 +414,13 @@ func (target *Target) parseArg(typ Type, p *parser, vars map[string]Arg) (Arg, e
 			}
 		}
 		if optType == nil {
-			return nil, fmt.Errorf("union arg %v has unknown option: %v", typ.Name(), name)
+			var names []string
+			for _, t2 := range t1.Fields {
+				names = append(names, t2.FieldName())
+			}
+			err := p.errorf("union arg %v has unknown option: %v", typ.Name(), name)
+			err.Suggestions = suggestNames(name, names)
+			return nil, err
 		}
 		opt, err := target.parseArg(optType, p, vars)
 		if err != nil {
@@ -365,10 +432,10 @@ func (target *Target) parseArg(typ Type, p *parser, vars map[string]Arg) (Arg, e
 		p.Parse('i')
 		p.Parse('l')
 		if r != "" {
-			return nil, fmt.Errorf("named nil argument")
+			return nil, p.errorf("named nil argument")
 		}
 	default:
-		return nil, fmt.Errorf("failed to parse argument at %v (line #%v/%v: %v)", int(p.Char()), p.l, p.i, p.s)
+		return nil, p.errorAt(p.i, string(p.Char()), "failed to parse argument")
 	}
 	if r != "" {
 		vars[r] = arg
@@ -418,14 +485,14 @@ func parseAddr(p *parser, base bool) (uint64, int, uint64, error) {
 	pstr := p.Ident()
 	page, err := strconv.ParseUint(pstr, 0, 64)
 	if err != nil {
-		return 0, 0, 0, fmt.Errorf("failed to parse addr page: '%v'", pstr)
+		return 0, 0, 0, p.errorf("failed to parse addr page: '%v'", pstr)
 	}
 	if page%encodingPageSize != 0 {
-		return 0, 0, 0, fmt.Errorf("address base is not page size aligned: '%v'", pstr)
+		return 0, 0, 0, p.errorf("address base is not page size aligned: '%v'", pstr)
 	}
 	if base {
 		if page < encodingAddrBase {
-			return 0, 0, 0, fmt.Errorf("address without base offset: '%v'", pstr)
+			return 0, 0, 0, p.errorf("address without base offset: '%v'", pstr)
 		}
 		page -= encodingAddrBase
 	}
@@ -441,7 +508,7 @@ func parseAddr(p *parser, base bool) (uint64, int, uint64, error) {
 		ostr := p.Ident()
 		off, err = strconv.ParseInt(ostr, 0, 64)
 		if err != nil {
-			return 0, 0, 0, fmt.Errorf("failed to parse addr offset: '%v'", ostr)
+			return 0, 0, 0, p.errorf("failed to parse addr offset: '%v'", ostr)
 		}
 		if minus {
 			page -= encodingPageSize
@@ -454,7 +521,7 @@ func parseAddr(p *parser, base bool) (uint64, int, uint64, error) {
 		pstr := p.Ident()
 		size, err = strconv.ParseUint(pstr, 0, 64)
 		if err != nil {
-			return 0, 0, 0, fmt.Errorf("failed to parse addr size: '%v'", pstr)
+			return 0, 0, 0, p.errorf("failed to parse addr size: '%v'", pstr)
 		}
 	}
 	p.Parse(')')
@@ -464,11 +531,16 @@ func parseAddr(p *parser, base bool) (uint64, int, uint64, error) {
 }
 
 type parser struct {
-	r *bufio.Scanner
-	s string
-	i int
-	l int
-	e error
+	r   *bufio.Scanner
+	s   string
+	i   int
+	l   int
+	e   error
+	tok int // start of the last parsed identifier
+
+	fix      bool         // don't fail on references to unknown variables and renamed syscalls
+	dangling map[Arg]bool // references to unknown variables in fix mode
+	fixes    []string     // fixes done while parsing in fix mode
 }
 
 func (p *parser) Scan() bool {
@@ -481,6 +553,7 @@ func (p *parser) Scan() bool {
 	}
 	p.s = p.r.Text()
 	p.i = 0
+	p.tok = 0
 	p.l++
 	return true
 }
@@ -532,15 +605,12 @@ func (p *parser) SkipWs() {
 
 func (p *parser) Ident() string {
 	i := p.i
-	for p.i < len(p.s) &&
-		(p.s[p.i] >= 'a' && p.s[p.i] <= 'z' ||
-			p.s[p.i] >= 'A' && p.s[p.i] <= 'Z' ||
-			p.s[p.i] >= '0' && p.s[p.i] <= '9' ||
-			p.s[p.i] == '_' || p.s[p.i] == '$') {
+	p.tok = i
+	for p.i < len(p.s) && isIdentChar(p.s[p.i]) {
 		p.i++
 	}
 	if i == p.i {
-		p.failf("failed to parse identifier at pos %v", i)
+		p.failf("failed to parse identifier")
 		return ""
 	}
 	if ch := p.s[i]; ch >= '0' && ch <= '9' {
@@ -550,8 +620,65 @@ func (p *parser) Ident() string {
 	return s
 }
 
+func isIdentChar(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+		ch == '_' || ch == '$'
+}
+
 func (p *parser) failf(msg string, args ...interface{}) {
-	p.e = fmt.Errorf("%v\nline #%v: %v", fmt.Sprintf(msg, args...), p.l, p.s)
+	token := ""
+	if p.i < len(p.s) {
+		token = p.s[p.i : p.i+1]
+	}
+	p.e = p.errorAt(p.i, token, msg, args...)
+}
+
+// errorf returns an error about the last parsed identifier.
+func (p *parser) errorf(msg string, args ...interface{}) *DeserializeError {
+	end := p.tok
+	for end < len(p.s) && isIdentChar(p.s[end]) {
+		end++
+	}
+	return p.errorAt(p.tok, p.s[p.tok:end], msg, args...)
+}
+
+func (p *parser) errorAt(pos int, token, msg string, args ...interface{}) *DeserializeError {
+	return &DeserializeError{
+		Line:  p.l,
+		Col:   pos + 1,
+		Token: token,
+		Msg:   fmt.Sprintf(msg, args...),
+		Text:  p.s,
+	}
+}
+
+// DeserializeError describes a problem in a textual program and its location.
+type DeserializeError struct {
+	Line        int    // 1-based line number
+	Col         int    // 1-based column of the offending token
+	Token       string // the offending token, can be empty
+	Msg         string
+	Suggestions []string // existing names that were probably meant instead of Token
+	Text        string   // the offending line
+}
+
+func (err *DeserializeError) Error() string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "line %v, col %v: %v", err.Line, err.Col, err.Msg)
+	if len(err.Suggestions) != 0 {
+		fmt.Fprintf(buf, " (did you mean %v?)", strings.Join(err.Suggestions, ", "))
+	}
+	fmt.Fprintf(buf, "\n%v\n", err.Text)
+	// Point to the token, tabs are preserved to keep alignment.
+	for i := 0; i < err.Col-1 && i < len(err.Text); i++ {
+		if err.Text[i] == '\t' {
+			buf.WriteByte('\t')
+		} else {
+			buf.WriteByte(' ')
+		}
+	}
+	buf.WriteByte('^')
+	return buf.String()
 }
 
 // CallSet returns a set of all calls in the program.
@@ -124,3 +124,100 @@ func TestDeserialize(t *testing.T) {
 		}
 	}
 }
+
+func TestDeserializeErrors(t *testing.T) {
+	target, _, _ := initTest(t)
+	tests := []struct {
+		data        string
+		line        int
+		col         int
+		token       string
+		suggestions []string
+	}{
+		{
+			"getpid()\nopne(0x0, 0x0, 0x0)",
+			2, 1, "opne", []string{"open"},
+		},
+		{
+			"r0 = socket$inet6_tpc(0xa, 0x1, 0x0)",
+			1, 6, "socket$inet6_tpc", []string{"socket$inet6_tcp", "socket$inet_tcp", "socket$inet6_dccp"},
+		},
+		{
+			"syz_test$union0(&(0x7f0000000000)={0x0, @f3=0x0})",
+			1, 42, "f3", []string{"f0", "f1", "f2"},
+		},
+		{
+			"getpid(0x0, 0x0)",
+			1, 8, "", nil,
+		},
+		{
+			"getpid() foo",
+			1, 10, "foo", nil,
+		},
+		{
+			"syz_test$struct(&(0x7f0000000000)=0x0)",
+			1, 35, "0x0", nil,
+		},
+	}
+	for i, test := range tests {
+		_, err := target.Deserialize([]byte(test.data))
+		derr, ok := err.(*DeserializeError)
+		if !ok {
+			t.Fatalf("#%v: got error %#v, want *DeserializeError", i, err)
+		}
+		if derr.Line != test.line || derr.Col != test.col || derr.Token != test.token {
+			t.Errorf("#%v: got location %v:%v %q, want %v:%v %q\n%v",
+				i, derr.Line, derr.Col, derr.Token, test.line, test.col, test.token, err)
+		}
+		if !reflect.DeepEqual(derr.Suggestions, test.suggestions) {
+			t.Errorf("#%v: got suggestions %q, want %q", i, derr.Suggestions, test.suggestions)
+		}
+	}
+}
+
+func TestDeserializeRenamed(t *testing.T) {
+	target, _, _ := initTest(t)
+	target.SyscallRenames = map[string]string{"socket$inet_old": "socket$inet"}
+	defer func() {
+		target.SyscallRenames = nil
+	}()
+	data := []byte("r0 = socket$inet_old(0x2, 0x1, 0x0)\nclose(r0)\n")
+	_, err := target.Deserialize(data)
+	derr, ok := err.(*DeserializeError)
+	if !ok {
+		t.Fatalf("got error %#v, want *DeserializeError", err)
+	}
+	if len(derr.Suggestions) == 0 || derr.Suggestions[0] != "socket$inet" {
+		t.Fatalf("renamed syscall is not suggested: %v", err)
+	}
+	p, fixes, err := target.DeserializeFix(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"renamed syscall socket$inet_old to socket$inet"}; !reflect.DeepEqual(fixes, want) {
+		t.Fatalf("got fixes %q, want %q", fixes, want)
+	}
+	if got := p.Calls[0].Meta.Name; got != "socket$inet" {
+		t.Fatalf("got call %v, want socket$inet", got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		dist int
+	}{
+		{"", "", 0},
+		{"open", "open", 0},
+		{"opne", "open", 1},
+		{"open", "openat", 2},
+		{"", "read", 4},
+		{"mmap", "munmap", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, test := range tests {
+		if dist := editDistance(test.a, test.b); dist != test.dist {
+			t.Errorf("editDistance(%q, %q) = %v, want %v", test.a, test.b, dist, test.dist)
+		}
+	}
+}
@@ -3,6 +3,8 @@
 
 // This file does serialization of programs for executor binary.
 // The format aims at simple parsing: binary and irreversible.
+// Serialized programs start with a header (ExecMagic, ExecVersion),
+// the format is decoded by the executor and by pkg/execfmt.
 
 package prog
 
@@ -11,6 +13,13 @@ import (
 	"sort"
 )
 
+const (
+	ExecMagic = uint64(0x63657865637a7973) // "syzcexec"
+	// ExecVersion must be bumped on every change of the format
+	// (kExecVersion in executor/executor.h must be updated accordingly).
+	ExecVersion = uint64(1)
+)
+
 const (
 	ExecInstrEOF = ^uint64(iota)
 	ExecInstrCopyin
@@ -26,6 +35,7 @@ const (
 
 const (
 	ExecArgCsumInet = uint64(iota)
+	ExecArgCsumCrc32c
 )
 
 const (
@@ -60,6 +70,22 @@ func (s ByPhysicalAddr) Less(i, j int) bool {
 // Returns number of bytes written to the buffer.
 // If the provided buffer is too small for the program an error is returned.
 func (p *Prog) SerializeForExec(buffer []byte, pid int) (int, error) {
+	w := p.serializeForExec(buffer, pid)
+	if w.eof {
+		return 0, fmt.Errorf("provided buffer is too small")
+	}
+	return len(buffer) - len(w.buf), nil
+}
+
+// ExecResults returns args that produce results referenced by other calls
+// (return values and copyout values) indexed by the instruction index
+// in the SerializeForExec output. This allows to map result references
+// in the serialized program back to the program args.
+func (p *Prog) ExecResults() map[int]Arg {
+	return p.serializeForExec(make([]byte, ExecBufferSize), 0).results
+}
+
+func (p *Prog) serializeForExec(buffer []byte, pid int) *execContext {
 	if debug {
 		if err := p.validate(); err != nil {
 			panic(fmt.Errorf("serializing invalid program: %v", err))
@@ -67,11 +93,14 @@ func (p *Prog) SerializeForExec(buffer []byte, pid int) (int, error) {
 	}
 	instrSeq := 0
 	w := &execContext{
-		target: p.Target,
-		buf:    buffer,
-		eof:    false,
-		args:   make(map[Arg]argInfo),
+		target:  p.Target,
+		buf:     buffer,
+		eof:     false,
+		args:    make(map[Arg]argInfo),
+		results: make(map[int]Arg),
 	}
+	w.write(ExecMagic)
+	w.write(ExecVersion)
 	for _, c := range p.Calls {
 		// Calculate checksums.
 		csumMap := calcChecksumsCall(c, pid)
@@ -80,11 +109,9 @@ func (p *Prog) SerializeForExec(buffer []byte, pid int) (int, error) {
 			csumUses = make(map[Arg]bool)
 			for arg, info := range csumMap {
 				csumUses[arg] = true
-				if info.Kind == CsumInet {
-					for _, chunk := range info.Chunks {
-						if chunk.Kind == CsumChunkArg {
-							csumUses[chunk.Arg] = true
-						}
+				for _, chunk := range info.Chunks {
+					if chunk.Kind == CsumChunkArg {
+						csumUses[chunk.Arg] = true
 					}
 				}
 			}
@@ -136,24 +163,26 @@ func (p *Prog) SerializeForExec(buffer []byte, pid int) (int, error) {
 				switch csumMap[arg].Kind {
 				case CsumInet:
 					w.write(ExecArgCsumInet)
-					w.write(uint64(len(csumMap[arg].Chunks)))
-					for _, chunk := range csumMap[arg].Chunks {
-						switch chunk.Kind {
-						case CsumChunkArg:
-							w.write(ExecArgCsumChunkData)
-							w.write(w.args[chunk.Arg].Addr)
-							w.write(chunk.Arg.Size())
-						case CsumChunkConst:
-							w.write(ExecArgCsumChunkConst)
-							w.write(chunk.Value)
-							w.write(chunk.Size)
-						default:
-							panic(fmt.Sprintf("csum chunk has unknown kind %v", chunk.Kind))
-						}
-					}
+				case CsumCrc32c:
+					w.write(ExecArgCsumCrc32c)
 				default:
 					panic(fmt.Sprintf("csum arg has unknown kind %v", csumMap[arg].Kind))
 				}
+				w.write(uint64(len(csumMap[arg].Chunks)))
+				for _, chunk := range csumMap[arg].Chunks {
+					switch chunk.Kind {
+					case CsumChunkArg:
+						w.write(ExecArgCsumChunkData)
+						w.write(w.args[chunk.Arg].Addr)
+						w.write(chunk.Arg.Size())
+					case CsumChunkConst:
+						w.write(ExecArgCsumChunkConst)
+						w.write(chunk.Value)
+						w.write(chunk.Size)
+					default:
+						panic(fmt.Sprintf("csum chunk has unknown kind %v", chunk.Kind))
+					}
+				}
 				instrSeq++
 			}
 		}
@@ -165,6 +194,7 @@ func (p *Prog) SerializeForExec(buffer []byte, pid int) (int, error) {
 		}
 		if len(*c.Ret.(ArgUsed).Used()) != 0 {
 			w.args[c.Ret] = argInfo{Idx: instrSeq}
+			w.results[instrSeq] = c.Ret
 		}
 		instrSeq++
 		// Generate copyout instructions that persist interesting return values.
@@ -182,6 +212,7 @@ func (p *Prog) SerializeForExec(buffer []byte, pid int) (int, error) {
 				}
 				info := w.args[arg]
 				info.Idx = instrSeq
+				w.results[instrSeq] = arg
 				instrSeq++
 				w.args[arg] = info
 				w.write(ExecInstrCopyout)
@@ -193,10 +224,7 @@ func (p *Prog) SerializeForExec(buffer []byte, pid int) (int, error) {
 		})
 	}
 	w.write(ExecInstrEOF)
-	if w.eof {
-		return 0, fmt.Errorf("provided buffer is too small")
-	}
-	return len(buffer) - len(w.buf), nil
+	return w
 }
 
 func (target *Target) physicalAddr(arg Arg) uint64 {
@@ -214,10 +242,11 @@ func (target *Target) physicalAddr(arg Arg) uint64 {
 }
 
 type execContext struct {
-	target *Target
-	buf    []byte
-	eof    bool
-	args   map[Arg]argInfo
+	target  *Target
+	buf     []byte
+	eof     bool
+	args    map[Arg]argInfo
+	results map[int]Arg // instruction index -> arg that produces the result
 }
 
 type argInfo struct {
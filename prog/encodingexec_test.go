@@ -24,13 +24,15 @@ func TestSerializeForExecRandom(t *testing.T) {
 func TestSerializeForExec(t *testing.T) {
 	// A brief recap of exec format.
 	// Exec format is an sequence of uint64's which encodes a sequence of calls.
+	// The sequence is prefixed with a header (ExecMagic, ExecVersion).
 	// The sequence is terminated by a speciall call ExecInstrEOF.
 	// Each call is (call ID, number of arguments, arguments...).
 	// Each argument is (type, size, value).
-	// There are 3 types of arguments:
+	// There are 4 types of arguments:
 	//  - ExecArgConst: value is const value
 	//  - ExecArgResult: value is index of a call whose result we want to reference
 	//  - ExecArgData: value is a binary blob (represented as ]size/8[ uint64's)
+	//  - ExecArgCsum: value is (checksum kind, number of chunks, chunks...)
 	// There are 2 other special call:
 	//  - ExecInstrCopyin: copies its second argument into address specified by first argument
 	//  - ExecInstrCopyout: reads value at address specified by first argument (result can be referenced by ExecArgResult)
@@ -41,6 +43,9 @@ func TestSerializeForExec(t *testing.T) {
 		argConst     = uint64(ExecArgConst)
 		argResult    = uint64(ExecArgResult)
 		argData      = uint64(ExecArgData)
+		argCsum      = uint64(ExecArgCsum)
+		argCsumData  = uint64(ExecArgCsumChunkData)
+		csumCrc32c   = uint64(ExecArgCsumCrc32c)
 	)
 	target, _, _ := initTest(t)
 	var (
@@ -252,6 +257,18 @@ func TestSerializeForExec(t *testing.T) {
 				instrEOF,
 			},
 		},
+		{
+			"syz_test$csum_sctp(&(0x7f0000000000)={0x1, 0x2, 0x0, \"aabb\"})",
+			[]uint64{
+				instrCopyin, dataOffset + 0, argConst, 4, 0x01000000, 0, 0,
+				instrCopyin, dataOffset + 4, argConst, 4, 0x02000000, 0, 0,
+				instrCopyin, dataOffset + 8, argConst, 4, 0, 0, 0,
+				instrCopyin, dataOffset + 12, argData, 2, 0xbbaa,
+				instrCopyin, dataOffset + 8, argCsum, 4, csumCrc32c, 1, argCsumData, dataOffset, 14,
+				callID("syz_test$csum_sctp"), 1, argConst, ptrSize, dataOffset, 0, 0,
+				instrEOF,
+			},
+		},
 		{
 			"syz_test$res1(0xffff)",
 			[]uint64{
@@ -273,6 +290,7 @@ func TestSerializeForExec(t *testing.T) {
 				t.Fatalf("failed to serialize: %v", err)
 			}
 			w := new(bytes.Buffer)
+			binary.Write(w, binary.LittleEndian, []uint64{ExecMagic, ExecVersion})
 			binary.Write(w, binary.LittleEndian, test.serialized)
 			data := buf
 			if len(data) > len(w.Bytes()) {
@@ -16,6 +16,9 @@ package prog
 // replacing the pointed argument with the saved value.
 //		4. If a valid program is obtained, then fuzzer launches it and
 // checks if new coverage is obtained.
+// Comparisons against the program's own input buffers (e.g. kernel compares
+// a copy of one input field with another) are filtered out before step 2:
+// such hints are trivially satisfied and rarely give new coverage.
 // For more insights on particular mutations please see prog/hints_test.go.
 
 import (
@@ -34,6 +37,11 @@ type CompMap map[uint64]uint64Set
 
 const (
 	maxDataLength = 100
+	// Input buffer values below this magnitude are not fingerprinted:
+	// small values match kernel constants too frequently.
+	minInputValue = 1 << 16
+	// Max number of bytes of each input buffer that are fingerprinted.
+	maxInputLength = 4 << 10
 )
 
 var specialIntsSet uint64Set
@@ -48,16 +56,65 @@ func (m CompMap) AddComp(arg1, arg2 uint64) {
 // Mutates the program using the comparison operands stored in compMaps.
 // For each of the mutants executes the exec callback.
 func (p *Prog) MutateWithHints(compMaps []CompMap, exec func(newP *Prog)) {
+	input := p.inputFingerprint()
 	for i, c := range p.Calls {
 		if c.Meta == p.Target.MmapSyscall {
 			continue
 		}
+		compMap := compMaps[i].filterInput(input)
 		foreachArg(c, func(arg, _ Arg, _ *[]Arg) {
-			generateHints(p, compMaps[i], c, arg, exec)
+			generateHints(p, compMap, c, arg, exec)
 		})
 	}
 }
 
+// inputFingerprint returns the set of 32/64-bit values contained in the program's
+// input buffers (data args passed to kernel). Integer args are not fingerprinted:
+// their values are supposed to match kernel constants.
+func (p *Prog) inputFingerprint() uint64Set {
+	input := make(uint64Set)
+	add := func(v uint64) {
+		if v >= minInputValue && -v > minInputValue {
+			input[v] = true
+		}
+	}
+	for _, c := range p.Calls {
+		foreachArg(c, func(arg, _ Arg, _ *[]Arg) {
+			a, ok := arg.(*DataArg)
+			if !ok || a.Type().Dir() == DirOut {
+				return
+			}
+			data := a.Data[:min(len(a.Data), maxInputLength)]
+			for i := 0; i+4 <= len(data); i++ {
+				v := uint64(binary.LittleEndian.Uint32(data[i:]))
+				add(v)
+				add(uint64(int64(int32(v))))
+				if i+8 <= len(data) {
+					add(binary.LittleEndian.Uint64(data[i:]))
+				}
+			}
+		})
+	}
+	return input
+}
+
+// filterInput returns a copy of m without comparisons against input values
+// (these are likely input data copied verbatim by kernel and compared with itself).
+func (m CompMap) filterInput(input uint64Set) CompMap {
+	if len(input) == 0 {
+		return m
+	}
+	res := make(CompMap)
+	for op1, ops := range m {
+		for op2 := range ops {
+			if !input[op2] {
+				res.AddComp(op1, op2)
+			}
+		}
+	}
+	return res
+}
+
 func generateHints(p *Prog, compMap CompMap, c *Call, arg Arg, exec func(p *Prog)) {
 	newP, argMap := p.cloneImpl(true)
 	var originalArg Arg
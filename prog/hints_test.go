@@ -336,3 +336,22 @@ func TestHintsShrinkExpand(t *testing.T) {
 		})
 	}
 }
+
+func TestHintsFilterInput(t *testing.T) {
+	target, _, _ := initTest(t)
+	p, err := target.Deserialize([]byte(`write(0xffffffffffffffff, &(0x7f0000000000)="0000000078563412", 0xdeadbeef)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0x12345678 is contained in the input buffer, so the comparison
+	// against it must not produce a hint.
+	comps := CompMap{0xdeadbeef: uint64Set{0x12345678: true, 0xcafebabe: true}}
+	var got []string
+	p.MutateWithHints([]CompMap{comps}, func(p *Prog) {
+		got = append(got, string(p.Serialize()))
+	})
+	want := []string{"write(0xffffffffffffffff, &(0x7f0000000000)=\"0000000078563412\", 0xcafebabe)\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got hints:\n%q\nwant:\n%q", got, want)
+	}
+}
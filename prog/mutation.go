@@ -4,13 +4,31 @@
 package prog
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
+	"path"
+	"strings"
 	"unsafe"
 )
 
 func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, corpus []*Prog) {
+	p.MutateFocused(rs, ncalls, ct, corpus, nil)
+}
+
+// MutateFocused is the same as Mutate, but argument mutations are biased towards
+// calls with indices in focus. Callers pass calls that gave new coverage
+// when the program was added to corpus, because mutating arguments
+// of these calls is more likely to give new coverage again.
+func (p *Prog) MutateFocused(rs rand.Source, ncalls int, ct *ChoiceTable, corpus []*Prog, focus []int) {
 	r := newRand(p.Target, rs)
+	// Remember the calls themselves, indices change as calls are inserted and removed.
+	var focusCalls []*Call
+	for _, idx := range focus {
+		if idx >= 0 && idx < len(p.Calls) {
+			focusCalls = append(focusCalls, p.Calls[idx])
+		}
+	}
 
 	retry := false
 	for stop := false; !stop || retry; stop = r.oneOf(3) {
@@ -23,12 +41,20 @@ func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, corpus []*Pro
 				continue
 			}
 			p0 := corpus[r.Intn(len(corpus))]
-			p0c := p0.Clone()
-			idx := r.Intn(len(p.Calls))
-			p.Calls = append(p.Calls[:idx], append(p0c.Calls, p.Calls[idx:]...)...)
+			if len(p0.Calls) == 0 {
+				retry = true
+				continue
+			}
+			p.splice(r, p0, r.Intn(len(p.Calls)+1), r.Intn(len(p0.Calls)))
 			for i := len(p.Calls) - 1; i >= ncalls; i-- {
 				p.removeCall(i)
 			}
+		case r.nOutOf(1, 100):
+			// Squash several small related progs from corpus into this one.
+			if !p.squash(r, corpus, ncalls) {
+				retry = true
+				continue
+			}
 		case r.nOutOf(20, 31):
 			// Insert a new call.
 			if len(p.Calls) >= ncalls {
@@ -49,7 +75,7 @@ func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, corpus []*Pro
 				retry = true
 				continue
 			}
-			c := p.Calls[r.Intn(len(p.Calls))]
+			c := r.chooseMutatedCall(p, focusCalls)
 			if len(c.Args) == 0 {
 				retry = true
 				continue
@@ -115,11 +141,13 @@ func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, corpus []*Pro
 								minLen, maxLen = t.TypeSize, t.TypeSize
 							}
 							a.Data = mutateData(r, append([]byte{}, a.Data...), minLen, maxLen)
+						} else if len(t.Values) == 0 && t.TypeSize == 0 && t.Dir() != DirOut && isPathLike(a.Data) {
+							a.Data = []byte(r.mutateFilename(s, string(a.Data)))
 						} else {
 							a.Data = r.randString(s, t.Values, t.Dir())
 						}
 					case BufferFilename:
-						a.Data = []byte(r.filename(s))
+						a.Data = []byte(r.mutateFilename(s, string(a.Data)))
 					case BufferText:
 						a.Data = r.mutateText(t.Text, a.Data)
 					default:
@@ -201,6 +229,8 @@ func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, corpus []*Pro
 					p.replaceArg(c, arg, arg1, calls)
 				case *LenType:
 					panic("bad arg returned by mutationArgs: LenType")
+				case *TagType:
+					panic("bad arg returned by mutationArgs: TagType")
 				case *CsumType:
 					panic("bad arg returned by mutationArgs: CsumType")
 				case *ConstType:
@@ -249,6 +279,239 @@ func (p *Prog) Mutate(rs rand.Source, ncalls int, ct *ChoiceTable, corpus []*Pro
 	}
 }
 
+// splice replaces calls of p starting from idx with calls of p0 starting from idx0.
+// Resources that the spliced calls take from the dropped p0 calls are renumbered
+// to compatible resources created by the remaining p calls (if there are any),
+// so that the spliced calls operate on objects created by p. Dropped p0 calls
+// that the spliced calls still depend on (producers of resources that can't be
+// renumbered and mmaps of pages that p does not map) are kept.
+func (p *Prog) splice(r *randGen, p0 *Prog, idx, idx0 int) {
+	p0 = p0.Clone()
+	s := newState(p.Target, nil)
+	for _, c := range p.Calls[:idx] {
+		s.analyze(c)
+	}
+	// Producers of resources and last mappers of pages among the dropped p0 calls.
+	producers := make(map[Arg]int)
+	var mappers [maxPages]int
+	for i := range mappers {
+		mappers[i] = -1
+	}
+	for i, c := range p0.Calls[:idx0] {
+		foreachArgArray(&c.Args, c.Ret, func(arg, _ Arg, _ *[]Arg) {
+			if _, ok := arg.(ArgUsed); ok {
+				producers[arg] = i
+			}
+		})
+		if start, npages, mapped := p0.Target.AnalyzeMmap(c); npages != 0 {
+			for page := start; page < start+npages && page < maxPages; page++ {
+				mappers[page] = -1
+				if mapped {
+					mappers[page] = i
+				}
+			}
+		}
+	}
+	keep := make([]bool, idx0)
+	renumbered := make(map[Arg]Arg)
+	fixup := func(c *Call) {
+		foreachArg(c, func(arg, _ Arg, _ *[]Arg) {
+			switch a := arg.(type) {
+			case *ResultArg:
+				if a.Res == nil {
+					return
+				}
+				producer, ok := producers[a.Res]
+				if !ok {
+					return
+				}
+				res, ok := renumbered[a.Res]
+				if !ok {
+					res = r.compatibleResource(s, a.Type().(*ResourceType))
+					renumbered[a.Res] = res
+				}
+				if res == nil {
+					keep[producer] = true
+					return
+				}
+				p0.replaceArg(c, arg, MakeResultArg(arg.Type(), res, 0), nil)
+			case *PointerArg:
+				lo, hi := p0.Target.pointerPages(a)
+				for page := lo; page < hi; page++ {
+					if mapper := mappers[page]; mapper != -1 && !s.pages[page] {
+						keep[mapper] = true
+					}
+				}
+			}
+		})
+	}
+	for _, c := range p0.Calls[idx0:] {
+		fixup(c)
+	}
+	// Dependencies always precede the call, so a single backward pass is enough.
+	for i := idx0 - 1; i >= 0; i-- {
+		if keep[i] {
+			fixup(p0.Calls[i])
+		} else {
+			p0.removeCall(i)
+		}
+	}
+	for i := len(p.Calls) - 1; i >= idx; i-- {
+		p.removeCall(i)
+	}
+	p.Calls = append(p.Calls, p0.Calls...)
+}
+
+// compatibleResource returns a random resource from s that can be precisely used as res, or nil.
+func (r *randGen) compatibleResource(s *state, res *ResourceType) Arg {
+	var allres []Arg
+	for name, res1 := range s.resources {
+		if isCompatibleResourceImpl(res.Desc.Kind, r.target.resourceMap[name].Kind, true) {
+			allres = append(allres, res1...)
+		}
+	}
+	if len(allres) == 0 {
+		return nil
+	}
+	return allres[r.Intn(len(allres))]
+}
+
+const (
+	maxSquashProgs = 3 // max number of corpus progs squashed into a prog at once
+	maxSquashCalls = 5 // only progs with at most this number of calls are squashed
+)
+
+// squash merges several small corpus progs that work with the same subsystem
+// as p into p. Calls of the progs are randomly interleaved (keeping the order
+// of calls within each prog), so that calls of one prog are executed while
+// objects created by another prog are alive. Progs are considered to belong
+// to the same subsystem if they use the same specialized resources (e.g. fd_kvm).
+// Returns false if no suitable progs are found.
+func (p *Prog) squash(r *randGen, corpus []*Prog, ncalls int) bool {
+	kinds := subsystemResources(p)
+	if len(kinds) == 0 {
+		return false
+	}
+	progs := [][]*Call{p.Calls}
+	total := len(p.Calls)
+	used := make(map[*Prog]bool)
+	for try := 0; try < 100 && len(corpus) != 0 && len(progs) <= maxSquashProgs; try++ {
+		p0 := corpus[r.Intn(len(corpus))]
+		if p0 == p || used[p0] || len(p0.Calls) == 0 || len(p0.Calls) > maxSquashCalls ||
+			total+len(p0.Calls) > ncalls {
+			continue
+		}
+		related := false
+		for kind := range subsystemResources(p0) {
+			if kinds[kind] {
+				related = true
+				break
+			}
+		}
+		if !related {
+			continue
+		}
+		used[p0] = true
+		progs = append(progs, p0.Clone().Calls)
+		total += len(p0.Calls)
+	}
+	if len(progs) == 1 {
+		return false
+	}
+	var calls []*Call
+	for len(progs) != 0 {
+		i := r.Intn(len(progs))
+		calls = append(calls, progs[i][0])
+		progs[i] = progs[i][1:]
+		if len(progs[i]) == 0 {
+			progs = append(progs[:i], progs[i+1:]...)
+		}
+	}
+	p.Calls = calls
+	return true
+}
+
+// subsystemResources returns names of specialized resources (e.g. fd_kvm, but not fd) used by p.
+func subsystemResources(p *Prog) map[string]bool {
+	res := make(map[string]bool)
+	for _, c := range p.Calls {
+		foreachArgArray(&c.Args, c.Ret, func(arg, _ Arg, _ *[]Arg) {
+			if typ, ok := arg.Type().(*ResourceType); ok && len(typ.Desc.Kind) > 1 {
+				res[typ.Desc.Name] = true
+			}
+		})
+	}
+	return res
+}
+
+// mutateFilename mutates file name old using known paths of the same kind
+// (device nodes, procfs/sysfs files, sysctls), sibling files in the same directory
+// and simple structural mutations of the path.
+func (r *randGen) mutateFilename(s *state, old string) string {
+	dict := &r.target.paths
+	file := strings.TrimRight(old, "\x00")
+	if len(dict.all) == 0 || r.oneOf(3) {
+		return r.filename(s)
+	}
+	switch {
+	case r.nOutOf(1, 3):
+		// Another file of the same kind (e.g. another device node for a device node).
+		if files := dict.kinds[classifyPath(file)]; len(files) != 0 {
+			return files[r.Intn(len(files))] + "\x00"
+		}
+		return dict.all[r.Intn(len(dict.all))] + "\x00"
+	case r.nOutOf(1, 2):
+		// Another file in the same directory.
+		if files := dict.dirs[path.Dir(file)]; len(files) != 0 {
+			return files[r.Intn(len(files))] + "\x00"
+		}
+		return dict.all[r.Intn(len(dict.all))] + "\x00"
+	default:
+		switch r.Intn(4) {
+		case 0:
+			file = path.Dir(file)
+		case 1:
+			if files := dict.dirs[file]; len(files) != 0 {
+				file = files[r.Intn(len(files))]
+			} else {
+				file += "/"
+			}
+		case 2:
+			file += []string{"/.", "/..", "/"}[r.Intn(3)]
+		default:
+			file = strings.Replace(file, "/", "//", 1)
+		}
+		if isDangerousPath(path.Clean(file)) {
+			return r.filename(s)
+		}
+		return file + "\x00"
+	}
+}
+
+func isPathLike(data []byte) bool {
+	return len(data) > 1 && data[0] == '/' && bytes.IndexByte(bytes.TrimRight(data, "\x00"), 0) == -1
+}
+
+// chooseMutatedCall returns a call of p to mutate arguments of.
+// With high probability it is one of the focus calls that are still present in p.
+func (r *randGen) chooseMutatedCall(p *Prog, focus []*Call) *Call {
+	if len(focus) != 0 && r.nOutOf(3, 4) {
+		var present []*Call
+		for _, c := range p.Calls {
+			for _, c1 := range focus {
+				if c == c1 {
+					present = append(present, c)
+					break
+				}
+			}
+		}
+		if len(present) != 0 {
+			return present[r.Intn(len(present))]
+		}
+	}
+	return p.Calls[r.Intn(len(p.Calls))]
+}
+
 // Minimize minimizes program p into an equivalent program using the equivalence
 // predicate pred.  It iteratively generates simpler programs and asks pred
 // whether it is equal to the orginal program or not. If it is equivalent then
@@ -309,6 +572,9 @@ func Minimize(p0 *Prog, callIndex0 int, pred0 func(*Prog, int) bool, crash bool)
 		}
 	}
 
+	// Try to remove independent clusters of calls at once.
+	p0, callIndex0 = minimizeClusters(p0, callIndex0, pred)
+
 	// Try to remove all calls except the last one one-by-one.
 	for i := len(p0.Calls) - 1; i >= 0; i-- {
 		if i == callIndex0 {
@@ -451,7 +717,7 @@ func Minimize(p0 *Prog, callIndex0 int, pred0 func(*Prog, int) bool, crash bool)
 				}
 			}
 			p0 = p
-		case *VmaType, *LenType, *CsumType, *ConstType:
+		case *VmaType, *LenType, *TagType, *CsumType, *ConstType:
 			// TODO: try to remove offset from vma
 			return false
 		default:
@@ -482,6 +748,119 @@ func Minimize(p0 *Prog, callIndex0 int, pred0 func(*Prog, int) bool, crash bool)
 	return p0, callIndex0
 }
 
+// OptionSimplifier tries to simplify execution options that are opaque for prog
+// (e.g. csource.Options). It returns simplified copy of opts and true,
+// or false if the simplification is not applicable to opts.
+// The passed options must not be modified in place.
+type OptionSimplifier func(opts interface{}) (interface{}, bool)
+
+// MinimizeWithOracle is like Minimize, but it also simplifies execution options
+// using simplifiers. oracle says if the program still crashes when executed with
+// the given options. The result is the simplest (program, call index, options)
+// combination found that still satisfies oracle.
+// Options are simplified both before and after program minimization:
+// simpler options make program minimization faster and more reliable, while
+// a smaller program may allow further option simplifications.
+// If options depend on call indices (e.g. fault injection call), oracle needs
+// to update them according to the passed callIndex.
+func MinimizeWithOracle(p0 *Prog, callIndex0 int, opts0 interface{}, simplifiers []OptionSimplifier,
+	oracle func(p *Prog, callIndex int, opts interface{}) bool) (*Prog, int, interface{}) {
+	opts := opts0
+	simplify := func(p *Prog, callIndex int) {
+		for _, simplifier := range simplifiers {
+			if opts1, ok := simplifier(opts); ok && oracle(p, callIndex, opts1) {
+				opts = opts1
+			}
+		}
+	}
+	simplify(p0, callIndex0)
+	p, callIndex := Minimize(p0, callIndex0, func(p1 *Prog, callIndex1 int) bool {
+		return oracle(p1, callIndex1, opts)
+	}, true)
+	simplify(p, callIndex)
+	return p, callIndex, opts
+}
+
+// minimizeClusters tries to remove clusters of calls that are not connected with the rest of the program
+// by resources (see CallGraph.Clusters), this takes one predicate run per cluster instead of one per call.
+// Calls that remaining calls depend on for memory (e.g. mmap) are not removed.
+// Single-call clusters are left for the one-by-one removal.
+func minimizeClusters(p0 *Prog, callIndex0 int, pred func(*Prog, int) bool) (*Prog, int) {
+	clusters := p0.CallGraph().Clusters()
+	if len(clusters) < 2 {
+		return p0, callIndex0
+	}
+	// cur[i] is the index of the original call i in p0, or -1 if the call is removed.
+	cur := make([]int, len(p0.Calls))
+	for i := range cur {
+		cur[i] = i
+	}
+	crashCall := callIndex0
+	for ci := len(clusters) - 1; ci >= 0; ci-- {
+		cluster := clusters[ci]
+		if len(cluster) < 2 || clusterContains(cluster, crashCall) {
+			continue
+		}
+		remove := make(map[int]bool)
+		for _, i := range cluster {
+			if cur[i] != -1 {
+				remove[cur[i]] = true
+			}
+		}
+		g := p0.CallGraph()
+		for i, deps := range g.Deps {
+			if remove[i] {
+				continue
+			}
+			for _, dep := range deps {
+				delete(remove, dep.Call)
+			}
+		}
+		if len(remove) < 2 || len(remove) == len(p0.Calls) {
+			continue
+		}
+		p := p0.Clone()
+		callIndex := callIndex0
+		for i := len(p.Calls) - 1; i >= 0; i-- {
+			if !remove[i] {
+				continue
+			}
+			p.removeCall(i)
+			if i < callIndex {
+				callIndex--
+			}
+		}
+		if !pred(p, callIndex) {
+			continue
+		}
+		p0, callIndex0 = p, callIndex
+		for i, idx := range cur {
+			if idx == -1 {
+				continue
+			}
+			if remove[idx] {
+				cur[i] = -1
+				continue
+			}
+			for removed := range remove {
+				if removed < idx {
+					cur[i]--
+				}
+			}
+		}
+	}
+	return p0, callIndex0
+}
+
+func clusterContains(cluster []int, call int) bool {
+	for _, i := range cluster {
+		if i == call {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Prog) TrimAfter(idx int) {
 	if idx < 0 || idx >= len(p.Calls) {
 		panic("trimming non-existing call")
@@ -516,6 +895,9 @@ func (target *Target) mutationArgs(c *Call) (args, bases []Arg) {
 		case *LenType:
 			// Size is updated when the size-of arg change.
 			return
+		case *TagType:
+			// Tag is updated when the union option changes.
+			return
 		case *CsumType:
 			// Checksum is updated when the checksummed data changes.
 			return
@@ -326,3 +326,266 @@ func TestMinimizeCallIndex(t *testing.T) {
 		}
 	}
 }
+
+func TestMinimizeWithOracle(t *testing.T) {
+	target, _, _ := initTest(t)
+	type options struct {
+		threaded bool
+		collide  bool
+		procs    int
+	}
+	simplifiers := []OptionSimplifier{
+		func(opts interface{}) (interface{}, bool) {
+			o := opts.(options)
+			if !o.collide {
+				return nil, false
+			}
+			o.collide = false
+			return o, true
+		},
+		func(opts interface{}) (interface{}, bool) {
+			o := opts.(options)
+			if o.collide || !o.threaded {
+				return nil, false
+			}
+			o.threaded = false
+			return o, true
+		},
+		func(opts interface{}) (interface{}, bool) {
+			o := opts.(options)
+			if o.procs == 1 {
+				return nil, false
+			}
+			o.procs = 1
+			return o, true
+		},
+	}
+	p0, err := target.Deserialize([]byte(
+		"mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+			"sched_yield()\n" +
+			"pipe2(&(0x7f0000000000)={0x0, 0x0}, 0x0)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The crash requires sched_yield and threaded mode, and also several procs
+	// unless the program consists of the single call. So procs can only be reduced
+	// after program minimization.
+	oracle := func(p *Prog, callIndex int, opts interface{}) bool {
+		o := opts.(options)
+		if callIndex == -1 || p.Calls[callIndex].Meta.Name != "sched_yield" {
+			t.Fatalf("bad call index %v", callIndex)
+		}
+		return o.threaded && (o.procs > 1 || len(p.Calls) == 1)
+	}
+	p, callIndex, opts := MinimizeWithOracle(p0, 1, options{true, true, 4}, simplifiers, oracle)
+	if want := (options{threaded: true, procs: 1}); opts.(options) != want {
+		t.Errorf("got options %+v, want %+v", opts, want)
+	}
+	if want := "sched_yield()\n"; string(p.Serialize()) != want || callIndex != 0 {
+		t.Errorf("got program (call %v):\n%s\nwant:\n%s", callIndex, p.Serialize(), want)
+	}
+}
+
+func TestMutateFocused(t *testing.T) {
+	target, rs, iters := initTest(t)
+	for i := 0; i < iters; i++ {
+		p := target.Generate(rs, 10, nil)
+		focus := []int{rand.New(rs).Intn(len(p.Calls))}
+		p.MutateFocused(rs, 10, nil, nil, focus)
+	}
+}
+
+func TestChooseMutatedCall(t *testing.T) {
+	target, rs, _ := initTest(t)
+	r := newRand(target, rs)
+	p := target.Generate(rs, 10, nil)
+	for len(p.Calls) < 4 {
+		p = target.Generate(rs, 10, nil)
+	}
+	focus := []*Call{p.Calls[1], p.Calls[3]}
+	const iters = 1000
+	hits := 0
+	for i := 0; i < iters; i++ {
+		c := r.chooseMutatedCall(p, focus)
+		if c == focus[0] || c == focus[1] {
+			hits++
+		}
+	}
+	// Focus calls are chosen with probability at least 3/4.
+	if hits < iters*2/3 {
+		t.Fatalf("focus calls were chosen %v times out of %v", hits, iters)
+	}
+	// Calls that were removed from the program are not chosen.
+	removed := []*Call{p.Calls[0]}
+	p.removeCall(0)
+	for i := 0; i < iters; i++ {
+		if c := r.chooseMutatedCall(p, removed); c == removed[0] {
+			t.Fatalf("removed call was chosen")
+		}
+	}
+}
+
+func TestMutateFilename(t *testing.T) {
+	target0, rs, iters := initTest(t)
+	// Use a copy of the target, since other tests use it concurrently.
+	target := new(Target)
+	*target = *target0
+	target.paths = pathDictionary{}
+	target.AddPaths([]string{"/dev/null", "/dev/zero", "/proc/self/maps", "/proc/sys/net/core/somaxconn",
+		"/sys/kernel/mm", "/proc/sysrq-trigger", "/dev/watchdog0"})
+	r := newRand(target, rs)
+	s := newState(target, nil)
+	for i := 0; i < iters; i++ {
+		file := r.mutateFilename(s, "/dev/null\x00")
+		if len(file) == 0 || file[len(file)-1] != 0 {
+			t.Fatalf("filename is not null-terminated: %q", file)
+		}
+		if isDangerousPath(file[:len(file)-1]) {
+			t.Fatalf("got dangerous filename %q", file)
+		}
+	}
+	if target.paths.known["/proc/sysrq-trigger"] || target.paths.known["/dev/watchdog0"] {
+		t.Fatalf("dangerous paths were added to dictionary")
+	}
+	if !target.paths.known["/proc/sys/net/core/somaxconn"] {
+		t.Fatalf("path was not added to dictionary")
+	}
+}
+
+func TestSplice(t *testing.T) {
+	target, rs, _ := initTest(t)
+	r := newRand(target, rs)
+	const (
+		openKvm  = "r0 = openat$kvm(0xffffffffffffff9c, &(0x7f0000000000)=\"2f6465762f6b766d00\", 0x0, 0x0)\n"
+		openKvm1 = "r0 = openat$kvm(0xffffffffffffff9c, &(0x7f0000001000)=\"2f6465762f6b766d00\", 0x0, 0x0)\n"
+	)
+	p0 := openKvm1 +
+		"r1 = ioctl$KVM_CREATE_VM(r0, 0xae01, 0x0)\n" +
+		"ioctl$KVM_CREATE_VCPU(r1, 0xae41, 0x0)\n"
+	tests := []struct {
+		p       string
+		idx     int
+		idx0    int
+		spliced string
+	}{
+		// The spliced call uses fd_kvm created by p.
+		{
+			openKvm + "sched_yield()\n",
+			1, 1,
+			openKvm +
+				"r1 = ioctl$KVM_CREATE_VM(r0, 0xae01, 0x0)\n" +
+				"ioctl$KVM_CREATE_VCPU(r1, 0xae41, 0x0)\n",
+		},
+		// p does not create fd_kvmvm, so its producer is kept and uses fd_kvm created by p.
+		{
+			openKvm + "sched_yield()\n",
+			2, 2,
+			openKvm +
+				"sched_yield()\n" +
+				"r1 = ioctl$KVM_CREATE_VM(r0, 0xae01, 0x0)\n" +
+				"ioctl$KVM_CREATE_VCPU(r1, 0xae41, 0x0)\n",
+		},
+		// Whole p0 is appended.
+		{
+			"sched_yield()\n",
+			1, 0,
+			"sched_yield()\n" + openKvm1 +
+				"r1 = ioctl$KVM_CREATE_VM(r0, 0xae01, 0x0)\n" +
+				"ioctl$KVM_CREATE_VCPU(r1, 0xae41, 0x0)\n",
+		},
+	}
+	for i, test := range tests {
+		p, err := target.Deserialize([]byte(test.p))
+		if err != nil {
+			t.Fatalf("failed to deserialize prog: %v", err)
+		}
+		pp0, err := target.Deserialize([]byte(p0))
+		if err != nil {
+			t.Fatalf("failed to deserialize prog: %v", err)
+		}
+		p.splice(r, pp0, test.idx, test.idx0)
+		if err := p.validate(); err != nil {
+			t.Fatalf("#%v: spliced prog is broken: %v", i, err)
+		}
+		if got := string(p.Serialize()); got != test.spliced {
+			t.Errorf("#%v: got:\n%s\nwant:\n%s", i, got, test.spliced)
+		}
+		if got := string(pp0.Serialize()); got != p0 {
+			t.Errorf("#%v: corpus prog changed:\n%s", i, got)
+		}
+	}
+}
+
+func TestSpliceMmap(t *testing.T) {
+	target, rs, _ := initTest(t)
+	r := newRand(target, rs)
+	p0, err := target.Deserialize([]byte(
+		"mmap(&(0x7f0000000000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+			"mmap(&(0x7f0000001000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+			"sched_yield()\n" +
+			"openat$kvm(0xffffffffffffff9c, &(0x7f0000001000)=\"2f6465762f6b766d00\", 0x0, 0x0)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := target.Deserialize([]byte("getpid()\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.splice(r, p0, 1, 3)
+	if err := p.validate(); err != nil {
+		t.Fatalf("spliced prog is broken: %v", err)
+	}
+	// Only the mmap of the page used by the spliced call is kept.
+	want := "getpid()\n" +
+		"mmap(&(0x7f0000001000/0x1000)=nil, 0x1000, 0x3, 0x32, 0xffffffffffffffff, 0x0)\n" +
+		"openat$kvm(0xffffffffffffff9c, &(0x7f0000001000)=\"2f6465762f6b766d00\", 0x0, 0x0)\n"
+	if got := string(p.Serialize()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSquash(t *testing.T) {
+	target, rs, iters := initTest(t)
+	r := newRand(target, rs)
+	var corpus []*Prog
+	for _, data := range []string{
+		"r0 = openat$kvm(0xffffffffffffff9c, &(0x7f0000000000)=\"2f6465762f6b766d00\", 0x0, 0x0)\n" +
+			"ioctl$KVM_CREATE_VM(r0, 0xae01, 0x0)\n",
+		"r0 = openat$kvm(0xffffffffffffff9c, &(0x7f0000000000)=\"2f6465762f6b766d00\", 0x0, 0x0)\n" +
+			"r1 = ioctl$KVM_CREATE_VM(r0, 0xae01, 0x0)\n" +
+			"ioctl$KVM_CREATE_VCPU(r1, 0xae41, 0x0)\n",
+		"pipe2(&(0x7f0000000000)={0x0, 0x0}, 0x0)\n",
+	} {
+		p, err := target.Deserialize([]byte(data))
+		if err != nil {
+			t.Fatalf("failed to deserialize prog: %v", err)
+		}
+		corpus = append(corpus, p)
+	}
+	for i := 0; i < iters; i++ {
+		p := corpus[0].Clone()
+		if !p.squash(r, corpus, 10) {
+			t.Fatalf("failed to squash")
+		}
+		if err := p.validate(); err != nil {
+			t.Fatalf("squashed prog is broken: %v", err)
+		}
+		// The original prog itself can be squashed as well.
+		if len(p.Calls) < 4 || len(p.Calls) > 10 {
+			t.Fatalf("squashed prog has %v calls:\n%s", len(p.Calls), p.Serialize())
+		}
+		for _, c := range p.Calls {
+			if c.Meta.Name == "pipe2" {
+				t.Fatalf("unrelated prog was squashed:\n%s", p.Serialize())
+			}
+		}
+	}
+	// pipe2 prog does not use any specialized resources.
+	if p := corpus[2].Clone(); p.squash(r, corpus, 10) {
+		t.Fatalf("unrelated progs were squashed:\n%s", p.Serialize())
+	}
+	// Squash into a prog that already has ncalls calls.
+	if p := corpus[1].Clone(); p.squash(r, corpus, 3) {
+		t.Fatalf("squash exceeded ncalls:\n%s", p.Serialize())
+	}
+}
@@ -5,6 +5,8 @@ package prog
 
 import (
 	"bytes"
+	"fmt"
+	"sort"
 	"strconv"
 )
 
@@ -19,10 +21,36 @@ type LogEntry struct {
 	FaultNth  int
 }
 
+// LogProcPrefix returns prefix for program lines of the given proc.
+// Lines with the prefix are attributed to the proc's program even if they are
+// interleaved with programs of other procs (e.g. when programs are written to dmesg
+// line-by-line concurrently).
+func LogProcPrefix(proc int) string {
+	return fmt.Sprintf("syzkaller[%v]: ", proc)
+}
+
+// ParseLog extracts programs from execution log. The returned entries are sorted by Start.
+// Unprefixed program lines belong to the last started program, lines with LogProcPrefix
+// belong to the last started program of the corresponding proc.
 func (target *Target) ParseLog(data []byte) []*LogEntry {
 	var entries []*LogEntry
-	ent := &LogEntry{}
-	var cur []byte
+	type logProg struct {
+		ent      *LogEntry
+		cur      []byte
+		prefixed bool
+	}
+	// Lines before the first program marker are attributed to proc 0.
+	last := &logProg{ent: &LogEntry{}}
+	procs := map[int]*logProg{0: last}
+	finish := func(lp *logProg, end int) {
+		if lp.ent.P != nil && len(lp.ent.P.Calls) != 0 {
+			lp.ent.End = end
+			entries = append(entries, lp.ent)
+		}
+		if procs[lp.ent.Proc] == lp {
+			delete(procs, lp.ent.Proc)
+		}
+	}
 	for pos := 0; pos < len(data); {
 		nl := bytes.IndexByte(data[pos:], '\n')
 		if nl == -1 {
@@ -35,11 +63,14 @@ func (target *Target) ParseLog(data []byte) []*LogEntry {
 		pos = nl + 1
 
 		if proc, ok := extractInt(line, "executing program "); ok {
-			if ent.P != nil && len(ent.P.Calls) != 0 {
-				ent.End = pos0
-				entries = append(entries, ent)
+			// Programs of other procs stay open only if their lines are prefixed,
+			// otherwise the new program terminates them (the log is not interleaved).
+			for _, lp := range procs {
+				if lp.ent.Proc == proc || !lp.prefixed {
+					finish(lp, pos0)
+				}
 			}
-			ent = &LogEntry{
+			ent := &LogEntry{
 				Proc:  proc,
 				Start: pos0,
 			}
@@ -48,27 +79,79 @@ func (target *Target) ParseLog(data []byte) []*LogEntry {
 				ent.FaultCall = faultCall
 				ent.FaultNth, _ = extractInt(line, "fault-nth:")
 			}
-			cur = nil
+			last = &logProg{ent: ent}
+			procs[proc] = last
 			continue
 		}
-		if ent == nil {
+		lp := last
+		if proc, rest, ok := extractProcPrefix(line); ok {
+			lp = procs[proc]
+			if lp == nil {
+				continue
+			}
+			lp.prefixed = true
+			line = rest
+		}
+		if procs[lp.ent.Proc] != lp {
 			continue
 		}
-		tmp := append(cur, line...)
+		tmp := append(lp.cur[:len(lp.cur):len(lp.cur)], line...)
 		p, err := target.Deserialize(tmp)
 		if err != nil {
 			continue
 		}
-		cur = tmp
-		ent.P = p
+		lp.cur = tmp
+		lp.ent.P = p
 	}
-	if ent.P != nil && len(ent.P.Calls) != 0 {
-		ent.End = len(data)
-		entries = append(entries, ent)
+	for _, lp := range procs {
+		finish(lp, len(data))
 	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start < entries[j].Start
+	})
 	return entries
 }
 
+// FaultComment returns a program comment line that describes fault injected into call/nth.
+// It is prepended to serialized programs that give their signal only with the fault
+// (corpus programs found by fault fuzzing). Deserialize ignores comments.
+func FaultComment(call, nth int) []byte {
+	return []byte(fmt.Sprintf("# fault-call:%v fault-nth:%v\n", call, nth))
+}
+
+// ParseFaultComment returns the fault described by FaultComment in the first line of data.
+func ParseFaultComment(data []byte) (call, nth int, ok bool) {
+	line := data
+	if nl := bytes.IndexByte(data, '\n'); nl != -1 {
+		line = data[:nl]
+	}
+	if !bytes.HasPrefix(line, []byte("# fault-call:")) {
+		return 0, 0, false
+	}
+	call, _ = extractInt(line, "fault-call:")
+	nth, ok = extractInt(line, "fault-nth:")
+	return call, nth, ok
+}
+
+// extractProcPrefix returns proc and the rest of the line after LogProcPrefix.
+func extractProcPrefix(line []byte) (int, []byte, bool) {
+	const prefix = "syzkaller["
+	pos := bytes.Index(line, []byte(prefix))
+	if pos == -1 {
+		return 0, nil, false
+	}
+	pos += len(prefix)
+	end := pos
+	for end != len(line) && line[end] >= '0' && line[end] <= '9' {
+		end++
+	}
+	if end == pos || !bytes.HasPrefix(line[end:], []byte("]: ")) {
+		return 0, nil, false
+	}
+	proc, _ := strconv.Atoi(string(line[pos:end]))
+	return proc, line[end+3:], true
+}
+
 func extractInt(line []byte, prefix string) (int, bool) {
 	pos := bytes.Index(line, []byte(prefix))
 	if pos == -1 {
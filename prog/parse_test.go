@@ -135,3 +135,74 @@ getpid()
 		t.Fatalf("bad program: %s, want %s", got, want)
 	}
 }
+
+func TestFaultComment(t *testing.T) {
+	target, err := GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(FaultComment(1, 55), "gettid()\ngetpid()\n"...)
+	call, nth, ok := ParseFaultComment(data)
+	if !ok || call != 1 || nth != 55 {
+		t.Fatalf("got fault %v/%v/%v, want 1/55/true", call, nth, ok)
+	}
+	p, err := target.Deserialize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.String(), "gettid-getpid"; got != want {
+		t.Fatalf("bad program: %s, want %s", got, want)
+	}
+	for _, data := range []string{
+		"gettid()\n",
+		"# some comment\ngettid()\n",
+		"gettid()\n# fault-call:1 fault-nth:55\n",
+	} {
+		if _, _, ok := ParseFaultComment([]byte(data)); ok {
+			t.Fatalf("parsed fault comment in %q", data)
+		}
+	}
+}
+
+func TestParseInterleaved(t *testing.T) {
+	target, err := GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const execLog = `[   10.000001] syzkaller: executing program 1:
+[   10.000002] syzkaller[1]: getpid()
+[   10.000003] syzkaller: executing program 2:
+[   10.000004] syzkaller[2]: munlockall()
+[   10.000005] syzkaller[1]: gettid()
+[   10.000006] syzkaller[3]: getpid()
+[   10.000007] syzkaller[2]: getpid()
+[   10.000008] syzkaller: executing program 1:
+[   10.000009] syzkaller[1]: munlockall()
+[   10.000010] syzkaller[2]: gettid()
+`
+	entries := target.ParseLog([]byte(execLog))
+	type result struct {
+		proc int
+		prog string
+	}
+	want := []result{
+		{1, "getpid-gettid"},
+		{2, "munlockall-getpid-gettid"},
+		{1, "munlockall"},
+	}
+	if len(entries) != len(want) {
+		for i, ent := range entries {
+			t.Logf("program #%v: proc %v: %s\n", i, ent.Proc, ent.P)
+		}
+		t.Fatalf("got %v programs, want %v", len(entries), len(want))
+	}
+	for i, ent := range entries {
+		got := result{ent.Proc, ent.P.String()}
+		if got != want[i] {
+			t.Errorf("program #%v: got %+v, want %+v", i, got, want[i])
+		}
+		if i != 0 && entries[i-1].Start >= ent.Start {
+			t.Errorf("program #%v: entries are not sorted by start offset", i)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+type pathKind int
+
+const (
+	pathOther  pathKind = iota
+	pathDev             // device nodes: /dev/...
+	pathProc            // procfs files: /proc/...
+	pathSysctl          // sysctl knobs: /proc/sys/...
+	pathSys             // sysfs files: /sys/...
+	pathKindCount
+)
+
+// pathDictionary holds known file paths grouped by kind and by parent directory.
+type pathDictionary struct {
+	all   []string
+	kinds [pathKindCount][]string
+	dirs  map[string][]string
+	known map[string]bool
+}
+
+// Paths (prefixes) that must not be used in generated programs, since writing to them
+// (or even opening them) reboots/hangs the machine or disturbs the fuzzer.
+var dangerousPaths = []string{
+	"/dev/watchdog",
+	"/dev/mem",
+	"/dev/kmem",
+	"/dev/port",
+	"/proc/sysrq-trigger",
+	"/proc/kcore",
+	"/proc/sys/kernel/panic",
+	"/proc/sys/kernel/sysrq",
+	"/proc/sys/vm/drop_caches",
+	"/sys/power",
+	"/sys/kernel/debug",
+	"/sys/firmware",
+}
+
+func classifyPath(file string) pathKind {
+	switch {
+	case strings.HasPrefix(file, "/dev/"):
+		return pathDev
+	case strings.HasPrefix(file, "/proc/sys/"):
+		return pathSysctl
+	case strings.HasPrefix(file, "/proc/"):
+		return pathProc
+	case strings.HasPrefix(file, "/sys/"):
+		return pathSys
+	default:
+		return pathOther
+	}
+}
+
+func isDangerousPath(file string) bool {
+	for _, bad := range dangerousPaths {
+		// Prefix match also covers e.g. /dev/watchdog0 and /proc/sys/kernel/panic_on_oops.
+		if strings.HasPrefix(file, bad) {
+			return true
+		}
+	}
+	return false
+}
+
+func (dict *pathDictionary) add(file string) {
+	file = strings.TrimRight(file, "\x00")
+	if !strings.HasPrefix(file, "/") || strings.ContainsAny(file, "\x00\n") {
+		return
+	}
+	// Templates of syz_open_dev, e.g. /dev/loop#.
+	file = strings.Replace(file, "#", "0", -1)
+	file = path.Clean(file)
+	if dict.known[file] || isDangerousPath(file) {
+		return
+	}
+	if dict.known == nil {
+		dict.known = make(map[string]bool)
+		dict.dirs = make(map[string][]string)
+	}
+	dict.known[file] = true
+	dict.all = append(dict.all, file)
+	kind := classifyPath(file)
+	dict.kinds[kind] = append(dict.kinds[kind], file)
+	dir := path.Dir(file)
+	dict.dirs[dir] = append(dict.dirs[dir], file)
+}
+
+// initPaths collects file paths mentioned in string values of the descriptions.
+func initPaths(target *Target) {
+	var paths []string
+	for _, c := range target.Syscalls {
+		ForeachType(c, func(t0 Type) {
+			if t, ok := t0.(*BufferType); ok && (t.Kind == BufferString || t.Kind == BufferFilename) {
+				paths = append(paths, t.Values...)
+			}
+		})
+	}
+	sort.Strings(paths)
+	for _, file := range paths {
+		target.paths.add(file)
+	}
+}
+
+// AddPaths adds file paths (e.g. collected from /proc, /sys and /dev listings on the target machine)
+// to the dictionary used for filename generation and mutation.
+// It is not thread-safe with respect to generation and mutation,
+// so it must be called before the target is used.
+func (target *Target) AddPaths(paths []string) {
+	for _, file := range paths {
+		target.paths.add(file)
+	}
+}
@@ -31,7 +31,7 @@ func (arg *ArgCommon) Type() Type {
 	return arg.typ
 }
 
-// Used for ConstType, IntType, FlagsType, LenType, ProcType and CsumType.
+// Used for ConstType, IntType, FlagsType, LenType, TagType, ProcType and CsumType.
 type ConstArg struct {
 	ArgCommon
 	Val uint64
@@ -52,6 +52,8 @@ func (arg *ConstArg) Value(pid int) uint64 {
 		return encodeValue(arg.Val, typ.Size(), typ.BigEndian)
 	case *LenType:
 		return encodeValue(arg.Val, typ.Size(), typ.BigEndian)
+	case *TagType:
+		return encodeValue(arg.Val, typ.Size(), typ.BigEndian)
 	case *CsumType:
 		// Checksums are computed dynamically in executor.
 		return 0
@@ -266,7 +268,7 @@ func MakeReturnArg(t Type) Arg {
 
 func defaultArg(t Type) Arg {
 	switch typ := t.(type) {
-	case *IntType, *ConstType, *FlagsType, *LenType, *ProcType, *CsumType:
+	case *IntType, *ConstType, *FlagsType, *LenType, *TagType, *ProcType, *CsumType:
 		return MakeConstArg(t, t.Default())
 	case *ResourceType:
 		return MakeResultArg(t, nil, typ.Desc.Type.Default())
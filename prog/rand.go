@@ -154,7 +154,10 @@ func (r *randGen) flags(vv []uint64) (v uint64) {
 }
 
 func (r *randGen) filename(s *state) string {
-	// TODO: support procfs and sysfs
+	if paths := r.target.paths.all; len(paths) != 0 && r.oneOf(10) {
+		// A known file (device node, procfs/sysfs file, etc).
+		return paths[r.Intn(len(paths))] + "\x00"
+	}
 	dir := "."
 	if r.oneOf(2) && len(s.files) != 0 {
 		files := make([]string, 0, len(s.files))
@@ -626,6 +629,9 @@ func (r *randGen) generateArg(s *state, typ Type) (arg Arg, calls []*Call) {
 	case *ConstType:
 		return MakeConstArg(a, a.Val), nil
 	case *IntType:
+		if vals := r.target.fieldValues[a]; len(vals) != 0 && r.bin() {
+			return MakeConstArg(a, vals[r.Intn(len(vals))]), nil
+		}
 		v := r.randInt()
 		switch a.Kind {
 		case IntFileoff:
@@ -687,6 +693,9 @@ func (r *randGen) generateArg(s *state, typ Type) (arg Arg, calls []*Call) {
 	case *LenType:
 		// Return placeholder value of 0 while generating len arg.
 		return MakeConstArg(a, 0), nil
+	case *TagType:
+		// Tag is assigned together with sizes once the union option is known.
+		return MakeConstArg(a, 0), nil
 	case *CsumType:
 		return MakeConstArg(a, 0), nil
 	default:
@@ -48,6 +48,15 @@ func (target *Target) assignSizes(args []Arg, parentsMap map[Arg]Arg) {
 		if arg = InnerArg(arg); arg == nil {
 			continue // Pointer to optional len field, no need to fill in value.
 		}
+		if typ, ok := arg.Type().(*TagType); ok {
+			union, ok := argsMap[typ.Union].(*UnionArg)
+			if !ok {
+				panic(fmt.Sprintf("tag field '%v' references non existent union field '%v', argsMap: %+v",
+					typ.FieldName(), typ.Union, argsMap))
+			}
+			arg.(*ConstArg).Val = unionOptionKey(union)
+			continue
+		}
 		if typ, ok := arg.Type().(*LenType); ok {
 			a := arg.(*ConstArg)
 
@@ -86,6 +95,17 @@ func (target *Target) assignSizes(args []Arg, parentsMap map[Arg]Arg) {
 	}
 }
 
+// unionOptionKey returns the tag value of the currently selected union option.
+func unionOptionKey(arg *UnionArg) uint64 {
+	typ := arg.Type().(*UnionType)
+	for i, opt := range typ.Fields {
+		if opt.FieldName() == arg.OptionType.FieldName() {
+			return typ.OptionKeys[i]
+		}
+	}
+	panic(fmt.Sprintf("union '%v' has no option '%v'", typ.Name(), arg.OptionType.FieldName()))
+}
+
 func (target *Target) assignSizesArray(args []Arg) {
 	parentsMap := make(map[Arg]Arg)
 	foreachArgArray(&args, nil, func(arg, base Arg, _ *[]Arg) {
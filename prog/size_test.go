@@ -137,3 +137,38 @@ func TestAssignSize(t *testing.T) {
 		}
 	}
 }
+
+func TestAssignTags(t *testing.T) {
+	target, _, _ := initTest(t)
+	int32Type := func(name string) *IntType {
+		return &IntType{IntTypeCommon: IntTypeCommon{TypeCommon: TypeCommon{
+			TypeName: "int32", FldName: name, TypeSize: 4}}}
+	}
+	tag := &TagType{
+		IntTypeCommon: IntTypeCommon{TypeCommon: TypeCommon{TypeName: "tag", FldName: "type", TypeSize: 2}},
+		Union:         "payload",
+	}
+	opt0, opt1 := int32Type("foo"), int32Type("bar")
+	union := &UnionType{
+		FldName: "payload",
+		StructDesc: &StructDesc{
+			TypeCommon: TypeCommon{TypeName: "union", TypeSize: 4},
+			Fields:     []Type{opt0, opt1},
+			OptionKeys: []uint64{10, 20},
+		},
+	}
+	strct := &StructType{
+		StructDesc: &StructDesc{
+			TypeCommon: TypeCommon{TypeName: "struct", TypeSize: 6},
+			Fields:     []Type{tag, union},
+		},
+	}
+	for i, opt := range []*IntType{opt0, opt1} {
+		tagArg := MakeConstArg(tag, 0).(*ConstArg)
+		arg := MakeGroupArg(strct, []Arg{tagArg, unionArg(union, MakeConstArg(opt, 0), opt)})
+		target.assignSizesArray([]Arg{arg})
+		if want := union.OptionKeys[i]; tagArg.Val != want {
+			t.Fatalf("option %v: tag is %v, want %v", i, tagArg.Val, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"sort"
+)
+
+const maxSuggestions = 3
+
+// suggestSyscalls returns names of syscalls that are close to the unknown name.
+func (target *Target) suggestSyscalls(name string) []string {
+	var names []string
+	for name1 := range target.SyscallMap {
+		names = append(names, name1)
+	}
+	for name1 := range target.SyscallRenames {
+		names = append(names, name1)
+	}
+	res := suggestNames(name, names)
+	for i, name1 := range res {
+		if newName := target.SyscallRenames[name1]; newName != "" {
+			res[i] = newName
+		}
+	}
+	return dedupNames(res)
+}
+
+// suggestNames returns up to maxSuggestions candidates that are close to name
+// (probably misspelled) in edit distance, closest first.
+func suggestNames(name string, candidates []string) []string {
+	type candidate struct {
+		name string
+		dist int
+	}
+	maxDist := len(name)/3 + 1
+	var found []candidate
+	for _, name1 := range candidates {
+		if dist := editDistance(name, name1); dist <= maxDist {
+			found = append(found, candidate{name1, dist})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].dist != found[j].dist {
+			return found[i].dist < found[j].dist
+		}
+		return found[i].name < found[j].name
+	})
+	var res []string
+	for i := 0; i < len(found) && i < maxSuggestions; i++ {
+		res = append(res, found[i].name)
+	}
+	return res
+}
+
+func dedupNames(names []string) []string {
+	dedup := make(map[string]bool)
+	var res []string
+	for _, name := range names {
+		if !dedup[name] {
+			dedup[name] = true
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+// editDistance returns the number of single character insertions, deletions, substitutions
+// and transpositions of adjacent characters required to transform a into b.
+func editDistance(a, b string) int {
+	// d[i][j] is the distance between a[:i] and b[:j].
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(min(d[i-1][j]+1, d[i][j-1]+1), d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
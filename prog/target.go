@@ -50,12 +50,20 @@ type Target struct {
 	// Used as fallback when string type does not have own dictionary.
 	StringDictionary []string
 
+	// SyscallRenames maps old names of syscalls that were renamed in descriptions
+	// to the current names, so that old programs can be fixed up (see DeserializeFix).
+	SyscallRenames map[string]string
+
 	// Filled by prog package:
 	SyscallMap  map[string]*Syscall
 	ConstMap    map[string]uint64
 	resourceMap map[string]*ResourceDesc
 	// Maps resource name to a list of calls that can create the resource.
 	resourceCtors map[string][]*Syscall
+	// Known file paths used for filename generation and mutation.
+	paths pathDictionary
+	// Values discovered on the target machine for particular struct fields (see AddFieldValues).
+	fieldValues map[Type][]uint64
 }
 
 var targets = make(map[string]*Target)
@@ -86,6 +94,12 @@ func GetTarget(OS, arch string) (*Target, error) {
 	return target, nil
 }
 
+// DataSize returns size of the data area that starts at DataOffset,
+// all pointer arguments of generated programs point into this area.
+func (target *Target) DataSize() uint64 {
+	return maxPages * target.PageSize
+}
+
 func AllTargets() []*Target {
 	var res []*Target
 	for _, t := range targets {
@@ -145,6 +159,7 @@ func initTarget(target *Target) {
 	for _, res := range target.Resources {
 		target.resourceCtors[res.Name] = target.calcResourceCtors(res.Kind, false)
 	}
+	initPaths(target)
 }
 
 type Gen struct {
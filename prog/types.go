@@ -167,6 +167,13 @@ type LenType struct {
 	Buf      string
 }
 
+// TagType holds the key of the option chosen in the sibling union field Union
+// (for type-length-value layouts where the type selects the payload).
+type TagType struct {
+	IntTypeCommon
+	Union string
+}
+
 type ProcType struct {
 	IntTypeCommon
 	ValuesStart   uint64
@@ -178,6 +185,7 @@ type CsumKind int
 const (
 	CsumInet CsumKind = iota
 	CsumPseudo
+	CsumCrc32c
 )
 
 type CsumType struct {
@@ -267,6 +275,8 @@ type StructDesc struct {
 	TypeCommon
 	Fields    []Type
 	AlignAttr uint64
+	// For unions with keyed options: tag value of each option (parallel to Fields).
+	OptionKeys []uint64
 }
 
 func (t *StructDesc) FieldName() string {
@@ -314,7 +324,7 @@ func ForeachType(meta *Syscall, f func(Type)) {
 			for _, opt := range a.Fields {
 				rec(opt)
 			}
-		case *ResourceType, *BufferType, *VmaType, *LenType,
+		case *ResourceType, *BufferType, *VmaType, *LenType, *TagType,
 			*FlagsType, *ConstType, *IntType, *ProcType, *CsumType:
 		default:
 			panic("unknown type")
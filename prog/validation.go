@@ -64,6 +64,9 @@ func (c *Call) validate(ctx *validCtx) error {
 				if _, ok := a.Type().(*LenType); ok {
 					break
 				}
+				if _, ok := a.Type().(*TagType); ok {
+					break
+				}
 				if a.Val != 0 && a.Val != a.Type().Default() {
 					return fmt.Errorf("syscall %v: output arg '%v'/'%v' has non default value '%+v'", c.Meta.Name, a.Type().FieldName(), a.Type().Name(), a)
 				}
@@ -0,0 +1,35 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+// AddFieldValues adds values discovered on the target machine for integer field fieldName
+// of struct structName (e.g. IDs of generic netlink families for type of netlink messages).
+// Generation prefers these values over random ones, because values of such fields
+// are allocated dynamically and are almost impossible to guess.
+// It is not thread-safe with respect to generation and mutation,
+// so it must be called before the target is used.
+func (target *Target) AddFieldValues(structName, fieldName string, vals []uint64) {
+	if len(vals) == 0 {
+		return
+	}
+	if target.fieldValues == nil {
+		target.fieldValues = make(map[Type][]uint64)
+	}
+	seen := make(map[Type]bool)
+	for _, c := range target.Syscalls {
+		ForeachType(c, func(t0 Type) {
+			t, ok := t0.(*StructType)
+			if !ok || t.Name() != structName {
+				return
+			}
+			for _, fld := range t.Fields {
+				if _, ok := fld.(*IntType); !ok || fld.FieldName() != fieldName || seen[fld] {
+					continue
+				}
+				seen[fld] = true
+				target.fieldValues[fld] = append(target.fieldValues[fld], vals...)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package prog
+
+import (
+	"testing"
+)
+
+func TestAddFieldValues(t *testing.T) {
+	target0, rs, iters := initTest(t)
+	// Use a copy of the target, since other tests use it concurrently.
+	target := new(Target)
+	*target = *target0
+	target.fieldValues = nil
+	const familyID = 0x1234
+	target.AddFieldValues("netlink_msg", "type", []uint64{familyID})
+	if len(target.fieldValues) == 0 {
+		t.Fatalf("no fields found for netlink_msg.type")
+	}
+	meta := target.SyscallMap["sendmsg$netlink"]
+	r := newRand(target, rs)
+	found := false
+	for i := 0; i < iters && !found; i++ {
+		s := newState(target, nil)
+		for _, c := range r.generateParticularCall(s, meta) {
+			foreachArg(c, func(arg, _ Arg, _ *[]Arg) {
+				if a, ok := arg.(*ConstArg); ok && target.fieldValues[a.Type()] != nil && a.Val == familyID {
+					found = true
+				}
+			})
+		}
+	}
+	if !found {
+		t.Fatalf("netlink_msg.type was never generated with the discovered value")
+	}
+}
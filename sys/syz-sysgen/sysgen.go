@@ -84,7 +84,7 @@ func main() {
 				fmt.Fprintf(out, "const revision_%v = %q\n", job.Target.Arch, rev)
 				writeSource(sysFile, out.Bytes())
 
-				job.ArchData = generateExecutorSyscalls(job.Target, prog.Syscalls, rev)
+				job.ArchData = generateExecutorSyscalls(job.Target, prog.Syscalls, consts, rev)
 				job.OK = true
 			}()
 		}
@@ -166,7 +166,8 @@ func generate(target *targets.Target, prg *compiler.Prog, consts map[string]uint
 	fmt.Fprintf(out, "\n\n")
 }
 
-func generateExecutorSyscalls(target *targets.Target, syscalls []*prog.Syscall, rev string) []byte {
+func generateExecutorSyscalls(target *targets.Target, syscalls []*prog.Syscall, consts map[string]uint64,
+	rev string) []byte {
 	type SyscallData struct {
 		Name     string
 		CallName string
@@ -191,10 +192,19 @@ func generateExecutorSyscalls(target *targets.Target, syscalls []*prog.Syscall,
 		if syz {
 			fake[c.CallName] = c.NR
 		}
+		nr := c.NR
+		if remap := target.SyscallRemap[c.CallName]; remap != "" {
+			val, ok := consts[target.SyscallPrefix+remap]
+			if !ok {
+				failf("%v/%v: no const for %v%v (remap of %v)",
+					target.OS, target.Arch, target.SyscallPrefix, remap, c.CallName)
+			}
+			nr = val
+		}
 		data.Calls = append(data.Calls, SyscallData{
 			Name:     c.Name,
 			CallName: c.CallName,
-			NR:       int32(c.NR),
+			NR:       int32(nr),
 			NeedCall: syz || !target.SyscallNumbers,
 		})
 	}
@@ -17,6 +17,10 @@ type Target struct {
 	KernelCrossCompile string
 	// NeedSyscallDefine is used by csource package to decide when to emit __NR_* defines.
 	NeedSyscallDefine func(nr uint64) bool
+	// SyscallRemap maps syscall names to names of syscalls that must be executed instead
+	// (both by executor and C programs), e.g. because the original has a different signature
+	// on this arch. The replacement must have the same arguments as the original description.
+	SyscallRemap map[string]string
 }
 
 type os struct {
@@ -29,6 +33,10 @@ type os struct {
 	ExecutorUsesShmem bool
 	// If ExecutorUsesForkServer, executor uses extended protocol with handshake.
 	ExecutorUsesForkServer bool
+	// Name of the dedicated common header used by csource package (executor/common_NAME.h).
+	// If empty, OSes with SyscallNumbers use the generic posix header,
+	// otherwise C programs are not supported.
+	CommonHeader string
 }
 
 var List = map[string]map[string]*Target{
@@ -55,6 +63,7 @@ var List = map[string]map[string]*Target{
 			CCompilerPrefix:  "x86_64-linux-gnu-",
 			KernelArch:       "i386",
 			KernelHeaderArch: "x86",
+			SyscallRemap:     remap32BitMmap,
 		},
 		"arm64": {
 			PtrSize:          8,
@@ -71,6 +80,7 @@ var List = map[string]map[string]*Target{
 			CCompilerPrefix:  "arm-linux-gnueabihf-",
 			KernelArch:       "arm",
 			KernelHeaderArch: "arm",
+			SyscallRemap:     remap32BitMmap,
 		},
 		"ppc64le": {
 			PtrSize:          8,
@@ -114,12 +124,19 @@ var List = map[string]map[string]*Target{
 	},
 }
 
+// On 32-bit linux mmap is old_mmap that takes a pointer to a struct with arguments,
+// mmap2 has the signature that we expect (with offset in pages).
+var remap32BitMmap = map[string]string{
+	"mmap": "mmap2",
+}
+
 var oses = map[string]os{
 	"linux": {
 		SyscallNumbers:         true,
 		SyscallPrefix:          "__NR_",
 		ExecutorUsesShmem:      true,
 		ExecutorUsesForkServer: true,
+		CommonHeader:           "linux",
 	},
 	"freebsd": {
 		SyscallNumbers:         true,
@@ -142,6 +159,7 @@ var oses = map[string]os{
 		SyscallPrefix:          "SYS_",
 		ExecutorUsesShmem:      false,
 		ExecutorUsesForkServer: false,
+		CommonHeader:           "akaros",
 	},
 }
 
@@ -0,0 +1,103 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// BuildCache holds kernel builds shared between managers.
+// Managers that track the same repo/branch with the same compiler, config
+// and userspace image reuse a single build instead of building the same
+// kernel several times. Builds are stored in builds/KEY dirs as hard links
+// to files in managers latest dirs, so cache eviction does not affect
+// running managers. The least recently used builds are removed when
+// the number of builds exceeds the limit.
+type BuildCache struct {
+	mu   sync.Mutex
+	dir  string
+	size int
+}
+
+// Files that are stored in the cache, tag is not stored because it is
+// rewritten by every manager and must not be shared between managers.
+var cachedFiles = []string{
+	"kernel.config",
+	"image",
+	"key",
+	"obj/vmlinux",
+}
+
+func newBuildCache(dir string, size int) *BuildCache {
+	if err := osutil.MkdirAll(dir); err != nil {
+		Fatal(err)
+	}
+	return &BuildCache{
+		dir:  dir,
+		size: size,
+	}
+}
+
+// Get links files of the build identified by key into dst dir.
+// Returns false if there is no such build.
+func (bc *BuildCache) Get(key, dst string) bool {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	dir := filepath.Join(bc.dir, key)
+	if !osutil.FilesExist(dir, cachedFiles) {
+		return false
+	}
+	if err := osutil.LinkFiles(dir, dst, cachedFiles); err != nil {
+		Logf(0, "failed to link cached build %v: %v", key, err)
+		return false
+	}
+	// Update modification time for LRU eviction.
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+	return true
+}
+
+// Put adds build in src dir to the cache under key.
+func (bc *BuildCache) Put(key, src string) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.size <= 0 {
+		return nil
+	}
+	dir := filepath.Join(bc.dir, key)
+	if err := osutil.LinkFiles(src, dir, cachedFiles); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("failed to cache build: %v", err)
+	}
+	bc.trim()
+	return nil
+}
+
+// trim removes the least recently used builds if there are more than bc.size of them.
+func (bc *BuildCache) trim() {
+	entries, err := ioutil.ReadDir(bc.dir)
+	if err != nil {
+		Logf(0, "failed to read build cache dir: %v", err)
+		return
+	}
+	if len(entries) <= bc.size {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for _, entry := range entries[:len(entries)-bc.size] {
+		Logf(0, "removing cached build %v", entry.Name())
+		os.RemoveAll(filepath.Join(bc.dir, entry.Name()))
+	}
+}
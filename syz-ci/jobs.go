@@ -0,0 +1,333 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	"github.com/google/syzkaller/pkg/bisect"
+	"github.com/google/syzkaller/pkg/config"
+	"github.com/google/syzkaller/pkg/git"
+	"github.com/google/syzkaller/pkg/kconfig"
+	"github.com/google/syzkaller/pkg/kernel"
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+)
+
+const (
+	jobPollPeriod = 10 * time.Minute
+	// How long we run the reproducer on a single kernel build.
+	reproTestDuration = 10 * time.Minute
+	reproTestAttempts = 3
+	// Max number of kernel configs tested during config minimization.
+	configMinimizeSteps = 50
+)
+
+// JobProcessor polls dashboard for jobs (fix bisection, patch testing, config minimization)
+// and executes them.
+// Jobs are executed sequentially and use a separate kernel checkout per manager
+// (managers/NAME/jobs/kernel), so they don't interfere with the main manager loop.
+type JobProcessor struct {
+	managers []*Manager
+	stop     chan struct{}
+}
+
+func newJobProcessor(managers []*Manager, stop chan struct{}) *JobProcessor {
+	jp := &JobProcessor{
+		stop: stop,
+	}
+	for _, mgr := range managers {
+		if mgr.dash != nil {
+			jp.managers = append(jp.managers, mgr)
+		}
+	}
+	return jp
+}
+
+func (jp *JobProcessor) loop() {
+	ticker := time.NewTicker(jobPollPeriod)
+	defer ticker.Stop()
+	for {
+		for _, mgr := range jp.managers {
+			jp.poll(mgr)
+		}
+		select {
+		case <-ticker.C:
+		case <-jp.stop:
+			return
+		}
+	}
+}
+
+func (jp *JobProcessor) poll(mgr *Manager) {
+	req, err := mgr.dash.JobPoll([]string{mgr.name})
+	if err != nil {
+		Logf(0, "%v: failed to poll jobs: %v", mgr.name, err)
+		return
+	}
+	if req.ID == "" {
+		return
+	}
+	job := &Job{
+		req: req,
+		mgr: mgr,
+		jp:  jp,
+		dir: osutil.Abs(filepath.Join("managers", mgr.mgrcfg.Name, "jobs")),
+		log: new(bytes.Buffer),
+	}
+	Logf(0, "%v: starting job %v for bug %q", mgr.name, req.ID, req.BugTitle)
+	done := &dashapi.JobDoneReq{
+		ID: req.ID,
+	}
+	var commits []*git.Commit
+	switch req.Type {
+	case dashapi.JobBisectFix:
+		commits, err = job.bisectFix()
+	case dashapi.JobTestPatch:
+		var com *git.Commit
+		com, done.CrashTitle, done.CrashReport, err = job.testPatch()
+		if com != nil {
+			commits = append(commits, com)
+		}
+	case dashapi.JobMinimizeConfig:
+		done.KernelConfig, err = job.minimizeConfig()
+	default:
+		err = fmt.Errorf("unknown job type %v", req.Type)
+	}
+	if err != nil {
+		job.logf("job failed: %v", err)
+		done.Error = []byte(err.Error())
+	}
+	for _, com := range commits {
+		done.Commits = append(done.Commits, dashapi.Commit{
+			Hash:   com.Hash,
+			Title:  com.Title,
+			Author: com.Author,
+			Date:   com.Date,
+		})
+	}
+	done.Log = job.log.Bytes()
+	if err := mgr.dash.JobDone(done); err != nil {
+		Logf(0, "%v: failed to mark job as done: %v", mgr.name, err)
+	}
+}
+
+// Job holds state of a single job execution.
+type Job struct {
+	req *dashapi.JobPollResp
+	mgr *Manager
+	jp  *JobProcessor
+	dir string
+	log *bytes.Buffer
+}
+
+func (job *Job) logf(msg string, args ...interface{}) {
+	Logf(0, "%v: "+msg, append([]interface{}{job.mgr.name}, args...)...)
+	fmt.Fprintf(job.log, "%v: %v\n", time.Now().Format("2006/01/02 15:04:05"), fmt.Sprintf(msg, args...))
+}
+
+// bisectFix searches for the commit that fixed the bug.
+// The bug reproduced on req.KernelCommit, so we first check that it does not
+// reproduce on the current branch head and then bisect between these two commits.
+func (job *Job) bisectFix() ([]*git.Commit, error) {
+	req := job.req
+	kernelDir := filepath.Join(job.dir, "kernel")
+	if err := osutil.MkdirAll(job.dir); err != nil {
+		return nil, fmt.Errorf("failed to create job dir: %v", err)
+	}
+	kernelConfig := filepath.Join(job.dir, "kernel.config")
+	if err := osutil.WriteFile(kernelConfig, req.KernelConfig); err != nil {
+		return nil, fmt.Errorf("failed to write kernel config: %v", err)
+	}
+	head, err := git.Poll(kernelDir, req.KernelRepo, req.KernelBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to poll %v/%v: %v", req.KernelRepo, req.KernelBranch, err)
+	}
+	cfg, err := job.bisectConfig(kernelDir, kernelConfig)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Good = req.KernelCommit
+	cfg.Bad = head
+	cfg.Fix = true
+	return bisect.Run(cfg)
+}
+
+// testPatch applies the patch on top of the requested branch and runs the reproducer.
+// Returns the tested commit and crash title/report if the patch did not fix the bug.
+func (job *Job) testPatch() (*git.Commit, string, []byte, error) {
+	req := job.req
+	kernelDir := filepath.Join(job.dir, "kernel")
+	if err := osutil.MkdirAll(job.dir); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to create job dir: %v", err)
+	}
+	kernelConfig := filepath.Join(job.dir, "kernel.config")
+	if err := osutil.WriteFile(kernelConfig, req.KernelConfig); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to write kernel config: %v", err)
+	}
+	head, err := git.Poll(kernelDir, req.KernelRepo, req.KernelBranch)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to poll %v/%v: %v", req.KernelRepo, req.KernelBranch, err)
+	}
+	com, err := git.GetCommit(kernelDir, head)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	job.logf("testing patch on %v %q", com.Hash, com.Title)
+	if err := git.Patch(kernelDir, req.Patch); err != nil {
+		return com, "", nil, err
+	}
+	cfg, err := job.bisectConfig(kernelDir, kernelConfig)
+	if err != nil {
+		return com, "", nil, err
+	}
+	// Any crash means that the patch did not fix the bug.
+	cfg.Title = ""
+	env, err := bisect.NewEnv(cfg)
+	if err != nil {
+		return com, "", nil, err
+	}
+	defer env.Close()
+	res, err := env.Test()
+	if err != nil {
+		return com, "", nil, err
+	}
+	switch res.Result {
+	case git.BisectSkip:
+		return com, "", nil, fmt.Errorf("failed to build kernel or run the reproducer")
+	case git.BisectNew:
+		return com, res.Title, res.Report, nil
+	}
+	job.logf("the reproducer did not trigger crash")
+	return com, "", nil, nil
+}
+
+// minimizeConfig searches for a minimal kernel config that still reproduces the bug
+// on the commit where the bug was found. Options enabled in defconfig are not touched.
+func (job *Job) minimizeConfig() ([]byte, error) {
+	req := job.req
+	kernelDir := filepath.Join(job.dir, "kernel")
+	if err := osutil.MkdirAll(job.dir); err != nil {
+		return nil, fmt.Errorf("failed to create job dir: %v", err)
+	}
+	if _, err := git.Poll(kernelDir, req.KernelRepo, req.KernelBranch); err != nil {
+		return nil, fmt.Errorf("failed to poll %v/%v: %v", req.KernelRepo, req.KernelBranch, err)
+	}
+	if err := git.Checkout(kernelDir, req.KernelCommit); err != nil {
+		return nil, err
+	}
+	full, err := kconfig.Parse(req.KernelConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kernel config: %v", err)
+	}
+	defconfig, err := kernel.DefConfig(kernelDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create defconfig: %v", err)
+	}
+	base, err := kconfig.Parse(defconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse defconfig: %v", err)
+	}
+	kernelConfig := filepath.Join(job.dir, "kernel.config")
+	cfg, err := job.bisectConfig(kernelDir, kernelConfig)
+	if err != nil {
+		return nil, err
+	}
+	env, err := bisect.NewEnv(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer env.Close()
+	pred := func(kcfg *kconfig.Config) (bool, error) {
+		if err := osutil.WriteFile(kernelConfig, kcfg.Serialize()); err != nil {
+			return false, fmt.Errorf("failed to write kernel config: %v", err)
+		}
+		res, err := env.Test()
+		if err != nil {
+			return false, err
+		}
+		return res.Result == git.BisectNew, nil
+	}
+	job.logf("minimizing config for %q on %v", req.BugTitle, req.KernelCommit)
+	if ok, err := pred(full); err != nil || !ok {
+		if err == nil {
+			err = fmt.Errorf("the bug does not reproduce with the original config")
+		}
+		return nil, err
+	}
+	minimized, err := kconfig.Minimize(base, full, configMinimizeSteps, pred)
+	if err != nil {
+		return nil, err
+	}
+	enabled := func(cfg *kconfig.Config) int {
+		n := 0
+		for _, opt := range cfg.Options {
+			if opt.Value != "" {
+				n++
+			}
+		}
+		return n
+	}
+	job.logf("minimized config from %v to %v enabled options", enabled(full), enabled(minimized))
+	return minimized.Serialize(), nil
+}
+
+// bisectConfig returns config for testing the job reproducer on kernels
+// built from kernelDir with kernelConfig. The C reproducer is used if there is one,
+// otherwise the syz reproducer is run with the options it was found with.
+func (job *Job) bisectConfig(kernelDir, kernelConfig string) (*bisect.Config, error) {
+	mgrcfg, err := job.createManagerConfig(kernelDir)
+	if err != nil {
+		return nil, err
+	}
+	return &bisect.Config{
+		Trace:        job.log,
+		KernelDir:    kernelDir,
+		KernelConfig: kernelConfig,
+		Compiler:     job.mgr.mgrcfg.Compiler,
+		Ccache:       job.mgr.cfg.Ccache,
+		Userspace:    job.mgr.mgrcfg.Userspace,
+		Cmdline:      job.mgr.mgrcfg.Kernel_Cmdline,
+		Sysctl:       job.mgr.mgrcfg.Kernel_Sysctl,
+		WorkDir:      job.dir,
+		Manager:      mgrcfg,
+		Repro:        job.req.ReproC,
+		ReproSyz:     job.req.ReproSyz,
+		ReproOpts:    job.req.ReproOpts,
+		Title:        job.req.BugTitle,
+		Attempts:     reproTestAttempts,
+		Duration:     reproTestDuration,
+		BuildSem:     kernelBuildSem,
+		Stop:         job.jp.stop,
+	}, nil
+}
+
+// createManagerConfig creates config for the job VMs, kernel and image paths are set by pkg/bisect.
+func (job *Job) createManagerConfig(kernelDir string) (*mgrconfig.Config, error) {
+	mgrcfg := mgrconfig.DefaultValues()
+	if err := config.LoadData(job.mgr.mgrcfg.Manager_Config, mgrcfg); err != nil {
+		return nil, err
+	}
+	if mgrcfg.Target == "" {
+		mgrcfg.Target = "linux/amd64"
+	}
+	// Use a different name, so that VMs don't clash with VMs of the main manager.
+	mgrcfg.Name = job.mgr.name + "-job"
+	mgrcfg.Workdir = filepath.Join(job.dir, "workdir")
+	mgrcfg.Vmlinux = filepath.Join(kernelDir, "vmlinux")
+	mgrcfg.Kernel_Src = kernelDir
+	mgrcfg.Syzkaller = filepath.FromSlash("syzkaller/current")
+	mgrcfg.Dashboard_Client = ""
+	mgrcfg.Hub_Client = ""
+	configFile := filepath.Join(job.dir, "manager.cfg")
+	if err := config.SaveFile(configFile, mgrcfg); err != nil {
+		return nil, err
+	}
+	return mgrconfig.LoadFile(configFile)
+}
@@ -49,14 +49,16 @@ type Manager struct {
 	latestDir  string
 	compilerID string
 	configTag  string
+	imageTag   string // identifies userspace image, kernel cmdline and sysctls
 	cfg        *Config
 	mgrcfg     *ManagerConfig
 	cmd        *ManagerCmd
 	dash       *dashapi.Dashboard
+	buildCache *BuildCache
 	stop       chan struct{}
 }
 
-func createManager(cfg *Config, mgrcfg *ManagerConfig, stop chan struct{}) *Manager {
+func createManager(cfg *Config, mgrcfg *ManagerConfig, buildCache *BuildCache, stop chan struct{}) *Manager {
 	dir := osutil.Abs(filepath.Join("managers", mgrcfg.Name))
 	if err := osutil.MkdirAll(dir); err != nil {
 		Fatal(err)
@@ -76,6 +78,17 @@ func createManager(cfg *Config, mgrcfg *ManagerConfig, stop chan struct{}) *Mana
 	if err != nil {
 		Fatal(err)
 	}
+	imageData := []byte(osutil.Abs(mgrcfg.Userspace))
+	for _, file := range []string{mgrcfg.Kernel_Cmdline, mgrcfg.Kernel_Sysctl} {
+		if file == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			Fatal(err)
+		}
+		imageData = append(imageData, data...)
+	}
 
 	mgr := &Manager{
 		name:       cfg.Name + "-" + mgrcfg.Name,
@@ -85,9 +98,11 @@ func createManager(cfg *Config, mgrcfg *ManagerConfig, stop chan struct{}) *Mana
 		latestDir:  filepath.Join(dir, "latest"),
 		compilerID: compilerID,
 		configTag:  hash.String(configData),
+		imageTag:   hash.String(imageData),
 		cfg:        cfg,
 		mgrcfg:     mgrcfg,
 		dash:       dash,
+		buildCache: buildCache,
 		stop:       stop,
 	}
 	os.RemoveAll(mgr.currentDir)
@@ -214,8 +229,17 @@ func (mgr *Manager) build() error {
 	if err != nil {
 		return fmt.Errorf("failed to get git HEAD commit: %v", err)
 	}
-	if err := kernel.Build(mgr.kernelDir, mgr.mgrcfg.Compiler, mgr.mgrcfg.Kernel_Config); err != nil {
-		return fmt.Errorf("kernel build failed: %v", err)
+	var tagData []byte
+	tagData = append(tagData, kernelCommit...)
+	tagData = append(tagData, mgr.compilerID...)
+	tagData = append(tagData, mgr.configTag...)
+	info := &BuildInfo{
+		Tag:             hash.String(tagData),
+		CompilerID:      mgr.compilerID,
+		KernelRepo:      mgr.mgrcfg.Repo,
+		KernelBranch:    mgr.mgrcfg.Branch,
+		KernelCommit:    kernelCommit,
+		KernelConfigTag: mgr.configTag,
 	}
 
 	// We first form the whole image in tmp dir and then rename it to latest.
@@ -227,8 +251,40 @@ func (mgr *Manager) build() error {
 		return fmt.Errorf("failed to create tmp dir: %v", err)
 	}
 
-	image := filepath.Join(tmpDir, "image")
-	key := filepath.Join(tmpDir, "key")
+	// Another manager may have already built exactly the same kernel and image.
+	cacheKey := hash.String([]byte(info.Tag + mgr.imageTag))
+	if mgr.buildCache.Get(cacheKey, tmpDir) {
+		Logf(0, "%v: reusing cached kernel build %v", mgr.name, cacheKey)
+	} else {
+		if err := mgr.buildImage(tmpDir); err != nil {
+			return err
+		}
+		if err := mgr.buildCache.Put(cacheKey, tmpDir); err != nil {
+			// This is not critical for operation.
+			Logf(0, "%v: %v", mgr.name, err)
+		}
+	}
+
+	info.Time = time.Now()
+	if err := config.SaveFile(filepath.Join(tmpDir, "tag"), info); err != nil {
+		return fmt.Errorf("failed to write tag file: %v", err)
+	}
+
+	// Now try to replace latest with our tmp dir as atomically as we can get on Linux.
+	if err := os.RemoveAll(mgr.latestDir); err != nil {
+		return fmt.Errorf("failed to remove latest dir: %v", err)
+	}
+	return os.Rename(tmpDir, mgr.latestDir)
+}
+
+// buildImage builds kernel and image and stores all build files (except for tag) in dir.
+func (mgr *Manager) buildImage(dir string) error {
+	err := kernel.Build(mgr.kernelDir, mgr.mgrcfg.Compiler, mgr.cfg.Ccache, mgr.mgrcfg.Kernel_Config)
+	if err != nil {
+		return fmt.Errorf("kernel build failed: %v", err)
+	}
+	image := filepath.Join(dir, "image")
+	key := filepath.Join(dir, "key")
 	err = kernel.CreateImage(mgr.kernelDir, mgr.mgrcfg.Userspace,
 		mgr.mgrcfg.Kernel_Cmdline, mgr.mgrcfg.Kernel_Sysctl, image, key)
 	if err != nil {
@@ -237,38 +293,13 @@ func (mgr *Manager) build() error {
 	// TODO(dvyukov): test that the image is good (boots and we can ssh into it).
 
 	vmlinux := filepath.Join(mgr.kernelDir, "vmlinux")
-	objDir := filepath.Join(tmpDir, "obj")
+	objDir := filepath.Join(dir, "obj")
 	osutil.MkdirAll(objDir)
 	if err := os.Rename(vmlinux, filepath.Join(objDir, "vmlinux")); err != nil {
 		return fmt.Errorf("failed to rename vmlinux file: %v", err)
 	}
-	kernelConfig := filepath.Join(tmpDir, "kernel.config")
-	if err := osutil.CopyFile(mgr.mgrcfg.Kernel_Config, kernelConfig); err != nil {
-		return err
-	}
-
-	var tagData []byte
-	tagData = append(tagData, kernelCommit...)
-	tagData = append(tagData, mgr.compilerID...)
-	tagData = append(tagData, mgr.configTag...)
-	info := &BuildInfo{
-		Time:            time.Now(),
-		Tag:             hash.String(tagData),
-		CompilerID:      mgr.compilerID,
-		KernelRepo:      mgr.mgrcfg.Repo,
-		KernelBranch:    mgr.mgrcfg.Branch,
-		KernelCommit:    kernelCommit,
-		KernelConfigTag: mgr.configTag,
-	}
-	if err := config.SaveFile(filepath.Join(tmpDir, "tag"), info); err != nil {
-		return fmt.Errorf("failed to write tag file: %v", err)
-	}
-
-	// Now try to replace latest with our tmp dir as atomically as we can get on Linux.
-	if err := os.RemoveAll(mgr.latestDir); err != nil {
-		return fmt.Errorf("failed to remove latest dir: %v", err)
-	}
-	return os.Rename(tmpDir, mgr.latestDir)
+	kernelConfig := filepath.Join(dir, "kernel.config")
+	return osutil.CopyFile(mgr.mgrcfg.Kernel_Config, kernelConfig)
 }
 
 func (mgr *Manager) restartManager() {
@@ -12,6 +12,7 @@ package main
 // 2 main components:
 //  - SyzUpdater: handles syzkaller updates
 //  - Manager: handles kernel build and syz-manager process (one per manager)
+//  - JobProcessor: executes jobs requested by dashboard (e.g. fix bisection)
 // Both operate in a similar way and keep 2 builds:
 //  - latest: latest known good build (i.e. we tested it)
 //    preserved across restarts/reboots, i.e. we can start fuzzing even when
@@ -41,6 +42,10 @@ package main
 //		workdir/	: manager workdir (never deleted)
 //		latest/		: latest good kernel image build
 //		current/	: kernel image currently in use
+//		jobs/		: kernel checkout, image and workdir used by jobs
+// builds/
+//	KEY/			: kernel builds shared between managers with identical
+//				  kernel commit, compiler, config and userspace image
 //
 // Current executable, syzkaller and kernel builds are marked with tag files.
 // Tag files uniquely identify the build (git hash, compiler identity, kernel config, etc).
@@ -52,6 +57,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/exec"
 	"sync"
 
 	"github.com/google/syzkaller/pkg/config"
@@ -72,6 +78,9 @@ type Config struct {
 	Syzkaller_Repo         string
 	Syzkaller_Branch       string
 	Syzkaller_Descriptions string // Dir with additional syscall descriptions (.txt and .const files).
+	Jobs                   bool   // Execute jobs requested by dashboard (e.g. fix bisection), optional.
+	Ccache                 string // Path to ccache binary, enables incremental kernel builds (optional).
+	Build_Cache_Size       int    // Number of kernel builds shared between managers (default 10).
 	Managers               []*ManagerConfig
 }
 
@@ -120,8 +129,9 @@ func main() {
 	var wg sync.WaitGroup
 	wg.Add(len(cfg.Managers))
 	managers := make([]*Manager, len(cfg.Managers))
+	buildCache := newBuildCache(osutil.Abs("builds"), cfg.Build_Cache_Size)
 	for i, mgrcfg := range cfg.Managers {
-		managers[i] = createManager(cfg, mgrcfg, stop)
+		managers[i] = createManager(cfg, mgrcfg, buildCache, stop)
 	}
 	for _, mgr := range managers {
 		mgr := mgr
@@ -130,6 +140,14 @@ func main() {
 			mgr.loop()
 		}()
 	}
+	if cfg.Jobs && cfg.Dashboard_Addr != "" {
+		jp := newJobProcessor(managers, stop)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jp.loop()
+		}()
+	}
 
 	<-stop
 	wg.Wait()
@@ -146,6 +164,7 @@ func loadConfig(filename string) (*Config, error) {
 		Syzkaller_Repo:   "https://github.com/google/syzkaller.git",
 		Syzkaller_Branch: "master",
 		Goroot:           os.Getenv("GOROOT"),
+		Build_Cache_Size: 10,
 	}
 	if err := config.LoadFile(filename, cfg); err != nil {
 		return nil, err
@@ -159,10 +178,20 @@ func loadConfig(filename string) (*Config, error) {
 	if len(cfg.Managers) == 0 {
 		return nil, fmt.Errorf("no managers specified")
 	}
+	if cfg.Ccache != "" {
+		if _, err := exec.LookPath(cfg.Ccache); err != nil {
+			return nil, fmt.Errorf("bad param 'ccache': %v", err)
+		}
+	}
+	names := make(map[string]bool)
 	for i, mgr := range cfg.Managers {
 		if mgr.Name == "" {
 			return nil, fmt.Errorf("param 'managers[%v].name' is empty", i)
 		}
+		if names[mgr.Name] {
+			return nil, fmt.Errorf("duplicate manager name %v", mgr.Name)
+		}
+		names[mgr.Name] = true
 		mgrcfg := new(mgrconfig.Config)
 		if err := config.LoadData(mgr.Manager_Config, mgrcfg); err != nil {
 			return nil, fmt.Errorf("manager %v: %v", mgr.Name, err)
@@ -24,6 +24,7 @@ import (
 	"github.com/google/syzkaller/pkg/hash"
 	"github.com/google/syzkaller/pkg/host"
 	"github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/pkg/kmemleak"
 	. "github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/osutil"
 	. "github.com/google/syzkaller/pkg/rpctype"
@@ -38,12 +39,20 @@ var (
 	flagManager  = flag.String("manager", "", "manager rpc address")
 	flagProcs    = flag.Int("procs", 1, "number of parallel test processes")
 	flagLeak     = flag.Bool("leak", false, "detect memory leaks")
+	flagLeakReq  = flag.Bool("leak_request", false, "scan for memory leaks only when requested by manager")
 	flagOutput   = flag.String("output", "stdout", "write programs to none/stdout/dmesg/file")
 	flagPprof    = flag.String("pprof", "", "address to serve pprof profiles")
+	flagFault    = flag.Bool("fault_fuzz", false, "systematically inject faults into new corpus programs")
 )
 
 const (
 	programLength = 30
+	// Number of executions during triage, only signal present in all of them is considered stable.
+	triageRuns = 3
+	// Programs with less than this percent of stable signal are considered flaky.
+	flakyStability = 50
+	// Flaky programs are chosen for mutation once per this number of mutations.
+	flakyMutateRatio = 10
 )
 
 type Input struct {
@@ -51,11 +60,18 @@ type Input struct {
 	call      int
 	signal    []uint32
 	minimized bool
+	// The input gives new signal only with a fault injected into call/faultNth.
+	fault    bool
+	faultNth int
 }
 
 type Candidate struct {
 	p         *prog.Prog
 	minimized bool
+	// Fault injected into the program, see prog.FaultComment.
+	fault     bool
+	faultCall int
+	faultNth  int
 }
 
 var (
@@ -67,18 +83,34 @@ var (
 	maxSignal    map[uint32]struct{}
 	newSignal    map[uint32]struct{}
 
-	corpusMu     sync.RWMutex
-	corpus       []*prog.Prog
-	corpusHashes map[hash.Sig]struct{}
+	corpusMu sync.RWMutex
+	corpus   []*prog.Prog
+	// Indices of calls that gave new signal for each corpus program,
+	// mutations are focused on these calls.
+	corpusCalls  [][]int
+	corpusHashes map[hash.Sig]int // index in corpus
+	// Programs with unstable signal (see triageInput) are kept in a separate pool,
+	// which is mutated less often, so that they don't add noise to the corpus.
+	flakyCorpus       []*prog.Prog
+	flakyCorpusCalls  [][]int
+	flakyCorpusHashes map[hash.Sig]int
 
 	triageMu        sync.RWMutex
 	triage          []Input
 	triageCandidate []Input
 	candidates      []Candidate
 	smashQueue      []Input
+	faultQueue      []*prog.Prog // corpus programs to systematically inject faults into
 
 	gate *ipc.Gate
 
+	// The choice table is rebuilt when manager disables/enables syscalls at runtime.
+	ctMu         sync.RWMutex
+	ct           *prog.ChoiceTable
+	prios        [][]float32
+	callWeights  []float32              // per-syscall weights sent by manager (nil if not used)
+	enabledCalls map[*prog.Syscall]bool // calls enabled in config and supported by the machine
+
 	statExecGen       uint64
 	statExecFuzz      uint64
 	statExecCandidate uint64
@@ -86,13 +118,18 @@ var (
 	statExecMinimize  uint64
 	statExecSmash     uint64
 	statNewInput      uint64
+	statFlakyInput    uint64
 	statExecHints     uint64
 	statExecHintSeeds uint64
+	statExecFault     uint64
+	statExecLeak      uint64
 
 	allTriaged            uint32
+	leakScanRequested     uint32 // manager requested a memory leak scan (with -leak_request)
 	noCover               bool
 	faultInjectionEnabled bool
 	compsSupported        bool
+	detectResourceLeaks   bool
 )
 
 func main() {
@@ -133,7 +170,8 @@ func main() {
 	corpusSignal = make(map[uint32]struct{})
 	maxSignal = make(map[uint32]struct{})
 	newSignal = make(map[uint32]struct{})
-	corpusHashes = make(map[hash.Sig]struct{})
+	corpusHashes = make(map[hash.Sig]int)
+	flakyCorpusHashes = make(map[hash.Sig]int)
 
 	Logf(0, "dialing manager at %v", *flagManager)
 	a := &ConnectArgs{*flagName}
@@ -142,7 +180,10 @@ func main() {
 		panic(err)
 	}
 	calls := buildCallList(target, r.EnabledCalls)
-	ct := target.BuildChoiceTable(r.Prios, calls)
+	enabledCalls = calls
+	prios = r.Prios
+	callWeights = r.CallWeights
+	updateChoiceTable(r.DisabledCalls)
 	for _, inp := range r.Inputs {
 		addInput(inp)
 	}
@@ -150,19 +191,7 @@ func main() {
 		maxSignal[s] = struct{}{}
 	}
 	for _, candidate := range r.Candidates {
-		p, err := target.Deserialize(candidate.Prog)
-		if err != nil {
-			panic(err)
-		}
-		if noCover {
-			corpusMu.Lock()
-			corpus = append(corpus, p)
-			corpusMu.Unlock()
-		} else {
-			triageMu.Lock()
-			candidates = append(candidates, Candidate{p, candidate.Minimized})
-			triageMu.Unlock()
-		}
+		addCandidate(candidate)
 	}
 
 	// This requires "fault-inject: support systematic fault injection" kernel commit.
@@ -216,7 +245,22 @@ func main() {
 		manager = conn
 	}
 
-	kmemleakInit()
+	if err := kmemleak.Init(*flagLeak); err != nil {
+		Fatalf("BUG: %v", err)
+	}
+
+	if paths := collectPaths(); len(paths) != 0 {
+		Logf(1, "collected %v paths for filename mutation", len(paths))
+		target.AddPaths(paths)
+	}
+	if families := collectNetlinkFamilies(); len(families) != 0 {
+		Logf(1, "discovered %v generic netlink families", len(families))
+		var ids []uint64
+		for _, id := range families {
+			ids = append(ids, id)
+		}
+		target.AddFieldValues("netlink_msg", "type", ids)
+	}
 
 	config, err := ipc.DefaultConfig()
 	if err != nil {
@@ -232,11 +276,16 @@ func main() {
 		config.Flags |= ipc.FlagEnableFault
 	}
 	noCover = config.Flags&ipc.FlagSignal == 0
+	detectResourceLeaks = config.Flags&ipc.FlagResourceLeaks != 0
 	leakCallback := func() {
-		if atomic.LoadUint32(&allTriaged) != 0 {
-			// Scan for leaks once in a while (it is damn slow).
-			kmemleakScan(true)
+		if atomic.LoadUint32(&allTriaged) == 0 {
+			return
 		}
+		if *flagLeakReq && atomic.SwapUint32(&leakScanRequested, 0) == 0 {
+			return
+		}
+		// Scan for leaks once in a while (it is damn slow).
+		kmemleakScan(true)
 	}
 	if !*flagLeak {
 		leakCallback = nil
@@ -259,7 +308,8 @@ func main() {
 
 			for i := 0; ; i++ {
 				triageMu.RLock()
-				if len(triageCandidate) != 0 || len(candidates) != 0 || len(triage) != 0 || len(smashQueue) != 0 {
+				if len(triageCandidate) != 0 || len(candidates) != 0 || len(triage) != 0 ||
+					len(smashQueue) != 0 || len(faultQueue) != 0 {
 					triageMu.RUnlock()
 					triageMu.Lock()
 					if len(triageCandidate) != 0 {
@@ -283,7 +333,13 @@ func main() {
 							}
 						}
 						Logf(1, "executing candidate: %s", candidate.p)
-						execute(pid, env, candidate.p, false, false, candidate.minimized, true, &statExecCandidate)
+						opts := &ipc.ExecOpts{}
+						if candidate.fault {
+							opts.Flags |= ipc.FlagInjectFault
+							opts.FaultCall = candidate.faultCall
+							opts.FaultNth = candidate.faultNth
+						}
+						executeOpts(pid, env, opts, candidate.p, candidate.minimized, true, &statExecCandidate)
 						continue
 					} else if len(triage) != 0 {
 						last := len(triage) - 1
@@ -299,7 +355,15 @@ func main() {
 						smashQueue = smashQueue[:last]
 						triageMu.Unlock()
 						Logf(1, "%v: smashing call %v in program: %v", pid, inp.call, inp.p.String())
-						smashInput(pid, env, ct, rs, inp)
+						smashInput(pid, env, choiceTable(), rs, inp)
+						continue
+					} else if len(faultQueue) != 0 {
+						last := len(faultQueue) - 1
+						p := faultQueue[last]
+						faultQueue = faultQueue[:last]
+						triageMu.Unlock()
+						Logf(1, "%v: fault fuzzing program: %v", pid, p)
+						faultFuzz(pid, env, p)
 						continue
 					} else {
 						triageMu.Unlock()
@@ -309,17 +373,23 @@ func main() {
 				}
 
 				corpusMu.RLock()
-				if len(corpus) == 0 || i%100 == 0 {
+				if len(corpus) == 0 && len(flakyCorpus) == 0 || i%100 == 0 {
 					// Generate a new prog.
 					corpusMu.RUnlock()
-					p := target.Generate(rnd, programLength, ct)
+					p := target.Generate(rnd, programLength, choiceTable())
 					Logf(1, "#%v: generated: %s", i, p)
 					execute(pid, env, p, false, false, false, false, &statExecGen)
 				} else {
 					// Mutate an existing prog.
-					p := corpus[rnd.Intn(len(corpus))].Clone()
+					progs, calls := corpus, corpusCalls
+					if len(flakyCorpus) != 0 && (len(corpus) == 0 || rnd.Intn(flakyMutateRatio) == 0) {
+						progs, calls = flakyCorpus, flakyCorpusCalls
+					}
+					idx := rnd.Intn(len(progs))
+					p := progs[idx].Clone()
+					focus := calls[idx]
 					corpusMu.RUnlock()
-					p.Mutate(rs, programLength, ct, corpus)
+					p.MutateFocused(rs, programLength, choiceTable(), corpus, focus)
 					Logf(1, "#%v: mutated: %s", i, p)
 					execute(pid, env, p, false, false, false, false, &statExecFuzz)
 				}
@@ -384,7 +454,14 @@ func main() {
 			execSmash := atomic.SwapUint64(&statExecSmash, 0)
 			a.Stats["exec smash"] = execSmash
 			execTotal += execSmash
+			execFault := atomic.SwapUint64(&statExecFault, 0)
+			a.Stats["exec fault"] = execFault
+			execTotal += execFault
+			execLeak := atomic.SwapUint64(&statExecLeak, 0)
+			a.Stats["exec leak"] = execLeak
+			execTotal += execLeak
 			a.Stats["fuzzer new inputs"] = atomic.SwapUint64(&statNewInput, 0)
+			a.Stats["fuzzer flaky inputs"] = atomic.SwapUint64(&statFlakyInput, 0)
 			r := &PollRes{}
 			if err := manager.Call("Manager.Poll", a, r); err != nil {
 				panic(err)
@@ -396,23 +473,20 @@ func main() {
 				}
 				signalMu.Unlock()
 			}
+			if r.LeakScan {
+				atomic.StoreUint32(&leakScanRequested, 1)
+			}
+			if r.UpdateCalls {
+				if r.CallWeights != nil {
+					callWeights = r.CallWeights
+				}
+				updateChoiceTable(r.DisabledCalls)
+			}
 			for _, inp := range r.NewInputs {
 				addInput(inp)
 			}
 			for _, candidate := range r.Candidates {
-				p, err := target.Deserialize(candidate.Prog)
-				if err != nil {
-					panic(err)
-				}
-				if noCover {
-					corpusMu.Lock()
-					corpus = append(corpus, p)
-					corpusMu.Unlock()
-				} else {
-					triageMu.Lock()
-					candidates = append(candidates, Candidate{p, candidate.Minimized})
-					triageMu.Unlock()
-				}
+				addCandidate(candidate)
 			}
 			if len(r.Candidates) == 0 && atomic.LoadUint32(&allTriaged) == 0 {
 				if *flagLeak {
@@ -464,6 +538,77 @@ func buildCallList(target *prog.Target, enabledCalls string) map[*prog.Syscall]b
 	return calls
 }
 
+// updateChoiceTable rebuilds the choice table for enabledCalls without the disabled calls,
+// so that new programs don't use them. Programs already in corpus can still contain them.
+// Priorities of choosing syscalls are scaled by callWeights.
+func updateChoiceTable(disabled []string) {
+	calls := make(map[*prog.Syscall]bool)
+	for c := range enabledCalls {
+		calls[c] = true
+	}
+	for _, name := range disabled {
+		delete(calls, target.SyscallMap[name])
+	}
+	trans := target.TransitivelyEnabledCalls(calls)
+	for c := range calls {
+		if !trans[c] {
+			delete(calls, c)
+		}
+	}
+	if len(calls) == 0 {
+		Logf(0, "all syscalls are disabled, ignoring the update")
+		return
+	}
+	newCT := target.BuildChoiceTable(weightPrios(prios, callWeights), calls)
+	ctMu.Lock()
+	ct = newCT
+	ctMu.Unlock()
+	if len(disabled) != 0 {
+		Logf(0, "%v syscalls enabled, %v disabled at runtime", len(calls), len(enabledCalls)-len(calls))
+	}
+}
+
+// weightPrios returns a copy of prios with the priority of choosing every syscall scaled by its weight.
+func weightPrios(prios [][]float32, weights []float32) [][]float32 {
+	if prios == nil || len(weights) != len(prios) {
+		return prios
+	}
+	res := make([][]float32, len(prios))
+	for i, row := range prios {
+		res[i] = make([]float32, len(row))
+		for j, prio := range row {
+			res[i][j] = prio * weights[j]
+		}
+	}
+	return res
+}
+
+func choiceTable() *prog.ChoiceTable {
+	ctMu.RLock()
+	defer ctMu.RUnlock()
+	return ct
+}
+
+func addCandidate(candidate RpcCandidate) {
+	p, err := target.Deserialize(candidate.Prog)
+	if err != nil {
+		panic(err)
+	}
+	if noCover {
+		corpusMu.Lock()
+		addToCorpusLocked(p, hash.Hash(candidate.Prog), -1, false)
+		corpusMu.Unlock()
+		return
+	}
+	cand := Candidate{p: p, minimized: candidate.Minimized}
+	if call, nth, ok := prog.ParseFaultComment(candidate.Prog); ok && call < len(p.Calls) {
+		cand.fault, cand.faultCall, cand.faultNth = true, call, nth
+	}
+	triageMu.Lock()
+	candidates = append(candidates, cand)
+	triageMu.Unlock()
+}
+
 func addInput(inp RpcInput) {
 	corpusMu.Lock()
 	defer corpusMu.Unlock()
@@ -477,24 +622,50 @@ func addInput(inp RpcInput) {
 	if err != nil {
 		panic(err)
 	}
-	sig := hash.Hash(inp.Prog)
-	if _, ok := corpusHashes[sig]; !ok {
-		corpus = append(corpus, p)
-		corpusHashes[sig] = struct{}{}
-	}
+	// Stability is 0 for inputs that were not triaged with stability check.
+	flaky := inp.Stability != 0 && inp.Stability < flakyStability
+	addToCorpusLocked(p, hash.Hash(inp.Prog), inp.CallIndex, flaky)
 	if diff := cover.SignalDiff(maxSignal, inp.Signal); len(diff) != 0 {
 		cover.SignalAdd(corpusSignal, diff)
 		cover.SignalAdd(maxSignal, diff)
 	}
 }
 
+// addToCorpusLocked adds p to corpus or to the flaky pool (if it is not there yet)
+// and records that call of p gave new signal (if call is not -1).
+// Programs already present in corpus are never moved to the flaky pool.
+// Must be called with corpusMu held.
+func addToCorpusLocked(p *prog.Prog, sig hash.Sig, call int, flaky bool) {
+	progs, calls, hashes := &corpus, &corpusCalls, corpusHashes
+	if _, ok := corpusHashes[sig]; flaky && !ok {
+		progs, calls, hashes = &flakyCorpus, &flakyCorpusCalls, flakyCorpusHashes
+	}
+	idx, ok := hashes[sig]
+	if !ok {
+		idx = len(*progs)
+		*progs = append(*progs, p)
+		*calls = append(*calls, nil)
+		hashes[sig] = idx
+	}
+	if call < 0 || call >= len(p.Calls) {
+		return
+	}
+	for _, call1 := range (*calls)[idx] {
+		if call1 == call {
+			return
+		}
+	}
+	(*calls)[idx] = append((*calls)[idx], call)
+}
+
 func smashInput(pid int, env *ipc.Env, ct *prog.ChoiceTable, rs rand.Source, inp Input) {
-	if faultInjectionEnabled {
+	if faultInjectionEnabled && !*flagFault {
+		// With fault fuzzing all calls of the program are failed in faultFuzz.
 		failCall(pid, env, inp.p, inp.call)
 	}
 	for i := 0; i < 100; i++ {
 		p := inp.p.Clone()
-		p.Mutate(rs, programLength, ct, corpus)
+		p.MutateFocused(rs, programLength, ct, corpus, []int{inp.call})
 		Logf(1, "#%v: mutated: %s", pid, p)
 		execute(pid, env, p, false, false, false, false, &statExecSmash)
 	}
@@ -518,6 +689,25 @@ func failCall(pid int, env *ipc.Env, p *prog.Prog, call int) {
 	}
 }
 
+// faultFuzz injects faults into every call of p, one allocation at a time,
+// until the call does not reach the next allocation. Executions where the faulted call
+// gives new signal are triaged with the same fault, so error paths end up in corpus.
+func faultFuzz(pid int, env *ipc.Env, p *prog.Prog) {
+	for call := range p.Calls {
+		for nth := 0; nth < 100; nth++ {
+			opts := &ipc.ExecOpts{
+				Flags:     ipc.FlagInjectFault,
+				FaultCall: call,
+				FaultNth:  nth,
+			}
+			info := executeOpts(pid, env, opts, p, false, false, &statExecFault)
+			if info != nil && len(info) > call && !info[call].FaultInjected {
+				break
+			}
+		}
+	}
+}
+
 func triageInput(pid int, env *ipc.Env, inp Input) {
 	if noCover {
 		panic("should not be called when coverage is disabled")
@@ -532,28 +722,47 @@ func triageInput(pid int, env *ipc.Env, inp Input) {
 	newSignal = cover.Canonicalize(newSignal)
 
 	call := inp.p.Calls[inp.call].Meta
-	data := inp.p.Serialize()
+	data := serializeInput(inp)
 	sig := hash.Hash(data)
 
 	Logf(3, "triaging input for %v (new signal=%v):\n%s", call.CallName, len(newSignal), data)
 	var inputCover cover.Cover
+	var errno int
+	var duration time.Duration
+	record := func(inf ipc.CallInfo) {
+		errno = inf.Errno
+		if duration < inf.Duration {
+			duration = inf.Duration
+		}
+	}
 	opts := &ipc.ExecOpts{
 		Flags: ipc.FlagCollectCover,
 	}
+	if inp.fault {
+		opts.Flags |= ipc.FlagInjectFault
+		opts.FaultCall = inp.call
+		opts.FaultNth = inp.faultNth
+	}
+	// Percent of the call signal that was present in all triage runs.
+	stability := 100
 	if inp.minimized {
 		// We just need to get input coverage.
-		for i := 0; i < 3; i++ {
+		for i := 0; i < triageRuns; i++ {
 			info := execute1(pid, env, opts, inp.p, &statExecTriage)
 			if len(info) == 0 || len(info[inp.call].Cover) == 0 {
 				continue // The call was not executed. Happens sometimes.
 			}
 			inputCover = append([]uint32{}, info[inp.call].Cover...)
+			record(info[inp.call])
 			break
 		}
 	} else {
 		// We need to compute input coverage and non-flaky signal for minimization.
+		// Only signal that is present in all runs is added to corpus.
+		stableSignal := cover.Canonicalize(inp.signal)
+		allSignal := stableSignal
 		notexecuted := false
-		for i := 0; i < 3; i++ {
+		for i := 0; i < triageRuns; i++ {
 			info := execute1(pid, env, opts, inp.p, &statExecTriage)
 			if len(info) == 0 || len(info[inp.call].Signal) == 0 {
 				// The call was not executed. Happens sometimes.
@@ -564,19 +773,32 @@ func triageInput(pid int, env *ipc.Env, inp Input) {
 				continue
 			}
 			inf := info[inp.call]
-			newSignal = cover.Intersection(newSignal, cover.Canonicalize(inf.Signal))
+			record(inf)
+			signal := cover.Canonicalize(inf.Signal)
+			newSignal = cover.Intersection(newSignal, signal)
 			if len(newSignal) == 0 {
 				return
 			}
+			stableSignal = cover.Intersection(stableSignal, signal)
+			allSignal = cover.Union(allSignal, signal)
 			if len(inputCover) == 0 {
 				inputCover = append([]uint32{}, inf.Cover...)
 			} else {
 				inputCover = cover.Union(inputCover, inf.Cover)
 			}
 		}
+		inp.signal = stableSignal
+		stability = len(stableSignal) * 100 / len(allSignal)
 
+		// The fault is always injected into the minimized call, so it follows the call index.
 		inp.p, inp.call = prog.Minimize(inp.p, inp.call, func(p1 *prog.Prog, call1 int) bool {
-			info := execute(pid, env, p1, false, false, false, false, &statExecMinimize)
+			opts := &ipc.ExecOpts{}
+			if inp.fault {
+				opts.Flags |= ipc.FlagInjectFault
+				opts.FaultCall = call1
+				opts.FaultNth = inp.faultNth
+			}
+			info := executeOpts(pid, env, opts, p1, false, false, &statExecMinimize)
 			if len(info) == 0 || len(info[call1].Signal) == 0 {
 				return false // The call was not executed.
 			}
@@ -591,15 +813,23 @@ func triageInput(pid int, env *ipc.Env, inp Input) {
 		}, false)
 	}
 
+	flaky := stability < flakyStability
 	atomic.AddUint64(&statNewInput, 1)
-	Logf(2, "added new input for %v to corpus:\n%s", call.CallName, data)
+	if flaky {
+		atomic.AddUint64(&statFlakyInput, 1)
+	}
+	Logf(2, "added new input for %v to corpus (stability %v%%):\n%s", call.CallName, stability, data)
 	a := &NewInputArgs{
 		Name: *flagName,
 		RpcInput: RpcInput{
-			Call:   call.CallName,
-			Prog:   data,
-			Signal: []uint32(cover.Canonicalize(inp.signal)),
-			Cover:  []uint32(inputCover),
+			Call:      call.CallName,
+			CallIndex: inp.call,
+			Prog:      data,
+			Signal:    []uint32(cover.Canonicalize(inp.signal)),
+			Cover:     []uint32(inputCover),
+			Errno:     errno,
+			Duration:  duration,
+			Stability: stability,
 		},
 	}
 	if err := manager.Call("Manager.NewInput", a, nil); err != nil {
@@ -611,16 +841,68 @@ func triageInput(pid int, env *ipc.Env, inp Input) {
 	signalMu.Unlock()
 
 	corpusMu.Lock()
-	if _, ok := corpusHashes[sig]; !ok {
-		corpus = append(corpus, inp.p)
-		corpusHashes[sig] = struct{}{}
-	}
+	addToCorpusLocked(inp.p, sig, inp.call, flaky)
 	corpusMu.Unlock()
 
-	if !inp.minimized {
-		triageMu.Lock()
+	if inp.fault {
+		// Resource leaks are checked and faults are injected when the program
+		// is triaged without the fault.
+		return
+	}
+	if detectResourceLeaks && !flaky {
+		checkResourceLeaks(pid, env, inp.p)
+	}
+	triageMu.Lock()
+	if !inp.minimized && !flaky {
 		smashQueue = append(smashQueue, inp)
-		triageMu.Unlock()
+	}
+	if *flagFault && faultInjectionEnabled {
+		faultQueue = append(faultQueue, inp.p)
+	}
+	triageMu.Unlock()
+}
+
+// serializeInput serializes the input program, faulty inputs are prefixed with prog.FaultComment,
+// so that the fault is preserved in the corpus and is replayed when the program is executed as candidate.
+func serializeInput(inp Input) []byte {
+	data := inp.p.Serialize()
+	if inp.fault {
+		data = append(prog.FaultComment(inp.call, inp.faultNth), data...)
+	}
+	return data
+}
+
+// kmemleakScan scans for memory leaks, found leaks are printed as crashes recognized by manager.
+// Every scan is followed by kmemleak.ScanMarker to delimit programs executed between scans.
+func kmemleakScan(report bool) {
+	leaks, err := kmemleak.Scan(report)
+	if err != nil {
+		panic(err)
+	}
+	if len(leaks) != 0 {
+		Logf(0, "%v:\n%s\n", kmemleak.ReportHeader, leaks)
+	}
+	Logf(0, "%v", kmemleak.ScanMarker)
+}
+
+// checkResourceLeaks executes p several times in a row and reports kernel objects
+// (files, sockets, etc) that leak with every execution (see ipc.LeakDetector).
+func checkResourceLeaks(pid int, env *ipc.Env, p *prog.Prog) {
+	var detector ipc.LeakDetector
+	for i := 0; i < ipc.LeakCheckRuns; i++ {
+		execute1(pid, env, &ipc.ExecOpts{}, p, &statExecLeak)
+		res, ok := env.Resources()
+		if !ok {
+			detector.Reset()
+			continue
+		}
+		if leaks := detector.Add(res); leaks != "" {
+			// Resource leak in output should be recognized by manager.
+			logMu.Lock()
+			Logf(0, "%s", leaks)
+			logMu.Unlock()
+			return
+		}
 	}
 }
 
@@ -658,6 +940,12 @@ func execute(pid int, env *ipc.Env, p *prog.Prog, needCover, needComps, minimize
 	if needCover {
 		opts.Flags |= ipc.FlagCollectCover
 	}
+	return executeOpts(pid, env, opts, p, minimized, candidate, stat)
+}
+
+// executeOpts executes p with opts and queues calls that give new signal for triage.
+func executeOpts(pid int, env *ipc.Env, opts *ipc.ExecOpts, p *prog.Prog, minimized, candidate bool,
+	stat *uint64) []ipc.CallInfo {
 	info := execute1(pid, env, opts, p, stat)
 	signalMu.RLock()
 	defer signalMu.RUnlock()
@@ -681,6 +969,15 @@ func execute(pid int, env *ipc.Env, p *prog.Prog, needCover, needComps, minimize
 			signal:    append([]uint32{}, inf.Signal...),
 			minimized: minimized,
 		}
+		if opts.Flags&ipc.FlagInjectFault != 0 {
+			if i != opts.FaultCall {
+				// The fault is replayed in the triaged call (it has to follow the call
+				// during minimization), so new signal of other calls is only counted in max signal.
+				continue
+			}
+			inp.fault = true
+			inp.faultNth = opts.FaultNth
+		}
 		triageMu.Lock()
 		if candidate {
 			triageCandidate = append(triageCandidate, inp)
@@ -729,9 +1026,15 @@ func execute1(pid int, env *ipc.Env, opts *ipc.ExecOpts, p *prog.Prog, stat *uin
 	case "dmesg":
 		fd, err := syscall.Open("/dev/kmsg", syscall.O_WRONLY, 0)
 		if err == nil {
-			buf := new(bytes.Buffer)
-			fmt.Fprintf(buf, "syzkaller: executing program %v%v:\n%s", pid, strOpts, p.Serialize())
-			syscall.Write(fd, buf.Bytes())
+			// Messages of different procs can be interleaved in the kernel log,
+			// so every program line is prefixed with the proc to allow untangling them.
+			syscall.Write(fd, []byte(fmt.Sprintf("syzkaller: executing program %v%v:\n", pid, strOpts)))
+			prefix := prog.LogProcPrefix(pid)
+			for _, line := range bytes.SplitAfter(p.Serialize(), []byte{'\n'}) {
+				if len(line) != 0 {
+					syscall.Write(fd, append([]byte(prefix), line...))
+				}
+			}
 			syscall.Close(fd)
 		}
 	case "file":
@@ -766,5 +1069,11 @@ retry:
 		goto retry
 	}
 	Logf(2, "result failed=%v hanged=%v: %v\n", failed, hanged, string(output))
+	if leaks := ipc.FormatLeaks(p, info); leaks != "" {
+		// KASLR leak in output should be recognized by manager.
+		logMu.Lock()
+		Logf(0, "%s", leaks)
+		logMu.Unlock()
+	}
 	return info
 }
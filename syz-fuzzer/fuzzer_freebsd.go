@@ -3,19 +3,14 @@
 
 package main
 
-import (
-	"github.com/google/syzkaller/pkg/log"
-)
-
-func kmemleakInit() {
-	if *flagLeak {
-		log.Fatalf("leak checking is not supported on fuchsia")
-	}
+func checkCompsSupported() (kcov, comps bool) {
+	return true, false
 }
 
-func kmemleakScan(report bool) {
+func collectPaths() []string {
+	return nil
 }
 
-func checkCompsSupported() (kcov, comps bool) {
-	return true, false
+func collectNetlinkFamilies() map[string]uint64 {
+	return nil
 }
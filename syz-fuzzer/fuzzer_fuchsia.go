@@ -5,19 +5,14 @@
 
 package main
 
-import (
-	"github.com/google/syzkaller/pkg/log"
-)
-
-func kmemleakInit() {
-	if *flagLeak {
-		log.Fatalf("leak checking is not supported on fuchsia")
-	}
+func checkCompsSupported() (kcov, comps bool) {
+	return false, false
 }
 
-func kmemleakScan(report bool) {
+func collectPaths() []string {
+	return nil
 }
 
-func checkCompsSupported() (kcov, comps bool) {
-	return false, false
+func collectNetlinkFamilies() map[string]uint64 {
+	return nil
 }
@@ -4,86 +4,18 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/sys/linux"
 )
 
-func kmemleakInit() {
-	fd, err := syscall.Open("/sys/kernel/debug/kmemleak", syscall.O_RDWR, 0)
-	if err != nil {
-		if *flagLeak {
-			log.Fatalf("BUG: /sys/kernel/debug/kmemleak is missing (%v). Enable CONFIG_KMEMLEAK and mount debugfs.", err)
-		} else {
-			return
-		}
-	}
-	defer syscall.Close(fd)
-	what := "scan=off"
-	if !*flagLeak {
-		what = "off"
-	}
-	if _, err := syscall.Write(fd, []byte(what)); err != nil {
-		// kmemleak returns EBUSY when kmemleak is already turned off.
-		if err != syscall.EBUSY {
-			panic(err)
-		}
-	}
-}
-
-var kmemleakBuf []byte
-
-func kmemleakScan(report bool) {
-	fd, err := syscall.Open("/sys/kernel/debug/kmemleak", syscall.O_RDWR, 0)
-	if err != nil {
-		panic(err)
-	}
-	defer syscall.Close(fd)
-	// Kmemleak has false positives. To mitigate most of them, it checksums
-	// potentially leaked objects, and reports them only on the next scan
-	// iff the checksum does not change. Because of that we do the following
-	// intricate dance:
-	// Scan, sleep, scan again. At this point we can get some leaks.
-	// If there are leaks, we sleep and scan again, this can remove
-	// false leaks. Then, read kmemleak again. If we get leaks now, then
-	// hopefully these are true positives during the previous testing cycle.
-	if _, err := syscall.Write(fd, []byte("scan")); err != nil {
-		panic(err)
-	}
-	time.Sleep(time.Second)
-	if _, err := syscall.Write(fd, []byte("scan")); err != nil {
-		panic(err)
-	}
-	if report {
-		if kmemleakBuf == nil {
-			kmemleakBuf = make([]byte, 128<<10)
-		}
-		n, err := syscall.Read(fd, kmemleakBuf)
-		if err != nil {
-			panic(err)
-		}
-		if n != 0 {
-			time.Sleep(time.Second)
-			if _, err := syscall.Write(fd, []byte("scan")); err != nil {
-				panic(err)
-			}
-			n, err := syscall.Read(fd, kmemleakBuf)
-			if err != nil {
-				panic(err)
-			}
-			if n != 0 {
-				// BUG in output should be recognized by manager.
-				log.Logf(0, "BUG: memory leak:\n%s\n", kmemleakBuf[:n])
-			}
-		}
-	}
-	if _, err := syscall.Write(fd, []byte("clear")); err != nil {
-		panic(err)
-	}
-}
-
 // Checks if the KCOV device supports comparisons.
 // Returns a pair of bools:
 //		First  - is the kcov device present in the system.
@@ -117,3 +49,77 @@ func checkCompsSupported() (kcov, comps bool) {
 	comps = errno == 0
 	return
 }
+
+// collectPaths returns device nodes, procfs and sysfs files present on the machine.
+// They are added to the dictionary used for filename generation and mutation.
+func collectPaths() []string {
+	var paths []string
+	roots := []struct {
+		dir   string
+		depth int // max depth of directories to descend into
+		max   int // max number of files to collect
+	}{
+		{"/dev", 2, 1000},
+		{"/proc", 1, 500},
+		{"/proc/self/", 1, 200}, // trailing slash to follow the symlink
+		{"/proc/sys", 4, 2000},
+		{"/sys", 3, 2000},
+	}
+	for _, root := range roots {
+		count := 0
+		filepath.Walk(root.dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil || count >= root.max {
+				if info != nil && info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if file == root.dir {
+				return nil
+			}
+			if info.IsDir() {
+				depth := strings.Count(strings.TrimPrefix(file, filepath.Clean(root.dir)), "/")
+				if depth >= root.depth || isPidDir(info.Name()) {
+					return filepath.SkipDir
+				}
+			}
+			paths = append(paths, file)
+			count++
+			return nil
+		})
+	}
+	return paths
+}
+
+// collectNetlinkFamilies returns IDs of generic netlink families registered in the kernel
+// (family name -> ID) as reported by executor. IDs of most families are allocated dynamically,
+// so random message types almost never reach them.
+func collectNetlinkFamilies() map[string]uint64 {
+	out, err := osutil.RunCmd(time.Minute, "", *flagExecutor, "netlink")
+	if err != nil {
+		log.Logf(0, "failed to discover netlink families: %v", err)
+		return nil
+	}
+	families := make(map[string]uint64)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		id, err := strconv.ParseUint(fields[1], 10, 16)
+		if err != nil {
+			continue
+		}
+		families[fields[0]] = id
+	}
+	return families
+}
+
+func isPidDir(name string) bool {
+	for _, c := range name {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
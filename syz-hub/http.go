@@ -42,14 +42,17 @@ func (hub *Hub) httpSummary(w http.ResponseWriter, r *http.Request) {
 	}
 	for name, mgr := range hub.st.Managers {
 		total.Added += mgr.Added
+		total.Invalid += mgr.Invalid
 		total.Deleted += mgr.Deleted
 		total.New += mgr.New
 		total.SentRepros += mgr.SentRepros
 		total.RecvRepros += mgr.RecvRepros
 		data.Managers = append(data.Managers, UIManager{
 			Name:       name,
+			Domain:     mgr.Domain,
 			Corpus:     len(mgr.Corpus.Records),
 			Added:      mgr.Added,
+			Invalid:    mgr.Invalid,
 			Deleted:    mgr.Deleted,
 			New:        mgr.New,
 			SentRepros: mgr.SentRepros,
@@ -58,6 +61,17 @@ func (hub *Hub) httpSummary(w http.ResponseWriter, r *http.Request) {
 	}
 	sort.Sort(UIManagerArray(data.Managers))
 	data.Managers = append([]UIManager{total}, data.Managers...)
+	for name, client := range hub.clients {
+		received, invalid := hub.clientStats(name)
+		data.Clients = append(data.Clients, UIClient{
+			Name:     name,
+			Received: received,
+			Invalid:  invalid,
+			Dropped:  client.dropped,
+			Banned:   client.banned,
+		})
+	}
+	sort.Sort(UIClientArray(data.Clients))
 	if err := summaryTemplate.Execute(w, data); err != nil {
 		Logf(0, "failed to execute template: %v", err)
 		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
@@ -71,13 +85,16 @@ func compileTemplate(html string) *template.Template {
 
 type UISummaryData struct {
 	Managers []UIManager
+	Clients  []UIClient
 	Log      string
 }
 
 type UIManager struct {
 	Name       string
+	Domain     string
 	Corpus     int
 	Added      int
+	Invalid    int
 	Deleted    int
 	New        int
 	Repros     int
@@ -91,6 +108,20 @@ func (a UIManagerArray) Len() int           { return len(a) }
 func (a UIManagerArray) Less(i, j int) bool { return a[i].Name < a[j].Name }
 func (a UIManagerArray) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
+type UIClient struct {
+	Name     string
+	Received int
+	Invalid  int
+	Dropped  int
+	Banned   bool
+}
+
+type UIClientArray []UIClient
+
+func (a UIClientArray) Len() int           { return len(a) }
+func (a UIClientArray) Less(i, j int) bool { return a[i].Name < a[j].Name }
+func (a UIClientArray) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
 var summaryTemplate = compileTemplate(`
 <!doctype html>
 <html>
@@ -106,8 +137,10 @@ var summaryTemplate = compileTemplate(`
 	<caption>Managers:</caption>
 	<tr>
 		<th>Name</th>
+		<th>Domain</th>
 		<th>Corpus</th>
 		<th>Added</th>
+		<th>Invalid</th>
 		<th>Deleted</th>
 		<th>New</th>
 		<th>Repros</th>
@@ -117,8 +150,10 @@ var summaryTemplate = compileTemplate(`
 	{{range $m := $.Managers}}
 	<tr>
 		<td>{{$m.Name}}</td>
+		<td>{{$m.Domain}}</td>
 		<td>{{$m.Corpus}}</td>
 		<td>{{$m.Added}}</td>
+		<td>{{$m.Invalid}}</td>
 		<td>{{$m.Deleted}}</td>
 		<td>{{$m.New}}</td>
 		<td>{{$m.Repros}}</td>
@@ -129,6 +164,27 @@ var summaryTemplate = compileTemplate(`
 </table>
 <br><br>
 
+<table>
+	<caption>Clients:</caption>
+	<tr>
+		<th>Name</th>
+		<th>Received</th>
+		<th>Invalid</th>
+		<th>Rate limited</th>
+		<th>Banned</th>
+	</tr>
+	{{range $c := $.Clients}}
+	<tr>
+		<td>{{$c.Name}}</td>
+		<td>{{$c.Received}}</td>
+		<td>{{$c.Invalid}}</td>
+		<td>{{$c.Dropped}}</td>
+		<td>{{$c.Banned}}</td>
+	</tr>
+	{{end}}
+</table>
+<br><br>
+
 Log:
 <br>
 <textarea id="log_textarea" readonly rows="50">
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"crypto/subtle"
 	"flag"
 	"fmt"
 	"strings"
@@ -26,13 +27,39 @@ type Config struct {
 	Clients []struct {
 		Name string
 		Key  string
+		// Max number of programs and reproducers accepted from the client per hour.
+		// Excessive inputs are dropped. Zero means no limit.
+		Rate_Limit int
+		// Same as Rate_Limit, but for each individual manager of the client.
+		// Inputs from managers beyond the first 1000 active ones are dropped.
+		Manager_Rate_Limit int
+		// Corpus domains the client managers are allowed to use (any if empty).
+		Domains []string
 	}
+	// Clients that sent at least that many programs are checked for reputation (default 1000).
+	Reputation_Min_Inputs int
+	// Clients with lower share of valid programs (in percents) are rejected (default 10).
+	Reputation_Min_Valid int
 }
 
 type Hub struct {
-	mu   sync.Mutex
-	st   *state.State
-	keys map[string]string
+	mu      sync.Mutex
+	st      *state.State
+	clients map[string]*Client
+
+	reputationMinInputs int
+	reputationMinValid  int
+}
+
+// Client holds per-client authentication and abuse protection state.
+type Client struct {
+	key              string
+	limiter          *RateLimiter
+	managerRateLimit int
+	managerLimiters  map[string]*RateLimiter
+	domains          map[string]bool
+	dropped          int // number of inputs dropped due to rate limiting
+	banned           bool
 }
 
 func main() {
@@ -48,11 +75,37 @@ func main() {
 		Fatalf("failed to load state: %v", err)
 	}
 	hub := &Hub{
-		st:   st,
-		keys: make(map[string]string),
+		st:                  st,
+		clients:             make(map[string]*Client),
+		reputationMinInputs: cfg.Reputation_Min_Inputs,
+		reputationMinValid:  cfg.Reputation_Min_Valid,
+	}
+	if hub.reputationMinInputs == 0 {
+		hub.reputationMinInputs = 1000
+	}
+	if hub.reputationMinValid == 0 {
+		hub.reputationMinValid = 10
 	}
-	for _, mgr := range cfg.Clients {
-		hub.keys[mgr.Name] = mgr.Key
+	for _, client := range cfg.Clients {
+		if client.Name == "" || client.Key == "" {
+			Fatalf("client name or key is empty")
+		}
+		if hub.clients[client.Name] != nil {
+			Fatalf("duplicate client %v", client.Name)
+		}
+		c := &Client{
+			key:              client.Key,
+			limiter:          NewRateLimiter(client.Rate_Limit),
+			managerRateLimit: client.Manager_Rate_Limit,
+			managerLimiters:  make(map[string]*RateLimiter),
+		}
+		if len(client.Domains) != 0 {
+			c.domains = make(map[string]bool)
+			for _, domain := range client.Domains {
+				c.domains[domain] = true
+			}
+		}
+		hub.clients[client.Name] = c
 	}
 
 	hub.initHttp(cfg.Http)
@@ -66,42 +119,52 @@ func main() {
 }
 
 func (hub *Hub) Connect(a *HubConnectArgs, r *int) error {
-	name, err := hub.auth(a.Client, a.Key, a.Manager)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	name, client, err := hub.auth(a.Client, a.Key, a.Manager)
 	if err != nil {
 		return err
 	}
-	hub.mu.Lock()
-	defer hub.mu.Unlock()
 
-	Logf(0, "connect from %v: fresh=%v calls=%v corpus=%v",
-		name, a.Fresh, len(a.Calls), len(a.Corpus))
-	if err := hub.st.Connect(name, a.Fresh, a.Calls, a.Corpus); err != nil {
+	if client.domains != nil && !client.domains[a.Domain] {
+		Logf(0, "connect from %v: domain %q is not allowed", name, a.Domain)
+		return fmt.Errorf("domain %q is not allowed for client %v", a.Domain, a.Client)
+	}
+
+	Logf(0, "connect from %v: domain=%q fresh=%v calls=%v corpus=%v",
+		name, a.Domain, a.Fresh, len(a.Calls), len(a.Corpus))
+	corpus := a.Corpus[:hub.limit(client, name, len(a.Corpus))]
+	if err := hub.st.Connect(name, a.Domain, a.Fresh, a.Calls, corpus); err != nil {
 		Logf(0, "connect error: %v", err)
 		return err
 	}
+	hub.checkReputation(a.Client, client)
 	return nil
 }
 
 func (hub *Hub) Sync(a *HubSyncArgs, r *HubSyncRes) error {
-	name, err := hub.auth(a.Client, a.Key, a.Manager)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	name, client, err := hub.auth(a.Client, a.Key, a.Manager)
 	if err != nil {
 		return err
 	}
-	hub.mu.Lock()
-	defer hub.mu.Unlock()
 
-	progs, more, err := hub.st.Sync(name, a.Add, a.Del)
+	add := a.Add[:hub.limit(client, name, len(a.Add))]
+	repros := a.Repros[:hub.limit(client, name, len(a.Repros))]
+	progs, more, err := hub.st.Sync(name, add, a.Del)
 	if err != nil {
 		Logf(0, "sync error: %v", err)
 		return err
 	}
 	r.Progs = progs
 	r.More = more
-	for _, repro := range a.Repros {
+	for _, repro := range repros {
 		if err := hub.st.AddRepro(name, repro); err != nil {
 			Logf(0, "add repro error: %v", err)
 		}
 	}
+	hub.checkReputation(a.Client, client)
 	if a.NeedRepros {
 		repro, err := hub.st.PendingRepro(name)
 		if err != nil {
@@ -116,16 +179,81 @@ func (hub *Hub) Sync(a *HubSyncArgs, r *HubSyncRes) error {
 	return nil
 }
 
-func (hub *Hub) auth(client, key, manager string) (string, error) {
-	if key, ok := hub.keys[client]; !ok || key != key {
-		Logf(0, "connect from unauthorized client %v", client)
-		return "", fmt.Errorf("unauthorized manager")
+func (hub *Hub) auth(name, key, manager string) (string, *Client, error) {
+	client := hub.clients[name]
+	if client == nil || subtle.ConstantTimeCompare([]byte(client.key), []byte(key)) != 1 {
+		Logf(0, "connect from unauthorized client %v", name)
+		return "", nil, fmt.Errorf("unauthorized manager")
+	}
+	if client.banned {
+		return "", nil, fmt.Errorf("client %v is banned due to low reputation", name)
 	}
 	if manager == "" {
-		manager = client
-	} else if !strings.HasPrefix(manager, client) {
-		Logf(0, "manager %v does not have client prefix %v", manager, client)
-		return "", fmt.Errorf("unauthorized manager")
+		manager = name
+	} else if manager != name && !strings.HasPrefix(manager, name+"-") {
+		Logf(0, "manager %v does not have client prefix %v", manager, name)
+		return "", nil, fmt.Errorf("unauthorized manager")
+	}
+	return manager, client, nil
+}
+
+// limit returns how many of the n inputs can be accepted from the client manager.
+func (hub *Hub) limit(client *Client, manager string, n int) int {
+	allowed := n
+	if client.managerRateLimit != 0 {
+		limiter := client.managerLimiters[manager]
+		if limiter == nil {
+			evictManagerLimiters(client)
+			if len(client.managerLimiters) >= maxManagerLimiters {
+				Logf(0, "too many active managers of client, dropping inputs from %v", manager)
+				client.dropped += n
+				return 0
+			}
+			limiter = NewRateLimiter(client.managerRateLimit)
+			client.managerLimiters[manager] = limiter
+		}
+		allowed = limiter.Take(allowed)
+	}
+	allowed = client.limiter.Take(allowed)
+	client.dropped += n - allowed
+	return allowed
+}
+
+// Max number of managers of a single client that are rate limited at the same time.
+const maxManagerLimiters = 1000
+
+// evictManagerLimiters deletes limiters of managers that were idle long enough
+// for the limiters to refill, they are equivalent to new limiters.
+func evictManagerLimiters(client *Client) {
+	for manager, limiter := range client.managerLimiters {
+		if limiter.Refilled() {
+			delete(client.managerLimiters, manager)
+		}
+	}
+}
+
+// checkReputation bans the client if most of the programs it sent are invalid.
+// The ban holds until hub restart.
+func (hub *Hub) checkReputation(name string, client *Client) {
+	received, invalid := hub.clientStats(name)
+	if received < hub.reputationMinInputs {
+		return
+	}
+	if (received-invalid)*100 < received*hub.reputationMinValid {
+		Logf(0, "banning client %v: %v out of %v programs are invalid", name, invalid, received)
+		client.banned = true
+	}
+}
+
+// clientStats returns total number of received and invalid programs for all managers of the client.
+// Managers of the client are named either as the client or as client name followed by "-"
+// (matching just the prefix would attribute managers of client "ab" to client "a").
+func (hub *Hub) clientStats(name string) (received, invalid int) {
+	for mgrName, mgr := range hub.st.Managers {
+		if mgrName == name || strings.HasPrefix(mgrName, name+"-") {
+			received += mgr.Received
+			invalid += mgr.Invalid
+		}
 	}
-	return manager, nil
+	return
 }
@@ -0,0 +1,85 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAuth(t *testing.T) {
+	hub := &Hub{
+		clients: map[string]*Client{
+			"foo":    {key: "1234"},
+			"foobar": {key: "5678"},
+		},
+	}
+	tests := []struct {
+		client  string
+		key     string
+		manager string
+		result  string
+	}{
+		{"foo", "1234", "", "foo"},
+		{"foo", "1234", "foo", "foo"},
+		{"foo", "1234", "foo-bar", "foo-bar"},
+		{"foo", "1234", "foobar", ""},
+		{"foo", "1234", "foobar-baz", ""},
+		{"foo", "1234", "bar", ""},
+		{"foo", "5678", "", ""},
+		{"foobar", "5678", "foobar-baz", "foobar-baz"},
+		{"baz", "1234", "", ""},
+	}
+	for i, test := range tests {
+		manager, client, err := hub.auth(test.client, test.key, test.manager)
+		if test.result == "" {
+			if err == nil {
+				t.Errorf("#%v: auth(%q, %q, %q) succeeded", i, test.client, test.key, test.manager)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%v: auth(%q, %q, %q) failed: %v", i, test.client, test.key, test.manager, err)
+			continue
+		}
+		if manager != test.result || client != hub.clients[test.client] {
+			t.Errorf("#%v: auth(%q, %q, %q) = %q, want %q",
+				i, test.client, test.key, test.manager, manager, test.result)
+		}
+	}
+}
+
+func TestManagerLimiters(t *testing.T) {
+	hub := new(Hub)
+	client := &Client{
+		limiter:          NewRateLimiter(0),
+		managerRateLimit: 10,
+		managerLimiters:  make(map[string]*RateLimiter),
+	}
+	if allowed := hub.limit(client, "foo-0", 20); allowed != 10 {
+		t.Fatalf("allowed %v inputs, want 10", allowed)
+	}
+	for i := 1; i < maxManagerLimiters; i++ {
+		hub.limit(client, fmt.Sprintf("foo-%v", i), 1)
+	}
+	if allowed := hub.limit(client, "foo-new", 1); allowed != 0 {
+		t.Fatalf("allowed %v inputs over the manager limit", allowed)
+	}
+	// Managers that did not send anything for an hour don't need limiters.
+	for manager, limiter := range client.managerLimiters {
+		if manager != "foo-0" {
+			limiter.last = limiter.last.Add(-time.Hour)
+		}
+	}
+	if allowed := hub.limit(client, "foo-new", 1); allowed != 1 {
+		t.Fatalf("allowed %v inputs after idle managers expired", allowed)
+	}
+	if len(client.managerLimiters) != 2 {
+		t.Fatalf("have %v manager limiters, want 2", len(client.managerLimiters))
+	}
+	if allowed := hub.limit(client, "foo-0", 1); allowed != 0 {
+		t.Fatalf("allowed %v inputs from rate limited manager", allowed)
+	}
+}
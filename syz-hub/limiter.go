@@ -0,0 +1,49 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+)
+
+// RateLimiter is a token bucket that allows to accept up to limit items per hour
+// with bursts of up to limit items.
+type RateLimiter struct {
+	limit  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(limit int) *RateLimiter {
+	return &RateLimiter{
+		limit:  float64(limit),
+		tokens: float64(limit),
+		last:   time.Now(),
+	}
+}
+
+// Take returns how many of the n items are allowed to pass now.
+// Zero limit means no limit.
+func (rl *RateLimiter) Take(n int) int {
+	if rl.limit == 0 {
+		return n
+	}
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Hours() * rl.limit
+	if rl.tokens > rl.limit {
+		rl.tokens = rl.limit
+	}
+	rl.last = now
+	if float64(n) > rl.tokens {
+		n = int(rl.tokens)
+	}
+	rl.tokens -= float64(n)
+	return n
+}
+
+// Refilled returns true if the limiter has accumulated the maximum number of tokens,
+// i.e. it behaves the same way as a newly created limiter.
+func (rl *RateLimiter) Refilled() bool {
+	return rl.tokens+time.Since(rl.last).Hours()*rl.limit >= rl.limit
+}
@@ -39,9 +39,13 @@ type Manager struct {
 	corpusFile    string
 	corpusSeqFile string
 	reproSeqFile  string
+	domainFile    string
 	ownRepros     map[string]bool
+	Domain        string // programs are exchanged only between managers with the same domain
 	Connected     time.Time
 	Added         int
+	Received      int // total number of received programs/repros
+	Invalid       int // number of received programs/repros that failed validation
 	Deleted       int
 	New           int
 	SentRepros    int
@@ -117,8 +121,11 @@ func (st *State) createManager(name string) (*Manager, error) {
 		corpusFile:    filepath.Join(dir, "corpus.db"),
 		corpusSeqFile: filepath.Join(dir, "seq"),
 		reproSeqFile:  filepath.Join(dir, "repro.seq"),
+		domainFile:    filepath.Join(dir, "domain"),
 		ownRepros:     make(map[string]bool),
 	}
+	domain, _ := ioutil.ReadFile(mgr.domainFile)
+	mgr.Domain = string(domain)
 	mgr.corpusSeq = loadSeqFile(mgr.corpusSeqFile)
 	if st.corpusSeq < mgr.corpusSeq {
 		st.corpusSeq = mgr.corpusSeq
@@ -132,13 +139,13 @@ func (st *State) createManager(name string) (*Manager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open manager corpus %v: %v", mgr.corpusFile, err)
 	}
-	Logf(0, "created manager %v: corpus=%v, corpusSeq=%v, reproSeq=%v",
-		mgr.name, len(mgr.Corpus.Records), mgr.corpusSeq, mgr.reproSeq)
+	Logf(0, "created manager %v: domain=%q corpus=%v, corpusSeq=%v, reproSeq=%v",
+		mgr.name, mgr.Domain, len(mgr.Corpus.Records), mgr.corpusSeq, mgr.reproSeq)
 	st.Managers[name] = mgr
 	return mgr, nil
 }
 
-func (st *State) Connect(name string, fresh bool, calls []string, corpus [][]byte) error {
+func (st *State) Connect(name, domain string, fresh bool, calls []string, corpus [][]byte) error {
 	mgr := st.Managers[name]
 	if mgr == nil {
 		var err error
@@ -148,10 +155,13 @@ func (st *State) Connect(name string, fresh bool, calls []string, corpus [][]byt
 		}
 	}
 	mgr.Connected = time.Now()
-	if fresh {
+	if fresh || mgr.Domain != domain {
+		// Programs from the new domain were never sent to the manager.
 		mgr.corpusSeq = 0
 		mgr.reproSeq = 0
 	}
+	mgr.Domain = domain
+	writeFile(mgr.domainFile, []byte(domain))
 	saveSeqFile(mgr.corpusSeqFile, mgr.corpusSeq)
 	saveSeqFile(mgr.reproSeqFile, mgr.reproSeq)
 
@@ -199,9 +209,11 @@ func (st *State) AddRepro(name string, repro []byte) error {
 	if mgr == nil || mgr.Connected.IsZero() {
 		return fmt.Errorf("unconnected manager %v", name)
 	}
+	mgr.Received++
 	if _, err := prog.CallSet(repro); err != nil {
 		Logf(0, "manager %v: failed to extract call set: %v, program:\n%v",
 			mgr.name, err, string(repro))
+		mgr.Invalid++
 		return nil
 	}
 	sig := hash.String(repro)
@@ -239,6 +251,9 @@ func (st *State) PendingRepro(name string) ([]byte, error) {
 		if mgr.ownRepros[key] {
 			continue
 		}
+		if !st.sameDomainRepro(mgr, key) {
+			continue
+		}
 		calls, err := prog.CallSet(rec.Val)
 		if err != nil {
 			return nil, fmt.Errorf("failed to extract call set: %v\nprogram: %s", err, rec.Val)
@@ -266,6 +281,12 @@ func (st *State) pendingInputs(mgr *Manager) ([][]byte, int, error) {
 	if mgr.corpusSeq == st.corpusSeq {
 		return nil, 0, nil
 	}
+	var domain []*Manager
+	for _, mgr1 := range st.Managers {
+		if mgr1 != mgr && mgr1.Domain == mgr.Domain {
+			domain = append(domain, mgr1)
+		}
+	}
 	var records []db.Record
 	for key, rec := range st.Corpus.Records {
 		if mgr.corpusSeq >= rec.Seq {
@@ -274,6 +295,9 @@ func (st *State) pendingInputs(mgr *Manager) ([][]byte, int, error) {
 		if _, ok := mgr.Corpus.Records[key]; ok {
 			continue
 		}
+		if !inCorpusOf(domain, key) {
+			continue
+		}
 		calls, err := prog.CallSet(rec.Val)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to extract call set: %v\nprogram: %s", err, rec.Val)
@@ -298,7 +322,7 @@ func (st *State) pendingInputs(mgr *Manager) ([][]byte, int, error) {
 		more = len(records) - pos
 		records = records[:pos]
 	}
-	progs := make([][]byte, len(records))
+	progs := make([][]byte, 0, len(records))
 	for _, rec := range records {
 		progs = append(progs, rec.Val)
 	}
@@ -324,8 +348,10 @@ func (st *State) addInputs(mgr *Manager, inputs [][]byte) {
 }
 
 func (st *State) addInput(mgr *Manager, input []byte) {
+	mgr.Received++
 	if _, err := prog.CallSet(input); err != nil {
 		Logf(0, "manager %v: failed to extract call set: %v, program:\n%v", mgr.name, err, string(input))
+		mgr.Invalid++
 		return
 	}
 	sig := hash.String(input)
@@ -353,6 +379,27 @@ func (st *State) purgeCorpus() {
 	}
 }
 
+// inCorpusOf returns true if the program is in corpus of any of the managers.
+func inCorpusOf(managers []*Manager, sig string) bool {
+	for _, mgr := range managers {
+		if _, ok := mgr.Corpus.Records[sig]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDomainRepro returns false if the repro was sent by a manager from a different domain.
+// Origin of repros received before restart is unknown, such repros are sent to all managers.
+func (st *State) sameDomainRepro(mgr *Manager, sig string) bool {
+	for _, mgr1 := range st.Managers {
+		if mgr1.ownRepros[sig] {
+			return mgr1.Domain == mgr.Domain
+		}
+	}
+	return true
+}
+
 func managerSupportsAllCalls(mgr, prog map[string]struct{}) bool {
 	for c := range prog {
 		if _, ok := mgr[c]; !ok {
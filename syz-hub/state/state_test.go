@@ -28,7 +28,7 @@ func TestState(t *testing.T) {
 		t.Fatalf("synced with unconnected manager")
 	}
 	calls := []string{"read", "write"}
-	if err := st.Connect("foo", false, calls, nil); err != nil {
+	if err := st.Connect("foo", "", false, calls, nil); err != nil {
 		t.Fatalf("Connect failed: %v", err)
 	}
 	_, _, err = st.Sync("foo", nil, nil)
@@ -49,10 +49,10 @@ func TestRepro(t *testing.T) {
 		t.Fatalf("failed to make state: %v", err)
 	}
 
-	if err := st.Connect("foo", false, []string{"open", "read", "write"}, nil); err != nil {
+	if err := st.Connect("foo", "", false, []string{"open", "read", "write"}, nil); err != nil {
 		t.Fatalf("Connect failed: %v", err)
 	}
-	if err := st.Connect("bar", false, []string{"open", "read", "close"}, nil); err != nil {
+	if err := st.Connect("bar", "", false, []string{"open", "read", "close"}, nil); err != nil {
 		t.Fatalf("Connect failed: %v", err)
 	}
 	checkPendingRepro(t, st, "foo", "")
@@ -86,10 +86,10 @@ func TestRepro(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to make state: %v", err)
 	}
-	if err := st.Connect("foo", false, []string{"open", "read", "write"}, nil); err != nil {
+	if err := st.Connect("foo", "", false, []string{"open", "read", "write"}, nil); err != nil {
 		t.Fatalf("Connect failed: %v", err)
 	}
-	if err := st.Connect("bar", false, []string{"open", "read", "close"}, nil); err != nil {
+	if err := st.Connect("bar", "", false, []string{"open", "read", "close"}, nil); err != nil {
 		t.Fatalf("Connect failed: %v", err)
 	}
 	checkPendingRepro(t, st, "bar", "")
@@ -112,3 +112,127 @@ func caller(skip int) string {
 	_, file, line, _ := runtime.Caller(skip + 1)
 	return fmt.Sprintf("%v:%v", filepath.Base(file), line)
 }
+
+func TestInvalidInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syz-hub-state-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	st, err := Make(dir)
+	if err != nil {
+		t.Fatalf("failed to make state: %v", err)
+	}
+	corpus := [][]byte{[]byte("open()"), []byte("garbage")}
+	if err := st.Connect("foo", "", false, []string{"open", "read"}, corpus); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if _, _, err := st.Sync("foo", [][]byte{[]byte("read()"), []byte("(")}, nil); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := st.AddRepro("foo", []byte("=")); err != nil {
+		t.Fatalf("AddRepro failed: %v", err)
+	}
+	mgr := st.Managers["foo"]
+	if mgr.Received != 5 || mgr.Invalid != 3 {
+		t.Fatalf("got received=%v invalid=%v, want 5/3", mgr.Received, mgr.Invalid)
+	}
+	if len(st.Corpus.Records) != 2 {
+		t.Fatalf("corpus contains %v programs, want 2", len(st.Corpus.Records))
+	}
+}
+
+func TestPendingInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syz-hub-state-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	st, err := Make(dir)
+	if err != nil {
+		t.Fatalf("failed to make state: %v", err)
+	}
+	calls := []string{"open", "read", "write"}
+	if err := st.Connect("foo", "", false, calls, nil); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	corpus := [][]byte{[]byte("open()"), []byte("read()"), []byte("write()")}
+	if err := st.Connect("bar", "", false, calls, corpus); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	progs, more, err := st.Sync("foo", nil, nil)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	// Pending inputs used to be preceded by the same number of nil entries.
+	if len(progs) != len(corpus) || more != 0 {
+		t.Fatalf("Sync returned %v programs (more %v), want %v", len(progs), more, len(corpus))
+	}
+	for i, p := range progs {
+		if len(p) == 0 {
+			t.Fatalf("Sync returned empty program #%v", i)
+		}
+	}
+}
+
+func TestDomains(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syz-hub-state-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	st, err := Make(dir)
+	if err != nil {
+		t.Fatalf("failed to make state: %v", err)
+	}
+	calls := []string{"open", "read", "write"}
+	if err := st.Connect("foo", "linux/amd64", false, calls, [][]byte{[]byte("open()")}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := st.Connect("bar", "linux/arm64", false, calls, [][]byte{[]byte("read()")}); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := st.Connect("baz", "linux/amd64", false, calls, nil); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	checkPendingInputs(t, st, "baz", "open()")
+	checkPendingInputs(t, st, "foo")
+	if err := st.AddRepro("bar", []byte("write()")); err != nil {
+		t.Fatalf("AddRepro failed: %v", err)
+	}
+	checkPendingRepro(t, st, "baz", "")
+	checkPendingRepro(t, st, "foo", "")
+
+	// Switching domain resends programs from the new domain.
+	if err := st.Connect("baz", "linux/arm64", false, calls, nil); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	checkPendingInputs(t, st, "baz", "read()")
+	checkPendingRepro(t, st, "baz", "write()")
+
+	// Check that domains are persisted.
+	st, err = Make(dir)
+	if err != nil {
+		t.Fatalf("failed to make state: %v", err)
+	}
+	if domain := st.Managers["baz"].Domain; domain != "linux/arm64" {
+		t.Fatalf("restored domain %q, want %q", domain, "linux/arm64")
+	}
+}
+
+func checkPendingInputs(t *testing.T, st *State, name string, result ...string) {
+	progs, _, err := st.Sync(name, nil, nil)
+	if err != nil {
+		t.Fatalf("\n%v: Sync failed: %v", caller(1), err)
+	}
+	var got []string
+	for _, p := range progs {
+		got = append(got, string(p))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(result) {
+		t.Fatalf("\n%v: Sync returned %q, want %q", caller(1), got, result)
+	}
+}
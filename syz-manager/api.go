@@ -0,0 +1,371 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+// JSON API for programmatic control of the manager.
+// It is served alongside the HTML pages and allows external dashboards and CI systems
+// to query crashes and coverage, fetch repro artifacts, pause/resume fuzzing
+// and inject seed programs without scraping HTML.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/syzkaller/pkg/cover"
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	. "github.com/google/syzkaller/pkg/rpctype"
+)
+
+type APIStatus struct {
+	Name       string
+	Uptime     time.Duration
+	Paused     bool
+	Fuzzing    int
+	Reproing   int
+	Corpus     int
+	Candidates int
+	Signal     int
+	Cover      int
+	Stats      map[string]uint64
+}
+
+type APICrash struct {
+	ID             string
+	Description    string
+	Count          int
+	FirstTime      string
+	LastTime       string
+	KernelVersions []string
+	Repro          bool
+	CRepro         bool
+	Files          []string
+}
+
+type APICallCover struct {
+	Name   string
+	Inputs int
+	Cover  int
+}
+
+type APISeedResult struct {
+	Added   int
+	Dropped int
+	Errors  []string
+}
+
+// Crash artifacts that can be fetched via /api/crash/file.
+var apiCrashFiles = map[string]bool{
+	"description":         true,
+	"repro.prog":          true,
+	"repro.cprog":         true,
+	"repro.report":        true,
+	"repro.tag":           true,
+	"repro.stats":         true,
+	"repro.stats.log":     true,
+	"repro.metadata.json": true,
+	kernelConfigFile:      true,
+	crashIndexFile:        true,
+}
+
+func (mgr *Manager) initAPI() {
+	http.HandleFunc("/api/status", mgr.apiStatus)
+	http.HandleFunc("/api/crashes", mgr.apiCrashes)
+	http.HandleFunc("/api/crash/file", mgr.apiCrashFile)
+	http.HandleFunc("/api/cover", mgr.apiCover)
+	http.HandleFunc("/api/pause", mgr.apiPause)
+	http.HandleFunc("/api/resume", mgr.apiResume)
+	http.HandleFunc("/api/seed", mgr.apiSeed)
+	http.HandleFunc("/api/regression", mgr.apiRegression)
+	http.HandleFunc("/api/syscalls", mgr.apiSyscalls)
+}
+
+func (mgr *Manager) apiStatus(w http.ResponseWriter, r *http.Request) {
+	mgr.mu.Lock()
+	status := &APIStatus{
+		Name:       mgr.cfg.Name,
+		Uptime:     time.Since(mgr.startTime),
+		Paused:     mgr.paused,
+		Corpus:     len(mgr.corpus),
+		Candidates: len(mgr.candidates),
+		Signal:     len(mgr.corpusSignal),
+		Cover:      len(mgr.corpusCover),
+		Stats:      make(map[string]uint64),
+	}
+	for k, v := range mgr.stats {
+		status.Stats[k] = v
+	}
+	mgr.mu.Unlock()
+	status.Fuzzing = int(atomic.LoadUint32(&mgr.numFuzzing))
+	status.Reproing = int(atomic.LoadUint32(&mgr.numReproducing))
+	apiReply(w, status)
+}
+
+func (mgr *Manager) apiCrashes(w http.ResponseWriter, r *http.Request) {
+	crashTypes, err := collectCrashes(mgr.cfg.Workdir)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "failed to collect crashes: %v", err)
+		return
+	}
+	query := r.FormValue("q")
+	crashes := []*APICrash{}
+	for _, ct := range crashTypes {
+		if !matchCrash(ct, query) {
+			continue
+		}
+		crash := &APICrash{
+			ID:             ct.ID,
+			Description:    ct.Description,
+			Count:          ct.Count,
+			FirstTime:      ct.FirstTime,
+			LastTime:       ct.LastTime,
+			KernelVersions: ct.KernelVersions,
+		}
+		files, _ := osutil.ListDir(filepath.Join(mgr.crashdir, ct.ID))
+		for _, f := range files {
+			switch f {
+			case "repro.prog":
+				crash.Repro = true
+			case "repro.cprog":
+				crash.CRepro = true
+			}
+			if isAPICrashFile(f) {
+				crash.Files = append(crash.Files, f)
+			}
+		}
+		crashes = append(crashes, crash)
+	}
+	apiReply(w, crashes)
+}
+
+func (mgr *Manager) apiCrashFile(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	file := r.FormValue("file")
+	if readCrash(mgr.cfg.Workdir, id, false) == nil {
+		apiError(w, http.StatusNotFound, "unknown crash %q", id)
+		return
+	}
+	if !isAPICrashFile(file) {
+		apiError(w, http.StatusBadRequest, "bad crash file %q", file)
+		return
+	}
+	data, err := ioutil.ReadFile(filepath.Join(mgr.crashdir, id, file))
+	if err != nil {
+		apiError(w, http.StatusNotFound, "failed to read %v: %v", file, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(data)
+}
+
+// isAPICrashFile returns true if the crash directory file can be served via API.
+func isAPICrashFile(file string) bool {
+	if apiCrashFiles[file] {
+		return true
+	}
+	for _, prefix := range []string{"log", "report", "metadata"} {
+		if strings.HasPrefix(file, prefix) {
+			index := file[len(prefix):]
+			if prefix == "metadata" {
+				if !strings.HasSuffix(index, ".json") {
+					return false
+				}
+				index = strings.TrimSuffix(index, ".json")
+			}
+			_, err := strconv.ParseUint(index, 10, 64)
+			return err == nil
+		}
+	}
+	return false
+}
+
+func (mgr *Manager) apiCover(w http.ResponseWriter, r *http.Request) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	type CallCov struct {
+		count int
+		cov   cover.Cover
+	}
+	calls := make(map[string]*CallCov)
+	for _, inp := range mgr.corpus {
+		if calls[inp.Call] == nil {
+			calls[inp.Call] = new(CallCov)
+		}
+		cc := calls[inp.Call]
+		cc.count++
+		cc.cov = cover.Union(cc.cov, cover.Cover(inp.Cover))
+	}
+	res := []*APICallCover{}
+	for c, cc := range calls {
+		res = append(res, &APICallCover{
+			Name:   c,
+			Inputs: cc.count,
+			Cover:  len(cc.cov),
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	apiReply(w, res)
+}
+
+func (mgr *Manager) apiPause(w http.ResponseWriter, r *http.Request) {
+	mgr.apiSetPaused(w, r, true)
+}
+
+func (mgr *Manager) apiResume(w http.ResponseWriter, r *http.Request) {
+	mgr.apiSetPaused(w, r, false)
+}
+
+func (mgr *Manager) apiSetPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		apiError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	mgr.mu.Lock()
+	changed := mgr.paused != paused
+	mgr.paused = paused
+	mgr.mu.Unlock()
+	if changed {
+		Logf(0, "fuzzing paused=%v via API", paused)
+		// Wake up vmLoop, it will stop or start instances.
+		select {
+		case mgr.pauseChanged <- true:
+		default:
+		}
+	}
+	mgr.apiStatus(w, r)
+}
+
+// apiRegression returns report of the last finished regression testing run.
+func (mgr *Manager) apiRegression(w http.ResponseWriter, r *http.Request) {
+	mgr.mu.Lock()
+	rep := mgr.lastRegression
+	mgr.mu.Unlock()
+	if rep == nil {
+		apiError(w, http.StatusNotFound, "no regression testing results")
+		return
+	}
+	apiReply(w, rep)
+}
+
+// apiSyscalls returns state of syscalls enabled in config.
+// POST requests with enable/disable parameters (comma-separated lists of syscall names
+// or name prefixes followed by '*') change the set of syscalls used for fuzzing.
+func (mgr *Manager) apiSyscalls(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if err := mgr.changeSyscalls(r); err != nil {
+			apiError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+	}
+	apiReply(w, mgr.syscallStates())
+}
+
+// changeSyscalls applies enable/disable request parameters.
+func (mgr *Manager) changeSyscalls(r *http.Request) error {
+	for _, param := range []struct {
+		name   string
+		enable bool
+	}{
+		{"disable", false},
+		{"enable", true},
+	} {
+		var patterns []string
+		for _, pattern := range strings.Split(r.FormValue(param.name), ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+		if _, err := mgr.setSyscallsEnabled(patterns, param.enable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apiSeed adds programs from the request body to the triage queue.
+// The body is a sequence of programs in the serialized form separated by empty lines.
+func (mgr *Manager) apiSeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apiError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "failed to read request: %v", err)
+		return
+	}
+	res := &APISeedResult{}
+	var candidates []RpcCandidate
+	for i, text := range splitPrograms(data) {
+		p, err := mgr.target.Deserialize(text)
+		if err != nil {
+			res.Dropped++
+			res.Errors = append(res.Errors, fmt.Sprintf("program #%v: %v", i, err))
+			continue
+		}
+		candidates = append(candidates, RpcCandidate{
+			Prog:      p.Serialize(),
+			Minimized: false,
+		})
+	}
+	res.Added = len(candidates)
+	mgr.mu.Lock()
+	mgr.candidates = append(mgr.candidates, candidates...)
+	mgr.stats["api seed"] += uint64(len(candidates))
+	mgr.mu.Unlock()
+	Logf(0, "added %v seed programs via API (%v dropped)", res.Added, res.Dropped)
+	apiReply(w, res)
+}
+
+// splitPrograms splits text with programs separated by empty lines.
+func splitPrograms(data []byte) [][]byte {
+	var progs [][]byte
+	var cur []byte
+	for _, line := range bytes.SplitAfter(data, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			if len(cur) != 0 {
+				progs = append(progs, cur)
+				cur = nil
+			}
+			continue
+		}
+		cur = append(cur, line...)
+	}
+	if len(cur) != 0 {
+		progs = append(progs, cur)
+	}
+	return progs
+}
+
+func apiReply(w http.ResponseWriter, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "failed to marshal reply: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func apiError(w http.ResponseWriter, code int, msg string, args ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	data, _ := json.Marshal(struct{ Error string }{fmt.Sprintf(msg, args...)})
+	w.Write(data)
+}
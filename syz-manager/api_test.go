@@ -0,0 +1,43 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitPrograms(t *testing.T) {
+	data := []byte("\nmmap(0x0, 0x1000)\nclose(0x1)\n\n\n  \ngetpid()")
+	want := []string{"mmap(0x0, 0x1000)\nclose(0x1)\n", "getpid()"}
+	var got []string
+	for _, p := range splitPrograms(data) {
+		got = append(got, string(p))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestIsAPICrashFile(t *testing.T) {
+	tests := map[string]bool{
+		"repro.prog":     true,
+		"repro.cprog":    true,
+		"log0":           true,
+		"report12":       true,
+		"metadata3.json": true,
+		"kernel.config":  true,
+		"metadata3":      false,
+		"log":            false,
+		"logx":           false,
+		"../config":      false,
+		"repro.prog/x":   false,
+		"":               false,
+	}
+	for file, want := range tests {
+		if got := isAPICrashFile(file); got != want {
+			t.Errorf("%q: got %v, want %v", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+// With coverage_bias enabled fuzzers generate calls to syscalls that historically
+// gave little new coverage (rarely covered or newly enabled syscalls) more frequently.
+// Manager maintains the amount of new signal found by corpus inputs of every syscall,
+// decays it over time (so that syscalls that stopped giving new coverage lose the advantage)
+// and periodically sends per-syscall weights derived from it to fuzzers,
+// which scale the choice table with them. The stats are not persisted across restarts.
+
+import (
+	"math"
+	"time"
+
+	. "github.com/google/syzkaller/pkg/log"
+)
+
+const (
+	biasPeriod = 10 * time.Minute // how often weights are recalculated and sent to fuzzers
+	biasDecay  = 0.9              // signal stats are multiplied by this every biasPeriod
+	biasMax    = 4                // max weight of a syscall
+)
+
+// recordCallSignal accounts the amount of new signal found by an input of syscall id.
+func (mgr *Manager) recordCallSignal(id, signal int) {
+	if mgr.callSignal == nil {
+		mgr.callSignal = make([]float64, len(mgr.target.Syscalls))
+	}
+	mgr.callSignal[id] += float64(signal)
+}
+
+// updateCallWeights decays the signal stats, recalculates syscall weights
+// and schedules sending them to all fuzzers.
+func (mgr *Manager) updateCallWeights() {
+	mgr.lastBias = time.Now()
+	if mgr.callSignal == nil {
+		mgr.callSignal = make([]float64, len(mgr.target.Syscalls))
+	}
+	mgr.callWeights = callWeights(mgr.callSignal, mgr.syscalls)
+	for i := range mgr.callSignal {
+		mgr.callSignal[i] *= biasDecay
+	}
+	boosted := 0
+	for _, w := range mgr.callWeights {
+		if w > 1 {
+			boosted++
+		}
+	}
+	Logf(1, "coverage bias: %v syscalls are boosted", boosted)
+	for _, f := range mgr.fuzzers {
+		f.updateCalls = true
+	}
+}
+
+// callWeights returns weights of choosing syscalls in [1, biasMax]: syscalls enabled in config
+// that found less new signal than the average one get weights inversely proportional
+// to the square root of their signal (square root smooths differences between syscalls).
+func callWeights(signal []float64, enabled map[int]bool) []float32 {
+	weights := make([]float32, len(signal))
+	total := 0.0
+	for id := range enabled {
+		total += signal[id]
+	}
+	avg := total / float64(len(enabled))
+	for id := range weights {
+		weights[id] = 1
+		if !enabled[id] {
+			continue
+		}
+		w := math.Sqrt((avg + 1) / (signal[id] + 1))
+		weights[id] = float32(math.Max(1, math.Min(biasMax, w)))
+	}
+	return weights
+}
@@ -0,0 +1,30 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCallWeights(t *testing.T) {
+	signal := []float64{0, 99, 399, 0, 1599, 1}
+	enabled := map[int]bool{0: true, 1: true, 2: true, 4: true, 5: true}
+	// The average signal is 419.6, so rarely covered syscalls are boosted,
+	// the newly enabled syscall 0 up to biasMax, and syscalls covered better
+	// than the average ones, as well as disabled syscall 3, keep weight 1.
+	want := []float32{biasMax, 2.0509, 1.0254, 1, 1, biasMax}
+	got := callWeights(signal, enabled)
+	if len(got) != len(want) {
+		t.Fatalf("got %v weights, want %v", len(got), len(want))
+	}
+	for i := range got {
+		if d := got[i] - want[i]; d > 1e-3 || d < -1e-3 {
+			t.Errorf("syscall %v: got weight %v, want %v", i, got[i], want[i])
+		}
+	}
+	if got := callWeights([]float64{5, 5}, map[int]bool{0: true, 1: true}); !reflect.DeepEqual(got, []float32{1, 1}) {
+		t.Errorf("equal signal: got weights %v, want all 1", got)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/google/syzkaller/pkg/hash"
+	. "github.com/google/syzkaller/pkg/log"
+	. "github.com/google/syzkaller/pkg/rpctype"
+)
+
+// Corpus rotation.
+// Programs added early during fuzzing tend to be large, since they were minimized
+// against a small corpus. Later their signal is frequently fully covered by newer
+// and smaller programs, and they only dilute the corpus for mutation.
+// rotateCorpus periodically retires such programs.
+
+type corpusInfo struct {
+	added   time.Time // when the program was first added to the corpus
+	calls   int       // number of calls in the program
+	crashes int       // number of crash logs the program was found in
+}
+
+const (
+	// Programs younger than this are never retired.
+	corpusRetireAge    = time.Hour
+	corpusRotatePeriod = 10 * time.Minute
+)
+
+// addCorpusInfo records metadata of a new corpus program and returns the time
+// it was added to the corpus (persisted in corpus database as record seq).
+func (mgr *Manager) addCorpusInfo(sig string, data []byte) time.Time {
+	added := time.Now()
+	if rec, ok := mgr.corpusDB.Records[sig]; ok && rec.Seq != 0 {
+		added = time.Unix(int64(rec.Seq), 0)
+	}
+	mgr.corpusInfo[sig] = &corpusInfo{
+		added: added,
+		calls: countCalls(data),
+	}
+	return added
+}
+
+// countCalls returns number of calls in the serialized program.
+// Serialized programs contain one call per line, besides comments (e.g. prog.FaultComment).
+func countCalls(data []byte) int {
+	calls := 0
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		line = bytes.TrimSpace(line)
+		if len(line) != 0 && line[0] != '#' {
+			calls++
+		}
+	}
+	return calls
+}
+
+// noteCrashPrograms marks corpus programs that were executed before the crash.
+// Such programs are never retired.
+func (mgr *Manager) noteCrashPrograms(log []byte) {
+	for _, ent := range mgr.target.ParseLog(log) {
+		if info := mgr.corpusInfo[hash.String(ent.P.Serialize())]; info != nil {
+			info.crashes++
+		}
+	}
+}
+
+func (mgr *Manager) rotateCorpus() {
+	if time.Since(mgr.lastRotation) < corpusRotatePeriod {
+		return
+	}
+	mgr.lastRotation = time.Now()
+	for sig := range mgr.corpusInfo {
+		if _, ok := mgr.corpus[sig]; !ok {
+			delete(mgr.corpusInfo, sig)
+		}
+	}
+	retired := retiredPrograms(mgr.corpus, mgr.corpusInfo, time.Now())
+	for _, sig := range retired {
+		delete(mgr.corpus, sig)
+		delete(mgr.corpusInfo, sig)
+	}
+	if len(retired) != 0 {
+		Logf(1, "retired %v corpus programs subsumed by newer smaller programs", len(retired))
+		mgr.stats["corpus retired"] += uint64(len(retired))
+	}
+}
+
+// retiredPrograms returns corpus programs whose signal is fully covered by newer
+// programs with fewer calls. Programs that were seen in crash logs
+// and programs younger than corpusRetireAge are never retired.
+func retiredPrograms(corpus map[string]RpcInput, infos map[string]*corpusInfo, now time.Time) []string {
+	type entry struct {
+		sig  string
+		inp  RpcInput
+		info *corpusInfo
+	}
+	var entries []entry
+	for sig, inp := range corpus {
+		if info := infos[sig]; info != nil {
+			entries = append(entries, entry{sig, inp, info})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].info.calls != entries[j].info.calls {
+			return entries[i].info.calls < entries[j].info.calls
+		}
+		return entries[i].sig < entries[j].sig
+	})
+	// latest[s] is the latest time a program with s in signal and with fewer calls
+	// than the current group of programs was added. If it is later than the time
+	// a program was added for all elements of its signal, the program is subsumed.
+	latest := make(map[uint32]time.Time)
+	var retired []string
+	for i := 0; i < len(entries); {
+		j := i
+		for j < len(entries) && entries[j].info.calls == entries[i].info.calls {
+			j++
+		}
+		group := entries[i:j]
+		i = j
+		for _, e := range group {
+			if e.info.crashes != 0 || now.Sub(e.info.added) < corpusRetireAge || len(e.inp.Signal) == 0 {
+				continue
+			}
+			subsumed := true
+			for _, s := range e.inp.Signal {
+				if !latest[s].After(e.info.added) {
+					subsumed = false
+					break
+				}
+			}
+			if subsumed {
+				retired = append(retired, e.sig)
+			}
+		}
+		for _, e := range group {
+			for _, s := range e.inp.Signal {
+				if latest[s].Before(e.info.added) {
+					latest[s] = e.info.added
+				}
+			}
+		}
+	}
+	sort.Strings(retired)
+	return retired
+}
@@ -0,0 +1,59 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/google/syzkaller/pkg/rpctype"
+)
+
+func TestRetiredPrograms(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-3 * corpusRetireAge)
+	newer := now.Add(-2 * corpusRetireAge)
+	corpus := map[string]RpcInput{
+		"old-big":       {Signal: []uint32{1, 2, 3}},
+		"old-crashed":   {Signal: []uint32{1, 2}},
+		"old-unique":    {Signal: []uint32{1, 4}},
+		"new-small1":    {Signal: []uint32{1, 2}},
+		"new-small2":    {Signal: []uint32{3}},
+		"young-big":     {Signal: []uint32{1}},
+		"old-small":     {Signal: []uint32{2}},
+		"new-same-size": {Signal: []uint32{2, 3}},
+	}
+	infos := map[string]*corpusInfo{
+		"old-big":       {added: old, calls: 5},
+		"old-crashed":   {added: old, calls: 5, crashes: 1},
+		"old-unique":    {added: old, calls: 5},
+		"new-small1":    {added: newer, calls: 2},
+		"new-small2":    {added: newer, calls: 3},
+		"young-big":     {added: now, calls: 10},
+		"old-small":     {added: old, calls: 1},
+		"new-same-size": {added: newer, calls: 1},
+	}
+	got := retiredPrograms(corpus, infos, now)
+	want := []string{"old-big"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got retired %v, want %v", got, want)
+	}
+}
+
+func TestCountCalls(t *testing.T) {
+	tests := []struct {
+		data  string
+		calls int
+	}{
+		{"getpid()\n", 1},
+		{"getpid()\ngettid()", 2},
+		{"# fault-call:1 fault-nth:2\ngetpid()\ngettid()\n", 2},
+	}
+	for _, test := range tests {
+		if calls := countCalls([]byte(test.data)); calls != test.calls {
+			t.Errorf("program %q: got %v calls, want %v", test.data, calls, test.calls)
+		}
+	}
+}
@@ -11,9 +11,11 @@ import (
 	"io"
 	"io/ioutil"
 	"os/exec"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/syzkaller/pkg/cover"
 	. "github.com/google/syzkaller/pkg/log"
@@ -162,6 +164,105 @@ func generateCoverHtml(w io.Writer, vmlinux string, cov []uint32) error {
 	return nil
 }
 
+// coverExporter exports coverage of the corpus as LCOV data and per-file HTML (see Cover_Export_Period).
+// Symbolization results are cached, so every export symbolizes only PCs that were not seen before.
+type coverExporter struct {
+	vmlinux  string
+	exporter *cover.Exporter
+	frames   map[uint64][]symbolizer.Frame
+}
+
+func newCoverExporter(vmlinux, dir, srcDir string) *coverExporter {
+	return &coverExporter{
+		vmlinux:  vmlinux,
+		exporter: cover.NewExporter(dir, srcDir),
+		frames:   make(map[uint64][]symbolizer.Frame),
+	}
+}
+
+// export exports coverage of the given inputs, it returns number of regenerated file pages.
+// Line counters are numbers of inputs that cover the line.
+func (ce *coverExporter) export(inputs []cover.Cover) (int, error) {
+	base, err := getVmOffset(ce.vmlinux)
+	if err != nil {
+		return 0, err
+	}
+	counts := make(map[uint64]int)
+	for _, cov := range inputs {
+		for _, pc := range cov {
+			counts[cover.RestorePC(pc, base)-callLen]++
+		}
+	}
+	pcs := make([]uint64, 0, len(counts))
+	for pc := range counts {
+		pcs = append(pcs, pc)
+	}
+	uncovered, err := uncoveredPcsInFuncs(ce.vmlinux, pcs)
+	if err != nil {
+		return 0, err
+	}
+	var unknown []uint64
+	for _, pcs := range [][]uint64{pcs, uncovered} {
+		for _, pc := range pcs {
+			if _, ok := ce.frames[pc]; !ok {
+				unknown = append(unknown, pc)
+			}
+		}
+	}
+	if len(unknown) != 0 {
+		symb := symbolizer.NewSymbolizer()
+		frames, err := symb.SymbolizeArray(ce.vmlinux, unknown)
+		symb.Close()
+		if err != nil {
+			return 0, err
+		}
+		for _, pc := range unknown {
+			ce.frames[pc] = nil // remember PCs without debug info too
+		}
+		for _, frame := range frames {
+			ce.frames[frame.PC] = append(ce.frames[frame.PC], frame)
+		}
+	}
+	lines := make(cover.LineCoverage)
+	for _, pc := range uncovered {
+		for _, frame := range ce.frames[pc] {
+			lines.Add(frame.File, frame.Line, 0)
+		}
+	}
+	// A line can contain several PCs, the number of inputs that cover the line
+	// is approximated by the maximum over its PCs.
+	for _, pc := range pcs {
+		for _, frame := range ce.frames[pc] {
+			lines.Add(frame.File, frame.Line, 0)
+			if lines[frame.File][frame.Line] < counts[pc] {
+				lines[frame.File][frame.Line] = counts[pc]
+			}
+		}
+	}
+	return ce.exporter.Export(lines)
+}
+
+func (mgr *Manager) exportCoverLoop() {
+	ce := newCoverExporter(mgr.cfg.Vmlinux, filepath.Join(mgr.cfg.Workdir, "cover"), mgr.cfg.Kernel_Src)
+	for {
+		time.Sleep(time.Duration(mgr.cfg.Cover_Export_Period) * time.Minute)
+		mgr.mu.Lock()
+		inputs := make([]cover.Cover, 0, len(mgr.corpus))
+		for _, inp := range mgr.corpus {
+			inputs = append(inputs, cover.Cover(inp.Cover))
+		}
+		mgr.mu.Unlock()
+		start := time.Now()
+		updated, err := ce.export(inputs)
+		if err != nil {
+			Logf(0, "failed to export coverage: %v", err)
+			continue
+		}
+		Logf(1, "exported coverage of %v inputs (%v files updated) in %v",
+			len(inputs), updated, time.Since(start))
+	}
+}
+
 func fileSet(covered, uncovered []symbolizer.Frame) map[string][]coverage {
 	files := make(map[string]map[int]bool)
 	funcs := make(map[string]bool)
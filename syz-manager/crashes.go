@@ -0,0 +1,249 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+// Crash artifacts retention and indexing.
+// Every crash dir has index.json that summarizes all crashes of the bug (including the ones
+// whose logs were already deleted), and the number/total size of saved crash logs is limited
+// (see Max_Crash_Logs and Max_Crash_Disk), so that long-running managers don't fill disks.
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// CrashIndex is the contents of index.json file in a crash dir.
+type CrashIndex struct {
+	Title     string
+	Count     int // total number of crashes, including deleted ones
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Distinct kernel versions the crash happened on, the most recent is the last.
+	KernelVersions []string
+}
+
+const (
+	crashIndexFile     = "index.json"
+	maxIndexedVersions = 20
+)
+
+// updateCrashIndex records a new crash of the bug with the given kernel version.
+func updateCrashIndex(dir, title, version string, now time.Time) {
+	idx := readCrashIndex(dir)
+	if idx == nil {
+		idx = &CrashIndex{FirstSeen: now}
+	}
+	idx.Title = title
+	idx.Count++
+	idx.LastSeen = now
+	if version != "" {
+		for i, v := range idx.KernelVersions {
+			if v == version {
+				idx.KernelVersions = append(idx.KernelVersions[:i], idx.KernelVersions[i+1:]...)
+				break
+			}
+		}
+		idx.KernelVersions = append(idx.KernelVersions, version)
+		if len(idx.KernelVersions) > maxIndexedVersions {
+			idx.KernelVersions = idx.KernelVersions[len(idx.KernelVersions)-maxIndexedVersions:]
+		}
+	}
+	data, err := json.MarshalIndent(idx, "", "\t")
+	if err != nil {
+		Logf(0, "failed to marshal crash index: %v", err)
+		return
+	}
+	if err := osutil.WriteFile(filepath.Join(dir, crashIndexFile), data); err != nil {
+		Logf(0, "failed to write crash index: %v", err)
+	}
+}
+
+func readCrashIndex(dir string) *CrashIndex {
+	data, err := ioutil.ReadFile(filepath.Join(dir, crashIndexFile))
+	if err != nil {
+		return nil
+	}
+	idx := new(CrashIndex)
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil
+	}
+	return idx
+}
+
+// Linux oops header, e.g. "CPU: 0 PID: 1 Comm: syz-executor Not tainted 4.17.0-rc4+ #5".
+var kernelVersionRe = regexp.MustCompile(`(?:Not tainted|Tainted:[A-Z ]*) ([0-9][^ \n]*)`)
+
+// kernelVersion returns version of the kernel the crash happened on: taken from the report
+// or the log, if the kernel prints it, otherwise the kernel commit or the tag of the manager.
+func kernelVersion(rep, log []byte, prov *Provenance) string {
+	for _, data := range [][]byte{rep, log} {
+		if match := kernelVersionRe.FindSubmatch(data); match != nil {
+			return string(match[1])
+		}
+	}
+	if prov.KernelCommit != "" {
+		return prov.KernelCommit
+	}
+	return prov.Tag
+}
+
+// savedCrash is a single saved crash: logN file and the accompanying reportN, tagN and metadataN.json.
+type savedCrash struct {
+	dir   string
+	index int
+	time  time.Time
+	files []string
+	size  int64
+}
+
+var savedCrashPrefixes = []string{"log", "report", "tag", "metadata"}
+
+// savedCrashIndex returns index of the saved crash the file belongs to, or -1.
+func savedCrashIndex(file string) int {
+	for _, prefix := range savedCrashPrefixes {
+		if !strings.HasPrefix(file, prefix) {
+			continue
+		}
+		num := strings.TrimSuffix(file[len(prefix):], ".json")
+		if prefix == "metadata" && num == file[len(prefix):] {
+			return -1
+		}
+		index, err := strconv.ParseUint(num, 10, 32)
+		if err != nil {
+			return -1
+		}
+		return int(index)
+	}
+	return -1
+}
+
+// enforceCrashRetention deletes old crash logs so that every crash dir contains
+// at most maxPerBug saved crashes and crashdir takes at most maxDisk bytes (0 means no limit).
+// Under the disk limit the oldest crashes are deleted first, but the newest crash of every bug,
+// descriptions, indexes and reproducers are never deleted. Returns number of deleted crashes.
+func enforceCrashRetention(crashdir string, maxPerBug int, maxDisk int64) int {
+	dirs, err := osutil.ListDir(crashdir)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	var candidates []*savedCrash
+	deleted := 0
+	for _, dir := range dirs {
+		crashes, size, n := trimSavedCrashes(filepath.Join(crashdir, dir), maxPerBug)
+		total += size
+		deleted += n
+		if len(crashes) > 1 {
+			candidates = append(candidates, crashes[1:]...)
+		}
+	}
+	if maxDisk != 0 && total > maxDisk {
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].time.Before(candidates[j].time)
+		})
+		for _, crash := range candidates {
+			if total <= maxDisk {
+				break
+			}
+			crash.remove()
+			total -= crash.size
+			deleted++
+		}
+		if total > maxDisk {
+			Logf(0, "crashes take %v MB even after deleting old logs, max_crash_disk is %v MB",
+				total>>20, maxDisk>>20)
+		}
+	}
+	return deleted
+}
+
+// trimCrashDir deletes the oldest crash logs in a single crash dir, so that it contains
+// at most maxPerBug saved crashes. Returns number of deleted crashes.
+func trimCrashDir(dir string, maxPerBug int) int {
+	_, _, deleted := trimSavedCrashes(dir, maxPerBug)
+	return deleted
+}
+
+// trimSavedCrashes is trimCrashDir that also returns the remaining crashes (the newest first)
+// and the remaining size of all files in the dir.
+func trimSavedCrashes(dir string, maxPerBug int) ([]*savedCrash, int64, int) {
+	crashes, total := listSavedCrashes(dir)
+	sort.Slice(crashes, func(i, j int) bool {
+		return crashes[i].time.After(crashes[j].time)
+	})
+	deleted := 0
+	if len(crashes) > maxPerBug {
+		for _, crash := range crashes[maxPerBug:] {
+			crash.remove()
+			total -= crash.size
+			deleted++
+		}
+		crashes = crashes[:maxPerBug]
+	}
+	return crashes, total, deleted
+}
+
+// listSavedCrashes returns saved crashes in the crash dir and the total size of all files in the dir.
+func listSavedCrashes(dir string) ([]*savedCrash, int64) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, 0
+	}
+	var total int64
+	crashes := make(map[int]*savedCrash)
+	for _, f := range files {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		total += f.Size()
+		index := savedCrashIndex(f.Name())
+		if index < 0 {
+			continue
+		}
+		crash := crashes[index]
+		if crash == nil {
+			crash = &savedCrash{dir: dir, index: index}
+			crashes[index] = crash
+		}
+		crash.files = append(crash.files, f.Name())
+		crash.size += f.Size()
+		if strings.HasPrefix(f.Name(), "log") {
+			crash.time = f.ModTime()
+		}
+	}
+	var res []*savedCrash
+	for _, crash := range crashes {
+		res = append(res, crash)
+	}
+	return res, total
+}
+
+func (crash *savedCrash) remove() {
+	for _, f := range crash.files {
+		os.Remove(filepath.Join(crash.dir, f))
+	}
+}
+
+// matchCrash returns true if the crash matches the search query:
+// all space-separated words of the query must be contained (case-insensitively)
+// in the title, ID or kernel versions of the crash.
+func matchCrash(crash *UICrashType, query string) bool {
+	text := strings.ToLower(crash.Description + " " + crash.ID + " " + strings.Join(crash.KernelVersions, " "))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,294 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+func TestCrashIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syz-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if readCrashIndex(dir) != nil {
+		t.Fatalf("read non-existent index")
+	}
+	t0 := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	updateCrashIndex(dir, "title", "4.15.0", t0)
+	updateCrashIndex(dir, "title", "4.16.0", t0.Add(time.Hour))
+	updateCrashIndex(dir, "title", "", t0.Add(2*time.Hour))
+	updateCrashIndex(dir, "title", "4.15.0", t0.Add(3*time.Hour))
+	idx := readCrashIndex(dir)
+	want := &CrashIndex{
+		Title:          "title",
+		Count:          4,
+		FirstSeen:      t0,
+		LastSeen:       t0.Add(3 * time.Hour),
+		KernelVersions: []string{"4.16.0", "4.15.0"},
+	}
+	if idx == nil || !idx.FirstSeen.Equal(want.FirstSeen) || !idx.LastSeen.Equal(want.LastSeen) {
+		t.Fatalf("bad index: %+v", idx)
+	}
+	idx.FirstSeen, idx.LastSeen = want.FirstSeen, want.LastSeen
+	if !reflect.DeepEqual(idx, want) {
+		t.Fatalf("got index:\n%+v\nwant:\n%+v", idx, want)
+	}
+	for i := 0; i < 2*maxIndexedVersions; i++ {
+		updateCrashIndex(dir, "title", fmt.Sprintf("v%v", i), t0)
+	}
+	idx = readCrashIndex(dir)
+	if len(idx.KernelVersions) != maxIndexedVersions ||
+		idx.KernelVersions[maxIndexedVersions-1] != fmt.Sprintf("v%v", 2*maxIndexedVersions-1) {
+		t.Fatalf("bad kernel versions: %q", idx.KernelVersions)
+	}
+}
+
+func TestKernelVersion(t *testing.T) {
+	prov := &Provenance{Tag: "tag", KernelCommit: "1111111111111111111111111111111111111111"}
+	tests := []struct {
+		rep  string
+		log  string
+		prov *Provenance
+		want string
+	}{
+		{
+			rep:  "CPU: 0 PID: 4195 Comm: syz-executor0 Not tainted 4.17.0-rc4+ #5\nHardware name: QEMU",
+			prov: prov,
+			want: "4.17.0-rc4+",
+		},
+		{
+			rep:  "CPU: 1 PID: 1 Comm: swapper/0 Tainted: G    B   W         4.15.0-next-20180123 #1\n",
+			prov: prov,
+			want: "4.15.0-next-20180123",
+		},
+		{
+			rep:  "no version here",
+			log:  "[   10.1] CPU: 0 PID: 1 Comm: init Not tainted 4.9.0 #1\n",
+			prov: prov,
+			want: "4.9.0",
+		},
+		{
+			rep:  "no version here",
+			prov: prov,
+			want: prov.KernelCommit,
+		},
+		{
+			prov: &Provenance{Tag: "tag"},
+			want: "tag",
+		},
+	}
+	for i, test := range tests {
+		if got := kernelVersion([]byte(test.rep), []byte(test.log), test.prov); got != test.want {
+			t.Errorf("#%v: got %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+func TestSavedCrashIndex(t *testing.T) {
+	tests := map[string]int{
+		"log0":                0,
+		"log99":               99,
+		"report12":            12,
+		"tag3":                3,
+		"metadata7.json":      7,
+		"metadata7":           -1,
+		"description":         -1,
+		"repro.log":           -1,
+		"repro.report":        -1,
+		"repro.metadata.json": -1,
+		"regression":          -1,
+		"logs":                -1,
+		crashIndexFile:        -1,
+		kernelConfigFile:      -1,
+	}
+	for file, want := range tests {
+		if got := savedCrashIndex(file); got != want {
+			t.Errorf("%v: got %v, want %v", file, got, want)
+		}
+	}
+}
+
+func TestCrashRetention(t *testing.T) {
+	crashdir, err := ioutil.TempDir("", "syz-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(crashdir)
+	t0 := time.Now().Add(-time.Hour)
+	// Bug "a" has 5 crashes, bug "b" has 2, every crash takes 1000 bytes (log + report).
+	// Crash times are interleaved: a0 b0 a1 b1 a2 a3 a4.
+	times := map[string][]time.Duration{
+		"a": {0, 2, 4, 5, 6},
+		"b": {1, 3},
+	}
+	for bug, offsets := range times {
+		dir := filepath.Join(crashdir, bug)
+		osutil.MkdirAll(dir)
+		osutil.WriteFile(filepath.Join(dir, "description"), []byte(bug))
+		osutil.WriteFile(filepath.Join(dir, "repro.prog"), make([]byte, 100))
+		for i, off := range offsets {
+			for _, f := range []string{"log", "report"} {
+				file := filepath.Join(dir, fmt.Sprintf("%v%v", f, i))
+				osutil.WriteFile(file, make([]byte, 500))
+				ts := t0.Add(off * time.Minute)
+				if err := os.Chtimes(file, ts, ts); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	saved := func() map[string][]string {
+		res := make(map[string][]string)
+		for bug := range times {
+			files, _ := osutil.ListDir(filepath.Join(crashdir, bug))
+			sort.Strings(files)
+			res[bug] = files
+		}
+		return res
+	}
+
+	// Per-bug limit: the 2 oldest crashes of "a" are deleted.
+	if deleted := enforceCrashRetention(crashdir, 3, 0); deleted != 2 {
+		t.Fatalf("deleted %v crashes, want 2", deleted)
+	}
+	want := map[string][]string{
+		"a": {"description", "log2", "log3", "log4", "report2", "report3", "report4", "repro.prog"},
+		"b": {"description", "log0", "log1", "report0", "report1", "repro.prog"},
+	}
+	if got := saved(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got files:\n%q\nwant:\n%q", got, want)
+	}
+
+	// Disk limit: 5 crashes and other files take 5202 bytes,
+	// the oldest crashes b0 and a2 are deleted to fit into 3500.
+	if deleted := enforceCrashRetention(crashdir, 3, 3500); deleted != 2 {
+		t.Fatalf("deleted %v crashes, want 2", deleted)
+	}
+	want = map[string][]string{
+		"a": {"description", "log3", "log4", "report3", "report4", "repro.prog"},
+		"b": {"description", "log1", "report1", "repro.prog"},
+	}
+	if got := saved(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got files:\n%q\nwant:\n%q", got, want)
+	}
+
+	// The last crash of every bug is never deleted, even if the limit is not satisfied.
+	if deleted := enforceCrashRetention(crashdir, 3, 1); deleted != 1 {
+		t.Fatalf("deleted %v crashes, want 1", deleted)
+	}
+	want = map[string][]string{
+		"a": {"description", "log4", "report4", "repro.prog"},
+		"b": {"description", "log1", "report1", "repro.prog"},
+	}
+	if got := saved(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got files:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestMatchCrash(t *testing.T) {
+	crash := &UICrashType{
+		Description:    "KASAN: use-after-free Read in tcp_sendmsg",
+		ID:             "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+		KernelVersions: []string{"4.16.0-rc7+", "4.17.0"},
+	}
+	tests := map[string]bool{
+		"":                    true,
+		"kasan":               true,
+		"TCP_SENDMSG":         true,
+		"kasan tcp":           true,
+		"kasan udp":           false,
+		"4.17":                true,
+		"4.18":                false,
+		"da39a3ee":            true,
+		"use-after-free 4.16": true,
+	}
+	for query, want := range tests {
+		if got := matchCrash(crash, query); got != want {
+			t.Errorf("%q: got %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestTrimCrashDir(t *testing.T) {
+	crashdir, err := ioutil.TempDir("", "syz-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(crashdir)
+	t0 := time.Now().Add(-time.Hour)
+	for _, bug := range []string{"a", "b"} {
+		dir := filepath.Join(crashdir, bug)
+		osutil.MkdirAll(dir)
+		osutil.WriteFile(filepath.Join(dir, "description"), []byte(bug))
+		// log1 is the newest crash, log2 is the oldest one.
+		for i, off := range []time.Duration{1, 2, 0} {
+			file := filepath.Join(dir, fmt.Sprintf("log%v", i))
+			osutil.WriteFile(file, nil)
+			ts := t0.Add(off * time.Minute)
+			if err := os.Chtimes(file, ts, ts); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if deleted := trimCrashDir(filepath.Join(crashdir, "a"), 2); deleted != 1 {
+		t.Fatalf("deleted %v crashes, want 1", deleted)
+	}
+	if deleted := trimCrashDir(filepath.Join(crashdir, "a"), 1); deleted != 1 {
+		t.Fatalf("deleted %v crashes, want 1", deleted)
+	}
+	for bug, want := range map[string][]string{
+		"a": {"description", "log1"},
+		"b": {"description", "log0", "log1", "log2"},
+	} {
+		files, _ := osutil.ListDir(filepath.Join(crashdir, bug))
+		sort.Strings(files)
+		if !reflect.DeepEqual(files, want) {
+			t.Fatalf("bug %v: got files %q, want %q", bug, files, want)
+		}
+	}
+}
+
+func TestReadCrashID(t *testing.T) {
+	workdir, err := ioutil.TempDir("", "syz-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(workdir)
+	id := hash.String([]byte("title"))
+	for _, dir := range []string{filepath.Join("crashes", id), "secret"} {
+		if err := osutil.MkdirAll(filepath.Join(workdir, dir)); err != nil {
+			t.Fatal(err)
+		}
+		if err := osutil.WriteFile(filepath.Join(workdir, dir, "description"), []byte("title\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if crash := readCrash(workdir, id, false); crash == nil || crash.Description != "title" {
+		t.Fatalf("failed to read crash %v: %+v", id, crash)
+	}
+	for _, bad := range []string{
+		"",
+		strings.ToUpper(id),
+		"../secret" + strings.Repeat("/", 31),
+		"../crashes/" + id[:29],
+		id[:39] + "/",
+	} {
+		if crash := readCrash(workdir, bad, false); crash != nil {
+			t.Errorf("read crash with bad id %q: %+v", bad, crash)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+// Email reporting for private instances that don't use dashboard.
+// Every new unique crash is emailed once with the report and the tail of the log,
+// and then once more when a reproducer is found. Sent emails are recorded
+// in the crash dir ("email" and "repro.email" files), so bugs are not reported
+// again after manager restart. If the hourly email limit is reached,
+// the email is not sent and the bug is reported on the next occurrence.
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/smtp"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/syzkaller/pkg/email"
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+)
+
+const (
+	emailCrashFile = "email"
+	emailReproFile = "repro.email"
+	emailLogTail   = 100 // number of trailing log lines included into crash emails
+)
+
+type Emailer struct {
+	cfg  *mgrconfig.Config
+	send func(to []string, msg []byte) error
+
+	mu      sync.Mutex
+	sent    []time.Time // times of emails sent during the last hour
+	pending map[string]bool
+}
+
+func newEmailer(cfg *mgrconfig.Config) *Emailer {
+	em := &Emailer{
+		cfg:     cfg,
+		pending: make(map[string]bool),
+	}
+	em.send = func(to []string, msg []byte) error {
+		var auth smtp.Auth
+		if cfg.Email_User != "" {
+			host := cfg.Email_Smtp
+			if pos := strings.LastIndexByte(host, ':'); pos != -1 {
+				host = host[:pos]
+			}
+			auth = smtp.PlainAuth("", cfg.Email_User, cfg.Email_Password, host)
+		}
+		return smtp.SendMail(cfg.Email_Smtp, auth, cfg.Email_Sender, to, msg)
+	}
+	return em
+}
+
+// emailCrash emails the crash saved in dir, if it was not emailed yet.
+// If the crash was emailed, but its reproducer was not, emails the reproducer.
+func (em *Emailer) emailCrash(dir, title string, log, report []byte, prov *Provenance) {
+	if !osutil.IsExist(filepath.Join(dir, emailCrashFile)) {
+		body := new(bytes.Buffer)
+		fmt.Fprintf(body, "syzkaller hit the following crash on %v:\n\n%v\n\n", em.cfg.Name, title)
+		em.writeProvenance(body, title, prov)
+		if len(report) != 0 {
+			fmt.Fprintf(body, "%s\n\n", report)
+		}
+		if len(log) != 0 {
+			fmt.Fprintf(body, "Console log tail:\n%s\n\n", logTail(log, emailLogTail))
+		}
+		fmt.Fprintf(body, "The crash is saved in %v.\n", dir)
+		if em.cfg.Reproduce {
+			fmt.Fprintf(body, "A reproducer will be sent in a separate email once it is found.\n")
+		}
+		em.sendAsync(dir, emailCrashFile, em.subject(title), body.String(), nil)
+		return
+	}
+	if osutil.IsExist(filepath.Join(dir, "repro.prog")) {
+		em.emailRepro(dir, title, prov)
+	}
+}
+
+// emailRepro emails the reproducer saved in dir, if it was not emailed yet.
+func (em *Emailer) emailRepro(dir, title string, prov *Provenance) {
+	if osutil.IsExist(filepath.Join(dir, emailReproFile)) {
+		return
+	}
+	prog, err := ioutil.ReadFile(filepath.Join(dir, "repro.prog"))
+	if err != nil {
+		return
+	}
+	attachments := []email.Attachment{{Name: "repro.syz", Data: prog}}
+	body := new(bytes.Buffer)
+	fmt.Fprintf(body, "syzkaller has found a reproducer for the following crash on %v:\n\n%v\n\n", em.cfg.Name, title)
+	em.writeProvenance(body, title, prov)
+	if cprog, err := ioutil.ReadFile(filepath.Join(dir, "repro.cprog")); err == nil {
+		attachments = append(attachments, email.Attachment{Name: "repro.c", Data: cprog})
+		fmt.Fprintf(body, "C reproducer is attached as repro.c.\n")
+	}
+	fmt.Fprintf(body, "syzkaller reproducer is attached as repro.syz, it can be run with syz-execprog.\n")
+	if report, err := ioutil.ReadFile(filepath.Join(dir, "repro.report")); err == nil {
+		fmt.Fprintf(body, "\n%s\n", report)
+	}
+	em.sendAsync(dir, emailReproFile, "Re: "+em.subject(title), body.String(), attachments)
+}
+
+func (em *Emailer) subject(title string) string {
+	if em.cfg.Name == "" {
+		return "[syzkaller] " + title
+	}
+	return fmt.Sprintf("[syzkaller] %v: %v", em.cfg.Name, title)
+}
+
+func (em *Emailer) writeProvenance(w *bytes.Buffer, title string, prov *Provenance) {
+	if em.cfg.Tag != "" {
+		fmt.Fprintf(w, "Tag: %v\n", em.cfg.Tag)
+	}
+	if prov != nil {
+		meta := &CrashMetadata{Title: title, Provenance: *prov}
+		meta.writeProvenance(w)
+	}
+}
+
+// sendAsync sends the email in background unless the email limit is reached
+// or the same email is being sent. marker file is created in dir on success.
+func (em *Emailer) sendAsync(dir, marker, subject, body string, attachments []email.Attachment) {
+	key := filepath.Join(dir, marker)
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	if em.pending[key] {
+		return
+	}
+	now := time.Now()
+	for len(em.sent) != 0 && now.Sub(em.sent[0]) > time.Hour {
+		em.sent = em.sent[1:]
+	}
+	if len(em.sent) >= em.cfg.Email_Rate {
+		Logf(0, "email limit reached, not sending '%v'", subject)
+		return
+	}
+	em.sent = append(em.sent, now)
+	em.pending[key] = true
+	msg := email.FormMessage(em.cfg.Email_Sender, em.cfg.Email_Addrs, subject, body, attachments)
+	go func() {
+		err := em.send(em.cfg.Email_Addrs, msg)
+		if err != nil {
+			Logf(0, "failed to send email '%v': %v", subject, err)
+		} else {
+			osutil.WriteFile(key, []byte(now.Format(time.RFC3339)+"\n"))
+		}
+		em.mu.Lock()
+		delete(em.pending, key)
+		em.mu.Unlock()
+	}()
+}
+
+// logTail returns the last n lines of log.
+func logTail(log []byte, n int) []byte {
+	log = bytes.TrimRight(log, "\n")
+	pos := len(log)
+	for ; n > 0 && pos != -1; n-- {
+		pos = bytes.LastIndexByte(log[:pos], '\n')
+	}
+	return log[pos+1:]
+}
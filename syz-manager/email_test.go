@@ -0,0 +1,107 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+)
+
+func TestEmailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syz-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &mgrconfig.Config{
+		Name:         "ci-test",
+		Reproduce:    true,
+		Email_Addrs:  []string{"a@example.com"},
+		Email_Sender: "syzkaller@example.com",
+		Email_Rate:   2,
+	}
+	em := newEmailer(cfg)
+	sent := make(chan []byte, 10)
+	em.send = func(to []string, msg []byte) error {
+		sent <- msg
+		return nil
+	}
+	wait := func(marker string) []byte {
+		var msg []byte
+		select {
+		case msg = <-sent:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("email is not sent")
+		}
+		// The marker is written after send returns.
+		for i := 0; !osutil.IsExist(filepath.Join(dir, marker)); i++ {
+			if i == 1000 {
+				t.Fatalf("%v is not created", marker)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return msg
+	}
+	prov := &Provenance{Target: "linux/amd64", KernelCommit: "1234567890123456789012345678901234567890"}
+	log := []byte(strings.Repeat("program line\n", 1000) + "last line\n")
+	em.emailCrash(dir, "KASAN: use-after-free in foo", log, []byte("the report"), prov)
+	msg := wait(emailCrashFile)
+	for _, want := range []string{"Subject: [syzkaller] ci-test: KASAN: use-after-free in foo",
+		"the report", "last line", prov.KernelCommit} {
+		if !bytes.Contains(msg, []byte(want)) {
+			t.Errorf("crash email does not contain %q:\n%s", want, msg)
+		}
+	}
+	if n := bytes.Count(msg, []byte("program line")); n != emailLogTail-1 {
+		t.Errorf("crash email contains %v log lines, want %v", n, emailLogTail-1)
+	}
+	// The crash was already emailed and there is no repro.
+	em.emailCrash(dir, "KASAN: use-after-free in foo", log, nil, prov)
+	osutil.WriteFile(filepath.Join(dir, "repro.prog"), []byte("getpid()\n"))
+	osutil.WriteFile(filepath.Join(dir, "repro.cprog"), []byte("int main() {}\n"))
+	em.emailCrash(dir, "KASAN: use-after-free in foo", log, nil, prov)
+	msg = wait(emailReproFile)
+	for _, want := range []string{"Subject: Re: [syzkaller] ci-test: KASAN: use-after-free in foo",
+		"filename=repro.syz", "filename=repro.c"} {
+		if !bytes.Contains(msg, []byte(want)) {
+			t.Errorf("repro email does not contain %q:\n%s", want, msg)
+		}
+	}
+	em.emailRepro(dir, "KASAN: use-after-free in foo", prov)
+	// Email limit is reached.
+	dir2 := filepath.Join(dir, "2")
+	osutil.MkdirAll(dir2)
+	em.emailCrash(dir2, "WARNING in bar", log, nil, prov)
+	select {
+	case msg := <-sent:
+		t.Fatalf("unexpected email:\n%s", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLogTail(t *testing.T) {
+	tests := []struct {
+		log  string
+		n    int
+		tail string
+	}{
+		{"a\nb\nc\n", 2, "b\nc"},
+		{"a\nb\nc", 2, "b\nc"},
+		{"a\nb\nc\n", 5, "a\nb\nc"},
+		{"", 3, ""},
+	}
+	for _, test := range tests {
+		if got := string(logTail([]byte(test.log), test.n)); got != test.tail {
+			t.Errorf("logTail(%q, %v) = %q, want %q", test.log, test.n, got, test.tail)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/google/syzkaller/pkg/cover"
+	"github.com/google/syzkaller/pkg/hash"
 	. "github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/osutil"
 )
@@ -36,6 +37,13 @@ func (mgr *Manager) initHttp() {
 	http.HandleFunc("/file", mgr.httpFile)
 	http.HandleFunc("/report", mgr.httpReport)
 	http.HandleFunc("/rawcover", mgr.httpRawCover)
+	http.HandleFunc("/syscalls", mgr.httpSyscalls)
+	http.HandleFunc("/metrics", mgr.httpMetrics)
+	if mgr.cfg.Cover_Export_Period != 0 {
+		http.Handle("/coverreport/", http.StripPrefix("/coverreport/",
+			http.FileServer(http.Dir(filepath.Join(mgr.cfg.Workdir, "cover")))))
+	}
+	mgr.initAPI()
 
 	ln, err := net.Listen("tcp4", mgr.cfg.Http)
 	if err != nil {
@@ -50,7 +58,8 @@ func (mgr *Manager) initHttp() {
 
 func (mgr *Manager) httpSummary(w http.ResponseWriter, r *http.Request) {
 	data := &UISummaryData{
-		Name: mgr.cfg.Name,
+		Name:        mgr.cfg.Name,
+		CrashSearch: r.FormValue("crash_search"),
 	}
 
 	var err error
@@ -58,6 +67,15 @@ func (mgr *Manager) httpSummary(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("failed to collect crashes: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if data.CrashSearch != "" {
+		var found []*UICrashType
+		for _, crash := range data.Crashes {
+			if matchCrash(crash, data.CrashSearch) {
+				found = append(found, crash)
+			}
+		}
+		data.Crashes = found
+	}
 
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
@@ -68,10 +86,17 @@ func (mgr *Manager) httpSummary(w http.ResponseWriter, r *http.Request) {
 	data.Stats = append(data.Stats, UIStat{Name: "triage queue", Value: fmt.Sprint(len(mgr.candidates))})
 	data.Stats = append(data.Stats, UIStat{Name: "cover", Value: fmt.Sprint(len(mgr.corpusCover)), Link: "/cover"})
 	data.Stats = append(data.Stats, UIStat{Name: "signal", Value: fmt.Sprint(len(mgr.corpusSignal))})
+	data.Stats = append(data.Stats, UIStat{
+		Name:  "syscalls",
+		Value: fmt.Sprintf("%v (%v disabled)", len(mgr.syscalls)-len(mgr.disabledCalls), len(mgr.disabledCalls)),
+		Link:  "/syscalls",
+	})
 
 	type CallCov struct {
-		count int
-		cov   cover.Cover
+		count    int
+		errors   int
+		duration time.Duration
+		cov      cover.Cover
 	}
 	calls := make(map[string]*CallCov)
 	for _, inp := range mgr.corpus {
@@ -80,6 +105,10 @@ func (mgr *Manager) httpSummary(w http.ResponseWriter, r *http.Request) {
 		}
 		cc := calls[inp.Call]
 		cc.count++
+		if inp.Errno != 0 {
+			cc.errors++
+		}
+		cc.duration += inp.Duration
 		cc.cov = cover.Union(cc.cov, cover.Cover(inp.Cover))
 	}
 
@@ -94,11 +123,30 @@ func (mgr *Manager) httpSummary(w http.ResponseWriter, r *http.Request) {
 		data.Calls = append(data.Calls, UICallType{
 			Name:   c,
 			Inputs: cc.count,
+			Errors: cc.errors,
+			Time:   cc.duration / time.Duration(cc.count),
 			Cover:  len(cc.cov),
 		})
 	}
 	sort.Sort(UICallTypeArray(data.Calls))
 
+	for i, group := range mgr.cfg.Focus {
+		fg := UIFocusGroup{
+			Name:     group.Name,
+			Weight:   group.Weight,
+			Syscalls: len(mgr.focus[i]),
+		}
+		var groupCov cover.Cover
+		for id := range mgr.focus[i] {
+			if cc := calls[mgr.target.Syscalls[id].Name]; cc != nil {
+				fg.Inputs += cc.count
+				groupCov = cover.Union(groupCov, cc.cov)
+			}
+		}
+		fg.Cover = len(groupCov)
+		data.Focus = append(data.Focus, fg)
+	}
+
 	var intStats []UIStat
 	for k, v := range mgr.stats {
 		val := fmt.Sprintf("%v", v)
@@ -151,10 +199,11 @@ func (mgr *Manager) httpCorpus(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		data = append(data, UIInput{
-			Short: p.String(),
-			Full:  string(inp.Prog),
-			Cover: len(inp.Cover),
-			Sig:   sig,
+			Short:     p.String(),
+			Full:      string(inp.Prog),
+			Cover:     len(inp.Cover),
+			Stability: inp.Stability,
+			Sig:       sig,
 		})
 	}
 	sort.Sort(UIInputArray(data))
@@ -222,6 +271,25 @@ func (mgr *Manager) httpPrio(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (mgr *Manager) httpSyscalls(w http.ResponseWriter, r *http.Request) {
+	data := &UISyscallsData{}
+	if r.Method == http.MethodPost {
+		if err := mgr.changeSyscalls(r); err != nil {
+			data.Error = err.Error()
+		}
+	}
+	data.Syscalls = mgr.syscallStates()
+	for _, c := range data.Syscalls {
+		if !c.Enabled {
+			data.Disabled++
+		}
+	}
+	if err := syscallsTemplate.Execute(w, data); err != nil {
+		http.Error(w, fmt.Sprintf("failed to execute template: %v", err), http.StatusInternalServerError)
+		return
+	}
+}
+
 func (mgr *Manager) httpFile(w http.ResponseWriter, r *http.Request) {
 	file := filepath.Clean(r.FormValue("name"))
 	if !strings.HasPrefix(file, "crashes/") && !strings.HasPrefix(file, "corpus/") {
@@ -261,6 +329,13 @@ func (mgr *Manager) httpReport(w http.ResponseWriter, r *http.Request) {
 		commitDesc = fmt.Sprintf(" on commit %s.", trimNewLines(tag))
 	}
 	fmt.Fprintf(w, "Syzkaller hit '%s' bug%s.\n\n", trimNewLines(desc), commitDesc)
+	meta := readCrashMetadata(filepath.Join(mgr.crashdir, crashID, "repro.metadata.json"))
+	if meta == nil {
+		meta = readCrashMetadata(filepath.Join(mgr.crashdir, crashID, "metadata0.json"))
+	}
+	if meta != nil {
+		meta.writeProvenance(w)
+	}
 	if len(rep) != 0 {
 		guiltyFile := mgr.getReporter().ExtractGuiltyFile(rep)
 		if guiltyFile != "" {
@@ -271,6 +346,9 @@ func (mgr *Manager) httpReport(w http.ResponseWriter, r *http.Request) {
 			} else {
 				fmt.Fprintf(w, "Failed to extract maintainers: %v\n\n", err)
 			}
+			if subsystem, err := mgr.getReporter().GetSubsystem(guiltyFile); err == nil && subsystem != "" {
+				fmt.Fprintf(w, "Subsystem: %v\n\n", subsystem)
+			}
 		}
 		fmt.Fprintf(w, "%s\n\n", rep)
 	}
@@ -332,7 +410,9 @@ func collectCrashes(workdir string) ([]*UICrashType, error) {
 }
 
 func readCrash(workdir, dir string, full bool) *UICrashType {
-	if len(dir) != 40 {
+	// Crash dirs are named with hash.String of the description,
+	// anything else may point outside of the crashes dir.
+	if sig, err := hash.FromString(dir); err != nil || sig.String() != dir {
 		return nil
 	}
 	crashdir := filepath.Join(workdir, "crashes")
@@ -409,14 +489,22 @@ func readCrash(workdir, dir string, full bool) *UICrashType {
 	} else if reproAttempts >= maxReproAttempts {
 		triaged = "non-reproducible"
 	}
-	return &UICrashType{
+	crash := &UICrashType{
 		Description: string(desc),
 		LastTime:    modTime.Format(dateFormat),
 		ID:          dir,
 		Count:       len(crashes),
+		Saved:       len(crashes),
 		Triaged:     triaged,
 		Crashes:     crashes,
 	}
+	if idx := readCrashIndex(filepath.Join(crashdir, dir)); idx != nil {
+		crash.Count = idx.Count
+		crash.FirstTime = idx.FirstSeen.Format(dateFormat)
+		crash.LastTime = idx.LastSeen.Format(dateFormat)
+		crash.KernelVersions = idx.KernelVersions
+	}
+	return crash
 }
 
 func trimNewLines(data []byte) []byte {
@@ -427,20 +515,25 @@ func trimNewLines(data []byte) []byte {
 }
 
 type UISummaryData struct {
-	Name    string
-	Stats   []UIStat
-	Calls   []UICallType
-	Crashes []*UICrashType
-	Log     string
+	Name        string
+	Stats       []UIStat
+	Calls       []UICallType
+	Focus       []UIFocusGroup
+	Crashes     []*UICrashType
+	CrashSearch string
+	Log         string
 }
 
 type UICrashType struct {
-	Description string
-	LastTime    string
-	ID          string
-	Count       int
-	Triaged     string
-	Crashes     []*UICrash
+	Description    string
+	FirstTime      string // empty for crashes saved before crash indexes were introduced
+	LastTime       string
+	ID             string
+	Count          int // total number of crashes
+	Saved          int // number of saved crash logs
+	KernelVersions []string
+	Triaged        string
+	Crashes        []*UICrash
 }
 
 type UICrash struct {
@@ -461,15 +554,26 @@ type UIStat struct {
 type UICallType struct {
 	Name   string
 	Inputs int
+	Errors int           // number of inputs where the call failed
+	Time   time.Duration // average call duration across inputs
 	Cover  int
 }
 
+type UIFocusGroup struct {
+	Name     string
+	Weight   float64
+	Syscalls int
+	Inputs   int
+	Cover    int
+}
+
 type UIInput struct {
-	Short string
-	Full  string
-	Calls int
-	Cover int
-	Sig   string
+	Short     string
+	Full      string
+	Calls     int
+	Cover     int
+	Stability int
+	Sig       string
 }
 
 type UICallTypeArray []UICallType
@@ -529,11 +633,17 @@ var summaryTemplate = template.Must(template.New("").Parse(addStyle(`
 </table>
 <br>
 
+<form action="/">
+	<input type="text" name="crash_search" value="{{$.CrashSearch}}" placeholder="title, id or kernel version">
+	<input type="submit" value="Search crashes">
+	{{if $.CrashSearch}}<a href="/">reset</a>{{end}}
+</form>
 <table>
 	<caption>Crashes:</caption>
 	<tr>
 		<th>Description</th>
 		<th>Count</th>
+		<th>First Time</th>
 		<th>Last Time</th>
 		<th>Report</th>
 	</tr>
@@ -541,6 +651,7 @@ var summaryTemplate = template.Must(template.New("").Parse(addStyle(`
 	<tr>
 		<td><a href="/crash?id={{$c.ID}}">{{$c.Description}}</a></td>
 		<td>{{$c.Count}}</td>
+		<td>{{$c.FirstTime}}</td>
 		<td>{{$c.LastTime}}</td>
 		<td>
 			{{if $c.Triaged}}
@@ -552,6 +663,29 @@ var summaryTemplate = template.Must(template.New("").Parse(addStyle(`
 </table>
 <br>
 
+{{if $.Focus}}
+<table>
+	<caption>Focus groups:</caption>
+	<tr>
+		<th>Name</th>
+		<th>Weight</th>
+		<th>Syscalls</th>
+		<th>Inputs</th>
+		<th>Cover</th>
+	</tr>
+	{{range $g := $.Focus}}
+	<tr>
+		<td>{{$g.Name}}</td>
+		<td>{{$g.Weight}}</td>
+		<td>{{$g.Syscalls}}</td>
+		<td>{{$g.Inputs}}</td>
+		<td>{{$g.Cover}}</td>
+	</tr>
+	{{end}}
+</table>
+<br>
+{{end}}
+
 <b>Log:</b>
 <br>
 <textarea id="log_textarea" readonly rows="20">
@@ -569,6 +703,8 @@ var summaryTemplate = template.Must(template.New("").Parse(addStyle(`
 {{range $c := $.Calls}}
 	{{$c.Name}}
 		<a href='/corpus?call={{$c.Name}}'>inputs:{{$c.Inputs}}</a>
+		errors:{{$c.Errors}}
+		time:{{$c.Time}}
 		<a href='/cover?call={{$c.Name}}'>cover:{{$c.Cover}}</a>
 		<a href='/prio?call={{$c.Name}}'>prio</a> <br>
 {{end}}
@@ -591,6 +727,16 @@ Report: <a href="/report?id={{.ID}}">{{.Triaged}}</a>
 {{end}}
 <br><br>
 
+<table>
+	<tr><td>Crashes:</td><td>{{.Count}} ({{.Saved}} saved)</td></tr>
+	{{if .FirstTime}}<tr><td>First seen:</td><td>{{.FirstTime}}</td></tr>{{end}}
+	<tr><td>Last seen:</td><td>{{.LastTime}}</td></tr>
+	{{if .KernelVersions}}
+	<tr><td>Kernels:</td><td>{{range $v := .KernelVersions}}{{$v}}<br>{{end}}</td></tr>
+	{{end}}
+</table>
+<br>
+
 <table>
 	<tr>
 		<th>#</th>
@@ -627,6 +773,7 @@ var corpusTemplate = template.Must(template.New("").Parse(addStyle(`
 {{range $c := $}}
 	<span title="{{$c.Full}}">{{$c.Short}}</span>
 		<a href='/cover?input={{$c.Sig}}'>cover:{{$c.Cover}}</a>
+		{{if $c.Stability}}<span title="percent of signal that was stable across triage runs">stability:{{$c.Stability}}%</span>{{end}}
 		<br>
 {{end}}
 </body></html>
@@ -663,6 +810,54 @@ Priorities for {{$.Call}} <br> <br>
 </body></html>
 `)))
 
+type UISyscallsData struct {
+	Syscalls []SyscallState
+	Disabled int
+	Error    string
+}
+
+var syscallsTemplate = template.Must(template.New("").Parse(addStyle(`
+<!doctype html>
+<html>
+<head>
+	<title>syzkaller syscalls</title>
+	{{STYLE}}
+</head>
+<body>
+{{if $.Error}}
+	<b>Error: {{$.Error}}</b> <br> <br>
+{{end}}
+<form method="post">
+	Syscalls (comma-separated, name prefix followed by * matches all variants):
+	<input type="text" name="disable" size="50"> <input type="submit" value="disable">
+</form>
+<form method="post">
+	Syscalls (comma-separated, name prefix followed by * matches all variants):
+	<input type="text" name="enable" size="50"> <input type="submit" value="enable">
+</form>
+<br>
+<table>
+	<caption>Syscalls enabled in config ({{$.Disabled}} disabled at runtime):</caption>
+	{{range $c := $.Syscalls}}
+	<tr>
+		<td>{{$c.Name}}</td>
+		<td>
+			<form method="post">
+			{{if $c.Enabled}}
+				<input type="hidden" name="disable" value="{{$c.Name}}">
+				enabled <input type="submit" value="disable">
+			{{else}}
+				<input type="hidden" name="enable" value="{{$c.Name}}">
+				<b>disabled</b> <input type="submit" value="enable">
+			{{end}}
+			</form>
+		</td>
+	</tr>
+	{{end}}
+</table>
+</body></html>
+`)))
+
 func addStyle(html string) string {
 	return strings.Replace(html, "{{STYLE}}", htmlStyle, -1)
 }
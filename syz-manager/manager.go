@@ -8,11 +8,13 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -38,6 +40,7 @@ var (
 	flagConfig = flag.String("config", "", "configuration file")
 	flagDebug  = flag.Bool("debug", false, "dump all VM output to console")
 	flagBench  = flag.String("bench", "", "write execution statistics into this file periodically")
+	flagCheck  = flag.Bool("check-config", false, "validate config, image, ssh key and VM params and exit without fuzzing")
 )
 
 type Manager struct {
@@ -54,22 +57,43 @@ type Manager struct {
 	fuzzingTime    time.Duration
 	stats          map[string]uint64
 	crashTypes     map[string]bool
+	crashSigs      map[string]string // crash stack signature -> description of the first such crash
+	crashMu        sync.Mutex        // serializes saving of crash logs with pruning (see pruneCrashesLoop)
+	provenance     *Provenance
 	vmStop         chan bool
 	vmChecked      bool
 	fresh          bool
 	numFuzzing     uint32
 	numReproducing uint32
+	numReproQueued uint32 // crashes waiting for reproduction (pending and queued)
 
-	dash *dashapi.Dashboard
+	dash    *dashapi.Dashboard
+	emailer *Emailer
 
 	mu              sync.Mutex
 	phase           int
 	enabledSyscalls string
+	seccompDeny     string
 	enabledCalls    []string // as determined by fuzzer
+	paused          bool     // fuzzing is paused via API
+	pauseChanged    chan bool
+	lastRegression  *RegressionReport
+
+	// Syscalls enabled in config (immutable) and syscalls disabled at runtime via UI/API.
+	syscalls      map[int]bool
+	focus         []map[int]bool // syscalls of cfg.Focus groups
+	disabledCalls map[int]bool
+
+	// Per-syscall new signal stats and weights for cfg.Coverage_Bias (see bias.go).
+	callSignal  []float64
+	callWeights []float32
+	lastBias    time.Time
 
 	candidates     []RpcCandidate // untriaged inputs from corpus and hub
 	disabledHashes map[string]struct{}
 	corpus         map[string]RpcInput
+	corpusInfo     map[string]*corpusInfo // metadata for corpus rotation
+	lastRotation   time.Time
 	corpusSignal   map[uint32]struct{}
 	maxSignal      map[uint32]struct{}
 	corpusCover    map[uint32]struct{}
@@ -104,6 +128,8 @@ type Fuzzer struct {
 	name         string
 	inputs       []RpcInput
 	newMaxSignal []uint32
+	updateCalls  bool // need to send new set of disabled syscalls and syscall weights
+	lastLeakScan time.Time
 }
 
 type Crash struct {
@@ -134,10 +160,37 @@ func main() {
 	}
 	// mmap is used to allocate memory.
 	syscalls[target.MmapSyscall.ID] = true
+	if *flagCheck {
+		if err := checkConfig(cfg, target); err != nil {
+			Fatalf("%v", err)
+		}
+		Logf(0, "config %v is OK", *flagConfig)
+		return
+	}
 	initAllCover(cfg.Vmlinux)
 	RunManager(cfg, target, syscalls)
 }
 
+// checkConfig validates the parts of cfg that are otherwise checked only during
+// fuzzing startup, without creating any VMs.
+func checkConfig(cfg *mgrconfig.Config, target *prog.Target) error {
+	if err := mgrconfig.CheckFiles(cfg); err != nil {
+		return err
+	}
+	if _, err := csource.SeccompDenyNumbers(target, cfg.Seccomp_Deny); err != nil {
+		return err
+	}
+	if _, err := mgrconfig.ParseFocus(cfg); err != nil {
+		return err
+	}
+	env := mgrconfig.CreateVMEnv(cfg, *flagDebug)
+	env.Check = true
+	if _, err := vm.Create(cfg.Type, env); err != nil {
+		return fmt.Errorf("bad config param vm: %v", err)
+	}
+	return nil
+}
+
 func RunManager(cfg *mgrconfig.Config, target *prog.Target, syscalls map[int]bool) {
 	env := mgrconfig.CreateVMEnv(cfg, *flagDebug)
 	vmPool, err := vm.Create(cfg.Type, env)
@@ -157,6 +210,14 @@ func RunManager(cfg *mgrconfig.Config, target *prog.Target, syscalls map[int]boo
 		enabledSyscalls = buf.String()[1:]
 		Logf(1, "enabled syscalls: %v", enabledSyscalls)
 	}
+	seccompDeny, err := csource.SeccompDenyNumbers(target, cfg.Seccomp_Deny)
+	if err != nil {
+		Fatalf("%v", err)
+	}
+	focus, err := mgrconfig.ParseFocus(cfg)
+	if err != nil {
+		Fatalf("%v", err)
+	}
 
 	mgr := &Manager{
 		cfg:             cfg,
@@ -166,8 +227,15 @@ func RunManager(cfg *mgrconfig.Config, target *prog.Target, syscalls map[int]boo
 		startTime:       time.Now(),
 		stats:           make(map[string]uint64),
 		crashTypes:      make(map[string]bool),
+		crashSigs:       loadCrashSignatures(crashdir),
+		provenance:      collectProvenance(cfg, target),
 		enabledSyscalls: enabledSyscalls,
+		seccompDeny:     seccompDeny,
+		syscalls:        syscalls,
+		focus:           focus,
+		disabledCalls:   make(map[int]bool),
 		corpus:          make(map[string]RpcInput),
+		corpusInfo:      make(map[string]*corpusInfo),
 		disabledHashes:  make(map[string]struct{}),
 		corpusSignal:    make(map[uint32]struct{}),
 		maxSignal:       make(map[uint32]struct{}),
@@ -175,6 +243,7 @@ func RunManager(cfg *mgrconfig.Config, target *prog.Target, syscalls map[int]boo
 		fuzzers:         make(map[string]*Fuzzer),
 		fresh:           true,
 		vmStop:          make(chan bool),
+		pauseChanged:    make(chan bool, 1),
 		hubReproQueue:   make(chan *Crash, 10),
 		needMoreRepros:  make(chan chan bool),
 		usedFiles:       make(map[string]time.Time),
@@ -233,6 +302,9 @@ func RunManager(cfg *mgrconfig.Config, target *prog.Target, syscalls map[int]boo
 		shuffle[i], shuffle[j] = shuffle[j], shuffle[i]
 	}
 
+	// Apply retention limits to crashes left from previous runs (the limits could have changed).
+	mgr.pruneCrashes()
+
 	// Create HTTP server.
 	mgr.initHttp()
 	mgr.collectUsedFiles()
@@ -249,6 +321,9 @@ func RunManager(cfg *mgrconfig.Config, target *prog.Target, syscalls map[int]boo
 	if cfg.Dashboard_Addr != "" {
 		mgr.dash = dashapi.New(cfg.Dashboard_Client, cfg.Dashboard_Addr, cfg.Dashboard_Key)
 	}
+	if len(cfg.Email_Addrs) != 0 {
+		mgr.emailer = newEmailer(cfg)
+	}
 
 	go func() {
 		for lastTime := time.Now(); ; {
@@ -265,6 +340,9 @@ func RunManager(cfg *mgrconfig.Config, target *prog.Target, syscalls map[int]boo
 			executed := mgr.stats["exec total"]
 			crashes := mgr.stats["crashes"]
 			signal := len(mgr.corpusSignal)
+			if mgr.cfg.Coverage_Bias && time.Since(mgr.lastBias) > biasPeriod {
+				mgr.updateCallWeights()
+			}
 			mgr.mu.Unlock()
 			numReproducing := atomic.LoadUint32(&mgr.numReproducing)
 
@@ -318,6 +396,14 @@ func RunManager(cfg *mgrconfig.Config, target *prog.Target, syscalls map[int]boo
 		}()
 	}
 
+	if mgr.cfg.Cover_Export_Period != 0 {
+		go mgr.exportCoverLoop()
+	}
+
+	if mgr.cfg.Max_Crash_Disk != 0 {
+		go mgr.pruneCrashesLoop()
+	}
+
 	go func() {
 		c := make(chan os.Signal, 2)
 		signal.Notify(c, syscall.SIGINT)
@@ -340,6 +426,7 @@ type RunResult struct {
 type ReproResult struct {
 	instances []int
 	desc0     string
+	report0   []byte
 	res       *repro.Result
 	err       error
 	hub       bool // repro came from hub
@@ -363,11 +450,20 @@ func (mgr *Manager) vmLoop() {
 	reproInstances := 0
 	var reproQueue []*Crash
 	reproDone := make(chan *ReproResult, 1)
+	var regression *regressionRun
+	var nextRegression time.Time
+	var regressionTicker <-chan time.Time
+	if mgr.cfg.Regression_Period != 0 {
+		regressionTicker = time.NewTicker(time.Minute).C
+	}
+	regressionInstances := 0
+	regressionDone := make(chan *RegressionRunResult, 1)
 	stopPending := false
 	shutdown := vm.Shutdown
 	for {
 		mgr.mu.Lock()
 		phase := mgr.phase
+		paused := mgr.paused
 		mgr.mu.Unlock()
 
 		for crash := range pendingRepro {
@@ -377,7 +473,7 @@ func (mgr *Manager) vmLoop() {
 			delete(pendingRepro, crash)
 			if !crash.hub {
 				if mgr.dash == nil {
-					if !mgr.needRepro(crash.desc) {
+					if !mgr.needRepro(mgr.dedupCrash(crash.desc, crash.report)) {
 						continue
 					}
 				} else {
@@ -402,12 +498,21 @@ func (mgr *Manager) vmLoop() {
 		Logf(1, "loop: phase=%v shutdown=%v instances=%v/%v %+v repro: pending=%v reproducing=%v queued=%v",
 			phase, shutdown == nil, len(instances), vmCount, instances,
 			len(pendingRepro), len(reproducing), len(reproQueue))
+		atomic.StoreUint32(&mgr.numReproQueued, uint32(len(pendingRepro)+len(reproQueue)))
 
 		canRepro := func() bool {
 			return phase >= phaseTriagedHub &&
 				len(reproQueue) != 0 && reproInstances+instancesPerRepro <= vmCount
 		}
 
+		if regressionTicker != nil && regression == nil && shutdown != nil && time.Now().After(nextRegression) {
+			nextRegression = time.Now().Add(time.Duration(mgr.cfg.Regression_Period) * time.Hour)
+			regression = mgr.startRegression()
+		}
+		canRegress := func() bool {
+			return regression != nil && len(regression.queue) != 0
+		}
+
 		if shutdown == nil {
 			if len(instances) == vmCount {
 				return
@@ -425,10 +530,22 @@ func (mgr *Manager) vmLoop() {
 				Logf(1, "loop: starting repro of '%v' on instances %+v", crash.desc, vmIndexes)
 				go func() {
 					res, err := repro.Run(crash.log, mgr.cfg, mgr.getReporter(), mgr.vmPool, vmIndexes)
-					reproDone <- &ReproResult{vmIndexes, crash.desc, res, err, crash.hub}
+					reproDone <- &ReproResult{vmIndexes, crash.desc, crash.report, res, err, crash.hub}
+				}()
+			}
+			for canRegress() && len(instances) != 0 {
+				last := len(instances) - 1
+				idx := instances[last]
+				instances = instances[:last]
+				regressionInstances++
+				res := regression.next()
+				Logf(1, "loop: starting regression test of '%v' on instance %v", res.Title, idx)
+				go func() {
+					mgr.runRegressionTest(idx, res)
+					regressionDone <- &RegressionRunResult{idx, res}
 				}()
 			}
-			for !canRepro() && len(instances) != 0 {
+			for !paused && !canRepro() && !canRegress() && len(instances) != 0 {
 				last := len(instances) - 1
 				idx := instances[last]
 				instances = instances[:last]
@@ -441,7 +558,8 @@ func (mgr *Manager) vmLoop() {
 		}
 
 		var stopRequest chan bool
-		if !stopPending && canRepro() {
+		fuzzing := vmCount - len(instances) - reproInstances - regressionInstances
+		if !stopPending && fuzzing != 0 && (canRepro() || canRegress() || paused) {
 			stopRequest = mgr.vmStop
 		}
 
@@ -483,11 +601,22 @@ func (mgr *Manager) vmLoop() {
 			reproInstances -= instancesPerRepro
 			if res.res == nil {
 				if !res.hub {
-					mgr.saveFailedRepro(res.desc0)
+					mgr.saveFailedRepro(res.desc0, res.report0)
 				}
 			} else {
 				mgr.saveRepro(res.res, res.hub)
 			}
+		case res := <-regressionDone:
+			instances = append(instances, res.idx)
+			regressionInstances--
+			regression.running--
+			if len(regression.queue) == 0 && regression.running == 0 {
+				mgr.finishRegression(regression)
+				regression = nil
+			}
+		case <-regressionTicker:
+		case <-mgr.pauseChanged:
+			Logf(1, "loop: pause state changed")
 		case <-shutdown:
 			Logf(1, "loop: shutting down...")
 			shutdown = nil
@@ -539,7 +668,23 @@ func (mgr *Manager) runInstance(index int) (*Crash, error) {
 		" -leak=%v -cover=%v -sandbox=%v -debug=%v -v=%d",
 		fuzzerBin, executorBin, index, mgr.cfg.TargetArch, fwdAddr, procs,
 		leak, mgr.cfg.Cover, mgr.cfg.Sandbox, *flagDebug, fuzzerV)
-	outc, errc, err := inst.Run(time.Hour, mgr.vmStop, cmd)
+	if mgr.seccompDeny != "" {
+		cmd += " -seccomp_deny=" + mgr.seccompDeny
+	}
+	if leak && mgr.cfg.Leak_Scan_Period != 0 {
+		cmd += " -leak_request"
+	}
+	if mgr.cfg.Fault_Fuzz {
+		cmd += " -fault_fuzz"
+	}
+	if mgr.cfg.Kaslr_Leak {
+		cmd += " -kaslr_leak"
+	}
+	if mgr.cfg.Resource_Leak {
+		cmd += " -resource_leak"
+	}
+	cmd += mgr.cfg.TimeoutFlags()
+	outc, errc, err := inst.Run(time.Hour, mgr.vmStop, mgr.cfg.VMCommand(cmd))
 	if err != nil {
 		return nil, fmt.Errorf("failed to run fuzzer: %v", err)
 	}
@@ -580,29 +725,26 @@ func (mgr *Manager) isSuppressed(crash *Crash) bool {
 
 func (mgr *Manager) saveCrash(crash *Crash) bool {
 	Logf(0, "vm-%v: crash: %v", crash.vmIndex, crash.desc)
+	// Crashes with the same stack are merged only in the local view,
+	// dashboard receives the original title.
+	desc := mgr.dedupCrash(crash.desc, crash.report)
 	mgr.mu.Lock()
 	mgr.stats["crashes"]++
-	if !mgr.crashTypes[crash.desc] {
-		mgr.crashTypes[crash.desc] = true
+	if !mgr.crashTypes[desc] {
+		mgr.crashTypes[desc] = true
 		mgr.stats["crash types"]++
 	}
+	mgr.noteCrashPrograms(crash.log)
 	mgr.mu.Unlock()
 
 	crash.report = mgr.symbolizeReport(crash.report)
 	if mgr.dash != nil {
-		var maintainers []string
-		guiltyFile := mgr.getReporter().ExtractGuiltyFile(crash.report)
-		if guiltyFile != "" {
-			var err error
-			maintainers, err = mgr.getReporter().GetMaintainers(guiltyFile)
-			if err != nil {
-				Logf(0, "failed to get maintainers: %v", err)
-			}
-		}
+		maintainers, subsystem := mgr.crashOwners(crash.report)
 		dc := &dashapi.Crash{
 			BuildID:     mgr.cfg.Tag,
 			Title:       crash.desc,
 			Maintainers: maintainers,
+			Subsystem:   subsystem,
 			Log:         crash.log,
 			Report:      crash.report,
 		}
@@ -616,19 +758,24 @@ func (mgr *Manager) saveCrash(crash *Crash) bool {
 		}
 	}
 
-	sig := hash.Hash([]byte(crash.desc))
+	sig := hash.Hash([]byte(desc))
 	id := sig.String()
 	dir := filepath.Join(mgr.crashdir, id)
 	osutil.MkdirAll(dir)
-	if err := osutil.WriteFile(filepath.Join(dir, "description"), []byte(crash.desc+"\n")); err != nil {
+	if err := osutil.WriteFile(filepath.Join(dir, "description"), []byte(desc+"\n")); err != nil {
 		Logf(0, "failed to write crash: %v", err)
 	}
-	// Save up to 100 reports. If we already have 100, overwrite the oldest one.
+	if sig := report.StackSignature(crash.report); sig != "" && !osutil.IsExist(filepath.Join(dir, "signature")) {
+		osutil.WriteFile(filepath.Join(dir, "signature"), []byte(sig+"\n"))
+	}
+	updateCrashIndex(dir, desc, kernelVersion(crash.report, crash.log, mgr.provenance), time.Now())
+	// Save up to Max_Crash_Logs reports. If we already have that many, overwrite the oldest one.
 	// Newer reports are generally more useful. Overwriting is also needed
 	// to be able to understand if a particular bug still happens or already fixed.
+	mgr.crashMu.Lock()
 	oldestI := 0
 	var oldestTime time.Time
-	for i := 0; i < 100; i++ {
+	for i := 0; i < mgr.cfg.Max_Crash_Logs; i++ {
 		info, err := os.Stat(filepath.Join(dir, fmt.Sprintf("log%v", i)))
 		if err != nil {
 			oldestI = i
@@ -646,8 +793,79 @@ func (mgr *Manager) saveCrash(crash *Crash) bool {
 	if len(crash.report) > 0 {
 		osutil.WriteFile(filepath.Join(dir, fmt.Sprintf("report%v", oldestI)), crash.report)
 	}
+	mgr.provenance.saveCrashMetadata(dir, fmt.Sprintf("metadata%v.json", oldestI), desc)
+	// Only this crash dir has changed, Max_Crash_Disk is enforced periodically (see pruneCrashesLoop).
+	if deleted := trimCrashDir(dir, mgr.cfg.Max_Crash_Logs); deleted != 0 {
+		Logf(1, "deleted %v old crash logs of %v", deleted, desc)
+	}
+	mgr.crashMu.Unlock()
+	if mgr.emailer != nil {
+		mgr.emailer.emailCrash(dir, desc, crash.log, crash.report, mgr.provenance)
+	}
+
+	return mgr.needRepro(desc)
+}
 
-	return mgr.needRepro(crash.desc)
+// pruneCrashes deletes old crash logs according to Max_Crash_Logs and Max_Crash_Disk.
+func (mgr *Manager) pruneCrashes() {
+	maxDisk := int64(mgr.cfg.Max_Crash_Disk) << 20
+	mgr.crashMu.Lock()
+	defer mgr.crashMu.Unlock()
+	if deleted := enforceCrashRetention(mgr.crashdir, mgr.cfg.Max_Crash_Logs, maxDisk); deleted != 0 {
+		Logf(0, "deleted %v old crash logs", deleted)
+	}
+}
+
+// Period of Max_Crash_Disk enforcement. Scanning all crash dirs is too slow to be done on every crash.
+const crashPrunePeriod = 10 * time.Minute
+
+func (mgr *Manager) pruneCrashesLoop() {
+	for {
+		time.Sleep(crashPrunePeriod)
+		mgr.pruneCrashes()
+	}
+}
+
+// dedupCrash returns description of a previously seen crash with the same
+// stack signature as report, so that slightly different titles caused by
+// the same bug collapse into a single crash. If there is no such crash,
+// desc is returned and remembered for the signature.
+func (mgr *Manager) dedupCrash(desc string, rep []byte) string {
+	sig := report.StackSignature(rep)
+	if sig == "" {
+		return desc
+	}
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if first, ok := mgr.crashSigs[sig]; ok {
+		if first != desc {
+			Logf(0, "crash '%v' has the same stack as '%v', merging", desc, first)
+		}
+		return first
+	}
+	mgr.crashSigs[sig] = desc
+	return desc
+}
+
+// loadCrashSignatures restores crash stack signatures saved in crashdir.
+func loadCrashSignatures(crashdir string) map[string]string {
+	sigs := make(map[string]string)
+	dirs, err := osutil.ListDir(crashdir)
+	if err != nil {
+		return sigs
+	}
+	for _, dir := range dirs {
+		sig, err := ioutil.ReadFile(filepath.Join(crashdir, dir, "signature"))
+		if err != nil {
+			continue
+		}
+		desc, err := ioutil.ReadFile(filepath.Join(crashdir, dir, "description"))
+		if err != nil {
+			continue
+		}
+		sigs[strings.TrimSpace(string(sig))] = strings.TrimSpace(string(desc))
+	}
+	return sigs
 }
 
 const maxReproAttempts = 3
@@ -669,7 +887,7 @@ func (mgr *Manager) needRepro(desc string) bool {
 	return false
 }
 
-func (mgr *Manager) saveFailedRepro(desc string) {
+func (mgr *Manager) saveFailedRepro(desc string, rep []byte) {
 	if mgr.dash != nil {
 		cid := &dashapi.CrashID{
 			BuildID: mgr.cfg.Tag,
@@ -679,7 +897,7 @@ func (mgr *Manager) saveFailedRepro(desc string) {
 			Logf(0, "failed to report failed repro to dashboard: %v", err)
 		}
 	}
-	dir := filepath.Join(mgr.crashdir, hash.String([]byte(desc)))
+	dir := filepath.Join(mgr.crashdir, hash.String([]byte(mgr.dedupCrash(desc, rep))))
 	osutil.MkdirAll(dir)
 	for i := 0; i < maxReproAttempts; i++ {
 		name := filepath.Join(dir, fmt.Sprintf("repro%v", i))
@@ -691,11 +909,13 @@ func (mgr *Manager) saveFailedRepro(desc string) {
 }
 
 func (mgr *Manager) saveRepro(res *repro.Result, hub bool) {
+	// See the comment in saveCrash.
+	desc := mgr.dedupCrash(res.Desc, res.Report)
 	res.Report = mgr.symbolizeReport(res.Report)
-	dir := filepath.Join(mgr.crashdir, hash.String([]byte(res.Desc)))
+	dir := filepath.Join(mgr.crashdir, hash.String([]byte(desc)))
 	osutil.MkdirAll(dir)
 
-	if err := osutil.WriteFile(filepath.Join(dir, "description"), []byte(res.Desc+"\n")); err != nil {
+	if err := osutil.WriteFile(filepath.Join(dir, "description"), []byte(desc+"\n")); err != nil {
 		Logf(0, "failed to write crash: %v", err)
 	}
 	opts := fmt.Sprintf("# %+v\n", res.Opts)
@@ -710,23 +930,17 @@ func (mgr *Manager) saveRepro(res *repro.Result, hub bool) {
 	if len(res.Report) > 0 {
 		osutil.WriteFile(filepath.Join(dir, "repro.report"), res.Report)
 	}
+	mgr.provenance.saveCrashMetadata(dir, "repro.metadata.json", desc)
 	osutil.WriteFile(filepath.Join(dir, "repro.stats.log"), res.Stats.Log)
-	stats := fmt.Sprintf("Extracting prog: %s\nMinimizing prog: %s\nSimplifying prog options: %s\nExtracting C: %s\nSimplifying C: %s\n",
-		res.Stats.ExtractProgTime, res.Stats.MinimizeProgTime, res.Stats.SimplifyProgTime, res.Stats.ExtractCTime, res.Stats.SimplifyCTime)
+	stats := fmt.Sprintf("Extracting prog: %s\nMinimizing prog: %s\nSimplifying prog options: %s\nExtracting C: %s\nSimplifying C: %s\nMeasuring reliability: %s\nReliability: %v/%v\n",
+		res.Stats.ExtractProgTime, res.Stats.MinimizeProgTime, res.Stats.SimplifyProgTime, res.Stats.ExtractCTime, res.Stats.SimplifyCTime,
+		res.Stats.MeasureTime, res.Successes, res.Runs)
 	osutil.WriteFile(filepath.Join(dir, "repro.stats"), []byte(stats))
-	var cprogText []byte
 	if res.CRepro {
-		cprog, err := csource.Write(res.Prog, res.Opts)
-		if err == nil {
-			formatted, err := csource.Format(cprog)
-			if err == nil {
-				cprog = formatted
-			}
-			osutil.WriteFile(filepath.Join(dir, "repro.cprog"), cprog)
-			cprogText = cprog
-		} else {
-			Logf(0, "failed to write C source: %v", err)
-		}
+		osutil.WriteFile(filepath.Join(dir, "repro.cprog"), res.CSource)
+	}
+	if mgr.emailer != nil {
+		mgr.emailer.emailRepro(dir, desc, mgr.provenance)
 	}
 
 	// Append this repro to repro list to send to hub if it didn't come from hub originally.
@@ -739,24 +953,19 @@ func (mgr *Manager) saveRepro(res *repro.Result, hub bool) {
 	}
 
 	if mgr.dash != nil {
-		var maintainers []string
-		guiltyFile := mgr.getReporter().ExtractGuiltyFile(res.Report)
-		if guiltyFile != "" {
-			var err error
-			maintainers, err = mgr.getReporter().GetMaintainers(guiltyFile)
-			if err != nil {
-				Logf(0, "failed to get maintainers: %v", err)
-			}
-		}
+		maintainers, subsystem := mgr.crashOwners(res.Report)
 		dc := &dashapi.Crash{
-			BuildID:     mgr.cfg.Tag,
-			Title:       res.Desc,
-			Maintainers: maintainers,
-			Log:         res.Log,
-			Report:      res.Report,
-			ReproOpts:   []byte(fmt.Sprintf("%+v", res.Opts)),
-			ReproSyz:    res.Prog.Serialize(),
-			ReproC:      cprogText,
+			BuildID:      mgr.cfg.Tag,
+			Title:        res.Desc,
+			Maintainers:  maintainers,
+			Subsystem:    subsystem,
+			Log:          res.Log,
+			Report:       res.Report,
+			ReproOpts:    []byte(fmt.Sprintf("%+v", res.Opts)),
+			ReproSyz:     res.Prog.Serialize(),
+			ReproC:       res.CSource,
+			ReproRuns:    res.Runs,
+			ReproCrashes: res.Successes,
 		}
 		if _, err := mgr.dash.ReportCrash(dc); err != nil {
 			Logf(0, "failed to report repro to dashboard: %v", err)
@@ -764,6 +973,24 @@ func (mgr *Manager) saveRepro(res *repro.Result, hub bool) {
 	}
 }
 
+// crashOwners returns maintainers and subsystem of the file guilty in the crash report.
+func (mgr *Manager) crashOwners(report []byte) (maintainers []string, subsystem string) {
+	guiltyFile := mgr.getReporter().ExtractGuiltyFile(report)
+	if guiltyFile == "" {
+		return
+	}
+	var err error
+	maintainers, err = mgr.getReporter().GetMaintainers(guiltyFile)
+	if err != nil {
+		Logf(0, "failed to get maintainers: %v", err)
+	}
+	subsystem, err = mgr.getReporter().GetSubsystem(guiltyFile)
+	if err != nil {
+		Logf(0, "failed to get subsystem: %v", err)
+	}
+	return
+}
+
 func (mgr *Manager) symbolizeReport(text []byte) []byte {
 	if len(text) == 0 || mgr.cfg.Vmlinux == "" {
 		return text
@@ -806,6 +1033,7 @@ func (mgr *Manager) minimizeCorpus() {
 		}
 		Logf(1, "minimized corpus: %v -> %v", len(mgr.corpus), len(newCorpus))
 		mgr.corpus = newCorpus
+		mgr.rotateCorpus()
 	}
 
 	// Don't minimize persistent corpus until fuzzers have triaged all inputs from it.
@@ -833,7 +1061,8 @@ func (mgr *Manager) Connect(a *ConnectArgs, r *ConnectRes) error {
 
 	mgr.stats["vm restarts"]++
 	f := &Fuzzer{
-		name: a.Name,
+		name:         a.Name,
+		lastLeakScan: time.Now(),
 	}
 	mgr.fuzzers[a.Name] = f
 	mgr.minimizeCorpus()
@@ -856,6 +1085,7 @@ func (mgr *Manager) Connect(a *ConnectArgs, r *ConnectRes) error {
 			corpus = append(corpus, p)
 		}
 		prios := mgr.target.CalculatePriorities(corpus)
+		mgr.applyFocus(prios)
 
 		mgr.mu.Lock()
 		mgr.prios = prios
@@ -867,6 +1097,8 @@ func (mgr *Manager) Connect(a *ConnectArgs, r *ConnectRes) error {
 	}
 	r.Prios = mgr.prios
 	r.EnabledCalls = mgr.enabledSyscalls
+	r.DisabledCalls = mgr.disabledCallNames()
+	r.CallWeights = mgr.callWeights
 	r.NeedCheck = !mgr.vmChecked
 	r.MaxSignal = make([]uint32, 0, len(mgr.maxSignal))
 	for s := range mgr.maxSignal {
@@ -933,6 +1165,13 @@ func (mgr *Manager) NewInput(a *NewInputArgs, r *int) error {
 		return nil
 	}
 	mgr.stats["manager new inputs"]++
+	if mgr.cfg.Coverage_Bias {
+		p, err := mgr.target.Deserialize(a.RpcInput.Prog)
+		if err == nil && a.CallIndex >= 0 && a.CallIndex < len(p.Calls) {
+			newSignal := cover.SignalDiff(mgr.corpusSignal, a.Signal)
+			mgr.recordCallSignal(p.Calls[a.CallIndex].Meta.ID, len(newSignal))
+		}
+	}
 	cover.SignalAdd(mgr.corpusSignal, a.Signal)
 	cover.SignalAdd(mgr.corpusCover, a.Cover)
 	sig := hash.String(a.RpcInput.Prog)
@@ -940,10 +1179,17 @@ func (mgr *Manager) NewInput(a *NewInputArgs, r *int) error {
 		// The input is already present, but possibly with diffent signal/coverage/call.
 		inp.Signal = cover.Union(inp.Signal, a.RpcInput.Signal)
 		inp.Cover = cover.Union(inp.Cover, a.RpcInput.Cover)
+		if inp.Duration < a.RpcInput.Duration {
+			inp.Duration = a.RpcInput.Duration
+		}
+		if inp.Stability < a.RpcInput.Stability {
+			inp.Stability = a.RpcInput.Stability
+		}
 		mgr.corpus[sig] = inp
 	} else {
 		mgr.corpus[sig] = a.RpcInput
-		mgr.corpusDB.Save(sig, a.RpcInput.Prog, 0)
+		added := mgr.addCorpusInfo(sig, a.RpcInput.Prog)
+		mgr.corpusDB.Save(sig, a.RpcInput.Prog, uint64(added.Unix()))
 		if err := mgr.corpusDB.Flush(); err != nil {
 			Logf(0, "failed to save corpus database: %v", err)
 		}
@@ -987,6 +1233,17 @@ func (mgr *Manager) Poll(a *PollArgs, r *PollRes) error {
 	}
 	r.MaxSignal = f.newMaxSignal
 	f.newMaxSignal = nil
+	if f.updateCalls {
+		r.UpdateCalls = true
+		r.DisabledCalls = mgr.disabledCallNames()
+		r.CallWeights = mgr.callWeights
+		f.updateCalls = false
+	}
+	if period := time.Duration(mgr.cfg.Leak_Scan_Period) * time.Second; period != 0 &&
+		time.Since(f.lastLeakScan) >= period {
+		r.LeakScan = true
+		f.lastLeakScan = time.Now()
+	}
 	for i := 0; i < 100 && len(f.inputs) > 0; i++ {
 		last := len(f.inputs) - 1
 		r.NewInputs = append(r.NewInputs, f.inputs[last])
@@ -1040,9 +1297,13 @@ func (mgr *Manager) hubSync() {
 			Client:  mgr.cfg.Hub_Client,
 			Key:     mgr.cfg.Hub_Key,
 			Manager: mgr.cfg.Name,
+			Domain:  mgr.cfg.TargetOS + "/" + mgr.cfg.TargetArch,
 			Fresh:   mgr.fresh,
 			Calls:   mgr.enabledCalls,
 		}
+		if mgr.cfg.Hub_Domain != "" {
+			a.Domain += "/" + mgr.cfg.Hub_Domain
+		}
 		hubCorpus := make(map[hash.Sig]bool)
 		for _, inp := range mgr.corpus {
 			hubCorpus[hash.Hash(inp.Prog)] = true
@@ -1191,3 +1452,30 @@ func (mgr *Manager) checkUsedFiles() {
 		}
 	}
 }
+
+// applyFocus scales priorities of choosing syscalls from focus groups by the group weights.
+// If a syscall belongs to several groups, the largest weight is used.
+func (mgr *Manager) applyFocus(prios [][]float32) {
+	if len(mgr.focus) == 0 {
+		return
+	}
+	weights := make([]float32, len(prios))
+	for i := range weights {
+		weights[i] = 1
+	}
+	inGroup := make([]bool, len(prios))
+	for i, group := range mgr.focus {
+		w := float32(mgr.cfg.Focus[i].Weight)
+		for id := range group {
+			if !inGroup[id] || weights[id] < w {
+				weights[id] = w
+				inGroup[id] = true
+			}
+		}
+	}
+	for _, prio := range prios {
+		for j := range prio {
+			prio[j] *= weights[j]
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+// Manager statistics in Prometheus text exposition format served on /metrics,
+// so that a fleet of managers can be monitored with standard tooling.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type metricType string
+
+const (
+	metricCounter metricType = "counter"
+	metricGauge   metricType = "gauge"
+)
+
+// metric is a single sample. Samples of the same metric must go one after another,
+// they are distinguished by the stat label (used for the generic syz_stat_total metric).
+type metric struct {
+	name  string
+	help  string
+	typ   metricType
+	stat  string
+	value float64
+}
+
+func (mgr *Manager) httpMetrics(w http.ResponseWriter, r *http.Request) {
+	buf := new(bytes.Buffer)
+	writeMetrics(buf, mgr.collectMetrics())
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+func (mgr *Manager) collectMetrics() []metric {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	execPerSec := 0.0
+	if !mgr.firstConnect.IsZero() {
+		execPerSec = float64(mgr.stats["exec total"]) / (time.Since(mgr.firstConnect).Seconds() + 1)
+	}
+	metrics := []metric{
+		{name: "syz_uptime_seconds", help: "Time since the manager start.", typ: metricGauge,
+			value: time.Since(mgr.startTime).Seconds()},
+		{name: "syz_fuzzing_seconds_total", help: "Total fuzzing time of all VMs.", typ: metricCounter,
+			value: mgr.fuzzingTime.Seconds()},
+		{name: "syz_exec_total", help: "Number of executed programs.", typ: metricCounter,
+			value: float64(mgr.stats["exec total"])},
+		{name: "syz_exec_per_second", help: "Average number of executed programs per second.", typ: metricGauge,
+			value: execPerSec},
+		{name: "syz_corpus_size", help: "Number of programs in the corpus.", typ: metricGauge,
+			value: float64(len(mgr.corpus))},
+		{name: "syz_triage_queue", help: "Number of candidate programs waiting for triage.", typ: metricGauge,
+			value: float64(len(mgr.candidates))},
+		{name: "syz_coverage", help: "Number of covered kernel PCs.", typ: metricGauge,
+			value: float64(len(mgr.corpusCover))},
+		{name: "syz_signal", help: "Amount of corpus signal.", typ: metricGauge,
+			value: float64(len(mgr.corpusSignal))},
+		{name: "syz_crashes_total", help: "Number of kernel crashes.", typ: metricCounter,
+			value: float64(mgr.stats["crashes"])},
+		{name: "syz_crash_types", help: "Number of distinct crash types.", typ: metricGauge,
+			value: float64(mgr.stats["crash types"])},
+		{name: "syz_suppressed_total", help: "Number of suppressed crashes.", typ: metricCounter,
+			value: float64(mgr.stats["suppressed"])},
+		{name: "syz_vm_restarts_total", help: "Number of VM restarts.", typ: metricCounter,
+			value: float64(mgr.stats["vm restarts"])},
+		{name: "syz_vms_fuzzing", help: "Number of VMs that are fuzzing.", typ: metricGauge,
+			value: float64(atomic.LoadUint32(&mgr.numFuzzing))},
+		{name: "syz_repro_queue", help: "Number of crashes waiting for reproduction.", typ: metricGauge,
+			value: float64(atomic.LoadUint32(&mgr.numReproQueued))},
+		{name: "syz_repro_running", help: "Number of running reproductions.", typ: metricGauge,
+			value: float64(atomic.LoadUint32(&mgr.numReproducing))},
+	}
+	var stats []string
+	for k := range mgr.stats {
+		stats = append(stats, k)
+	}
+	sort.Strings(stats)
+	for _, k := range stats {
+		metrics = append(metrics, metric{
+			name:  "syz_stat_total",
+			help:  "Raw manager and fuzzer statistics (as shown on the summary page).",
+			typ:   metricCounter,
+			stat:  k,
+			value: float64(mgr.stats[k]),
+		})
+	}
+	return metrics
+}
+
+func writeMetrics(w io.Writer, metrics []metric) {
+	for i, m := range metrics {
+		if i == 0 || metrics[i-1].name != m.name {
+			fmt.Fprintf(w, "# HELP %v %v\n", m.name, m.help)
+			fmt.Fprintf(w, "# TYPE %v %v\n", m.name, m.typ)
+		}
+		labels := ""
+		if m.stat != "" {
+			labels = fmt.Sprintf("{stat=\"%v\"}", escapeLabel(m.stat))
+		}
+		fmt.Fprintf(w, "%v%v %v\n", m.name, labels, strconv.FormatFloat(m.value, 'f', -1, 64))
+	}
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelReplacer.Replace(v)
+}
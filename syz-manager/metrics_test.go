@@ -0,0 +1,35 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	metrics := []metric{
+		{name: "syz_exec_total", help: "Number of executed programs.", typ: metricCounter, value: 12345678},
+		{name: "syz_exec_per_second", help: "Average number of executed programs per second.",
+			typ: metricGauge, value: 0.5},
+		{name: "syz_stat_total", help: "Raw stats.", typ: metricCounter, stat: "exec gen", value: 1},
+		{name: "syz_stat_total", help: "Raw stats.", typ: metricCounter, stat: `a"b\c`, value: 2},
+	}
+	want := `# HELP syz_exec_total Number of executed programs.
+# TYPE syz_exec_total counter
+syz_exec_total 12345678
+# HELP syz_exec_per_second Average number of executed programs per second.
+# TYPE syz_exec_per_second gauge
+syz_exec_per_second 0.5
+# HELP syz_stat_total Raw stats.
+# TYPE syz_stat_total counter
+syz_stat_total{stat="exec gen"} 1
+syz_stat_total{stat="a\"b\\c"} 2
+`
+	buf := new(bytes.Buffer)
+	writeMetrics(buf, metrics)
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
@@ -6,11 +6,15 @@ package mgrconfig
 import (
 	"encoding/json"
 	"fmt"
+	"net/mail"
+	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/google/syzkaller/pkg/config"
+	"github.com/google/syzkaller/pkg/csource"
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/prog"
 	_ "github.com/google/syzkaller/sys"
@@ -18,44 +22,110 @@ import (
 )
 
 type Config struct {
-	Name       string // Instance name (used for identification and as GCE instance prefix)
-	Target     string // Target OS/arch, e.g. "linux/arm64" or "linux/amd64/386" (amd64 OS with 386 test process)
-	Http       string // TCP address to serve HTTP stats page (e.g. "localhost:50000")
-	Rpc        string // TCP address to serve RPC for fuzzer processes (optional)
-	Workdir    string
-	Vmlinux    string
-	Kernel_Src string // kernel source directory
-	Tag        string // arbitrary optional tag that is saved along with crash reports (e.g. branch/commit)
-	Image      string // linux image for VMs
-	Sshkey     string // ssh key for the image (may be empty for some VM types)
-	Ssh_User   string // ssh user ("root" by default)
+	Name          string // Instance name (used for identification and as GCE instance prefix)
+	Target        string // Target OS/arch, e.g. "linux/arm64" or "linux/amd64/386" (amd64 OS with 386 test process)
+	Http          string // TCP address to serve HTTP stats page (e.g. "localhost:50000")
+	Rpc           string // TCP address to serve RPC for fuzzer processes (":0" by default)
+	Workdir       string
+	Vmlinux       string
+	Kernel_Src    string // kernel source directory
+	Kernel_Config string // kernel .config file saved along with crash reports (kernel_src/.config by default)
+	Tag           string // arbitrary optional tag that is saved along with crash reports (e.g. branch/commit)
+	Image         string // linux image for VMs
+	Sshkey        string // ssh key for the image (may be empty for some VM types)
+	Ssh_User      string // ssh user ("root" by default)
 
 	Hub_Client string
 	Hub_Addr   string
 	Hub_Key    string
+	// Hub corpus domain, programs are exchanged only with managers with the same domain
+	// (e.g. tag of the kernel config). Managers of different targets never exchange programs.
+	Hub_Domain string
 
 	Dashboard_Client string
 	Dashboard_Addr   string
 	Dashboard_Key    string
 
+	// Email new unique crashes and their reproducers to these addresses
+	// (for private instances that don't use dashboard).
+	Email_Addrs    []string
+	Email_Sender   string // From address for the emails
+	Email_Smtp     string // SMTP server address ("localhost:25" by default)
+	Email_User     string // SMTP auth user (no auth if empty)
+	Email_Password string // SMTP auth password
+	Email_Rate     int    // max number of emails per hour (10 by default)
+
 	Syzkaller string // path to syzkaller checkout (syz-manager will look for binaries in bin subdir)
-	Procs     int    // number of parallel processes inside of every VM
+	Procs     int    // number of parallel processes inside of every VM (1 by default)
 
 	Sandbox string // type of sandbox to use during fuzzing:
 	// "none": don't do anything special (has false positives, e.g. due to killing init)
 	// "setuid": impersonate into user nobody (65534), default
 	// "namespace": create a new namespace for fuzzer using CLONE_NEWNS/CLONE_NEWNET/CLONE_NEWPID/etc,
 	//	requires building kernel with CONFIG_NAMESPACES, CONFIG_UTS_NS, CONFIG_USER_NS, CONFIG_PID_NS and CONFIG_NET_NS.
+	// "seccomp": install seccomp filter that denies Seccomp_Deny syscalls (they fail with EPERM),
+	//	allows to reproduce crashes under restricted environments like containers.
+	Seccomp_Deny []string // syscalls denied with seccomp sandbox (e.g. "kexec_load", "open$dir")
+
+	// Sysctls set in every VM before starting the fuzzer (linux only), e.g. {"kernel.panic_on_warn": "1"}.
+	// C reproducers set the same sysctls, so that crashes reproduce in the same environment.
+	Sysctl map[string]string
+	// Additional kernel command line appended to the VM-specific cmdline (qemu/kvm only).
+	Cmdline string
 
-	Cover     bool // use kcov coverage (default: true)
+	Cover     bool // use kcov coverage (on by default)
 	Leak      bool // do memory leak checking
 	Reproduce bool // reproduce, localize and minimize crashers (on by default)
+	// Time budget in minutes for extraction of a reproducer of a flaky crash (0 - disabled).
+	// If set, reproduction candidates are retried according to their estimated probability
+	// to reproduce the crash (multi-armed bandit) instead of fixed number of attempts.
+	Repro_Budget int
+	// Request kmemleak scans from the fuzzer every that many seconds
+	// (0 means that fuzzer scans after every batch of executions).
+	Leak_Scan_Period int
+	// Systematically inject faults into every call of every new corpus program
+	// to cover error paths (requires kernel built with CONFIG_FAULT_INJECTION).
+	Fault_Fuzz bool
+	// Report kernel pointers returned to user-space by syscalls as "KASLR leak" crashes (linux only).
+	Kaslr_Leak bool
+	// Report programs that leak kernel objects (files, sockets, loop devices, memory cgroup usage)
+	// with every execution as "resource leak" crashes (linux only).
+	Resource_Leak bool
+	// Generate calls to syscalls with low historical coverage (e.g. newly enabled) more frequently.
+	Coverage_Bias bool
+
+	// Execution timeouts in milliseconds, 0 means the default (see pkg/ipc).
+	// Increase them for slow targets (e.g. emulated arches or KMSAN kernels),
+	// so that slowness is not misclassified as hangs.
+	Call_Timeout    int // how long to wait for a single call in threaded mode (20ms by default)
+	Program_Timeout int // how long a single program can run before it is killed (3s by default)
+	Shutdown_Grace  int // how long to wait for executor to exit before SIGKILL (5s by default)
+
+	// Re-run all saved reproducers against the kernel on start and then every N hours
+	// to detect fixed and regressed bugs (0 - disabled). Results are saved in workdir/regression.
+	Regression_Period int
+
+	// Export coverage of the corpus as LCOV data (genhtml-compatible) and per-file annotated HTML
+	// to workdir/cover every N minutes (0 - disabled), requires Vmlinux with debug info.
+	// Only files with changed coverage are regenerated. The report is also served at /coverreport/.
+	Cover_Export_Period int
+
+	// Crash logs retention: max number of saved crash logs/reports per bug (100 by default,
+	// the oldest one is overwritten) and max total size of workdir/crashes in MB (0 - unlimited,
+	// the oldest logs of all bugs are deleted, but the last log of every bug and reproducers are kept).
+	// Every crash dir has index.json with first/last seen time and kernel versions of all crashes.
+	Max_Crash_Logs int
+	Max_Crash_Disk int
 
 	Enable_Syscalls  []string
 	Disable_Syscalls []string
 	Suppressions     []string // don't save reports matching these regexps, but reboot VM after them
 	Ignores          []string // completely ignore reports matching these regexps (don't save nor reboot)
 
+	// Named syscall groups that are chosen more (or less) frequently than other syscalls,
+	// e.g. {"name": "bpf", "syscalls": ["bpf", "socket$bpf*"], "weight": 10}.
+	Focus []FocusGroup
+
 	Type string          // VM type (qemu, kvm, local)
 	VM   json.RawMessage // VM-type-specific config
 
@@ -80,14 +150,17 @@ func LoadFile(filename string) (*Config, error) {
 	return load(nil, filename)
 }
 
+// DefaultValues returns config with default values of params,
+// the defaults must be reflected in comments of the Config fields.
 func DefaultValues() *Config {
 	return &Config{
-		Ssh_User:  "root",
-		Cover:     true,
-		Reproduce: true,
-		Sandbox:   "setuid",
-		Rpc:       ":0",
-		Procs:     1,
+		Ssh_User:       "root",
+		Cover:          true,
+		Reproduce:      true,
+		Sandbox:        "setuid",
+		Rpc:            ":0",
+		Procs:          1,
+		Max_Crash_Logs: 100,
 	}
 }
 
@@ -141,9 +214,78 @@ func load(data []byte, filename string) (*Config, error) {
 		return nil, fmt.Errorf("bad config param procs: '%v', want [1, 32]", cfg.Procs)
 	}
 	switch cfg.Sandbox {
-	case "none", "setuid", "namespace":
+	case "none", "setuid", "namespace", "seccomp":
 	default:
-		return nil, fmt.Errorf("config param sandbox must contain one of none/setuid/namespace")
+		return nil, fmt.Errorf("config param sandbox must contain one of none/setuid/namespace/seccomp")
+	}
+	if len(cfg.Seccomp_Deny) != 0 && cfg.Sandbox != "seccomp" {
+		return nil, fmt.Errorf("config param seccomp_deny requires seccomp sandbox")
+	}
+	if len(cfg.Sysctl) != 0 && cfg.TargetOS != "linux" {
+		return nil, fmt.Errorf("config param sysctl is supported only on linux")
+	}
+	for name, val := range cfg.Sysctl {
+		if err := csource.CheckSysctl(name, val); err != nil {
+			return nil, fmt.Errorf("bad config param sysctl: %v", err)
+		}
+	}
+	if cfg.Cmdline != "" && cfg.Type != "qemu" && cfg.Type != "kvm" {
+		return nil, fmt.Errorf("config param cmdline is supported only with qemu/kvm VMs")
+	}
+	if cfg.Fault_Fuzz && !cfg.Cover {
+		return nil, fmt.Errorf("config param fault_fuzz requires cover")
+	}
+	if cfg.Repro_Budget < 0 {
+		return nil, fmt.Errorf("config param repro_budget must be >= 0")
+	}
+	if cfg.Leak_Scan_Period < 0 {
+		return nil, fmt.Errorf("config param leak_scan_period must be >= 0")
+	}
+	if cfg.Leak_Scan_Period != 0 && !cfg.Leak {
+		return nil, fmt.Errorf("config param leak_scan_period requires leak")
+	}
+	if cfg.Kaslr_Leak && cfg.TargetOS != "linux" {
+		return nil, fmt.Errorf("config param kaslr_leak is supported only on linux")
+	}
+	if cfg.Resource_Leak && cfg.TargetOS != "linux" {
+		return nil, fmt.Errorf("config param resource_leak is supported only on linux")
+	}
+	if cfg.Call_Timeout < 0 || cfg.Program_Timeout < 0 || cfg.Shutdown_Grace < 0 {
+		return nil, fmt.Errorf("bad config timeouts: call_timeout=%v program_timeout=%v shutdown_grace=%v",
+			cfg.Call_Timeout, cfg.Program_Timeout, cfg.Shutdown_Grace)
+	}
+	if cfg.Call_Timeout != 0 && cfg.Program_Timeout != 0 && cfg.Program_Timeout < cfg.Call_Timeout {
+		return nil, fmt.Errorf("config param program_timeout must not be less than call_timeout")
+	}
+	focusNames := make(map[string]bool)
+	for _, group := range cfg.Focus {
+		if group.Name == "" || focusNames[group.Name] {
+			return nil, fmt.Errorf("config param focus: empty or duplicate group name %q", group.Name)
+		}
+		focusNames[group.Name] = true
+		if len(group.Syscalls) == 0 {
+			return nil, fmt.Errorf("config param focus: no syscalls in group %v", group.Name)
+		}
+		if group.Weight < 0.01 || group.Weight > 1000 {
+			return nil, fmt.Errorf("config param focus: bad weight %v for group %v (must be in 0.01..1000)",
+				group.Weight, group.Name)
+		}
+	}
+	if cfg.Regression_Period < 0 {
+		return nil, fmt.Errorf("bad config param regression_period: %v", cfg.Regression_Period)
+	}
+	if cfg.Cover_Export_Period < 0 {
+		return nil, fmt.Errorf("bad config param cover_export_period: %v", cfg.Cover_Export_Period)
+	}
+	if cfg.Cover_Export_Period != 0 && (!cfg.Cover || cfg.Vmlinux == "") {
+		return nil, fmt.Errorf("config param cover_export_period requires cover and vmlinux")
+	}
+
+	if cfg.Max_Crash_Logs < 1 {
+		return nil, fmt.Errorf("bad config param max_crash_logs: %v", cfg.Max_Crash_Logs)
+	}
+	if cfg.Max_Crash_Disk < 0 {
+		return nil, fmt.Errorf("bad config param max_crash_disk: %v", cfg.Max_Crash_Disk)
 	}
 
 	cfg.Workdir = osutil.Abs(cfg.Workdir)
@@ -152,6 +294,16 @@ func load(data []byte, filename string) (*Config, error) {
 	if cfg.Kernel_Src == "" {
 		cfg.Kernel_Src = filepath.Dir(cfg.Vmlinux) // assume in-tree build by default
 	}
+	if cfg.Kernel_Config == "" {
+		if config := filepath.Join(cfg.Kernel_Src, ".config"); osutil.IsExist(config) {
+			cfg.Kernel_Config = config
+		}
+	} else {
+		cfg.Kernel_Config = osutil.Abs(cfg.Kernel_Config)
+		if !osutil.IsExist(cfg.Kernel_Config) {
+			return nil, fmt.Errorf("config param kernel_config: file %v does not exist", cfg.Kernel_Config)
+		}
+	}
 
 	if err := parseSuppressions(cfg); err != nil {
 		return nil, err
@@ -165,10 +317,57 @@ func load(data []byte, filename string) (*Config, error) {
 		cfg.Dashboard_Key == "") {
 		return nil, fmt.Errorf("dashboard_client is set, but name/dashboard_addr/dashboard_key is empty")
 	}
+	if len(cfg.Email_Addrs) != 0 {
+		if cfg.Email_Sender == "" {
+			return nil, fmt.Errorf("email_addrs is set, but email_sender is empty")
+		}
+		for _, addr := range append([]string{cfg.Email_Sender}, cfg.Email_Addrs...) {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return nil, fmt.Errorf("config param email_addrs: bad email address %q: %v", addr, err)
+			}
+		}
+		if cfg.Email_Smtp == "" {
+			cfg.Email_Smtp = "localhost:25"
+		}
+		if cfg.Email_Rate == 0 {
+			cfg.Email_Rate = 10
+		}
+		if cfg.Email_Rate < 0 {
+			return nil, fmt.Errorf("config param email_rate: must be positive, got %v", cfg.Email_Rate)
+		}
+	}
 
 	return cfg, nil
 }
 
+// CheckFiles checks files referenced by cfg that are not required to exist by LoadFile:
+// kernel image and sources, VM image and ssh key. VM-type-specific params are checked
+// by vm.Create with Env.Check set (see syz-manager -check-config).
+func CheckFiles(cfg *Config) error {
+	if cfg.Vmlinux != "" && !osutil.IsExist(cfg.Vmlinux) {
+		return fmt.Errorf("config param vmlinux: file %v does not exist", cfg.Vmlinux)
+	}
+	if !osutil.IsExist(cfg.Kernel_Src) {
+		return fmt.Errorf("config param kernel_src: dir %v does not exist", cfg.Kernel_Src)
+	}
+	// 9p is a special value for qemu that means sharing host root fs.
+	if cfg.Image != "" && cfg.Image != "9p" && !osutil.IsExist(cfg.Image) {
+		return fmt.Errorf("config param image: file %v does not exist", cfg.Image)
+	}
+	if cfg.Sshkey != "" {
+		info, err := os.Stat(cfg.Sshkey)
+		if err != nil {
+			return fmt.Errorf("config param sshkey: %v", err)
+		}
+		// ssh refuses to use private keys that are accessible by others.
+		if info.Mode()&0077 != 0 {
+			return fmt.Errorf("config param sshkey: %v is accessible by other users (mode %v)",
+				cfg.Sshkey, info.Mode())
+		}
+	}
+	return nil
+}
+
 func SplitTarget(target string) (string, string, string, error) {
 	if target == "" {
 		return "", "", "", fmt.Errorf("target is empty")
@@ -186,17 +385,84 @@ func SplitTarget(target string) (string, string, string, error) {
 	return os, vmarch, arch, nil
 }
 
-func ParseEnabledSyscalls(cfg *Config) (map[int]bool, error) {
-	match := func(call *prog.Syscall, str string) bool {
-		if str == call.CallName || str == call.Name {
-			return true
-		}
-		if len(str) > 1 && str[len(str)-1] == '*' && strings.HasPrefix(call.Name, str[:len(str)-1]) {
-			return true
+// FocusGroup is a named group of syscalls for targeted fuzzing.
+type FocusGroup struct {
+	Name     string
+	Syscalls []string // matched the same way as enable_syscalls
+	Weight   float64  // how many times more frequently the syscalls are chosen (0.01..1000)
+}
+
+// ParseFocus returns IDs of syscalls in every focus group (in the order of cfg.Focus).
+func ParseFocus(cfg *Config) ([]map[int]bool, error) {
+	target, err := prog.GetTarget(cfg.TargetOS, cfg.TargetArch)
+	if err != nil {
+		return nil, err
+	}
+	var groups []map[int]bool
+	for _, group := range cfg.Focus {
+		calls := make(map[int]bool)
+		for _, c := range group.Syscalls {
+			n := 0
+			for _, call := range target.Syscalls {
+				if MatchSyscall(call, c) {
+					calls[call.ID] = true
+					n++
+				}
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("unknown syscall %v in focus group %v", c, group.Name)
+			}
 		}
-		return false
+		groups = append(groups, calls)
+	}
+	return groups, nil
+}
+
+// MatchSyscall says if call matches str as used in enable_syscalls/disable_syscalls:
+// str is either a syscall name (e.g. "open" matches all "open$*" variants too),
+// a full variant name, or a variant name prefix followed by '*'.
+func MatchSyscall(call *prog.Syscall, str string) bool {
+	if str == call.CallName || str == call.Name {
+		return true
+	}
+	if len(str) > 1 && str[len(str)-1] == '*' && strings.HasPrefix(call.Name, str[:len(str)-1]) {
+		return true
+	}
+	return false
+}
+
+// TimeoutFlags returns command line flags for syz-fuzzer/syz-execprog
+// that set the non-default execution timeouts.
+func (cfg *Config) TimeoutFlags() string {
+	flags := ""
+	if cfg.Call_Timeout != 0 {
+		flags += fmt.Sprintf(" -call_timeout=%vms", cfg.Call_Timeout)
+	}
+	if cfg.Program_Timeout != 0 {
+		flags += fmt.Sprintf(" -program_timeout=%vms", cfg.Program_Timeout)
+	}
+	if cfg.Shutdown_Grace != 0 {
+		flags += fmt.Sprintf(" -shutdown_grace=%vms", cfg.Shutdown_Grace)
+	}
+	return flags
+}
+
+// VMCommand returns cmd prefixed with shell commands that apply the configured sysctls.
+// Failures to set sysctls are ignored, since they may be missing in some kernels.
+func (cfg *Config) VMCommand(cmd string) string {
+	var names []string
+	for name := range cfg.Sysctl {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	prefix := ""
+	for _, name := range names {
+		prefix += fmt.Sprintf("echo '%v' > %v 2>/dev/null; ", cfg.Sysctl[name], csource.SysctlPath(name))
 	}
+	return prefix + cmd
+}
 
+func ParseEnabledSyscalls(cfg *Config) (map[int]bool, error) {
 	target, err := prog.GetTarget(cfg.TargetOS, cfg.TargetArch)
 	if err != nil {
 		return nil, err
@@ -207,7 +473,7 @@ func ParseEnabledSyscalls(cfg *Config) (map[int]bool, error) {
 		for _, c := range cfg.Enable_Syscalls {
 			n := 0
 			for _, call := range target.Syscalls {
-				if match(call, c) {
+				if MatchSyscall(call, c) {
 					syscalls[call.ID] = true
 					n++
 				}
@@ -224,7 +490,7 @@ func ParseEnabledSyscalls(cfg *Config) (map[int]bool, error) {
 	for _, c := range cfg.Disable_Syscalls {
 		n := 0
 		for _, call := range target.Syscalls {
-			if match(call, c) {
+			if MatchSyscall(call, c) {
 				delete(syscalls, call.ID)
 				n++
 			}
@@ -275,6 +541,7 @@ func CreateVMEnv(cfg *Config, debug bool) *vm.Env {
 		Image:   cfg.Image,
 		SshKey:  cfg.Sshkey,
 		SshUser: cfg.Ssh_User,
+		Cmdline: cfg.Cmdline,
 		Debug:   debug,
 		Config:  cfg.VM,
 	}
@@ -4,10 +4,13 @@
 package mgrconfig
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/google/syzkaller/pkg/config"
+	_ "github.com/google/syzkaller/sys"
 	"github.com/google/syzkaller/vm/gce"
 	"github.com/google/syzkaller/vm/qemu"
 )
@@ -38,3 +41,81 @@ func TestCanned(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFocus(t *testing.T) {
+	cfg := &Config{
+		TargetOS:   "linux",
+		TargetArch: "amd64",
+		Focus: []FocusGroup{
+			{Name: "bpf", Syscalls: []string{"bpf"}, Weight: 10},
+			{Name: "pipe", Syscalls: []string{"pipe", "pipe2"}, Weight: 0.5},
+		},
+	}
+	groups, err := ParseFocus(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || len(groups[0]) < 2 || len(groups[1]) != 2 {
+		t.Fatalf("bad focus groups: %v", groups)
+	}
+	cfg.Focus[1].Syscalls = append(cfg.Focus[1].Syscalls, "foobar")
+	if _, err := ParseFocus(cfg); err == nil {
+		t.Fatalf("unknown syscall is not detected")
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syz-mgrconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	image := filepath.Join(dir, "image")
+	sshkey := filepath.Join(dir, "key")
+	for _, file := range []string{image, sshkey} {
+		if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &Config{
+		Kernel_Src: dir,
+		Image:      image,
+		Sshkey:     sshkey,
+	}
+	if err := CheckFiles(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Image = "9p"
+	if err := CheckFiles(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Image = filepath.Join(dir, "foo")
+	if err := CheckFiles(cfg); err == nil {
+		t.Fatalf("missing image is not detected")
+	}
+	cfg.Image = image
+	if err := os.Chmod(sshkey, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckFiles(cfg); err == nil {
+		t.Fatalf("world-readable ssh key is not detected")
+	}
+}
+
+func TestVMCommand(t *testing.T) {
+	cfg := &Config{}
+	if cmd := cfg.VMCommand("syz-fuzzer"); cmd != "syz-fuzzer" {
+		t.Fatalf("bad command without sysctls: %q", cmd)
+	}
+	cfg.Sysctl = map[string]string{
+		"vm.overcommit_memory":    "1",
+		"kernel.panic_on_warn":    "1",
+		"net.core.bpf_jit_enable": "0 1",
+	}
+	want := "echo '1' > /proc/sys/kernel/panic_on_warn 2>/dev/null; " +
+		"echo '0 1' > /proc/sys/net/core/bpf_jit_enable 2>/dev/null; " +
+		"echo '1' > /proc/sys/vm/overcommit_memory 2>/dev/null; syz-fuzzer"
+	if cmd := cfg.VMCommand("syz-fuzzer"); cmd != want {
+		t.Fatalf("bad command:\n%q\nwant:\n%q", cmd, want)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/pkg/git"
+	"github.com/google/syzkaller/pkg/hash"
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/sys"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+)
+
+// Provenance describes the kernel and syzkaller build the manager is running.
+// It is captured once on start and saved next to every crash log/report
+// in machine-readable form (metadataN.json, repro.metadata.json),
+// so that old crashes can be matched to the exact build they happened on.
+type Provenance struct {
+	Tag              string `json:",omitempty"`
+	Target           string
+	KernelCommit     string `json:",omitempty"`
+	KernelCompiler   string `json:",omitempty"`
+	KernelConfigHash string `json:",omitempty"` // hash of the kernel.config file saved in the crash dir
+	SyzkallerCommit  string
+	DescriptionsHash string
+	kernelConfig     []byte
+}
+
+// CrashMetadata is the contents of metadataN.json/repro.metadata.json files.
+type CrashMetadata struct {
+	Title string
+	Time  time.Time
+	Provenance
+}
+
+const kernelConfigFile = "kernel.config"
+
+func collectProvenance(cfg *mgrconfig.Config, target *prog.Target) *Provenance {
+	prov := &Provenance{
+		Tag:              cfg.Tag,
+		Target:           cfg.Target,
+		SyzkallerCommit:  sys.GitRevision,
+		DescriptionsHash: target.Revision,
+	}
+	if osutil.IsExist(filepath.Join(cfg.Kernel_Src, ".git")) {
+		commit, err := git.HeadCommit(cfg.Kernel_Src)
+		if err != nil {
+			Logf(0, "failed to get kernel commit: %v", err)
+		}
+		prov.KernelCommit = commit
+	}
+	if cfg.Vmlinux != "" {
+		compiler, err := vmlinuxCompiler(cfg.Vmlinux)
+		if err != nil {
+			Logf(0, "failed to get kernel compiler: %v", err)
+		}
+		prov.KernelCompiler = compiler
+	}
+	if cfg.Kernel_Config != "" {
+		data, err := ioutil.ReadFile(cfg.Kernel_Config)
+		if err != nil {
+			Logf(0, "failed to read kernel config: %v", err)
+		}
+		if len(data) != 0 {
+			prov.kernelConfig = data
+			prov.KernelConfigHash = hash.String(data)
+		}
+	}
+	return prov
+}
+
+// vmlinuxCompiler returns identity of the compiler that was used to build vmlinux
+// (e.g. "GCC: (GNU) 7.1.1 20170620") taken from the .comment ELF section.
+func vmlinuxCompiler(vmlinux string) (string, error) {
+	file, err := elf.Open(vmlinux)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	sec := file.Section(".comment")
+	if sec == nil {
+		return "", fmt.Errorf("no .comment section in %v", vmlinux)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(sec.Open(), 1<<20))
+	if err != nil {
+		return "", err
+	}
+	// The section contains a NUL-separated list of identities of all tools,
+	// the first one is the compiler.
+	for _, ident := range bytes.Split(data, []byte{0}) {
+		if s := strings.TrimSpace(string(ident)); s != "" {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("empty .comment section in %v", vmlinux)
+}
+
+// saveCrashMetadata writes metadata file with the given name into crash dir
+// along with the kernel config, if it is not there yet.
+func (prov *Provenance) saveCrashMetadata(dir, name, title string) {
+	meta := &CrashMetadata{
+		Title:      title,
+		Time:       time.Now(),
+		Provenance: *prov,
+	}
+	data, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		Logf(0, "failed to marshal crash metadata: %v", err)
+		return
+	}
+	if err := osutil.WriteFile(filepath.Join(dir, name), data); err != nil {
+		Logf(0, "failed to write crash metadata: %v", err)
+	}
+	if len(prov.kernelConfig) != 0 {
+		configFile := filepath.Join(dir, kernelConfigFile)
+		if old, err := ioutil.ReadFile(configFile); err != nil || !bytes.Equal(old, prov.kernelConfig) {
+			osutil.WriteFile(configFile, prov.kernelConfig)
+		}
+	}
+}
+
+func readCrashMetadata(file string) *CrashMetadata {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	meta := new(CrashMetadata)
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil
+	}
+	return meta
+}
+
+// writeProvenance writes human-readable description of the build for text reports.
+func (meta *CrashMetadata) writeProvenance(w io.Writer) {
+	if meta.KernelCommit != "" {
+		fmt.Fprintf(w, "Kernel commit: %v\n", meta.KernelCommit)
+	}
+	if meta.KernelCompiler != "" {
+		fmt.Fprintf(w, "Compiler: %v\n", meta.KernelCompiler)
+	}
+	if meta.KernelConfigHash != "" {
+		fmt.Fprintf(w, "Kernel config: %v (%v)\n", kernelConfigFile, meta.KernelConfigHash)
+	}
+	fmt.Fprintf(w, "Syzkaller commit: %v\n", meta.SyzkallerCommit)
+	fmt.Fprintf(w, "Target: %v\n\n", meta.Target)
+}
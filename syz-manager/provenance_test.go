@@ -0,0 +1,66 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCrashMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syz-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := []byte("CONFIG_KASAN=y\n")
+	prov := &Provenance{
+		Tag:              "tag",
+		Target:           "linux/amd64",
+		KernelCommit:     "1111111111111111111111111111111111111111",
+		KernelCompiler:   "GCC: (GNU) 7.1.1",
+		KernelConfigHash: "hash",
+		SyzkallerCommit:  "2222222222222222222222222222222222222222",
+		kernelConfig:     config,
+	}
+	prov.saveCrashMetadata(dir, "metadata0.json", "KASAN: use-after-free in foo")
+	meta := readCrashMetadata(filepath.Join(dir, "metadata0.json"))
+	if meta == nil {
+		t.Fatalf("failed to read metadata")
+	}
+	if meta.Title != "KASAN: use-after-free in foo" || meta.Time.IsZero() {
+		t.Fatalf("bad metadata: %+v", meta)
+	}
+	meta.kernelConfig = config
+	if !reflect.DeepEqual(meta.Provenance, *prov) {
+		t.Fatalf("provenance does not match:\ngot:  %+v\nwant: %+v", meta.Provenance, *prov)
+	}
+	saved, err := ioutil.ReadFile(filepath.Join(dir, kernelConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(saved, config) {
+		t.Fatalf("saved config %q, want %q", saved, config)
+	}
+	buf := new(bytes.Buffer)
+	meta.writeProvenance(buf)
+	for _, want := range []string{prov.KernelCommit, prov.KernelCompiler, prov.SyzkallerCommit} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestVmlinuxCompiler(t *testing.T) {
+	// Test binary is not built by a C compiler, so just check that it does not crash.
+	vmlinuxCompiler(os.Args[0])
+	if _, err := vmlinuxCompiler(filepath.Join("non", "existent")); err == nil {
+		t.Fatalf("no error for non-existent file")
+	}
+}
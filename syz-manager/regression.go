@@ -0,0 +1,249 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+// Regression testing re-runs all saved reproducers against the current kernel
+// and detects bugs that were fixed (the reproducer does not crash the kernel anymore)
+// and bugs that regressed (the reproducer of a previously fixed bug crashes the kernel again).
+// Testing is done on start (which usually means a new kernel build) and then periodically.
+// Each run produces a report in workdir/regression, the last status of each bug
+// is saved in its crash dir in the "regression" file.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/dashboard/dashapi"
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/report"
+	"github.com/google/syzkaller/vm"
+)
+
+const regressionTestDuration = 5 * time.Minute
+
+const (
+	regressionFixed      = "fixed"      // the reproducer did not crash the kernel
+	regressionReproduces = "reproduces" // the reproducer triggered the same crash
+	regressionRegressed  = "regressed"  // the reproducer of a fixed bug triggered the same crash
+	regressionOther      = "other"      // the reproducer triggered a different crash
+	regressionError      = "error"      // the test failed (e.g. VM failed to boot)
+)
+
+type RegressionResult struct {
+	ID         string // crash dir name
+	Title      string
+	Status     string
+	PrevStatus string `json:",omitempty"`
+	CrashTitle string `json:",omitempty"` // title of the crash triggered by the reproducer
+	Error      string `json:",omitempty"`
+}
+
+type RegressionReport struct {
+	Tag        string
+	Start      time.Time
+	End        time.Time
+	Fixed      int
+	Reproduces int
+	Regressed  int
+	Other      int
+	Errors     int
+	Results    []*RegressionResult
+}
+
+type RegressionRunResult struct {
+	idx int
+	res *RegressionResult
+}
+
+// regressionRun is an in-progress regression testing run.
+type regressionRun struct {
+	report  *RegressionReport
+	queue   []*RegressionResult // tests that are not started yet
+	running int
+}
+
+// startRegression creates a regression run for all crashes that have reproducers.
+func (mgr *Manager) startRegression() *regressionRun {
+	run := &regressionRun{
+		report: &RegressionReport{
+			Tag:   mgr.cfg.Tag,
+			Start: time.Now(),
+		},
+	}
+	dirs, err := osutil.ListDir(mgr.crashdir)
+	if err != nil {
+		Logf(0, "failed to list crashes: %v", err)
+		return nil
+	}
+	for _, dir := range dirs {
+		if !osutil.IsExist(filepath.Join(mgr.crashdir, dir, "repro.prog")) {
+			continue
+		}
+		desc, err := ioutil.ReadFile(filepath.Join(mgr.crashdir, dir, "description"))
+		if err != nil {
+			continue
+		}
+		prev, _ := ioutil.ReadFile(filepath.Join(mgr.crashdir, dir, "regression"))
+		res := &RegressionResult{
+			ID:         dir,
+			Title:      strings.TrimSpace(string(desc)),
+			PrevStatus: strings.TrimSpace(string(prev)),
+		}
+		run.queue = append(run.queue, res)
+		run.report.Results = append(run.report.Results, res)
+	}
+	if len(run.queue) == 0 {
+		return nil
+	}
+	Logf(0, "starting regression testing of %v reproducers", len(run.queue))
+	return run
+}
+
+func (run *regressionRun) next() *RegressionResult {
+	res := run.queue[0]
+	run.queue = run.queue[1:]
+	run.running++
+	return res
+}
+
+// runRegressionTest runs the reproducer of the crash on VM index and fills in the result.
+func (mgr *Manager) runRegressionTest(index int, res *RegressionResult) {
+	title, rep, err := mgr.testRepro(index, filepath.Join(mgr.crashdir, res.ID, "repro.prog"))
+	switch {
+	case err != nil:
+		res.Status = regressionError
+		res.Error = err.Error()
+	case title == "":
+		res.Status = regressionFixed
+	case !mgr.sameCrash(title, rep, res.Title):
+		res.Status = regressionOther
+		res.CrashTitle = title
+	case res.PrevStatus == regressionFixed:
+		res.Status = regressionRegressed
+		res.CrashTitle = title
+	default:
+		res.Status = regressionReproduces
+		res.CrashTitle = title
+	}
+	Logf(0, "vm-%v: regression test of '%v': %v %v", index, res.Title, res.Status, res.CrashTitle)
+	if res.Status == regressionError {
+		return
+	}
+	status := []byte(res.Status + "\n")
+	if err := osutil.WriteFile(filepath.Join(mgr.crashdir, res.ID, "regression"), status); err != nil {
+		Logf(0, "failed to write regression status: %v", err)
+	}
+}
+
+// testRepro boots a VM and runs the reproducer in it.
+// Returns title and report of the crash, or empty title if the kernel did not crash.
+func (mgr *Manager) testRepro(index int, reproFile string) (string, []byte, error) {
+	inst, err := mgr.vmPool.Create(index)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create instance: %v", err)
+	}
+	defer inst.Close()
+	execprogBin, err := inst.Copy(mgr.cfg.SyzExecprogBin)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to copy binary: %v", err)
+	}
+	executorBin, err := inst.Copy(mgr.cfg.SyzExecutorBin)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to copy binary: %v", err)
+	}
+	vmReproFile, err := inst.Copy(reproFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to copy reproducer: %v", err)
+	}
+	cmd := fmt.Sprintf("%v -executor=%v -arch=%v -repeat=0 -procs=%v -cover=0 -sandbox=%v %v",
+		execprogBin, executorBin, mgr.cfg.TargetArch, mgr.cfg.Procs, mgr.cfg.Sandbox, vmReproFile)
+	outc, errc, err := inst.Run(regressionTestDuration, nil, mgr.cfg.VMCommand(cmd))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to run execprog: %v", err)
+	}
+	desc, text, _, crashed, timedout := vm.MonitorExecution(outc, errc, false, mgr.getReporter())
+	if timedout || !crashed {
+		return "", nil, nil
+	}
+	return desc, text, nil
+}
+
+// sameCrash returns true if the crash is the bug, or was merged into the bug
+// because of the same stack signature (see dedupCrash).
+func (mgr *Manager) sameCrash(title string, rep []byte, bug string) bool {
+	if title == bug {
+		return true
+	}
+	sig := report.StackSignature(rep)
+	if sig == "" {
+		return false
+	}
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	return mgr.crashSigs[sig] == bug
+}
+
+// finishRegression saves the report of the finished regression run
+// and sends the results to dashboard.
+func (mgr *Manager) finishRegression(run *regressionRun) {
+	rep := run.report
+	rep.End = time.Now()
+	for _, res := range rep.Results {
+		switch res.Status {
+		case regressionFixed:
+			rep.Fixed++
+		case regressionReproduces:
+			rep.Reproduces++
+		case regressionRegressed:
+			rep.Regressed++
+		case regressionOther:
+			rep.Other++
+		case regressionError:
+			rep.Errors++
+		}
+	}
+	Logf(0, "regression testing finished: fixed=%v reproduces=%v regressed=%v other=%v errors=%v",
+		rep.Fixed, rep.Reproduces, rep.Regressed, rep.Other, rep.Errors)
+	mgr.mu.Lock()
+	mgr.lastRegression = rep
+	mgr.mu.Unlock()
+
+	data, err := json.MarshalIndent(rep, "", "\t")
+	if err != nil {
+		Logf(0, "failed to marshal regression report: %v", err)
+		return
+	}
+	dir := filepath.Join(mgr.cfg.Workdir, "regression")
+	osutil.MkdirAll(dir)
+	file := filepath.Join(dir, rep.Start.Format("2006-01-02-15-04-05")+".json")
+	if err := osutil.WriteFile(file, data); err != nil {
+		Logf(0, "failed to write regression report: %v", err)
+	}
+
+	if mgr.dash != nil {
+		dr := &dashapi.RegressionReport{
+			BuildID: mgr.cfg.Tag,
+		}
+		for _, res := range rep.Results {
+			if res.Status == regressionError {
+				continue
+			}
+			dr.Results = append(dr.Results, dashapi.RegressionResult{
+				Title:      res.Title,
+				Reproduced: res.Status == regressionReproduces || res.Status == regressionRegressed,
+			})
+			if res.Status == regressionOther {
+				dr.Results[len(dr.Results)-1].CrashTitle = res.CrashTitle
+			}
+		}
+		if err := mgr.dash.ReportRegression(dr); err != nil {
+			Logf(0, "failed to report regression results to dashboard: %v", err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+// Syscalls enabled in config can be disabled and re-enabled while the manager runs
+// (e.g. to steer fuzzing toward a subsystem after reviewing coverage).
+// Changes are propagated to fuzzers in Poll replies, fuzzers stop generating new calls
+// to disabled syscalls, but programs that are already in corpus are still mutated.
+// The runtime set is not persisted across manager restarts.
+
+import (
+	"fmt"
+	"sort"
+
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/prog"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+)
+
+type SyscallState struct {
+	Name    string
+	Enabled bool
+}
+
+// matchSyscalls returns syscalls from the enabled set that match any of the patterns
+// (in the enable_syscalls config format). mmap can't be disabled because it's used
+// to allocate memory for all programs.
+func matchSyscalls(target *prog.Target, enabled map[int]bool, patterns []string) ([]*prog.Syscall, error) {
+	var res []*prog.Syscall
+	for _, pattern := range patterns {
+		n := 0
+		for _, c := range target.Syscalls {
+			if !enabled[c.ID] || c == target.MmapSyscall || !mgrconfig.MatchSyscall(c, pattern) {
+				continue
+			}
+			res = append(res, c)
+			n++
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("no enabled syscalls match %q", pattern)
+		}
+	}
+	return res, nil
+}
+
+// setSyscallsEnabled enables or disables syscalls matching patterns
+// and returns the number of syscalls that changed state.
+func (mgr *Manager) setSyscallsEnabled(patterns []string, enable bool) (int, error) {
+	calls, err := matchSyscalls(mgr.target, mgr.syscalls, patterns)
+	if err != nil {
+		return 0, err
+	}
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	changed := 0
+	for _, c := range calls {
+		if mgr.disabledCalls[c.ID] == !enable {
+			continue
+		}
+		if enable {
+			delete(mgr.disabledCalls, c.ID)
+		} else {
+			mgr.disabledCalls[c.ID] = true
+		}
+		changed++
+	}
+	if changed != 0 {
+		for _, f := range mgr.fuzzers {
+			f.updateCalls = true
+		}
+		Logf(0, "enabled=%v %v syscalls (%v disabled in total)", enable, changed, len(mgr.disabledCalls))
+	}
+	return changed, nil
+}
+
+// disabledCallNames returns sorted names of syscalls disabled at runtime.
+// Must be called with mgr.mu held.
+func (mgr *Manager) disabledCallNames() []string {
+	var names []string
+	for id := range mgr.disabledCalls {
+		names = append(names, mgr.target.Syscalls[id].Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// syscallStates returns state of all syscalls enabled in config sorted by name.
+func (mgr *Manager) syscallStates() []SyscallState {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	states := []SyscallState{}
+	for _, c := range mgr.target.Syscalls {
+		if !mgr.syscalls[c.ID] {
+			continue
+		}
+		states = append(states, SyscallState{
+			Name:    c.Name,
+			Enabled: !mgr.disabledCalls[c.ID],
+		})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/syzkaller/prog"
+)
+
+func TestMatchSyscalls(t *testing.T) {
+	target, err := prog.GetTarget("linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled := make(map[int]bool)
+	for _, name := range []string{"mmap", "open", "openat", "socket$inet", "socket$inet6", "close"} {
+		enabled[target.SyscallMap[name].ID] = true
+	}
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"open"}, []string{"open"}},
+		{[]string{"open*"}, []string{"open", "openat"}},
+		{[]string{"socket"}, []string{"socket$inet", "socket$inet6"}},
+		{[]string{"socket$inet6", "close"}, []string{"close", "socket$inet6"}},
+		{[]string{"mmap"}, nil},
+		{[]string{"socket$unix"}, nil},
+		{[]string{"close", "foo"}, nil},
+	}
+	for i, test := range tests {
+		calls, err := matchSyscalls(target, enabled, test.patterns)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("test #%v: no error for %q", i, test.patterns)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test #%v: %v", i, err)
+			continue
+		}
+		var got []string
+		for _, c := range calls {
+			got = append(got, c.Name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test #%v: got %q, want %q", i, got, test.want)
+		}
+	}
+}
@@ -5,6 +5,11 @@
 // First, run syz-manager with -bench=old flag.
 // Then, do experimental modifications and run syz-manager again with -bench=new flag.
 // Then, run syz-benchcmp old new.
+// Fuzzing is noisy, so it's better to run each configuration several times
+// and pass a comma-separated list of bench files for each configuration:
+// syz-benchcmp old0,old1,old2 new0,new1,new2.
+// Then the graphs show the mean and the 95% confidence interval across runs,
+// and the final stats show whether the difference is statistically significant.
 package main
 
 import (
@@ -14,14 +19,17 @@ import (
 	"fmt"
 	"html/template"
 	"io/ioutil"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strings"
 )
 
 var (
 	flagSkip = flag.Int("skip", -30, "skip that many seconds after start (skip first 20% by default)")
+	flagOut  = flag.String("out", "", "write html to this file instead of opening it in browser")
 )
 
 type Graph struct {
@@ -33,23 +41,44 @@ type Graph struct {
 type Point struct {
 	Time uint64
 	Vals []uint64
+	// Lower and upper bounds of the confidence interval for Vals (multi-run configurations only).
+	Lo []uint64
+	Hi []uint64
+}
+
+// Config is a set of bench files obtained with the same configuration.
+type Config struct {
+	Name  string
+	Files []string
 }
 
 func main() {
 	flag.Parse()
 	if len(flag.Args()) == 0 {
-		fmt.Fprintf(os.Stderr, "usage: syz-benchcmp [flags] bench_file0 [bench_file1 [bench_file2]]...\n")
+		fmt.Fprintf(os.Stderr, "usage: syz-benchcmp [flags] bench_file0[,bench_file0...] [bench_file1[,bench_file1...]]...\n")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
+	var configs []Config
+	var runs []string
+	for _, arg := range flag.Args() {
+		files := strings.Split(arg, ",")
+		name := filepath.Base(files[0])
+		if len(files) > 1 {
+			name = fmt.Sprintf("%v (x%v)", name, len(files))
+		}
+		configs = append(configs, Config{name, files})
+		runs = append(runs, files...)
+	}
 	graphs := []*Graph{
 		&Graph{Name: "coverage"},
 		&Graph{Name: "corpus"},
 		&Graph{Name: "exec total"},
+		&Graph{Name: "exec speed"},
 		&Graph{Name: "crash types"},
 	}
-	for i, fname := range flag.Args() {
+	for i, fname := range runs {
 		data := readFile(fname)
 		addExecSpeed(data)
 		for _, g := range graphs {
@@ -57,7 +86,7 @@ func main() {
 			for _, v := range data {
 				pt := Point{
 					Time: v["fuzzing"],
-					Vals: make([]uint64, len(flag.Args())),
+					Vals: make([]uint64, len(runs)),
 				}
 				pt.Vals[i] = v[g.Name]
 				g.Points = append(g.Points, pt)
@@ -72,7 +101,10 @@ func main() {
 		skipStart(g)
 		restoreMissingPoints(g)
 	}
-	printFinalStats(graphs)
+	printFinalStats(configs, graphs)
+	for _, g := range graphs {
+		aggregateRuns(configs, g)
+	}
 	display(graphs)
 }
 
@@ -160,35 +192,158 @@ func restoreMissingPoints(g *Graph) {
 	}
 }
 
-func printFinalStats(graphs []*Graph) {
-	for i := 1; i < len(graphs[0].Headers); i++ {
-		fmt.Printf("%-12v%16v%16v%16v\n", "", graphs[0].Headers[0], graphs[0].Headers[i], "diff")
-		for _, g := range graphs {
-			lastNonZero := func(x int) uint64 {
-				for j := len(g.Points) - 1; j >= 0; j-- {
-					if v := g.Points[j].Vals[x]; v != 0 {
-						return v
-					}
+// aggregateRuns replaces per-run columns with per-config mean and confidence interval.
+// Points where not all runs of a config have data are left empty for that config.
+func aggregateRuns(configs []Config, g *Graph) {
+	g.Headers = nil
+	for _, cfg := range configs {
+		g.Headers = append(g.Headers, cfg.Name)
+	}
+	for pi := range g.Points {
+		pt := &g.Points[pi]
+		var vals, lo, hi []uint64
+		run := 0
+		for _, cfg := range configs {
+			var sample []float64
+			for range cfg.Files {
+				if v := pt.Vals[run]; v != 0 {
+					sample = append(sample, float64(v))
+				}
+				run++
+			}
+			var st Stats
+			if len(sample) == len(cfg.Files) {
+				st = calcStats(sample)
+			}
+			vals = append(vals, uint64(st.Mean))
+			lo = append(lo, uint64(math.Max(st.Mean-st.CI, 0)))
+			hi = append(hi, uint64(st.Mean+st.CI))
+		}
+		pt.Vals, pt.Lo, pt.Hi = vals, lo, hi
+	}
+}
+
+func printFinalStats(configs []Config, graphs []*Graph) {
+	finalStats := func(g *Graph, cfg int) Stats {
+		run := 0
+		for i := 0; i < cfg; i++ {
+			run += len(configs[i].Files)
+		}
+		var sample []float64
+		for x := run; x < run+len(configs[cfg].Files); x++ {
+			for j := len(g.Points) - 1; j >= 0; j-- {
+				if v := g.Points[j].Vals[x]; v != 0 {
+					sample = append(sample, float64(v))
+					break
 				}
-				return 0
 			}
-			old := lastNonZero(0)
-			new := lastNonZero(i)
-			fmt.Printf("%-12v%16v%16v%+16d\n", g.Name, old, new, int64(new-old))
+		}
+		return calcStats(sample)
+	}
+	for i := 1; i < len(configs); i++ {
+		fmt.Printf("%-12v%24v%24v%16v\n", "", configs[0].Name, configs[i].Name, "diff")
+		for _, g := range graphs {
+			old := finalStats(g, 0)
+			new := finalStats(g, i)
+			diff := fmt.Sprintf("%+.0f", new.Mean-old.Mean)
+			if old.Mean != 0 {
+				diff = fmt.Sprintf("%+.1f%%", (new.Mean-old.Mean)*100/old.Mean)
+			}
+			if significant(old, new) {
+				diff += "*"
+			}
+			fmt.Printf("%-12v%24v%24v%16v\n", g.Name, old, new, diff)
 		}
 		fmt.Printf("\n")
 	}
+	if len(configs) > 1 {
+		fmt.Printf("values are mean ± 95%% confidence interval, * marks significant difference\n")
+	}
+}
+
+// Stats describes a sample of final values of a single metric across runs of a config.
+type Stats struct {
+	N      int
+	Mean   float64
+	Stddev float64
+	CI     float64 // half-width of the 95% confidence interval for Mean
+}
+
+func (st Stats) String() string {
+	if st.N <= 1 {
+		return fmt.Sprintf("%.0f", st.Mean)
+	}
+	return fmt.Sprintf("%.0f ± %.0f", st.Mean, st.CI)
+}
+
+func calcStats(sample []float64) Stats {
+	st := Stats{N: len(sample)}
+	if st.N == 0 {
+		return st
+	}
+	for _, v := range sample {
+		st.Mean += v
+	}
+	st.Mean /= float64(st.N)
+	if st.N == 1 {
+		return st
+	}
+	for _, v := range sample {
+		st.Stddev += (v - st.Mean) * (v - st.Mean)
+	}
+	st.Stddev = math.Sqrt(st.Stddev / float64(st.N-1))
+	st.CI = tQuantile(st.N-1) * st.Stddev / math.Sqrt(float64(st.N))
+	return st
+}
+
+// significant uses Welch's t-test to check if the difference between means
+// of the two samples is significant at the 95% level.
+func significant(a, b Stats) bool {
+	if a.N <= 1 || b.N <= 1 {
+		return false
+	}
+	va := a.Stddev * a.Stddev / float64(a.N)
+	vb := b.Stddev * b.Stddev / float64(b.N)
+	if va+vb == 0 {
+		return a.Mean != b.Mean
+	}
+	t := math.Abs(a.Mean-b.Mean) / math.Sqrt(va+vb)
+	df := (va + vb) * (va + vb) / (va*va/float64(a.N-1) + vb*vb/float64(b.N-1))
+	return t > tQuantile(int(df))
+}
+
+// tQuantile returns two-sided 95% quantile of Student's t-distribution with df degrees of freedom.
+func tQuantile(df int) float64 {
+	table := []float64{12.71, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+	if df < 1 {
+		df = 1
+	}
+	if df > len(table) {
+		return 1.96
+	}
+	return table[df-1]
 }
 
 func display(graphs []*Graph) {
-	outf, err := ioutil.TempFile("", "")
+	var outf *os.File
+	var err error
+	if *flagOut != "" {
+		outf, err = os.Create(*flagOut)
+	} else {
+		outf, err = ioutil.TempFile("", "")
+	}
 	if err != nil {
-		failf("failed to create temp file: %v", err)
+		failf("failed to create output file: %v", err)
 	}
 	if err := htmlTemplate.Execute(outf, graphs); err != nil {
 		failf("failed to execute template: %v", err)
 	}
 	outf.Close()
+	if *flagOut != "" {
+		return
+	}
 	name := outf.Name() + ".html"
 	if err := os.Rename(outf.Name(), name); err != nil {
 		failf("failed to rename file: %v", err)
@@ -210,7 +365,9 @@ func failf(msg string, args ...interface{}) {
 }
 
 var htmlTemplate = template.Must(
-	template.New("").Parse(`
+	template.New("").Funcs(template.FuncMap{
+		"odd": func(i int) bool { return i%2 == 1 },
+	}).Parse(`
 <!doctype html>
 <html>
   <head>
@@ -226,9 +383,11 @@ var htmlTemplate = template.Must(
           data.addColumn({type: 'number'});
           {{range $graph.Headers}}
             data.addColumn({type: 'number', label: '{{.}}'});
+            data.addColumn({type: 'number', role: 'interval'});
+            data.addColumn({type: 'number', role: 'interval'});
           {{end}}
           data.addRows([
-            {{range $graph.Points}} [ {{.Time}}, {{range .Vals}} {{if .}} {{.}} {{end}}, {{end}}
+            {{range $pt := $graph.Points}} [ {{$pt.Time}}, {{range $i, $v := $pt.Vals}} {{if $v}} {{$v}}, {{index $pt.Lo $i}}, {{index $pt.Hi $i}} {{else}} , , {{end}}, {{end}}
           ],
           {{end}}
           ]);
@@ -240,6 +399,7 @@ var htmlTemplate = template.Must(
               legend: {position: "in"},
               focusTarget: "category",
               hAxis: {title: "Time, sec"},
+              intervals: {style: "area"},
               chartArea: {left: "5%", top: "5%", width: "90%", height:"85%"}
             })
         }
@@ -249,14 +409,12 @@ var htmlTemplate = template.Must(
 </head>
 <body>
   <table style="width: 100%; height: 98vh">
-    <tr>
-      <td style="width: 50%"> <div id="graph_div_0"></div> </td>
-      <td style="width: 50%"> <div id="graph_div_2"></div> </td>
-    </tr>
-    <tr>
-      <td style="width: 50%"> <div id="graph_div_1"></div> </td>
-      <td style="width: 50%"> <div id="graph_div_3"></div> </td>
-    </tr>
+    {{range $id, $graph := .}}
+    {{if not (odd $id)}}<tr>{{end}}
+      <td style="width: 50%"> <div id="graph_div_{{$id}}"></div> </td>
+    {{if odd $id}}</tr>{{end}}
+    {{end}}
+    {{if odd (len .)}}</tr>{{end}}
   </table>
 </body>
 </html>
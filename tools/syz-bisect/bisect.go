@@ -0,0 +1,87 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-bisect finds the kernel commit that introduced a crash given a C reproducer.
+// Usage:
+//
+//	syz-bisect -config=manager.cfg -kernel_dir=linux -good=v4.14 -bad=HEAD \
+//		-kernel_config=.config -userspace=wheezy -title="KASAN: use-after-free Read in foo" repro.c
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/google/syzkaller/pkg/bisect"
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+)
+
+var (
+	flagConfig       = flag.String("config", "", "manager config file (VM type and target)")
+	flagKernelDir    = flag.String("kernel_dir", "", "kernel git checkout (will be modified)")
+	flagGood         = flag.String("good", "", "commit where the crash does not happen")
+	flagBad          = flag.String("bad", "", "commit where the crash happens")
+	flagKernelConfig = flag.String("kernel_config", "", "kernel config file")
+	flagCompiler     = flag.String("compiler", "gcc", "compiler to build kernel")
+	flagCcache       = flag.String("ccache", "", "ccache binary (optional)")
+	flagUserspace    = flag.String("userspace", "", "userspace system dir for image")
+	flagCmdline      = flag.String("cmdline", "", "file with additional kernel command line (optional)")
+	flagSysctl       = flag.String("sysctl", "", "file with additional sysctl values (optional)")
+	flagWorkdir      = flag.String("workdir", "bisect-workdir", "dir for kernel images and temp files")
+	flagTitle        = flag.String("title", "", "title of the crash (any crash counts if empty)")
+	flagAttempts     = flag.Int("attempts", 3, "number of reproducer runs on every commit")
+	flagDuration     = flag.Duration("duration", 10*time.Minute, "duration of every reproducer run")
+)
+
+func main() {
+	flag.Parse()
+	if len(flag.Args()) != 1 || *flagConfig == "" || *flagKernelDir == "" ||
+		*flagGood == "" || *flagBad == "" || *flagKernelConfig == "" || *flagUserspace == "" {
+		fmt.Fprintf(os.Stderr, "usage: syz-bisect -config=manager.cfg -kernel_dir=linux -good=commit -bad=commit"+
+			" -kernel_config=.config -userspace=dir [flags] repro.c\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	mgrcfg, err := mgrconfig.LoadFile(*flagConfig)
+	if err != nil {
+		Fatalf("%v", err)
+	}
+	repro, err := ioutil.ReadFile(flag.Args()[0])
+	if err != nil {
+		Fatalf("failed to read reproducer: %v", err)
+	}
+	cfg := &bisect.Config{
+		Trace:        os.Stdout,
+		KernelDir:    *flagKernelDir,
+		Good:         *flagGood,
+		Bad:          *flagBad,
+		KernelConfig: *flagKernelConfig,
+		Compiler:     *flagCompiler,
+		Ccache:       *flagCcache,
+		Userspace:    *flagUserspace,
+		Cmdline:      *flagCmdline,
+		Sysctl:       *flagSysctl,
+		WorkDir:      *flagWorkdir,
+		Manager:      mgrcfg,
+		Repro:        repro,
+		Title:        *flagTitle,
+		Attempts:     *flagAttempts,
+		Duration:     *flagDuration,
+	}
+	commits, err := bisect.Run(cfg)
+	if err != nil {
+		Fatalf("bisection failed: %v", err)
+	}
+	if len(commits) == 1 {
+		fmt.Printf("the first bad commit: %v %v\n", commits[0].Hash, commits[0].Title)
+		return
+	}
+	fmt.Printf("bisection is inconclusive, the first bad commit could be any of:\n")
+	for _, com := range commits {
+		fmt.Printf("%v %v\n", com.Hash, com.Title)
+	}
+}
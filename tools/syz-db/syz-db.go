@@ -25,6 +25,10 @@ func main() {
 		pack(os.Args[2], os.Args[3])
 	case "unpack":
 		unpack(os.Args[2], os.Args[3])
+	case "export":
+		export(os.Args[2], os.Args[3])
+	case "import":
+		importDir(os.Args[2], os.Args[3])
 	default:
 		usage()
 	}
@@ -34,6 +38,9 @@ func usage() {
 	fmt.Fprintf(os.Stderr, "usage:\n")
 	fmt.Fprintf(os.Stderr, "  syz-db pack dir corpus.db\n")
 	fmt.Fprintf(os.Stderr, "  syz-db unpack corpus.db dir\n")
+	fmt.Fprintf(os.Stderr, "  syz-db export corpus.db dir\n")
+	fmt.Fprintf(os.Stderr, "  syz-db import dir corpus.db\n")
+	fmt.Fprintf(os.Stderr, "export/import use portable format: textual .syz programs with metadata\n")
 	os.Exit(1)
 }
 
@@ -89,6 +96,43 @@ func unpack(file, dir string) {
 	}
 }
 
+func export(file, dir string) {
+	corpus, err := db.Open(file)
+	if err != nil {
+		failf("failed to open database: %v", err)
+	}
+	if err := db.Export(corpus, dir); err != nil {
+		failf("failed to export database: %v", err)
+	}
+}
+
+func importDir(dir, file string) {
+	records, err := db.Import(dir)
+	if err != nil {
+		failf("failed to import corpus: %v", err)
+	}
+	os.Remove(file)
+	db, err := db.Open(file)
+	if err != nil {
+		failf("failed to open database file: %v", err)
+	}
+	for _, rec := range records {
+		key := rec.Key
+		if sig := hash.String(rec.Val); key != sig {
+			fmt.Fprintf(os.Stderr, "fixing hash %v -> %v\n", key, sig)
+			key = sig
+		}
+		seq := rec.Meta.Seq
+		if seq == 0 && !rec.Meta.Time.IsZero() {
+			seq = uint64(rec.Meta.Time.Unix())
+		}
+		db.Save(key, rec.Val, seq)
+	}
+	if err := db.Flush(); err != nil {
+		failf("failed to save database file: %v", err)
+	}
+}
+
 func failf(msg string, args ...interface{}) {
 	fmt.Fprintf(os.Stderr, msg+"\n", args...)
 	os.Exit(1)
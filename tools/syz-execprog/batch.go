@@ -0,0 +1,221 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/ipc"
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/prog"
+)
+
+// Batch mode executes every program once in a fresh executor process
+// (so that programs don't affect each other) and writes a machine-readable summary.
+// This is useful for triage of a corpus on a new kernel.
+
+type BatchResult struct {
+	Name    string
+	Calls   int
+	Crashed bool   // executor detected a kernel bug
+	Hanged  bool   // the program did not finish within the time budget
+	Error   string `json:",omitempty"`
+	Errnos  []int  // per-call errno, -1 if the call was not executed
+}
+
+type BatchSummary struct {
+	Programs int
+	Crashed  int
+	Hanged   int
+	Errors   int
+	// Errno histogram across all executed calls (0 for successful calls).
+	Errnos  map[int]int
+	Results []*BatchResult
+}
+
+type namedProg struct {
+	name string
+	p    *prog.Prog
+}
+
+// loadPrograms loads programs from log files, directories with program/log files and corpus databases.
+func loadPrograms(target *prog.Target, files []string) []namedProg {
+	var progs []namedProg
+	for _, fn := range files {
+		if filepath.Base(fn) == "corpus.db" {
+			progs = append(progs, loadCorpusDB(target, fn)...)
+			continue
+		}
+		if st, err := os.Stat(fn); err == nil && st.IsDir() {
+			entries, err := osutil.ListDir(fn)
+			if err != nil {
+				Fatalf("failed to read dir: %v", err)
+			}
+			sort.Strings(entries)
+			for _, ent := range entries {
+				progs = append(progs, loadLog(target, filepath.Join(fn, ent))...)
+			}
+			continue
+		}
+		progs = append(progs, loadLog(target, fn)...)
+	}
+	return progs
+}
+
+func loadLog(target *prog.Target, fn string) []namedProg {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		Fatalf("failed to read log file: %v", err)
+	}
+	var progs []namedProg
+	entries := target.ParseLog(data)
+	for i, ent := range entries {
+		name := fn
+		if len(entries) > 1 {
+			name = fmt.Sprintf("%v#%v", fn, i)
+		}
+		progs = append(progs, namedProg{name, ent.P})
+	}
+	return progs
+}
+
+func loadCorpusDB(target *prog.Target, fn string) []namedProg {
+	corpus, err := db.Open(fn)
+	if err != nil {
+		Fatalf("failed to open corpus database: %v", err)
+	}
+	var keys []string
+	for key := range corpus.Records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var progs []namedProg
+	for _, key := range keys {
+		p, err := target.Deserialize(corpus.Records[key].Val)
+		if err != nil {
+			Logf(0, "failed to deserialize corpus program %v: %v", key, err)
+			continue
+		}
+		progs = append(progs, namedProg{fn + ":" + key, p})
+	}
+	return progs
+}
+
+func runBatch(progs []namedProg, config ipc.Config, execOpts *ipc.ExecOpts, procs int, output string) {
+	if config.Timeout == 0 {
+		config.Timeout = *flagBatchTimeout
+	}
+	execOpts.Flags |= ipc.FlagCollectErrno
+	results := make([]*BatchResult, len(progs))
+	var wg sync.WaitGroup
+	var posMu sync.Mutex
+	pos := 0
+	lastPrint := time.Now()
+	shutdown := make(chan struct{})
+	osutil.HandleInterrupts(shutdown)
+	for pid := 0; pid < procs; pid++ {
+		pid := pid
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				posMu.Lock()
+				idx := pos
+				pos++
+				if time.Since(lastPrint) > 5*time.Second {
+					Logf(0, "executed programs: %v/%v", idx, len(progs))
+					lastPrint = time.Now()
+				}
+				posMu.Unlock()
+				if idx >= len(progs) {
+					return
+				}
+				select {
+				case <-shutdown:
+					return
+				default:
+				}
+				results[idx] = runIsolated(progs[idx], pid, config, execOpts)
+			}
+		}()
+	}
+	wg.Wait()
+
+	summary := &BatchSummary{
+		Errnos:  make(map[int]int),
+		Results: []*BatchResult{},
+	}
+	for _, res := range results {
+		if res == nil {
+			continue // interrupted
+		}
+		summary.Programs++
+		if res.Crashed {
+			summary.Crashed++
+		}
+		if res.Hanged {
+			summary.Hanged++
+		}
+		if res.Error != "" {
+			summary.Errors++
+		}
+		for _, errno := range res.Errnos {
+			if errno != -1 {
+				summary.Errnos[errno]++
+			}
+		}
+		summary.Results = append(summary.Results, res)
+	}
+	data, err := json.MarshalIndent(summary, "", "\t")
+	if err != nil {
+		Fatalf("failed to marshal summary: %v", err)
+	}
+	data = append(data, '\n')
+	if output == "-" {
+		os.Stdout.Write(data)
+	} else if err := osutil.WriteFile(output, data); err != nil {
+		Fatalf("failed to write summary: %v", err)
+	}
+	Logf(0, "executed %v programs: crashed=%v hanged=%v errors=%v",
+		summary.Programs, summary.Crashed, summary.Hanged, summary.Errors)
+}
+
+// runIsolated executes the program in a fresh executor process.
+func runIsolated(np namedProg, pid int, config ipc.Config, execOpts *ipc.ExecOpts) *BatchResult {
+	res := &BatchResult{
+		Name:  np.name,
+		Calls: len(np.p.Calls),
+	}
+	env, err := ipc.MakeEnv(*flagExecutor, pid, config)
+	if err != nil {
+		res.Error = fmt.Sprintf("failed to create ipc env: %v", err)
+		return res
+	}
+	defer env.Close()
+	output, info, failed, hanged, err := env.Exec(execOpts, np.p)
+	res.Crashed = failed
+	res.Hanged = hanged
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if failed {
+		fmt.Printf("BUG: executor-detected bug in %v:\n%s", np.name, output)
+	}
+	if leaks := ipc.FormatLeaks(np.p, info); leaks != "" {
+		fmt.Printf("%v:\n%s", np.name, leaks)
+	}
+	for _, inf := range info {
+		res.Errnos = append(res.Errnos, inf.Errno)
+	}
+	return res
+}
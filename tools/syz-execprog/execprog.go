@@ -10,7 +10,6 @@ import (
 	"encoding/binary"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"runtime"
 	"sync"
@@ -18,6 +17,7 @@ import (
 
 	"github.com/google/syzkaller/pkg/cover"
 	"github.com/google/syzkaller/pkg/ipc"
+	"github.com/google/syzkaller/pkg/kmemleak"
 	. "github.com/google/syzkaller/pkg/log"
 	"github.com/google/syzkaller/pkg/osutil"
 	"github.com/google/syzkaller/prog"
@@ -34,12 +34,17 @@ var (
 	flagFaultCall = flag.Int("fault_call", -1, "inject fault into this call (0-based)")
 	flagFaultNth  = flag.Int("fault_nth", 0, "inject fault on n-th operation (0-based)")
 	flagHints     = flag.Bool("hints", false, "do a hints-generation run")
+	flagErrno     = flag.Bool("errno", false, "print errno of each executed call")
+	flagLeak      = flag.Bool("leak", false, "scan for memory leaks between and after executions")
+
+	flagBatch        = flag.String("batch", "", "execute each program once in a fresh executor and write JSON summary to this file (- for stdout)")
+	flagBatchTimeout = flag.Duration("batch_timeout", 10*time.Second, "per-program time budget in batch mode (unless -timeout is given)")
 )
 
 func main() {
 	flag.Parse()
 	if len(flag.Args()) == 0 {
-		fmt.Fprintf(os.Stderr, "usage: execprog [flags] file-with-programs+\n")
+		fmt.Fprintf(os.Stderr, "usage: execprog [flags] (file-with-programs|dir|corpus.db)+\n")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
@@ -49,16 +54,10 @@ func main() {
 		Fatalf("%v", err)
 	}
 
+	namedProgs := loadPrograms(target, flag.Args())
 	var progs []*prog.Prog
-	for _, fn := range flag.Args() {
-		data, err := ioutil.ReadFile(fn)
-		if err != nil {
-			Fatalf("failed to read log file: %v", err)
-		}
-		entries := target.ParseLog(data)
-		for _, ent := range entries {
-			progs = append(progs, ent.P)
-		}
+	for _, np := range namedProgs {
+		progs = append(progs, np.p)
 	}
 	Logf(0, "parsed %v programs", len(progs))
 	if len(progs) == 0 {
@@ -86,6 +85,10 @@ func main() {
 		execOpts.Flags |= ipc.FlagCollectComps
 	}
 
+	if *flagErrno {
+		execOpts.Flags |= ipc.FlagCollectErrno
+	}
+
 	if *flagFaultCall >= 0 {
 		config.Flags |= ipc.FlagEnableFault
 		execOpts.Flags |= ipc.FlagInjectFault
@@ -103,10 +106,27 @@ func main() {
 		config.Flags |= ipc.FlagEnableTun
 	}
 
+	if *flagBatch != "" {
+		runBatch(namedProgs, config, execOpts, *flagProcs, *flagBatch)
+		return
+	}
+
+	var leakCallback func()
+	if *flagLeak {
+		if err := kmemleak.Init(true); err != nil {
+			Fatalf("%v", err)
+		}
+		// Discard leaks that happened before the programs were executed.
+		if _, err := kmemleak.Scan(false); err != nil {
+			Fatalf("failed to scan for leaks: %v", err)
+		}
+		leakCallback = scanLeaks
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(*flagProcs)
 	var posMu, logMu sync.Mutex
-	gate := ipc.NewGate(2**flagProcs, nil)
+	gate := ipc.NewGate(2**flagProcs, leakCallback)
 	var pos int
 	var lastPrint time.Time
 	shutdown := make(chan struct{})
@@ -119,6 +139,8 @@ func main() {
 				Fatalf("failed to create ipc env: %v", err)
 			}
 			defer env.Close()
+			// Resource leaks are detected across repeated executions of the same program.
+			leakDetectors := make(map[int]*ipc.LeakDetector)
 			for {
 				if !func() bool {
 					// Limit concurrency window.
@@ -153,9 +175,33 @@ func main() {
 					if failed {
 						fmt.Printf("BUG: executor-detected bug:\n%s", output)
 					}
+					if leaks := ipc.FormatLeaks(p, info); leaks != "" {
+						fmt.Print(leaks)
+					}
+					if config.Flags&ipc.FlagResourceLeaks != 0 {
+						detector := leakDetectors[idx%len(progs)]
+						if detector == nil {
+							detector = new(ipc.LeakDetector)
+							leakDetectors[idx%len(progs)] = detector
+						}
+						if res, ok := env.Resources(); !ok {
+							detector.Reset()
+						} else if leaks := detector.Add(res); leaks != "" {
+							fmt.Print(leaks)
+						}
+					}
 					if config.Flags&ipc.FlagDebug != 0 || err != nil {
 						fmt.Printf("result: failed=%v hanged=%v err=%v\n\n%s", failed, hanged, err, output)
 					}
+					if *flagErrno {
+						logMu.Lock()
+						for i, inf := range info {
+							if inf.Errno != -1 {
+								fmt.Printf("call #%v: errno %v, duration %v\n", i, inf.Errno, inf.Duration)
+							}
+						}
+						logMu.Unlock()
+					}
 					if *flagCoverFile != "" {
 						// Coverage is dumped in sanitizer format.
 						// github.com/google/sanitizers/tools/sancov command can be used to dump PCs,
@@ -193,4 +239,17 @@ func main() {
 
 	osutil.HandleInterrupts(shutdown)
 	wg.Wait()
+	if *flagLeak {
+		scanLeaks()
+	}
+}
+
+func scanLeaks() {
+	leaks, err := kmemleak.Scan(true)
+	if err != nil {
+		Fatalf("failed to scan for leaks: %v", err)
+	}
+	if len(leaks) != 0 {
+		fmt.Printf("%v:\n%s\n", kmemleak.ReportHeader, leaks)
+	}
 }
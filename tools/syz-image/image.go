@@ -0,0 +1,537 @@
+// Copyright 2018 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-image builds a minimal Linux disk image suitable for syzkaller VMs.
+// The base root filesystem is created either with debootstrap (Debian, requires root)
+// or with a Buildroot source tree (does not require root). Then the image is customized
+// with debugfs (from e2fsprogs) without mounting it: root ssh access with a freshly
+// generated key, serial console, network, debugfs mount, sysctls and the device nodes
+// that syzkaller needs early in boot are set up.
+// Image creation is reproducible: file system UUID and hash seed are fixed, timestamps
+// are fixed with -timestamp (or SOURCE_DATE_EPOCH), and Buildroot is built with BR2_REPRODUCIBLE. For Debian the set of
+// packages is pinned if -mirror points to a snapshot (e.g. snapshot.debian.org).
+//
+// If -config is given, target arch and output image/sshkey paths are taken from the manager config:
+//
+//	syz-image -config my.cfg
+//	syz-image -distro buildroot -buildroot ~/buildroot -arch arm64 -image arm64.img
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/pkg/config"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/syz-manager/mgrconfig"
+)
+
+var (
+	flagConfig    = flag.String("config", "", "manager config to take arch and image/sshkey paths from")
+	flagDistro    = flag.String("distro", "debian", "base distribution (debian, buildroot)")
+	flagArch      = flag.String("arch", runtime.GOARCH, "target arch")
+	flagRelease   = flag.String("release", "stretch", "debian release")
+	flagMirror    = flag.String("mirror", "http://deb.debian.org/debian", "debian mirror")
+	flagBuildroot = flag.String("buildroot", "", "buildroot source tree (required for buildroot)")
+	flagPackages  = flag.String("packages", "", "comma-separated additional packages (debian packages or buildroot BR2_PACKAGE_* names)")
+	flagSize      = flag.Int("size", 2048, "image size in MB")
+	flagImage     = flag.String("image", "", "output image file (default: DISTRO.img)")
+	flagSshkey    = flag.String("sshkey", "", "output ssh private key file (default: IMAGE.id_rsa)")
+	flagTimestamp = flag.Int64("timestamp", 0, "timestamp of files created in the image (default: SOURCE_DATE_EPOCH or current time)")
+	flagDebug     = flag.Bool("debug", false, "print commands and their output")
+)
+
+type archInfo struct {
+	debian    string   // debootstrap arch
+	qemu      string   // qemu-user binary suffix to run foreign debootstrap second stage
+	buildroot []string // buildroot config lines that select the arch
+	console   string   // serial console device used by qemu
+}
+
+var archs = map[string]archInfo{
+	"amd64":   {"amd64", "x86_64", []string{"BR2_x86_64=y"}, "ttyS0"},
+	"386":     {"i386", "i386", []string{"BR2_i386=y", "BR2_x86_pentiumpro=y"}, "ttyS0"},
+	"arm64":   {"arm64", "aarch64", []string{"BR2_aarch64=y"}, "ttyAMA0"},
+	"arm":     {"armhf", "arm", []string{"BR2_arm=y", "BR2_cortex_a15=y", "BR2_ARM_FPU_VFPV4=y"}, "ttyAMA0"},
+	"ppc64le": {"ppc64el", "ppc64le", []string{"BR2_powerpc64le=y", "BR2_powerpc_power8=y"}, "hvc0"},
+}
+
+// debianPackages are installed in addition to the debootstrap base system.
+var debianPackages = []string{"openssh-server", "curl", "tar", "gcc", "libc6-dev", "time", "strace",
+	"sudo", "less", "psmisc"}
+
+// sysctls are applied on boot. They make kernel output more useful for crash reports
+// and enable features that syzkaller tests.
+var sysctls = []string{
+	"kernel.printk = 7 4 1 3",
+	"debug.exception-trace = 0",
+	"net.core.bpf_jit_enable = 1",
+	"net.core.bpf_jit_harden = 2",
+	"net.ipv4.ping_group_range = 0 65535",
+}
+
+// imageDevice is a device node created in the image, so that it's present
+// before devtmpfs is mounted (or if the kernel does not have it).
+type imageDevice struct {
+	path         string
+	char         bool
+	major, minor int
+	perm         int
+}
+
+var devices = []imageDevice{
+	{"/dev/console", true, 5, 1, 0600},
+	{"/dev/null", true, 1, 3, 0666},
+	{"/dev/zero", true, 1, 5, 0666},
+	{"/dev/tty", true, 5, 0, 0666},
+	{"/dev/urandom", true, 1, 9, 0666},
+	{"/dev/kvm", true, 10, 232, 0666},
+	{"/dev/net/tun", true, 10, 200, 0666},
+	{"/dev/fuse", true, 10, 229, 0666},
+	{"/dev/loop-control", true, 10, 237, 0660},
+}
+
+// imageFile is a file written into the image. If edit is set, the file contents are
+// the result of edit applied to the existing contents (empty if the file does not exist).
+type imageFile struct {
+	path string
+	perm int
+	data []byte
+	edit func(old []byte) []byte
+}
+
+func main() {
+	flag.Parse()
+	arch, image, sshkey := *flagArch, *flagImage, *flagSshkey
+	if *flagConfig != "" {
+		cfg := mgrconfig.DefaultValues()
+		if err := config.LoadFile(*flagConfig, cfg); err != nil {
+			failf("%v", err)
+		}
+		targetOS, vmArch, _, err := mgrconfig.SplitTarget(cfg.Target)
+		if err != nil {
+			failf("%v", err)
+		}
+		if targetOS != "linux" {
+			failf("only linux images are supported, config target is %v", cfg.Target)
+		}
+		arch = vmArch
+		if image == "" && cfg.Image != "9p" {
+			image = cfg.Image
+		}
+		if sshkey == "" {
+			sshkey = cfg.Sshkey
+		}
+	}
+	info, ok := archs[arch]
+	if !ok {
+		failf("unsupported arch %v", arch)
+	}
+	if *flagDistro != "debian" && *flagDistro != "buildroot" {
+		failf("unknown distro %v", *flagDistro)
+	}
+	if image == "" {
+		image = *flagDistro + ".img"
+	}
+	if sshkey == "" {
+		sshkey = image + ".id_rsa"
+	}
+	timestamp := *flagTimestamp
+	if timestamp == 0 {
+		if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+			var err error
+			if timestamp, err = strconv.ParseInt(epoch, 10, 64); err != nil {
+				failf("bad SOURCE_DATE_EPOCH %q: %v", epoch, err)
+			}
+		}
+	}
+	var packages []string
+	if *flagPackages != "" {
+		packages = strings.Split(*flagPackages, ",")
+	}
+	tmpDir, err := ioutil.TempDir("", "syz-image")
+	if err != nil {
+		failf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	b := &builder{
+		arch:      arch,
+		info:      info,
+		image:     image,
+		tmpDir:    tmpDir,
+		size:      *flagSize,
+		packages:  packages,
+		timestamp: timestamp,
+	}
+	pubKey, err := generateKey(sshkey)
+	if err != nil {
+		failf("%v", err)
+	}
+	files := commonFiles(pubKey)
+	switch *flagDistro {
+	case "debian":
+		err = b.buildDebian()
+		files = append(files, debianFiles()...)
+	case "buildroot":
+		err = b.buildBuildroot()
+		files = append(files, buildrootFiles()...)
+	}
+	if err != nil {
+		os.Remove(image)
+		failf("%v", err)
+	}
+	if err := b.customize(files, devices); err != nil {
+		os.Remove(image)
+		failf("failed to customize image: %v", err)
+	}
+	for _, file := range []string{image, sshkey, sshkey + ".pub"} {
+		chownToSudoUser(file)
+	}
+	fmt.Printf("image: %v\nsshkey: %v\n", image, sshkey)
+	if *flagConfig == "" {
+		fmt.Printf("manager config:\n\t\"image\": %q,\n\t\"sshkey\": %q,\n", osutil.Abs(image), osutil.Abs(sshkey))
+	}
+}
+
+type builder struct {
+	arch      string
+	info      archInfo
+	image     string
+	tmpDir    string
+	size      int
+	packages  []string
+	timestamp int64
+}
+
+func (b *builder) buildDebian() error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("debootstrap requires root, run syz-image with sudo or use -distro buildroot")
+	}
+	rootfs := filepath.Join(b.tmpDir, "rootfs")
+	foreign := b.arch != runtime.GOARCH && !(b.arch == "386" && runtime.GOARCH == "amd64")
+	args := []string{
+		"--arch=" + b.info.debian,
+		"--include=" + strings.Join(append(append([]string{}, debianPackages...), b.packages...), ","),
+	}
+	if foreign {
+		args = append(args, "--foreign")
+	}
+	args = append(args, *flagRelease, rootfs, *flagMirror)
+	if err := b.run(time.Hour, "", "debootstrap", args...); err != nil {
+		return err
+	}
+	if foreign {
+		// The second stage runs target binaries, this requires qemu-user-static registered in binfmt_misc.
+		qemu := "qemu-" + b.info.qemu + "-static"
+		qemuPath, err := exec.LookPath(qemu)
+		if err != nil {
+			return fmt.Errorf("foreign arch %v requires %v: %v", b.arch, qemu, err)
+		}
+		rootfsQemu := filepath.Join(rootfs, "usr", "bin", qemu)
+		if err := osutil.CopyFile(qemuPath, rootfsQemu); err != nil {
+			return err
+		}
+		if err := os.Chmod(rootfsQemu, 0755); err != nil {
+			return err
+		}
+		err = b.run(time.Hour, "", "chroot", rootfs, "/debootstrap/debootstrap", "--second-stage")
+		os.Remove(rootfsQemu)
+		if err != nil {
+			return err
+		}
+	}
+	// Don't leave package caches in the image.
+	os.RemoveAll(filepath.Join(rootfs, "var", "cache", "apt", "archives"))
+	return b.mkfs(rootfs)
+}
+
+func (b *builder) mkfs(rootfs string) error {
+	f, err := os.Create(b.image)
+	if err != nil {
+		return err
+	}
+	// Truncate produces a sparse file, so mkfs does not need to write zeros.
+	err = f.Truncate(int64(b.size) << 20)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to truncate image: %v", err)
+	}
+	// Fixed UUID and hash seed make the image reproducible, they are derived from the output path
+	// so that several images attached to the same VM are still distinguishable.
+	uuid := b.uuid()
+	return b.run(time.Hour, "", "mkfs.ext4", "-F", "-q", "-L", "syzkaller", "-U", uuid,
+		"-E", "hash_seed="+uuid+",root_owner=0:0", "-d", rootfs, b.image)
+}
+
+func (b *builder) uuid() string {
+	h := sha256.Sum256([]byte(filepath.Base(b.image)))
+	h[6] = h[6]&0x0f | 0x40
+	h[8] = h[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+func (b *builder) buildBuildroot() error {
+	if *flagBuildroot == "" {
+		return fmt.Errorf("-buildroot is required for buildroot distro")
+	}
+	cfg := append([]string{}, b.info.buildroot...)
+	cfg = append(cfg,
+		"BR2_TOOLCHAIN_BUILDROOT_GLIBC=y",
+		"BR2_REPRODUCIBLE=y",
+		`BR2_TARGET_GENERIC_HOSTNAME="syzkaller"`,
+		`BR2_TARGET_GENERIC_ISSUE="syzkaller"`,
+		fmt.Sprintf("BR2_TARGET_GENERIC_GETTY_PORT=%q", b.info.console),
+		`BR2_SYSTEM_DHCP="eth0"`,
+		"BR2_PACKAGE_OPENSSH=y",
+		"BR2_PACKAGE_STRACE=y",
+		"BR2_TARGET_ROOTFS_EXT2=y",
+		"BR2_TARGET_ROOTFS_EXT2_4=y",
+		fmt.Sprintf("BR2_TARGET_ROOTFS_EXT2_SIZE=\"%vM\"", b.size),
+		"# BR2_TARGET_ROOTFS_TAR is not set",
+	)
+	for _, pkg := range b.packages {
+		cfg = append(cfg, "BR2_PACKAGE_"+strings.ToUpper(strings.Replace(pkg, "-", "_", -1))+"=y")
+	}
+	defconfig := filepath.Join(b.tmpDir, "defconfig")
+	if err := osutil.WriteFile(defconfig, []byte(strings.Join(cfg, "\n")+"\n")); err != nil {
+		return err
+	}
+	out := filepath.Join(b.tmpDir, "buildroot")
+	if err := b.run(time.Minute, *flagBuildroot, "make", "O="+out, "BR2_DEFCONFIG="+defconfig, "defconfig"); err != nil {
+		return err
+	}
+	if err := b.run(10*time.Hour, *flagBuildroot, "make", "O="+out); err != nil {
+		return err
+	}
+	return osutil.CopyFile(filepath.Join(out, "images", "rootfs.ext4"), b.image)
+}
+
+func commonFiles(pubKey []byte) []imageFile {
+	return []imageFile{
+		{path: "/etc/hostname", perm: 0644, data: []byte("syzkaller\n")},
+		{path: "/etc/hosts", perm: 0644, data: []byte("127.0.0.1\tlocalhost\n")},
+		{path: "/etc/sysctl.d/99-syzkaller.conf", perm: 0644, data: []byte(strings.Join(sysctls, "\n") + "\n")},
+		{path: "/root/.ssh/authorized_keys", perm: 0600, data: pubKey},
+	}
+}
+
+func debianFiles() []imageFile {
+	return []imageFile{
+		{path: "/etc/passwd", perm: 0644, edit: func(old []byte) []byte {
+			// Allow passwordless root login on the console.
+			return bytes.Replace(old, []byte("root:x:"), []byte("root::"), 1)
+		}},
+		{path: "/etc/fstab", perm: 0644, edit: appendData("debugfs /sys/kernel/debug debugfs defaults 0 0\n")},
+		{path: "/etc/network/interfaces", perm: 0644, edit: appendData("\nauto eth0\niface eth0 inet dhcp\n")},
+		{path: "/etc/resolv.conf", perm: 0644, data: []byte("nameserver 8.8.8.8\n")},
+		// Empty rule overrides the udev rule that renames network interfaces,
+		// so that the interface is eth0 regardless of net.ifnames in kernel cmdline.
+		{path: "/etc/udev/rules.d/80-net-setup-link.rules", perm: 0644, data: []byte{}},
+	}
+}
+
+func buildrootFiles() []imageFile {
+	// Busybox init neither mounts debugfs nor applies sysctl.d.
+	return []imageFile{
+		{path: "/etc/init.d/S01syzkaller", perm: 0755, data: []byte("#!/bin/sh\n" +
+			"mount -t debugfs none /sys/kernel/debug\n" +
+			"sysctl -q -p /etc/sysctl.d/99-syzkaller.conf\n")},
+	}
+}
+
+func appendData(data string) func([]byte) []byte {
+	return func(old []byte) []byte {
+		if bytes.Contains(old, []byte(data)) {
+			return old
+		}
+		return append(append([]byte{}, old...), data...)
+	}
+}
+
+// customize writes files and device nodes into the image with debugfs, so that it does not
+// need to be mounted (which requires root) and works the same way for all distros.
+// debugfs creates entries only in the current dir, so every entry is created after cd to its parent.
+func (b *builder) customize(files []imageFile, devices []imageDevice) error {
+	hostDir := filepath.Join(b.tmpDir, "files")
+	if err := osutil.MkdirAll(hostDir); err != nil {
+		return err
+	}
+	script := new(bytes.Buffer)
+	dirs := make(map[string]bool)
+	var mkdir func(dir string)
+	mkdir = func(dir string) {
+		if dir == "/" || dirs[dir] {
+			return
+		}
+		dirs[dir] = true
+		parent, name := filepath.Split(dir)
+		mkdir(filepath.Clean(parent))
+		fmt.Fprintf(script, "cd %v\nmkdir %v\n", filepath.Clean(parent), name)
+	}
+	for i, file := range files {
+		data := file.data
+		if file.edit != nil {
+			old, err := b.readFile(file.path)
+			if err != nil {
+				return err
+			}
+			data = file.edit(old)
+		}
+		hostFile := filepath.Join(hostDir, fmt.Sprint(i))
+		if err := osutil.WriteFile(hostFile, data); err != nil {
+			return err
+		}
+		files[i].data = data
+		dir, name := filepath.Split(file.path)
+		dir = filepath.Clean(dir)
+		mkdir(dir)
+		fmt.Fprintf(script, "cd %v\nrm %v\nwrite %v %v\n", dir, name, hostFile, name)
+		fmt.Fprintf(script, "sif %v mode 0%o\nsif %v uid 0\nsif %v gid 0\n", file.path, 0100000|file.perm, file.path, file.path)
+	}
+	for _, dev := range devices {
+		dir, name := filepath.Split(dev.path)
+		dir = filepath.Clean(dir)
+		mkdir(dir)
+		typ, mode := "b", 0060000
+		if dev.char {
+			typ, mode = "c", 0020000
+		}
+		fmt.Fprintf(script, "cd %v\nrm %v\nmknod %v %v %v %v\n", dir, name, name, typ, dev.major, dev.minor)
+		fmt.Fprintf(script, "sif %v mode 0%o\n", dev.path, mode|dev.perm)
+	}
+	// The ssh dir must not be accessible by others, otherwise sshd ignores authorized_keys.
+	fmt.Fprintf(script, "sif /root/.ssh mode 040700\n")
+	if err := b.debugfs(script.String(), true); err != nil {
+		return err
+	}
+	// debugfs does not fail on errors in commands, so check that the files were written.
+	for _, file := range files {
+		data, err := b.readFile(file.path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(data, file.data) {
+			return fmt.Errorf("failed to write %v into the image", file.path)
+		}
+	}
+	return nil
+}
+
+// readFile returns contents of the file in the image, or nil if the file does not exist.
+func (b *builder) readFile(path string) ([]byte, error) {
+	hostFile := filepath.Join(b.tmpDir, "dump")
+	os.Remove(hostFile)
+	if err := b.debugfs(fmt.Sprintf("dump %v %v\n", path, hostFile), false); err != nil {
+		return nil, err
+	}
+	if !osutil.IsExist(hostFile) {
+		return nil, nil
+	}
+	return ioutil.ReadFile(hostFile)
+}
+
+func (b *builder) debugfs(script string, write bool) error {
+	scriptFile := filepath.Join(b.tmpDir, "debugfs.script")
+	if err := osutil.WriteFile(scriptFile, []byte(script)); err != nil {
+		return err
+	}
+	args := []string{"-f", scriptFile, b.image}
+	if write {
+		args = append([]string{"-w"}, args...)
+	}
+	return b.run(time.Minute, "", "debugfs", args...)
+}
+
+func (b *builder) run(timeout time.Duration, dir, bin string, args ...string) error {
+	if *flagDebug {
+		fmt.Printf("running %v %v\n", bin, strings.Join(args, " "))
+	}
+	// Fixed time makes timestamps of inodes created by mkfs and debugfs reproducible.
+	env := os.Environ()
+	if b.timestamp != 0 {
+		env = append(env,
+			fmt.Sprintf("E2FSPROGS_FAKE_TIME=%v", b.timestamp),
+			fmt.Sprintf("SOURCE_DATE_EPOCH=%v", b.timestamp))
+	}
+	output, err := osutil.RunCmdEnv(timeout, env, dir, bin, args...)
+	if *flagDebug {
+		os.Stdout.Write(output)
+	}
+	return err
+}
+
+// generateKey generates a new RSA key, writes the private key into file (and the public key
+// into file.pub) and returns the public key in authorized_keys format.
+func generateKey(file string) ([]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ssh key: %v", err)
+	}
+	priv := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	if err := osutil.MkdirAll(filepath.Dir(file)); err != nil {
+		return nil, err
+	}
+	// ssh refuses to use private keys that are accessible by others.
+	if err := ioutil.WriteFile(file, priv, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(file, 0600); err != nil {
+		return nil, err
+	}
+	// Public key in the SSH wire format (RFC 4253, section 6.6).
+	wire := new(bytes.Buffer)
+	writeString := func(data []byte) {
+		binary.Write(wire, binary.BigEndian, uint32(len(data)))
+		wire.Write(data)
+	}
+	writeMpint := func(v *big.Int) {
+		data := v.Bytes()
+		if len(data) != 0 && data[0]&0x80 != 0 {
+			data = append([]byte{0}, data...)
+		}
+		writeString(data)
+	}
+	writeString([]byte("ssh-rsa"))
+	writeMpint(big.NewInt(int64(key.E)))
+	writeMpint(key.N)
+	pub := []byte("ssh-rsa " + base64.StdEncoding.EncodeToString(wire.Bytes()) + " syzkaller\n")
+	if err := osutil.WriteFile(file+".pub", pub); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
+// chownToSudoUser gives the output files back to the user if syz-image runs under sudo.
+func chownToSudoUser(file string) {
+	uid, err1 := strconv.Atoi(os.Getenv("SUDO_UID"))
+	gid, err2 := strconv.Atoi(os.Getenv("SUDO_GID"))
+	if err1 != nil || err2 != nil || os.Geteuid() != 0 {
+		return
+	}
+	os.Chown(file, uid, gid)
+}
+
+func failf(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg+"\n", args...)
+	os.Exit(1)
+}
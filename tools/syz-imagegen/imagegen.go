@@ -0,0 +1,293 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-imagegen generates seed filesystem images for syz_mount_image pseudo-syscall.
+// For every supported filesystem it runs the corresponding mkfs utility
+// with a number of different parameter combinations, splits the resulting
+// image into non-zero segments and writes a seed program for each image
+// into the output dir. The seed programs can be added to the corpus with:
+//	syz-db pack dir corpus.db
+// The generated call has the following layout:
+//	syz_mount_image(fs ptr[in, string[image_filesystem]], dir ptr[in, filename], size intptr,
+//		nsegs len[segments], segments ptr[in, array[fs_image_segment]],
+//		flags flags[mount_flags], opts ptr[in, string, opt])
+//	fs_image_segment {
+//		data	ptr[in, array[int8]]
+//		size	len[data, intptr]
+//		offset	intptr
+//	}
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+)
+
+var (
+	flagOutput = flag.String("output", "", "output dir for seed programs (required)")
+	flagFS     = flag.String("fs", "", "comma-separated list of filesystems to generate (all by default)")
+	flagKeep   = flag.Bool("keep", false, "keep raw images next to seed programs")
+)
+
+type FileSystem struct {
+	Name    string
+	MinSize int        // minimal image size accepted by mkfs
+	MkfsCmd string     // mkfs binary
+	Args    []string   // args that are always passed to mkfs (image file name is appended)
+	Params  [][]string // alternative parameter sets, each one produces a separate image
+	Opts    []string   // mount options that are used with images of this filesystem
+}
+
+var fileSystems = []FileSystem{
+	{
+		Name:    "ext4",
+		MinSize: 1 << 20,
+		MkfsCmd: "mkfs.ext4",
+		Args:    []string{"-F", "-q"},
+		Params: [][]string{
+			{},
+			{"-b", "1024", "-O", "^has_journal"},
+			{"-b", "2048", "-O", "inline_data,^has_journal"},
+			{"-O", "encrypt,quota"},
+			{"-O", "bigalloc,extent", "-C", "16384"},
+			{"-O", "^extent,^64bit", "-t", "ext3"},
+			{"-t", "ext2"},
+		},
+		Opts: []string{"errors=continue", "noload", "data=journal", "nodelalloc"},
+	},
+	{
+		Name:    "vfat",
+		MinSize: 64 << 10,
+		MkfsCmd: "mkfs.vfat",
+		Params: [][]string{
+			{"-F", "12"},
+			{"-F", "16"},
+			{"-F", "32", "-s", "1"},
+		},
+		Opts: []string{"uni_xlate=1", "shortname=mixed", "utf8=1"},
+	},
+	{
+		Name:    "btrfs",
+		MinSize: 16 << 20,
+		MkfsCmd: "mkfs.btrfs",
+		Args:    []string{"-f", "-q"},
+		Params: [][]string{
+			{},
+			{"-M"},
+			{"-O", "no-holes,skinny-metadata"},
+		},
+		Opts: []string{"nodatacow", "compress=lzo", "space_cache=v2"},
+	},
+	{
+		Name:    "xfs",
+		MinSize: 16 << 20,
+		MkfsCmd: "mkfs.xfs",
+		Args:    []string{"-f", "-q"},
+		Params: [][]string{
+			{},
+			{"-b", "size=1024"},
+			{"-m", "crc=0", "-i", "attr=1"},
+		},
+		Opts: []string{"nouuid", "noquota", "inode32"},
+	},
+	{
+		Name:    "msdos",
+		MinSize: 64 << 10,
+		MkfsCmd: "mkfs.msdos",
+		Params: [][]string{
+			{},
+		},
+		Opts: []string{"check=strict"},
+	},
+}
+
+// Segment is a non-zero part of the image.
+type Segment struct {
+	Offset int
+	Data   []byte
+}
+
+func main() {
+	flag.Parse()
+	if *flagOutput == "" {
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	if err := osutil.MkdirAll(*flagOutput); err != nil {
+		failf("failed to create output dir: %v", err)
+	}
+	target, err := prog.GetTarget("linux", runtime.GOARCH)
+	if err != nil {
+		failf("%v", err)
+	}
+	enabled := make(map[string]bool)
+	for _, fs := range strings.Split(*flagFS, ",") {
+		if fs != "" {
+			enabled[fs] = true
+		}
+	}
+	known := make(map[string]bool)
+	generated, failed := 0, 0
+	for _, fs := range fileSystems {
+		known[fs.Name] = true
+		if len(enabled) != 0 && !enabled[fs.Name] {
+			continue
+		}
+		if _, err := exec.LookPath(fs.MkfsCmd); err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %v: %v is not installed\n", fs.Name, fs.MkfsCmd)
+			continue
+		}
+		for i, params := range fs.Params {
+			if err := generateImage(target, fs, i, params); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				failed++
+				continue
+			}
+			generated++
+		}
+	}
+	for fs := range enabled {
+		if !known[fs] {
+			failf("unknown filesystem %v", fs)
+		}
+	}
+	fmt.Printf("generated %v images (%v failed)\n", generated, failed)
+}
+
+func generateImage(target *prog.Target, fs FileSystem, variant int, params []string) error {
+	f, err := ioutil.TempFile("", "syz-imagegen")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	image := f.Name()
+	defer os.Remove(image)
+	// Truncate produces a sparse file, so mkfs does not need to write zeros.
+	err = f.Truncate(int64(fs.MinSize))
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to truncate image: %v", err)
+	}
+	args := append(append(append([]string{}, fs.Args...), params...), image)
+	if _, err := osutil.RunCmd(time.Minute, "", fs.MkfsCmd, args...); err != nil {
+		return fmt.Errorf("%v %v failed: %v", fs.Name, params, err)
+	}
+	data, err := ioutil.ReadFile(image)
+	if err != nil {
+		return fmt.Errorf("failed to read image: %v", err)
+	}
+	name := fmt.Sprintf("%v_%v", fs.Name, variant)
+	if *flagKeep {
+		if err := osutil.WriteFile(filepath.Join(*flagOutput, name+".img"), data); err != nil {
+			return err
+		}
+	}
+	opts := fs.Opts[variant%len(fs.Opts)]
+	seed := serializeProg(fs.Name, len(data), splitImage(data), opts)
+	// Don't write seeds that would be rejected when the corpus is loaded
+	// (e.g. images with too much non-zero data exceed max program line length).
+	if _, err := target.Deserialize(seed); err != nil {
+		return fmt.Errorf("%v %v: bad seed program: %v", fs.Name, params, err)
+	}
+	// Name seed programs the same way as syz-db unpack does, so that they can be packed as is.
+	fname := filepath.Join(*flagOutput, hash.String(seed))
+	if err := osutil.WriteFile(fname, seed); err != nil {
+		return err
+	}
+	fmt.Printf("%v: %v %v -> %v\n", name, fs.MkfsCmd, strings.Join(params, " "), fname)
+	return nil
+}
+
+// splitImage splits image into non-zero segments.
+// Zero gaps shorter than segmentGap are included into segments
+// to not produce lots of tiny segments.
+func splitImage(data []byte) []Segment {
+	const (
+		granularity = 64
+		segmentGap  = 4 * granularity
+	)
+	var segs []Segment
+	start, end := -1, -1
+	for off := 0; off < len(data); off += granularity {
+		chunk := data[off:min(off+granularity, len(data))]
+		if isZero(chunk) {
+			continue
+		}
+		if start != -1 && off-end >= segmentGap {
+			segs = append(segs, Segment{start, data[start:end]})
+			start = -1
+		}
+		if start == -1 {
+			start = off
+		}
+		end = off + len(chunk)
+	}
+	if start != -1 {
+		segs = append(segs, Segment{start, data[start:end]})
+	}
+	return segs
+}
+
+// serializeProg returns syz_mount_image call that mounts the image in the program
+// text format. Addresses are emitted in the canonical page+offset form
+// that is accepted by prog.Deserialize.
+func serializeProg(fs string, size int, segs []Segment, opts string) []byte {
+	const (
+		dataStart  = 0x7f0000000000
+		segStart   = 0x7f0000010000
+		imageStart = 0x7f0000100000
+	)
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "syz_mount_image(&(0x%x)=\"%v\", &(0x%x+0x100)=\"%v\", 0x%x, 0x%x, &(0x%x)=[",
+		dataStart, hexString(fs), dataStart, hexString("./file0"),
+		size, len(segs), segStart)
+	addr := imageStart
+	for i, seg := range segs {
+		if i != 0 {
+			fmt.Fprintf(buf, ", ")
+		}
+		fmt.Fprintf(buf, "{&(0x%x)=\"%v\", 0x%x, 0x%x}",
+			addr, hex.EncodeToString(seg.Data), len(seg.Data), seg.Offset)
+		addr += (len(seg.Data) + 0xfff) &^ 0xfff
+	}
+	fmt.Fprintf(buf, "], 0x0, &(0x%x+0x200)=\"%v\")\n", dataStart, hexString(opts))
+	return buf.Bytes()
+}
+
+func hexString(s string) string {
+	return hex.EncodeToString(append([]byte(s), 0))
+}
+
+func isZero(data []byte) bool {
+	for _, v := range data {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func failf(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+)
+
+func TestSerializeProg(t *testing.T) {
+	target, err := prog.GetTarget("linux", runtime.GOARCH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 64<<10)
+	for i := 0; i < 1000; i++ {
+		data[i] = byte(i)
+	}
+	for i := 10000; i < 20000; i++ {
+		data[i] = 0xff
+	}
+	data[len(data)-1] = 1
+	segs := splitImage(data)
+	if len(segs) != 3 {
+		t.Fatalf("got %v segments, want 3", len(segs))
+	}
+	seed := serializeProg("vfat", len(data), segs, "utf8=1")
+	p, err := target.Deserialize(seed)
+	if err != nil {
+		t.Fatalf("failed to deserialize seed: %v\n%s", err, seed)
+	}
+	if len(p.Calls) != 1 || p.Calls[0].Meta.Name != "syz_mount_image" {
+		t.Fatalf("bad seed program:\n%s", seed)
+	}
+}
+
+func TestGenerateImage(t *testing.T) {
+	target, err := prog.GetTarget("linux", runtime.GOARCH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "syz-imagegen-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	*flagOutput = dir
+	tested := false
+	for _, fs := range fileSystems {
+		if _, err := exec.LookPath(fs.MkfsCmd); err != nil {
+			continue
+		}
+		tested = true
+		for i, params := range fs.Params {
+			if err := generateImage(target, fs, i, params); err != nil {
+				t.Errorf("%v", err)
+			}
+		}
+	}
+	if !tested {
+		t.Skip("no mkfs utilities installed")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := target.Deserialize(data); err != nil {
+			t.Errorf("failed to deserialize %v: %v", file, err)
+		}
+	}
+}
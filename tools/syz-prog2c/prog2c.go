@@ -8,34 +8,70 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/google/syzkaller/pkg/csource"
+	"github.com/google/syzkaller/pkg/gosource"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/pkg/pysource"
 	"github.com/google/syzkaller/prog"
 	_ "github.com/google/syzkaller/sys"
 )
 
 var (
-	flagOS         = flag.String("os", runtime.GOOS, "target os")
-	flagArch       = flag.String("arch", runtime.GOARCH, "target arch")
-	flagThreaded   = flag.Bool("threaded", false, "create threaded program")
-	flagCollide    = flag.Bool("collide", false, "create collide program")
-	flagRepeat     = flag.Bool("repeat", false, "repeat program infinitely or not")
-	flagProcs      = flag.Int("procs", 1, "number of parallel processes")
-	flagSandbox    = flag.String("sandbox", "", "sandbox to use (none, setuid, namespace)")
-	flagProg       = flag.String("prog", "", "file with program to convert (required)")
-	flagFaultCall  = flag.Int("fault_call", -1, "inject fault into this call (0-based)")
-	flagFaultNth   = flag.Int("fault_nth", 0, "inject fault on n-th operation (0-based)")
-	flagEnableTun  = flag.Bool("tun", false, "set up TUN/TAP interface")
-	flagUseTmpDir  = flag.Bool("tmpdir", false, "create a temporary dir and execute inside it")
-	flagHandleSegv = flag.Bool("segv", false, "catch and ignore SIGSEGV")
-	flagWaitRepeat = flag.Bool("waitrepeat", false, "wait for each repeat attempt")
-	flagDebug      = flag.Bool("debug", false, "generate debug printfs")
+	flagOS          = flag.String("os", runtime.GOOS, "target os")
+	flagArch        = flag.String("arch", runtime.GOARCH, "target arch")
+	flagThreaded    = flag.Bool("threaded", false, "create threaded program")
+	flagCollide     = flag.Bool("collide", false, "create collide program")
+	flagRepeat      = flag.Bool("repeat", false, "repeat program infinitely or not")
+	flagRepeatTimes = flag.Int("repeat_times", 0, "number of repeat iterations (0 - infinitely)")
+	flagProcs       = flag.Int("procs", 1, "number of parallel processes")
+	flagSandbox     = flag.String("sandbox", "", "sandbox to use (none, setuid, namespace, seccomp)")
+	flagSeccompDeny = flag.String("seccomp_deny", "", "comma-separated syscalls denied in seccomp sandbox")
+	flagProg        = flag.String("prog", "", "file with program or C source with embedded program to convert (required)")
+	flagFaultCall   = flag.Int("fault_call", -1, "inject fault into this call (0-based)")
+	flagFaultNth    = flag.Int("fault_nth", 0, "inject fault on n-th operation (0-based)")
+	flagFaults      = flag.String("faults", "", "additional fault points as comma-separated call:nth pairs")
+	flagEnableTun   = flag.Bool("tun", false, "set up TUN/TAP interface")
+	flagEnableUSB   = flag.Bool("usb", false, "emulate USB devices for syz_usb_* calls")
+	flagCgroups     = flag.Bool("cgroups", false, "create and enter dedicated cgroups (requires namespace sandbox)")
+	flagSandboxUID  = flag.Int("sandbox_uid", 0, "uid of test processes inside of the namespace sandbox")
+	flagSandboxGID  = flag.Int("sandbox_gid", 0, "gid of test processes inside of the namespace sandbox")
+	flagAffinity    = flag.Bool("affinity", false, "bind procs and threads to CPUs round-robin")
+	flagCPUs        = flag.String("cpus", "", "comma-separated CPUs to bind to with -affinity (default: all online CPUs)")
+	flagCoverage    = flag.Bool("coverage", false, "collect KCOV coverage and write covered PCs to "+csource.CoverFile)
+	flagWatchdog    = flag.Bool("watchdog", false, fmt.Sprintf("monitor kernel log and exit with %v on crashes and %v on hangs",
+		csource.WatchdogCrashStatus, csource.WatchdogHangStatus))
+	flagExecutorEnv = flag.Bool("executor_env", false, "apply resource limits of executor test processes")
+	flagUseTmpDir   = flag.Bool("tmpdir", false, "create a temporary dir and execute inside it")
+	flagHandleSegv  = flag.Bool("segv", false, "catch and ignore SIGSEGV")
+	flagWaitRepeat  = flag.Bool("waitrepeat", false, "wait for each repeat attempt")
+	flagCallTimeout = flag.Duration("call_timeout", 0, "how long to wait for each call in threaded mode (default 20ms)")
+	flagProgTimeout = flag.Duration("program_timeout", 0, "kill each waitrepeat attempt after this time (default 5s)")
+	flagRepeatTime  = flag.Duration("repeat_timeout", 0, "stop repeating, clean up and exit after this time (requires waitrepeat)")
+	flagDebug       = flag.Bool("debug", false, "generate debug printfs")
+	flagTrace       = flag.Bool("trace", false, "print calls with arguments and results to stderr at runtime")
+	flagMinimal     = flag.Bool("minimal", false, "don't use cpp to preprocess the program")
+	flagForce32Bit  = flag.Bool("force32", false, "generate program for the 32-bit compat ABI (build with -m32)")
+	flagBase64      = flag.Bool("base64", false, "encode large data arguments with base64")
+	flagDataOffset  = flag.Uint64("data_offset", 0, "map the data area at this address and relocate pointers into it (default: target data offset)")
+	flagDataSize    = flag.Uint64("data_size", 0, "size of the data area (default: target data size)")
+	flagEmbedProg   = flag.Bool("embed", false, "embed the program as a comment into the C source")
+	flagFix         = flag.Bool("fix", false, "fix broken resource references and renamed syscalls in hand-edited programs instead of failing")
+	flagKmod        = flag.Bool("kmod", false, "generate Linux kernel module instead of user-space program (supports only repeat flag)")
+	flagGo          = flag.Bool("go", false, "generate Go program instead of C (supports only threaded and repeat flags)")
+	flagPython      = flag.Bool("python", false, "generate Python ctypes program instead of C (supports only repeat flag)")
+	flagOut         = flag.String("out", "", "write C source to this file instead of stdout")
+	flagBuild       = flag.String("build", "", "also write build file (make or android) next to the -out file")
+	flagVariants    = flag.String("variants", "", "write single-threaded, threaded, fault and repeat variants into this dir")
 )
 
 func main() {
 	flag.Parse()
-	if *flagProg == "" {
+	if *flagProg == "" || *flagBuild != "" && *flagOut == "" {
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
@@ -49,26 +85,112 @@ func main() {
 		fmt.Fprintf(os.Stderr, "failed to read prog file: %v\n", err)
 		os.Exit(1)
 	}
-	p, err := target.Deserialize(data)
+	if embedded, ok := csource.ExtractProg(data); ok {
+		// Allow to regenerate C reproducers from reproducers.
+		data = embedded
+	}
+	var p *prog.Prog
+	if *flagFix {
+		var fixes []string
+		p, fixes, err = target.DeserializeFix(data)
+		for _, fix := range fixes {
+			fmt.Fprintf(os.Stderr, "fixed %v\n", fix)
+		}
+	} else {
+		p, err = target.DeserializeStrict(data)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to deserialize the program: %v\n", err)
 		os.Exit(1)
 	}
+	if *flagKmod {
+		src, err := csource.WriteModule(p, csource.ModuleOptions{Repeat: *flagRepeat})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to generate kernel module: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(src)
+		return
+	}
+	if *flagGo {
+		src, err := gosource.Write(p, gosource.Options{
+			Threaded: *flagThreaded,
+			Repeat:   *flagRepeat,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to generate Go source: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(src)
+		return
+	}
+	if *flagPython {
+		src, err := pysource.Write(p, pysource.Options{
+			Repeat: *flagRepeat,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to generate Python source: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(src)
+		return
+	}
+	faults, err := parseFaults(*flagFaults)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	var seccompDeny []string
+	if *flagSeccompDeny != "" {
+		seccompDeny = strings.Split(*flagSeccompDeny, ",")
+	}
 	opts := csource.Options{
-		Threaded:   *flagThreaded,
-		Collide:    *flagCollide,
-		Repeat:     *flagRepeat,
-		Procs:      *flagProcs,
-		Sandbox:    *flagSandbox,
-		Fault:      *flagFaultCall >= 0,
-		FaultCall:  *flagFaultCall,
-		FaultNth:   *flagFaultNth,
-		EnableTun:  *flagEnableTun,
-		UseTmpDir:  *flagUseTmpDir,
-		HandleSegv: *flagHandleSegv,
-		WaitRepeat: *flagWaitRepeat,
-		Debug:      *flagDebug,
-		Repro:      false,
+		Threaded:    *flagThreaded,
+		Collide:     *flagCollide,
+		Repeat:      *flagRepeat,
+		RepeatTimes: *flagRepeatTimes,
+		Procs:       *flagProcs,
+		Sandbox:     *flagSandbox,
+		SeccompDeny: seccompDeny,
+		Fault:       *flagFaultCall >= 0,
+		FaultCall:   *flagFaultCall,
+		FaultNth:    *flagFaultNth,
+		Faults:      faults,
+		EnableTun:   *flagEnableTun,
+		EnableUSB:   *flagEnableUSB,
+		UseTmpDir:   *flagUseTmpDir,
+		HandleSegv:  *flagHandleSegv,
+		WaitRepeat:  *flagWaitRepeat,
+		Debug:       *flagDebug,
+		Trace:       *flagTrace,
+		Repro:       false,
+		Minimal:     *flagMinimal,
+		Force32Bit:  *flagForce32Bit,
+		Base64Data:  *flagBase64,
+		EmbedProg:   *flagEmbedProg,
+	}
+	opts.EnableCgroups = *flagCgroups
+	opts.SandboxUID = *flagSandboxUID
+	opts.SandboxGID = *flagSandboxGID
+	opts.Coverage = *flagCoverage
+	opts.Watchdog = *flagWatchdog
+	opts.MatchExecutorEnv = *flagExecutorEnv
+	opts.Affinity = *flagAffinity
+	if opts.CPUs, err = parseCPUs(*flagCPUs); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	opts.CallTimeout = *flagCallTimeout
+	opts.ProgramTimeout = *flagProgTimeout
+	opts.RepeatTimeout = *flagRepeatTime
+	opts.DataOffset = *flagDataOffset
+	opts.DataSize = *flagDataSize
+	if *flagVariants != "" {
+		if err := writeVariants(p, opts, *flagVariants); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 	src, err := csource.Write(p, opts)
 	if err != nil {
@@ -80,5 +202,93 @@ func main() {
 	} else {
 		src = formatted
 	}
-	os.Stdout.Write(src)
+	if *flagOut == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := osutil.WriteFile(*flagOut, src); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write C source: %v\n", err)
+		os.Exit(1)
+	}
+	if *flagBuild != "" {
+		if err := writeBuildFile(target, *flagBuild, *flagOut); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func writeVariants(p *prog.Prog, opts csource.Options, dir string) error {
+	variants, err := csource.WriteVariants(p, opts)
+	if err != nil {
+		return err
+	}
+	if err := osutil.MkdirAll(dir); err != nil {
+		return err
+	}
+	for _, v := range variants {
+		src := v.Source
+		if formatted, err := csource.Format(src); err == nil {
+			src = formatted
+		}
+		file := filepath.Join(dir, fmt.Sprintf("repro_%v.c", v.Name))
+		if err := osutil.WriteFile(file, src); err != nil {
+			return fmt.Errorf("failed to write C source: %v", err)
+		}
+		fmt.Printf("%v\n", file)
+	}
+	return nil
+}
+
+func writeBuildFile(target *prog.Target, kind, srcFile string) error {
+	if *flagForce32Bit {
+		var err error
+		if target, err = csource.CompatTarget(target); err != nil {
+			return err
+		}
+	}
+	src := filepath.Base(srcFile)
+	bin := strings.TrimSuffix(src, filepath.Ext(src))
+	if bin == src {
+		bin += ".bin"
+	}
+	data, err := csource.WriteBuildFile(target, kind, src, bin, csource.BuildOpts{})
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(filepath.Dir(srcFile), csource.BuildFileName(kind))
+	if err := osutil.WriteFile(file, data); err != nil {
+		return fmt.Errorf("failed to write build file: %v", err)
+	}
+	return nil
+}
+
+func parseFaults(str string) ([]csource.FaultPoint, error) {
+	var faults []csource.FaultPoint
+	if str == "" {
+		return nil, nil
+	}
+	for _, point := range strings.Split(str, ",") {
+		var f csource.FaultPoint
+		if _, err := fmt.Sscanf(point, "%d:%d", &f.Call, &f.Nth); err != nil {
+			return nil, fmt.Errorf("bad fault point %q: %v", point, err)
+		}
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
+
+func parseCPUs(str string) ([]int, error) {
+	var cpus []int
+	if str == "" {
+		return nil, nil
+	}
+	for _, cpu := range strings.Split(str, ",") {
+		v, err := strconv.Atoi(cpu)
+		if err != nil {
+			return nil, fmt.Errorf("bad cpu %q: %v", cpu, err)
+		}
+		cpus = append(cpus, v)
+	}
+	return cpus, nil
 }
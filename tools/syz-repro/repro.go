@@ -41,6 +41,10 @@ func main() {
 	if _, err := prog.GetTarget(cfg.TargetOS, cfg.TargetArch); err != nil {
 		log.Fatalf("%v", err)
 	}
+	if embedded, ok := csource.ExtractProg(data); ok {
+		// C reproducer with embedded program, turn it into a single-program log.
+		data = append([]byte("executing program 0:\n"), embedded...)
+	}
 	env := mgrconfig.CreateVMEnv(cfg, false)
 	vmPool, err := vm.Create(cfg.Type, env)
 	if err != nil {
@@ -71,16 +75,9 @@ func main() {
 		return
 	}
 
-	fmt.Printf("opts: %+v crepro: %v\n\n", res.Opts, res.CRepro)
+	fmt.Printf("opts: %+v crepro: %v reliability: %v/%v\n\n", res.Opts, res.CRepro, res.Successes, res.Runs)
 	fmt.Printf("%s\n", res.Prog.Serialize())
 	if res.CRepro {
-		src, err := csource.Write(res.Prog, res.Opts)
-		if err != nil {
-			log.Fatalf("failed to generate C repro: %v", err)
-		}
-		if formatted, err := csource.Format(src); err == nil {
-			src = formatted
-		}
-		fmt.Printf("%s\n", src)
+		fmt.Printf("%s\n", res.CSource)
 	}
 }
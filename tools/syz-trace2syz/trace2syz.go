@@ -0,0 +1,82 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// syz-trace2syz converts strace logs into syzkaller programs.
+// The programs are either printed or added to a corpus database,
+// which can be then used as syz-manager workdir/corpus.db.
+// The traces should be obtained with: strace -f -s 256 -o trace.txt ./binary
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+
+	"github.com/google/syzkaller/pkg/db"
+	"github.com/google/syzkaller/pkg/hash"
+	"github.com/google/syzkaller/pkg/trace2syz"
+	"github.com/google/syzkaller/prog"
+	_ "github.com/google/syzkaller/sys"
+)
+
+var (
+	flagOS     = flag.String("os", runtime.GOOS, "target os")
+	flagArch   = flag.String("arch", runtime.GOARCH, "target arch")
+	flagCorpus = flag.String("corpus", "", "add programs to this corpus database instead of printing them")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: syz-trace2syz [flags] trace.txt...\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	target, err := prog.GetTarget(*flagOS, *flagArch)
+	if err != nil {
+		failf("%v", err)
+	}
+	var progs []*prog.Prog
+	for _, file := range flag.Args() {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			failf("failed to read trace file: %v", err)
+		}
+		res, err := trace2syz.Parse(target, data)
+		if err != nil {
+			failf("failed to convert %v: %v", file, err)
+		}
+		progs = append(progs, res...)
+	}
+	if *flagCorpus == "" {
+		for _, p := range progs {
+			fmt.Printf("%s\n", p.Serialize())
+		}
+		return
+	}
+	corpus, err := db.Open(*flagCorpus)
+	if err != nil {
+		failf("failed to open corpus database: %v", err)
+	}
+	added := 0
+	for _, p := range progs {
+		data := p.Serialize()
+		sig := hash.String(data)
+		if _, ok := corpus.Records[sig]; ok {
+			continue
+		}
+		corpus.Save(sig, data, 0)
+		added++
+	}
+	if err := corpus.Flush(); err != nil {
+		failf("failed to save corpus database: %v", err)
+	}
+	fmt.Printf("converted %v programs, added %v new programs to %v\n", len(progs), added, *flagCorpus)
+}
+
+func failf(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg+"\n", args...)
+	os.Exit(1)
+}
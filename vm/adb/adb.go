@@ -14,6 +14,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,8 +30,14 @@ func init() {
 }
 
 type Config struct {
-	Adb     string   // adb binary name ("adb" by default)
-	Devices []string // list of adb device IDs to use
+	Adb      string   // adb binary name ("adb" by default)
+	Fastboot string   // fastboot binary name ("fastboot" by default)
+	Devices  []string // list of adb device IDs to use
+
+	// Images to flash with fastboot when a device does not come up,
+	// partition name -> image file (e.g. {"boot": "/path/to/boot.img"}).
+	// If not specified, fastboot is only used to reboot devices stuck in bootloader.
+	Repair_Images map[string]string
 
 	// Ensure that a device battery level is at 20+% before fuzzing.
 	// Sometimes we observe that a device can't charge during heavy fuzzing
@@ -45,16 +53,19 @@ type Pool struct {
 }
 
 type instance struct {
-	adbBin  string
-	device  string
-	console string
-	closed  chan bool
-	debug   bool
+	adbBin       string
+	fastbootBin  string
+	repairImages map[string]string
+	device       string
+	console      string
+	closed       chan bool
+	debug        bool
 }
 
 func ctor(env *vmimpl.Env) (vmimpl.Pool, error) {
 	cfg := &Config{
 		Adb:           "adb",
+		Fastboot:      "fastboot",
 		Battery_Check: true,
 	}
 	if err := config.LoadData(env.Config, cfg); err != nil {
@@ -63,6 +74,19 @@ func ctor(env *vmimpl.Env) (vmimpl.Pool, error) {
 	if _, err := exec.LookPath(cfg.Adb); err != nil {
 		return nil, err
 	}
+	if _, err := exec.LookPath(cfg.Fastboot); err != nil {
+		if len(cfg.Repair_Images) != 0 {
+			return nil, err
+		}
+		Logf(0, "fastboot is not found, recovery of bricked devices is disabled")
+		cfg.Fastboot = ""
+	}
+	for part, image := range cfg.Repair_Images {
+		if !osutil.IsExist(image) {
+			return nil, fmt.Errorf("repair image '%v' for partition %v does not exist", image, part)
+		}
+		cfg.Repair_Images[part] = osutil.Abs(image)
+	}
 	if len(cfg.Devices) == 0 {
 		return nil, fmt.Errorf("no adb devices specified")
 	}
@@ -88,10 +112,12 @@ func (pool *Pool) Count() int {
 
 func (pool *Pool) Create(workdir string, index int) (vmimpl.Instance, error) {
 	inst := &instance{
-		adbBin: pool.cfg.Adb,
-		device: pool.cfg.Devices[index],
-		closed: make(chan bool),
-		debug:  pool.env.Debug,
+		adbBin:       pool.cfg.Adb,
+		fastbootBin:  pool.cfg.Fastboot,
+		repairImages: pool.cfg.Repair_Images,
+		device:       pool.cfg.Devices[index],
+		closed:       make(chan bool),
+		debug:        pool.env.Debug,
 	}
 	closeInst := inst
 	defer func() {
@@ -275,8 +301,13 @@ func (inst *instance) adb(args ...string) ([]byte, error) {
 
 func (inst *instance) repair() error {
 	// Assume that the device is in a bad state initially and reboot it.
-	// Ignore errors, maybe we will manage to reboot it anyway.
-	inst.waitForSsh()
+	if err := inst.waitForSsh(); err != nil {
+		Logf(0, "device %v: %v, trying to recover", inst.device, err)
+		// Ignore errors, maybe we will manage to reboot it anyway.
+		if err := inst.recover(); err != nil {
+			Logf(0, "device %v: %v", inst.device, err)
+		}
+	}
 	// History: adb reboot episodically hangs, so we used a more reliable way:
 	// using syz-executor to issue reboot syscall. However, this has stopped
 	// working, probably due to the introduction of seccomp. Therefore,
@@ -300,6 +331,67 @@ func (inst *instance) repair() error {
 	return nil
 }
 
+// recover tries to bring back a device that does not respond to adb:
+// moves it to bootloader (if it is not there yet), flashes repair images
+// and reboots it with fastboot.
+func (inst *instance) recover() error {
+	if inst.fastbootBin == "" {
+		return fmt.Errorf("fastboot is not available")
+	}
+	if !inst.inFastboot() {
+		// The device may still be visible to adb (e.g. offline or in recovery).
+		inst.adb("reboot", "bootloader")
+		for i := 0; i < 12 && !inst.inFastboot(); i++ {
+			if !vmimpl.SleepInterruptible(5 * time.Second) {
+				return fmt.Errorf("shutdown in progress")
+			}
+		}
+		if !inst.inFastboot() {
+			return fmt.Errorf("device is not in fastboot mode, manual intervention required")
+		}
+	}
+	var parts []string
+	for part := range inst.repairImages {
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	for _, part := range parts {
+		Logf(0, "device %v: flashing %v partition", inst.device, part)
+		if _, err := inst.fastboot(5*time.Minute, "flash", part, inst.repairImages[part]); err != nil {
+			return err
+		}
+	}
+	Logf(0, "device %v: rebooting with fastboot", inst.device)
+	if _, err := inst.fastboot(time.Minute, "reboot"); err != nil {
+		return err
+	}
+	return inst.waitForSsh()
+}
+
+func (inst *instance) inFastboot() bool {
+	out, err := osutil.RunCmd(time.Minute, "", inst.fastbootBin, "devices")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) != 0 && fields[0] == inst.device {
+			return true
+		}
+	}
+	return false
+}
+
+func (inst *instance) fastboot(timeout time.Duration, args ...string) ([]byte, error) {
+	if inst.debug {
+		Logf(0, "executing fastboot %+v", args)
+	}
+	out, err := osutil.RunCmd(timeout, "", inst.fastbootBin, append([]string{"-s", inst.device}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("fastboot %+v failed: %v", args, err)
+	}
+	return out, nil
+}
+
 func (inst *instance) waitForSsh() error {
 	var err error
 	for i := 0; i < 300; i++ {
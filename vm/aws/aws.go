@@ -0,0 +1,416 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package aws allows to use Amazon Elastic Compute Cloud (EC2) virtual machines as VMs.
+// It is assumed that syz-manager also runs on EC2 in the same VPC as VMs
+// and that the aws command line tool is installed and configured.
+//
+// See https://docs.aws.amazon.com/AWSEC2/latest/UserGuide for details.
+// In particular, how to import images:
+// https://docs.aws.amazon.com/vm-import/latest/userguide/vmimport-import-snapshot.html
+// Working with serial console:
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/ec2-serial-console.html
+// Replacing root volumes:
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/replace-root.html
+package aws
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/google/syzkaller/pkg/config"
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/vm/vmimpl"
+)
+
+func init() {
+	vmimpl.Register("aws", ctor)
+}
+
+type Config struct {
+	Count             int    // number of VMs to use
+	Region            string // EC2 region (e.g. "us-west-2")
+	Instance_Type     string // EC2 instance type (e.g. "c5.large")
+	Subnet_ID         string // VPC subnet to create instances in
+	Security_Group_ID string // security group that allows ssh from the manager
+	S3_Path           string // S3 path to upload image (bucket/dir)
+	AMI               string // Pre-created AMI to use
+	// Don't terminate instances on restart, instead restore their root volumes
+	// to the launch state from the AMI snapshot and reboot them.
+	// This is considerably faster than creating new instances.
+	Reuse_Instances bool
+}
+
+type Pool struct {
+	env *vmimpl.Env
+	cfg *Config
+	EC2 *ec2
+}
+
+type instance struct {
+	env       *vmimpl.Env
+	cfg       *Config
+	EC2       *ec2
+	debug     bool
+	name      string
+	id        string
+	ip        string
+	conKey    string // per-instance ssh key used to connect to serial console
+	sshKey    string
+	sshUser   string
+	closed    chan bool
+	terminate bool // terminate the instance on Close
+}
+
+func ctor(env *vmimpl.Env) (vmimpl.Pool, error) {
+	if env.Name == "" {
+		return nil, fmt.Errorf("config param name is empty (required for AWS)")
+	}
+	cfg := &Config{
+		Count: 1,
+	}
+	if err := config.LoadData(env.Config, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse aws vm config: %v", err)
+	}
+	if cfg.Count < 1 || cfg.Count > 1000 {
+		return nil, fmt.Errorf("invalid config param count: %v, want [1, 1000]", cfg.Count)
+	}
+	if env.Debug {
+		cfg.Count = 1
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("region parameter is empty")
+	}
+	if cfg.Instance_Type == "" {
+		return nil, fmt.Errorf("instance_type parameter is empty")
+	}
+	if cfg.AMI == "" && cfg.S3_Path == "" {
+		return nil, fmt.Errorf("s3_path parameter is empty")
+	}
+	if cfg.AMI == "" && env.Image == "" {
+		return nil, fmt.Errorf("config param image is empty (required for AWS)")
+	}
+	if cfg.AMI != "" && env.Image != "" {
+		return nil, fmt.Errorf("both image and ami are specified")
+	}
+	if env.SshKey == "" || env.SshUser == "" {
+		return nil, fmt.Errorf("config params sshkey and ssh_user are required for AWS")
+	}
+	if env.Check {
+		return &Pool{cfg: cfg, env: env}, nil
+	}
+
+	EC2, err := newEC2(cfg.Region, env.Debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init ec2: %v", err)
+	}
+	Logf(0, "EC2 initialized: running on %v, internal IP %v, region %v",
+		EC2.Instance, EC2.InternalIP, cfg.Region)
+
+	if cfg.AMI == "" {
+		s3Image := filepath.Join(cfg.S3_Path, env.Name+"-image.raw")
+		Logf(0, "uploading image to %v...", s3Image)
+		if err := EC2.UploadImage(env.Image, s3Image); err != nil {
+			return nil, err
+		}
+		Logf(0, "creating AMI %v...", env.Name)
+		if err := EC2.DeleteImage(env.Name); err != nil {
+			return nil, fmt.Errorf("failed to delete AMI: %v", err)
+		}
+		cfg.AMI, err = EC2.CreateImage(env.Name, s3Image, env.Arch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AMI: %v", err)
+		}
+	}
+	pool := &Pool{
+		cfg: cfg,
+		env: env,
+		EC2: EC2,
+	}
+	return pool, nil
+}
+
+func (pool *Pool) Count() int {
+	return pool.cfg.Count
+}
+
+func (pool *Pool) Create(workdir string, index int) (vmimpl.Instance, error) {
+	name := fmt.Sprintf("%v-%v", pool.env.Name, index)
+	// Create SSH key for the serial console.
+	conKey := filepath.Join(workdir, "key")
+	keygen := exec.Command("ssh-keygen", "-t", "rsa", "-b", "2048", "-N", "", "-C", "syzkaller", "-f", conKey)
+	if out, err := keygen.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to execute ssh-keygen: %v\n%s", err, out)
+	}
+
+	id, ip, err := pool.EC2.FindInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	if id != "" && pool.cfg.Reuse_Instances {
+		Logf(0, "restoring instance: %v (%v)", name, id)
+		if err := pool.EC2.RestoreInstance(id); err != nil {
+			Logf(0, "failed to restore instance %v: %v", name, err)
+			pool.EC2.TerminateInstance(id, true)
+			id = ""
+		}
+	} else if id != "" {
+		Logf(0, "terminating instance: %v (%v)", name, id)
+		if err := pool.EC2.TerminateInstance(id, true); err != nil {
+			return nil, err
+		}
+		id = ""
+	}
+	if id == "" {
+		Logf(0, "creating instance: %v", name)
+		id, ip, err = pool.EC2.CreateInstance(name, pool.cfg.Instance_Type, pool.cfg.AMI,
+			pool.cfg.Subnet_ID, pool.cfg.Security_Group_ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ok := false
+	defer func() {
+		if !ok {
+			pool.EC2.TerminateInstance(id, false)
+		}
+	}()
+	Logf(0, "wait instance to boot: %v (%v)", name, ip)
+	if err := pool.waitInstanceBoot(ip); err != nil {
+		return nil, err
+	}
+	ok = true
+	inst := &instance{
+		env:       pool.env,
+		cfg:       pool.cfg,
+		EC2:       pool.EC2,
+		debug:     pool.env.Debug,
+		name:      name,
+		id:        id,
+		ip:        ip,
+		conKey:    conKey,
+		sshKey:    pool.env.SshKey,
+		sshUser:   pool.env.SshUser,
+		closed:    make(chan bool),
+		terminate: !pool.cfg.Reuse_Instances,
+	}
+	return inst, nil
+}
+
+func (inst *instance) Close() {
+	close(inst.closed)
+	if inst.terminate {
+		inst.EC2.TerminateInstance(inst.id, false)
+	}
+}
+
+func (inst *instance) Forward(port int) (string, error) {
+	return fmt.Sprintf("%v:%v", inst.EC2.InternalIP, port), nil
+}
+
+func (inst *instance) Copy(hostSrc string) (string, error) {
+	vmDst := "./" + filepath.Base(hostSrc)
+	args := append(sshArgs(inst.debug, inst.sshKey, "-P", 22), hostSrc, inst.sshUser+"@"+inst.ip+":"+vmDst)
+	if _, err := runCmd(inst.debug, "scp", args...); err != nil {
+		return "", err
+	}
+	return vmDst, nil
+}
+
+func (inst *instance) Run(timeout time.Duration, stop <-chan bool, command string) (<-chan []byte, <-chan error, error) {
+	conRpipe, conWpipe, err := osutil.LongPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The pushed key is valid only for 60 seconds, so we need to push it on every connect.
+	conKeyPub, err := ioutil.ReadFile(inst.conKey + ".pub")
+	if err != nil {
+		conRpipe.Close()
+		conWpipe.Close()
+		return nil, nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if err := inst.EC2.SendSerialConsoleKey(inst.id, string(conKeyPub)); err != nil {
+		conRpipe.Close()
+		conWpipe.Close()
+		return nil, nil, err
+	}
+	conAddr := fmt.Sprintf("%v.port0@serial-console.ec2-instance-connect.%v.aws", inst.id, inst.cfg.Region)
+	conArgs := append(sshArgs(inst.debug, inst.conKey, "-p", 22), conAddr)
+	con := exec.Command("ssh", conArgs...)
+	con.Env = []string{}
+	con.Stdout = conWpipe
+	con.Stderr = conWpipe
+	if _, err := con.StdinPipe(); err != nil { // SSH would close connection on stdin EOF
+		conRpipe.Close()
+		conWpipe.Close()
+		return nil, nil, err
+	}
+	if err := con.Start(); err != nil {
+		conRpipe.Close()
+		conWpipe.Close()
+		return nil, nil, fmt.Errorf("failed to connect to console server: %v", err)
+	}
+	conWpipe.Close()
+
+	var tee io.Writer
+	if inst.debug {
+		tee = os.Stdout
+	}
+	merger := vmimpl.NewOutputMerger(tee)
+	merger.Add("console", conRpipe)
+
+	// Similarly to GCE, wait for the console connection to be established
+	// before starting the command, otherwise we can miss beginning of a crash.
+	// Unlike GCE, EC2 serial console does not print anything on connect,
+	// so just give it some time and check that ssh has not failed.
+	{
+		var output []byte
+		timeout := time.NewTimer(10 * time.Second)
+		permissionDeniedMsg := []byte("Permission denied (publickey)")
+	loop:
+		for {
+			select {
+			case out := <-merger.Output:
+				output = append(output, out...)
+				if bytes.Contains(output, permissionDeniedMsg) {
+					break loop
+				}
+			case err := <-merger.Err:
+				con.Process.Kill()
+				merger.Wait()
+				return nil, nil, fmt.Errorf("failed to connect to console: %v\n%s", err, output)
+			case <-timeout.C:
+				break loop
+			}
+		}
+		timeout.Stop()
+		if bytes.Contains(output, permissionDeniedMsg) {
+			con.Process.Kill()
+			merger.Wait()
+			return nil, nil, fmt.Errorf("console permission denied")
+		}
+	}
+
+	sshRpipe, sshWpipe, err := osutil.LongPipe()
+	if err != nil {
+		con.Process.Kill()
+		merger.Wait()
+		return nil, nil, err
+	}
+	if inst.env.OS == "linux" {
+		if inst.sshUser != "root" {
+			command = fmt.Sprintf("sudo bash -c '%v'", command)
+		}
+	}
+	args := append(sshArgs(inst.debug, inst.sshKey, "-p", 22), inst.sshUser+"@"+inst.ip, command)
+	ssh := exec.Command("ssh", args...)
+	ssh.Stdout = sshWpipe
+	ssh.Stderr = sshWpipe
+	if err := ssh.Start(); err != nil {
+		con.Process.Kill()
+		merger.Wait()
+		sshRpipe.Close()
+		sshWpipe.Close()
+		return nil, nil, fmt.Errorf("failed to connect to instance: %v", err)
+	}
+	sshWpipe.Close()
+	merger.Add("ssh", sshRpipe)
+
+	errc := make(chan error, 1)
+	signal := func(err error) {
+		select {
+		case errc <- err:
+		default:
+		}
+	}
+
+	go func() {
+		select {
+		case <-time.After(timeout):
+			signal(vmimpl.TimeoutErr)
+		case <-stop:
+			signal(vmimpl.TimeoutErr)
+		case <-inst.closed:
+			signal(fmt.Errorf("instance closed"))
+		case err := <-merger.Err:
+			con.Process.Kill()
+			ssh.Process.Kill()
+			merger.Wait()
+			con.Wait()
+			if cmdErr := ssh.Wait(); cmdErr == nil {
+				// If the command exited successfully, we got EOF error from merger.
+				// But in this case no error has happened and the EOF is expected.
+				err = nil
+			} else {
+				// Check if the instance was terminated (e.g. spot instance interruption).
+				time.Sleep(5 * time.Second)
+				if !inst.EC2.IsInstanceRunning(inst.id) {
+					Logf(1, "%v: ssh exited but instance is not running", inst.name)
+					err = vmimpl.TimeoutErr
+				}
+			}
+			signal(err)
+			return
+		}
+		con.Process.Kill()
+		ssh.Process.Kill()
+		merger.Wait()
+		con.Wait()
+		ssh.Wait()
+	}()
+	return merger.Output, errc, nil
+}
+
+func (pool *Pool) waitInstanceBoot(ip string) error {
+	pwd := "pwd"
+	if pool.env.OS == "windows" {
+		pwd = "dir"
+	}
+	for i := 0; i < 100; i++ {
+		if !vmimpl.SleepInterruptible(5 * time.Second) {
+			return fmt.Errorf("shutdown in progress")
+		}
+		args := append(sshArgs(pool.env.Debug, pool.env.SshKey, "-p", 22), pool.env.SshUser+"@"+ip, pwd)
+		if _, err := runCmd(pool.env.Debug, "ssh", args...); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("can't ssh into the instance")
+}
+
+func runCmd(debug bool, bin string, args ...string) ([]byte, error) {
+	if debug {
+		Logf(0, "running command: %v %#v", bin, args)
+	}
+	output, err := osutil.RunCmd(time.Minute, "", bin, args...)
+	if debug {
+		Logf(0, "result: %v\n%s", err, output)
+	}
+	return output, err
+}
+
+func sshArgs(debug bool, sshKey, portArg string, port int) []string {
+	args := []string{
+		portArg, fmt.Sprint(port),
+		"-i", sshKey,
+		"-F", "/dev/null",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "BatchMode=yes",
+		"-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "ConnectTimeout=10",
+	}
+	if debug {
+		args = append(args, "-v")
+	}
+	return args
+}
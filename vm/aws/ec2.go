@@ -0,0 +1,361 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+)
+
+// ec2 is a thin wrapper around EC2 functionality of the aws command line tool.
+// We use the tool instead of the Go SDK to not drag the SDK into vendor.
+type ec2 struct {
+	Region     string
+	Instance   string
+	InternalIP string
+	debug      bool
+}
+
+type ec2Instance struct {
+	InstanceId       string
+	PrivateIpAddress string
+	State            struct {
+		Name string
+	}
+}
+
+func newEC2(region string, debug bool) (*ec2, error) {
+	ctx := &ec2{
+		Region: region,
+		debug:  debug,
+	}
+	var err error
+	ctx.Instance, err = getMeta("instance-id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ec2 instance-id: %v", err)
+	}
+	ctx.InternalIP, err = getMeta("local-ipv4")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ec2 local-ipv4: %v", err)
+	}
+	if _, err := ctx.run(nil, "sts", "get-caller-identity"); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// FindInstance returns id and internal IP of a non-terminated instance with the given name,
+// or empty strings if there is no such instance.
+func (ctx *ec2) FindInstance(name string) (string, string, error) {
+	var res struct {
+		Reservations []struct {
+			Instances []ec2Instance
+		}
+	}
+	if _, err := ctx.run(&res, "ec2", "describe-instances",
+		"--filters", "Name=tag:Name,Values="+name,
+		"Name=instance-state-name,Values=pending,running,stopping,stopped"); err != nil {
+		return "", "", err
+	}
+	for _, r := range res.Reservations {
+		for _, inst := range r.Instances {
+			return inst.InstanceId, inst.PrivateIpAddress, nil
+		}
+	}
+	return "", "", nil
+}
+
+// CreateInstance creates a new instance and returns its id and internal IP address.
+func (ctx *ec2) CreateInstance(name, instanceType, ami, subnet, securityGroup string) (string, string, error) {
+	args := []string{"ec2", "run-instances",
+		"--image-id", ami,
+		"--instance-type", instanceType,
+		"--count", "1",
+		"--tag-specifications", fmt.Sprintf("ResourceType=instance,Tags=[{Key=Name,Value=%v}]", name),
+	}
+	if subnet != "" {
+		args = append(args, "--subnet-id", subnet)
+	}
+	if securityGroup != "" {
+		args = append(args, "--security-group-ids", securityGroup)
+	}
+	var res struct {
+		Instances []ec2Instance
+	}
+	if _, err := ctx.run(&res, args...); err != nil {
+		return "", "", fmt.Errorf("failed to create instance: %v", err)
+	}
+	if len(res.Instances) != 1 {
+		return "", "", fmt.Errorf("run-instances returned %v instances", len(res.Instances))
+	}
+	id, ip := res.Instances[0].InstanceId, res.Instances[0].PrivateIpAddress
+	if _, err := ctx.run(nil, "ec2", "wait", "instance-running", "--instance-ids", id); err != nil {
+		ctx.TerminateInstance(id, false)
+		return "", "", fmt.Errorf("instance %v did not start: %v", id, err)
+	}
+	if ip == "" {
+		ctx.TerminateInstance(id, false)
+		return "", "", fmt.Errorf("didn't find instance internal IP address")
+	}
+	return id, ip, nil
+}
+
+// RestoreInstance restores the root volume of the instance to the launch state
+// and reboots it. Instance data that is not on the root volume is preserved.
+func (ctx *ec2) RestoreInstance(id string) error {
+	if !ctx.IsInstanceRunning(id) {
+		if _, err := ctx.run(nil, "ec2", "start-instances", "--instance-ids", id); err != nil {
+			return fmt.Errorf("failed to start instance: %v", err)
+		}
+		if _, err := ctx.run(nil, "ec2", "wait", "instance-running", "--instance-ids", id); err != nil {
+			return fmt.Errorf("instance %v did not start: %v", id, err)
+		}
+	}
+	var res struct {
+		ReplaceRootVolumeTask struct {
+			ReplaceRootVolumeTaskId string
+		}
+	}
+	if _, err := ctx.run(&res, "ec2", "create-replace-root-volume-task", "--instance-id", id); err != nil {
+		return fmt.Errorf("failed to replace root volume: %v", err)
+	}
+	taskID := res.ReplaceRootVolumeTask.ReplaceRootVolumeTaskId
+	for start := time.Now(); time.Since(start) < 10*time.Minute; {
+		time.Sleep(5 * time.Second)
+		var tasks struct {
+			ReplaceRootVolumeTasks []struct {
+				TaskState string
+			}
+		}
+		if _, err := ctx.run(&tasks, "ec2", "describe-replace-root-volume-tasks",
+			"--replace-root-volume-task-ids", taskID); err != nil {
+			return err
+		}
+		if len(tasks.ReplaceRootVolumeTasks) != 1 {
+			return fmt.Errorf("replace root volume task %v is not found", taskID)
+		}
+		switch state := tasks.ReplaceRootVolumeTasks[0].TaskState; state {
+		case "pending", "in-progress":
+			continue
+		case "succeeded":
+			return nil
+		default:
+			return fmt.Errorf("replace root volume task %v: %v", taskID, state)
+		}
+	}
+	return fmt.Errorf("replace root volume task %v has not finished", taskID)
+}
+
+func (ctx *ec2) TerminateInstance(id string, wait bool) error {
+	if _, err := ctx.run(nil, "ec2", "terminate-instances", "--instance-ids", id); err != nil {
+		return fmt.Errorf("failed to terminate instance: %v", err)
+	}
+	if wait {
+		if _, err := ctx.run(nil, "ec2", "wait", "instance-terminated", "--instance-ids", id); err != nil {
+			return fmt.Errorf("instance %v did not terminate: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (ctx *ec2) IsInstanceRunning(id string) bool {
+	var res struct {
+		Reservations []struct {
+			Instances []ec2Instance
+		}
+	}
+	if _, err := ctx.run(&res, "ec2", "describe-instances", "--instance-ids", id); err != nil {
+		return false
+	}
+	for _, r := range res.Reservations {
+		for _, inst := range r.Instances {
+			return inst.State.Name == "running"
+		}
+	}
+	return false
+}
+
+// SendSerialConsoleKey allows the key to be used to connect to the serial console
+// of the instance for the next 60 seconds.
+func (ctx *ec2) SendSerialConsoleKey(id, key string) error {
+	if _, err := ctx.run(nil, "ec2-instance-connect", "send-serial-console-ssh-public-key",
+		"--instance-id", id, "--serial-port", "0", "--ssh-public-key", key); err != nil {
+		return fmt.Errorf("failed to send serial console key: %v", err)
+	}
+	return nil
+}
+
+func (ctx *ec2) UploadImage(localImage, s3Image string) error {
+	if _, err := ctx.runTimeout(time.Hour, nil, "s3", "cp", "--only-show-errors",
+		localImage, "s3://"+s3Image); err != nil {
+		return fmt.Errorf("failed to upload image: %v", err)
+	}
+	return nil
+}
+
+// CreateImage imports raw disk image s3Image as a snapshot and registers an AMI
+// with the snapshot as the root volume. Returns id of the new AMI.
+func (ctx *ec2) CreateImage(imageName, s3Image, arch string) (string, error) {
+	bucket := s3Image
+	key := ""
+	if pos := strings.IndexByte(s3Image, '/'); pos != -1 {
+		bucket, key = s3Image[:pos], s3Image[pos+1:]
+	}
+	container, err := json.Marshal(map[string]interface{}{
+		"Description": imageName,
+		"Format":      "RAW",
+		"UserBucket": map[string]string{
+			"S3Bucket": bucket,
+			"S3Key":    key,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	var task struct {
+		ImportTaskId string
+	}
+	if _, err := ctx.run(&task, "ec2", "import-snapshot", "--disk-container", string(container)); err != nil {
+		return "", fmt.Errorf("failed to import snapshot: %v", err)
+	}
+	snapshot := ""
+	for start := time.Now(); snapshot == ""; {
+		if time.Since(start) > time.Hour {
+			return "", fmt.Errorf("import snapshot task %v has not finished", task.ImportTaskId)
+		}
+		time.Sleep(10 * time.Second)
+		var tasks struct {
+			ImportSnapshotTasks []struct {
+				SnapshotTaskDetail struct {
+					Status        string
+					StatusMessage string
+					SnapshotId    string
+				}
+			}
+		}
+		if _, err := ctx.run(&tasks, "ec2", "describe-import-snapshot-tasks",
+			"--import-task-ids", task.ImportTaskId); err != nil {
+			return "", err
+		}
+		if len(tasks.ImportSnapshotTasks) != 1 {
+			return "", fmt.Errorf("import snapshot task %v is not found", task.ImportTaskId)
+		}
+		detail := tasks.ImportSnapshotTasks[0].SnapshotTaskDetail
+		switch detail.Status {
+		case "active":
+			continue
+		case "completed":
+			snapshot = detail.SnapshotId
+		default:
+			return "", fmt.Errorf("import snapshot task %v: %v %v",
+				task.ImportTaskId, detail.Status, detail.StatusMessage)
+		}
+	}
+	awsArch := "x86_64"
+	if arch == "arm64" {
+		awsArch = "arm64"
+	}
+	const rootDevice = "/dev/xvda"
+	var res struct {
+		ImageId string
+	}
+	if _, err := ctx.run(&res, "ec2", "register-image",
+		"--name", imageName,
+		"--architecture", awsArch,
+		"--virtualization-type", "hvm",
+		"--ena-support",
+		"--root-device-name", rootDevice,
+		"--block-device-mappings",
+		fmt.Sprintf("DeviceName=%v,Ebs={SnapshotId=%v,DeleteOnTermination=true}", rootDevice, snapshot),
+	); err != nil {
+		return "", fmt.Errorf("failed to register image: %v", err)
+	}
+	return res.ImageId, nil
+}
+
+func (ctx *ec2) DeleteImage(imageName string) error {
+	var res struct {
+		Images []struct {
+			ImageId string
+		}
+	}
+	if _, err := ctx.run(&res, "ec2", "describe-images", "--owners", "self",
+		"--filters", "Name=name,Values="+imageName); err != nil {
+		return err
+	}
+	for _, image := range res.Images {
+		if _, err := ctx.run(nil, "ec2", "deregister-image", "--image-id", image.ImageId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ctx *ec2) run(res interface{}, args ...string) ([]byte, error) {
+	return ctx.runTimeout(10*time.Minute, res, args...)
+}
+
+// runTimeout runs the aws tool and unmarshals its json output into res (if not nil).
+func (ctx *ec2) runTimeout(timeout time.Duration, res interface{}, args ...string) ([]byte, error) {
+	args = append([]string{"--region", ctx.Region, "--output", "json"}, args...)
+	if ctx.debug {
+		Logf(0, "running command: aws %#v", args)
+	}
+	output, err := osutil.RunCmd(timeout, "", "aws", args...)
+	if ctx.debug {
+		Logf(0, "result: %v\n%s", err, output)
+	}
+	if err != nil {
+		return output, err
+	}
+	if res != nil {
+		if err := json.Unmarshal(output, res); err != nil {
+			return output, fmt.Errorf("failed to parse aws output: %v\n%s", err, output)
+		}
+	}
+	return output, nil
+}
+
+// getMeta queries instance metadata service (IMDSv2).
+func getMeta(path string) (string, error) {
+	const metaURL = "http://169.254.169.254/latest/"
+	req, err := http.NewRequest("PUT", metaURL+"api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := doMetaRequest(req)
+	if err != nil {
+		return "", err
+	}
+	req, err = http.NewRequest("GET", metaURL+"meta-data/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("X-aws-ec2-metadata-token", token)
+	return doMetaRequest(req)
+}
+
+func doMetaRequest(req *http.Request) (string, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata request failed: %v\n%s", resp.Status, body)
+	}
+	return string(body), nil
+}
@@ -0,0 +1,420 @@
+// Copyright 2017 syzkaller project authors. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+
+// Package ipmi implements VMs on bare-metal lab machines.
+// Machines are power cycled via IPMI (or a custom command, e.g. for a PDU),
+// kernel console is captured via IPMI serial-over-LAN and everything else
+// (copying syz-executor and friends, running commands) is done over ssh.
+// The kernel can be deployed via PXE (the machines are asked to boot from network
+// on every power cycle), otherwise the kernel installed on the machine is used.
+package ipmi
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/syzkaller/pkg/config"
+	. "github.com/google/syzkaller/pkg/log"
+	"github.com/google/syzkaller/pkg/osutil"
+	"github.com/google/syzkaller/vm/vmimpl"
+)
+
+func init() {
+	vmimpl.Register("ipmi", ctor)
+}
+
+type Config struct {
+	Machines        []*Machine // bare-metal machines to fuzz
+	Target_Dir      string     // directory to copy/run on machines ("/tmp/syzkaller" by default)
+	Pxe             bool       // boot machines from network on every power cycle
+	Power_Cycle_Cmd string     // host command to power cycle a machine instead of IPMI (e.g. PDU script), gets addr as arg
+	Boot_Timeout    int        // seconds to wait for a machine to come up after power cycle (600 by default)
+}
+
+type Machine struct {
+	Addr         string // ssh address of the machine
+	Bmc_Addr     string // IPMI BMC address of the machine
+	Bmc_User     string // IPMI user (optional)
+	Bmc_Password string // IPMI password (optional)
+}
+
+type Pool struct {
+	env *vmimpl.Env
+	cfg *Config
+}
+
+type instance struct {
+	cfg     *Config
+	machine *Machine
+	workdir string
+	target  string
+	closed  chan bool
+	debug   bool
+	sshkey  string
+	port    int
+}
+
+func ctor(env *vmimpl.Env) (vmimpl.Pool, error) {
+	cfg := &Config{
+		Target_Dir:   "/tmp/syzkaller",
+		Boot_Timeout: 600,
+	}
+	if err := config.LoadData(env.Config, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse ipmi vm config: %v", err)
+	}
+	if len(cfg.Machines) == 0 {
+		return nil, fmt.Errorf("config param machines is empty")
+	}
+	for i, m := range cfg.Machines {
+		if m.Addr == "" {
+			return nil, fmt.Errorf("config param machines[%v].addr is empty", i)
+		}
+		if m.Bmc_Addr == "" {
+			return nil, fmt.Errorf("config param machines[%v].bmc_addr is empty", i)
+		}
+	}
+	if cfg.Target_Dir == "" {
+		return nil, fmt.Errorf("config param target_dir is empty")
+	}
+	if cfg.Boot_Timeout <= 0 {
+		return nil, fmt.Errorf("config param boot_timeout must be positive")
+	}
+	if _, err := exec.LookPath("ipmitool"); err != nil {
+		return nil, fmt.Errorf("ipmitool is not found: %v", err)
+	}
+	// sshkey is optional
+	if env.SshKey != "" && !osutil.IsExist(env.SshKey) {
+		return nil, fmt.Errorf("ssh key '%v' does not exist", env.SshKey)
+	}
+	if env.Debug {
+		cfg.Machines = cfg.Machines[:1]
+	}
+	pool := &Pool{
+		cfg: cfg,
+		env: env,
+	}
+	return pool, nil
+}
+
+func (pool *Pool) Count() int {
+	return len(pool.cfg.Machines)
+}
+
+func (pool *Pool) Create(workdir string, index int) (vmimpl.Instance, error) {
+	machine := pool.cfg.Machines[index]
+	inst := &instance{
+		cfg:     pool.cfg,
+		machine: machine,
+		workdir: workdir,
+		target:  pool.env.SshUser + "@" + machine.Addr,
+		closed:  make(chan bool),
+		debug:   pool.env.Debug,
+		sshkey:  pool.env.SshKey,
+	}
+	if machine.Bmc_Password != "" {
+		// Don't pass the password on command line, it would be visible in ps.
+		if err := ioutil.WriteFile(inst.passwordFile(), []byte(machine.Bmc_Password), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write ipmi password file: %v", err)
+		}
+	}
+	closeInst := inst
+	defer func() {
+		if closeInst != nil {
+			closeInst.Close()
+		}
+	}()
+	if err := inst.boot(); err != nil {
+		return nil, err
+	}
+
+	// Create working dir if doesn't exist.
+	inst.ssh("mkdir -p '" + inst.cfg.Target_Dir + "'")
+
+	// Remove temp files from previous runs.
+	inst.ssh("rm -rf '" + filepath.Join(inst.cfg.Target_Dir, "*") + "'")
+
+	closeInst = nil
+	return inst, nil
+}
+
+// boot power cycles the machine and waits until it is reachable over ssh.
+// Console output is captured during boot and returned with the error if the machine does not come up.
+func (inst *instance) boot() error {
+	con, err := inst.openConsole()
+	if err != nil {
+		return err
+	}
+	output := new(consoleBuffer)
+	done := make(chan bool)
+	go func() {
+		io.Copy(output, con)
+		close(done)
+	}()
+	defer func() {
+		con.Close()
+		<-done
+	}()
+	if err := inst.powerCycle(); err != nil {
+		return err
+	}
+	if err := inst.waitForSsh(time.Duration(inst.cfg.Boot_Timeout) * time.Second); err != nil {
+		con.Close()
+		<-done
+		return fmt.Errorf("%v\nconsole output:\n%s", err, output.Bytes())
+	}
+	return nil
+}
+
+func (inst *instance) powerCycle() error {
+	if inst.cfg.Pxe {
+		if _, err := inst.ipmitool("chassis", "bootdev", "pxe"); err != nil {
+			return err
+		}
+	}
+	Logf(2, "ipmi: power cycling %v", inst.machine.Addr)
+	if inst.cfg.Power_Cycle_Cmd != "" {
+		if _, err := osutil.RunCmd(5*time.Minute, "", inst.cfg.Power_Cycle_Cmd, inst.machine.Addr); err != nil {
+			return fmt.Errorf("failed to power cycle %v: %v", inst.machine.Addr, err)
+		}
+		return nil
+	}
+	// Note: "chassis power cycle" fails if the machine is powered off.
+	if _, err := inst.ipmitool("chassis", "power", "off"); err != nil {
+		return err
+	}
+	if !vmimpl.SleepInterruptible(10 * time.Second) {
+		return fmt.Errorf("shutdown in progress")
+	}
+	if _, err := inst.ipmitool("chassis", "power", "on"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// openConsole opens serial-over-LAN console of the machine.
+// Kernel must be configured to write console output to the serial port used by SOL
+// (e.g. console=ttyS1,115200).
+func (inst *instance) openConsole() (io.ReadCloser, error) {
+	// Only one SOL session can be active, kill the stale one (if any).
+	inst.ipmitool("sol", "deactivate")
+	return vmimpl.OpenCommandConsole("ipmitool", inst.ipmitoolArgs("sol", "activate")...)
+}
+
+func (inst *instance) ipmitool(args ...string) ([]byte, error) {
+	args = inst.ipmitoolArgs(args...)
+	if inst.debug {
+		Logf(0, "running command: ipmitool %#v", args)
+	}
+	out, err := osutil.RunCmd(time.Minute, "", "ipmitool", args...)
+	if err != nil && inst.debug {
+		Logf(0, "ipmitool failed: %v", err)
+	}
+	return out, err
+}
+
+func (inst *instance) ipmitoolArgs(args ...string) []string {
+	res := []string{"-I", "lanplus", "-H", inst.machine.Bmc_Addr}
+	if inst.machine.Bmc_User != "" {
+		res = append(res, "-U", inst.machine.Bmc_User)
+	}
+	if inst.machine.Bmc_Password != "" {
+		res = append(res, "-f", inst.passwordFile())
+	}
+	return append(res, args...)
+}
+
+func (inst *instance) passwordFile() string {
+	return filepath.Join(inst.workdir, "ipmi.password")
+}
+
+func (inst *instance) Forward(port int) (string, error) {
+	if inst.port != 0 {
+		return "", fmt.Errorf("ipmi: Forward port already set")
+	}
+	if port == 0 {
+		return "", fmt.Errorf("ipmi: Forward port is zero")
+	}
+	inst.port = port
+	return fmt.Sprintf("127.0.0.1:%v", port), nil
+}
+
+func (inst *instance) ssh(command string) ([]byte, error) {
+	args := append(inst.sshArgs("-p"), inst.target, command)
+	if inst.debug {
+		Logf(0, "running command: ssh %#v", args)
+	}
+	out, err := osutil.RunCmd(time.Minute, "", "ssh", args...)
+	if err != nil {
+		if inst.debug {
+			Logf(0, "ssh failed: %v", err)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+func (inst *instance) waitForSsh(timeout time.Duration) error {
+	var err error
+	start := time.Now()
+	for {
+		if !vmimpl.SleepInterruptible(10 * time.Second) {
+			return fmt.Errorf("shutdown in progress")
+		}
+		if _, err = inst.ssh("pwd"); err == nil {
+			return nil
+		}
+		if time.Since(start) > timeout {
+			break
+		}
+	}
+	return fmt.Errorf("ipmi: machine %v did not come up after power cycle: %v", inst.machine.Addr, err)
+}
+
+func (inst *instance) Close() {
+	close(inst.closed)
+	os.Remove(inst.passwordFile())
+}
+
+func (inst *instance) Copy(hostSrc string) (string, error) {
+	baseName := filepath.Base(hostSrc)
+	vmDst := filepath.Join(inst.cfg.Target_Dir, baseName)
+	inst.ssh("pkill -9 '" + baseName + "'; rm -f '" + vmDst + "'")
+	args := append(inst.sshArgs("-P"), hostSrc, inst.target+":"+vmDst)
+	if inst.debug {
+		Logf(0, "running command: scp %#v", args)
+	}
+	if _, err := osutil.RunCmd(3*time.Minute, "", "scp", args...); err != nil {
+		return "", err
+	}
+	return vmDst, nil
+}
+
+func (inst *instance) Run(timeout time.Duration, stop <-chan bool, command string) (<-chan []byte, <-chan error, error) {
+	con, err := inst.openConsole()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rpipe, wpipe, err := osutil.LongPipe()
+	if err != nil {
+		con.Close()
+		return nil, nil, err
+	}
+
+	args := inst.sshArgs("-p")
+	// Forward target port as part of the ssh connection (reverse proxy).
+	if inst.port != 0 {
+		proxy := fmt.Sprintf("%v:127.0.0.1:%v", inst.port, inst.port)
+		args = append(args, "-R", proxy)
+	}
+	args = append(args, inst.target, "cd "+inst.cfg.Target_Dir+" && exec "+command)
+	if inst.debug {
+		Logf(0, "running command: ssh %#v", args)
+	}
+	cmd := exec.Command("ssh", args...)
+	cmd.Stdout = wpipe
+	cmd.Stderr = wpipe
+	if err := cmd.Start(); err != nil {
+		con.Close()
+		rpipe.Close()
+		wpipe.Close()
+		return nil, nil, err
+	}
+	wpipe.Close()
+
+	var tee io.Writer
+	if inst.debug {
+		tee = os.Stdout
+	}
+	merger := vmimpl.NewOutputMerger(tee)
+	merger.Add("console", con)
+	merger.Add("ssh", rpipe)
+
+	errc := make(chan error, 1)
+	signal := func(err error) {
+		select {
+		case errc <- err:
+		default:
+		}
+	}
+
+	go func() {
+		select {
+		case <-time.After(timeout):
+			signal(vmimpl.TimeoutErr)
+		case <-stop:
+			signal(vmimpl.TimeoutErr)
+		case <-inst.closed:
+			if inst.debug {
+				Logf(0, "instance closed")
+			}
+			signal(fmt.Errorf("instance closed"))
+		case err := <-merger.Err:
+			cmd.Process.Kill()
+			con.Close()
+			merger.Wait()
+			if cmdErr := cmd.Wait(); cmdErr == nil {
+				// If the command exited successfully, we got EOF error from merger.
+				// But in this case no error has happened and the EOF is expected.
+				err = nil
+			}
+			signal(err)
+			return
+		}
+		cmd.Process.Kill()
+		con.Close()
+		merger.Wait()
+		cmd.Wait()
+	}()
+	return merger.Output, errc, nil
+}
+
+func (inst *instance) sshArgs(portArg string) []string {
+	args := []string{
+		portArg, "22",
+		"-o", "ConnectionAttempts=10",
+		"-o", "ConnectTimeout=10",
+		"-o", "BatchMode=yes",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "IdentitiesOnly=yes",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "LogLevel=error",
+	}
+	if inst.sshkey != "" {
+		args = append(args, "-i", inst.sshkey)
+	}
+	if inst.debug {
+		args = append(args, "-v")
+	}
+	return args
+}
+
+// consoleBuffer keeps the tail of boot console output.
+type consoleBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+const maxBootOutput = 128 << 10
+
+func (b *consoleBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, data...)
+	if len(b.buf) > maxBootOutput {
+		b.buf = b.buf[len(b.buf)-maxBootOutput:]
+	}
+	return len(data), nil
+}
+
+func (b *consoleBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte{}, b.buf...)
+}
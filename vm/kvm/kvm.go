@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -85,6 +86,9 @@ func ctor(env *vmimpl.Env) (vmimpl.Pool, error) {
 	if cfg.Mem < 128 || cfg.Mem > 1048576 {
 		return nil, fmt.Errorf("invalid config param mem: %v, want [128-1048576]", cfg.Mem)
 	}
+	if env.Cmdline != "" {
+		cfg.Cmdline = strings.TrimSpace(cfg.Cmdline + " " + env.Cmdline)
+	}
 	cfg.Kernel = osutil.Abs(cfg.Kernel)
 	pool := &Pool{
 		cfg: cfg,
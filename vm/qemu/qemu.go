@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/syzkaller/pkg/config"
@@ -23,6 +24,8 @@ import (
 
 const (
 	hostAddr = "10.0.2.10"
+	// If there are no heartbeats from the guest for this long, we consider it hung.
+	heartbeatTimeout = time.Minute
 )
 
 func init() {
@@ -38,6 +41,10 @@ type Config struct {
 	Initrd    string // linux initial ramdisk. (optional)
 	Cpu       int    // number of VM CPUs
 	Mem       int    // amount of VM memory in MBs
+	// Use a dedicated virtio-serial channel for command output and guest heartbeats
+	// (requires CONFIG_VIRTIO_CONSOLE in the guest kernel). Command output then does
+	// not depend on ssh connection, and guest hangs are detected faster.
+	Virtio_Serial bool
 }
 
 type Pool struct {
@@ -46,6 +53,8 @@ type Pool struct {
 }
 
 type instance struct {
+	lastHeartbeat int64 // unix nanoseconds, accessed atomically; first for alignment
+
 	cfg     *Config
 	image   string
 	debug   bool
@@ -53,6 +62,8 @@ type instance struct {
 	sshkey  string
 	sshuser string
 	port    int
+	logPort int
+	hbPort  int
 	rpipe   io.ReadCloser
 	wpipe   io.WriteCloser
 	qemu    *exec.Cmd
@@ -133,6 +144,12 @@ func ctor(env *vmimpl.Env) (vmimpl.Pool, error) {
 	if cfg.Mem < 128 || cfg.Mem > 1048576 {
 		return nil, fmt.Errorf("bad qemu mem: %v, want [128-1048576]", cfg.Mem)
 	}
+	if env.Cmdline != "" {
+		if cfg.Kernel == "" {
+			return nil, fmt.Errorf("additional kernel cmdline requires kernel")
+		}
+		cfg.Cmdline = strings.TrimSpace(cfg.Cmdline + " " + env.Cmdline)
+	}
 	cfg.Kernel = osutil.Abs(cfg.Kernel)
 	cfg.Initrd = osutil.Abs(cfg.Initrd)
 	pool := &Pool{
@@ -223,15 +240,7 @@ func (inst *instance) Close() {
 }
 
 func (inst *instance) Boot() error {
-	for {
-		// Find an unused TCP port.
-		inst.port = rand.Intn(64<<10-1<<10) + 1<<10
-		ln, err := net.Listen("tcp", fmt.Sprintf("localhost:%v", inst.port))
-		if err == nil {
-			ln.Close()
-			break
-		}
-	}
+	inst.port = unusedTCPPort()
 	// TODO: ignores inst.cfg.Cpu
 	args := []string{
 		"-m", strconv.Itoa(inst.cfg.Mem),
@@ -244,6 +253,17 @@ func (inst *instance) Boot() error {
 		"-smp", "sockets=2,cores=2,threads=1",
 	}
 	args = append(args, strings.Split(inst.cfg.Qemu_Args, " ")...)
+	if inst.cfg.Virtio_Serial {
+		inst.logPort = unusedTCPPort()
+		inst.hbPort = unusedTCPPort()
+		args = append(args,
+			"-device", "virtio-serial-pci,id=syzserial",
+			"-chardev", fmt.Sprintf("socket,id=syzlog,host=localhost,port=%v,server,nowait", inst.logPort),
+			"-device", "virtserialport,bus=syzserial.0,chardev=syzlog,name=syzkaller.log",
+			"-chardev", fmt.Sprintf("socket,id=syzhb,host=localhost,port=%v,server,nowait", inst.hbPort),
+			"-device", "virtserialport,bus=syzserial.0,chardev=syzhb,name=syzkaller.heartbeat",
+		)
+	}
 	if inst.image == "9p" {
 		args = append(args,
 			"-fsdev", "local,id=fsdev0,path=/,security_model=none,readonly",
@@ -379,9 +399,79 @@ func (inst *instance) Boot() error {
 		}
 	}
 	bootOutputStop <- true
+	if inst.cfg.Virtio_Serial {
+		if err := inst.setupVirtioSerial(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// virtioSerialSetup is executed in the guest after boot. It starts forwarding of
+// /tmp/syz-log fifo (where Run redirects command output) to the log port
+// (writers don't need to open the port, which can be opened only once),
+// and a loop that writes heartbeats to the heartbeat port.
+const virtioSerialSetup = `rm -f /tmp/syz-log && mkfifo /tmp/syz-log && ` +
+	`(nohup cat 0<>/tmp/syz-log >/dev/virtio-ports/syzkaller.log 2>/dev/null &) && ` +
+	`(nohup sh -c "while true; do echo; sleep 1; done" ` +
+	`>/dev/virtio-ports/syzkaller.heartbeat 2>/dev/null </dev/null &)`
+
+func (inst *instance) setupVirtioSerial() error {
+	logConn, err := net.Dial("tcp", fmt.Sprintf("localhost:%v", inst.logPort))
+	if err != nil {
+		return fmt.Errorf("failed to connect to virtio-serial log port: %v", err)
+	}
+	inst.merger.Add("log", logConn)
+	hbConn, err := net.Dial("tcp", fmt.Sprintf("localhost:%v", inst.hbPort))
+	if err != nil {
+		return fmt.Errorf("failed to connect to virtio-serial heartbeat port: %v", err)
+	}
+	heartbeatC := make(chan bool, 1)
+	go func() {
+		var buf [64]byte
+		for {
+			if _, err := hbConn.Read(buf[:]); err != nil {
+				hbConn.Close()
+				return
+			}
+			atomic.StoreInt64(&inst.lastHeartbeat, time.Now().UnixNano())
+			select {
+			case heartbeatC <- true:
+			default:
+			}
+		}
+	}()
+	args := append(inst.sshArgs("-p"), inst.sshuser+"@localhost", virtioSerialSetup)
+	if inst.debug {
+		Logf(0, "running command: ssh %#v", args)
+	}
+	if out, err := osutil.RunCmd(time.Minute, "", "ssh", args...); err != nil {
+		return fmt.Errorf("failed to setup virtio-serial in guest: %v\n%s", err, out)
+	}
+	select {
+	case <-heartbeatC:
+		return nil
+	case <-time.After(10 * time.Second):
+		return fmt.Errorf("no heartbeats from guest (is CONFIG_VIRTIO_CONSOLE enabled?)")
+	}
+}
+
+func (inst *instance) heartbeatLost() bool {
+	last := time.Unix(0, atomic.LoadInt64(&inst.lastHeartbeat))
+	return time.Since(last) > heartbeatTimeout
+}
+
+func unusedTCPPort() int {
+	for {
+		port := rand.Intn(64<<10-1<<10) + 1<<10
+		ln, err := net.Listen("tcp", fmt.Sprintf("localhost:%v", port))
+		if err == nil {
+			ln.Close()
+			return port
+		}
+	}
+}
+
 func (inst *instance) Forward(port int) (string, error) {
 	return fmt.Sprintf("%v:%v", hostAddr, port), nil
 }
@@ -425,6 +515,9 @@ func (inst *instance) Run(timeout time.Duration, stop <-chan bool, command strin
 	}
 	inst.merger.Add("ssh", rpipe)
 
+	if inst.cfg.Virtio_Serial {
+		command = fmt.Sprintf("(%v) >/tmp/syz-log 2>&1", command)
+	}
 	args := append(inst.sshArgs("-p"), inst.sshuser+"@localhost", command)
 	if inst.debug {
 		Logf(0, "running command: ssh %#v", args)
@@ -446,20 +539,41 @@ func (inst *instance) Run(timeout time.Duration, stop <-chan bool, command strin
 	}
 
 	go func() {
-		select {
-		case <-time.After(timeout):
-			signal(vmimpl.TimeoutErr)
-		case <-stop:
-			signal(vmimpl.TimeoutErr)
-		case err := <-inst.merger.Err:
-			cmd.Process.Kill()
-			if cmdErr := cmd.Wait(); cmdErr == nil {
-				// If the command exited successfully, we got EOF error from merger.
-				// But in this case no error has happened and the EOF is expected.
-				err = nil
+		var watchdog <-chan time.Time
+		if inst.cfg.Virtio_Serial {
+			ticker := time.NewTicker(5 * time.Second)
+			defer ticker.Stop()
+			watchdog = ticker.C
+		}
+		timeoutC := time.After(timeout)
+	loop:
+		for {
+			select {
+			case <-timeoutC:
+				signal(vmimpl.TimeoutErr)
+				break loop
+			case <-stop:
+				signal(vmimpl.TimeoutErr)
+				break loop
+			case <-watchdog:
+				if inst.heartbeatLost() {
+					Logf(1, "qemu: no heartbeats from guest for %v", heartbeatTimeout)
+					signal(vmimpl.HangErr)
+					break loop
+				}
+			case err := <-inst.merger.Err:
+				cmd.Process.Kill()
+				if cmdErr := cmd.Wait(); cmdErr == nil {
+					// If the command exited successfully, we got EOF error from merger.
+					// But in this case no error has happened and the EOF is expected.
+					err = nil
+				} else if inst.cfg.Virtio_Serial && inst.heartbeatLost() {
+					// The connection was lost because the guest is hung.
+					err = vmimpl.HangErr
+				}
+				signal(err)
+				return
 			}
-			signal(err)
-			return
 		}
 		cmd.Process.Kill()
 		cmd.Wait()
@@ -19,7 +19,9 @@ import (
 	"github.com/google/syzkaller/vm/vmimpl"
 
 	_ "github.com/google/syzkaller/vm/adb"
+	_ "github.com/google/syzkaller/vm/aws"
 	_ "github.com/google/syzkaller/vm/gce"
+	_ "github.com/google/syzkaller/vm/ipmi"
 	_ "github.com/google/syzkaller/vm/isolated"
 	_ "github.com/google/syzkaller/vm/kvm"
 	_ "github.com/google/syzkaller/vm/odroid"
@@ -42,6 +44,7 @@ type Env vmimpl.Env
 var (
 	Shutdown   = vmimpl.Shutdown
 	TimeoutErr = vmimpl.TimeoutErr
+	HangErr    = vmimpl.HangErr
 )
 
 func Create(typ string, env *Env) (*Pool, error) {
@@ -161,6 +164,9 @@ func MonitorExecution(outc <-chan []byte, errc <-chan error, needOutput bool,
 				return extractError("")
 			case TimeoutErr:
 				return err.Error(), nil, nil, false, true
+			case HangErr:
+				// The kernel may have printed something about the hang.
+				return extractError("no heartbeat from test machine")
 			default:
 				// Note: connection lost can race with a kernel oops message.
 				// In such case we want to return the kernel oops.
@@ -78,18 +78,23 @@ func (t *tty) Close() error {
 
 // Open dmesg remotely
 func OpenRemoteConsole(bin string, args ...string) (rc io.ReadCloser, err error) {
+	return OpenCommandConsole(bin, append(args, "dmesg -w")...)
+}
+
+// OpenCommandConsole provides console output of a long-running host command
+// (e.g. "ipmitool sol activate" that streams serial console of a physical machine).
+func OpenCommandConsole(bin string, args ...string) (rc io.ReadCloser, err error) {
 	rpipe, wpipe, err := osutil.LongPipe()
 	if err != nil {
 		return nil, err
 	}
-	args = append(args, "dmesg -w")
 	cmd := exec.Command(bin, args...)
 	cmd.Stdout = wpipe
 	cmd.Stderr = wpipe
 	if err := cmd.Start(); err != nil {
 		rpipe.Close()
 		wpipe.Close()
-		return nil, fmt.Errorf("failed to start adb: %v", err)
+		return nil, fmt.Errorf("failed to start %v: %v", bin, err)
 	}
 	wpipe.Close()
 	con := &remoteCon{
@@ -52,8 +52,12 @@ type Env struct {
 	Image   string
 	SshKey  string
 	SshUser string
+	Cmdline string // additional kernel command line (supported only by VM types that boot the kernel)
 	Debug   bool
 	Config  []byte // json-serialized VM-type-specific config
+	// Only validate the config and return a pool that must not be used,
+	// without allocating any resources (e.g. uploading images to cloud).
+	Check bool
 }
 
 // Create creates a VM type that can be used to create individual VMs.
@@ -74,6 +78,9 @@ var (
 	// Close to interrupt all pending operations in all VMs.
 	Shutdown   = make(chan struct{})
 	TimeoutErr = errors.New("timeout")
+	// HangErr is returned from Instance.Run if the VM implementation detected
+	// that the machine stopped responding (e.g. guest heartbeats are missing).
+	HangErr = errors.New("hang")
 
 	ctors = make(map[string]ctorFunc)
 )